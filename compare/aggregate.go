@@ -0,0 +1,205 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+// tCritical95 holds two-sided 95% Student's t critical values indexed by degrees of freedom
+var tCritical95 = []float64{
+	0, 12.706, 4.303, 3.182, 2.776, 2.571, 2.447, 2.365, 2.306, 2.262, 2.228,
+	2.201, 2.179, 2.160, 2.145, 2.131, 2.120, 2.110, 2.101, 2.093, 2.086,
+	2.080, 2.074, 2.069, 2.064, 2.060, 2.056, 2.052, 2.048, 2.045, 2.042,
+}
+
+// MetricStats describes the spread of one metric over repeated runs
+type MetricStats struct {
+	Metric string  `json:"metric"`
+	Runs   int     `json:"runs"`
+	Mean   float64 `json:"mean"`
+	StdDev float64 `json:"stdDev"`
+	Min    float64 `json:"min"`
+	Max    float64 `json:"max"`
+	CILow  float64 `json:"ci95Low"`
+	CIHigh float64 `json:"ci95High"`
+	// CV is the coefficient of variation (stddev / mean) in percent
+	CV float64 `json:"cvPct"`
+}
+
+// Aggregate is the document written by -aggregate mode
+type Aggregate struct {
+	GeneratedAt string         `json:"generatedAt"`
+	Files       []string       `json:"files"`
+	Metrics     []*MetricStats `json:"metrics"`
+}
+
+// aggregatedMetric is one metric summarized across runs
+type aggregatedMetric struct {
+	name  string
+	value func(*PlatformSummary) (float64, bool)
+}
+
+// aggregatedMetrics lists the metrics summarized across runs, in display order
+func aggregatedMetrics() []aggregatedMetric {
+	metrics := []aggregatedMetric{
+		{"actualRPS", func(p *PlatformSummary) (float64, bool) { return p.ActualRPS, p.TotalRequests > 0 }},
+		{"errorRatePct", func(p *PlatformSummary) (float64, bool) { return p.ErrorRate, p.TotalRequests > 0 }},
+		{"totalRequests", func(p *PlatformSummary) (float64, bool) { return float64(p.TotalRequests), true }},
+	}
+	for _, key := range comparedPercentiles {
+		metrics = append(metrics, aggregatedMetric{key + "Ms", latencyValue(key)})
+	}
+	return append(metrics, aggregatedMetric{"meanMs", latencyValue("mean")})
+}
+
+// latencyValue returns an accessor for one latency field
+func latencyValue(key string) func(*PlatformSummary) (float64, bool) {
+	return func(p *PlatformSummary) (float64, bool) {
+		v, ok := p.LatencyMs[key]
+		return v, ok
+	}
+}
+
+// computeStats calculates mean, sample standard deviation and a 95% confidence
+// interval for the mean using the t distribution
+func computeStats(metric string, values []float64) *MetricStats {
+	s := &MetricStats{Metric: metric, Runs: len(values)}
+	if len(values) == 0 {
+		return s
+	}
+
+	s.Min, s.Max = values[0], values[0]
+	var sum float64
+	for _, v := range values {
+		sum += v
+		s.Min = math.Min(s.Min, v)
+		s.Max = math.Max(s.Max, v)
+	}
+	s.Mean = sum / float64(len(values))
+
+	if len(values) > 1 {
+		var sq float64
+		for _, v := range values {
+			sq += (v - s.Mean) * (v - s.Mean)
+		}
+		s.StdDev = math.Sqrt(sq / float64(len(values)-1))
+
+		df := len(values) - 1
+		t := 1.96
+		if df < len(tCritical95) {
+			t = tCritical95[df]
+		}
+		margin := t * s.StdDev / math.Sqrt(float64(len(values)))
+		s.CILow, s.CIHigh = s.Mean-margin, s.Mean+margin
+	} else {
+		s.CILow, s.CIHigh = s.Mean, s.Mean
+	}
+
+	if s.Mean != 0 {
+		s.CV = s.StdDev / math.Abs(s.Mean) * 100
+	}
+	return s
+}
+
+// aggregateRuns summarizes the results of repeated identical runs
+func aggregateRuns(runs []*PlatformSummary) *Aggregate {
+	a := &Aggregate{}
+	for _, run := range runs {
+		a.Files = append(a.Files, run.File)
+	}
+
+	for _, m := range aggregatedMetrics() {
+		var values []float64
+		for _, run := range runs {
+			if v, ok := m.value(run); ok {
+				values = append(values, v)
+			}
+		}
+		if len(values) > 0 {
+			a.Metrics = append(a.Metrics, computeStats(m.name, values))
+		}
+	}
+	return a
+}
+
+// printAggregate writes the per-metric spread as a table
+func printAggregate(a *Aggregate) {
+	fmt.Printf("Aggregated %d runs\n\n", len(a.Files))
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Metric\tRuns\tMean\tStdDev\tCV\t95% CI\tMin\tMax")
+	for _, s := range a.Metrics {
+		fmt.Fprintf(w, "%s\t%d\t%.2f\t%.2f\t%.1f%%\t[%.2f, %.2f]\t%.2f\t%.2f\n",
+			s.Metric, s.Runs, s.Mean, s.StdDev, s.CV, s.CILow, s.CIHigh, s.Min, s.Max)
+	}
+	w.Flush()
+
+	for _, s := range a.Metrics {
+		if s.Runs < 3 {
+			fmt.Println("\nWarning: fewer than 3 runs; confidence intervals are very wide")
+			break
+		}
+	}
+}
+
+// writeAggregateCSV writes the per-metric spread as CSV
+func writeAggregateCSV(out io.Writer, a *Aggregate) error {
+	w := csv.NewWriter(out)
+	if err := w.Write([]string{"metric", "runs", "mean", "stdDev", "cvPct", "ci95Low", "ci95High", "min", "max"}); err != nil {
+		return err
+	}
+	for _, s := range a.Metrics {
+		row := []string{s.Metric, fmt.Sprint(s.Runs), formatFloat(s.Mean), formatFloat(s.StdDev), formatFloat(s.CV),
+			formatFloat(s.CILow), formatFloat(s.CIHigh), formatFloat(s.Min), formatFloat(s.Max)}
+		if err := w.Write(row); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// runAggregate loads the results files of repeated runs and reports their spread
+func runAggregate(files []string, format, outputPath string) {
+	if len(files) < 2 {
+		log.Fatal("-aggregate needs at least two results files as arguments")
+	}
+
+	var runs []*PlatformSummary
+	for _, file := range files {
+		summary, err := loadSummary(file, file)
+		if err != nil {
+			log.Fatalf("Failed to load %s: %v", file, err)
+		}
+		for _, warning := range summary.Warnings {
+			fmt.Fprintf(os.Stderr, "Warning (%s): %s\n", file, warning)
+		}
+		runs = append(runs, summary)
+	}
+	for _, warning := range generatorWarnings(runs) {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
+	}
+
+	result := aggregateRuns(runs)
+	result.GeneratedAt = time.Now().Format(time.RFC3339)
+
+	if format == "csv" {
+		if err := writeAggregateCSV(os.Stdout, result); err != nil {
+			log.Fatalf("Error writing CSV: %v", err)
+		}
+	} else {
+		printAggregate(result)
+	}
+
+	resultJSON, _ := json.MarshalIndent(result, "", "  ")
+	if err := os.WriteFile(outputPath, resultJSON, 0644); err != nil {
+		log.Fatalf("Error writing aggregate file: %v", err)
+	}
+	fmt.Fprintf(os.Stderr, "\nAggregate saved to %s\n", outputPath)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// PlatformCost describes the infrastructure a platform ran on during the test
+type PlatformCost struct {
+	HourlyCost  float64 // USD per instance-hour
+	Instances   int
+	Description string
+}
+
+// CostAnalysis is the cost-normalized view of a platform's results
+type CostAnalysis struct {
+	HourlyCost          float64 `json:"hourlyCost"`
+	Instances           int     `json:"instances"`
+	TotalHourlyCost     float64 `json:"totalHourlyCost"`
+	SuccessfulRPS       float64 `json:"successfulRPS"`
+	CostPer1kRequests   float64 `json:"costPer1kRequests"`
+	RPSPerDollarPerHour float64 `json:"rpsPerDollarPerHour"`
+	Description         string  `json:"description,omitempty"`
+}
+
+// loadCosts reads per-platform cost metadata, keyed by platform name
+func loadCosts(path string) (map[string]PlatformCost, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var costs map[string]PlatformCost
+	if err := json.Unmarshal(data, &costs); err != nil {
+		return nil, fmt.Errorf("parsing %s: %v", path, err)
+	}
+	return costs, nil
+}
+
+// lookupCost finds a platform's cost entry, ignoring case
+func lookupCost(costs map[string]PlatformCost, platform string) (PlatformCost, bool) {
+	for name, cost := range costs {
+		if strings.EqualFold(name, platform) {
+			return cost, true
+		}
+	}
+	return PlatformCost{}, false
+}
+
+// analyzeCost computes cost per 1k successful requests and throughput per dollar.
+// Only successful requests count, so a platform cannot look cheap by failing fast.
+func analyzeCost(p *PlatformSummary, cost PlatformCost) *CostAnalysis {
+	instances := cost.Instances
+	if instances <= 0 {
+		instances = 1
+	}
+
+	a := &CostAnalysis{
+		HourlyCost:      cost.HourlyCost,
+		Instances:       instances,
+		TotalHourlyCost: cost.HourlyCost * float64(instances),
+		SuccessfulRPS:   p.ActualRPS * p.SuccessRate / 100,
+		Description:     cost.Description,
+	}
+
+	if a.SuccessfulRPS > 0 {
+		requestsPerHour := a.SuccessfulRPS * 3600
+		a.CostPer1kRequests = a.TotalHourlyCost / requestsPerHour * 1000
+	}
+	if a.TotalHourlyCost > 0 {
+		a.RPSPerDollarPerHour = a.SuccessfulRPS / a.TotalHourlyCost
+	}
+
+	return a
+}
+
+// costPer1k returns the cost per 1k requests if it could be computed
+func (a *CostAnalysis) costPer1k() (float64, bool) {
+	if a == nil || a.SuccessfulRPS <= 0 || a.TotalHourlyCost <= 0 {
+		return 0, false
+	}
+	return a.CostPer1kRequests, true
+}
+
+// hasCost reports whether any platform has cost metadata
+func hasCost(platforms []*PlatformSummary) bool {
+	for _, p := range platforms {
+		if p.Cost != nil {
+			return true
+		}
+	}
+	return false
+}
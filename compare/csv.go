@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/csv"
+	"io"
+	"math"
+	"strconv"
+)
+
+// writeCSV writes the summary table, one row per platform in rank order, so it
+// can be pasted straight into a spreadsheet
+func writeCSV(out io.Writer, c *Comparison) error {
+	w := csv.NewWriter(out)
+
+	header := []string{"rank", "platform", "score", "totalRequests", "successfulRequests", "failedRequests",
+		"actualRPS", "targetRPS", "errorRatePct"}
+	for _, key := range latencyKeys() {
+		header = append(header, key+"Ms")
+	}
+	header = append(header, "totalHourlyCost", "costPer1kRequests", "rpsPerDollarPerHour")
+	if err := w.Write(header); err != nil {
+		return err
+	}
+
+	byName := make(map[string]*PlatformSummary, len(c.Platforms))
+	for _, p := range c.Platforms {
+		byName[p.Platform] = p
+	}
+
+	for _, score := range c.Ranking {
+		p := byName[score.Platform]
+		row := []string{
+			strconv.Itoa(score.Rank),
+			p.Platform,
+			formatFloat(score.Score),
+			strconv.FormatInt(p.TotalRequests, 10),
+			strconv.FormatInt(p.SuccessfulRequests, 10),
+			strconv.FormatInt(p.FailedRequests, 10),
+			formatFloat(p.ActualRPS),
+			formatFloat(p.TargetRPS),
+			formatFloat(p.ErrorRate),
+		}
+		for _, key := range latencyKeys() {
+			if v, ok := p.LatencyMs[key]; ok {
+				row = append(row, formatFloat(v))
+			} else {
+				row = append(row, "")
+			}
+		}
+		if p.Cost != nil {
+			row = append(row, formatFloat(p.Cost.TotalHourlyCost), formatFloat(p.Cost.CostPer1kRequests),
+				formatFloat(p.Cost.RPSPerDollarPerHour))
+		} else {
+			row = append(row, "", "", "")
+		}
+		if err := w.Write(row); err != nil {
+			return err
+		}
+	}
+
+	w.Flush()
+	return w.Error()
+}
+
+// formatFloat renders a number for CSV, rounded to 4 decimals and without exponent notation
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(math.Round(v*1e4)/1e4, 'f', -1, 64)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// describeGenerator summarizes the generator hardware of a results file:
+// generatorHost of a single run, or generatorHosts of a merged wsm k8s run.
+// The hostname is left out, since identical machines compare fine; "" means
+// the results predate the generator host.
+func describeGenerator(raw map[string]interface{}) string {
+	if host, ok := raw["generatorHost"].(map[string]interface{}); ok {
+		// One agent's share of a distributed run
+		if agents, err := numberValue(host["agents"]); err == nil && agents > 1 {
+			return fmt.Sprintf("%s, 1 of %.0f agents", describeHost(host), agents)
+		}
+		return describeHost(host)
+	}
+	hosts, _ := raw["generatorHosts"].([]interface{})
+	seen := make(map[string]bool)
+	var descriptions []string
+	for _, h := range hosts {
+		host, ok := h.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if d := describeHost(host); !seen[d] {
+			seen[d] = true
+			descriptions = append(descriptions, d)
+		}
+	}
+	if len(descriptions) == 0 {
+		return ""
+	}
+	sort.Strings(descriptions)
+	return fmt.Sprintf("%d agents on %s", len(hosts), strings.Join(descriptions, " + "))
+}
+
+// describeHost renders the hardware of one generatorHost, e.g. "c5.2xlarge
+// in eu-west-1, 8 CPUs (Intel Xeon Platinum 8275CL), 15.3 GiB"
+func describeHost(host map[string]interface{}) string {
+	var parts []string
+	if instanceType, ok := host["instanceType"]; ok {
+		placement := fmt.Sprint(instanceType)
+		if region, ok := host["region"]; ok {
+			placement += fmt.Sprintf(" in %v", region)
+		}
+		parts = append(parts, placement)
+	}
+	cpus := fmt.Sprintf("%v CPUs", host["cpus"])
+	if model, ok := host["cpuModel"]; ok {
+		cpus += fmt.Sprintf(" (%v)", model)
+	}
+	parts = append(parts, cpus)
+	if memory, err := numberValue(host["memoryBytes"]); err == nil && memory > 0 {
+		parts = append(parts, fmt.Sprintf("%.1f GiB", memory/(1<<30)))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// generatorWarnings flags platforms whose results came from different
+// generator hardware, or from an unknown one next to a known one
+func generatorWarnings(platforms []*PlatformSummary) []string {
+	var known, unknown []string
+	generators := make(map[string]bool)
+	for _, p := range platforms {
+		if p.Generator == "" {
+			unknown = append(unknown, p.Platform)
+			continue
+		}
+		known = append(known, fmt.Sprintf("%s on %s", p.Platform, p.Generator))
+		generators[p.Generator] = true
+	}
+	if len(known) == 0 {
+		return nil
+	}
+
+	var warnings []string
+	if len(generators) > 1 {
+		warnings = append(warnings, "The results come from different generators: "+strings.Join(known, "; "))
+	}
+	if len(unknown) > 0 {
+		warnings = append(warnings, fmt.Sprintf("No generator host in the results of %s; they may come from other hardware than %s", strings.Join(unknown, ", "), strings.Join(known, "; ")))
+	}
+	return warnings
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func loadGeneratorSummary(t *testing.T, platform, generator string) *PlatformSummary {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), platform+".json")
+	data := `{"totalRequests": 10, "successfulRequests": 10, "failedRequests": 0` + generator + `}`
+	if err := os.WriteFile(file, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s, err := loadSummary(platform, file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestGeneratorWarnings(t *testing.T) {
+	large := `, "generatorHost": {"hostname": "gen-1", "cpus": 8, "cpuModel": "Xeon", "memoryBytes": 17179869184,
+		"instanceType": "c5.2xlarge", "region": "eu-west-1", "agents": 1}`
+	sameOnOtherHost := strings.Replace(large, "gen-1", "gen-2", 1)
+	small := `, "generatorHost": {"hostname": "gen-1", "cpus": 2, "cpuModel": "Xeon", "agents": 1}`
+	merged := `, "agents": 2, "generatorHosts": [{"cpus": 4, "instanceType": "c5.xlarge", "agents": 2}, {"cpus": 4, "instanceType": "c5.xlarge", "agents": 2}]`
+
+	spree := loadGeneratorSummary(t, "Spree", large)
+	if spree.Generator != "c5.2xlarge in eu-west-1, 8 CPUs (Xeon), 16.0 GiB" {
+		t.Errorf("generator %q", spree.Generator)
+	}
+	if k8s := loadGeneratorSummary(t, "Saleor", merged); k8s.Generator != "2 agents on c5.xlarge, 4 CPUs" {
+		t.Errorf("merged generator %q", k8s.Generator)
+	}
+	if agent := loadGeneratorSummary(t, "Saleor", `, "generatorHost": {"cpus": 4, "agents": 2}`); agent.Generator != "4 CPUs, 1 of 2 agents" {
+		t.Errorf("agent generator %q", agent.Generator)
+	}
+
+	for _, c := range []struct {
+		name      string
+		medusa    string
+		warnings  int
+		mentioned string
+	}{
+		{"same hardware on another host", sameOnOtherHost, 0, ""},
+		{"different hardware", small, 1, "Medusa on 2 CPUs (Xeon)"},
+		{"no generator host", "", 1, "No generator host in the results of Medusa"},
+	} {
+		warnings := generatorWarnings([]*PlatformSummary{loadGeneratorSummary(t, "Medusa", c.medusa), spree})
+		if len(warnings) != c.warnings || c.warnings > 0 && !strings.Contains(warnings[0], c.mentioned) {
+			t.Errorf("%s: warnings %q, want %d mentioning %q", c.name, warnings, c.warnings, c.mentioned)
+		}
+	}
+
+	// Results that predate the generator host are compared without warnings
+	older := []*PlatformSummary{loadGeneratorSummary(t, "Medusa", ""), loadGeneratorSummary(t, "Spree", "")}
+	if warnings := generatorWarnings(older); warnings != nil {
+		t.Errorf("older results: %q", warnings)
+	}
+}
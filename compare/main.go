@@ -0,0 +1,207 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Comparison is the document written to the -output file
+type Comparison struct {
+	GeneratedAt string                           `json:"generatedAt"`
+	Platforms   []*PlatformSummary               `json:"platforms"`
+	Weights     map[string]float64               `json:"weights"`
+	Ranking     []PlatformScore                  `json:"ranking"`
+	Operations  map[string][]OperationComparison `json:"operations,omitempty"`
+
+	// Set when the platforms ran on different generator hardware
+	GeneratorWarnings []string `json:"generatorWarnings,omitempty"`
+}
+
+// compare builds the comparison document for the loaded platforms
+func compare(platforms []*PlatformSummary, weights map[string]float64) *Comparison {
+	return &Comparison{
+		GeneratedAt: time.Now().Format(time.RFC3339),
+		Platforms:   platforms,
+		Weights:     weights,
+		Ranking:     scorePlatforms(platforms, weights),
+		Operations:  compareOperations(platforms),
+
+		GeneratorWarnings: generatorWarnings(platforms),
+	}
+}
+
+// printComparison writes a summary table of the comparison to stdout
+func printComparison(c *Comparison) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Platform\tRequests\tActual RPS\tError Rate"+percentileColumns())
+	for _, p := range c.Platforms {
+		fmt.Fprintf(w, "%s\t%d\t%.2f\t%.2f%%%s\n",
+			p.Platform, p.TotalRequests, p.ActualRPS, p.ErrorRate, formatPercentiles(p.LatencyMs))
+	}
+	w.Flush()
+
+	if c.Weights["cost"] > 0 || hasCost(c.Platforms) {
+		fmt.Println()
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "Platform\tInstances\t$/hour\tSuccessful RPS\t$ per 1k req\tRPS per $/hour")
+		for _, p := range c.Platforms {
+			if p.Cost == nil {
+				continue
+			}
+			fmt.Fprintf(w, "%s\t%d\t%.3f\t%.2f\t%.5f\t%.2f\n",
+				p.Platform, p.Cost.Instances, p.Cost.TotalHourlyCost, p.Cost.SuccessfulRPS,
+				p.Cost.CostPer1kRequests, p.Cost.RPSPerDollarPerHour)
+		}
+		w.Flush()
+	}
+
+	fmt.Println()
+	w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	header := "Rank\tPlatform\tScore"
+	for _, component := range scoreComponents {
+		if c.Weights[component] > 0 {
+			header += fmt.Sprintf("\t%s (%.0f%%)", component, c.Weights[component]*100)
+		}
+	}
+	fmt.Fprintln(w, header)
+	for _, score := range c.Ranking {
+		row := fmt.Sprintf("%d\t%s\t%.1f", score.Rank, score.Platform, score.Score)
+		for _, component := range scoreComponents {
+			if c.Weights[component] > 0 {
+				row += fmt.Sprintf("\t%.1f", score.Breakdown[component])
+			}
+		}
+		fmt.Fprintln(w, row)
+	}
+	w.Flush()
+
+	printOperations(c.Operations)
+
+	for _, p := range c.Platforms {
+		for _, warning := range p.Warnings {
+			fmt.Printf("Warning (%s): %s\n", p.Platform, warning)
+		}
+	}
+	for _, warning := range c.GeneratorWarnings {
+		fmt.Printf("Warning: %s\n", warning)
+	}
+}
+
+// formatMillis formats a latency field for display, or "-" if unavailable
+func formatMillis(latency map[string]float64, key string) string {
+	v, ok := latency[key]
+	if !ok {
+		return "-"
+	}
+	return fmt.Sprintf("%.1fms", v)
+}
+
+// percentileColumns is the header of the table percentiles, each after a tab
+func percentileColumns() string {
+	return "\t" + strings.Join(tablePercentiles, "\t")
+}
+
+// formatPercentiles renders the table percentiles of a latency map, each after a tab
+func formatPercentiles(latency map[string]float64) string {
+	var b strings.Builder
+	for _, key := range tablePercentiles {
+		b.WriteString("\t" + formatMillis(latency, key))
+	}
+	return b.String()
+}
+
+func main() {
+	medusaPath := flag.String("medusa", "", "Path to the Medusa results file")
+	saleorPath := flag.String("saleor", "", "Path to the Saleor results file")
+	spreePath := flag.String("spree", "", "Path to the Spree results file")
+	outputPath := flag.String("output", "comparison.json", "Path to write the comparison JSON to")
+	costsPath := flag.String("costs", "", "Optional JSON file with per-platform infrastructure cost (HourlyCost, Instances)")
+	format := flag.String("format", "text", "Console output format: text or csv")
+	weightsSpec := flag.String("weights", "", "Scoring weights, e.g. throughput=0.4,latencyP95=0.2,errorRate=0.3,consistency=0.1,cost=0")
+	aggregate := flag.Bool("aggregate", false, "Aggregate repeated runs given as arguments (mean, stddev, 95% CI) instead of comparing platforms")
+	percentiles := flag.String("percentiles", "", "Latency percentiles to show, e.g. 50,99,99.9 (default 50,90,95,99; tables show 50,95,99)")
+	flag.Parse()
+
+	if *percentiles != "" {
+		keys, err := parsePercentiles(*percentiles)
+		if err != nil {
+			log.Fatalf("Invalid -percentiles: %v", err)
+		}
+		comparedPercentiles, tablePercentiles = keys, keys
+	}
+
+	if *format != "text" && *format != "csv" {
+		log.Fatalf("Unknown -format %q (expected text or csv)", *format)
+	}
+
+	if *aggregate {
+		output := "aggregate.json"
+		flag.Visit(func(f *flag.Flag) {
+			if f.Name == "output" {
+				output = *outputPath
+			}
+		})
+		runAggregate(flag.Args(), *format, output)
+		return
+	}
+
+	weights, err := parseWeights(*weightsSpec)
+	if err != nil {
+		log.Fatalf("Invalid -weights: %v", err)
+	}
+
+	var costs map[string]PlatformCost
+	if *costsPath != "" {
+		costs, err = loadCosts(*costsPath)
+		if err != nil {
+			log.Fatalf("Failed to load cost metadata: %v", err)
+		}
+	}
+
+	inputs := []struct{ name, path string }{
+		{"Medusa", *medusaPath},
+		{"Saleor", *saleorPath},
+		{"Spree", *spreePath},
+	}
+
+	var platforms []*PlatformSummary
+	for _, in := range inputs {
+		if in.path == "" {
+			continue
+		}
+		summary, err := loadSummary(in.name, in.path)
+		if err != nil {
+			log.Fatalf("Failed to load %s results: %v", in.name, err)
+		}
+		if cost, ok := lookupCost(costs, in.name); ok {
+			summary.Cost = analyzeCost(summary, cost)
+		}
+		platforms = append(platforms, summary)
+	}
+
+	if len(platforms) == 0 {
+		log.Fatal("No results files given; use -medusa, -saleor and/or -spree")
+	}
+
+	comparison := compare(platforms, weights)
+	if *format == "csv" {
+		if err := writeCSV(os.Stdout, comparison); err != nil {
+			log.Fatalf("Error writing CSV: %v", err)
+		}
+	} else {
+		printComparison(comparison)
+	}
+
+	comparisonJSON, _ := json.MarshalIndent(comparison, "", "  ")
+	if err := os.WriteFile(*outputPath, comparisonJSON, 0644); err != nil {
+		log.Fatalf("Error writing comparison file: %v", err)
+	}
+	// Keep stdout clean for CSV so it can be redirected straight to a file
+	fmt.Fprintf(os.Stderr, "\nComparison saved to %s\n", *outputPath)
+}
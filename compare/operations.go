@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// operationAliases maps each runner's operation names to a shared logical name,
+// since the same operation is named differently per platform
+var operationAliases = map[string]string{
+	"products":         "product_list",
+	"categories":       "category_list",
+	"specific_product": "product_detail",
+	"specificproduct":  "product_detail",
+	"specificcategory": "category_detail",
+}
+
+// OperationSummary holds the normalized metrics of one operation on one platform
+type OperationSummary struct {
+	Name           string             `json:"name"`
+	Requests       int64              `json:"requests"`
+	FailedRequests int64              `json:"failedRequests"`
+	ErrorRate      float64            `json:"errorRate"`
+	LatencyMs      map[string]float64 `json:"latencyMs,omitempty"`
+}
+
+// OperationComparison is one platform's entry in a per-operation comparison
+type OperationComparison struct {
+	Platform string `json:"platform"`
+	*OperationSummary
+}
+
+// logicalOperation returns the shared name for a runner's operation name
+func logicalOperation(name string) string {
+	key := strings.ToLower(strings.ReplaceAll(name, "-", "_"))
+	if alias, ok := operationAliases[key]; ok {
+		return alias
+	}
+	if alias, ok := operationAliases[strings.ReplaceAll(key, "_", "")]; ok {
+		return alias
+	}
+	return key
+}
+
+// parseOperations reads the per-operation section of a results file, if present
+func (s *PlatformSummary) parseOperations(raw map[string]interface{}) {
+	ops, ok := raw["operations"].(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	s.Operations = make(map[string]*OperationSummary, len(ops))
+	for name, value := range ops {
+		fields, ok := value.(map[string]interface{})
+		if !ok {
+			s.warn("operations.%s is not an object", name)
+			continue
+		}
+
+		op := &OperationSummary{Name: name}
+		op.Requests = int64(s.number(fields, "requests"))
+		op.FailedRequests = int64(s.number(fields, "failedRequests"))
+		if op.Requests > 0 {
+			op.ErrorRate = float64(op.FailedRequests) / float64(op.Requests) * 100
+		}
+
+		if latency, ok := fields["latency"].(map[string]interface{}); ok {
+			op.LatencyMs = make(map[string]float64, len(latency))
+			for key, v := range latency {
+				ms, err := durationMillis(v)
+				if err != nil {
+					s.warn("operations.%s.latency.%s: %v", name, key, err)
+					continue
+				}
+				op.LatencyMs[key] = ms
+			}
+		}
+
+		s.Operations[logicalOperation(name)] = op
+	}
+}
+
+// compareOperations groups per-operation metrics by logical operation across platforms.
+// Only operations reported by at least two platforms are included.
+func compareOperations(platforms []*PlatformSummary) map[string][]OperationComparison {
+	grouped := make(map[string][]OperationComparison)
+	for _, p := range platforms {
+		for logical, op := range p.Operations {
+			grouped[logical] = append(grouped[logical], OperationComparison{Platform: p.Platform, OperationSummary: op})
+		}
+	}
+
+	for logical, entries := range grouped {
+		if len(entries) < 2 {
+			delete(grouped, logical)
+		}
+	}
+	return grouped
+}
+
+// printOperations writes one table per logical operation shared by several platforms
+func printOperations(operations map[string][]OperationComparison) {
+	names := make([]string, 0, len(operations))
+	for name := range operations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Printf("\nOperation: %s\n", name)
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "Platform\tName\tRequests\tError Rate"+percentileColumns())
+		for _, e := range operations[name] {
+			fmt.Fprintf(w, "%s\t%s\t%d\t%.2f%%%s\n",
+				e.Platform, e.Name, e.Requests, e.ErrorRate, formatPercentiles(e.LatencyMs))
+		}
+		w.Flush()
+	}
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// comparedPercentiles are the percentiles in the CSV and the aggregates, and
+// tablePercentiles those in the console tables. -percentiles sets both, to
+// match runs with a custom Test.Percentiles list.
+var (
+	comparedPercentiles = []string{"p50", "p90", "p95", "p99"}
+	tablePercentiles    = []string{"p50", "p95", "p99"}
+)
+
+// latencyKeys lists the latency fields compared across platforms, in display order
+func latencyKeys() []string {
+	keys := append([]string{"min"}, comparedPercentiles...)
+	return append(keys, "max", "mean")
+}
+
+// parsePercentiles reads a -percentiles list such as "50,99,99.9" into the
+// keys the runners report them under
+func parsePercentiles(spec string) ([]string, error) {
+	var keys []string
+	for _, part := range strings.Split(spec, ",") {
+		p, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil || p <= 0 || p >= 100 {
+			return nil, fmt.Errorf("%q is not a percentile between 0 and 100", part)
+		}
+		keys = append(keys, "p"+strconv.FormatFloat(p, 'f', -1, 64))
+	}
+	return keys, nil
+}
+
+// PlatformSummary holds the normalized headline metrics of one results file
+type PlatformSummary struct {
+	Platform           string                       `json:"platform"`
+	File               string                       `json:"file"`
+	TotalRequests      int64                        `json:"totalRequests"`
+	SuccessfulRequests int64                        `json:"successfulRequests"`
+	FailedRequests     int64                        `json:"failedRequests"`
+	ActualRPS          float64                      `json:"actualRPS"`
+	TargetRPS          float64                      `json:"targetRPS"`
+	SuccessRate        float64                      `json:"successRate"`
+	ErrorRate          float64                      `json:"errorRate"`
+	DurationSeconds    float64                      `json:"durationSeconds"`
+	LatencyMs          map[string]float64           `json:"latencyMs"`
+	Cost               *CostAnalysis                `json:"cost,omitempty"`
+	Operations         map[string]*OperationSummary `json:"operations,omitempty"`
+	Generator          string                       `json:"generator,omitempty"`
+	Warnings           []string                     `json:"warnings,omitempty"`
+}
+
+// loadResults reads a runner results file into a generic map, keeping numbers exact
+func loadResults(path string) (map[string]interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var raw map[string]interface{}
+	if err := decoder.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("parsing %s: %v", path, err)
+	}
+	return raw, nil
+}
+
+// loadSummary reads a results file and normalizes the fields every runner reports.
+// Fields that are missing or malformed are recorded as warnings rather than failing
+// the comparison, since runners have emitted numbers and strings interchangeably.
+func loadSummary(platform, path string) (*PlatformSummary, error) {
+	raw, err := loadResults(path)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &PlatformSummary{
+		Platform:  platform,
+		File:      path,
+		LatencyMs: make(map[string]float64),
+	}
+
+	s.TotalRequests = int64(s.number(raw, "totalRequests"))
+	s.SuccessfulRequests = int64(s.number(raw, "successfulRequests"))
+	s.FailedRequests = int64(s.number(raw, "failedRequests"))
+	s.ActualRPS = s.number(raw, "actualRPS")
+	s.TargetRPS = s.number(raw, "targetRPS")
+
+	if v, ok := raw["testDuration"]; ok {
+		ms, err := durationMillis(v)
+		if err != nil {
+			s.warn("testDuration: %v", err)
+		}
+		s.DurationSeconds = ms / 1000
+	}
+
+	if _, ok := raw["successRate"]; ok {
+		s.SuccessRate = s.number(raw, "successRate")
+	} else if s.TotalRequests > 0 {
+		s.SuccessRate = float64(s.SuccessfulRequests) / float64(s.TotalRequests) * 100
+	}
+	if s.TotalRequests > 0 {
+		s.ErrorRate = float64(s.FailedRequests) / float64(s.TotalRequests) * 100
+	} else {
+		s.ErrorRate = 100 - s.SuccessRate
+	}
+
+	if latency, ok := raw["latency"].(map[string]interface{}); ok {
+		for key, value := range latency {
+			ms, err := durationMillis(value)
+			if err != nil {
+				s.warn("latency.%s: %v", key, err)
+				continue
+			}
+			s.LatencyMs[key] = ms
+		}
+	} else if _, present := raw["latency"]; present {
+		s.warn("latency is not an object")
+	}
+
+	s.parseOperations(raw)
+	s.Generator = describeGenerator(raw)
+
+	return s, nil
+}
+
+// number reads a numeric field, recording a warning if it cannot be parsed
+func (s *PlatformSummary) number(raw map[string]interface{}, key string) float64 {
+	value, ok := raw[key]
+	if !ok {
+		return 0
+	}
+	n, err := numberValue(value)
+	if err != nil {
+		s.warn("%s: %v", key, err)
+	}
+	return n
+}
+
+// warn records a non-fatal parsing problem
+func (s *PlatformSummary) warn(format string, args ...interface{}) {
+	s.Warnings = append(s.Warnings, fmt.Sprintf(format, args...))
+}
+
+// numberValue converts JSON numbers and numeric strings such as "21.59" or
+// "97.50%" to a float
+func numberValue(value interface{}) (float64, error) {
+	switch v := value.(type) {
+	case json.Number:
+		return v.Float64()
+	case float64:
+		return v, nil
+	case int64:
+		return float64(v), nil
+	case int:
+		return float64(v), nil
+	case string:
+		trimmed := strings.TrimSuffix(strings.TrimSpace(v), "%")
+		n, err := strconv.ParseFloat(strings.TrimSpace(trimmed), 64)
+		if err != nil {
+			return 0, fmt.Errorf("%q is not a number", v)
+		}
+		return n, nil
+	case nil:
+		return 0, nil
+	default:
+		return 0, fmt.Errorf("unexpected type %T", value)
+	}
+}
+
+// durationMillis converts a latency value to milliseconds. Go duration strings
+// ("8.41s", "120ms", "1m3s") are parsed as durations; bare numbers, whether
+// JSON numbers or numeric strings, are taken to already be milliseconds.
+func durationMillis(value interface{}) (float64, error) {
+	if str, ok := value.(string); ok {
+		str = strings.TrimSpace(str)
+		if n, err := strconv.ParseFloat(str, 64); err == nil {
+			return n, nil
+		}
+		d, err := time.ParseDuration(str)
+		if err != nil {
+			return 0, fmt.Errorf("%q is neither a duration nor a number", str)
+		}
+		return float64(d) / float64(time.Millisecond), nil
+	}
+	return numberValue(value)
+}
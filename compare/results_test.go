@@ -0,0 +1,219 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func approx(a, b float64) bool {
+	return math.Abs(a-b) < 1e-6
+}
+
+func TestDurationMillis(t *testing.T) {
+	for _, c := range []struct {
+		value interface{}
+		want  float64
+	}{
+		{"8.417392199s", 8417.392199},
+		{"120ms", 120},
+		{"7m0.006294481s", 420006.294481},
+		{"758.418µs", 0.758418},
+		{" 42ns ", 0.000042},
+		{"12.5", 12.5}, // numeric strings are already milliseconds
+		{json.Number("7"), 7},
+		{float64(3.25), 3.25},
+		{int64(40), 40},
+		{nil, 0},
+	} {
+		got, err := durationMillis(c.value)
+		if err != nil {
+			t.Errorf("durationMillis(%#v): %v", c.value, err)
+			continue
+		}
+		if !approx(got, c.want) {
+			t.Errorf("durationMillis(%#v) = %v, want %v", c.value, got, c.want)
+		}
+	}
+
+	for _, bad := range []interface{}{"fast", "10 parsecs", true} {
+		if _, err := durationMillis(bad); err == nil {
+			t.Errorf("durationMillis(%#v) succeeded, want an error", bad)
+		}
+	}
+}
+
+func TestNumberValue(t *testing.T) {
+	for _, c := range []struct {
+		value interface{}
+		want  float64
+	}{
+		{"21.59", 21.59},
+		{"97.50%", 97.5},
+		{" 0.84 % ", 0.84},
+		{"100.00%", 100},
+		{json.Number("16592"), 16592},
+		{json.Number("39.5"), 39.5},
+		{float64(6.5), 6.5},
+		{int64(15), 15},
+		{15, 15},
+		{nil, 0},
+	} {
+		got, err := numberValue(c.value)
+		if err != nil {
+			t.Errorf("numberValue(%#v): %v", c.value, err)
+			continue
+		}
+		if !approx(got, c.want) {
+			t.Errorf("numberValue(%#v) = %v, want %v", c.value, got, c.want)
+		}
+	}
+
+	for _, bad := range []interface{}{"n/a", "%", true, []interface{}{1}} {
+		if _, err := numberValue(bad); err == nil {
+			t.Errorf("numberValue(%#v) succeeded, want an error", bad)
+		}
+	}
+}
+
+// checkSummary asserts what must hold for every well-formed results file
+func checkSummary(t *testing.T, s *PlatformSummary) {
+	t.Helper()
+	if len(s.Warnings) > 0 {
+		t.Errorf("%s: unexpected warnings %v", s.File, s.Warnings)
+	}
+	if s.TotalRequests == 0 || s.SuccessfulRequests+s.FailedRequests != s.TotalRequests {
+		t.Errorf("%s: %d successful + %d failed != %d total", s.File, s.SuccessfulRequests, s.FailedRequests, s.TotalRequests)
+	}
+	if s.ActualRPS <= 0 || s.DurationSeconds <= 0 {
+		t.Errorf("%s: actualRPS %v, duration %vs", s.File, s.ActualRPS, s.DurationSeconds)
+	}
+	if math.Abs(s.SuccessRate+s.ErrorRate-100) > 0.01 { // successRate is rounded to 2 places
+		t.Errorf("%s: success rate %v and error rate %v do not add up", s.File, s.SuccessRate, s.ErrorRate)
+	}
+	for _, key := range []string{"p50", "p90", "p95", "p99"} {
+		if s.LatencyMs[key] <= 0 {
+			t.Errorf("%s: latency %s = %v", s.File, key, s.LatencyMs[key])
+		}
+	}
+	if s.LatencyMs["p50"] > s.LatencyMs["p99"] {
+		t.Errorf("%s: p50 %vms above p99 %vms", s.File, s.LatencyMs["p50"], s.LatencyMs["p99"])
+	}
+}
+
+// The committed benchmark results are the older runner format: rates as
+// "%" strings, RPS as numeric strings, counts as JSON numbers and latency as
+// duration strings in seconds
+func TestLoadSummaryCommittedResults(t *testing.T) {
+	files, err := filepath.Glob("../benchmark_results_*/*_results.json")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no committed results found: %v", err)
+	}
+	for _, file := range files {
+		platform := strings.TrimSuffix(filepath.Base(file), "_results.json")
+		s, err := loadSummary(platform, file)
+		if err != nil {
+			t.Fatalf("%s: %v", file, err)
+		}
+		checkSummary(t, s)
+	}
+
+	s, err := loadSummary("medusa", "../benchmark_results_7min_20250312_184147/medusa_results.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		name      string
+		got, want float64
+	}{
+		{"totalRequests", float64(s.TotalRequests), 16592},
+		{"failedRequests", float64(s.FailedRequests), 1281},
+		{"actualRPS", s.ActualRPS, 39.5},
+		{"targetRPS", s.TargetRPS, 40},
+		{"successRate", s.SuccessRate, 92.28},
+		{"durationSeconds", s.DurationSeconds, 420.006294481},
+		{"p50", s.LatencyMs["p50"], 10508.781225},
+		{"p99", s.LatencyMs["p99"], 12173.101375},
+	} {
+		if !approx(c.got, c.want) {
+			t.Errorf("medusa 7min %s = %v, want %v", c.name, c.got, c.want)
+		}
+	}
+}
+
+// testdata holds results written by the current runners against wsm
+// mocktarget: sub-millisecond latency in µs, per-operation sections and
+// min/max/mean
+func TestLoadSummaryRunnerResults(t *testing.T) {
+	for _, platform := range []string{"spree", "medusa", "saleor"} {
+		file := filepath.Join("testdata", platform+"_results.json")
+		s, err := loadSummary(platform, file)
+		if err != nil {
+			t.Fatalf("%s: %v", file, err)
+		}
+		checkSummary(t, s)
+		if s.LatencyMs["p95"] >= 1000 {
+			t.Errorf("%s: p95 %vms, the mock answers in well under a second", file, s.LatencyMs["p95"])
+		}
+		if len(s.Operations) == 0 {
+			t.Errorf("%s: no operations parsed", file)
+		}
+		var requests int64
+		withLatency := 0
+		for name, op := range s.Operations {
+			requests += op.Requests
+			// operations without sampled durations report no latency
+			if op.LatencyMs == nil {
+				continue
+			}
+			withLatency++
+			if op.LatencyMs["p95"] <= 0 {
+				t.Errorf("%s: operation %s has no p95", file, name)
+			}
+		}
+		if withLatency == 0 {
+			t.Errorf("%s: no operation latency parsed", file)
+		}
+		if requests != s.TotalRequests {
+			t.Errorf("%s: operations add up to %d requests, want %d", file, requests, s.TotalRequests)
+		}
+	}
+
+	s, err := loadSummary("spree", "testdata/spree_results.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !approx(s.LatencyMs["p50"], 0.758418) || !approx(s.ActualRPS, 23.82) || !approx(s.SuccessRate, 100) {
+		t.Errorf("spree: p50 %vms, actualRPS %v, successRate %v", s.LatencyMs["p50"], s.ActualRPS, s.SuccessRate)
+	}
+	if _, ok := s.Operations["product_detail"]; !ok {
+		t.Errorf("spree: specificProduct not mapped to product_detail, got %v", s.Operations)
+	}
+}
+
+func TestLoadSummaryMalformedFields(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "results.json")
+	data := `{"totalRequests": 10, "successfulRequests": 9, "failedRequests": 1,
+		"actualRPS": "n/a", "testDuration": "a while",
+		"latency": {"p50": "12ms", "p95": "slow"}}`
+	if err := os.WriteFile(file, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s, err := loadSummary("spree", file)
+	if err != nil {
+		t.Fatalf("malformed fields must not fail the comparison: %v", err)
+	}
+	if len(s.Warnings) != 3 {
+		t.Errorf("warnings %v, want one each for actualRPS, testDuration and latency.p95", s.Warnings)
+	}
+	if s.LatencyMs["p50"] != 12 || s.ErrorRate != 10 || s.SuccessRate != 90 {
+		t.Errorf("p50 %v, error rate %v, success rate %v", s.LatencyMs["p50"], s.ErrorRate, s.SuccessRate)
+	}
+
+	if _, err := loadSummary("spree", filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("a missing file must fail")
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// defaultWeights is the scoring model used when -weights is not given
+var defaultWeights = map[string]float64{
+	"throughput":  0.35,
+	"latencyP95":  0.25,
+	"errorRate":   0.30,
+	"consistency": 0.10,
+	"cost":        0,
+}
+
+// scoreComponents lists the scoring components in display order
+var scoreComponents = []string{"throughput", "latencyP95", "errorRate", "consistency", "cost"}
+
+// PlatformScore is a platform's overall score with the weighted contribution of each component
+type PlatformScore struct {
+	Rank      int                `json:"rank"`
+	Platform  string             `json:"platform"`
+	Score     float64            `json:"score"`
+	Breakdown map[string]float64 `json:"breakdown"`
+}
+
+// parseWeights parses "throughput=0.4,errorRate=0.4" style weights on top of the defaults.
+// Weights are normalized so they sum to one.
+func parseWeights(spec string) (map[string]float64, error) {
+	weights := make(map[string]float64, len(defaultWeights))
+	for k, v := range defaultWeights {
+		weights[k] = v
+	}
+
+	if strings.TrimSpace(spec) != "" {
+		for _, part := range strings.Split(spec, ",") {
+			kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+			if len(kv) != 2 {
+				return nil, fmt.Errorf("invalid weight %q, expected name=value", part)
+			}
+			name := strings.TrimSpace(kv[0])
+			if _, ok := defaultWeights[name]; !ok {
+				return nil, fmt.Errorf("unknown weight %q (valid: %s)", name, strings.Join(scoreComponents, ", "))
+			}
+			value, err := strconv.ParseFloat(strings.TrimSpace(kv[1]), 64)
+			if err != nil || value < 0 {
+				return nil, fmt.Errorf("invalid value for weight %s: %q", name, kv[1])
+			}
+			weights[name] = value
+		}
+	}
+
+	var total float64
+	for _, v := range weights {
+		total += v
+	}
+	if total == 0 {
+		return nil, fmt.Errorf("at least one weight must be positive")
+	}
+	for k := range weights {
+		weights[k] /= total
+	}
+	return weights, nil
+}
+
+// consistency returns the p99/p50 latency ratio; lower means a tighter distribution
+func consistency(p *PlatformSummary) (float64, bool) {
+	p50, ok50 := p.LatencyMs["p50"]
+	p99, ok99 := p.LatencyMs["p99"]
+	if !ok50 || !ok99 || p50 <= 0 {
+		return 0, false
+	}
+	return p99 / p50, true
+}
+
+// componentValue extracts the raw value of a scoring component and whether higher is better
+func componentValue(p *PlatformSummary, component string) (value float64, ok bool, higherIsBetter bool) {
+	switch component {
+	case "throughput":
+		return p.ActualRPS, p.TotalRequests > 0, true
+	case "latencyP95":
+		v, ok := p.LatencyMs["p95"]
+		return v, ok, false
+	case "errorRate":
+		// Scored as success rate so a 0% error rate doesn't divide by zero
+		return 100 - p.ErrorRate, p.TotalRequests > 0, true
+	case "consistency":
+		v, ok := consistency(p)
+		return v, ok, false
+	case "cost":
+		v, ok := p.Cost.costPer1k()
+		return v, ok, false
+	}
+	return 0, false, false
+}
+
+// scorePlatforms ranks platforms by a weighted sum of components, each normalized
+// to 0-100 relative to the best platform for that component. A platform missing
+// a component scores zero for it.
+func scorePlatforms(platforms []*PlatformSummary, weights map[string]float64) []PlatformScore {
+	scores := make([]PlatformScore, len(platforms))
+	for i, p := range platforms {
+		scores[i] = PlatformScore{Platform: p.Platform, Breakdown: make(map[string]float64)}
+	}
+
+	for _, component := range scoreComponents {
+		weight := weights[component]
+		if weight == 0 {
+			continue
+		}
+
+		// Find the best value for this component
+		best, found := 0.0, false
+		var higherIsBetter bool
+		for _, p := range platforms {
+			v, ok, higher := componentValue(p, component)
+			if !ok {
+				continue
+			}
+			higherIsBetter = higher
+			if !found || (higher && v > best) || (!higher && v < best) {
+				best, found = v, true
+			}
+		}
+		if !found {
+			continue
+		}
+
+		for i, p := range platforms {
+			v, ok, _ := componentValue(p, component)
+			if !ok {
+				scores[i].Breakdown[component] = 0
+				continue
+			}
+
+			normalized := 100.0
+			if higherIsBetter && best > 0 {
+				normalized = v / best * 100
+			} else if !higherIsBetter && v > 0 {
+				normalized = best / v * 100
+			}
+
+			contribution := normalized * weight
+			scores[i].Breakdown[component] = contribution
+			scores[i].Score += contribution
+		}
+	}
+
+	sort.SliceStable(scores, func(i, j int) bool {
+		return scores[i].Score > scores[j].Score
+	})
+	for i := range scores {
+		scores[i].Rank = i + 1
+	}
+	return scores
+}
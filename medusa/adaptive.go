@@ -0,0 +1,374 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// AIMDConfig tunes the additive-increase/multiplicative-decrease strategy
+type AIMDConfig struct {
+	Increase float64 // RPS added while the error rate is below the threshold, default 10% of InitialRPS
+	Decrease float64 // factor the RPS is multiplied by above it, default 0.5
+}
+
+// PIDConfig tunes the PID strategy. The controlled error is the distance to
+// a setpoint relative to the setpoint, clamped to [-1, 1], so the gains
+// don't depend on units: an error rate of twice the setpoint is -1. The
+// output is the relative RPS change, at most 25% per adjustment.
+type PIDConfig struct {
+	ErrorRate  float64       // error rate setpoint in percent, default half the threshold
+	P95        time.Duration // p95 latency setpoint; when set the setpoint further off steers
+	Kp, Ki, Kd float64       // gains, default 0.1, 0.02 and 0.05
+}
+
+// ConvergenceConfig ends an adaptive run early once its RPS has settled,
+// instead of always running for the whole Duration
+type ConvergenceConfig struct {
+	Windows   int     // consecutive adjustment windows the RPS must stay in the band (0 = off)
+	Tolerance float64 // half-width of the band around the windows' mean RPS in percent, default 5
+}
+
+// adaptiveWindow is what the controller observed over one sampling window
+type adaptiveWindow struct {
+	errorRate float64 // percent
+	p95       time.Duration
+}
+
+// adaptiveStrategy picks the next target RPS of an adaptive run and says why
+type adaptiveStrategy interface {
+	next(current float64, window adaptiveWindow) (float64, string)
+}
+
+// stepStrategy is the original controller: a fixed percentage up below the
+// error threshold and down above it
+type stepStrategy struct {
+	threshold float64
+	increase  float64
+	decrease  float64
+}
+
+func (s *stepStrategy) next(current float64, window adaptiveWindow) (float64, string) {
+	if window.errorRate > s.threshold {
+		return current - current*s.decrease/100, "exceeds threshold"
+	}
+	return current + current*s.increase/100, "below threshold"
+}
+
+// aimdStrategy adds a constant below the error threshold and cuts the RPS
+// by a factor above it, which backs off hard near the capacity limit and
+// approaches it again slowly
+type aimdStrategy struct {
+	threshold float64
+	config    AIMDConfig
+}
+
+func (s *aimdStrategy) next(current float64, window adaptiveWindow) (float64, string) {
+	if window.errorRate > s.threshold {
+		return current * s.config.Decrease, "exceeds threshold"
+	}
+	return current + s.config.Increase, "below threshold"
+}
+
+// pidStrategy steers the error rate, and optionally the p95 latency, to a
+// setpoint instead of bouncing around a threshold
+type pidStrategy struct {
+	config   PIDConfig
+	integral float64
+	previous float64
+	started  bool
+}
+
+const (
+	pidMaxStep     = 0.25 // largest relative RPS change of one adjustment
+	pidMaxIntegral = 3    // bound of the integral, so a long climb doesn't delay the back-off
+)
+
+// relativeError returns (setpoint - measured) / setpoint clamped to [-1, 1]
+func relativeError(setpoint, measured float64) float64 {
+	return math.Max(-1, math.Min(1, (setpoint-measured)/setpoint))
+}
+
+func (s *pidStrategy) next(current float64, window adaptiveWindow) (float64, string) {
+	e := relativeError(s.config.ErrorRate, window.errorRate)
+	if s.config.P95 > 0 && window.p95 > 0 {
+		e = math.Min(e, relativeError(float64(s.config.P95), float64(window.p95)))
+	}
+	derivative := 0.0
+	if s.started {
+		derivative = e - s.previous
+	}
+	s.previous, s.started = e, true
+
+	output := s.config.Kp*e + s.config.Ki*(s.integral+e) + s.config.Kd*derivative
+	if math.Abs(output) <= pidMaxStep {
+		// Integrating while the output is clamped would wind the integral up
+		s.integral = math.Max(-pidMaxIntegral, math.Min(pidMaxIntegral, s.integral+e))
+	}
+	output = math.Max(-pidMaxStep, math.Min(pidMaxStep, output))
+
+	return current + current*output, fmt.Sprintf("PID error %+.2f, output %+.1f%%", e, output*100)
+}
+
+// adaptiveDecision is one adjustment of the adaptive RPS
+type adaptiveDecision struct {
+	time      time.Time
+	oldRPS    float64
+	newRPS    float64
+	errorRate float64
+	p95       time.Duration
+	reason    string
+}
+
+// adaptiveController applies the configured strategy within the RPS bounds
+// and logs every adjustment for the results
+type adaptiveController struct {
+	name      string
+	minimum   float64
+	maximum   float64
+	threshold float64       // error rate in percent a sustained level must stay within
+	hold      time.Duration // how long a level must be held to count as sustained
+	strategy  adaptiveStrategy
+
+	convergence ConvergenceConfig
+
+	mutex     sync.Mutex
+	start     time.Time
+	decisions []adaptiveDecision
+	converged *adaptiveDecision // the adjustment that completed the stable windows
+	settledAt float64           // mean RPS of the stable windows
+}
+
+// newAdaptiveController returns nil unless Test.AdaptiveRPS is set or a
+// phase is adaptive
+func newAdaptiveController(config *Config) (*adaptiveController, error) {
+	if !config.Test.AdaptiveRPS && !adaptivePhase(config) {
+		return nil, nil
+	}
+	ac := config.Test.AdaptiveConfig
+	c := &adaptiveController{
+		name:      ac.Strategy,
+		minimum:   ac.MinimumRPS,
+		maximum:   ac.MaximumRPS,
+		threshold: ac.ErrorThresholdPercentage,
+		hold:      time.Duration(max(int64(ac.StabilizationWindow), int64(ac.SamplingWindow))),
+	}
+	switch ac.Strategy {
+	case "", "step":
+		c.name = "step"
+		c.strategy = &stepStrategy{threshold: ac.ErrorThresholdPercentage, increase: ac.RPSIncreasePercentage, decrease: ac.RPSDecreasePercentage}
+	case "aimd":
+		aimd := ac.AIMD
+		if aimd.Increase <= 0 {
+			aimd.Increase = ac.InitialRPS / 10
+		}
+		if aimd.Decrease <= 0 {
+			aimd.Decrease = 0.5
+		}
+		if aimd.Decrease >= 1 {
+			return nil, fmt.Errorf("AIMD.Decrease must be below 1, got %g", aimd.Decrease)
+		}
+		c.strategy = &aimdStrategy{threshold: ac.ErrorThresholdPercentage, config: aimd}
+	case "pid":
+		pid := ac.PID
+		if pid.ErrorRate <= 0 {
+			pid.ErrorRate = ac.ErrorThresholdPercentage / 2
+		}
+		if pid.ErrorRate <= 0 {
+			return nil, fmt.Errorf("the PID strategy needs PID.ErrorRate or ErrorThresholdPercentage")
+		}
+		if pid.Kp == 0 && pid.Ki == 0 && pid.Kd == 0 {
+			pid.Kp, pid.Ki, pid.Kd = 0.1, 0.02, 0.05
+		}
+		c.strategy = &pidStrategy{config: pid}
+	default:
+		return nil, fmt.Errorf("unknown adaptive strategy %q (available: step, aimd, pid)", ac.Strategy)
+	}
+
+	if ac.Convergence.Windows < 0 || ac.Convergence.Tolerance < 0 {
+		return nil, fmt.Errorf("Convergence.Windows and Convergence.Tolerance must not be negative")
+	}
+	// Adaptive phases end with their phase
+	if config.Test.AdaptiveRPS {
+		c.convergence = ac.Convergence
+	}
+	if c.convergence.Windows > 0 && c.convergence.Tolerance == 0 {
+		c.convergence.Tolerance = 5
+	}
+	return c, nil
+}
+
+// Start sets the time the decisions' offsets are counted from
+func (c *adaptiveController) Start(start time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.start = start
+}
+
+// next returns the target RPS after one adjustment
+func (c *adaptiveController) next(now time.Time, current float64, errorRate float64, p95 time.Duration) float64 {
+	rps, reason := c.strategy.next(current, adaptiveWindow{errorRate: errorRate, p95: p95})
+	if rps < c.minimum {
+		rps = c.minimum
+		reason += ", clamped to MinimumRPS"
+	}
+	if rps > c.maximum {
+		rps = c.maximum
+		reason += ", clamped to MaximumRPS"
+	}
+	c.mutex.Lock()
+	c.decisions = append(c.decisions, adaptiveDecision{
+		time:      now,
+		oldRPS:    current,
+		newRPS:    rps,
+		errorRate: errorRate,
+		p95:       p95,
+		reason:    reason,
+	})
+	converged := c.checkConvergence()
+	c.mutex.Unlock()
+
+	observed := fmt.Sprintf("Error rate %.2f%% (p95 %s) %s.", errorRate, p95.Round(time.Millisecond), reason)
+	switch {
+	case rps > current:
+		fmt.Printf("%s Increasing RPS from %s to %s\n", observed, formatRPS(current), formatRPS(rps))
+	case rps < current:
+		fmt.Printf("%s Decreasing RPS from %s to %s\n", observed, formatRPS(current), formatRPS(rps))
+	default:
+		fmt.Printf("%s Keeping RPS at %s\n", observed, formatRPS(rps))
+	}
+	if converged {
+		fmt.Printf("Adaptive RPS converged at %s RPS, within %g%% over the last %d windows; ending the run early\n",
+			formatRPS(c.settledAt), c.convergence.Tolerance, c.convergence.Windows)
+	}
+	return rps
+}
+
+// checkConvergence reports whether the RPS held in the last Windows
+// adjustment windows stayed within Tolerance percent of their mean; callers
+// hold the mutex
+func (c *adaptiveController) checkConvergence() bool {
+	n := c.convergence.Windows
+	if n <= 0 || c.converged != nil || len(c.decisions) < n {
+		return false
+	}
+	recent := c.decisions[len(c.decisions)-n:]
+	var sum float64
+	low, high := math.Inf(1), math.Inf(-1)
+	for _, d := range recent {
+		// The rate of a window is the one its closing adjustment started from
+		sum += d.oldRPS
+		low, high = math.Min(low, d.oldRPS), math.Max(high, d.oldRPS)
+	}
+	mean := sum / float64(n)
+	band := mean * c.convergence.Tolerance / 100
+	if mean <= 0 || high-mean > band || mean-low > band {
+		return false
+	}
+	c.converged, c.settledAt = &recent[n-1], mean
+	return true
+}
+
+// Converged reports whether the run should end because the RPS converged.
+// A nil controller never converges.
+func (c *adaptiveController) Converged() bool {
+	if c == nil {
+		return false
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.converged != nil
+}
+
+// sustainedLevel is the highest RPS level held for at least the hold time
+// with the error rate within the threshold
+type sustainedLevel struct {
+	rps       float64
+	from, to  time.Time
+	seconds   int // whole seconds the counts cover
+	requests  int64
+	failed    int64
+	errorRate float64
+}
+
+// sustained finds the sustained level among the periods between decisions.
+// The error rate and achieved rate are those of every request completed in
+// the period's seconds, not only the last sampling window the next decision
+// saw.
+func (c *adaptiveController) sustained(series *secondSeries) (sustainedLevel, bool) {
+	var best sustainedLevel
+	found := false
+	from := c.start
+	for _, d := range c.decisions {
+		level := sustainedLevel{rps: d.oldRPS, from: from, to: d.time}
+		from = d.time
+		if level.to.Sub(level.from) < c.hold {
+			continue
+		}
+		w := series.window(level.from, level.to)
+		level.seconds, level.requests, level.failed = w.seconds, w.requests, w.failed
+		if level.requests == 0 || level.seconds == 0 {
+			continue
+		}
+		level.errorRate = float64(level.failed) / float64(level.requests) * 100
+		if level.errorRate > c.threshold {
+			continue
+		}
+		if !found || level.rps > best.rps || level.rps == best.rps && level.to.Sub(level.from) > best.to.Sub(best.from) {
+			best, found = level, true
+		}
+	}
+	return best, found
+}
+
+// report lists every adjustment of the run and the sustained RPS
+func (c *adaptiveController) report(series *secondSeries) map[string]interface{} {
+	if c == nil {
+		return nil
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	report := map[string]interface{}{"strategy": c.name}
+	if level, ok := c.sustained(series); ok {
+		report["sustained"] = map[string]interface{}{
+			"rps":         roundRPS(level.rps),
+			"achievedRPS": fmt.Sprintf("%.2f", float64(level.requests)/float64(level.seconds)),
+			"errorRate":   fmt.Sprintf("%.2f%%", level.errorRate),
+			"heldFor":     level.to.Sub(level.from).Round(time.Millisecond).String(),
+			"offsetSec":   math.Round(level.from.Sub(c.start).Seconds()*10) / 10,
+		}
+		report["headline"] = fmt.Sprintf("sustained %s RPS at <%.2f%% errors", formatRPS(level.rps), c.threshold)
+	} else {
+		report["headline"] = fmt.Sprintf("no RPS level was held for %s at <%.2f%% errors", c.hold, c.threshold)
+	}
+
+	if c.convergence.Windows > 0 {
+		convergence := map[string]interface{}{
+			"windows":   c.convergence.Windows,
+			"tolerance": fmt.Sprintf("%g%%", c.convergence.Tolerance),
+			"converged": c.converged != nil,
+		}
+		if c.converged != nil {
+			convergence["rps"] = roundRPS(c.settledAt)
+			convergence["offsetSec"] = math.Round(c.converged.time.Sub(c.start).Seconds()*10) / 10
+		}
+		report["convergence"] = convergence
+	}
+
+	decisions := make([]map[string]interface{}, 0, len(c.decisions))
+	for _, d := range c.decisions {
+		decisions = append(decisions, map[string]interface{}{
+			"time":      d.time.Format(time.RFC3339Nano),
+			"offsetSec": math.Round(d.time.Sub(c.start).Seconds()*10) / 10,
+			"oldRPS":    roundRPS(d.oldRPS),
+			"newRPS":    roundRPS(d.newRPS),
+			"errorRate": fmt.Sprintf("%.2f%%", d.errorRate),
+			"p95":       d.p95.String(),
+			"reason":    d.reason,
+		})
+	}
+	report["decisions"] = decisions
+	return report
+}
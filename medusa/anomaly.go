@@ -0,0 +1,332 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+// AnomalyConfig tunes the detector run over the per-second series after the
+// test, which lists latency jumps and error bursts with their timestamps
+type AnomalyConfig struct {
+	Baseline    time.Duration // trailing window a second is compared with, default 30s
+	Threshold   float64       // robust z-score a second must exceed, default 4
+	MinRequests int64         // seconds with fewer completed requests are skipped, default 5
+}
+
+const (
+	secondSamples = 500 // latencies kept per second
+	anomalyGap    = 3   // anomalous seconds at most this far apart are one event
+)
+
+// secondBucket is the requests completed in one second of the test
+type secondBucket struct {
+	requests  int64
+	failed    int64
+	durations []time.Duration
+}
+
+// secondSeries records the per-second series the anomaly detector runs on
+type secondSeries struct {
+	config AnomalyConfig
+
+	mutex   sync.Mutex
+	start   time.Time
+	buckets []secondBucket
+}
+
+func newSecondSeries(config AnomalyConfig) *secondSeries {
+	if config.Baseline <= 0 {
+		config.Baseline = 30 * time.Second
+	}
+	if config.Threshold <= 0 {
+		config.Threshold = 4
+	}
+	if config.MinRequests <= 0 {
+		config.MinRequests = 5
+	}
+	return &secondSeries{config: config}
+}
+
+// Start sets the time the seconds are counted from; results before it are ignored
+func (s *secondSeries) Start(start time.Time) {
+	if s == nil {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.start = start
+}
+
+// observe adds a completed request to the current second
+func (s *secondSeries) observe(duration time.Duration, success bool) {
+	if s == nil {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.start.IsZero() {
+		return
+	}
+	second := int(time.Since(s.start) / time.Second)
+	for len(s.buckets) <= second {
+		s.buckets = append(s.buckets, secondBucket{})
+	}
+	b := &s.buckets[second]
+	b.requests++
+	if !success {
+		b.failed++
+	}
+	if len(b.durations) < secondSamples {
+		b.durations = append(b.durations, duration)
+	} else if i := rand.Int63n(b.requests); i < secondSamples {
+		b.durations[i] = duration
+	}
+}
+
+// recentP95 returns the p95 latency of the seconds in the last window, 0
+// when there is no data yet
+func (s *secondSeries) recentP95(window time.Duration) time.Duration {
+	if s == nil {
+		return 0
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.start.IsZero() {
+		return 0
+	}
+	last := int(time.Since(s.start) / time.Second)
+	first := last - int(window/time.Second)
+	if first < 0 {
+		first = 0
+	}
+	var sorted []time.Duration
+	for i := first; i <= last && i < len(s.buckets); i++ {
+		sorted = append(sorted, s.buckets[i].durations...)
+	}
+	sort.Slice(sorted, func(a, c int) bool { return sorted[a] < sorted[c] })
+	return percentileDuration(sorted, 0.95)
+}
+
+// seriesWindow is what completed in a range of whole seconds
+type seriesWindow struct {
+	seconds   int
+	requests  int64
+	failed    int64
+	durations []time.Duration // sampled, sorted
+}
+
+// window summarizes the seconds between from and to, both rounded to the
+// nearest second, so back-to-back windows share no second
+func (s *secondSeries) window(from, to time.Time) seriesWindow {
+	var w seriesWindow
+	if s == nil {
+		return w
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.start.IsZero() {
+		return w
+	}
+	first := int(from.Sub(s.start).Round(time.Second) / time.Second)
+	if first < 0 {
+		first = 0
+	}
+	last := int(to.Sub(s.start).Round(time.Second) / time.Second)
+	if last > first {
+		w.seconds = last - first
+	}
+	for i := first; i < last && i < len(s.buckets); i++ {
+		w.requests += s.buckets[i].requests
+		w.failed += s.buckets[i].failed
+		w.durations = append(w.durations, s.buckets[i].durations...)
+	}
+	sort.Slice(w.durations, func(a, c int) bool { return w.durations[a] < w.durations[c] })
+	return w
+}
+
+// secondSummary is one completed second for live views
+type secondSummary struct {
+	Second   int     `json:"second"`
+	Requests int64   `json:"requests"`
+	Failed   int64   `json:"failed"`
+	P50      float64 `json:"p50"` // milliseconds
+	P95      float64 `json:"p95"`
+	P99      float64 `json:"p99"`
+}
+
+// completed summarizes the seconds from first on that are over
+func (s *secondSeries) completed(first int) []secondSummary {
+	if s == nil {
+		return nil
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.start.IsZero() {
+		return nil
+	}
+	if first < 0 {
+		first = 0
+	}
+	current := int(time.Since(s.start) / time.Second)
+	var summaries []secondSummary
+	for i := first; i < current; i++ {
+		var b secondBucket
+		if i < len(s.buckets) {
+			b = s.buckets[i]
+		}
+		sorted := append([]time.Duration(nil), b.durations...)
+		sort.Slice(sorted, func(a, c int) bool { return sorted[a] < sorted[c] })
+		ms := func(p float64) float64 {
+			return math.Round(float64(percentileDuration(sorted, p))/float64(time.Millisecond)*100) / 100
+		}
+		summaries = append(summaries, secondSummary{
+			Second:   i,
+			Requests: b.requests,
+			Failed:   b.failed,
+			P50:      ms(0.5),
+			P95:      ms(0.95),
+			P99:      ms(0.99),
+		})
+	}
+	return summaries
+}
+
+// secondPoint is the summary of one second the detector compares
+type secondPoint struct {
+	second    int
+	p95       float64 // milliseconds
+	errorRate float64 // 0..1
+}
+
+// anomalyEvent is a run of anomalous seconds of one kind
+type anomalyEvent struct {
+	kind     string
+	first    int
+	last     int
+	peak     float64
+	baseline float64
+}
+
+// medianAndMAD returns the median and the median absolute deviation
+func medianAndMAD(values []float64) (float64, float64) {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	median := sorted[len(sorted)/2]
+	deviations := make([]float64, len(sorted))
+	for i, v := range sorted {
+		deviations[i] = math.Abs(v - median)
+	}
+	sort.Float64s(deviations)
+	return median, deviations[len(deviations)/2]
+}
+
+// points summarizes the seconds with enough requests to judge
+func (s *secondSeries) points() []secondPoint {
+	var points []secondPoint
+	for i, b := range s.buckets {
+		if b.requests < s.config.MinRequests {
+			continue
+		}
+		sorted := append([]time.Duration(nil), b.durations...)
+		sort.Slice(sorted, func(a, c int) bool { return sorted[a] < sorted[c] })
+		points = append(points, secondPoint{
+			second:    i,
+			p95:       float64(percentileDuration(sorted, 0.95)) / float64(time.Millisecond),
+			errorRate: float64(b.failed) / float64(b.requests),
+		})
+	}
+	return points
+}
+
+// detect compares every second with the median of the trailing baseline
+// window. A second is anomalous when it is more than Threshold robust
+// standard deviations (1.4826 * MAD) above that median; the deviation has a
+// floor (10% of the median latency, 1 point of error rate) so a perfectly
+// flat baseline doesn't turn noise into anomalies. A sustained shift stops
+// being reported once the trailing window has caught up with it.
+func (s *secondSeries) detect() []anomalyEvent {
+	points := s.points()
+	window := int(s.config.Baseline / time.Second)
+	minBaseline := window / 3
+	if minBaseline < 5 {
+		minBaseline = 5
+	}
+
+	var events []anomalyEvent
+	open := map[string]int{} // index of the latest event of each kind
+	flag := func(kind string, second int, value, baseline float64) {
+		// Seconds skipped for too few requests don't split an event
+		if k, ok := open[kind]; ok && events[k].last >= second-anomalyGap {
+			events[k].last = second
+			events[k].peak = math.Max(events[k].peak, value)
+			return
+		}
+		events = append(events, anomalyEvent{kind: kind, first: second, last: second, peak: value, baseline: baseline})
+		open[kind] = len(events) - 1
+	}
+
+	for i, point := range points {
+		var latencies, errorRates []float64
+		for j := i - 1; j >= 0 && points[j].second >= point.second-window; j-- {
+			latencies = append(latencies, points[j].p95)
+			errorRates = append(errorRates, points[j].errorRate)
+		}
+		if len(latencies) < minBaseline {
+			continue
+		}
+
+		median, mad := medianAndMAD(latencies)
+		sigma := math.Max(1.4826*mad, 0.1*median)
+		if point.p95 > median+s.config.Threshold*sigma {
+			flag("latencyJump", point.second, point.p95, median)
+		}
+
+		median, mad = medianAndMAD(errorRates)
+		sigma = math.Max(1.4826*mad, 0.01)
+		if point.errorRate > median+s.config.Threshold*sigma {
+			flag("errorBurst", point.second, point.errorRate, median)
+		}
+	}
+	return events
+}
+
+// report lists the detected anomalies with their timestamps
+func (s *secondSeries) report() map[string]interface{} {
+	if s == nil {
+		return nil
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.start.IsZero() {
+		return nil
+	}
+
+	events := s.detect()
+	list := make([]map[string]interface{}, 0, len(events))
+	for _, e := range events {
+		entry := map[string]interface{}{
+			"kind":      e.kind,
+			"start":     s.start.Add(time.Duration(e.first) * time.Second).Format(time.RFC3339),
+			"offsetSec": e.first,
+			"duration":  (time.Duration(e.last-e.first+1) * time.Second).String(),
+		}
+		if e.kind == "latencyJump" {
+			entry["peakP95"] = fmt.Sprintf("%.1fms", e.peak)
+			entry["baselineP95"] = fmt.Sprintf("%.1fms", e.baseline)
+		} else {
+			entry["peakErrorRate"] = fmt.Sprintf("%.2f%%", e.peak*100)
+			entry["baselineErrorRate"] = fmt.Sprintf("%.2f%%", e.baseline*100)
+		}
+		list = append(list, entry)
+	}
+	return map[string]interface{}{
+		"baseline":  s.config.Baseline.String(),
+		"threshold": s.config.Threshold,
+		"seconds":   len(s.buckets),
+		"events":    list,
+	}
+}
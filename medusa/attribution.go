@@ -0,0 +1,263 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Phases a request's latency is attributed to, in request order. send is the
+// time until the request was written (connection pool wait and writing the
+// request, without DNS, connect and TLS). server is the Server-Timing total
+// when the response has one, otherwise the whole wait for the first byte;
+// network is the rest of that wait, the round trip and queueing in front of
+// the application.
+var attributionPhases = []string{"dns", "connect", "tls", "send", "network", "server", "transfer"}
+
+const (
+	attributionSamples = 20000
+	totalPhase         = 7 // index of the total in an attributionRow
+)
+
+// attributionRow is the phase durations of one request followed by its total
+type attributionRow [8]time.Duration
+
+// attributionStats are the attributed requests of one operation
+type attributionStats struct {
+	requests         int64
+	withServerTiming int64
+	newConnections   int64
+	sums             attributionRow
+	rows             []attributionRow
+	serverMetrics    map[string]*serverMetric
+}
+
+// serverMetric sums one named Server-Timing metric, e.g. "db"
+type serverMetric struct {
+	count int64
+	sum   float64
+}
+
+// latencyAttribution splits sampled requests' latency into phases
+type latencyAttribution struct {
+	mutex      sync.Mutex
+	overall    *attributionStats
+	operations map[string]*attributionStats
+}
+
+func newLatencyAttribution() *latencyAttribution {
+	return &latencyAttribution{
+		overall:    newAttributionStats(),
+		operations: make(map[string]*attributionStats),
+	}
+}
+
+func newAttributionStats() *attributionStats {
+	return &attributionStats{serverMetrics: make(map[string]*serverMetric)}
+}
+
+// parseServerTiming reads the dur of each Server-Timing metric. The total is
+// the metric named "total" if there is one, otherwise the sum of all metrics.
+func parseServerTiming(header http.Header) (map[string]float64, time.Duration, bool) {
+	metrics := make(map[string]float64)
+	for _, value := range header.Values("Server-Timing") {
+		for _, entry := range strings.Split(value, ",") {
+			params := strings.Split(entry, ";")
+			name := strings.TrimSpace(params[0])
+			if name == "" {
+				continue
+			}
+			for _, param := range params[1:] {
+				key, arg, _ := strings.Cut(strings.TrimSpace(param), "=")
+				if strings.EqualFold(key, "dur") {
+					if ms, err := strconv.ParseFloat(strings.Trim(arg, `"`), 64); err == nil && ms >= 0 {
+						metrics[name] += ms
+					}
+				}
+			}
+		}
+	}
+	if len(metrics) == 0 {
+		return nil, 0, false
+	}
+	total, ok := metrics["total"]
+	if !ok {
+		for _, ms := range metrics {
+			total += ms
+		}
+	}
+	return metrics, time.Duration(total * float64(time.Millisecond)), true
+}
+
+// since returns b - a, or 0 if either is unset or b is earlier
+func since(a, b time.Time) time.Duration {
+	if a.IsZero() || b.IsZero() || b.Before(a) {
+		return 0
+	}
+	return b.Sub(a)
+}
+
+// add attributes one request that got a response
+func (a *latencyAttribution) add(operation string, timing *traceTiming, end time.Time) {
+	var row attributionRow
+	row[0] = since(timing.dnsStart, timing.dnsDone)
+	row[1] = since(timing.connectStart, timing.connectDone)
+	row[2] = since(timing.tlsStart, timing.tlsDone)
+	if send := since(timing.start, timing.wroteRequest) - row[0] - row[1] - row[2]; send > 0 {
+		row[3] = send
+	}
+	wait := since(timing.wroteRequest, timing.firstByte)
+	metrics, server, hasServerTiming := parseServerTiming(timing.header)
+	if hasServerTiming {
+		if server > wait {
+			// Clock granularity on the server; it can't exceed what we waited
+			server = wait
+		}
+		row[4], row[5] = wait-server, server
+	} else {
+		row[5] = wait
+	}
+	row[6] = since(timing.firstByte, timing.bodyDone)
+	if !timing.bodyDone.IsZero() {
+		end = timing.bodyDone
+	}
+	row[totalPhase] = since(timing.start, end)
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	stats, ok := a.operations[operation]
+	if !ok {
+		stats = newAttributionStats()
+		a.operations[operation] = stats
+	}
+	for _, s := range []*attributionStats{a.overall, stats} {
+		s.requests++
+		if hasServerTiming {
+			s.withServerTiming++
+		}
+		if !timing.reused {
+			s.newConnections++
+		}
+		for i := range row {
+			s.sums[i] += row[i]
+		}
+		if len(s.rows) < attributionSamples {
+			s.rows = append(s.rows, row)
+		} else if i := rand.Int63n(s.requests); i < attributionSamples {
+			s.rows[i] = row
+		}
+		for name, ms := range metrics {
+			m, ok := s.serverMetrics[name]
+			if !ok {
+				m = &serverMetric{}
+				s.serverMetrics[name] = m
+			}
+			m.count++
+			m.sum += ms
+		}
+	}
+}
+
+// shares returns each phase's share of the summed total of the rows
+func shares(sums attributionRow) map[string]string {
+	shares := make(map[string]string, len(attributionPhases))
+	if sums[totalPhase] <= 0 {
+		return shares
+	}
+	for i, phase := range attributionPhases {
+		shares[phase] = fmt.Sprintf("%.1f%%", float64(sums[i])/float64(sums[totalPhase])*100)
+	}
+	return shares
+}
+
+// report returns the phase distribution of one operation
+func (s *attributionStats) report() map[string]interface{} {
+	entry := map[string]interface{}{
+		"requests":         s.requests,
+		"withServerTiming": s.withServerTiming,
+		"newConnections":   s.newConnections,
+	}
+	if s.requests == 0 {
+		return entry
+	}
+
+	sorted := make([]time.Duration, len(s.rows))
+	phases := make(map[string]interface{}, len(attributionPhases))
+	for i, phase := range attributionPhases {
+		for j, row := range s.rows {
+			sorted[j] = row[i]
+		}
+		sort.Slice(sorted, func(a, b int) bool { return sorted[a] < sorted[b] })
+		phases[phase] = map[string]string{
+			"mean": (s.sums[i] / time.Duration(s.requests)).String(),
+			"p50":  percentileDuration(sorted, 0.5).String(),
+			"p95":  percentileDuration(sorted, 0.95).String(),
+		}
+	}
+	for j, row := range s.rows {
+		sorted[j] = row[totalPhase]
+	}
+	sort.Slice(sorted, func(a, b int) bool { return sorted[a] < sorted[b] })
+	p95 := percentileDuration(sorted, 0.95)
+
+	// Where the slowest 5% of requests spend their time
+	var tail attributionRow
+	for _, row := range s.rows {
+		if row[totalPhase] >= p95 {
+			for i := range row {
+				tail[i] += row[i]
+			}
+		}
+	}
+
+	entry["total"] = map[string]string{
+		"mean": (s.sums[totalPhase] / time.Duration(s.requests)).String(),
+		"p50":  percentileDuration(sorted, 0.5).String(),
+		"p95":  p95.String(),
+	}
+	entry["phases"] = phases
+	entry["share"] = shares(s.sums)
+	entry["tailShare"] = shares(tail)
+
+	dominant, largest := "", time.Duration(-1)
+	for i, phase := range attributionPhases {
+		if s.sums[i] > largest {
+			dominant, largest = phase, s.sums[i]
+		}
+	}
+	entry["dominantPhase"] = dominant
+
+	if len(s.serverMetrics) > 0 {
+		metrics := make(map[string]string, len(s.serverMetrics))
+		for name, m := range s.serverMetrics {
+			metrics[name] = fmt.Sprintf("%.1fms", m.sum/float64(m.count))
+		}
+		entry["serverTimingMeans"] = metrics
+	}
+	return entry
+}
+
+// report returns the attribution over all sampled requests and per operation
+func (a *latencyAttribution) report(sampleRate float64) map[string]interface{} {
+	if a == nil {
+		return nil
+	}
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	operations := make(map[string]interface{}, len(a.operations))
+	for operation, stats := range a.operations {
+		operations[operation] = stats.report()
+	}
+	return map[string]interface{}{
+		"sampleRate": sampleRate,
+		"phases":     attributionPhases,
+		"overall":    a.overall.report(),
+		"operations": operations,
+	}
+}
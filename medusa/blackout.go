@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+// defaultBlackoutFile is the blackout calendar read when -blackout is not
+// given; without the file there are no blackouts
+const defaultBlackoutFile = "blackout.json"
+
+// BlackoutCalendar lists the target's recurring maintenance windows.
+// Scheduled runs wait for an open window to close and runners pause their
+// load while one is open, so maintenance isn't reported as platform failures.
+type BlackoutCalendar struct {
+	Windows []BlackoutWindow
+}
+
+// BlackoutWindow opens at every match of Cron (five fields or @daily, ...,
+// in local time) and stays open for Duration, e.g. "30m"
+type BlackoutWindow struct {
+	Name     string
+	Cron     string
+	Duration string
+
+	schedule *CronSchedule
+	length   time.Duration
+}
+
+// blackoutGap is a stretch of time a blackout window was open
+type blackoutGap struct {
+	Window string    `json:"window"`
+	Start  time.Time `json:"start"`
+	End    time.Time `json:"end"`
+}
+
+// String renders the gap as e.g. "nightly maintenance 03:00-03:30"
+func (g blackoutGap) String() string {
+	return fmt.Sprintf("%s %s-%s", g.Window, g.Start.Format("15:04"), g.End.Format("15:04"))
+}
+
+// maxBlackout bounds how far back-to-back windows are merged, so a calendar
+// that is always open still ends somewhere
+const maxBlackout = 7 * 24 * time.Hour
+
+// loadBlackoutCalendar reads the calendar file; it returns nil when file is
+// empty or doesn't exist
+func loadBlackoutCalendar(file string) (*BlackoutCalendar, error) {
+	if file == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var calendar BlackoutCalendar
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields() // a misspelled window would never pause anything
+	if err := decoder.Decode(&calendar); err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+	for i := range calendar.Windows {
+		w := &calendar.Windows[i]
+		if w.Name == "" {
+			w.Name = w.Cron
+		}
+		if w.schedule, err = ParseCron(w.Cron); err != nil {
+			return nil, fmt.Errorf("%s: window %q: %v", file, w.Name, err)
+		}
+		if w.length, err = time.ParseDuration(w.Duration); err != nil || w.length <= 0 {
+			return nil, fmt.Errorf("%s: window %q: Duration %q is not a positive duration", file, w.Name, w.Duration)
+		}
+	}
+	return &calendar, nil
+}
+
+// gaps returns the windows open at any time between from and to, by start
+func (c *BlackoutCalendar) gaps(from, to time.Time) []blackoutGap {
+	if c == nil {
+		return nil
+	}
+	var gaps []blackoutGap
+	for _, w := range c.Windows {
+		// The first window that may still be open at from
+		for start := w.schedule.Next(from.Add(-w.length)); !start.IsZero() && start.Before(to); start = w.schedule.Next(start) {
+			if end := start.Add(w.length); end.After(from) {
+				gaps = append(gaps, blackoutGap{Window: w.Name, Start: start, End: end})
+			}
+		}
+	}
+	sort.Slice(gaps, func(i, j int) bool { return gaps[i].Start.Before(gaps[j].Start) })
+	return gaps
+}
+
+// openAt returns the name of a window open at t and when no window is open
+// anymore, merging windows that overlap or follow each other; name is ""
+// when none is open at t
+func (c *BlackoutCalendar) openAt(t time.Time) (string, time.Time) {
+	gaps := c.gaps(t, t.Add(time.Nanosecond))
+	if len(gaps) == 0 {
+		return "", time.Time{}
+	}
+	name, end := gaps[0].Window, t
+	for _, gap := range gaps {
+		if gap.End.After(end) {
+			end = gap.End
+		}
+	}
+	for limit := t.Add(maxBlackout); end.Before(limit); {
+		extended := end
+		for _, gap := range c.gaps(end, end.Add(time.Nanosecond)) {
+			if gap.End.After(extended) {
+				extended = gap.End
+			}
+		}
+		if extended == end {
+			break
+		}
+		end = extended
+	}
+	return name, end
+}
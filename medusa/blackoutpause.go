@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// blackoutPause holds load generation back while a window of the blackout
+// calendar is open, so a soak test running into nightly maintenance records a
+// gap instead of the target's maintenance errors. The plan's clock keeps
+// running: the run still ends on time.
+type blackoutPause struct {
+	calendar *BlackoutCalendar
+	paused   atomic.Bool
+	stopChan chan struct{}
+	wg       sync.WaitGroup
+
+	mutex sync.Mutex
+	gaps  []blackoutGap // End is when generation resumed
+}
+
+// newBlackoutPause returns nil without a calendar; it warns about the
+// windows the planned run will run into
+func newBlackoutPause(calendar *BlackoutCalendar, planned time.Duration) *blackoutPause {
+	if calendar == nil {
+		return nil
+	}
+	if planned > 0 {
+		now := time.Now()
+		if gaps := calendar.gaps(now, now.Add(planned)); len(gaps) > 0 {
+			windows := make([]string, len(gaps))
+			for i, gap := range gaps {
+				windows[i] = gap.String()
+			}
+			fmt.Printf("Warning: the run overlaps the blackout windows %s; load pauses during them\n", strings.Join(windows, ", "))
+		}
+	}
+	return &blackoutPause{calendar: calendar, stopChan: make(chan struct{})}
+}
+
+// Start checks the calendar now and then once a second
+func (b *blackoutPause) Start() {
+	if b == nil {
+		return
+	}
+	b.check(time.Now())
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-b.stopChan:
+				return
+			case now := <-ticker.C:
+				b.check(now)
+			}
+		}
+	}()
+}
+
+// check pauses or resumes generation as windows open and close
+func (b *blackoutPause) check(now time.Time) {
+	name, end := b.calendar.openAt(now)
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	switch paused := b.paused.Load(); {
+	case name != "" && !paused:
+		fmt.Printf("Blackout window %s is open until %s, pausing load generation\n", name, end.Format(time.RFC3339))
+		b.gaps = append(b.gaps, blackoutGap{Window: name, Start: now})
+		b.paused.Store(true)
+	case name == "" && paused:
+		fmt.Println("Blackout window closed, resuming load generation")
+		b.gaps[len(b.gaps)-1].End = now
+		b.paused.Store(false)
+	}
+}
+
+// Paused reports whether load generation should currently pause. A nil
+// pause never pauses.
+func (b *blackoutPause) Paused() bool {
+	return b != nil && b.paused.Load()
+}
+
+// Stop ends the checks; a gap still open ends with the run
+func (b *blackoutPause) Stop() {
+	if b == nil {
+		return
+	}
+	close(b.stopChan)
+	b.wg.Wait()
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.paused.Load() {
+		b.gaps[len(b.gaps)-1].End = time.Now()
+	}
+}
+
+// report lists the gaps in the load and their total (nil without a calendar)
+func (b *blackoutPause) report() map[string]interface{} {
+	if b == nil {
+		return nil
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	var total time.Duration
+	gaps := make([]map[string]interface{}, 0, len(b.gaps))
+	for _, gap := range b.gaps {
+		total += gap.End.Sub(gap.Start)
+		gaps = append(gaps, map[string]interface{}{
+			"window":   gap.Window,
+			"start":    gap.Start.Format(time.RFC3339),
+			"end":      gap.End.Format(time.RFC3339),
+			"duration": gap.End.Sub(gap.Start).Round(time.Second).String(),
+		})
+	}
+	return map[string]interface{}{
+		"windows":   len(b.calendar.Windows),
+		"gaps":      gaps,
+		"pausedFor": total.Round(time.Second).String(),
+	}
+}
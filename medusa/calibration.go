@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"time"
+)
+
+// defaultCalibrationFile is where wsm calibrate writes its stamp
+const defaultCalibrationFile = "calibration.json"
+
+// loadCalibration reads the stamp wsm calibrate left for the results; it
+// returns nil when there is none. A stamp from another host says nothing
+// about this machine and is ignored.
+func loadCalibration(path string) (map[string]interface{}, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var stamp map[string]interface{}
+	if err := json.Unmarshal(data, &stamp); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	host, _ := os.Hostname()
+	if calibrated, _ := stamp["host"].(string); calibrated != host {
+		fmt.Printf("Ignoring calibration stamp %s of host %q\n", path, calibrated)
+		return nil, nil
+	}
+	if at, ok := stamp["calibratedAt"].(string); ok {
+		if t, err := time.Parse(time.RFC3339, at); err == nil {
+			stamp["ageHours"] = math.Round(time.Since(t).Hours()*10) / 10
+		}
+	}
+	return stamp, nil
+}
+
+// plannedPeakRPS returns the highest RPS the config asks for
+func plannedPeakRPS(config *Config) float64 {
+	if len(config.Test.EndpointRPS) > 0 {
+		var total float64
+		for _, rps := range config.Test.EndpointRPS {
+			total += rps
+		}
+		return total
+	}
+	if len(config.Test.Phases) > 0 {
+		return phasesPeakRPS(config)
+	}
+	if config.Test.AdaptiveRPS {
+		return config.Test.AdaptiveConfig.MaximumRPS
+	}
+	var peak float64
+	for _, stage := range config.Test.RampupStages {
+		peak = math.Max(peak, stage.TargetRPS)
+	}
+	return peak
+}
+
+// checkCalibration warns when the plan asks for more than the machine
+// reached in calibration, and notes it in the stamp
+func checkCalibration(stamp map[string]interface{}, config *Config) {
+	if stamp == nil {
+		return
+	}
+	maxRPS, _ := stamp["maxRPS"].(float64)
+	peak := plannedPeakRPS(config)
+	fmt.Printf("Calibrated maximum of this machine: %.0f RPS\n", maxRPS)
+	if maxRPS > 0 && peak > maxRPS {
+		stamp["plannedPeakRPS"] = peak
+		stamp["exceedsCalibration"] = true
+		fmt.Printf("Warning: the plan peaks at %s RPS, above the calibrated maximum; a ceiling may be the generator's, not the target's\n", formatRPS(peak))
+	}
+}
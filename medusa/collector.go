@@ -0,0 +1,213 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// collectorTokenEnv holds the shared secret of the collector, if it has one
+const collectorTokenEnv = "WSM_COLLECTOR_TOKEN"
+
+// streamMessage is one line of the stream to the collector: a "hello"
+// naming the run at the start of every connection, a "second" per completed
+// second and an "end" when the run is over
+type streamMessage struct {
+	Type     string                 `json:"type"`
+	Run      string                 `json:"run,omitempty"`
+	RunID    string                 `json:"runId,omitempty"` // shared by the agents of a distributed run
+	Platform string                 `json:"platform,omitempty"`
+	Host     string                 `json:"host,omitempty"`
+	Started  *time.Time             `json:"started,omitempty"`
+	Data     *secondSummary         `json:"data,omitempty"`
+	Target   float64                `json:"target,omitempty"` // target RPS when the second was sent
+	Status   map[string]interface{} `json:"status,omitempty"`
+}
+
+// metricsStreamer streams the per-second results of the run to a central
+// collector (wsm collector), which merges runners on different hosts into
+// one live view. The stream is a chunked POST of newline-delimited JSON; a
+// dropped connection is reopened and picks up at the next unsent second.
+type metricsStreamer struct {
+	url    string
+	token  string
+	hello  streamMessage
+	series *secondSeries
+	status func() map[string]interface{}
+	target func() float64
+	client *http.Client
+
+	stop chan struct{}
+	done chan struct{}
+
+	next       int // first second not sent yet
+	reconnects int
+}
+
+// newMetricsStreamer returns nil when collector is empty
+func newMetricsStreamer(collector, platform string, series *secondSeries, status func() map[string]interface{}, target func() float64) (*metricsStreamer, error) {
+	if collector == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(collector)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("collector %q is not an http(s) URL", collector)
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/v1/stream"
+	host, _ := os.Hostname()
+	return &metricsStreamer{
+		url:    u.String(),
+		token:  os.Getenv(collectorTokenEnv),
+		hello:  streamMessage{Type: "hello", Platform: platform, Host: host},
+		series: series,
+		status: status,
+		target: target,
+		client: &http.Client{}, // no timeout, the request lasts the whole run
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}, nil
+}
+
+// Start begins streaming; call it when the series starts
+func (s *metricsStreamer) Start(start time.Time) {
+	if s == nil {
+		return
+	}
+	s.hello.Run = fmt.Sprintf("%s-%s-%d", s.hello.Platform, s.hello.Host, start.Unix())
+	s.hello.RunID = runID
+	s.hello.Started = &start
+	go s.run()
+}
+
+// Stop sends the last seconds and the end of the run, waiting a few seconds
+// at most for a slow collector
+func (s *metricsStreamer) Stop() {
+	if s == nil {
+		return
+	}
+	close(s.stop)
+	select {
+	case <-s.done:
+	case <-time.After(5 * time.Second):
+		log.Printf("Collector did not take the end of the stream in time")
+		return
+	}
+	fmt.Printf("Streamed %d seconds to the collector at %s (%d reconnects)\n", s.next, s.url, s.reconnects)
+}
+
+func (s *metricsStreamer) run() {
+	defer close(s.done)
+	backoff := time.Second
+	for {
+		err := s.stream()
+		if err == nil {
+			return
+		}
+		log.Printf("Streaming to the collector failed: %v", err)
+		select {
+		case <-s.stop:
+			return
+		case <-time.After(backoff):
+		}
+		s.reconnects++
+		backoff = min(backoff*2, 30*time.Second)
+	}
+}
+
+// stream sends one connection's worth; it returns nil once the end of the
+// run was delivered
+func (s *metricsStreamer) stream() error {
+	reader, writer := io.Pipe()
+	request, err := http.NewRequest(http.MethodPost, s.url, reader)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-ndjson")
+	if s.token != "" {
+		request.Header.Set("Authorization", "Bearer "+s.token)
+	}
+	result := make(chan error, 1)
+	go func() {
+		response, err := s.client.Do(request)
+		if err == nil {
+			io.Copy(io.Discard, response.Body)
+			response.Body.Close()
+			if response.StatusCode != http.StatusOK {
+				err = fmt.Errorf("collector answered %s", response.Status)
+			}
+		}
+		reader.CloseWithError(err) // unblocks a pending write
+		result <- err
+	}()
+
+	encoder := json.NewEncoder(writer)
+	send := func(message streamMessage) error {
+		return encoder.Encode(message)
+	}
+	fail := func(err error) error {
+		writer.Close()
+		if requestErr := <-result; requestErr != nil {
+			return requestErr
+		}
+		return err
+	}
+	if err := send(s.hello); err != nil {
+		return fail(err)
+	}
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			if err := s.flush(send); err != nil {
+				return fail(err)
+			}
+			if err := send(streamMessage{Type: "end"}); err != nil {
+				return fail(err)
+			}
+			writer.Close()
+			return <-result
+		case <-ticker.C:
+			if err := s.flush(send); err != nil {
+				return fail(err)
+			}
+		case err := <-result:
+			writer.Close()
+			if err == nil {
+				err = errors.New("collector closed the stream")
+			}
+			return err
+		}
+	}
+}
+
+// flush sends the seconds completed since the last flush
+func (s *metricsStreamer) flush(send func(streamMessage) error) error {
+	seconds := s.series.completed(s.next)
+	if len(seconds) == 0 {
+		return nil
+	}
+	target := roundRPS(s.target())
+	status := s.status()
+	for i := range seconds {
+		message := streamMessage{Type: "second", Data: &seconds[i], Target: target}
+		if i == len(seconds)-1 {
+			message.Status = status
+		}
+		if err := send(message); err != nil {
+			return err
+		}
+		s.next = seconds[i].Second + 1
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// corpusTasks generates numbered requests alternating between two header
+// sets
+func corpusTasks() func() Task {
+	n := 0
+	return func() Task {
+		n++
+		headers := map[string]string{"Accept": "application/json"}
+		if n%2 == 0 {
+			headers = map[string]string{"Accept": "application/json", "Authorization": "Bearer token"}
+		}
+		return Task{Type: "products", Method: "POST", URL: fmt.Sprintf("http://shop/products/%d", n), Body: fmt.Sprintf(`{"n":%d}`, n), Headers: headers}
+	}
+}
+
+func writeTestCorpus(t *testing.T, count int) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "requests.corpus")
+	if err := writeRequestCorpus(path, "fingerprint", count, corpusTasks()); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRequestCorpusRoundTrip(t *testing.T) {
+	path := writeTestCorpus(t, 3)
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("corpus file %v, %v; want it private", info, err)
+	}
+	c, err := openRequestCorpus(path, "fingerprint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.count != 3 || len(c.headers) != 2 {
+		t.Fatalf("%d requests and %d header sets, want 3 and 2", c.count, len(c.headers))
+	}
+
+	// The requests come back in order, starting over after the last one
+	want := corpusTasks()
+	for i := 0; i < 3; i++ {
+		w := want()
+		got := c.next()
+		if got.Type != w.Type || got.Method != w.Method || got.URL != w.URL || got.Body != w.Body || len(got.Headers) != len(w.Headers) {
+			t.Errorf("request %d: %+v, want %+v", i, got, w)
+		}
+	}
+	var urls []string
+	for i := 0; i < 4; i++ {
+		urls = append(urls, c.next().URL)
+	}
+	if got := strings.Join(urls, " "); got != "http://shop/products/1 http://shop/products/2 http://shop/products/3 http://shop/products/1" {
+		t.Errorf("after wrapping around: %s", got)
+	}
+
+	report := c.report()
+	if report["replayed"] != int64(7) || report["fullPasses"] != int64(2) || report["requests"] != 3 {
+		t.Errorf("report %v, want 7 replayed in 2 full passes of 3", report)
+	}
+}
+
+func TestRequestCorpusErrors(t *testing.T) {
+	path := writeTestCorpus(t, 3)
+	if _, err := openRequestCorpus(path, "other"); err == nil || !strings.Contains(err.Error(), "different config") {
+		t.Errorf("other fingerprint: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	truncated := filepath.Join(t.TempDir(), "truncated.corpus")
+	if err := os.WriteFile(truncated, data[:len(data)-5], 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := openRequestCorpus(truncated, "fingerprint"); err != errCorpusTruncated {
+		t.Errorf("truncated corpus: %v", err)
+	}
+
+	other := filepath.Join(t.TempDir(), "other.corpus")
+	if err := os.WriteFile(other, []byte("Accept,Authorization\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := openRequestCorpus(other, "fingerprint"); err == nil {
+		t.Error("a file that is not a corpus opened")
+	}
+	if _, err := openRequestCorpus(writeTestCorpus(t, 0), "fingerprint"); err == nil {
+		t.Error("an empty corpus opened")
+	}
+	if _, err := openRequestCorpus(filepath.Join(t.TempDir(), "missing.corpus"), "fingerprint"); !os.IsNotExist(err) {
+		t.Errorf("missing corpus: %v, want a not-exist error to build it", err)
+	}
+}
+
+// With -generators above 1 several goroutines replay the corpus at once;
+// run with -race
+func TestRequestCorpusConcurrentNext(t *testing.T) {
+	c, err := openRequestCorpus(writeTestCorpus(t, 3), "fingerprint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	const generators, perGenerator = 4, 300
+	counts := make([]map[string]int, generators)
+	var wg sync.WaitGroup
+	for g := range counts {
+		counts[g] = make(map[string]int)
+		wg.Add(1)
+		go func(seen map[string]int) {
+			defer wg.Done()
+			for i := 0; i < perGenerator; i++ {
+				seen[c.next().URL]++
+			}
+		}(counts[g])
+	}
+	wg.Wait()
+
+	// Every request is handed out equally often: none skipped or repeated
+	total := make(map[string]int)
+	for _, seen := range counts {
+		for url, n := range seen {
+			total[url] += n
+		}
+	}
+	if len(total) != 3 {
+		t.Errorf("requests %v, want all 3", total)
+	}
+	for url, n := range total {
+		if n != generators*perGenerator/3 {
+			t.Errorf("%s sent %d times, want %d", url, n, generators*perGenerator/3)
+		}
+	}
+	report := c.report()
+	if report["replayed"] != int64(generators*perGenerator) || report["fullPasses"] != int64(generators*perGenerator/3) {
+		t.Errorf("report %v", report)
+	}
+}
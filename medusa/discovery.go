@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Operation names of the requests for a single discovered resource
+const (
+	specificProductOperation  = "specificProduct"
+	specificCategoryOperation = "specificCategory"
+)
+
+// DiscoveryConfig fetches product and category IDs before the load, so that
+// requests for a single resource spread over the catalog instead of hitting
+// one cached product or category
+type DiscoveryConfig struct {
+	Percent float64 // share of requests for a single product or category; 0 turns discovery off
+	Limit   int     // products and categories fetched, default 100
+}
+
+// discoveredIDs are the IDs the single-resource requests draw from
+type discoveredIDs struct {
+	config        DiscoveryConfig
+	productsURL   string
+	categoriesURL string
+	products      []string
+	categories    []string
+}
+
+// discoverIDs fetches a page of products and one of categories with the
+// publishable key; it returns nil when discovery is off
+func discoverIDs(config *Config) (*discoveredIDs, error) {
+	dc := config.Test.Discovery
+	if dc.Percent <= 0 {
+		return nil, nil
+	}
+	if dc.Percent > 100 {
+		return nil, fmt.Errorf("discovery percent %.1f is above 100", dc.Percent)
+	}
+	if dc.Limit <= 0 {
+		dc.Limit = 100
+	}
+	d := &discoveredIDs{
+		config:        dc,
+		productsURL:   strings.TrimSuffix(config.Endpoints.Products, "/"),
+		categoriesURL: strings.TrimSuffix(config.Endpoints.Categories, "/"),
+	}
+	client := &http.Client{Timeout: 15 * time.Second}
+
+	var err error
+	d.products, err = fetchIDs(client, config.APIKey, d.productsURL, "products", dc.Limit)
+	if err != nil {
+		return nil, err
+	}
+	if len(d.products) == 0 {
+		return nil, fmt.Errorf("%s returned no products", d.productsURL)
+	}
+	if config.Endpoints.Categories != "" {
+		// A store without categories still gets single-product requests
+		d.categories, err = fetchIDs(client, config.APIKey, d.categoriesURL, "product_categories", dc.Limit)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return d, nil
+}
+
+// fetchIDs lists up to limit resources and returns the id of each entry of
+// the field holding them, e.g. "products"
+func fetchIDs(client *http.Client, apiKey, endpoint, field string, limit int) ([]string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	query := u.Query()
+	query.Set("limit", fmt.Sprint(limit))
+	query.Set("fields", "id")
+	u.RawQuery = query.Encode()
+
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("x-publishable-api-key", apiKey)
+	req.Header.Set("Accept", "application/json")
+	setLoadTestID(req.Header)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: status %d", u, resp.StatusCode)
+	}
+
+	var page map[string]json.RawMessage
+	if err := json.Unmarshal(body, &page); err != nil {
+		return nil, fmt.Errorf("%s: response is not JSON: %v", u, err)
+	}
+	var entries []struct {
+		ID string `json:"id"`
+	}
+	if raw, ok := page[field]; ok {
+		if err := json.Unmarshal(raw, &entries); err != nil {
+			return nil, fmt.Errorf("%s: %s is not a list: %v", u, field, err)
+		}
+	}
+	var ids []string
+	for _, entry := range entries {
+		if entry.ID != "" {
+			ids = append(ids, entry.ID)
+		}
+	}
+	return ids, nil
+}
+
+// pick decides whether the next request is for a single discovered product
+// or category. A nil set never picks.
+func (d *discoveredIDs) pick(headers map[string]string) (Task, bool) {
+	if d == nil || rand.Float64()*100 >= d.config.Percent {
+		return Task{}, false
+	}
+	if len(d.categories) > 0 && rand.Intn(2) == 0 {
+		id := d.categories[rand.Intn(len(d.categories))]
+		return Task{URL: d.categoriesURL + "/" + url.PathEscape(id), Headers: headers, Method: "GET", Type: specificCategoryOperation}, true
+	}
+	id := d.products[rand.Intn(len(d.products))]
+	return Task{URL: d.productsURL + "/" + url.PathEscape(id), Headers: headers, Method: "GET", Type: specificProductOperation}, true
+}
+
+// report lists how many IDs were discovered
+func (d *discoveredIDs) report() map[string]interface{} {
+	if d == nil {
+		return nil
+	}
+	return map[string]interface{}{
+		"percent":    d.config.Percent,
+		"products":   len(d.products),
+		"categories": len(d.categories),
+	}
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FakerConfig sets up the synthetic customer data filled into request
+// templates ({{email}}, {{firstName}}, ...). A fixed Seed makes runs reproducible.
+type FakerConfig struct {
+	Locale string // en_US (default), en_GB, de_DE or fr_FR
+	Seed   int64  // 0 seeds from the clock
+}
+
+// fakerLocale is the data one locale draws from
+type fakerLocale struct {
+	firstNames  []string
+	lastNames   []string
+	streets     []string
+	streetFirst bool // "12 Main Street" vs "Hauptstraße 12"
+	cities      []string
+	postalCode  string // pattern, # is a digit
+	country     string // ISO 3166-1 alpha-2
+	phone       string // pattern, # is a digit
+	emailDomain string
+}
+
+var fakerLocales = map[string]fakerLocale{
+	"en_US": {
+		firstNames:  []string{"James", "Mary", "Robert", "Patricia", "John", "Jennifer", "Michael", "Linda", "David", "Elizabeth", "Maria", "Daniel"},
+		lastNames:   []string{"Smith", "Johnson", "Williams", "Brown", "Jones", "Garcia", "Miller", "Davis", "Rodriguez", "Martinez", "Wilson", "Taylor"},
+		streets:     []string{"Main Street", "Oak Avenue", "Maple Drive", "Cedar Lane", "Park Road", "Washington Boulevard", "Lake Street", "Hill Road"},
+		streetFirst: true,
+		cities:      []string{"Springfield", "Portland", "Austin", "Denver", "Columbus", "Madison", "Raleigh", "Boise"},
+		postalCode:  "#####",
+		country:     "US",
+		phone:       "+1 ###-###-####",
+		emailDomain: "example.com",
+	},
+	"en_GB": {
+		firstNames:  []string{"Oliver", "Amelia", "George", "Isla", "Harry", "Ava", "Jack", "Emily", "Charlie", "Sophie", "Thomas", "Grace"},
+		lastNames:   []string{"Smith", "Jones", "Taylor", "Brown", "Williams", "Wilson", "Evans", "Thomas", "Roberts", "Walker", "Wright", "Hughes"},
+		streets:     []string{"High Street", "Station Road", "Church Lane", "Victoria Road", "Green Lane", "Manor Road", "Park Avenue", "Queens Road"},
+		streetFirst: true,
+		cities:      []string{"Leeds", "Bristol", "York", "Cardiff", "Oxford", "Norwich", "Bath", "Brighton"},
+		postalCode:  "LS# #AB",
+		country:     "GB",
+		phone:       "+44 7### ######",
+		emailDomain: "example.co.uk",
+	},
+	"de_DE": {
+		firstNames:  []string{"Lukas", "Anna", "Leon", "Marie", "Finn", "Sophie", "Jonas", "Emma", "Paul", "Mia", "Felix", "Lena"},
+		lastNames:   []string{"Müller", "Schmidt", "Schneider", "Fischer", "Weber", "Meyer", "Wagner", "Becker", "Schulz", "Hoffmann", "Koch", "Richter"},
+		streets:     []string{"Hauptstraße", "Schulstraße", "Gartenstraße", "Bahnhofstraße", "Dorfstraße", "Bergstraße", "Lindenstraße", "Waldweg"},
+		streetFirst: false,
+		cities:      []string{"Berlin", "Hamburg", "München", "Köln", "Leipzig", "Dresden", "Bremen", "Freiburg"},
+		postalCode:  "#####",
+		country:     "DE",
+		phone:       "+49 15# #######",
+		emailDomain: "example.de",
+	},
+	"fr_FR": {
+		firstNames:  []string{"Gabriel", "Louise", "Léo", "Jade", "Raphaël", "Emma", "Arthur", "Alice", "Louis", "Chloé", "Jules", "Lina"},
+		lastNames:   []string{"Martin", "Bernard", "Dubois", "Thomas", "Robert", "Richard", "Petit", "Durand", "Leroy", "Moreau", "Simon", "Laurent"},
+		streets:     []string{"rue de la Paix", "avenue Victor Hugo", "rue Nationale", "boulevard Voltaire", "rue du Moulin", "place de l'Église", "rue des Écoles", "allée des Tilleuls"},
+		streetFirst: true,
+		cities:      []string{"Paris", "Lyon", "Marseille", "Toulouse", "Nantes", "Lille", "Bordeaux", "Rennes"},
+		postalCode:  "#####",
+		country:     "FR",
+		phone:       "+33 6 ## ## ## ##",
+		emailDomain: "example.fr",
+	},
+}
+
+// fakerCards are the test card numbers payment providers accept in sandbox mode
+var fakerCards = []string{"4242424242424242", "5555555555554444", "378282246310005", "4000056655665556"}
+
+// faker generates synthetic customer data; it is safe for concurrent use
+type faker struct {
+	config FakerConfig
+	locale fakerLocale
+	mutex  sync.Mutex
+	rng    *rand.Rand
+}
+
+// newFaker validates the locale; the faker is always available so templates
+// work without configuration
+func newFaker(config FakerConfig) (*faker, error) {
+	if config.Locale == "" {
+		config.Locale = "en_US"
+	}
+	locale, ok := fakerLocales[config.Locale]
+	if !ok {
+		return nil, fmt.Errorf("unknown faker locale %q (en_US, en_GB, de_DE or fr_FR)", config.Locale)
+	}
+	if config.Seed == 0 {
+		config.Seed = time.Now().UnixNano()
+	}
+	return &faker{config: config, locale: locale, rng: rand.New(rand.NewSource(config.Seed))}, nil
+}
+
+// report records the locale and seed, so a run's data can be generated again
+func (f *faker) report() map[string]interface{} {
+	return map[string]interface{}{"locale": f.config.Locale, "seed": f.config.Seed}
+}
+
+// fakerPlaceholder matches {{name}} in templates
+var fakerPlaceholder = regexp.MustCompile(`\{\{(\w+)\}\}`)
+
+// fakerRecord is one synthetic person; every placeholder filled from the same
+// record describes them, so {{email}} matches {{firstName}}
+type fakerRecord struct {
+	first, last string
+	values      map[string]string
+}
+
+// fill replaces the {{...}} placeholders in the templates, all from one record
+func (f *faker) fill(templates ...string) []string {
+	filled := make([]string, len(templates))
+	copy(filled, templates)
+	if f == nil {
+		return filled
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	record := f.record()
+	for i, template := range templates {
+		filled[i] = f.fillRecord(template, record)
+	}
+	return filled
+}
+
+// fillValue fills the placeholders in every string of a JSON-like value
+// (e.g. GraphQL variables), all from one record
+func (f *faker) fillValue(v interface{}) interface{} {
+	if f == nil {
+		return v
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.fillValueRecord(v, f.record())
+}
+
+// record draws a new person; callers hold the mutex
+func (f *faker) record() *fakerRecord {
+	return &fakerRecord{
+		first:  f.pick(f.locale.firstNames),
+		last:   f.pick(f.locale.lastNames),
+		values: make(map[string]string),
+	}
+}
+
+// fillRecord fills one template; callers hold the mutex
+func (f *faker) fillRecord(template string, record *fakerRecord) string {
+	if !strings.Contains(template, "{{") {
+		return template
+	}
+	return fakerPlaceholder.ReplaceAllStringFunc(template, func(match string) string {
+		name := match[2 : len(match)-2]
+		if v, ok := record.values[name]; ok {
+			return v
+		}
+		v, ok := f.value(name, record)
+		if !ok {
+			return match
+		}
+		record.values[name] = v
+		return v
+	})
+}
+
+// fillValueRecord fills the strings of a JSON-like value; callers hold the mutex
+func (f *faker) fillValueRecord(v interface{}, record *fakerRecord) interface{} {
+	switch t := v.(type) {
+	case string:
+		return f.fillRecord(t, record)
+	case map[string]interface{}:
+		filled := make(map[string]interface{}, len(t))
+		for key, value := range t {
+			filled[key] = f.fillValueRecord(value, record)
+		}
+		return filled
+	case []interface{}:
+		filled := make([]interface{}, len(t))
+		for i, value := range t {
+			filled[i] = f.fillValueRecord(value, record)
+		}
+		return filled
+	}
+	return v
+}
+
+// value generates one placeholder; callers hold the mutex
+func (f *faker) value(name string, record *fakerRecord) (string, bool) {
+	l := f.locale
+	first, last := record.first, record.last
+	switch name {
+	case "firstName":
+		return first, true
+	case "lastName":
+		return last, true
+	case "fullName":
+		return first + " " + last, true
+	case "email":
+		return fmt.Sprintf("%s.%s.%d@%s", asciiLower(first), asciiLower(last), f.rng.Intn(100000), l.emailDomain), true
+	case "phone":
+		return f.digits(l.phone), true
+	case "street":
+		number := f.rng.Intn(200) + 1
+		if l.streetFirst {
+			return fmt.Sprintf("%d %s", number, f.pick(l.streets)), true
+		}
+		return fmt.Sprintf("%s %d", f.pick(l.streets), number), true
+	case "city":
+		return f.pick(l.cities), true
+	case "postalCode":
+		return f.digits(l.postalCode), true
+	case "country":
+		return l.country, true
+	case "cardNumber":
+		return f.pick(fakerCards), true
+	case "cardExpiry":
+		return fmt.Sprintf("%02d/%02d", f.rng.Intn(12)+1, time.Now().Year()%100+1+f.rng.Intn(4)), true
+	case "cvc":
+		return fmt.Sprintf("%03d", f.rng.Intn(1000)), true
+	case "uuid":
+		b := make([]byte, 16)
+		for i := range b {
+			b[i] = byte(f.rng.Intn(256))
+		}
+		b[6] = b[6]&0x0f | 0x40
+		b[8] = b[8]&0x3f | 0x80
+		return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), true
+	case "quantity":
+		return fmt.Sprint(f.rng.Intn(3) + 1), true
+	}
+	return "", false
+}
+
+// pick returns a random element; callers hold the mutex
+func (f *faker) pick(values []string) string {
+	return values[f.rng.Intn(len(values))]
+}
+
+// digits replaces each # in the pattern with a random digit; callers hold the mutex
+func (f *faker) digits(pattern string) string {
+	var b strings.Builder
+	for _, r := range pattern {
+		if r == '#' {
+			b.WriteByte(byte('0' + f.rng.Intn(10)))
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// asciiLower lowercases a name for use in an email address, dropping accents
+// and anything else outside a-z
+func asciiLower(s string) string {
+	replacer := strings.NewReplacer("ä", "ae", "ö", "oe", "ü", "ue", "ß", "ss", "é", "e", "è", "e", "ë", "e", "ï", "i", "ç", "c")
+	s = replacer.Replace(strings.ToLower(s))
+	var b strings.Builder
+	for _, r := range s {
+		if r >= 'a' && r <= 'z' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
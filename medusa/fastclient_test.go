@@ -0,0 +1,168 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func fastTestServer(t *testing.T, tls bool) (*httptest.Server, *fasthttpTransport) {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/echo", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("X-Method", r.Method)
+		w.Header().Set("X-Key", r.Header.Get("x-publishable-api-key"))
+		w.Header().Set("X-Agent", r.Header.Get("User-Agent"))
+		w.WriteHeader(http.StatusCreated)
+		w.Write(body)
+	})
+	mux.HandleFunc("/gzip", func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+			io.WriteString(w, "plain")
+			return
+		}
+		w.Header().Set("Content-Encoding", "gzip")
+		z := gzip.NewWriter(w)
+		io.WriteString(z, "compressed")
+		z.Close()
+	})
+	mux.HandleFunc("/redirect", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/echo", http.StatusFound)
+	})
+	mux.HandleFunc("/close", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Connection", "close")
+	})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(500 * time.Millisecond)
+	})
+
+	var server *httptest.Server
+	if tls {
+		server = httptest.NewTLSServer(mux)
+	} else {
+		server = httptest.NewServer(mux)
+	}
+	t.Cleanup(server.Close)
+	transport := newFasthttpTransport((&net.Dialer{}).DialContext, 4, 5*time.Second, true)
+	if tls {
+		transport.tls = server.Client().Transport.(*http.Transport).TLSClientConfig
+	}
+	return server, transport
+}
+
+func TestFasthttpTransportRequests(t *testing.T) {
+	server, transport := fastTestServer(t, false)
+	client := &http.Client{Transport: transport, Timeout: 5 * time.Second}
+
+	req, _ := http.NewRequest("POST", server.URL+"/echo", strings.NewReader(`{"quantity": 1}`))
+	req.Header.Set("x-publishable-api-key", "pk_test")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated || string(body) != `{"quantity": 1}` || resp.ContentLength != int64(len(body)) {
+		t.Errorf("echo: %s, %q, length %d", resp.Status, body, resp.ContentLength)
+	}
+	if resp.Header.Get("X-Method") != "POST" || resp.Header.Get("X-Key") != "pk_test" || resp.Header.Get("X-Agent") != "Go-http-client/1.1" {
+		t.Errorf("echo headers: %v", resp.Header)
+	}
+
+	// gzip is asked for and decompressed, as net/http does
+	resp, err = client.Get(server.URL + "/gzip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ = io.ReadAll(resp.Body)
+	if string(body) != "compressed" || !resp.Uncompressed || resp.Header.Get("Content-Encoding") != "" {
+		t.Errorf("gzip: %q, uncompressed %v, %v", body, resp.Uncompressed, resp.Header)
+	}
+
+	// Redirects are followed by http.Client, with its policy
+	resp, err = client.Get(server.URL + "/redirect")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusCreated || resp.Request.URL.Path != "/echo" {
+		t.Errorf("redirect: %s at %s", resp.Status, resp.Request.URL)
+	}
+
+	client.Timeout = 50 * time.Millisecond
+	if _, err := client.Get(server.URL + "/slow"); !isTimeoutError(err) {
+		t.Errorf("slow response: %v, want a timeout", err)
+	}
+}
+
+// Connection reuse and the trace phases come from the transport's own
+// connections
+func TestFasthttpTransportConnections(t *testing.T) {
+	for _, tls := range []bool{false, true} {
+		server, transport := fastTestServer(t, tls)
+		client := &http.Client{Transport: transport}
+		conns := newConnReuse()
+		var timings []*traceTiming
+		get := func(path string) {
+			t.Helper()
+			timing := &traceTiming{start: time.Now()}
+			timings = append(timings, timing)
+			req, _ := http.NewRequest("GET", server.URL+path, nil)
+			req = conns.begin(req.WithContext(context.WithValue(context.Background(), traceTimingKey{}, timing)))
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		for i := 0; i < 5; i++ {
+			get("/echo")
+		}
+		if conns.opened.Load() != 1 || conns.reused.Load() != 4 || conns.idle.Load() != 4 {
+			t.Errorf("tls %v: %d opened, %d reused (%d idle), want 1 and 4", tls, conns.opened.Load(), conns.reused.Load(), conns.idle.Load())
+		}
+		first, second := timings[0], timings[1]
+		if first.connectStart.IsZero() || first.connectDone.Before(first.connectStart) {
+			t.Errorf("tls %v: first request has no connect phase", tls)
+		}
+		if tls != !first.tlsStart.IsZero() || first.tlsDone.Before(first.tlsStart) {
+			t.Errorf("tls %v: TLS phase %s to %s", tls, first.tlsStart, first.tlsDone)
+		}
+		if !second.connectStart.IsZero() {
+			t.Errorf("tls %v: a reused connection has a connect phase", tls)
+		}
+		for i, timing := range timings {
+			if timing.wroteRequest.Before(timing.start) || timing.firstByte.Before(timing.wroteRequest) {
+				t.Errorf("tls %v: request %d sent %s, first byte %s", tls, i, timing.wroteRequest, timing.firstByte)
+			}
+		}
+
+		// A server closing every connection means a new one per request
+		// after the first, which still gets the idle one
+		for i := 0; i < 3; i++ {
+			get("/close")
+		}
+		if conns.opened.Load() != 1+2 || conns.reused.Load() != 4+1 {
+			t.Errorf("tls %v: %d opened, %d reused with Connection: close, want 3 and 5", tls, conns.opened.Load(), conns.reused.Load())
+		}
+	}
+}
+
+func TestCheckClientBackend(t *testing.T) {
+	for _, name := range []string{"", "net/http", "fasthttp"} {
+		if err := checkClientBackend(name); err != nil {
+			t.Errorf("%q: %v", name, err)
+		}
+	}
+	if err := checkClientBackend("curl"); err == nil {
+		t.Error("unknown client accepted")
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// fingerprintHeaders are response headers that identify the server software or build
+var fingerprintHeaders = []string{"Server", "X-Powered-By", "Via", "X-Version", "X-App-Version", "X-Api-Version", "X-Build"}
+
+// headerFingerprint collects the identifying headers present on a response
+func headerFingerprint(resp *http.Response) map[string]string {
+	headers := make(map[string]string)
+	for _, name := range fingerprintHeaders {
+		if value := resp.Header.Get(name); value != "" {
+			headers[name] = value
+		}
+	}
+	return headers
+}
+
+// fingerprintTarget checks that the Medusa server is up before any load is
+// sent: it calls the /health endpoint on the products endpoint's host and the
+// products endpoint itself with the publishable key, and records identifying
+// response headers. An unreachable target or a 5xx response is an error.
+func fingerprintTarget(config *Config) (map[string]interface{}, error) {
+	client := &http.Client{Timeout: 15 * time.Second}
+
+	productsURL, err := url.Parse(config.Endpoints.Products)
+	if err != nil {
+		return nil, fmt.Errorf("invalid products endpoint: %v", err)
+	}
+	healthURL := (&url.URL{Scheme: productsURL.Scheme, Host: productsURL.Host, Path: "/health"}).String()
+
+	env := map[string]interface{}{
+		"probedAt": time.Now().Format(time.RFC3339),
+	}
+	probes := make(map[string]interface{})
+	for _, target := range []struct{ name, url string }{{"health", healthURL}, {"products", config.Endpoints.Products}} {
+		req, err := http.NewRequest("GET", target.url, nil)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", target.url, err)
+		}
+		req.Header.Set("x-publishable-api-key", config.APIKey)
+		setLoadTestID(req.Header)
+
+		start := time.Now()
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", target.url, err)
+		}
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		resp.Body.Close()
+		if resp.StatusCode >= 500 {
+			return nil, fmt.Errorf("%s: status %d", target.url, resp.StatusCode)
+		}
+
+		probe := map[string]interface{}{
+			"url":          target.url,
+			"statusCode":   resp.StatusCode,
+			"probeLatency": time.Since(start).String(),
+		}
+		if target.name == "health" {
+			probe["body"] = strings.TrimSpace(string(body))
+		}
+		probes[target.name] = probe
+		if _, ok := env["headers"]; !ok {
+			env["headers"] = headerFingerprint(resp)
+		}
+	}
+	env["endpoints"] = probes
+	return env, nil
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Hook is a command or webhook run before, during or after the test, e.g. to
+// trigger a chaos action such as killing a pod or failing over the database
+type Hook struct {
+	Name    string
+	Phase   string        // "pre", "during" or "post"
+	Offset  time.Duration // for "during": time after the test starts
+	Command string        // run with sh -c
+	URL     string        // webhook, called with a JSON POST
+	Timeout time.Duration // default 60s
+}
+
+// hookRunner runs the configured hooks and records each as an annotation
+type hookRunner struct {
+	platform    string
+	hooks       []Hook
+	start       time.Time
+	timers      []*time.Timer
+	wg          sync.WaitGroup
+	mutex       sync.Mutex
+	annotations []map[string]interface{}
+}
+
+// newHookRunner validates the hooks and returns nil when none are configured
+func newHookRunner(platform string, hooks []Hook) (*hookRunner, error) {
+	if len(hooks) == 0 {
+		return nil, nil
+	}
+	for _, h := range hooks {
+		if h.Name == "" {
+			return nil, fmt.Errorf("every hook needs a name")
+		}
+		if h.Phase != "pre" && h.Phase != "during" && h.Phase != "post" {
+			return nil, fmt.Errorf("hook %s: phase must be pre, during or post", h.Name)
+		}
+		if (h.Command == "") == (h.URL == "") {
+			return nil, fmt.Errorf("hook %s: set exactly one of Command and URL", h.Name)
+		}
+	}
+	return &hookRunner{platform: platform, hooks: hooks}, nil
+}
+
+// run executes one hook and records the outcome
+func (r *hookRunner) run(h Hook) {
+	timeout := h.Timeout
+	if timeout <= 0 {
+		timeout = 60 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	now := time.Now()
+	offset := time.Duration(0)
+	if !r.start.IsZero() {
+		offset = now.Sub(r.start)
+	}
+	fmt.Printf("[%s] Running %s hook %s\n", now.Format(time.RFC3339), h.Phase, h.Name)
+
+	var output string
+	var err error
+	if h.Command != "" {
+		var out []byte
+		cmd := exec.CommandContext(ctx, "sh", "-c", h.Command)
+		cmd.Env = append(os.Environ(), runIDEnv+"="+runID)
+		out, err = cmd.CombinedOutput()
+		output = string(out)
+	} else {
+		payload, _ := json.Marshal(map[string]interface{}{
+			"hook":     h.Name,
+			"phase":    h.Phase,
+			"platform": r.platform,
+			"runId":    runID,
+			"time":     now.Format(time.RFC3339),
+			"offset":   offset.String(),
+		})
+		var req *http.Request
+		req, err = http.NewRequestWithContext(ctx, "POST", h.URL, bytes.NewReader(payload))
+		if err == nil {
+			req.Header.Set("Content-Type", "application/json")
+			var resp *http.Response
+			resp, err = http.DefaultClient.Do(req)
+			if err == nil {
+				resp.Body.Close()
+				output = resp.Status
+				if resp.StatusCode >= 400 {
+					err = fmt.Errorf("webhook returned %s", resp.Status)
+				}
+			}
+		}
+	}
+
+	annotation := map[string]interface{}{
+		"name":     h.Name,
+		"phase":    h.Phase,
+		"time":     now.Format(time.RFC3339),
+		"offset":   offset.Round(time.Millisecond).String(),
+		"duration": time.Since(now).Round(time.Millisecond).String(),
+		"success":  err == nil,
+	}
+	if output = strings.TrimSpace(output); output != "" {
+		if len(output) > 500 {
+			output = output[:500]
+		}
+		annotation["output"] = output
+	}
+	if err != nil {
+		annotation["error"] = err.Error()
+		fmt.Printf("Hook %s failed: %v\n", h.Name, err)
+	}
+
+	r.mutex.Lock()
+	r.annotations = append(r.annotations, annotation)
+	r.mutex.Unlock()
+}
+
+// runPhase runs the pre or post hooks in order. Safe to call on a nil runner.
+func (r *hookRunner) runPhase(phase string) {
+	if r == nil {
+		return
+	}
+	for _, h := range r.hooks {
+		if h.Phase == phase {
+			r.run(h)
+		}
+	}
+}
+
+// startDuring schedules the during hooks relative to the test start
+func (r *hookRunner) startDuring(start time.Time) {
+	if r == nil {
+		return
+	}
+	r.start = start
+	for _, h := range r.hooks {
+		if h.Phase != "during" {
+			continue
+		}
+		h := h
+		r.wg.Add(1)
+		timer := time.AfterFunc(h.Offset, func() {
+			defer r.wg.Done()
+			r.run(h)
+		})
+		r.timers = append(r.timers, timer)
+	}
+}
+
+// stopDuring cancels during hooks that haven't fired and waits for running ones
+func (r *hookRunner) stopDuring() {
+	if r == nil {
+		return
+	}
+	for _, timer := range r.timers {
+		if timer.Stop() {
+			r.wg.Done()
+		}
+	}
+	r.wg.Wait()
+}
+
+// report returns the annotations in the order the hooks ran
+func (r *hookRunner) report() []map[string]interface{} {
+	if r == nil {
+		return nil
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.annotations
+}
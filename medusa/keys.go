@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// PublishableKey is one publishable API key of the rotation. Keys of
+// different sales channels or cache scopes can be compared side by side,
+// and spreading the load avoids hitting a per-key rate limit.
+type PublishableKey struct {
+	Name    string   // label in the operation names and metrics, default key1, key2, ...
+	Key     string   // the pk_... value
+	Regions []string // region IDs sent as region_id, in turn, on the key's store requests
+}
+
+// keyRotation hands out the publishable keys, and each key's regions,
+// round-robin across requests
+type keyRotation struct {
+	keys    []PublishableKey
+	next    atomic.Uint64
+	regions []atomic.Uint64 // next region per key
+}
+
+// newKeyRotation validates the keys; it returns nil when there are none
+func newKeyRotation(keys []PublishableKey) (*keyRotation, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	names := make(map[string]bool)
+	for i := range keys {
+		if keys[i].Key == "" {
+			return nil, fmt.Errorf("API key %d has no Key", i+1)
+		}
+		if keys[i].Name == "" {
+			keys[i].Name = fmt.Sprintf("key%d", i+1)
+		}
+		if names[keys[i].Name] {
+			return nil, fmt.Errorf("API key name %q is used twice", keys[i].Name)
+		}
+		names[keys[i].Name] = true
+	}
+	return &keyRotation{keys: keys, regions: make([]atomic.Uint64, len(keys))}, nil
+}
+
+// keyTag marks the operations sent with a key, e.g. "products [key:b2b]"
+func keyTag(name string) string {
+	return " [key:" + name + "]"
+}
+
+// apply sets the next key, and region if the key has any, on a store request
+// and tags its operation so every per-operation metric is split per key
+func (r *keyRotation) apply(task *Task) {
+	if r == nil {
+		return
+	}
+	if task.Page != nil {
+		for i := range task.Page.calls {
+			r.apply(&task.Page.calls[i])
+		}
+		return
+	}
+	// Only store requests carry a publishable key; assets and uploads don't
+	if _, ok := task.Headers["x-publishable-api-key"]; !ok {
+		return
+	}
+	i := int((r.next.Add(1) - 1) % uint64(len(r.keys)))
+	key := &r.keys[i]
+	task.Headers = withHeaders(task.Headers, map[string]string{"x-publishable-api-key": key.Key})
+	task.Type += keyTag(key.Name)
+	if len(key.Regions) == 0 || task.Method != "GET" {
+		return
+	}
+	region := key.Regions[(r.regions[i].Add(1)-1)%uint64(len(key.Regions))]
+	if u, err := url.Parse(task.URL); err == nil {
+		query := u.Query()
+		query.Set("region_id", region)
+		u.RawQuery = query.Encode()
+		task.URL = u.String()
+	}
+}
+
+// maskKey shortens a key for the results, e.g. "pk_cf8e...21fa"
+func maskKey(key string) string {
+	if len(key) <= 12 {
+		return key
+	}
+	return key[:7] + "..." + key[len(key)-4:]
+}
+
+// report returns the requests, errors and latency per key
+func (r *keyRotation) report(counts, failures map[string]int64, durations map[string][]time.Duration) map[string]interface{} {
+	if r == nil {
+		return nil
+	}
+	keys := make(map[string]interface{}, len(r.keys))
+	for _, key := range r.keys {
+		tag := keyTag(key.Name)
+		var requests, failed int64
+		var sorted []time.Duration
+		for op, count := range counts {
+			if !strings.Contains(op, tag) {
+				continue
+			}
+			requests += count
+			failed += failures[op]
+			sorted = append(sorted, durations[op]...)
+		}
+		entry := map[string]interface{}{
+			"key":            maskKey(key.Key),
+			"requests":       requests,
+			"failedRequests": failed,
+			"errorRate":      fmt.Sprintf("%.2f%%", float64(failed)/float64(max(requests, 1))*100),
+		}
+		if len(key.Regions) > 0 {
+			entry["regions"] = key.Regions
+		}
+		if len(sorted) > 0 {
+			sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+			entry["latency"] = latencyPercentiles(sorted)
+		}
+		keys[key.Name] = entry
+	}
+	return keys
+}
@@ -12,6 +12,7 @@ import (
 	"os"
 	"os/signal"
 	"runtime"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"syscall"
@@ -25,30 +26,197 @@ type Config struct {
 		SpecificCategory string
 	}
 	APIKey string
+	// Publishable keys rotated across the store requests instead of APIKey,
+	// each optionally with regions; metrics are split per key
+	APIKeys []PublishableKey
 	Test struct {
 		MaxWorkers int
 		MaxQueueSize int
 		RampupStages []Stage
 		ReportingSeconds int
+		// Local IPs or interface names to bind outgoing connections to (round-robin)
+		SourceIPs []string
+
+		// HTTP client backend: "net/http" (the default) or "fasthttp", for
+		// loads where net/http limits the generator
+		Client string
+
+		// Keep requests that hit the client timeout in the latency percentiles
+		IncludeTimeoutsInLatency bool
+
+		// Maximum in-flight requests per operation, e.g. {"products": 10}
+		ConcurrencyLimits map[string]int
+
+		// Burst mode fires BurstConfig.Size requests back-to-back every
+		// BurstConfig.Interval instead of following RampupStages
+		BurstMode   bool
+		BurstConfig struct {
+			Size     int
+			Interval time.Duration
+		}
+
+		// Send Canary.Percent of each operation's requests to Canary.BaseURL
+		// (scheme and host replaced, path kept); metrics are split per variant
+		Canary struct {
+			BaseURL string
+			Percent float64
+		}
+
+		// Client classes add artificial network delay to a share of the
+		// workers to simulate clients in other regions
+		ClientClasses []ClientClass
+
+		// Bandwidth profiles throttle a share of the connections (e.g. 3G clients)
+		BandwidthProfiles []BandwidthProfile
+
+		// Tags per operation, e.g. {"products": ["read", "critical"]}; the
+		// results group requests, errors and latency by tag
+		Tags map[string][]string
+
+		// Per-operation success rules replacing the default 2xx check, e.g.
+		// {"specificProduct": {"Statuses": [200, 404]}}
+		SuccessCriteria map[string]SuccessRule
+
+		// Admin API traffic mixed into the load (needs a Token or Headers)
+		Admin AdminConfig
+
+		// Multipart uploads with synthetic files (operation "upload")
+		Upload UploadConfig
+
+		// Whether 3xx responses are followed, globally and per operation
+		Redirects RedirectConfig
+
+		// Sudden surge on one product on top of the regular load
+		FlashSale FlashSaleConfig
+
+		// Virtual users moving through states (browse, product, cart, ...)
+		// with transition probabilities, instead of independent requests
+		Journey JourneyConfig
+
+		// Search-as-you-type: users typing terms keystroke by keystroke
+		Autocomplete AutocompleteConfig
+
+		// Named pages whose API calls are sent concurrently and reported
+		// together, e.g. a product page's product, related and reviews calls
+		Pages []PageConfig
+
+		// Locale and seed of the fake customer data in entity operations
+		Faker FakerConfig
+
+		// IDs of created entities (carts, checkouts) shared between workers
+		// and the requests operating on them
+		Entities EntityConfig
+
+		// Images and static files found in responses, mixed into the traffic
+		Assets AssetConfig
+
+		// Expected cache TTL per operation; Age and Date headers beyond it
+		// are reported as staleness violations
+		CacheTTLs map[string]CacheExpectation
+
+		// Sampled responses compared with golden responses captured before
+		// the test, ignoring volatile fields
+		Golden GoldenConfig
+
+		// Operations answered with 202 Accepted that are polled until the
+		// started work finishes
+		AsyncPolling AsyncPollConfig
+
+		// Embedded receiver matching platform webhooks to the requests that
+		// triggered them, for end-to-end async latency
+		Webhooks WebhookConfig
+
+		// Sample of all requests logged as NDJSON with a timing breakdown
+		Trace TraceConfig
+
+		// Requests built before the test into a memory-mapped file and
+		// replayed, for RPS the per-request generation cannot keep up with
+		Corpus CorpusConfig
+
+		// Prometheus endpoint of the target, scraped for memory/CPU trends
+		TargetMetrics TargetMetricsConfig
+
+		// URLs polled during the run (health checks, Prometheus queries)
+		// whose values are stored next to the load side's latency
+		ExternalMetrics []ExternalSource
+
+		// Detector run over the per-second series after the test, listing
+		// latency jumps and error bursts with their timestamps
+		Anomalies AnomalyConfig
+
+		// Pass/fail criteria checked at the end; a miss exits with status 99
+		Thresholds ThresholdConfig
+
+		// Latency percentiles reported, default [50, 90, 95, 99]
+		Percentiles []float64
+
+		// Trimmed means and percentile confidence intervals
+		Statistics StatisticsConfig
+
+		// Record every request duration in histograms instead of a sample,
+		// for the overall and per-operation latency
+		RecordAllDurations bool
+
+		// Product and category IDs fetched before the load for the
+		// single-resource requests
+		Discovery DiscoveryConfig
+
+		// Commands or webhooks run before, during (at an offset) or after the
+		// test, e.g. chaos actions; each run is recorded as an annotation
+		Hooks []Hook
+
+		// A/B experiments: each attaches its Headers (e.g. feature-flag cookies)
+		// to Percent of the traffic; metrics are split per experiment
+		Experiments []Experiment
+
+		// CSV of (time offset, target RPS) rows replayed instead of RampupStages,
+		// with every RPS value multiplied by ProfileScale (default 1)
+		ProfileCSV   string
+		ProfileScale float64
+
+		// Named phases run one after another, each with its own load
+		// model (constant, ramp, spike, adaptive), instead of RampupStages
+		Phases []LoadPhase
+
+		// Requests per second per operation, e.g. {"products": 300,
+		// "categories": 20}, each paced on its own for Duration instead of
+		// RampupStages and the even products/categories split
+		EndpointRPS map[string]float64
 		AdaptiveRPS bool
 		AdaptiveConfig struct {
-			InitialRPS               int64
+			InitialRPS               float64
 			ErrorThresholdPercentage float64
 			RPSIncreasePercentage    float64
 			RPSDecreasePercentage    float64
-			MinimumRPS               int64
-			MaximumRPS               int64
+			MinimumRPS               float64
+			MaximumRPS               float64
 			SamplingWindow           time.Duration
 			StabilizationWindow      time.Duration
+
+			// How the RPS is adjusted: "step" (default), "aimd" or "pid"
+			Strategy string
+			AIMD     AIMDConfig
+			PID      PIDConfig
+
+			// End the run early once the RPS has settled (off by default)
+			Convergence ConvergenceConfig
 		}
+
+		// Follow RampupStages, but hold a rising stage while the error rate
+		// is above AdaptiveConfig.ErrorThresholdPercentage
+		AdaptiveStages bool
 		Duration time.Duration
+
+		// Hard limit on the whole run, whatever the stages, phases or
+		// adaptive settings say; the results are still written (0 = off)
+		MaxDuration time.Duration
 	}
 }
 
 
 type Stage struct {
 	Duration time.Duration
-	TargetRPS int64
+	TargetRPS float64
 	Description string
 }
 
@@ -58,16 +226,41 @@ type Metrics struct {
 	TotalRequests int64
 	SuccessfulRequests int64
 	FailedRequests int64
+	TimeoutRequests int64
 	RequestDurations []time.Duration
+	OperationCounts map[string]int64
+	OperationFailures map[string]int64
+	OperationDurations map[string][]time.Duration
 	mutex sync.Mutex
 	recentSuccessfulRequests int64
 	recentFailedRequests int64
 	lastSamplingTime time.Time
+	// Timed-out requests are excluded from RequestDurations unless this is set
+	IncludeTimeoutsInLatency bool
+	// Flash sale surge the catalog's results are split around (nil if off)
+	FlashSale *flashSale
+	// Per-second series the anomaly detector runs on
+	Series *secondSeries
+
+	// Reused and new connections, over the test and per reporting interval
+	Conns *connReuse
+	// Every failed request counted by error signature
+	Errors *errorTally
+	// Every duration, when recorded in full instead of sampled (nil otherwise)
+	Histograms *histogramSet
 }
 
 // Add a result to the metrics
-func (m *Metrics) AddResult(duration time.Duration, success bool) {
+func (m *Metrics) AddResult(duration time.Duration, operation string, success bool, timedOut bool) {
 	atomic.AddInt64(&m.TotalRequests, 1)
+	m.Series.observe(duration, success)
+	m.FlashSale.observe(operation, duration, success)
+	m.mutex.Lock()
+	m.OperationCounts[operation]++
+	if !success {
+		m.OperationFailures[operation]++
+	}
+	m.mutex.Unlock()
 	if success {
 		atomic.AddInt64(&m.SuccessfulRequests, 1)
 		atomic.AddInt64(&m.recentSuccessfulRequests, 1)
@@ -75,13 +268,56 @@ func (m *Metrics) AddResult(duration time.Duration, success bool) {
 		atomic.AddInt64(&m.FailedRequests, 1)
 		atomic.AddInt64(&m.recentFailedRequests, 1)
 	}
+	if timedOut {
+		atomic.AddInt64(&m.TimeoutRequests, 1)
+		// The duration is just the client timeout, not a real latency
+		if !m.IncludeTimeoutsInLatency {
+			return
+		}
+	}
+	m.Histograms.record(operation, duration)
 	if rand.Float64() < 0.01 { // Store only 1% of durations
 		m.mutex.Lock()
 		m.RequestDurations = append(m.RequestDurations, duration)
+		m.OperationDurations[operation] = append(m.OperationDurations[operation], duration)
 		m.mutex.Unlock()
 	}
 }
 
+// OperationStats summarizes requests, failures and latency per operation so the
+// same logical operation can be compared across platforms
+func (m *Metrics) OperationStats() map[string]interface{} {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	
+	stats := make(map[string]interface{}, len(m.OperationCounts))
+	for op, count := range m.OperationCounts {
+		failed := m.OperationFailures[op]
+		opStats := map[string]interface{}{
+			"requests":           count,
+			"successfulRequests": count - failed,
+			"failedRequests":     failed,
+			"errorRate":          fmt.Sprintf("%.2f%%", float64(failed)/float64(max(count, 1))*100),
+		}
+		
+		if latency, ok := m.Histograms.operationLatency(op); ok {
+			opStats["latency"] = latency
+		} else if len(m.OperationDurations[op]) > 0 {
+			durations := make([]time.Duration, len(m.OperationDurations[op]))
+			copy(durations, m.OperationDurations[op])
+			sortDurations(durations)
+			
+			opStats["latency"] = latencyPercentiles(durations)
+			if confidence := percentileConfidence(durations); confidence != nil {
+				opStats["latencyConfidence"] = confidence
+			}
+		}
+		
+		stats[op] = opStats
+	}
+	return stats
+}
+
 // Reset recent counters for adaptive testing
 func (m *Metrics) ResetRecentCounters() {
 	atomic.StoreInt64(&m.recentSuccessfulRequests, 0)
@@ -111,36 +347,36 @@ func (m *Metrics) CalculateStats() map[string]interface{} {
 	actualRPS := float64(m.TotalRequests) / testDuration.Seconds()
 	
 	// Calculate percentiles
-	var p50, p90, p95, p99 time.Duration
+	var durations []time.Duration
 	if len(m.RequestDurations) > 0 {
 		// Sort durations for percentile calculation
-		durations := make([]time.Duration, len(m.RequestDurations))
+		durations = make([]time.Duration, len(m.RequestDurations))
 		copy(durations, m.RequestDurations)
 		
 		// Quick sort implementation with custom comparator
 		// This is much faster than using sort.Slice for large slices
 		sortDurations(durations)
-		
-		p50 = percentileDuration(durations, 0.5)
-		p90 = percentileDuration(durations, 0.9)
-		p95 = percentileDuration(durations, 0.95)
-		p99 = percentileDuration(durations, 0.99)
 	}
 	
-	return map[string]interface{}{
+	stats := map[string]interface{}{
 		"totalRequests":      m.TotalRequests,
 		"successfulRequests": m.SuccessfulRequests,
 		"failedRequests":     m.FailedRequests,
+		"timeoutRequests":    m.TimeoutRequests,
+		"timeoutRate":        fmt.Sprintf("%.2f%%", float64(m.TimeoutRequests)/float64(max(m.TotalRequests, 1))*100),
+		"latencyIncludesTimeouts": m.IncludeTimeoutsInLatency,
 		"testDuration":       testDuration.String(),
 		"actualRPS":          fmt.Sprintf("%.2f", actualRPS),
 		"successRate":        fmt.Sprintf("%.2f%%", float64(m.SuccessfulRequests)/float64(max(m.TotalRequests, 1))*100),
-		"latency": map[string]string{
-			"p50": p50.String(),
-			"p90": p90.String(),
-			"p95": p95.String(),
-			"p99": p99.String(),
-		},
+		"latency": latencyPercentiles(durations),
+	}
+	if latency, ok := m.Histograms.overallLatency(); ok {
+		stats["latency"] = latency
+		stats["durationRecording"] = m.Histograms.report()
+	} else if confidence := percentileConfidence(durations); confidence != nil {
+		stats["latencyConfidence"] = confidence
 	}
+	return stats
 }
 
 // max returns the maximum of two int64 values
@@ -202,22 +438,62 @@ type Task struct {
 	Headers map[string]string
 	Method  string
 	Type    string 
+	Burst   *burst // Set when the task is part of a burst
+	Delay   time.Duration // Artificial client delay (client classes)
+	Upload  bool          // Multipart upload with a synthetic file
+	Body    string        // JSON request body (entity operations)
+	Page    *pageLoad     // Set when the task loads a page of concurrent calls
+	Result  *pageCall     // Filled in when the task is one of a page's calls
 }
 
 // Worker pool for handling concurrent requests
 type WorkerPool struct {
-	Tasks       chan Task
-	Workers     int
-	StopChan    chan struct{}
-	WaitGroup   sync.WaitGroup
-	HTTPClient  *http.Client
-	Metrics     *Metrics
-	CurrentRate *atomic.Int64 // Current RPS target being achieved
+	Tasks      chan Task
+	Workers    int
+	StopChan   chan struct{}
+	WaitGroup  sync.WaitGroup
+	HTTPClient *http.Client
+	Metrics    *Metrics
+	Config     *Config
+	Limiter    *concurrencyLimiter // per-operation in-flight caps (nil if none)
+	Shaper     *bandwidthShaper    // bandwidth-limited connections (nil if none)
+	Tracer     *requestTracer      // request trace log (nil if off)
+	Redirects  *redirectTracker    // redirect policy and counts
+	Uploads    *uploader           // multipart upload bodies (nil if off)
+	Webhooks   *webhookReceiver    // webhook correlation (nil if off)
+	Async      *asyncPoller        // follows 202 responses (nil if off)
+	Entities   *entityStore        // IDs of created entities (nil if off)
+	Assets     *assetStore         // asset URLs found in responses (nil if off)
+	Pages      *pageComposer       // page loads (nil if off)
+	Cache      *cacheChecker       // staleness checks of cached responses (nil if off)
+	Golden     *goldenChecker      // golden response comparisons (nil if off)
 }
 
 // NewWorkerPool creates a new worker pool
-func NewWorkerPool(workers, queueSize int, metrics *Metrics) *WorkerPool {
+func NewWorkerPool(workers, queueSize int, metrics *Metrics, config *Config) *WorkerPool {
+	if err := checkClientBackend(config.Test.Client); err != nil {
+		log.Fatalf("Invalid Client configuration: %v", err)
+	}
+	// Spread connections over the configured source addresses
+	dialer, err := newSourceIPDialer(config.Test.SourceIPs, 30*time.Second, 30*time.Second)
+	if err != nil {
+		log.Fatalf("Invalid SourceIPs configuration: %v", err)
+	}
+	shaper, err := newBandwidthShaper(config.Test.BandwidthProfiles)
+	if err != nil {
+		log.Fatalf("Invalid BandwidthProfiles configuration: %v", err)
+	}
+	redirects, err := newRedirectTracker(config.Test.Redirects)
+	if err != nil {
+		log.Fatalf("Invalid Redirects configuration: %v", err)
+	}
+	uploads, err := newUploader(config.Test.Upload)
+	if err != nil {
+		log.Fatalf("Invalid Upload configuration: %v", err)
+	}
+
 	transport := &http.Transport{
+		DialContext:         shaper.wrapDial(dialer.DialContext),
 		MaxIdleConns:        workers,
 		MaxIdleConnsPerHost: workers,
 		MaxConnsPerHost:     workers,
@@ -226,22 +502,29 @@ func NewWorkerPool(workers, queueSize int, metrics *Metrics) *WorkerPool {
 		DisableKeepAlives:   false,
 		ForceAttemptHTTP2:   true,
 	}
+	var base http.RoundTripper = transport
+	if config.Test.Client == "fasthttp" {
+		base = newFasthttpTransport(transport.DialContext, workers, 15*time.Second, !transport.DisableCompression)
+	}
 	
 	client := &http.Client{
-		Transport: transport,
-		Timeout:   15 * time.Second,
+		Transport:     clientTransport(base, config),
+		CheckRedirect: redirects.checkRedirect,
+		Timeout:       15 * time.Second,
 	}
 	
-	currentRate := &atomic.Int64{}
-	currentRate.Store(0)
-	
 	return &WorkerPool{
-		Tasks:       make(chan Task, queueSize),
-		Workers:     workers,
-		StopChan:    make(chan struct{}),
-		HTTPClient:  client,
-		Metrics:     metrics,
-		CurrentRate: currentRate,
+		Tasks:      make(chan Task, queueSize),
+		Workers:    workers,
+		StopChan:   make(chan struct{}),
+		HTTPClient: client,
+		Metrics:    metrics,
+		Config:     config,
+		Limiter:    newConcurrencyLimiter(config.Test.ConcurrencyLimits),
+		Shaper:     shaper,
+		Redirects:  redirects,
+		Uploads:    uploads,
+		Async:      newAsyncPoller(config.Test.AsyncPolling, client),
 	}
 }
 
@@ -249,7 +532,7 @@ func NewWorkerPool(workers, queueSize int, metrics *Metrics) *WorkerPool {
 func (p *WorkerPool) Start() {
 	for i := 0; i < p.Workers; i++ {
 		p.WaitGroup.Add(1)
-		go p.worker()
+		go p.worker(classForWorker(p.Config.Test.ClientClasses, i, p.Workers))
 	}
 }
 
@@ -260,7 +543,7 @@ func (p *WorkerPool) Stop() {
 }
 
 // worker processes tasks from the queue
-func (p *WorkerPool) worker() {
+func (p *WorkerPool) worker(class *ClientClass) {
 	defer p.WaitGroup.Done()
 	
 	for {
@@ -269,7 +552,16 @@ func (p *WorkerPool) worker() {
 			if !ok {
 				return
 			}
+			class.apply(&task)
+			if task.Page != nil {
+				p.loadPage(class, task.Page)
+				task.Burst.done()
+				continue
+			}
+			p.Limiter.acquire(task.Type)
 			p.executeTask(task)
+			p.Limiter.release(task.Type)
+			task.Burst.done()
 		case <-p.StopChan:
 			return
 		}
@@ -278,9 +570,26 @@ func (p *WorkerPool) worker() {
 
 // executeTask performs the HTTP request
 func (p *WorkerPool) executeTask(task Task) {
-	req, err := http.NewRequest(task.Method, task.URL, nil)
+	var duration time.Duration
+	success := false
+	defer func() { task.Result.set(duration, success) }()
+
+	var body io.Reader
+	contentType := ""
+	if task.Upload {
+		buf, formType, err := p.Uploads.formBody()
+		if err != nil {
+			log.Printf("Failed to build upload body: %v", err)
+			return
+		}
+		body, contentType = buf, formType
+	} else if task.Body != "" {
+		body, contentType = strings.NewReader(task.Body), "application/json"
+	}
+	req, err := http.NewRequest(task.Method, task.URL, body)
 	if err != nil {
-		p.Metrics.AddResult(0, false)
+		p.Metrics.AddResult(0, task.Type, false, false)
+		p.finishRequest(nil, task.Type, task.URL, 0, true, fmt.Sprintf("request creation error: %v", err))
 		return
 	}
 	
@@ -288,26 +597,89 @@ func (p *WorkerPool) executeTask(task Task) {
 	for key, value := range task.Headers {
 		req.Header.Set(key, value)
 	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	setLoadTestID(req.Header)
 	
+	req = withClientDelay(req, task.Delay)
+	req = p.Metrics.Conns.begin(req)
+	req, timing := p.Tracer.begin(req)
+	req, redirect := p.Redirects.begin(req, task.Type)
 	start := time.Now()
 	resp, err := p.HTTPClient.Do(req)
-	duration := time.Since(start)
+	duration = time.Since(start)
+	p.Redirects.finish(redirect, task.Type)
 	
-	success := err == nil && resp != nil && resp.StatusCode >= 200 && resp.StatusCode < 300
+	success = err == nil && resp != nil && statusAccepted(p.Config.Test.SuccessCriteria, task.Type, resp.StatusCode)
 	
+	status, errText := 0, ""
 	if resp != nil {
+    p.Cache.check(task.Type, resp.Header)
+    var respBody []byte
+    compareGolden := success && p.Golden.wants(task.Type)
+    if success && (compareGolden || needsBody(p.Config.Test.SuccessCriteria, task.Type) || p.Webhooks.wants(task.Type) || p.Entities.wants(task.Type) || p.Assets.wants(task.Type) || p.Async.needsBody(task.Type, resp.StatusCode)) {
+        // The success criteria, golden snapshot, webhook receiver, entity or
+        // asset store or poller inspect the body
+        respBody, _ = io.ReadAll(resp.Body)
+        if errText = checkBody(p.Config.Test.SuccessCriteria, task.Type, resp.StatusCode, respBody); errText != "" {
+            success = false
+        } else {
+            p.Webhooks.track(task.Type, start, start.Add(duration), respBody)
+            p.Entities.capture(task.Type, respBody)
+            p.Assets.capture(task.Type, resp.Request.URL, respBody)
+            if compareGolden {
+                p.Golden.compare(task.Type, respBody)
+            }
+        }
+    }
+    if success {
+        p.Async.accepted(task.Type, resp, start, task.Headers, respBody)
+    }
     // Always read the body fully before closing
-    io.Copy(io.Discard, resp.Body)
+    n, _ := io.Copy(io.Discard, resp.Body)
     resp.Body.Close()
+    timing.bodyRead(resp.Header)
+    if baseOperation(task.Type) == assetOperation {
+        p.Assets.record(n, time.Since(start), success)
+    }
+    status = resp.StatusCode
 }
+	if err != nil {
+		errText = err.Error()
+	}
 	
-	p.Metrics.AddResult(duration, success)
+	p.Metrics.AddResult(duration, task.Type, success, isTimeoutError(err))
+	p.finishRequest(timing, task.Type, task.URL, status, !success, errText)
 }
 type LoadGenerator struct {
 	Pool      *WorkerPool
 	Config    *Config
 	StopChan  chan struct{}
+	Done      chan struct{} // closed when load generation finishes on its own
+	Guard     *resourceGuard // pauses generation near the generator's resource limits
+	Blackout  *blackoutPause // pauses generation during maintenance windows (nil if none)
+	bursts    *burstTracker  // burst completion latencies in burst mode
+	Canary    *canaryRouter  // sends a share of traffic to a canary (nil if none)
+	Experiments *experimentPicker // A/B header sets (nil if none)
+	Admin       *adminWorkload    // admin API operations (nil if none)
+	Journeys    *journeys         // virtual user journeys (nil if off)
+	Autocomplete *autocomplete    // keystroke searches (nil if off)
+	Keys         *keyRotation     // publishable keys rotated across requests (nil if one)
+	Discovered   *discoveredIDs   // IDs of the single-resource requests (nil if off)
+	Hold         *stageHold       // holds rising stages on errors (nil if off)
+	Adaptive     *adaptiveController // adjusts the RPS (nil unless AdaptiveRPS)
+	Corpus       *requestCorpus      // pre-built requests replayed (nil if off)
+	Endpoints    *endpointRates      // per-operation rates (nil unless EndpointRPS)
+	Phases       *phasePlan          // load model per phase (nil unless Phases)
+	Tuning       generatorTuning     // generator goroutines and tick resolution
+	rate         atomic.Uint64       // target RPS as float64 bits, paced by the generators
 	WaitGroup sync.WaitGroup
+
+	// Progress tracking for periodic reports
+	testStart  time.Time
+	stageIndex atomic.Int64
+	stageStart atomic.Int64 // offset from testStart on the monotonic clock
 }
 
 func NewLoadGenerator(pool *WorkerPool, config *Config) *LoadGenerator {
@@ -315,6 +687,8 @@ func NewLoadGenerator(pool *WorkerPool, config *Config) *LoadGenerator {
 		Pool:     pool,
 		Config:   config,
 		StopChan: make(chan struct{}),
+		Done:     make(chan struct{}),
+		bursts:   &burstTracker{},
 	}
 }
 
@@ -329,33 +703,46 @@ func (g *LoadGenerator) Stop() {
 	g.WaitGroup.Wait()
 }
 
-// generateLoad produces tasks at the configured rate
+// generateLoad follows the stages or the adaptive controller and publishes
+// the target rate the generators pace to
 func (g *LoadGenerator) generateLoad() {
 	defer g.WaitGroup.Done()
+	defer close(g.Done)
 	
 	stageStart := time.Now()
 	testStart := time.Now()
 	currentStage := 0
+	g.testStart = testStart
+	g.stageStart.Store(int64(stageStart.Sub(testStart)))
 	
-	ticker := time.NewTicker(1 * time.Millisecond)
+	ticker := time.NewTicker(g.Tuning.Tick)
 	defer ticker.Stop()
 	
 	// Initialize variables for rate limiting
-	var currentTargetRPS int64
+	var currentTargetRPS float64
 	
-	if g.Config.Test.AdaptiveRPS {
+	if g.Phases != nil {
+		// Phases run one after another, each with its own load model
+		log.Printf("Starting phased testing with %d phases", len(g.Config.Test.Phases))
+		currentTargetRPS = g.Phases.Start(testStart)
+	} else if g.Endpoints != nil {
+		// Each operation is paced at its own fixed rate
+		currentTargetRPS = g.Endpoints.total()
+		log.Printf("Starting per-endpoint testing at %s RPS in total", formatRPS(currentTargetRPS))
+	} else if g.Config.Test.AdaptiveRPS {
 		// For adaptive testing, start with the initial RPS
 		currentTargetRPS = g.Config.Test.AdaptiveConfig.InitialRPS
-		log.Printf("Starting adaptive testing with initial RPS: %d", currentTargetRPS)
+		g.Adaptive.Start(testStart)
+		log.Printf("Starting adaptive testing with initial RPS: %s", formatRPS(currentTargetRPS))
 		log.Printf("Error threshold: %.2f%%", g.Config.Test.AdaptiveConfig.ErrorThresholdPercentage)
 	} else if len(g.Config.Test.RampupStages) > 0 {
 		// For staged testing, start with first stage
 		currentTargetRPS = g.Config.Test.RampupStages[0].TargetRPS
-		log.Printf("Starting staged testing with initial RPS: %d", currentTargetRPS)
+		log.Printf("Starting staged testing with initial RPS: %s", formatRPS(currentTargetRPS))
 	}
 	
 	startRPS := currentTargetRPS
-	g.Pool.CurrentRate.Store(currentTargetRPS)
+	g.publishRate(currentTargetRPS)
 	
 	// Initialize metrics for adaptive testing
 	g.Pool.Metrics.ResetRecentCounters()
@@ -370,7 +757,11 @@ func (g *LoadGenerator) generateLoad() {
 			select {
 			case <-reportTicker.C:
 				stats := g.Pool.Metrics.CalculateStats()
-				stats["targetRPS"] = currentTargetRPS
+				stats["targetRPS"] = roundRPS(g.targetRate())
+				stats["progress"] = g.progress()
+				if connections := g.Pool.Metrics.Conns.current(); connections != nil {
+					stats["connections"] = connections
+				}
 				statsJSON, _ := json.MarshalIndent(stats, "", "  ")
 				fmt.Println(string(statsJSON))
 			case <-g.StopChan:
@@ -379,10 +770,17 @@ func (g *LoadGenerator) generateLoad() {
 		}
 	}()
 	
-	// Variables for tracking requests per second
-	secondStart := time.Now()
-	requestsThisSecond := int64(0)
-	
+	// Burst mode replaces the rate-based loop below
+	if g.Config.Test.BurstMode {
+		g.generateBursts()
+		return
+	}
+	if g.Endpoints != nil {
+		g.startEndpointPacers()
+	} else {
+		g.startGenerators()
+	}
+
 	for {
 		select {
 		case <-g.StopChan:
@@ -403,53 +801,44 @@ func (g *LoadGenerator) generateLoad() {
 					recentErrorRate := g.Pool.Metrics.GetRecentErrorRate()
 					
 					// Only adjust RPS after stabilization window
-					if now.Sub(lastAdaptiveChange) >= g.Config.Test.AdaptiveConfig.StabilizationWindow {
-						previousRPS := currentTargetRPS
-						
-						// Adjust RPS based on error rate
-						if recentErrorRate > g.Config.Test.AdaptiveConfig.ErrorThresholdPercentage {
-							// Too many errors, decrease RPS
-							decreaseAmount := float64(currentTargetRPS) * (g.Config.Test.AdaptiveConfig.RPSDecreasePercentage / 100.0)
-							currentTargetRPS = currentTargetRPS - int64(decreaseAmount)
-							
-							// Ensure we don't go below minimum
-							if currentTargetRPS < g.Config.Test.AdaptiveConfig.MinimumRPS {
-								currentTargetRPS = g.Config.Test.AdaptiveConfig.MinimumRPS
-							}
-							
-							fmt.Printf("Error rate %.2f%% exceeds threshold. Decreasing RPS from %d to %d\n", 
-								recentErrorRate, previousRPS, currentTargetRPS)
-						} else {
-							// Error rate is acceptable, increase RPS
-							increaseAmount := float64(currentTargetRPS) * (g.Config.Test.AdaptiveConfig.RPSIncreasePercentage / 100.0)
-							currentTargetRPS = currentTargetRPS + int64(increaseAmount)
-							
-							// Ensure we don't exceed maximum
-							if currentTargetRPS > g.Config.Test.AdaptiveConfig.MaximumRPS {
-								currentTargetRPS = g.Config.Test.AdaptiveConfig.MaximumRPS
-							}
-							
-							fmt.Printf("Error rate %.2f%% below threshold. Increasing RPS from %d to %d\n", 
-								recentErrorRate, previousRPS, currentTargetRPS)
-						}
-						
-						g.Pool.CurrentRate.Store(currentTargetRPS)
+					if now.Sub(lastAdaptiveChange) >= g.Config.Test.AdaptiveConfig.StabilizationWindow && !g.Blackout.Paused() {
+						window := g.Config.Test.AdaptiveConfig.SamplingWindow
+						currentTargetRPS = g.Adaptive.next(now, currentTargetRPS, recentErrorRate, g.Pool.Metrics.Series.recentP95(window))
+						g.publishRate(currentTargetRPS)
 						lastAdaptiveChange = now
+						if g.Adaptive.Converged() {
+							return
+						}
 					}
 					
 					// Reset counters for next sampling window
 					g.Pool.Metrics.ResetRecentCounters()
 				}
-			} else {
+			} else if g.Phases != nil {
+				rps, done := g.Phases.advance(now, currentTargetRPS, g.Pool.Metrics.Series)
+				if done {
+					fmt.Println("Load test completed all phases.")
+					return
+				}
+				currentTargetRPS = rps
+				g.publishRate(currentTargetRPS)
+			} else if g.Endpoints == nil {
 				// Original staged testing logic
 				if currentStage < len(g.Config.Test.RampupStages) {
 					stage := g.Config.Test.RampupStages[currentStage]
+					// A held stage's clock stands still
+					if paused := g.Hold.pause(now, currentStage, stage, currentTargetRPS, stage.TargetRPS > startRPS, g.Pool.Metrics); paused > 0 {
+						stageStart = stageStart.Add(paused)
+						g.stageStart.Store(int64(stageStart.Sub(testStart)))
+					}
 					elapsed := now.Sub(stageStart)
 					
 					if elapsed >= stage.Duration {
 						// Move to next stage
 						stageStart = now
 						currentStage++
+						g.stageIndex.Store(int64(currentStage))
+						g.stageStart.Store(int64(now.Sub(testStart)))
 						if currentStage < len(g.Config.Test.RampupStages) {
 							startRPS = currentTargetRPS
 							fmt.Printf("Moving to stage %d: %s\n", currentStage+1, g.Config.Test.RampupStages[currentStage].Description)
@@ -465,37 +854,34 @@ func (g *LoadGenerator) generateLoad() {
 						progress := float64(elapsed) / float64(stage.Duration)
 						
 						// Linear interpolation between start RPS and target RPS
-						currentTargetRPS = startRPS + int64(float64(stage.TargetRPS-startRPS)*progress)
-						g.Pool.CurrentRate.Store(currentTargetRPS)
+						currentTargetRPS = startRPS + (stage.TargetRPS-startRPS)*progress
+						g.publishRate(currentTargetRPS)
 					}
 				}
 			}
-			
-			// Check if we've started a new second
-			if now.Sub(secondStart) >= time.Second {
-				secondStart = now
-				requestsThisSecond = 0
-			}
-			
-			// Ensure we don't exceed our target RPS
-			if requestsThisSecond < currentTargetRPS {
-				// Generate a task
-				task := g.generateTask()
-				
-				// Try to send the task, but don't block if queue is full
-				select {
-				case g.Pool.Tasks <- task:
-					requestsThisSecond++
-				default:
-					// Queue is full, skip this task
-				}
-			}
 		}
 	}
 }
 
-// generateTask creates a new HTTP request task
+// generateTask creates a new HTTP request task, sent with the next
+// publishable key when keys are rotated
 func (g *LoadGenerator) generateTask() Task {
+	task := g.selectTask()
+	g.Keys.apply(&task)
+	return task
+}
+
+// storeHeaders returns the headers of a Store API request
+func (g *LoadGenerator) storeHeaders() map[string]string {
+	return map[string]string{
+		"x-publishable-api-key": g.Config.APIKey,
+		"Accept":                "application/json",
+		"Content-Type":          "application/json",
+	}
+}
+
+// selectTask picks the operation of the next task
+func (g *LoadGenerator) selectTask() Task {
 	// Distribute traffic across endpoints
 	var url, taskType string
 	switch rand.Intn(2) {
@@ -507,10 +893,42 @@ func (g *LoadGenerator) generateTask() Task {
 		taskType = "categories"
 	}
 	
-	headers := map[string]string{
-		"x-publishable-api-key": g.Config.APIKey,
-		"Accept":                "application/json",
-		"Content-Type":          "application/json",
+	headers := g.storeHeaders()
+	if g.Pool.Uploads.pick() {
+		return Task{
+			URL:     g.Config.Test.Upload.URL,
+			Headers: withHeaders(headers, g.Config.Test.Upload.Headers),
+			Method:  "POST",
+			Type:    uploadOperation,
+			Upload:  true,
+		}
+	}
+	if g.Admin.pick() {
+		op := g.Admin.next()
+		return Task{URL: op.URL, Headers: withHeaders(headers, g.Admin.headers), Method: "GET", Type: op.Name}
+	}
+	if op, ok := g.Pool.Entities.pick(); ok {
+		return Task{URL: op.URL, Headers: withHeaders(headers, op.Headers), Method: op.Method, Type: op.Name, Body: op.Body}
+	}
+	if asset, ok := g.Pool.Assets.pick(); ok {
+		return Task{URL: asset, Headers: g.Config.Test.Assets.Headers, Method: "GET", Type: assetOperation}
+	}
+	if page, ok := g.Pool.Pages.pick(); ok {
+		if task, ok := g.pageTask(page, headers); ok {
+			return task
+		}
+	}
+	if g.Autocomplete.pick() {
+		return Task{URL: g.Autocomplete.url(g.Autocomplete.next()), Headers: headers, Method: "GET", Type: autocompleteOperation}
+	}
+	if user, state, ok := g.Journeys.advance(); ok {
+		if task, ok := g.journeyTask(g.Journeys.operation(state), headers); ok {
+			return task
+		}
+		g.Journeys.block(user, state)
+	}
+	if task, ok := g.Discovered.pick(headers); ok {
+		return task
 	}
 	
 	return Task{
@@ -524,10 +942,36 @@ func (g *LoadGenerator) generateTask() Task {
 func main() {
 	// Parse command line arguments
 	configPath := flag.String("config", "config.json", "Path to the configuration file")
+	outDir := flag.String("out-dir", ".", "Directory to write the results file to")
+	outName := flag.String("out-name", defaultResultsName, "Results file name template ({platform}, {timestamp}, {date})")
+	skipPrecheck := flag.Bool("skip-precheck", false, "Start the test without probing the target first")
+	maxCPU := flag.Float64("max-cpu", 0, "Pause load generation while the generator uses more than this percent of its CPU limit (0 = off)")
+	maxMem := flag.Float64("max-mem", 0, "Pause load generation while the generator uses more than this percent of its memory limit (0 = off)")
+	gomaxprocsFlag := flag.Int("gomaxprocs", 0, "GOMAXPROCS (0 = the container CPU quota, or the host's CPUs)")
+	generators := flag.Int("generators", 1, "Goroutines pacing and queueing tasks, each sending its share of the rate")
+	tick := flag.Duration("tick", 10*time.Millisecond, "Interval of the rate updates, and the longest a generator sleeps before it picks up a new rate")
+	preset := flag.String("preset", "", "Built-in scenario layered on the config (flash-sale)")
+	printJSON := flag.Bool("print-json", false, "Print the full results JSON to the console, not just the summary")
+	recordAll := flag.Bool("record-all-durations", false, "Record every request duration in histograms instead of a sample (overrides the config)")
+	calibrationPath := flag.String("calibration", defaultCalibrationFile, "Calibration stamp of wsm calibrate embedded in the results, if present")
+	sloDir := flag.String("slo-dir", defaultSLODir, "Directory of the per-platform SLO files the results are checked against (empty = off)")
+	uiListen := flag.String("ui-listen", "", "Serve a page with live charts of the test on this address, e.g. 127.0.0.1:8089 (empty = off)")
+	collector := flag.String("collector", "", "Stream the per-second results to a wsm collector at this URL, e.g. http://collector:9300 (empty = off)")
+	checksum := flag.Bool("checksum", false, "Embed the config used (secrets redacted) and a SHA-256 of the canonicalized results")
+	maxDuration := flag.Duration("max-duration", 0, "Stop the run after this long whatever the config says, still writing the results (0 = Test.MaxDuration)")
+	targetPolicyPath := flag.String("target-policy", defaultTargetPolicyFile, "Policy keeping high-RPS plans off production hosts, used if present (empty = off)")
+	blackoutFile := flag.String("blackout", defaultBlackoutFile, "Blackout calendar of maintenance windows to pause the load in, used if present (empty = none)")
+	productionOverride := flag.Bool("i-know-this-is-production", false, "Run a plan above the target policy's MaxRPS against production hosts anyway")
+	cloudMetadata := flag.Bool("cloud-metadata", true, "Look up the generator's instance type and region from the AWS, GCP or Azure metadata service")
 	flag.Parse()
 	
-	// Set GOMAXPROCS to use all available CPU cores
-	runtime.GOMAXPROCS(runtime.NumCPU())
+	// Size GOMAXPROCS to the container CPU quota rather than the host's core count
+	limits := detectContainerLimits()
+	tuning := generatorTuning{Generators: *generators, Tick: *tick}
+	if err := tuning.validate(); err != nil {
+		log.Fatalf("Invalid generator tuning: %v", err)
+	}
+	gomaxprocs, gomaxprocsFrom := configureGOMAXPROCS(limits, *gomaxprocsFlag)
 	
 	// Load configuration
 	configFile, err := os.Open(*configPath)
@@ -545,56 +989,557 @@ func main() {
 	if err := json.NewDecoder(configFile).Decode(&config); err != nil {
 		log.Fatalf("Failed to parse config file: %v", err)
 	}
+	if err := resolveCredentials(&config, *configPath); err != nil {
+		log.Fatalf("Invalid credentials configuration: %v", err)
+	}
+	if config.APIKey == apiKeyPlaceholder {
+		log.Fatalf("Set APIKey in %s to a publishable API key of the store", *configPath)
+	}
+	if config.APIKey == "" && len(config.APIKeys) > 0 {
+		// The pre-check, golden snapshot and flash sale use a single key
+		config.APIKey = config.APIKeys[0].Key
+	}
+	if err := applyRPSProfile(&config, *configPath); err != nil {
+		log.Fatalf("Failed to load RPS profile: %v", err)
+	}
+	if err := applyPreset(&config, *preset); err != nil {
+		log.Fatalf("Invalid preset: %v", err)
+	}
+	registerSecrets(&config)
+	id, err := newRunID()
+	if err != nil {
+		log.Fatalf("Generating the run ID failed: %v", err)
+	}
+	runID = id
+	fmt.Printf("Run ID: %s (sent as %s)\n", runID, loadTestIDHeader)
+	targetPolicy, err := loadTargetPolicy(*targetPolicyPath)
+	if err != nil {
+		log.Fatalf("Invalid target policy: %v", err)
+	}
+	peakRPS := plannedPeakRPS(&config) + float64(config.Test.FlashSale.ExtraRPS)
+	policyReport, err := checkTargetPolicy(targetPolicy, targetHosts(&config), peakRPS, *productionOverride)
+	if err != nil {
+		log.Fatalf("Refusing to run: %v", err)
+	}
+	calendar, err := loadBlackoutCalendar(*blackoutFile)
+	if err != nil {
+		log.Fatalf("Invalid blackout calendar: %v", err)
+	}
+	fileLimit, err := checkFileLimit(&config)
+	if err != nil {
+		log.Fatalf("Open file limit too low: %v", err)
+	}
+	if err := setPercentiles(config.Test.Percentiles, config.Test.Statistics); err != nil {
+		log.Fatalf("Invalid Percentiles: %v", err)
+	}
 	
 	// Initialize metrics
 	metrics := &Metrics{
 		StartTime: time.Now(),
 		lastSamplingTime: time.Now(),
+		Errors: newErrorTally(),
+		IncludeTimeoutsInLatency: config.Test.IncludeTimeoutsInLatency,
+		OperationCounts: make(map[string]int64),
+		OperationFailures: make(map[string]int64),
+		OperationDurations: make(map[string][]time.Duration),
 	}
 	
 	// Set up worker pool
-	pool := NewWorkerPool(config.Test.MaxWorkers, config.Test.MaxQueueSize, metrics)
+	pool := NewWorkerPool(config.Test.MaxWorkers, config.Test.MaxQueueSize, metrics, &config)
 	
+	tracer, err := newRequestTracer(config.Test.Trace, resultsOutput{Dir: *outDir}, "medusa", time.Now())
+	if err != nil {
+		log.Fatalf("Invalid trace configuration: %v", err)
+	}
+	pool.Tracer = tracer
+	webhooks, err := newWebhookReceiver(config.Test.Webhooks)
+	if err != nil {
+		log.Fatalf("Invalid webhook configuration: %v", err)
+	}
+	pool.Webhooks = webhooks
+	fakeData, err := newFaker(config.Test.Faker)
+	if err != nil {
+		log.Fatalf("Invalid faker configuration: %v", err)
+	}
+	entities, err := newEntityStore(config.Test.Entities, config.Endpoints.Products, fakeData)
+	if err != nil {
+		log.Fatalf("Invalid entity configuration: %v", err)
+	}
+	pool.Entities = entities
+	assets, err := newAssetStore(config.Test.Assets, []string{"products", "categories"})
+	if err != nil {
+		log.Fatalf("Invalid asset configuration: %v", err)
+	}
+	pool.Assets = assets
+	cache, err := newCacheChecker(config.Test.CacheTTLs)
+	if err != nil {
+		log.Fatalf("Invalid cache TTL configuration: %v", err)
+	}
+	pool.Cache = cache
+	golden, err := newGoldenChecker(config.Test.Golden, goldenFetchers(&config))
+	if err != nil {
+		log.Fatalf("Golden snapshot failed: %v", err)
+	}
+	pool.Golden = golden
+	external, err := newExternalPoller(config.Test.ExternalMetrics, metrics)
+	if err != nil {
+		log.Fatalf("Invalid external metrics configuration: %v", err)
+	}
+	if golden != nil {
+		fmt.Printf("Comparing %.1f%% of responses with %d golden responses\n", config.Test.Golden.SampleRate*100, len(golden.golden))
+	}
+	if assets != nil {
+		fmt.Printf("Sending %.1f%% of requests to assets found in responses\n", config.Test.Assets.Percent)
+	}
+	if webhooks != nil {
+		fmt.Printf("Receiving webhooks on %s%s\n", config.Test.Webhooks.Listen, webhooks.config.Path)
+	}
+
+	flashSale, err := newFlashSale(config.Test.FlashSale, Task{
+		URL: config.Test.FlashSale.URL,
+		Headers: map[string]string{
+			"x-publishable-api-key": config.APIKey,
+			"Accept":                "application/json",
+		},
+		Method: "GET",
+	})
+	if err != nil {
+		log.Fatalf("Invalid flash sale configuration: %v", err)
+	}
+	metrics.FlashSale = flashSale
+	metrics.Series = newSecondSeries(config.Test.Anomalies)
+	metrics.Conns = newConnReuse()
+	metrics.Histograms = newHistogramSet(config.Test.RecordAllDurations || *recordAll)
+	if flashSale != nil {
+		fmt.Printf("Flash sale: +%d RPS on %s from %s for %s\n", config.Test.FlashSale.ExtraRPS, config.Test.FlashSale.URL, config.Test.FlashSale.Start, config.Test.FlashSale.Duration)
+	}
+
 	// Set up load generator
 	generator := NewLoadGenerator(pool, &config)
+	generator.Tuning = tuning
+	guard := newResourceGuard(limits, gomaxprocs, *maxCPU, *maxMem)
+	guard.fileLimit = fileLimit
+	guard.gomaxprocsFrom = gomaxprocsFrom
+	guard.tuning = tuning.report()
+	generator.Guard = guard
+	canary, err := newCanaryRouter(config.Test.Canary.BaseURL, config.Test.Canary.Percent)
+	if err != nil {
+		log.Fatalf("Invalid canary configuration: %v", err)
+	}
+	generator.Canary = canary
+	if canary != nil {
+		fmt.Printf("Sending %.1f%% of requests to canary %s\n", config.Test.Canary.Percent, config.Test.Canary.BaseURL)
+	}
+	if err := validateClientClasses(config.Test.ClientClasses); err != nil {
+		log.Fatalf("Invalid client class configuration: %v", err)
+	}
+	for _, c := range config.Test.ClientClasses {
+		fmt.Printf("Client class %s: %.1f%% of workers with +%s (±%s) delay\n", c.Name, c.Percent, c.Delay, c.Jitter)
+	}
+	experiments, err := newExperimentPicker(config.Test.Experiments)
+	if err != nil {
+		log.Fatalf("Invalid experiment configuration: %v", err)
+	}
+	generator.Experiments = experiments
+	admin, err := newAdminWorkload(config.Test.Admin, config.Endpoints.Products)
+	if err != nil {
+		log.Fatalf("Invalid admin configuration: %v", err)
+	}
+	generator.Admin = admin
+	if admin != nil {
+		fmt.Printf("Sending %.1f%% of requests to the admin API\n", config.Test.Admin.Percent)
+	}
+	journey, err := newJourneys(config.Test.Journey, generator.journeyOperation, *configPath)
+	if err != nil {
+		log.Fatalf("Invalid journey configuration: %v", err)
+	}
+	generator.Journeys = journey
+	if journey != nil {
+		fmt.Printf("Simulating %d virtual users starting at %s\n", len(journey.users), config.Test.Journey.Start)
+	}
+	typing, err := newAutocomplete(config.Test.Autocomplete, config.Endpoints.Products)
+	if err != nil {
+		log.Fatalf("Invalid autocomplete configuration: %v", err)
+	}
+	generator.Autocomplete = typing
+	keys, err := newKeyRotation(config.APIKeys)
+	if err != nil {
+		log.Fatalf("Invalid APIKeys configuration: %v", err)
+	}
+	generator.Keys = keys
+	if keys != nil {
+		fmt.Printf("Rotating %d publishable keys across store requests\n", len(keys.keys))
+	}
+	hold, err := newStageHold(&config)
+	if err != nil {
+		log.Fatalf("Invalid AdaptiveStages configuration: %v", err)
+	}
+	generator.Hold = hold
+	if hold != nil {
+		fmt.Printf("Holding rising stages while the error rate exceeds %.2f%%\n", config.Test.AdaptiveConfig.ErrorThresholdPercentage)
+	}
+	adaptive, err := newAdaptiveController(&config)
+	if err != nil {
+		log.Fatalf("Invalid adaptive configuration: %v", err)
+	}
+	generator.Adaptive = adaptive
+	if adaptive != nil {
+		fmt.Printf("Adaptive strategy: %s\n", adaptive.name)
+	}
+	phases, err := newPhasePlan(&config, adaptive)
+	if err != nil {
+		log.Fatalf("Invalid Phases configuration: %v", err)
+	}
+	generator.Phases = phases
+	pages, err := newPageComposer(config.Test.Pages, generator.journeyOperation)
+	if err != nil {
+		log.Fatalf("Invalid page configuration: %v", err)
+	}
+	pool.Pages = pages
+	for _, page := range config.Test.Pages {
+		fmt.Printf("Loading page %s for %.1f%% of requests: %s\n", page.Name, page.Percent, strings.Join(page.Operations, ", "))
+	}
+	if typing != nil {
+		fmt.Printf("Sending %.1f%% of requests as autocomplete keystrokes from %d typing users\n", config.Test.Autocomplete.Percent, len(typing.users))
+	}
+	hooks, err := newHookRunner("medusa", config.Test.Hooks)
+	if err != nil {
+		log.Fatalf("Invalid hook configuration: %v", err)
+	}
+	for _, e := range config.Test.Experiments {
+		fmt.Printf("Experiment %s: %.1f%% of requests with headers %v\n", e.Name, e.Percent, e.Headers)
+	}
 	
 	// Handle OS signals for graceful shutdown
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 	
 	// Start load test
-	if config.Test.AdaptiveRPS {
+	if phases != nil {
+		fmt.Println("Starting phased load testing...")
+		fmt.Printf("Using phased load testing with %d phases\n", len(config.Test.Phases))
+	} else if len(config.Test.EndpointRPS) > 0 {
+		fmt.Println("Starting per-endpoint load testing...")
+		fmt.Printf("Using per-endpoint rates: %s\n", describeEndpointRPS(config.Test.EndpointRPS))
+	} else if config.Test.AdaptiveRPS {
 		fmt.Println("Starting adaptive load testing...")
-		fmt.Printf("Using adaptive load testing with initial RPS: %d, error threshold: %.2f%%\n", 
-			config.Test.AdaptiveConfig.InitialRPS, 
+		fmt.Printf("Using adaptive load testing with initial RPS: %s, error threshold: %.2f%%\n", 
+			formatRPS(config.Test.AdaptiveConfig.InitialRPS), 
 			config.Test.AdaptiveConfig.ErrorThresholdPercentage)
 	} else {
 		fmt.Println("Starting staged load testing...")
 		fmt.Printf("Using staged load testing with %d stages\n", len(config.Test.RampupStages))
 	}
+	printTestPlan(&config)
+	limit, err := newRunLimit(&config, *maxDuration)
+	if err != nil {
+		log.Fatalf("Invalid MaxDuration: %v", err)
+	}
+	blackout := newBlackoutPause(calendar, plannedDuration(&config))
+	generator.Blackout = blackout
+	calibration, err := loadCalibration(*calibrationPath)
+	if err != nil {
+		log.Fatalf("Invalid calibration stamp: %v", err)
+	}
+	slo, err := loadSLO(*sloDir, "medusa")
+	if err != nil {
+		log.Fatalf("Invalid SLO file: %v", err)
+	}
+	if slo != nil {
+		fmt.Printf("Checking the results against SLO %s\n", slo.describe())
+	}
+	checkCalibration(calibration, &config)
+	var embedded map[string]interface{}
+	if *checksum {
+		if embedded, err = embeddedConfig(&config); err != nil {
+			log.Fatalf("Embedding the config failed: %v", err)
+		}
+	}
+	if len(config.Test.SourceIPs) > 0 {
+		fmt.Printf("Binding outgoing connections to source addresses: %v\n", config.Test.SourceIPs)
+	}
+	generatorHost := describeGeneratorHost(*cloudMetadata)
+	fmt.Printf("Generator host: %s\n", summarizeGeneratorHost(generatorHost))
+
+	// Make sure the target is up and record what is running there
+	var environment map[string]interface{}
+	if !*skipPrecheck {
+		environment, err = fingerprintTarget(&config)
+		if err != nil {
+			log.Fatalf("Target pre-check failed: %v (use -skip-precheck to run anyway)", err)
+		}
+		metrics.StartTime = time.Now() // don't count the pre-check in the test duration
+		fmt.Printf("Target pre-check passed: server headers %v\n", environment["headers"])
+	}
+	discovered, err := discoverIDs(&config)
+	if err != nil {
+		log.Fatalf("ID discovery failed: %v", err)
+	}
+	generator.Discovered = discovered
+	if discovered != nil {
+		fmt.Printf("Discovered %d products and %d categories for %.1f%% of requests\n", len(discovered.products), len(discovered.categories), config.Test.Discovery.Percent)
+	}
+	corpus, err := loadRequestCorpus(&config, generator)
+	if err != nil {
+		log.Fatalf("Invalid Corpus configuration: %v", err)
+	}
+	generator.Corpus = corpus
+	if corpus != nil {
+		fmt.Printf("Replaying %d pre-built requests from %s\n", corpus.count, corpus.path)
+	}
+	endpoints, err := newEndpointRates(generator)
+	if err != nil {
+		log.Fatalf("Invalid EndpointRPS configuration: %v", err)
+	}
+	generator.Endpoints = endpoints
+	var liveConfig map[string]interface{}
+	if *uiListen != "" {
+		if liveConfig, err = embeddedConfig(&config); err != nil {
+			log.Fatalf("Embedding the config failed: %v", err)
+		}
+	}
+	ui, err := newLiveUI(*uiListen, "medusa", liveConfig, metrics.Series, func() map[string]interface{} {
+		status := generator.progress()
+		status["targetRPS"] = roundRPS(generator.targetRate())
+		return status
+	}, generator.targetRate)
+	if err != nil {
+		log.Fatalf("Invalid UI configuration: %v", err)
+	}
+	if ui != nil {
+		fmt.Printf("Live results on %s\n", ui.URL())
+	}
+	streamer, err := newMetricsStreamer(*collector, "medusa", metrics.Series, generator.progress, generator.targetRate)
+	if err != nil {
+		log.Fatalf("Invalid collector: %v", err)
+	}
 	
+	fmt.Printf("GOMAXPROCS: %d from %s (host CPUs: %d, CPU quota: %.2f)\n", gomaxprocs, gomaxprocsFrom, runtime.NumCPU(), limits.CPUQuota)
+	fmt.Printf("Load generators: %d, rate updates every %s\n", tuning.Generators, tuning.Tick)
+	hooks.runPhase("pre")
+	metrics.StartTime = time.Now() // pre hooks are not part of the test
+	metrics.Series.Start(metrics.StartTime)
+	ui.Start()
+	streamer.Start(metrics.StartTime)
+	clock := newClockWatch()
+	clock.Start(metrics.StartTime)
+	metrics.Conns.Start(metrics.StartTime, time.Duration(config.Test.ReportingSeconds)*time.Second)
+
+	guard.Start()
+	blackout.Start()
 	pool.Start()
 	generator.Start()
+	metrics.FlashSale.Start(pool.Tasks)
+	hooks.startDuring(time.Now())
+	scraper := newTargetScraper(config.Test.TargetMetrics, metrics)
+	scraper.Start(time.Now())
+	external.Start(time.Now())
+	limit.Start()
 	
 	// Wait for completion or interrupt
 	select {
 	case <-sigChan:
 		fmt.Println("\nReceived interrupt signal, shutting down...")
+	case <-generator.Done:
+		fmt.Println("\nLoad generation finished, shutting down...")
+	case <-limit.C():
+		limit.Reached()
 	}
+	limit.Stop()
 	
 	// Graceful shutdown
 	generator.Stop()
+	ui.Finish()
+	metrics.FlashSale.Stop()
 	close(pool.Tasks)
 	pool.Stop()
+	if pool.Async != nil {
+		fmt.Println("Waiting for async operations to finish...")
+	}
+	pool.Async.Stop()
+	if err := entities.save(); err != nil {
+		log.Printf("Failed to save entity IDs: %v", err)
+	}
+	tracer.Close()
+	webhooks.Stop()
+	ui.Stop()
+	streamer.Stop()
+	guard.Stop()
+	blackout.Stop()
+	clock.Stop()
+	metrics.Conns.Stop()
+	hooks.stopDuring()
+	scraper.Stop()
+	external.Stop()
+	hooks.runPhase("post")
 	
 	// Final report
 	metrics.EndTime = time.Now()
 	finalStats := metrics.CalculateStats()
+	finalStats["platform"] = "Medusa"
+	finalStats["testStartTime"] = metrics.StartTime.Format(time.RFC3339)
+	finalStats["testEndTime"] = metrics.EndTime.Format(time.RFC3339)
+	finalStats["operations"] = metrics.OperationStats()
+	metrics.mutex.Lock()
+	variants := variantStats(metrics.OperationCounts, metrics.OperationFailures, metrics.OperationDurations)
+	tags := tagStats(config.Test.Tags, metrics.OperationCounts, metrics.OperationFailures, metrics.OperationDurations)
+	keyStats := generator.Keys.report(metrics.OperationCounts, metrics.OperationFailures, metrics.OperationDurations)
+	metrics.mutex.Unlock()
+	if variants != nil {
+		finalStats["variants"] = variants
+	}
+	if tags != nil {
+		finalStats["tags"] = tags
+	}
+	if keyStats != nil {
+		finalStats["apiKeys"] = keyStats
+	}
+	if environment != nil {
+		finalStats["environment"] = environment
+	}
+	finalStats["runId"] = runID
+	finalStats["generatorHost"] = generatorHost
+	if policyReport != nil {
+		finalStats["targetPolicy"] = policyReport
+	}
+	if calibration != nil {
+		finalStats["calibration"] = calibration
+	}
+	if jumps := clock.report(); jumps != nil {
+		finalStats["clockJumps"] = jumps
+	}
+	if connections := metrics.Conns.report(); connections != nil {
+		finalStats["connections"] = connections
+	}
+	if corpus := generator.Corpus.report(); corpus != nil {
+		finalStats["corpus"] = corpus
+	}
+	if endpoints := generator.Endpoints.report(); endpoints != nil {
+		finalStats["endpointRPS"] = endpoints
+	}
+	if phases := generator.Phases.report(metrics.Series); phases != nil {
+		finalStats["phases"] = phases
+	}
+	finalStats["resources"] = guard.report()
+	if maxDuration := limit.report(); maxDuration != nil {
+		finalStats["maxDuration"] = maxDuration
+	}
+	if blackouts := blackout.report(); blackouts != nil {
+		finalStats["blackouts"] = blackouts
+	}
+	if caps := pool.Limiter.report(); caps != nil {
+		finalStats["concurrencyLimits"] = caps
+	}
+	if config.Test.BurstMode {
+		finalStats["bursts"] = generator.bursts.report(burstSettings(&config))
+	}
+	if bandwidth := pool.Shaper.report(); bandwidth != nil {
+		finalStats["bandwidthProfiles"] = bandwidth
+	}
+	if annotations := hooks.report(); len(annotations) > 0 {
+		finalStats["annotations"] = annotations
+	}
+	if resources := scraper.report(); resources != nil {
+		finalStats["targetResources"] = resources
+	}
+	if polled := external.report(); polled != nil {
+		finalStats["externalMetrics"] = polled
+	}
+	if trace := tracer.report(); trace != nil {
+		finalStats["trace"] = trace
+	}
+	if attribution := tracer.attributionReport(); attribution != nil {
+		finalStats["latencyAttribution"] = attribution
+	}
+	if redirects := pool.Redirects.report(); redirects != nil {
+		finalStats["redirects"] = redirects
+	}
+	if uploads := pool.Uploads.report(); uploads != nil {
+		finalStats["uploads"] = uploads
+	}
+	if stored := entities.report(); stored != nil {
+		finalStats["entities"] = stored
+	}
+	if downloads := assets.report(); downloads != nil {
+		finalStats["assets"] = downloads
+	}
+	if loads := pool.Pages.report(); loads != nil {
+		finalStats["pages"] = loads
+	}
+	if staleness := cache.report(); staleness != nil {
+		finalStats["cacheChecks"] = staleness
+	}
+	if divergence := golden.report(); divergence != nil {
+		finalStats["golden"] = divergence
+	}
+	if funnel := generator.Journeys.report(); funnel != nil {
+		finalStats["journeys"] = funnel
+	}
+	if typing := generator.Autocomplete.report(); typing != nil {
+		finalStats["autocomplete"] = typing
+	}
+	if held := generator.Hold.report(); held != nil {
+		finalStats["heldStages"] = held
+	}
+	if discovery := generator.Discovered.report(); discovery != nil {
+		finalStats["discovery"] = discovery
+	}
+	if adaptive := generator.Adaptive.report(metrics.Series); adaptive != nil {
+		finalStats["adaptive"] = adaptive
+	}
+	if surge := metrics.FlashSale.report(); surge != nil {
+		finalStats["flashSale"] = surge
+	}
+	if anomalies := metrics.Series.report(); anomalies != nil {
+		finalStats["anomalies"] = anomalies
+	}
+	if async := pool.Async.report(); async != nil {
+		finalStats["asyncOperations"] = async
+	}
+	if received := webhooks.report(); received != nil {
+		finalStats["webhooks"] = received
+	}
+	causes := metrics.Errors.report()
+	if len(causes) > 0 {
+		finalStats["errorCauses"] = causes
+	}
+	checks := checkThresholds(config.Test.Thresholds, finalStats)
+	if checks != nil {
+		finalStats["thresholds"] = map[string]interface{}{
+			"passed": thresholdsPassed(checks),
+			"checks": checks,
+		}
+	}
+	sloMet := slo.evaluate(finalStats)
+	if embedded != nil {
+		finalStats["config"] = embedded
+		if err := sealResults(finalStats); err != nil {
+			fmt.Printf("Error computing the results checksum: %v\n", err)
+		}
+	}
 	finalStatsJSON, _ := json.MarshalIndent(finalStats, "", "  ")
-	fmt.Println("\nFinal Test Results:")
-	fmt.Println(string(finalStatsJSON))
+	if *printJSON {
+		fmt.Println("\nFinal Test Results:")
+		fmt.Println(string(finalStatsJSON))
+	}
+	printSummary(finalStats, causes, checks)
+	
+	// Save to file
+	output := resultsOutput{Dir: *outDir, NameTemplate: *outName}
+	path, err := output.write("medusa", metrics.StartTime, finalStatsJSON)
+	if err != nil {
+		fmt.Printf("Error writing results file: %v\n", err)
+	} else {
+		fmt.Printf("\nDetailed results saved to %s\n", path)
+	}
+	if !thresholdsPassed(checks) || !sloMet {
+		os.Exit(thresholdFailedExit)
+	}
 }
 
+// apiKeyPlaceholder is the APIKey of a created default config
+const apiKeyPlaceholder = "pk_your_publishable_key"
+
 // createDefaultConfig creates a default configuration file
 func createDefaultConfig(path string) {
 	config := Config{}
@@ -603,8 +1548,8 @@ func createDefaultConfig(path string) {
 	config.Endpoints.Products = "http://wsm-medusa.alphasquadit.com/store/products"
 	config.Endpoints.Categories = "http://wsm-medusa.alphasquadit.com/store/product-categories/"
 	
-	// Set default API key
-	config.APIKey = "pk_cf8ea2bcf8f97ee114ed8797b464ffb068777ff1751ac7b0612f58b06dca21fa"
+	// A placeholder, so no real key is written out
+	config.APIKey = apiKeyPlaceholder
 	
 	// Set default test configuration
 	config.Test.MaxWorkers = 2500
@@ -623,6 +1568,9 @@ func createDefaultConfig(path string) {
 	config.Test.AdaptiveConfig.StabilizationWindow = 15 * time.Second
 	config.Test.Duration = 10 * time.Minute
 	
+	// Single products and categories drawn from the store's own IDs
+	config.Test.Discovery.Percent = 30
+	
 	// Define ramp-up stages (only used if AdaptiveRPS is false)
 	config.Test.RampupStages = []Stage{
 		{Duration: 30 * time.Second, TargetRPS: 10, Description: "Warm-up at 10 RPS"},
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// maxDurationGrace is how long the shutdown may take once MaxDuration is
+// reached; a shutdown stuck beyond it ends the process without results
+const maxDurationGrace = 2 * time.Minute
+
+// maxDurationExit is the exit status when the shutdown after MaxDuration
+// did not finish within maxDurationGrace
+const maxDurationExit = 98
+
+// runLimit stops a run at Test.MaxDuration (or -max-duration) whatever the
+// stages, phases or adaptive settings say, so a mistyped duration cannot keep
+// a target under load overnight. The run shuts down as on an interrupt and
+// writes its results.
+type runLimit struct {
+	limit   time.Duration
+	timer   *time.Timer
+	reached bool
+}
+
+// newRunLimit returns nil when there is no limit; override is -max-duration
+// and replaces the config's
+func newRunLimit(config *Config, override time.Duration) (*runLimit, error) {
+	limit := config.Test.MaxDuration
+	if override != 0 {
+		limit = override
+	}
+	if limit < 0 {
+		return nil, fmt.Errorf("MaxDuration %s is negative", limit)
+	}
+	if limit == 0 {
+		return nil, nil
+	}
+	if planned := plannedDuration(config); planned > limit {
+		fmt.Printf("Warning: the plan runs for %s; MaxDuration stops it after %s\n", planned, limit)
+	}
+	return &runLimit{limit: limit}, nil
+}
+
+// Start begins the countdown; call it when the load starts
+func (l *runLimit) Start() {
+	if l != nil {
+		l.timer = time.NewTimer(l.limit)
+	}
+}
+
+// C fires when the limit is reached; it never fires without a limit
+func (l *runLimit) C() <-chan time.Time {
+	if l == nil {
+		return nil
+	}
+	return l.timer.C
+}
+
+// Reached notes that the run is being stopped by the limit and makes sure
+// the process ends even if the shutdown hangs
+func (l *runLimit) Reached() {
+	l.reached = true
+	fmt.Printf("\nMaximum duration of %s reached, stopping the test...\n", l.limit)
+	time.AfterFunc(maxDurationGrace, func() {
+		fmt.Fprintf(os.Stderr, "Shutdown did not finish within %s of reaching MaxDuration; exiting without results\n", maxDurationGrace)
+		os.Exit(maxDurationExit)
+	})
+}
+
+// Stop cancels the countdown of a run that ended by itself
+func (l *runLimit) Stop() {
+	if l != nil && l.timer != nil {
+		l.timer.Stop()
+	}
+}
+
+// report records the limit and whether it ended the run (nil without a limit)
+func (l *runLimit) report() map[string]interface{} {
+	if l == nil {
+		return nil
+	}
+	return map[string]interface{}{
+		"limit":   l.limit.String(),
+		"reached": l.reached,
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// defaultResultsName is the results file name template used when -out-name is not given
+const defaultResultsName = "{platform}_{timestamp}.json"
+
+// resultsOutput describes where a run's results file is written
+type resultsOutput struct {
+	Dir          string
+	NameTemplate string
+}
+
+// path expands the name template for the given platform and time
+func (o resultsOutput) path(platform string, t time.Time) string {
+	name := o.NameTemplate
+	if name == "" {
+		name = defaultResultsName
+	}
+	name = strings.NewReplacer(
+		"{platform}", platform,
+		"{timestamp}", t.Format("20060102_150405"),
+		"{date}", t.Format("20060102"),
+		"{runId}", runID,
+	).Replace(name)
+	return filepath.Join(o.Dir, name)
+}
+
+// write saves the results and points <platform>_latest.json at the new file,
+// so repeated runs accumulate instead of overwriting each other
+func (o resultsOutput) write(platform string, t time.Time, data []byte) (string, error) {
+	if o.Dir != "" {
+		if err := os.MkdirAll(o.Dir, 0755); err != nil {
+			return "", fmt.Errorf("creating output directory: %v", err)
+		}
+	}
+
+	path := o.path(platform, t)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return "", err
+	}
+
+	latest := filepath.Join(filepath.Dir(path), platform+"_latest.json")
+	if latest != path {
+		os.Remove(latest)
+		if err := os.Symlink(filepath.Base(path), latest); err != nil {
+			fmt.Printf("Warning: could not update %s: %v\n", latest, err)
+		}
+	}
+
+	return path, nil
+}
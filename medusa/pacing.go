@@ -0,0 +1,102 @@
+package main
+
+import (
+	"math"
+	"strconv"
+	"time"
+)
+
+// maxPacingLag is how far a generator catches up after falling behind its
+// schedule (a stalled goroutine, a paused process); sends missed beyond it
+// are skipped rather than fired in a burst
+const maxPacingLag = 100 * time.Millisecond
+
+// roundRPS rounds a rate to two decimals for the results
+func roundRPS(rps float64) float64 {
+	return math.Round(rps*100) / 100
+}
+
+// formatRPS prints a rate without trailing zeros: "200", "0.5", "12.34"
+func formatRPS(rps float64) string {
+	return strconv.FormatFloat(roundRPS(rps), 'f', -1, 64)
+}
+
+// publishRate sets the target rate the generators pace to. The rate may be
+// fractional.
+func (g *LoadGenerator) publishRate(rps float64) {
+	g.rate.Store(math.Float64bits(math.Max(rps, 0)))
+}
+
+// targetRate returns the rate published by the rate loop
+func (g *LoadGenerator) targetRate() float64 {
+	return math.Float64frombits(g.rate.Load())
+}
+
+// emit queues one task unless the resource guard or a blackout window holds
+// generation back
+func (g *LoadGenerator) emit() {
+	if g.Guard.Throttled() || g.Blackout.Paused() {
+		return
+	}
+	task := g.nextTask()
+	g.assignVariant(&task)
+
+	// Try to send the task, but don't block if queue is full
+	select {
+	case g.Pool.Tasks <- task:
+	default:
+		// Queue is full, skip this task
+	}
+}
+
+// startGenerators launches the pacing generators, which run until the load
+// generator stops or finishes
+func (g *LoadGenerator) startGenerators() {
+	for i := 0; i < g.Tuning.Generators; i++ {
+		g.WaitGroup.Add(1)
+		go g.pace(i, g.targetRate, g.emit)
+	}
+}
+
+// pace calls emit for this generator's share of rate, each time at its
+// computed send time: the previous send plus the interval at the current
+// rate. Generators are offset from each other by a fraction of the interval
+// so their sends interleave.
+func (g *LoadGenerator) pace(index int, rate func() float64, emit func()) {
+	defer g.WaitGroup.Done()
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	generators := float64(g.Tuning.Generators)
+	var last time.Time // zero while the rate is 0
+	for {
+		select {
+		case <-g.StopChan:
+			return
+		case <-g.Done:
+			return
+		case <-timer.C:
+		}
+
+		now := time.Now()
+		wait := g.Tuning.Tick
+		if rate := rate() / generators; rate > 0 {
+			interval := time.Duration(float64(time.Second) / rate)
+			if last.IsZero() {
+				last = now.Add(-interval + time.Duration(float64(interval)*float64(index)/generators))
+			}
+			if now.Sub(last.Add(interval)) > maxPacingLag {
+				last = now.Add(-interval)
+			}
+			next := last.Add(interval)
+			for !next.After(now) {
+				emit()
+				last, next = next, next.Add(interval)
+			}
+			wait = min(next.Sub(now), wait)
+		} else {
+			last = time.Time{}
+		}
+		timer.Reset(wait)
+	}
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// plannedDuration returns how long the configured test is expected to run,
+// or zero if it runs until interrupted
+func plannedDuration(config *Config) time.Duration {
+	if config.Test.AdaptiveRPS || config.Test.BurstMode || len(config.Test.EndpointRPS) > 0 {
+		return config.Test.Duration
+	}
+
+	var total time.Duration
+	if len(config.Test.Phases) > 0 {
+		total = phasesDuration(config.Test.Phases)
+		if config.Test.Duration > 0 && config.Test.Duration < total {
+			total = config.Test.Duration
+		}
+		return total
+	}
+	for _, stage := range config.Test.RampupStages {
+		total += stage.Duration
+	}
+	if config.Test.Duration > 0 && config.Test.Duration < total {
+		total = config.Test.Duration
+	}
+	return total
+}
+
+// expectedStagedRequests estimates the number of requests a staged test will
+// send, following the same linear interpolation the load generator uses
+func expectedStagedRequests(config *Config) int64 {
+	stages := config.Test.RampupStages
+	if len(stages) == 0 {
+		return 0
+	}
+
+	limit := config.Test.Duration
+	var elapsed time.Duration
+	var total float64
+	prevRPS := stages[0].TargetRPS
+
+	for _, stage := range stages {
+		duration := stage.Duration
+		endRPS := stage.TargetRPS
+		if limit > 0 && elapsed+duration > limit {
+			// Only part of this stage runs before the overall duration is hit
+			fraction := float64(limit-elapsed) / float64(duration)
+			duration = limit - elapsed
+			endRPS = prevRPS + (endRPS-prevRPS)*fraction
+		}
+
+		total += (prevRPS + endRPS) / 2 * duration.Seconds()
+		elapsed += duration
+		prevRPS = stage.TargetRPS
+
+		if limit > 0 && elapsed >= limit {
+			break
+		}
+	}
+
+	return int64(total)
+}
+
+// expectedPhasedRequests estimates the requests of phases without an
+// adaptive one, up to the planned duration
+func expectedPhasedRequests(phases []LoadPhase, planned time.Duration) int64 {
+	var total, previous float64
+	var elapsed time.Duration
+	for _, phase := range phases {
+		if elapsed >= planned {
+			break
+		}
+		duration := min(phase.Duration, planned-elapsed)
+		fraction := float64(duration) / float64(phase.Duration)
+		switch phase.Model {
+		case "ramp":
+			end := previous + (phase.RPS-previous)*fraction
+			total += (previous + end) / 2 * duration.Seconds()
+		case "spike":
+			spike := min(max(int64(duration-phase.SpikeAt), 0), int64(phase.SpikeFor))
+			total += phase.RPS*duration.Seconds() + (phase.PeakRPS-phase.RPS)*time.Duration(spike).Seconds()
+		default:
+			total += phase.RPS * duration.Seconds()
+		}
+		previous = phase.RPS
+		elapsed += phase.Duration
+	}
+	return int64(total)
+}
+
+// printTestPlan prints the stages, expected duration and expected request volume
+func printTestPlan(config *Config) {
+	planned := plannedDuration(config)
+
+	fmt.Println("Test plan:")
+	if config.Test.BurstMode {
+		size, interval := burstSettings(config)
+		fmt.Printf("  Burst: %d requests back-to-back every %s\n", size, interval)
+		if planned > 0 {
+			fmt.Printf("  Expected duration: %s\n", planned)
+			fmt.Printf("  Expected requests: ~%d\n", int64(planned/interval+1)*int64(size))
+		} else {
+			fmt.Println("  Expected duration: until interrupted")
+		}
+		return
+	}
+	if len(config.Test.EndpointRPS) > 0 {
+		fmt.Printf("  Per endpoint: %s\n", describeEndpointRPS(config.Test.EndpointRPS))
+		fmt.Printf("  Expected duration: %s\n", planned)
+		fmt.Printf("  Expected requests: ~%d\n", int64(plannedPeakRPS(config)*planned.Seconds()))
+		return
+	}
+	if config.Test.AdaptiveRPS {
+		ac := config.Test.AdaptiveConfig
+		fmt.Printf("  Adaptive: start at %s RPS, bounded to %s-%s RPS\n", formatRPS(ac.InitialRPS), formatRPS(ac.MinimumRPS), formatRPS(ac.MaximumRPS))
+		if planned > 0 {
+			fmt.Printf("  Expected duration: %s\n", planned)
+			fmt.Printf("  Expected requests: between %d and %d\n",
+				int64(ac.MinimumRPS*planned.Seconds()),
+				int64(ac.MaximumRPS*planned.Seconds()))
+		} else {
+			fmt.Println("  Expected duration: until interrupted")
+		}
+		return
+	}
+
+	if len(config.Test.Phases) > 0 {
+		var offset time.Duration
+		for i, phase := range config.Test.Phases {
+			fmt.Printf("  Phase %d [%s - %s]: %s, %s\n", i+1, offset, offset+phase.Duration, phase.Name, phase.describe())
+			offset += phase.Duration
+		}
+		fmt.Printf("  Expected duration: %s\n", planned)
+		if !adaptivePhase(config) {
+			fmt.Printf("  Expected requests: ~%d\n", expectedPhasedRequests(config.Test.Phases, planned))
+		}
+		return
+	}
+	if config.Test.ProfileCSV != "" {
+		fmt.Printf("  Profile: %d points from %s, peak %s RPS\n", len(config.Test.RampupStages), config.Test.ProfileCSV, formatRPS(plannedPeakRPS(config)))
+		fmt.Printf("  Expected duration: %s\n", planned)
+		fmt.Printf("  Expected requests: ~%d\n", expectedStagedRequests(config))
+		return
+	}
+
+	var offset time.Duration
+	for i, stage := range config.Test.RampupStages {
+		fmt.Printf("  Stage %d [%s - %s]: %s RPS over %s (%s)\n",
+			i+1, offset, offset+stage.Duration, formatRPS(stage.TargetRPS), stage.Duration, stage.Description)
+		offset += stage.Duration
+	}
+	fmt.Printf("  Expected duration: %s\n", planned)
+	fmt.Printf("  Expected requests: ~%d\n", expectedStagedRequests(config))
+}
+
+// progress describes elapsed/remaining time and the current stage for periodic reports
+func (g *LoadGenerator) progress() map[string]interface{} {
+	elapsed := time.Since(g.testStart)
+	planned := plannedDuration(g.Config)
+
+	progress := map[string]interface{}{
+		"elapsed": elapsed.Round(time.Second).String(),
+	}
+
+	if planned > 0 {
+		remaining := planned - elapsed
+		if remaining < 0 {
+			remaining = 0
+		}
+		percent := float64(elapsed) / float64(planned) * 100
+		if percent > 100 {
+			percent = 100
+		}
+		progress["remaining"] = remaining.Round(time.Second).String()
+		progress["percentComplete"] = fmt.Sprintf("%.1f%%", percent)
+	}
+
+	if g.Phases != nil {
+		progress["phase"] = g.Phases.progress()
+	}
+
+	stages := g.Config.Test.RampupStages
+	if !g.Config.Test.AdaptiveRPS && !g.Config.Test.BurstMode && g.Endpoints == nil && g.Phases == nil && len(stages) > 0 {
+		index := int(g.stageIndex.Load())
+		if index >= len(stages) {
+			index = len(stages) - 1
+		}
+		stage := stages[index]
+
+		stageElapsed := time.Since(g.testStart) - time.Duration(g.stageStart.Load())
+		stagePercent := 100.0
+		if stage.Duration > 0 && stageElapsed < stage.Duration {
+			stagePercent = float64(stageElapsed) / float64(stage.Duration) * 100
+		}
+
+		progress["stage"] = fmt.Sprintf("%d/%d: %s", index+1, len(stages), stage.Description)
+		progress["stageProgress"] = fmt.Sprintf("%.1f%%", stagePercent)
+	}
+
+	return progress
+}
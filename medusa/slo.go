@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// defaultSLODir is where the per-platform SLO files are checked in
+const defaultSLODir = "slo"
+
+// SLOFile is the service level objectives of one platform, kept in
+// <dir>/<platform>.json so every run is judged by the same criteria instead
+// of thresholds copied into each config. Objectives take the fields of
+// Test.Thresholds.
+type SLOFile struct {
+	Name       string
+	Owner      string
+	Enforce    bool // a missed objective fails the run (exit 99) like a missed threshold
+	Objectives ThresholdConfig
+}
+
+// serviceObjective is a loaded SLO file
+type serviceObjective struct {
+	path   string
+	sha256 string
+	file   SLOFile
+}
+
+// thresholdsSet reports whether any limit is set
+func thresholdsSet(config ThresholdConfig) bool {
+	return config.P95 > 0 || config.P99 > 0 || config.ErrorRate > 0 || config.MinRPS > 0 || len(config.Operations) > 0
+}
+
+// loadSLO reads the platform's SLO file from dir; it returns nil when dir
+// is empty or has no file for the platform
+func loadSLO(dir, platform string) (*serviceObjective, error) {
+	if dir == "" {
+		return nil, nil
+	}
+	path := filepath.Join(dir, platform+".json")
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var file SLOFile
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields() // a misspelled objective would go unchecked
+	if err := decoder.Decode(&file); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if !thresholdsSet(file.Objectives) {
+		return nil, fmt.Errorf("%s sets no Objectives", path)
+	}
+	sum := sha256.Sum256(data)
+	return &serviceObjective{path: path, sha256: hex.EncodeToString(sum[:]), file: file}, nil
+}
+
+// describe names the SLO for the console
+func (s *serviceObjective) describe() string {
+	if s.file.Name != "" {
+		return fmt.Sprintf("%s (%s)", s.file.Name, s.path)
+	}
+	return s.path
+}
+
+// evaluate checks the objectives against the final report and records the
+// verdict in it, with the file's checksum to tell SLO revisions apart. It
+// returns false only when an enforced SLO was missed.
+func (s *serviceObjective) evaluate(report map[string]interface{}) bool {
+	if s == nil {
+		return true
+	}
+	checks := checkThresholds(s.file.Objectives, report)
+	passed := thresholdsPassed(checks)
+	verdict := map[string]interface{}{
+		"file":     s.path,
+		"sha256":   s.sha256,
+		"passed":   passed,
+		"enforced": s.file.Enforce,
+		"checks":   checks,
+	}
+	if s.file.Name != "" {
+		verdict["name"] = s.file.Name
+	}
+	if s.file.Owner != "" {
+		verdict["owner"] = s.file.Owner
+	}
+	report["slo"] = verdict
+	return passed || !s.file.Enforce
+}
+
+// printSLOVerdict prints the SLO line of the summary
+func printSLOVerdict(verdict map[string]interface{}) {
+	name := verdict["file"]
+	if n, ok := verdict["name"]; ok {
+		name = fmt.Sprintf("%v (%v)", n, verdict["file"])
+	}
+	checks, _ := verdict["checks"].([]map[string]interface{})
+	if verdict["passed"] == true {
+		fmt.Printf("  SLO %v: PASSED (%d checked)\n", name, len(checks))
+		return
+	}
+	var missed []string
+	for _, check := range checks {
+		if !check["passed"].(bool) {
+			missed = append(missed, fmt.Sprintf("%s %s, limit %s", check["threshold"], check["measured"], check["limit"]))
+		}
+	}
+	enforced := ""
+	if verdict["enforced"] != true {
+		enforced = " (not enforced)"
+	}
+	fmt.Printf("  SLO %v: FAILED%s: %s\n", name, enforced, strings.Join(missed, "; "))
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// tagStats groups the per-operation counts by the tags assigned to each
+// operation (e.g. read, cached, critical); variants count towards their
+// base operation's tags. An operation may carry several tags.
+func tagStats(tags map[string][]string, counts, failures map[string]int64, durations map[string][]time.Duration) map[string]interface{} {
+	if len(tags) == 0 {
+		return nil
+	}
+
+	type totals struct {
+		requests, failed int64
+		operations       map[string]bool
+		durations        []time.Duration
+	}
+	grouped := make(map[string]*totals)
+	for op, count := range counts {
+		base := baseOperation(op)
+		for _, tag := range tags[base] {
+			t, ok := grouped[tag]
+			if !ok {
+				t = &totals{operations: make(map[string]bool)}
+				grouped[tag] = t
+			}
+			t.requests += count
+			t.failed += failures[op]
+			t.operations[base] = true
+			t.durations = append(t.durations, durations[op]...)
+		}
+	}
+
+	stats := make(map[string]interface{}, len(grouped))
+	for tag, t := range grouped {
+		operations := make([]string, 0, len(t.operations))
+		for op := range t.operations {
+			operations = append(operations, op)
+		}
+		sort.Strings(operations)
+
+		entry := map[string]interface{}{
+			"operations":     operations,
+			"requests":       t.requests,
+			"failedRequests": t.failed,
+			"errorRate":      fmt.Sprintf("%.2f%%", float64(t.failed)/float64(max(t.requests, 1))*100),
+		}
+		if len(t.durations) > 0 {
+			sort.Slice(t.durations, func(i, j int) bool { return t.durations[i] < t.durations[j] })
+			entry["latency"] = latencyPercentiles(t.durations)
+		}
+		stats[tag] = entry
+	}
+	return stats
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// TargetMetricsConfig points at a Prometheus endpoint exposed by the target so
+// its memory/CPU can be tracked next to latency during long (soak) runs
+type TargetMetricsConfig struct {
+	URL      string
+	Interval time.Duration     // default 30s
+	Metrics  []string          // default process_resident_memory_bytes, process_cpu_seconds_total
+	Headers  map[string]string // e.g. Authorization for the metrics endpoint
+}
+
+var defaultTargetMetrics = []string{"process_resident_memory_bytes", "process_cpu_seconds_total"}
+
+// parsePrometheus extracts the wanted metrics from Prometheus text format,
+// summing the values of all label sets of a metric
+func parsePrometheus(r io.Reader, wanted map[string]bool) map[string]float64 {
+	values := make(map[string]float64)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		name, rest := line, ""
+		if i := strings.IndexAny(line, "{ "); i >= 0 {
+			name, rest = line[:i], line[i:]
+		}
+		if !wanted[name] {
+			continue
+		}
+		if strings.HasPrefix(rest, "{") {
+			end := strings.LastIndex(rest, "}")
+			if end < 0 {
+				continue
+			}
+			rest = rest[end+1:]
+		}
+		fields := strings.Fields(rest)
+		if len(fields) == 0 {
+			continue
+		}
+		if v, err := strconv.ParseFloat(fields[0], 64); err == nil {
+			values[name] += v
+		}
+	}
+	return values
+}
+
+// targetScraper periodically scrapes the target's metrics and records them in
+// a timeline together with the latency and error rate of the same window
+type targetScraper struct {
+	config  TargetMetricsConfig
+	metrics *Metrics
+	client  *http.Client
+	start   time.Time
+
+	stopChan chan struct{}
+	wg       sync.WaitGroup
+
+	mutex       sync.Mutex
+	samples     []map[string]interface{}
+	series      map[string][]float64 // per metric, aligned with offsets
+	offsets     []float64            // hours since start
+	lastDurIdx  int
+	lastTotal   int64
+	lastFailed  int64
+	lastCounter map[string]float64
+	lastTime    time.Time
+}
+
+// newTargetScraper returns nil when no metrics URL is configured
+func newTargetScraper(config TargetMetricsConfig, metrics *Metrics) *targetScraper {
+	if config.URL == "" {
+		return nil
+	}
+	if config.Interval <= 0 {
+		config.Interval = 30 * time.Second
+	}
+	if len(config.Metrics) == 0 {
+		config.Metrics = defaultTargetMetrics
+	}
+	return &targetScraper{
+		config:      config,
+		metrics:     metrics,
+		client:      &http.Client{Timeout: 10 * time.Second},
+		stopChan:    make(chan struct{}),
+		series:      make(map[string][]float64),
+		lastCounter: make(map[string]float64),
+	}
+}
+
+// scrape fetches the current values of the configured metrics
+func (s *targetScraper) scrape() (map[string]float64, error) {
+	req, err := http.NewRequest("GET", s.config.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range s.config.Headers {
+		req.Header.Set(key, value)
+	}
+	setLoadTestID(req.Header)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status %d", resp.StatusCode)
+	}
+
+	wanted := make(map[string]bool, len(s.config.Metrics))
+	for _, name := range s.config.Metrics {
+		wanted[name] = true
+	}
+	return parsePrometheus(resp.Body, wanted), nil
+}
+
+// sample records one timeline point
+func (s *targetScraper) sample(now time.Time) {
+	values, scrapeErr := s.scrape()
+
+	// Latency and error rate of the requests since the previous sample
+	s.metrics.mutex.Lock()
+	window := append([]time.Duration(nil), s.metrics.RequestDurations[min(s.lastDurIdx, len(s.metrics.RequestDurations)):]...)
+	s.lastDurIdx = len(s.metrics.RequestDurations)
+	s.metrics.mutex.Unlock()
+	total := atomic.LoadInt64(&s.metrics.TotalRequests)
+	failed := atomic.LoadInt64(&s.metrics.FailedRequests)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	point := map[string]interface{}{
+		"time":     now.Format(time.RFC3339),
+		"offset":   now.Sub(s.start).Round(time.Second).String(),
+		"requests": total - s.lastTotal,
+	}
+	if requests := total - s.lastTotal; requests > 0 {
+		point["errorRate"] = fmt.Sprintf("%.2f%%", float64(failed-s.lastFailed)/float64(requests)*100)
+	}
+	s.lastTotal, s.lastFailed = total, failed
+
+	p95 := -1.0
+	if len(window) > 0 {
+		sort.Slice(window, func(i, j int) bool { return window[i] < window[j] })
+		point["p50"] = percentileDuration(window, 0.5).String()
+		point["p95"] = percentileDuration(window, 0.95).String()
+		p95 = float64(percentileDuration(window, 0.95)) / float64(time.Millisecond)
+	}
+
+	target := make(map[string]float64)
+	if scrapeErr != nil {
+		point["scrapeError"] = scrapeErr.Error()
+	} else {
+		elapsed := now.Sub(s.lastTime).Seconds()
+		for name, value := range values {
+			// Counters are only meaningful as a rate (e.g. CPU cores in use)
+			if strings.HasSuffix(name, "_total") {
+				if prev, ok := s.lastCounter[name]; ok && elapsed > 0 {
+					target[name+"_rate"] = (value - prev) / elapsed
+				}
+				s.lastCounter[name] = value
+				continue
+			}
+			target[name] = value
+		}
+		point["target"] = target
+	}
+	s.lastTime = now
+	s.samples = append(s.samples, point)
+
+	// Keep series aligned with offsets for trend calculation; gaps are -1
+	hours := now.Sub(s.start).Hours()
+	s.offsets = append(s.offsets, hours)
+	s.series["latencyP95Ms"] = append(s.series["latencyP95Ms"], p95)
+	for name, value := range target {
+		for len(s.series[name]) < len(s.offsets)-1 {
+			s.series[name] = append(s.series[name], -1)
+		}
+		s.series[name] = append(s.series[name], value)
+	}
+}
+
+// Start scrapes once immediately and then every Interval
+func (s *targetScraper) Start(start time.Time) {
+	if s == nil {
+		return
+	}
+	s.start = start
+	s.lastTime = start
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(s.config.Interval)
+		defer ticker.Stop()
+		s.sample(time.Now())
+		for {
+			select {
+			case <-s.stopChan:
+				return
+			case now := <-ticker.C:
+				s.sample(now)
+			}
+		}
+	}()
+}
+
+// Stop ends scraping
+func (s *targetScraper) Stop() {
+	if s == nil {
+		return
+	}
+	close(s.stopChan)
+	s.wg.Wait()
+}
+
+// linearTrend fits value = a + b*hours over the points with a value (>= 0)
+// and returns the slope per hour and the first/last values
+func linearTrend(offsets, values []float64) (slope, first, last float64, n int) {
+	var sumX, sumY, sumXY, sumXX float64
+	for i, y := range values {
+		if y < 0 || i >= len(offsets) {
+			continue
+		}
+		x := offsets[i]
+		if n == 0 {
+			first = y
+		}
+		last = y
+		n++
+		sumX += x
+		sumY += y
+		sumXY += x * y
+		sumXX += x * x
+	}
+	denominator := float64(n)*sumXX - sumX*sumX
+	if n < 2 || denominator == 0 {
+		return 0, first, last, n
+	}
+	return (float64(n)*sumXY - sumX*sumY) / denominator, first, last, n
+}
+
+// report returns the timeline and a trend per series. A target memory series
+// that grows by more than 10% over the run while still rising is flagged as a possible leak.
+func (s *targetScraper) report() map[string]interface{} {
+	if s == nil {
+		return nil
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	trends := make(map[string]interface{}, len(s.series))
+	for name, values := range s.series {
+		slope, first, last, n := linearTrend(s.offsets, values)
+		if n < 2 {
+			continue
+		}
+		trend := map[string]interface{}{
+			"first":        first,
+			"last":         last,
+			"slopePerHour": slope,
+			"samplesUsed":  n,
+		}
+		if first > 0 {
+			trend["change"] = fmt.Sprintf("%.1f%%", (last-first)/first*100)
+		}
+		if strings.Contains(name, "memory") && first > 0 && last > first*1.1 && slope > 0 {
+			trend["possibleLeak"] = true
+		}
+		trends[name] = trend
+	}
+
+	return map[string]interface{}{
+		"url":      s.config.URL,
+		"interval": s.config.Interval.String(),
+		"timeline": s.samples,
+		"trends":   trends,
+	}
+}
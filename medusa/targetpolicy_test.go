@@ -0,0 +1,78 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckTargetPolicy(t *testing.T) {
+	policy := &TargetPolicy{
+		Production: []string{"*.example.com"},
+		MaxRPS:     5,
+	}
+	staging := &TargetPolicy{Allowed: []string{"localhost", "*.staging.example.com"}}
+
+	for _, c := range []struct {
+		name     string
+		policy   *TargetPolicy
+		targets  []string
+		peak     float64
+		override bool
+		refused  string
+		hosts    int
+	}{
+		{"no policy", nil, []string{"shop.example.com"}, 1000, false, "", 0},
+		{"not production", policy, []string{"localhost:3000"}, 1000, false, "", 0},
+		{"bare domain is not matched", policy, []string{"example.com"}, 1000, false, "", 0},
+		{"production above MaxRPS", policy, []string{"localhost", "Shop.Example.com:443"}, 50, false, "Shop.Example.com:443 (*.example.com)", 0},
+		{"production within MaxRPS", policy, []string{"shop.example.com"}, 5, false, "", 1},
+		{"overridden", policy, []string{"shop.example.com"}, 50, true, "", 1},
+		{"allowed", staging, []string{"localhost:8000", "api.staging.example.com"}, 1000, false, "", 0},
+		{"not in Allowed", staging, []string{"api.example.com"}, 1000, false, "api.example.com (not in Allowed)", 0},
+	} {
+		report, err := checkTargetPolicy(c.policy, c.targets, c.peak, c.override)
+		if c.refused != "" {
+			if err == nil || !strings.Contains(err.Error(), c.refused) {
+				t.Errorf("%s: error %v, want one naming %q", c.name, err, c.refused)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		hosts, _ := report["productionHosts"].([]string)
+		if len(hosts) != c.hosts {
+			t.Errorf("%s: production hosts %v, want %d", c.name, hosts, c.hosts)
+		}
+		if overridden, _ := report["overridden"].(bool); overridden != (c.override && c.hosts > 0) {
+			t.Errorf("%s: overridden %v", c.name, overridden)
+		}
+	}
+}
+
+func TestLoadTargetPolicy(t *testing.T) {
+	dir := t.TempDir()
+	if policy, err := loadTargetPolicy(filepath.Join(dir, "missing.json")); policy != nil || err != nil {
+		t.Errorf("missing file: %v, %v", policy, err)
+	}
+	for _, c := range []struct {
+		name, data, err string
+	}{
+		{"valid", `{"Production": ["shop.example.com"], "MaxRPS": 5}`, ""},
+		{"misspelled field", `{"Prodution": ["shop.example.com"]}`, "unknown field"},
+		{"bad pattern", `{"Production": ["[shop.example.com"]}`, "pattern"},
+		{"negative MaxRPS", `{"MaxRPS": -1}`, "negative"},
+	} {
+		file := filepath.Join(dir, "policy.json")
+		if err := os.WriteFile(file, []byte(c.data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		_, err := loadTargetPolicy(file)
+		if c.err == "" && err != nil || c.err != "" && (err == nil || !strings.Contains(err.Error(), c.err)) {
+			t.Errorf("%s: error %v, want %q", c.name, err, c.err)
+		}
+	}
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptrace"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// TraceConfig enables the request trace log: a sample of all requests (not
+// just errors) written as NDJSON with a timing breakdown
+type TraceConfig struct {
+	File         string  // default {platform}_trace_{timestamp}.ndjson in the output directory
+	SampleRate   float64 // fraction of requests traced, 0 = off
+	MaxPerSecond int     // cap on traced requests per second, default 100
+
+	// Fraction of requests whose latency is attributed to DNS, connect, TLS,
+	// server and transfer time in the results, 0 = off. Independent of the
+	// trace file and not capped per second.
+	Attribution float64
+}
+
+// traceTiming collects the httptrace events of one sampled request
+type traceTiming struct {
+	start        time.Time
+	dnsStart     time.Time
+	dnsDone      time.Time
+	connectStart time.Time
+	connectDone  time.Time
+	tlsStart     time.Time
+	tlsDone      time.Time
+	wroteRequest time.Time
+	firstByte    time.Time
+	bodyDone     time.Time
+	reused       bool
+	header       http.Header // response header, for Server-Timing
+
+	logged     bool // written to the trace file
+	attributed bool // counted in the latency attribution
+}
+
+// bodyRead marks the end of reading the response body
+func (t *traceTiming) bodyRead(header http.Header) {
+	if t != nil {
+		t.bodyDone = time.Now()
+		t.header = header
+	}
+}
+
+// drain reads a response body that would otherwise be closed unread, so the
+// transfer time of attributed requests is measured
+func (t *traceTiming) drain(resp *http.Response) {
+	if t == nil {
+		return
+	}
+	if t.attributed {
+		io.Copy(io.Discard, resp.Body)
+		t.bodyDone = time.Now()
+	}
+	t.header = resp.Header
+}
+
+// requestTracer writes sampled request traces to an NDJSON file
+type requestTracer struct {
+	config TraceConfig
+	path   string
+
+	attribution *latencyAttribution
+
+	mutex     sync.Mutex
+	file      *os.File
+	writer    *bufio.Writer
+	second    int64
+	inSecond  int
+	written   int64
+	dropped   int64
+	writeErrs int64
+}
+
+// newRequestTracer opens the trace file; it returns nil when neither the
+// trace file nor the latency attribution is on
+func newRequestTracer(config TraceConfig, output resultsOutput, platform string, t time.Time) (*requestTracer, error) {
+	if config.SampleRate <= 0 && config.Attribution <= 0 {
+		return nil, nil
+	}
+	if config.SampleRate > 1 {
+		return nil, fmt.Errorf("SampleRate must be between 0 and 1, got %v", config.SampleRate)
+	}
+	if config.Attribution > 1 {
+		return nil, fmt.Errorf("Attribution must be between 0 and 1, got %v", config.Attribution)
+	}
+	tracer := &requestTracer{config: config}
+	if config.Attribution > 0 {
+		tracer.attribution = newLatencyAttribution()
+	}
+	if config.SampleRate <= 0 {
+		return tracer, nil
+	}
+	if config.MaxPerSecond <= 0 {
+		config.MaxPerSecond = 100
+	}
+	if config.File == "" {
+		config.File = "{platform}_trace_{timestamp}.ndjson"
+	}
+
+	path := resultsOutput{Dir: output.Dir, NameTemplate: config.File}.path(platform, t)
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, err
+		}
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	tracer.config = config
+	tracer.path, tracer.file = path, file
+	tracer.writer = bufio.NewWriterSize(file, 64*1024)
+	return tracer, nil
+}
+
+// allow decides whether to trace a request: sampled, and under the per-second cap
+func (t *requestTracer) allow() bool {
+	if t.file == nil || rand.Float64() >= t.config.SampleRate {
+		return false
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	now := time.Now().Unix()
+	if now != t.second {
+		t.second, t.inSecond = now, 0
+	}
+	if t.inSecond >= t.config.MaxPerSecond {
+		t.dropped++
+		return false
+	}
+	t.inSecond++
+	return true
+}
+
+// begin attaches a client trace to the request if it is sampled for the
+// trace file or the latency attribution
+func (t *requestTracer) begin(req *http.Request) (*http.Request, *traceTiming) {
+	if t == nil {
+		return req, nil
+	}
+	attributed := rand.Float64() < t.config.Attribution
+	logged := t.allow()
+	if !logged && !attributed {
+		return req, nil
+	}
+
+	timing := &traceTiming{start: time.Now(), logged: logged, attributed: attributed}
+	trace := &httptrace.ClientTrace{
+		DNSStart:             func(httptrace.DNSStartInfo) { timing.dnsStart = time.Now() },
+		DNSDone:              func(httptrace.DNSDoneInfo) { timing.dnsDone = time.Now() },
+		ConnectStart:         func(string, string) { timing.connectStart = time.Now() },
+		ConnectDone:          func(string, string, error) { timing.connectDone = time.Now() },
+		TLSHandshakeStart:    func() { timing.tlsStart = time.Now() },
+		TLSHandshakeDone:     func(tls.ConnectionState, error) { timing.tlsDone = time.Now() },
+		GotConn:              func(info httptrace.GotConnInfo) { timing.reused = info.Reused },
+		WroteRequest:         func(httptrace.WroteRequestInfo) { timing.wroteRequest = time.Now() },
+		GotFirstResponseByte: func() { timing.firstByte = time.Now() },
+	}
+	ctx := context.WithValue(httptrace.WithClientTrace(req.Context(), trace), traceTimingKey{}, timing)
+	return req.WithContext(ctx), timing
+}
+
+// traceTimingKey holds a traced request's timing in its context, for a
+// transport without httptrace events (fasthttp) to fill in
+type traceTimingKey struct{}
+
+// millisBetween returns the milliseconds from a to b, or nil if either is unset
+func millisBetween(a, b time.Time) interface{} {
+	if a.IsZero() || b.IsZero() {
+		return nil
+	}
+	return float64(b.Sub(a).Microseconds()) / 1000
+}
+
+// finish writes the trace line of a sampled request
+func (t *requestTracer) finish(timing *traceTiming, operation, url string, status int, failed bool, errText string) {
+	if t == nil || timing == nil {
+		return
+	}
+	end := time.Now()
+	if timing.attributed && status > 0 {
+		t.attribution.add(operation, timing, end)
+	}
+	if !timing.logged {
+		return
+	}
+
+	record := map[string]interface{}{
+		"time":       timing.start.Format(time.RFC3339Nano),
+		"runId":      runID,
+		"operation":  operation,
+		"url":        redactText(url),
+		"status":     status,
+		"failed":     failed,
+		"reusedConn": timing.reused,
+		"totalMs":    millisBetween(timing.start, end),
+	}
+	phases := map[string]interface{}{
+		"dnsMs":     millisBetween(timing.dnsStart, timing.dnsDone),
+		"connectMs": millisBetween(timing.connectStart, timing.connectDone),
+		"tlsMs":     millisBetween(timing.tlsStart, timing.tlsDone),
+		"sendMs":    millisBetween(timing.start, timing.wroteRequest),
+		"waitMs":    millisBetween(timing.wroteRequest, timing.firstByte),
+		"readMs":    millisBetween(timing.firstByte, timing.bodyDone),
+	}
+	for key, value := range phases {
+		if value != nil {
+			record[key] = value
+		}
+	}
+	if _, server, ok := parseServerTiming(timing.header); ok {
+		record["serverMs"] = float64(server.Microseconds()) / 1000
+	}
+	if errText != "" {
+		record["error"] = errText
+	}
+
+	line, _ := json.Marshal(record)
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if _, err := t.writer.Write(append(line, '\n')); err != nil {
+		t.writeErrs++
+		return
+	}
+	t.written++
+}
+
+// Close flushes and closes the trace file
+func (t *requestTracer) Close() {
+	if t == nil || t.file == nil {
+		return
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if err := t.writer.Flush(); err != nil {
+		fmt.Printf("Warning: could not write trace file %s: %v\n", t.path, err)
+	}
+	t.file.Close()
+}
+
+// attributionReport returns the latency attribution, nil if it is off
+func (t *requestTracer) attributionReport() map[string]interface{} {
+	if t == nil {
+		return nil
+	}
+	return t.attribution.report(t.config.Attribution)
+}
+
+// report summarizes what was traced
+func (t *requestTracer) report() map[string]interface{} {
+	if t == nil || t.file == nil {
+		return nil
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return map[string]interface{}{
+		"file":               t.path,
+		"sampleRate":         t.config.SampleRate,
+		"maxPerSecond":       t.config.MaxPerSecond,
+		"traced":             t.written,
+		"droppedByRateLimit": t.dropped,
+		"writeErrors":        t.writeErrs,
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"mime/multipart"
+	"net/textproto"
+	"sort"
+	"sync/atomic"
+)
+
+// uploadOperation is the operation name of upload requests
+const uploadOperation = "upload"
+
+// UploadConfig adds multipart/form-data uploads with a synthetic file to
+// the mix, e.g. admin media uploads
+type UploadConfig struct {
+	URL          string
+	Percent      float64           // share of requests that are uploads
+	SizeBytes    int               // file size, default 100 KiB
+	MaxSizeBytes int               // if set, sizes are random between SizeBytes and MaxSizeBytes
+	FileField    string            // form field of the file, default "files"
+	FileName     string            // default "load-test.jpg"
+	ContentType  string            // file content type, default "image/jpeg"
+	Fields       map[string]string // additional form fields
+	Headers      map[string]string // e.g. the admin Authorization header
+}
+
+// uploader builds upload bodies from one shared random payload
+type uploader struct {
+	config  UploadConfig
+	payload []byte
+
+	requests atomic.Int64
+	bytes    atomic.Int64
+}
+
+// newUploader returns nil when uploads are off
+func newUploader(config UploadConfig) (*uploader, error) {
+	if config.Percent <= 0 {
+		return nil, nil
+	}
+	if config.Percent > 100 {
+		return nil, fmt.Errorf("upload percent %.1f is above 100", config.Percent)
+	}
+	if config.URL == "" {
+		return nil, fmt.Errorf("upload URL is required")
+	}
+	if config.SizeBytes <= 0 {
+		config.SizeBytes = 100 * 1024
+	}
+	if config.MaxSizeBytes < config.SizeBytes {
+		config.MaxSizeBytes = config.SizeBytes
+	}
+	if config.FileField == "" {
+		config.FileField = "files"
+	}
+	if config.FileName == "" {
+		config.FileName = "load-test.jpg"
+	}
+	if config.ContentType == "" {
+		config.ContentType = "image/jpeg"
+	}
+
+	payload := make([]byte, config.MaxSizeBytes)
+	for i := range payload {
+		payload[i] = byte(rand.Intn(256))
+	}
+	return &uploader{config: config, payload: payload}, nil
+}
+
+// pick reports whether the next request is an upload. A nil uploader never picks.
+func (u *uploader) pick() bool {
+	return u != nil && rand.Float64()*100 < u.config.Percent
+}
+
+// size returns the size of the next file
+func (u *uploader) size() int {
+	if u.config.MaxSizeBytes > u.config.SizeBytes {
+		return u.config.SizeBytes + rand.Intn(u.config.MaxSizeBytes-u.config.SizeBytes+1)
+	}
+	return u.config.SizeBytes
+}
+
+// body writes the form fields, in order, followed by the file, and returns
+// the body with its Content-Type
+func (u *uploader) body(fields [][2]string, fileField string) (*bytes.Buffer, string, error) {
+	size := u.size()
+	buf := bytes.NewBuffer(make([]byte, 0, size+1024))
+	w := multipart.NewWriter(buf)
+
+	for _, field := range fields {
+		if err := w.WriteField(field[0], field[1]); err != nil {
+			return nil, "", err
+		}
+	}
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name=%q; filename=%q`, fileField, u.config.FileName))
+	header.Set("Content-Type", u.config.ContentType)
+	part, err := w.CreatePart(header)
+	if err != nil {
+		return nil, "", err
+	}
+	if _, err := part.Write(u.payload[:size]); err != nil {
+		return nil, "", err
+	}
+	if err := w.Close(); err != nil {
+		return nil, "", err
+	}
+
+	u.requests.Add(1)
+	u.bytes.Add(int64(buf.Len()))
+	return buf, w.FormDataContentType(), nil
+}
+
+// formBody builds a plain multipart upload with the configured fields
+func (u *uploader) formBody() (*bytes.Buffer, string, error) {
+	keys := make([]string, 0, len(u.config.Fields))
+	for key := range u.config.Fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	fields := make([][2]string, 0, len(keys))
+	for _, key := range keys {
+		fields = append(fields, [2]string{key, u.config.Fields[key]})
+	}
+	return u.body(fields, u.config.FileField)
+}
+
+// report summarizes the uploads sent (nil if uploads are off)
+func (u *uploader) report() map[string]interface{} {
+	if u == nil {
+		return nil
+	}
+	requests := u.requests.Load()
+	report := map[string]interface{}{
+		"url":       u.config.URL,
+		"requests":  requests,
+		"bytesSent": u.bytes.Load(),
+	}
+	if requests > 0 {
+		report["averageBytes"] = u.bytes.Load() / requests
+	}
+	return report
+}
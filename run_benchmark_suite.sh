@@ -17,15 +17,15 @@ build_benchmarks() {
   
   # Build Medusa benchmark
   echo "Building Medusa benchmark..."
-  (cd medusa && go build -o ../medusa_benchmark main.go)
+  (cd medusa && go build -o ../medusa_benchmark .)
   
   # Build Saleor benchmark
   echo "Building Saleor benchmark..."
-  (cd saleor && go build -o ../saleor_benchmark main.go)
+  (cd saleor && go build -o ../saleor_benchmark .)
   
   # Build Spree benchmark
   echo "Building Spree benchmark..."
-  (cd spree && go build -o ../spree_benchmark main.go)
+  (cd spree && go build -o ../spree_benchmark .)
   
   echo "All benchmark executables built successfully."
   
@@ -51,7 +51,7 @@ run_benchmark() {
   fi
   
   # Run the benchmark and redirect output to a log file
-  ./${platform}_benchmark -config $platform/$config > "$results_dir/${platform}_output.log" 2>&1 &
+  ./${platform}_benchmark -config $platform/$config -out-dir "$results_dir" > "$results_dir/${platform}_output.log" 2>&1 &
   local pid=$!
   echo "$platform PID: $pid"
   
@@ -166,13 +166,15 @@ run_tests_for_duration() {
     echo -e "${YELLOW}No valid benchmark processes were started${NC}"
   fi
   
-  # Check for result files and copy them if they exist
+  # Each benchmark writes a timestamped results file plus a ${platform}_latest.json
+  # symlink into the results directory; copy the latest one to the name the
+  # comparison and report steps expect
   for platform in medusa saleor spree; do
-    if [ -f "${platform}_results.json" ]; then
-      echo "Copying ${platform}_results.json to $RESULTS_DIR/"
-      cp ${platform}_results.json "$RESULTS_DIR/" || echo "Warning: Failed to copy ${platform}_results.json"
+    if [ -f "$RESULTS_DIR/${platform}_latest.json" ]; then
+      echo "Copying ${platform}_latest.json to $RESULTS_DIR/${platform}_results.json"
+      cp -L "$RESULTS_DIR/${platform}_latest.json" "$RESULTS_DIR/${platform}_results.json" || echo "Warning: Failed to copy ${platform}_latest.json"
     else
-      echo -e "${YELLOW}Warning: ${platform}_results.json not found${NC}"
+      echo -e "${YELLOW}Warning: ${platform}_latest.json not found in $RESULTS_DIR${NC}"
     fi
   done
   
@@ -287,9 +289,9 @@ fi
 build_benchmarks
 
 # Build compare_results if needed
-if [ ! -x "./compare_results" ] && [ -f "compare_results.go" ]; then
+if [ ! -x "./compare_results" ] && [ -d "compare" ]; then
   echo -e "${GREEN}Building compare_results tool...${NC}"
-  go build -o compare_results compare_results.go
+  (cd compare && go build -o ../compare_results *.go)
 fi
 
 # Run tests for each duration sequentially
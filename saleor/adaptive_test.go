@@ -0,0 +1,198 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func adaptiveConfig(strategy string) *Config {
+	config := &Config{}
+	config.Test.AdaptiveRPS = true
+	ac := &config.Test.AdaptiveConfig
+	ac.Strategy = strategy
+	ac.InitialRPS = 100
+	ac.MinimumRPS = 10
+	ac.MaximumRPS = 200
+	ac.ErrorThresholdPercentage = 2
+	ac.RPSIncreasePercentage = 20
+	ac.RPSDecreasePercentage = 50
+	ac.SamplingWindow = 5 * time.Second
+	ac.StabilizationWindow = 10 * time.Second
+	return config
+}
+
+func newTestController(t *testing.T, config *Config) *adaptiveController {
+	t.Helper()
+	c, err := newAdaptiveController(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Start(time.Now())
+	return c
+}
+
+func TestAdaptiveStrategies(t *testing.T) {
+	now := time.Now()
+	for _, c := range []struct {
+		strategy  string
+		errorRate float64
+		want      float64
+	}{
+		{"step", 0, 120},
+		{"step", 5, 50},
+		{"aimd", 1, 110}, // Increase defaults to a tenth of InitialRPS
+		{"aimd", 3, 50},  // and Decrease to 0.5
+	} {
+		controller := newTestController(t, adaptiveConfig(c.strategy))
+		if got := controller.next(now, 100, c.errorRate, 0); math.Abs(got-c.want) > 1e-9 {
+			t.Errorf("%s at %.0f%% errors: %v RPS, want %v", c.strategy, c.errorRate, got, c.want)
+		}
+	}
+
+	// PID steers to half the threshold by default: up below it, down above,
+	// never more than pidMaxStep at once
+	pid := newTestController(t, adaptiveConfig("pid"))
+	if up := pid.next(now, 100, 0, 0); up <= 100 || up > 100*(1+pidMaxStep) {
+		t.Errorf("PID without errors: %v RPS", up)
+	}
+	pid = newTestController(t, adaptiveConfig("pid"))
+	if down := pid.next(now, 100, 50, 0); down >= 100 || down < 100*(1-pidMaxStep) {
+		t.Errorf("PID far above the setpoint: %v RPS", down)
+	}
+	// A p95 setpoint further off than the error rate steers
+	config := adaptiveConfig("pid")
+	config.Test.AdaptiveConfig.PID.P95 = 100 * time.Millisecond
+	pid = newTestController(t, config)
+	if down := pid.next(now, 100, 0, time.Second); down >= 100 {
+		t.Errorf("PID with p95 10x its setpoint: %v RPS", down)
+	}
+}
+
+func TestAdaptiveBounds(t *testing.T) {
+	now := time.Now()
+	c := newTestController(t, adaptiveConfig("step"))
+	if got := c.next(now, 190, 0, 0); got != 200 {
+		t.Errorf("step above MaximumRPS: %v", got)
+	}
+	if got := c.next(now, 12, 100, 0); got != 10 {
+		t.Errorf("step below MinimumRPS: %v", got)
+	}
+	if !strings.Contains(c.decisions[0].reason, "MaximumRPS") || !strings.Contains(c.decisions[1].reason, "MinimumRPS") {
+		t.Errorf("clamps not in the reasons: %q, %q", c.decisions[0].reason, c.decisions[1].reason)
+	}
+}
+
+func TestAdaptiveConfigErrors(t *testing.T) {
+	for strategy, change := range map[string]func(*Config){
+		"bayesian": func(*Config) {},
+		"aimd":     func(c *Config) { c.Test.AdaptiveConfig.AIMD.Decrease = 1.5 },
+		"pid":      func(c *Config) { c.Test.AdaptiveConfig.ErrorThresholdPercentage = 0 },
+	} {
+		config := adaptiveConfig(strategy)
+		change(config)
+		if _, err := newAdaptiveController(config); err == nil {
+			t.Errorf("%s: no error", strategy)
+		}
+	}
+	if c, err := newAdaptiveController(&Config{}); c != nil || err != nil {
+		t.Errorf("controller without AdaptiveRPS: %v, %v", c, err)
+	}
+}
+
+// seriesOf returns a series started at start with the given requests and
+// failures per second
+func seriesOf(start time.Time, requests, failed []int64) *secondSeries {
+	s := newSecondSeries(AnomalyConfig{})
+	s.Start(start)
+	for i := range requests {
+		s.buckets = append(s.buckets, secondBucket{requests: requests[i], failed: failed[i]})
+	}
+	return s
+}
+
+// The sustained level is the highest one held for the hold time within the
+// threshold, with the achieved rate over the seconds it held
+func TestAdaptiveSustained(t *testing.T) {
+	start := time.Now()
+	c := newTestController(t, adaptiveConfig("step"))
+	c.Start(start)
+	at := func(second int) time.Time { return start.Add(time.Duration(second) * time.Second) }
+	c.decisions = []adaptiveDecision{
+		{time: at(10), oldRPS: 100, newRPS: 120}, // held 10s at <2% errors
+		{time: at(20), oldRPS: 120, newRPS: 60},  // 10s at 5% errors
+		{time: at(25), oldRPS: 60, newRPS: 72},   // only 5s
+	}
+	requests := make([]int64, 25)
+	failed := make([]int64, 25)
+	for i := range requests {
+		switch {
+		case i < 10:
+			requests[i], failed[i] = 95, 1
+		case i < 20:
+			requests[i], failed[i] = 100, 5
+		default:
+			requests[i] = 60
+		}
+	}
+	series := seriesOf(start, requests, failed)
+
+	level, ok := c.sustained(series)
+	if !ok || level.rps != 100 || level.seconds != 10 || level.requests != 950 {
+		t.Fatalf("sustained %+v, %v; want 100 RPS over 10s and 950 requests", level, ok)
+	}
+	report := c.report(series)
+	sustained := report["sustained"].(map[string]interface{})
+	if sustained["achievedRPS"] != "95.00" || sustained["errorRate"] != "1.05%" {
+		t.Errorf("sustained report %v", sustained)
+	}
+	if len(report["decisions"].([]map[string]interface{})) != 3 {
+		t.Errorf("decisions %v", report["decisions"])
+	}
+
+	// No level held within the threshold
+	c.decisions = c.decisions[1:2]
+	if _, ok := c.sustained(series); ok {
+		t.Error("a level above the threshold counted as sustained")
+	}
+}
+
+// The run converges once the rates of the last Windows adjustment windows
+// stay within Tolerance of their mean
+func TestAdaptiveConvergence(t *testing.T) {
+	config := adaptiveConfig("step")
+	config.Test.AdaptiveConfig.Convergence.Windows = 3
+	c := newTestController(t, config)
+	now := time.Now()
+
+	// Bouncing around 100 RPS: 100, 120, 60 is out of the 5% band
+	rps := 100.0
+	for i, errorRate := range []float64{0, 5, 0} {
+		rps = c.next(now.Add(time.Duration(i)*10*time.Second), rps, errorRate, 0)
+	}
+	if c.Converged() {
+		t.Fatalf("converged while bouncing: %+v", c.decisions)
+	}
+	// Climbing from 150 to 180 and 200, then clamped at MaximumRPS: 180 is
+	// out of the band of 180, 200, 200
+	rps = 150
+	for i := 0; i < 5; i++ {
+		rps = c.next(now.Add(time.Duration(3+i)*10*time.Second), rps, 0, 0)
+		if converged := c.Converged(); converged != (i == 4) {
+			t.Fatalf("window %d: converged %v at %v RPS", i, converged, rps)
+		}
+	}
+	convergence := c.report(seriesOf(now, nil, nil))["convergence"].(map[string]interface{})
+	if convergence["rps"] != 200.0 || convergence["offsetSec"] != 70.0 || convergence["tolerance"] != "5%" {
+		t.Errorf("convergence %v", convergence)
+	}
+
+	config.Test.AdaptiveConfig.Convergence.Windows = -1
+	if _, err := newAdaptiveController(config); err == nil {
+		t.Error("negative Windows: no error")
+	}
+	if newTestController(t, adaptiveConfig("step")).report(seriesOf(now, nil, nil))["convergence"] != nil {
+		t.Error("convergence reported while off")
+	}
+}
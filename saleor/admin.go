@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+// AdminOperation is one dashboard query in the admin workload
+type AdminOperation struct {
+	Name   string // operation name in the results, e.g. "admin_orders"
+	Query  string
+	Weight int // relative frequency, default 1
+}
+
+// AdminConfig mixes Saleor dashboard traffic (merchandising, order
+// management) into the storefront load. Dashboard queries need a staff
+// token: Token is sent as a Bearer token, Headers can carry any other auth scheme.
+type AdminConfig struct {
+	Percent    float64
+	Token      string
+	Headers    map[string]string
+	Operations []AdminOperation // default: order, product and customer listings
+}
+
+// defaultAdminOperations lists the queries the Saleor dashboard runs most
+func defaultAdminOperations() []AdminOperation {
+	return []AdminOperation{
+		{Name: "admin_orders", Weight: 3, Query: `query { orders(first: 20, sortBy: {field: NUMBER, direction: DESC}) { edges { node { id number created status paymentStatus total { gross { amount currency } } } } } }`},
+		{Name: "admin_products", Weight: 2, Query: `query { products(first: 20) { edges { node { id name updatedAt productType { name } variants { sku quantityAvailable } } } } }`},
+		{Name: "admin_customers", Weight: 1, Query: `query { customers(first: 20) { edges { node { id email dateJoined orders { totalCount } } } } }`},
+	}
+}
+
+// adminWorkload picks weighted admin operations for a share of the requests
+type adminWorkload struct {
+	percent    float64
+	operations []AdminOperation
+	total      int
+	headers    map[string]string
+}
+
+// newAdminWorkload validates the admin configuration; it returns nil when
+// admin traffic is off
+func newAdminWorkload(config AdminConfig) (*adminWorkload, error) {
+	if config.Percent <= 0 {
+		return nil, nil
+	}
+	if config.Percent > 100 {
+		return nil, fmt.Errorf("admin percent %.1f is above 100", config.Percent)
+	}
+	if config.Token == "" && len(config.Headers) == 0 {
+		return nil, fmt.Errorf("admin traffic needs a Token or auth Headers")
+	}
+
+	operations := config.Operations
+	if len(operations) == 0 {
+		operations = defaultAdminOperations()
+	}
+	a := &adminWorkload{percent: config.Percent, headers: make(map[string]string)}
+	for _, op := range operations {
+		if op.Name == "" || op.Query == "" {
+			return nil, fmt.Errorf("admin operations need a Name and Query")
+		}
+		if op.Weight <= 0 {
+			op.Weight = 1
+		}
+		a.operations = append(a.operations, op)
+		a.total += op.Weight
+	}
+
+	if config.Token != "" {
+		a.headers["Authorization"] = "Bearer " + config.Token
+	}
+	for key, value := range config.Headers {
+		a.headers[key] = value
+	}
+	return a, nil
+}
+
+// pick reports whether the next request goes to the admin API. A nil workload never picks.
+func (a *adminWorkload) pick() bool {
+	return a != nil && rand.Float64()*100 < a.percent
+}
+
+// next returns a weighted random admin operation
+func (a *adminWorkload) next() AdminOperation {
+	n := rand.Intn(a.total)
+	for _, op := range a.operations {
+		if n < op.Weight {
+			return op
+		}
+		n -= op.Weight
+	}
+	return a.operations[len(a.operations)-1]
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// assetOperation is the operation name of the image and static asset requests
+const assetOperation = "asset"
+
+// AssetConfig mixes the images and static files referenced by API responses
+// into the traffic, so the media a page loads is fetched too
+type AssetConfig struct {
+	Percent    float64           // share of all requests
+	Operations []string          // responses asset URLs are extracted from; default the product operations
+	Extensions []string          // file extensions counted as assets, default images, CSS, JS and fonts
+	MaxURLs    int               // distinct URLs kept, default 5000
+	Headers    map[string]string // sent instead of the API headers, e.g. {"Accept": "image/webp"}
+}
+
+const assetSamples = 20000
+
+// assetStore collects asset URLs from responses and records their downloads
+type assetStore struct {
+	config     AssetConfig
+	operations map[string]bool
+	extensions map[string]bool
+
+	mutex     sync.Mutex
+	urls      []string
+	seen      map[string]bool
+	kinds     map[string]int64 // requests by file extension
+	missed    int64            // picked before any asset URL was found
+	fetched   int64
+	failed    int64
+	bytes     int64
+	downloads []time.Duration // up to assetSamples, replaced at random beyond
+}
+
+// newAssetStore validates the configuration; defaults are the operations
+// whose responses are searched unless Operations is set. It returns nil when
+// assets are off.
+func newAssetStore(config AssetConfig, defaults []string) (*assetStore, error) {
+	if config.Percent <= 0 {
+		return nil, nil
+	}
+	if config.Percent > 100 {
+		return nil, fmt.Errorf("asset Percent %.1f is above 100", config.Percent)
+	}
+	if len(config.Operations) == 0 {
+		config.Operations = defaults
+	}
+	if len(config.Extensions) == 0 {
+		config.Extensions = []string{"jpg", "jpeg", "png", "gif", "webp", "avif", "svg", "css", "js", "woff", "woff2"}
+	}
+	if config.MaxURLs <= 0 {
+		config.MaxURLs = 5000
+	}
+
+	s := &assetStore{
+		config:     config,
+		operations: make(map[string]bool),
+		extensions: make(map[string]bool),
+		seen:       make(map[string]bool),
+		kinds:      make(map[string]int64),
+	}
+	for _, op := range config.Operations {
+		s.operations[op] = true
+	}
+	for _, ext := range config.Extensions {
+		s.extensions[strings.ToLower(strings.TrimPrefix(ext, "."))] = true
+	}
+	return s, nil
+}
+
+// wants reports whether asset URLs are extracted from the operation's responses
+func (s *assetStore) wants(operation string) bool {
+	return s != nil && s.operations[baseOperation(operation)]
+}
+
+// capture collects the asset URLs in a successful JSON response; relative
+// URLs resolve against the request URL
+func (s *assetStore) capture(operation string, base *url.URL, body []byte) {
+	if !s.wants(operation) {
+		return
+	}
+	var doc interface{}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return
+	}
+	var found []string
+	s.walk(doc, func(value string) {
+		if s.asset(value) == "" {
+			return
+		}
+		if target, err := base.Parse(value); err == nil && strings.HasPrefix(target.Scheme, "http") {
+			found = append(found, target.String())
+		}
+	})
+	if len(found) == 0 {
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, u := range found {
+		if s.seen[u] {
+			continue
+		}
+		if len(s.urls) < s.config.MaxURLs {
+			s.urls = append(s.urls, u)
+		} else {
+			// Replace a random URL so later pages are represented as well
+			i := rand.Intn(len(s.urls))
+			delete(s.seen, s.urls[i])
+			s.urls[i] = u
+		}
+		s.seen[u] = true
+	}
+}
+
+// walk calls fn with every string in a JSON value
+func (s *assetStore) walk(v interface{}, fn func(string)) {
+	switch t := v.(type) {
+	case string:
+		fn(t)
+	case map[string]interface{}:
+		for _, value := range t {
+			s.walk(value, fn)
+		}
+	case []interface{}:
+		for _, value := range t {
+			s.walk(value, fn)
+		}
+	}
+}
+
+// asset returns the file extension of an asset URL, or "" if the string is
+// not one
+func (s *assetStore) asset(value string) string {
+	if !strings.HasPrefix(value, "http://") && !strings.HasPrefix(value, "https://") && !strings.HasPrefix(value, "/") {
+		return ""
+	}
+	u, err := url.Parse(value)
+	if err != nil {
+		return ""
+	}
+	ext := strings.ToLower(strings.TrimPrefix(path.Ext(u.Path), "."))
+	if !s.extensions[ext] {
+		return ""
+	}
+	return ext
+}
+
+// pick decides whether the next request fetches an asset and returns its
+// URL. A nil store never picks.
+func (s *assetStore) pick() (string, bool) {
+	if s == nil || rand.Float64()*100 >= s.config.Percent {
+		return "", false
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if len(s.urls) == 0 {
+		s.missed++
+		return "", false
+	}
+	u := s.urls[rand.Intn(len(s.urls))]
+	s.kinds[s.asset(u)]++
+	return u, true
+}
+
+// record counts a finished asset download; duration includes reading the body
+func (s *assetStore) record(bytes int64, duration time.Duration, success bool) {
+	if s == nil {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.fetched++
+	if !success {
+		s.failed++
+		return
+	}
+	s.bytes += bytes
+	if len(s.downloads) < assetSamples {
+		s.downloads = append(s.downloads, duration)
+	} else if i := rand.Int63n(s.fetched); i < assetSamples {
+		s.downloads[i] = duration
+	}
+}
+
+// report summarizes the asset downloads; their time to first byte is in the
+// asset operation's regular metrics
+func (s *assetStore) report() map[string]interface{} {
+	if s == nil {
+		return nil
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	report := map[string]interface{}{
+		"urlsFound": len(s.urls),
+		"requests":  s.fetched,
+		"failed":    s.failed,
+		"bytes":     s.bytes,
+		"byType":    s.kinds,
+	}
+	if s.missed > 0 {
+		// Picked before any asset URL was found; a normal request was sent instead
+		report["missed"] = s.missed
+	}
+	if ok := s.fetched - s.failed; ok > 0 {
+		report["avgBytes"] = s.bytes / ok
+	}
+	if len(s.downloads) > 0 {
+		sorted := append([]time.Duration(nil), s.downloads...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+		report["downloadTime"] = map[string]string{
+			"p50": percentileDuration(sorted, 0.5).String(),
+			"p95": percentileDuration(sorted, 0.95).String(),
+			"p99": percentileDuration(sorted, 0.99).String(),
+		}
+	}
+	return report
+}
+
+// fetchAsset downloads an asset with a plain GET; the GraphQL executor hands
+// asset tasks here
+func (p *WorkerPool) fetchAsset(task Task) {
+	req, err := http.NewRequest("GET", task.URL, nil)
+	if err != nil {
+		errText := fmt.Sprintf("request creation error: %v", err)
+		p.Metrics.AddResult(0, task.Operation, 0, false, &ErrorResponse{
+			Query: task.URL,
+			Time:  time.Now(),
+			Error: errText,
+		})
+		p.finishRequest(nil, task.Operation, task.URL, 0, true, errText)
+		return
+	}
+	for key, value := range task.Headers {
+		req.Header.Set(key, value)
+	}
+	setLoadTestID(req.Header)
+
+	req = withClientDelay(req, task.Delay)
+	req = p.Metrics.Conns.begin(req)
+	req, timing := p.Tracer.begin(req)
+	start := time.Now()
+	resp, err := p.HTTPClient.Do(req)
+	duration := time.Since(start)
+	if err != nil {
+		errResp := &ErrorResponse{
+			Query: task.URL,
+			Time:  time.Now(),
+			Error: fmt.Sprintf("request error: %v", err),
+		}
+		p.Metrics.AddResult(duration, task.Operation, 0, isTimeoutError(err), errResp)
+		p.finishRequest(timing, task.Operation, task.URL, 0, true, errResp.Error)
+		return
+	}
+
+	p.Cache.check(task.Operation, resp.Header)
+
+	// Assets are downloaded in full, like a browser would
+	n, _ := io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	timing.bodyRead(resp.Header)
+	success := statusAccepted(p.Config.Test.SuccessCriteria, task.Operation, resp.StatusCode)
+	p.Assets.record(n, time.Since(start), success)
+	p.Metrics.AddResult(duration, task.Operation, resp.StatusCode, false, nil)
+	p.finishRequest(timing, task.Operation, task.URL, resp.StatusCode, !success, "")
+}
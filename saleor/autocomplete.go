@@ -0,0 +1,179 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// autocompleteOperation is the operation name of the keystroke requests
+const autocompleteOperation = "autocomplete"
+
+// AutocompleteConfig simulates shoppers typing into the search box: each
+// virtual user types a term one keystroke at a time and every keystroke
+// searches the prefix typed so far ("s", "sh", "sho", "shoe")
+type AutocompleteConfig struct {
+	Percent  float64       // share of all requests
+	Users    int           // virtual users typing at the same time, default 20
+	Terms    []string      // search terms users type, picked at random
+	MinChars int           // prefix length of the first search, default 1
+	Delay    time.Duration // between keystrokes, default 150ms
+	Pause    time.Duration // before a user starts the next term, default 3s
+
+	// Search query; the prefix is passed in the variable named Variable
+	// (default "search"), next to the fixed Variables (e.g. the channel)
+	Query     string
+	Variable  string
+	Variables map[string]interface{}
+}
+
+// defaultAutocompleteQuery searches product names like the storefront's search box
+const defaultAutocompleteQuery = `query Autocomplete($search: String, $channel: String) {
+  products(first: 5, channel: $channel, filter: {search: $search}) {
+    edges { node { id name } }
+  }
+}`
+
+// typist is one virtual user typing a term
+type typist struct {
+	term    []rune // nil before the first keystroke of a term
+	typed   int
+	readyAt time.Time
+}
+
+// autocomplete hands out the keystroke requests of the typing users
+type autocomplete struct {
+	config AutocompleteConfig
+
+	mutex      sync.Mutex
+	users      []typist
+	nextUser   int
+	keystrokes map[int]int64 // requests by prefix length
+	terms      int64         // terms typed to the end
+	shortened  int64         // keystrokes sent before the user's delay was over
+}
+
+// newAutocomplete validates the configuration; it returns nil when
+// autocomplete is off
+func newAutocomplete(config AutocompleteConfig) (*autocomplete, error) {
+	if config.Percent <= 0 {
+		return nil, nil
+	}
+	if config.Percent > 100 {
+		return nil, fmt.Errorf("autocomplete Percent %.1f is above 100", config.Percent)
+	}
+	if len(config.Terms) == 0 {
+		return nil, fmt.Errorf("autocomplete needs Terms to type")
+	}
+	if config.Users <= 0 {
+		config.Users = 20
+	}
+	if config.MinChars <= 0 {
+		config.MinChars = 1
+	}
+	if config.Delay <= 0 {
+		config.Delay = 150 * time.Millisecond
+	}
+	if config.Pause <= 0 {
+		config.Pause = 3 * time.Second
+	}
+	if config.Query == "" {
+		config.Query = defaultAutocompleteQuery
+	}
+	if config.Variable == "" {
+		config.Variable = "search"
+	}
+	return &autocomplete{
+		config:     config,
+		users:      make([]typist, config.Users),
+		keystrokes: make(map[int]int64),
+	}, nil
+}
+
+// pick decides whether the next request is a keystroke. A nil autocomplete
+// never picks.
+func (a *autocomplete) pick() bool {
+	return a != nil && rand.Float64()*100 < a.config.Percent
+}
+
+// next types the next keystroke of a user whose delay is over (round robin)
+// and returns the prefix to search. When every user is still waiting, the
+// request rate wins like it does for journeys: the user that would be ready
+// first types early and the keystroke counts as shortened.
+func (a *autocomplete) next() string {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	now := time.Now()
+	i := -1
+	for k := 0; k < len(a.users); k++ {
+		c := (a.nextUser + k) % len(a.users)
+		if !a.users[c].readyAt.After(now) {
+			i = c
+			break
+		}
+		if i < 0 || a.users[c].readyAt.Before(a.users[i].readyAt) {
+			i = c
+		}
+	}
+	if a.users[i].readyAt.After(now) {
+		a.shortened++
+	}
+	a.nextUser = (i + 1) % len(a.users)
+	u := &a.users[i]
+
+	if u.term == nil {
+		u.term = []rune(a.config.Terms[rand.Intn(len(a.config.Terms))])
+		u.typed = a.config.MinChars - 1
+	}
+	u.typed++
+	if u.typed > len(u.term) {
+		u.typed = len(u.term)
+	}
+	prefix := string(u.term[:u.typed])
+	a.keystrokes[u.typed]++
+
+	if u.typed == len(u.term) {
+		a.terms++
+		*u = typist{readyAt: now.Add(a.config.Pause)}
+	} else {
+		u.readyAt = now.Add(a.config.Delay)
+	}
+	return prefix
+}
+
+// variables returns the search variables for a prefix
+func (a *autocomplete) variables(prefix string) map[string]interface{} {
+	variables := make(map[string]interface{}, len(a.config.Variables)+1)
+	for name, value := range a.config.Variables {
+		variables[name] = value
+	}
+	variables[a.config.Variable] = prefix
+	return variables
+}
+
+// report lists the keystrokes sent by prefix length
+func (a *autocomplete) report() map[string]interface{} {
+	if a == nil {
+		return nil
+	}
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	total := int64(0)
+	byLength := make(map[string]int64, len(a.keystrokes))
+	for length, count := range a.keystrokes {
+		byLength[fmt.Sprint(length)] = count
+		total += count
+	}
+	return map[string]interface{}{
+		"users":       len(a.users),
+		"keystrokes":  total,
+		"termsTyped":  a.terms,
+		"prefixChars": byLength,
+		// Keystrokes the target RPS forced out before the delay was over;
+		// add Users if this is a large share
+		"shortenedRequests": a.shortened,
+	}
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// batchOperation is the operation name of a batched HTTP request; the
+// operations inside the batch are accounted individually
+const batchOperation = "batch"
+
+// batchTracker counts batched HTTP requests, as opposed to the operations in them
+type batchTracker struct {
+	mutex     sync.Mutex
+	requests  int64
+	failed    int64
+	items     int64
+	durations []time.Duration
+}
+
+// add records one batched HTTP request
+func (b *batchTracker) add(duration time.Duration, items int, failed bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.requests++
+	b.items += int64(items)
+	if failed {
+		b.failed++
+	}
+	if rand.Float64() < 0.1 { // Store 10% of durations, like the request metrics
+		b.durations = append(b.durations, duration)
+	}
+}
+
+// report summarizes the batched HTTP requests; nil if batching is off
+func (b *batchTracker) report(size int) map[string]interface{} {
+	if size <= 1 {
+		return nil
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	report := map[string]interface{}{
+		"batchSize":          size,
+		"httpRequests":       b.requests,
+		"failedHttpRequests": b.failed,
+		"operations":         b.items,
+	}
+	if len(b.durations) > 0 {
+		sorted := append([]time.Duration(nil), b.durations...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+		report["latency"] = latencyPercentiles(sorted)
+	}
+	return report
+}
+
+// generateBatchTask creates a task carrying size operations, sent as one
+// JSON array the way Apollo's batch link does
+func (g *LoadGenerator) generateBatchTask(size int) Task {
+	batch := Task{Operation: batchOperation}
+	for i := 0; i < size; i++ {
+		batch.Batch = append(batch.Batch, g.generateGraphQLOperation())
+	}
+	return batch
+}
+
+// executeBatch sends a batch and records a result for every operation in it,
+// all with the latency of the HTTP request they shared
+func (p *WorkerPool) executeBatch(task Task) {
+	// Operations inherit the batch's canary/experiment tags
+	suffix := strings.TrimPrefix(task.Operation, batchOperation)
+
+	payload := make([]GraphQLRequest, len(task.Batch))
+	for i, item := range task.Batch {
+		payload[i] = GraphQLRequest{Query: item.Query, Variables: item.Variables}
+	}
+
+	// failAll records the same failure for every operation in the batch
+	failAll := func(duration time.Duration, statusCode int, timedOut bool, body, message string) {
+		for _, item := range task.Batch {
+			errResp := &ErrorResponse{
+				Query:      item.Query,
+				StatusCode: statusCode,
+				Body:       body,
+				Time:       time.Now(),
+				Error:      message,
+			}
+			p.Metrics.AddResult(duration, item.Operation+suffix, statusCode, timedOut, errResp)
+		}
+	}
+
+	reqBody, err := json.Marshal(payload)
+	if err != nil {
+		failAll(0, 0, false, "", fmt.Sprintf("request marshaling error: %v", err))
+		return
+	}
+
+	target := p.GraphQLURL
+	if task.URL != "" {
+		target = task.URL
+	}
+	req, err := http.NewRequest("POST", target, bytes.NewBuffer(reqBody))
+	if err != nil {
+		failAll(0, 0, false, "", fmt.Sprintf("request creation error: %v", err))
+		return
+	}
+	for key, value := range p.Headers {
+		req.Header.Set(key, value)
+	}
+	for key, value := range task.Headers {
+		req.Header.Set(key, value)
+	}
+	setLoadTestID(req.Header)
+
+	req = withClientDelay(req, task.Delay)
+	req = p.Metrics.Conns.begin(req)
+	req, timing := p.Tracer.begin(req)
+	req, redirect := p.Redirects.begin(req, task.Operation)
+	start := time.Now()
+	resp, err := p.HTTPClient.Do(req)
+	duration := time.Since(start)
+	p.Redirects.finish(redirect, task.Operation)
+
+	if err != nil {
+		failAll(duration, 0, isTimeoutError(err), "", fmt.Sprintf("request error: %v", err))
+		p.Batches.add(duration, len(task.Batch), true)
+		p.finishRequest(timing, task.Operation, target, 0, true, err.Error())
+		return
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	timing.bodyRead(resp.Header)
+	if err != nil {
+		failAll(duration, resp.StatusCode, isTimeoutError(err), "", fmt.Sprintf("error reading response: %v", err))
+		p.Batches.add(duration, len(task.Batch), true)
+		p.finishRequest(timing, task.Operation, target, resp.StatusCode, true, err.Error())
+		return
+	}
+
+	// A batched response is an array with one result per operation, in order
+	var results []json.RawMessage
+	if !statusAccepted(p.Config.Test.SuccessCriteria, task.Operation, resp.StatusCode) {
+		failAll(duration, resp.StatusCode, false, string(body), "")
+		p.Batches.add(duration, len(task.Batch), true)
+		p.finishRequest(timing, task.Operation, target, resp.StatusCode, true, "")
+		return
+	} else if err := json.Unmarshal(body, &results); err != nil || len(results) != len(task.Batch) {
+		message := fmt.Sprintf("batch response has %d results for %d operations", len(results), len(task.Batch))
+		if err != nil {
+			message = fmt.Sprintf("error parsing batch response: %v", err)
+		}
+		failAll(duration, resp.StatusCode, false, string(body), message)
+		p.Batches.add(duration, len(task.Batch), true)
+		p.finishRequest(timing, task.Operation, target, resp.StatusCode, true, message)
+		return
+	}
+
+	failed := 0
+	for i, item := range task.Batch {
+		operation := item.Operation + suffix
+
+		var graphqlResp GraphQLResponse
+		var errResp *ErrorResponse
+		if err := json.Unmarshal(results[i], &graphqlResp); err != nil {
+			errResp = &ErrorResponse{
+				Query:      item.Query,
+				StatusCode: resp.StatusCode,
+				Time:       time.Now(),
+				Error:      fmt.Sprintf("error parsing response: %v", err),
+			}
+		} else if len(graphqlResp.Errors) > 0 {
+			var graphqlErrors []string
+			for _, e := range graphqlResp.Errors {
+				graphqlErrors = append(graphqlErrors, e.Message)
+			}
+			errResp = &ErrorResponse{
+				Query:       item.Query,
+				StatusCode:  resp.StatusCode,
+				Body:        string(results[i]),
+				GraphQLErrs: graphqlErrors,
+				Time:        time.Now(),
+			}
+		} else if reason := checkBody(p.Config.Test.SuccessCriteria, operation, resp.StatusCode, results[i]); reason != "" {
+			errResp = &ErrorResponse{
+				Query:      item.Query,
+				StatusCode: resp.StatusCode,
+				Body:       string(results[i]),
+				Time:       time.Now(),
+				Error:      reason,
+			}
+		}
+
+		if errResp != nil {
+			failed++
+			errResp.FinalURL, errResp.Redirects = redirect.finalURL, redirect.hops
+		}
+		p.Metrics.AddOutcome(duration, operation, resp.StatusCode, false, errResp == nil, p.sampled(errResp))
+	}
+
+	p.Batches.add(duration, len(task.Batch), failed > 0)
+	traceErr := ""
+	if failed > 0 {
+		traceErr = fmt.Sprintf("%d of %d operations failed", failed, len(task.Batch))
+	}
+	p.finishRequest(timing, task.Operation, target, resp.StatusCode, failed > 0, traceErr)
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// burst is one group of requests fired back-to-back
+type burst struct {
+	start     time.Time
+	remaining atomic.Int64
+	dropped   atomic.Int64
+	tracker   *burstTracker
+}
+
+// done marks one request of the burst finished; the last one records how long
+// the whole burst took to complete. Safe to call on a nil burst.
+func (b *burst) done() {
+	if b == nil {
+		return
+	}
+	if b.remaining.Add(-1) == 0 {
+		b.tracker.record(time.Since(b.start), b.dropped.Load() > 0)
+	}
+}
+
+// burstTracker collects burst completion latencies separately from request latencies
+type burstTracker struct {
+	mutex       sync.Mutex
+	fired       int64
+	incomplete  int64 // bursts with requests dropped because the queue was full
+	dropped     int64
+	completions []time.Duration
+}
+
+func (t *burstTracker) record(duration time.Duration, incomplete bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if incomplete {
+		t.incomplete++
+		return
+	}
+	t.completions = append(t.completions, duration)
+}
+
+// report summarizes burst completion latency
+func (t *burstTracker) report(size int, interval time.Duration) map[string]interface{} {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	report := map[string]interface{}{
+		"size":             size,
+		"interval":         interval.String(),
+		"burstsFired":      t.fired,
+		"burstsCompleted":  len(t.completions),
+		"burstsIncomplete": t.incomplete,
+		"droppedRequests":  t.dropped,
+	}
+	if len(t.completions) > 0 {
+		sorted := make([]time.Duration, len(t.completions))
+		copy(sorted, t.completions)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+		report["completionLatency"] = map[string]string{
+			"min": sorted[0].String(),
+			"p50": percentileDuration(sorted, 0.5).String(),
+			"p90": percentileDuration(sorted, 0.9).String(),
+			"p99": percentileDuration(sorted, 0.99).String(),
+			"max": sorted[len(sorted)-1].String(),
+		}
+	}
+	return report
+}
+
+// burstSettings returns the burst size and interval with defaults applied
+func burstSettings(config *Config) (int, time.Duration) {
+	size := config.Test.BurstConfig.Size
+	if size < 1 {
+		size = 1
+	}
+	interval := config.Test.BurstConfig.Interval
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	return size, interval
+}
+
+// generateBursts fires BurstConfig.Size requests back-to-back every
+// BurstConfig.Interval until stopped or Test.Duration has passed
+func (g *LoadGenerator) generateBursts() {
+	size, interval := burstSettings(g.Config)
+
+	fire := func() {
+		if g.Blackout.Paused() {
+			return
+		}
+		b := &burst{start: time.Now(), tracker: g.bursts}
+		b.remaining.Store(int64(size))
+		atomic.AddInt64(&g.bursts.fired, 1)
+
+		for i := 0; i < size; i++ {
+			task := g.nextTask()
+			g.assignVariant(&task)
+			task.Burst = b
+			select {
+			case g.Pool.Tasks <- task:
+			default:
+				// Queue is full; the burst can't complete as configured
+				atomic.AddInt64(&g.bursts.dropped, 1)
+				b.dropped.Add(1)
+				b.done()
+			}
+		}
+	}
+
+	fmt.Printf("Burst mode: %d requests every %s\n", size, interval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	fire()
+	for {
+		select {
+		case <-g.StopChan:
+			return
+		case <-ticker.C:
+			if g.Config.Test.Duration > 0 && time.Since(g.testStart) >= g.Config.Test.Duration {
+				fmt.Println("Test duration completed.")
+				return
+			}
+			fire()
+		}
+	}
+}
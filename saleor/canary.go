@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// canarySuffix marks operations sent to the canary so every per-operation
+// metric is also broken down by variant
+const canarySuffix = " [canary]"
+
+// canaryRouter sends a percentage of requests to an alternate base URL
+type canaryRouter struct {
+	base    *url.URL
+	percent float64
+}
+
+// newCanaryRouter returns nil when no canary is configured
+func newCanaryRouter(baseURL string, percent float64) (*canaryRouter, error) {
+	if baseURL == "" || percent <= 0 {
+		return nil, nil
+	}
+	if percent > 100 {
+		return nil, fmt.Errorf("canary percent %.1f is above 100", percent)
+	}
+	base, err := url.Parse(baseURL)
+	if err != nil || base.Scheme == "" || base.Host == "" {
+		return nil, fmt.Errorf("invalid canary base URL %q", baseURL)
+	}
+	return &canaryRouter{base: base, percent: percent}, nil
+}
+
+// pick reports whether the next request goes to the canary. A nil router never picks.
+func (c *canaryRouter) pick() bool {
+	return c != nil && rand.Float64()*100 < c.percent
+}
+
+// rewrite moves target onto the canary's scheme and host, keeping its path and query
+func (c *canaryRouter) rewrite(target string) string {
+	u, err := url.Parse(target)
+	if err != nil {
+		return target
+	}
+	u.Scheme = c.base.Scheme
+	u.Host = c.base.Host
+	return u.String()
+}
+
+// variantOf returns the variant an operation name was tagged with, e.g.
+// "products [canary] [newCheckout]" -> "canary+newCheckout"; untagged
+// operations belong to "primary"
+func variantOf(op string) string {
+	var tags []string
+	for strings.HasSuffix(op, "]") {
+		open := strings.LastIndex(op, " [")
+		if open < 0 {
+			break
+		}
+		tags = append([]string{op[open+2 : len(op)-1]}, tags...)
+		op = op[:open]
+	}
+	if len(tags) == 0 {
+		return "primary"
+	}
+	return strings.Join(tags, "+")
+}
+
+// baseOperation strips the variant tags from an operation name
+func baseOperation(op string) string {
+	if i := strings.Index(op, " ["); i >= 0 {
+		return op[:i]
+	}
+	return op
+}
+
+// variantStats groups per-operation counters by variant (primary, canary or
+// A/B experiment). It returns nil when all traffic went to the primary variant.
+func variantStats(counts, failures map[string]int64, durations map[string][]time.Duration) map[string]interface{} {
+	type totals struct {
+		requests, failed int64
+		durations        []time.Duration
+	}
+	variants := make(map[string]*totals)
+	for op, count := range counts {
+		variant := variantOf(op)
+		t, ok := variants[variant]
+		if !ok {
+			t = &totals{}
+			variants[variant] = t
+		}
+		t.requests += count
+		t.failed += failures[op]
+		t.durations = append(t.durations, durations[op]...)
+	}
+	if _, ok := variants["primary"]; len(variants) == 0 || (ok && len(variants) == 1) {
+		return nil
+	}
+
+	stats := make(map[string]interface{}, len(variants))
+	for name, t := range variants {
+		entry := map[string]interface{}{
+			"requests":       t.requests,
+			"failedRequests": t.failed,
+			"errorRate":      fmt.Sprintf("%.2f%%", float64(t.failed)/float64(max(t.requests, 1))*100),
+		}
+		if len(t.durations) > 0 {
+			sort.Slice(t.durations, func(i, j int) bool { return t.durations[i] < t.durations[j] })
+			entry["latency"] = latencyPercentiles(t.durations)
+		}
+		stats[name] = entry
+	}
+	return stats
+}
+
+// routeCanary sends the task to the canary GraphQL endpoint when picked
+func (g *LoadGenerator) routeCanary(task *Task) {
+	// Assets are usually served by a CDN, not the platform
+	if task.Operation != assetOperation && g.Canary.pick() {
+		task.URL = g.Canary.rewrite(g.Config.GraphQLURL)
+		task.Operation += canarySuffix
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+// ClientClass simulates a group of clients further away from the target: each
+// request from a worker in the class is delayed by Delay ± Jitter before it is
+// sent, the way extra network round-trip time would delay it
+type ClientClass struct {
+	Name    string
+	Percent float64       // share of workers in this class
+	Delay   time.Duration // added round-trip time
+	Jitter  time.Duration // uniform random variation of Delay
+}
+
+// validateClientClasses checks names and that the percentages fit in 100
+func validateClientClasses(classes []ClientClass) error {
+	var total float64
+	names := make(map[string]bool)
+	for _, c := range classes {
+		if c.Name == "" || names[c.Name] {
+			return fmt.Errorf("client classes need unique names (got %q)", c.Name)
+		}
+		names[c.Name] = true
+		if c.Percent < 0 || c.Delay < 0 || c.Jitter < 0 {
+			return fmt.Errorf("client class %s has a negative value", c.Name)
+		}
+		total += c.Percent
+	}
+	if total > 100 {
+		return fmt.Errorf("client class percentages add up to %.1f%%, more than 100%%", total)
+	}
+	return nil
+}
+
+// classForWorker deterministically assigns worker i of n to a class so the
+// classes get their share of workers; workers past the listed classes are local (nil)
+func classForWorker(classes []ClientClass, i, n int) *ClientClass {
+	position := (float64(i) + 0.5) / float64(n) * 100
+	for j := range classes {
+		if position < classes[j].Percent {
+			return &classes[j]
+		}
+		position -= classes[j].Percent
+	}
+	return nil
+}
+
+// delay returns the delay for one request
+func (c *ClientClass) delay() time.Duration {
+	d := c.Delay
+	if c.Jitter > 0 {
+		d += time.Duration(rand.Int63n(int64(2*c.Jitter))) - c.Jitter
+	}
+	if d < 0 {
+		d = 0
+	}
+	return d
+}
+
+type clientDelayKey struct{}
+
+// withClientDelay attaches an artificial delay to a request for delayTransport
+func withClientDelay(req *http.Request, delay time.Duration) *http.Request {
+	if delay <= 0 {
+		return req
+	}
+	return req.WithContext(context.WithValue(req.Context(), clientDelayKey{}, delay))
+}
+
+// delayTransport waits out a request's artificial delay before sending it, so
+// the delay is part of the measured latency just like real network distance
+type delayTransport struct {
+	base http.RoundTripper
+}
+
+func (t *delayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if delay, ok := req.Context().Value(clientDelayKey{}).(time.Duration); ok {
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		}
+	}
+	return t.base.RoundTrip(req)
+}
+
+// clientTransport wraps the transport with delay injection when client classes are configured
+func clientTransport(transport http.RoundTripper, config *Config) http.RoundTripper {
+	if len(config.Test.ClientClasses) == 0 {
+		return transport
+	}
+	return &delayTransport{base: transport}
+}
+
+// checkClientBackend validates Test.Client. The fasthttp backend is built
+// for the plain REST loads of the Spree and Medusa runners; GraphQL runs
+// stay on net/http.
+func checkClientBackend(name string) error {
+	switch name {
+	case "", "net/http":
+		return nil
+	case "fasthttp":
+		return fmt.Errorf("the fasthttp client is only available in the REST runners (spree, medusa); the Saleor runner uses net/http")
+	}
+	return fmt.Errorf("unknown client %q (only net/http is available)", name)
+}
+
+// apply sets the task's delay and tags its operation with the class name so
+// metrics are split per class. Safe to call on a nil (local) class.
+func (c *ClientClass) apply(task *Task) {
+	if c == nil {
+		return
+	}
+	task.Delay = c.delay()
+	task.Operation += " [" + c.Name + "]"
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// clockJumpThreshold is the smallest disagreement between the wall clock and
+// the monotonic clock over one check that counts as a jump; NTP slews
+// smaller offsets gradually instead of stepping the clock
+const clockJumpThreshold = 100 * time.Millisecond
+
+// clockJump is one step of the wall clock during the run
+type clockJump struct {
+	at     time.Time     // wall time after the jump
+	offset time.Duration // monotonic time since the start of the test
+	jump   time.Duration // how far the wall clock moved beyond the elapsed time
+}
+
+// clockWatch compares the wall clock with the monotonic clock every second.
+// Durations and stage boundaries are measured on the monotonic clock and are
+// not affected by a jump, but the wall times in the results (start, end,
+// error samples, series timestamps) are, so the jumps are reported.
+type clockWatch struct {
+	start time.Time
+	stop  chan struct{}
+
+	mutex sync.Mutex
+	jumps []clockJump
+}
+
+func newClockWatch() *clockWatch {
+	return &clockWatch{stop: make(chan struct{})}
+}
+
+// Start checks the clocks until Stop
+func (w *clockWatch) Start(start time.Time) {
+	w.start = start
+	go func() {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		last := time.Now()
+		for {
+			select {
+			case <-w.stop:
+				return
+			case now := <-ticker.C:
+				// Round(0) strips the monotonic reading, leaving the wall clock
+				skew := now.Round(0).Sub(last.Round(0)) - now.Sub(last)
+				if skew >= clockJumpThreshold || skew <= -clockJumpThreshold {
+					w.mutex.Lock()
+					w.jumps = append(w.jumps, clockJump{at: now, offset: now.Sub(w.start), jump: skew})
+					w.mutex.Unlock()
+					fmt.Printf("Warning: the wall clock jumped by %s; durations are unaffected, wall times in the results are shifted\n", skew)
+				}
+				last = now
+			}
+		}
+	}()
+}
+
+// Stop ends the checks
+func (w *clockWatch) Stop() {
+	close(w.stop)
+}
+
+// report lists the wall clock jumps; nil when the clock kept steady
+func (w *clockWatch) report() map[string]interface{} {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if len(w.jumps) == 0 {
+		return nil
+	}
+	jumps := make([]map[string]interface{}, 0, len(w.jumps))
+	var total time.Duration
+	for _, j := range w.jumps {
+		total += j.jump
+		jumps = append(jumps, map[string]interface{}{
+			"time":      j.at.Format(time.RFC3339Nano),
+			"offsetSec": math.Round(j.offset.Seconds()*10) / 10,
+			"jump":      j.jump.String(),
+		})
+	}
+	return map[string]interface{}{
+		"jumps":     jumps,
+		"totalJump": total.String(),
+		"note":      "durations use the monotonic clock; wall times after a jump are shifted by it",
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// connInterval is the connection use of one reporting interval
+type connInterval struct {
+	offset time.Duration // end of the interval since the start of the test
+	reused int64
+	opened int64
+}
+
+// connReuse counts the requests that got an idle keep-alive connection
+// against those that had to open a new one, over the test and per reporting
+// interval. A burst of new connections at a ramp step explains a latency
+// spike there; a low reuse rate at steady load points at the transport
+// settings (MaxIdleConnsPerHost) or the target closing connections.
+type connReuse struct {
+	reused   atomic.Int64
+	opened   atomic.Int64
+	idle     atomic.Int64 // reused connections that had been idle
+	idleTime atomic.Int64 // total time those sat idle, in nanoseconds
+
+	start    time.Time
+	interval time.Duration
+	stop     chan struct{}
+
+	mutex      sync.Mutex
+	intervals  []connInterval
+	lastReused int64
+	lastOpened int64
+}
+
+func newConnReuse() *connReuse {
+	return &connReuse{stop: make(chan struct{})}
+}
+
+// begin attaches a client trace that counts the connection the request gets
+func (c *connReuse) begin(req *http.Request) *http.Request {
+	if c == nil {
+		return req
+	}
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			if !info.Reused {
+				c.opened.Add(1)
+				return
+			}
+			c.reused.Add(1)
+			if info.WasIdle {
+				c.idle.Add(1)
+				c.idleTime.Add(int64(info.IdleTime))
+			}
+		},
+	}
+	return req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+}
+
+// Start records an interval every interval until Stop
+func (c *connReuse) Start(start time.Time, interval time.Duration) {
+	if c == nil {
+		return
+	}
+	c.start, c.interval = start, interval
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-c.stop:
+				return
+			case now := <-ticker.C:
+				c.record(now)
+			}
+		}
+	}()
+}
+
+// Stop ends the intervals, recording the last partial one if it had requests
+func (c *connReuse) Stop() {
+	if c == nil {
+		return
+	}
+	close(c.stop)
+	c.mutex.Lock()
+	pending := c.reused.Load() != c.lastReused || c.opened.Load() != c.lastOpened
+	c.mutex.Unlock()
+	if pending {
+		c.record(time.Now())
+	}
+}
+
+// record closes the interval ending at now
+func (c *connReuse) record(now time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	reused, opened := c.reused.Load(), c.opened.Load()
+	c.intervals = append(c.intervals, connInterval{
+		offset: now.Sub(c.start),
+		reused: reused - c.lastReused,
+		opened: opened - c.lastOpened,
+	})
+	c.lastReused, c.lastOpened = reused, opened
+}
+
+// reuseRate formats the share of reused connections
+func reuseRate(reused, opened int64) string {
+	return fmt.Sprintf("%.2f%%", float64(reused)/float64(max(reused+opened, 1))*100)
+}
+
+// current is the connection use of the last reporting interval, for the
+// periodic report
+func (c *connReuse) current() map[string]interface{} {
+	if c == nil {
+		return nil
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if len(c.intervals) == 0 {
+		return nil
+	}
+	last := c.intervals[len(c.intervals)-1]
+	return map[string]interface{}{
+		"reused":    last.reused,
+		"new":       last.opened,
+		"reuseRate": reuseRate(last.reused, last.opened),
+	}
+}
+
+// report summarizes the connection use of the test
+func (c *connReuse) report() map[string]interface{} {
+	if c == nil {
+		return nil
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	reused, opened := c.reused.Load(), c.opened.Load()
+	if reused+opened == 0 {
+		return nil
+	}
+
+	intervals := make([]map[string]interface{}, 0, len(c.intervals))
+	var peak connInterval
+	for _, i := range c.intervals {
+		if i.opened > peak.opened {
+			peak = i
+		}
+		intervals = append(intervals, map[string]interface{}{
+			"offsetSec": math.Round(i.offset.Seconds()*10) / 10,
+			"reused":    i.reused,
+			"new":       i.opened,
+			"reuseRate": reuseRate(i.reused, i.opened),
+		})
+	}
+
+	report := map[string]interface{}{
+		"requests":        reused + opened,
+		"reused":          reused,
+		"new":             opened,
+		"reuseRate":       reuseRate(reused, opened),
+		"intervalSeconds": c.interval.Seconds(),
+		"intervals":       intervals,
+		"note":            "requests that got a connection; each new connection paid for the TCP (and TLS) handshake",
+	}
+	if idle := c.idle.Load(); idle > 0 {
+		report["avgIdleMs"] = math.Round(float64(c.idleTime.Load())/float64(idle)/float64(time.Millisecond)*100) / 100
+	}
+	if peak.opened > 0 {
+		report["peakNewConnections"] = map[string]interface{}{
+			"offsetSec": math.Round(peak.offset.Seconds()*10) / 10,
+			"new":       peak.opened,
+		}
+	}
+	return report
+}
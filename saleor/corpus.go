@@ -0,0 +1,398 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unsafe"
+)
+
+// corpusMagic starts a request corpus file; the version changes with the layout
+const corpusMagic = "WSMCORPUS1\n"
+
+// defaultCorpusRequests is the number of requests built when Requests is unset
+const defaultCorpusRequests = 100000
+
+// CorpusConfig pre-builds the requests of the test into a file that is
+// memory-mapped and replayed in a loop, so the load generator does no
+// operation selection and the workers no JSON marshaling per request
+type CorpusConfig struct {
+	Path     string // corpus file, built when missing or made for another config; empty = off
+	Requests int    // requests in the corpus, default 100000
+}
+
+// requestCorpus replays the requests of a corpus file. Strings of the tasks
+// point into the mapping, which is therefore never unmapped.
+type requestCorpus struct {
+	path     string
+	data     []byte
+	headers  []map[string]string // header sets, decoded once
+	start    int                 // offset of the first record
+	count    int
+	mapped   bool
+	built    bool
+	buildFor time.Duration
+
+	mutex    sync.Mutex
+	position int
+	replayed int64
+	passes   int64
+}
+
+// corpusConflicts lists the features whose requests depend on state at the
+// time they are sent (IDs, users, pages), which a corpus cannot capture
+func corpusConflicts(g *LoadGenerator) []string {
+	var conflicts []string
+	if g.Pool.Uploads != nil {
+		conflicts = append(conflicts, "Upload")
+	}
+	if g.Pool.Entities != nil {
+		conflicts = append(conflicts, "Entities")
+	}
+	if g.Pool.Assets != nil {
+		conflicts = append(conflicts, "Assets")
+	}
+	if g.Pool.Pages != nil {
+		conflicts = append(conflicts, "Pages")
+	}
+	if g.Discovered != nil {
+		conflicts = append(conflicts, "Discovery")
+	}
+	if g.Journeys != nil {
+		conflicts = append(conflicts, "Journey")
+	}
+	if g.Autocomplete != nil {
+		conflicts = append(conflicts, "Autocomplete")
+	}
+	if g.Config.Test.BatchSize > 1 {
+		conflicts = append(conflicts, "BatchSize")
+	}
+	return conflicts
+}
+
+// corpusFingerprint identifies the config a corpus was built for
+func corpusFingerprint(config *Config) (string, error) {
+	data, err := json.Marshal(config)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(append([]byte("saleor\n"), data...))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// loadRequestCorpus opens the corpus of the config, building it first when
+// the file is missing or was built for a different config
+func loadRequestCorpus(config *Config, g *LoadGenerator) (*requestCorpus, error) {
+	settings := config.Test.Corpus
+	if settings.Path == "" {
+		return nil, nil
+	}
+	if conflicts := corpusConflicts(g); len(conflicts) > 0 {
+		return nil, fmt.Errorf("a corpus replays requests built before the test and cannot be combined with %s", strings.Join(conflicts, ", "))
+	}
+	if settings.Requests < 0 {
+		return nil, fmt.Errorf("Requests must not be negative")
+	}
+	if settings.Requests == 0 {
+		settings.Requests = defaultCorpusRequests
+	}
+	fingerprint, err := corpusFingerprint(config)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := openRequestCorpus(settings.Path, fingerprint)
+	if err == nil {
+		return c, nil
+	}
+	if !os.IsNotExist(err) {
+		fmt.Printf("Rebuilding request corpus %s: %v\n", settings.Path, err)
+	}
+	start := time.Now()
+	if err := writeRequestCorpus(settings.Path, fingerprint, settings.Requests, g.generateGraphQLTask); err != nil {
+		return nil, err
+	}
+	if c, err = openRequestCorpus(settings.Path, fingerprint); err != nil {
+		return nil, err
+	}
+	c.built, c.buildFor = true, time.Since(start)
+	return c, nil
+}
+
+// corpusWriter writes the length-prefixed fields of a corpus file
+type corpusWriter struct {
+	w   *bufio.Writer
+	buf [binary.MaxVarintLen64]byte
+}
+
+func (w *corpusWriter) uint(n int) {
+	w.w.Write(w.buf[:binary.PutUvarint(w.buf[:], uint64(n))])
+}
+
+func (w *corpusWriter) string(s string) {
+	w.uint(len(s))
+	w.w.WriteString(s)
+}
+
+// headerSetKey identifies a header set regardless of map order
+func headerSetKey(headers map[string]string) string {
+	keys := make([]string, 0, len(headers))
+	for key := range headers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for _, key := range keys {
+		b.WriteString(key)
+		b.WriteByte(0)
+		b.WriteString(headers[key])
+		b.WriteByte(0)
+	}
+	return b.String()
+}
+
+// writeRequestCorpus builds count requests with generate and writes them to
+// path. The layout is the magic, the config fingerprint, the record count,
+// the distinct header sets and then the records (operation, method, URL,
+// query, serialized request body, header set), all strings prefixed with
+// their uvarint length. GET requests have no body; their URL is built when
+// sent, as persisted queries depend on what the target has seen.
+func writeRequestCorpus(path, fingerprint string, count int, generate func() Task) error {
+	fmt.Printf("Building request corpus %s with %d requests...\n", path, count)
+	tasks := make([]Task, count)
+	setIndex := make(map[string]int)
+	var sets []map[string]string
+	indexes := make([]int, count)
+	for i := range tasks {
+		tasks[i] = generate()
+		key := headerSetKey(tasks[i].Headers)
+		index, ok := setIndex[key]
+		if !ok {
+			index = len(sets)
+			setIndex[key] = index
+			sets = append(sets, tasks[i].Headers)
+		}
+		indexes[i] = index
+	}
+
+	// Headers often carry credentials, so the file is private
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	w := &corpusWriter{w: bufio.NewWriterSize(f, 1<<20)}
+	w.w.WriteString(corpusMagic)
+	w.string(fingerprint)
+	w.uint(count)
+	w.uint(len(sets))
+	for _, set := range sets {
+		w.uint(len(set))
+		for key, value := range set {
+			w.string(key)
+			w.string(value)
+		}
+	}
+	for i, task := range tasks {
+		var body []byte
+		if task.Method == "GET" {
+			if len(task.Variables) > 0 {
+				f.Close()
+				return fmt.Errorf("%s is sent as GET with variables, which the corpus does not keep", task.Operation)
+			}
+		} else if body, err = json.Marshal(GraphQLRequest{Query: task.Query, Variables: task.Variables}); err != nil {
+			f.Close()
+			return fmt.Errorf("marshaling %s: %v", task.Operation, err)
+		}
+		w.string(task.Operation)
+		w.string(task.Method)
+		w.string(task.URL)
+		w.string(task.Query)
+		w.string(string(body))
+		w.uint(indexes[i])
+	}
+	if err := w.w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// corpusReader reads the fields written by corpusWriter
+type corpusReader struct {
+	data     []byte
+	position int
+}
+
+var errCorpusTruncated = errors.New("corpus file is truncated")
+
+func (r *corpusReader) uint() (int, error) {
+	n, size := binary.Uvarint(r.data[r.position:])
+	if size <= 0 || n > uint64(len(r.data)) {
+		return 0, errCorpusTruncated
+	}
+	r.position += size
+	return int(n), nil
+}
+
+// string returns a string sharing the corpus memory
+func (r *corpusReader) string() (string, error) {
+	n, err := r.uint()
+	if err != nil || n == 0 {
+		return "", err
+	}
+	if r.position+n > len(r.data) {
+		return "", errCorpusTruncated
+	}
+	s := unsafe.String(&r.data[r.position], n)
+	r.position += n
+	return s, nil
+}
+
+// openRequestCorpus maps a corpus file and checks it was built for the config
+func openRequestCorpus(path, fingerprint string) (*requestCorpus, error) {
+	data, mapped, err := mapFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(string(data[:min(len(data), len(corpusMagic))]), corpusMagic) {
+		return nil, fmt.Errorf("not a request corpus")
+	}
+	r := &corpusReader{data: data, position: len(corpusMagic)}
+	built, err := r.string()
+	if err != nil {
+		return nil, err
+	}
+	if built != fingerprint {
+		return nil, fmt.Errorf("built for a different config")
+	}
+	c := &requestCorpus{path: path, data: data, mapped: mapped}
+	if c.count, err = r.uint(); err != nil {
+		return nil, err
+	}
+	if c.count == 0 {
+		return nil, fmt.Errorf("corpus has no requests")
+	}
+	sets, err := r.uint()
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < sets; i++ {
+		n, err := r.uint()
+		if err != nil {
+			return nil, err
+		}
+		set := make(map[string]string, n)
+		for j := 0; j < n; j++ {
+			key, err := r.string()
+			if err != nil {
+				return nil, err
+			}
+			if set[key], err = r.string(); err != nil {
+				return nil, err
+			}
+		}
+		c.headers = append(c.headers, set)
+	}
+	c.start, c.position = r.position, r.position
+
+	// Walk the records once so next never meets a malformed one
+	for i := 0; i < c.count; i++ {
+		if _, err := c.read(r); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// read decodes the record at the reader's position
+func (c *requestCorpus) read(r *corpusReader) (Task, error) {
+	var task Task
+	var err error
+	if task.Operation, err = r.string(); err != nil {
+		return task, err
+	}
+	if task.Method, err = r.string(); err != nil {
+		return task, err
+	}
+	if task.URL, err = r.string(); err != nil {
+		return task, err
+	}
+	if task.Query, err = r.string(); err != nil {
+		return task, err
+	}
+	body, err := r.string()
+	if err != nil {
+		return task, err
+	}
+	if body != "" {
+		task.Body = unsafe.Slice(unsafe.StringData(body), len(body))
+	}
+	set, err := r.uint()
+	if err != nil {
+		return task, err
+	}
+	if set >= len(c.headers) {
+		return task, fmt.Errorf("record refers to header set %d of %d", set, len(c.headers))
+	}
+	task.Headers = c.headers[set]
+	return task, nil
+}
+
+// next returns the next request, starting over after the last one. The
+// generator goroutines (-generators) call it concurrently.
+func (c *requestCorpus) next() Task {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	r := corpusReader{data: c.data, position: c.position}
+	task, _ := c.read(&r) // validated when the corpus was opened
+	c.replayed++
+	if c.replayed%int64(c.count) == 0 {
+		c.passes++
+		r.position = c.start
+	}
+	c.position = r.position
+	return task
+}
+
+// report describes the corpus and how much of it was replayed
+func (c *requestCorpus) report() map[string]interface{} {
+	if c == nil {
+		return nil
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	report := map[string]interface{}{
+		"path":         c.path,
+		"requests":     c.count,
+		"bytes":        len(c.data),
+		"memoryMapped": c.mapped,
+		"built":        c.built,
+		"replayed":     c.replayed,
+		"fullPasses":   c.passes,
+	}
+	if c.built {
+		report["buildMs"] = c.buildFor.Milliseconds()
+	}
+	return report
+}
+
+// nextTask returns the next request to send, from the corpus when there is one
+func (g *LoadGenerator) nextTask() Task {
+	if g.Corpus != nil {
+		return g.Corpus.next()
+	}
+	return g.generateGraphQLTask()
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// defaultDiscoveredProductQuery is the specific_product query run with a
+// discovered product ID
+const defaultDiscoveredProductQuery = `query SpecificProduct($id: ID!, $channel: String) {
+	product(id: $id, channel: $channel) {
+		id
+		name
+		description
+		pricing {
+			priceRange {
+				start {
+					gross {
+						amount
+						currency
+					}
+				}
+			}
+		}
+	}
+}`
+
+// DiscoveryConfig collects product and category IDs with a query at startup.
+// The specific_product queries then draw their product from those IDs, a
+// share of them from a small hot set, instead of always asking for the
+// one cached product of Queries.SpecificProduct.
+type DiscoveryConfig struct {
+	Enabled    bool
+	Limit      int     // products and categories fetched, default 100 (the API's maximum page)
+	Channel    string  // channel of the products, default "default-channel"
+	Hot        int     // the first Hot discovered products are the hot set, default 10
+	HotPercent float64 // share of specific_product queries for the hot set, default 80
+	Query      string  // specific_product query taking $id and $channel; default a product query
+}
+
+// discoveredIDs is the pool of discovered IDs
+type discoveredIDs struct {
+	config     DiscoveryConfig
+	products   []string // hot ones first
+	categories []string
+	hotDrawn   atomic.Int64
+	coldDrawn  atomic.Int64
+}
+
+// discoverIDs runs the discovery query; it returns nil when discovery is off.
+// The discovered IDs are also stored in the entity store, if there is one, as
+// kinds "product" and "category" for the entity operations.
+func discoverIDs(config *Config, entities *entityStore) (*discoveredIDs, error) {
+	dc := config.Test.Discovery
+	if !dc.Enabled {
+		return nil, nil
+	}
+	if dc.Limit <= 0 {
+		dc.Limit = 100
+	}
+	if dc.Channel == "" {
+		dc.Channel = "default-channel"
+	}
+	if dc.Hot <= 0 {
+		dc.Hot = 10
+	}
+	if dc.HotPercent <= 0 {
+		dc.HotPercent = 80
+	}
+	if dc.HotPercent > 100 {
+		return nil, fmt.Errorf("discovery HotPercent %.1f is above 100", dc.HotPercent)
+	}
+	if dc.Query == "" {
+		dc.Query = defaultDiscoveredProductQuery
+	}
+
+	query := fmt.Sprintf(`{ products(first: %d, channel: %q) { edges { node { id } } } categories(first: %d) { edges { node { id } } } }`,
+		dc.Limit, dc.Channel, dc.Limit)
+	resp, body, err := postGraphQL(&http.Client{Timeout: 15 * time.Second}, config, query)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", config.GraphQLURL, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: status %d", config.GraphQLURL, resp.StatusCode)
+	}
+	type connection struct {
+		Edges []struct {
+			Node struct {
+				ID string `json:"id"`
+			} `json:"node"`
+		} `json:"edges"`
+	}
+	var result struct {
+		Data struct {
+			Products   connection `json:"products"`
+			Categories connection `json:"categories"`
+		} `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("discovery response is not JSON: %v", err)
+	}
+	if len(result.Errors) > 0 {
+		return nil, fmt.Errorf("discovery query failed: %s", result.Errors[0].Message)
+	}
+
+	d := &discoveredIDs{config: dc}
+	for _, edge := range result.Data.Products.Edges {
+		d.products = append(d.products, edge.Node.ID)
+	}
+	for _, edge := range result.Data.Categories.Edges {
+		d.categories = append(d.categories, edge.Node.ID)
+	}
+	if len(d.products) == 0 {
+		return nil, fmt.Errorf("no products in channel %q", dc.Channel)
+	}
+	if entities != nil {
+		for _, id := range d.products {
+			entities.add("product", id)
+		}
+		for _, id := range d.categories {
+			entities.add("category", id)
+		}
+	}
+	return d, nil
+}
+
+// productTask returns a specific_product query for a discovered product:
+// HotPercent of them for one of the hot set, the rest for one of the others
+func (d *discoveredIDs) productTask() Task {
+	hot := min(d.config.Hot, len(d.products))
+	var id string
+	if hot == len(d.products) || rand.Float64()*100 < d.config.HotPercent {
+		id = d.products[rand.Intn(hot)]
+		d.hotDrawn.Add(1)
+	} else {
+		id = d.products[hot+rand.Intn(len(d.products)-hot)]
+		d.coldDrawn.Add(1)
+	}
+	return Task{
+		Query:     d.config.Query,
+		Variables: map[string]interface{}{"id": id, "channel": d.config.Channel},
+		Operation: "specific_product",
+	}
+}
+
+// report lists the discovered IDs and how often the hot and cold sets were drawn
+func (d *discoveredIDs) report() map[string]interface{} {
+	if d == nil {
+		return nil
+	}
+	return map[string]interface{}{
+		"channel":    d.config.Channel,
+		"products":   len(d.products),
+		"categories": len(d.categories),
+		"hot":        min(d.config.Hot, len(d.products)),
+		"hotPercent": d.config.HotPercent,
+		"hotDrawn":   d.hotDrawn.Load(),
+		"coldDrawn":  d.coldDrawn.Load(),
+	}
+}
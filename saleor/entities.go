@@ -0,0 +1,273 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"sync"
+)
+
+// EntityCapture registers the ID at Field of an operation's successful
+// responses in the store under Kind, e.g. the cart ID of a create-cart call
+type EntityCapture struct {
+	Kind  string // e.g. "cart"
+	Field string // dot path of the ID in the response, e.g. "data.checkoutCreate.checkout.id"
+}
+
+// EntityOperation works on an existing entity drawn from the store, or
+// creates one when Kind is empty; the entity ID is passed as the query
+// variable Variable
+type EntityOperation struct {
+	Name      string  // operation name in the results, e.g. "add_to_checkout"
+	Kind      string  // kind of entity the operation needs; empty for one that creates entities
+	Percent   float64 // share of all requests
+	Query     string
+	Variable  string                 // default "id"
+	Variables map[string]interface{} // other variables of the query
+	Headers   map[string]string
+}
+
+// EntityConfig keeps the IDs of entities created during the test (carts,
+// checkouts, tokens) so that other requests can operate on them, e.g. 90%
+// browsing and 10% working on existing carts
+type EntityConfig struct {
+	Capture    map[string]EntityCapture // per operation
+	Operations []EntityOperation
+	MaxPerKind int    // oldest IDs are dropped beyond this, default 10000
+	File       string // IDs are loaded from and saved to this JSON file if set
+}
+
+// entityStore is the store shared by all workers
+type entityStore struct {
+	config EntityConfig
+	faker  *faker
+
+	mutex      sync.RWMutex
+	ids        map[string][]string
+	loaded     int
+	registered map[string]int64
+	drawn      map[string]int64
+	missed     map[string]int64 // operation picked but no entity of its kind yet
+}
+
+// newEntityStore validates the configuration and loads persisted IDs; it
+// returns nil when nothing is captured or operated on
+func newEntityStore(config EntityConfig, faker *faker) (*entityStore, error) {
+	if len(config.Capture) == 0 && len(config.Operations) == 0 {
+		return nil, nil
+	}
+	if config.MaxPerKind <= 0 {
+		config.MaxPerKind = 10000
+	}
+	total := 0.0
+	for i, op := range config.Operations {
+		if op.Name == "" || op.Query == "" {
+			return nil, fmt.Errorf("entity operation %d needs a Name and Query", i+1)
+		}
+		if op.Variable == "" {
+			config.Operations[i].Variable = "id"
+		}
+		total += op.Percent
+	}
+	if total > 100 {
+		return nil, fmt.Errorf("entity operations add up to %.1f%%, above 100", total)
+	}
+	for op, capture := range config.Capture {
+		if capture.Kind == "" || capture.Field == "" {
+			return nil, fmt.Errorf("entity capture for %s needs a Kind and Field", op)
+		}
+	}
+
+	s := &entityStore{
+		config:     config,
+		faker:      faker,
+		ids:        make(map[string][]string),
+		registered: make(map[string]int64),
+		drawn:      make(map[string]int64),
+		missed:     make(map[string]int64),
+	}
+	if config.File != "" {
+		data, err := os.ReadFile(config.File)
+		if err == nil {
+			if err := json.Unmarshal(data, &s.ids); err != nil {
+				return nil, fmt.Errorf("parsing %s: %v", config.File, err)
+			}
+			for _, ids := range s.ids {
+				s.loaded += len(ids)
+			}
+		} else if !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// wants reports whether IDs are captured from the operation's responses
+func (s *entityStore) wants(operation string) bool {
+	if s == nil {
+		return false
+	}
+	_, ok := s.config.Capture[baseOperation(operation)]
+	return ok
+}
+
+// capture registers the entity ID of a successful response
+func (s *entityStore) capture(operation string, body []byte) {
+	if !s.wants(operation) {
+		return
+	}
+	capture := s.config.Capture[baseOperation(operation)]
+	var doc interface{}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return
+	}
+	id, ok := lookupPath(doc, capture.Field)
+	if !ok || id == nil {
+		return
+	}
+	s.add(capture.Kind, fmt.Sprint(id))
+}
+
+// add stores an ID, dropping the oldest beyond MaxPerKind
+func (s *entityStore) add(kind, id string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	ids := append(s.ids[kind], id)
+	if len(ids) > s.config.MaxPerKind {
+		ids = ids[len(ids)-s.config.MaxPerKind:]
+	}
+	s.ids[kind] = ids
+	s.registered[kind]++
+}
+
+// draw returns a random stored ID of the kind
+func (s *entityStore) draw(kind string) (string, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	ids := s.ids[kind]
+	if len(ids) == 0 {
+		return "", false
+	}
+	return ids[rand.Intn(len(ids))], true
+}
+
+// pick decides whether the next request operates on an existing entity. A
+// nil store never picks.
+func (s *entityStore) pick() (EntityOperation, bool) {
+	if s == nil || len(s.config.Operations) == 0 {
+		return EntityOperation{}, false
+	}
+	n := rand.Float64() * 100
+	for _, op := range s.config.Operations {
+		if n >= op.Percent {
+			n -= op.Percent
+			continue
+		}
+		return s.prepare(op)
+	}
+	return EntityOperation{}, false
+}
+
+// named returns the entity operation with the given name, prepared like pick
+// does; Percent 0 operations can only be reached this way (user journeys)
+func (s *entityStore) named(name string) (EntityOperation, bool) {
+	if s == nil {
+		return EntityOperation{}, false
+	}
+	for _, op := range s.config.Operations {
+		if op.Name == name {
+			return s.prepare(op)
+		}
+	}
+	return EntityOperation{}, false
+}
+
+// prepare draws an entity for the operation and puts its ID and the fake
+// data in the variables; false if no entity of its kind is stored yet
+func (s *entityStore) prepare(op EntityOperation) (EntityOperation, bool) {
+	if variables, ok := s.faker.fillValue(op.Variables).(map[string]interface{}); ok {
+		op.Variables = variables
+	}
+	if op.Kind == "" {
+		return op, true
+	}
+	id, ok := s.draw(op.Kind)
+	s.mutex.Lock()
+	if ok {
+		s.drawn[op.Kind]++
+	} else {
+		s.missed[op.Name]++
+	}
+	s.mutex.Unlock()
+	if !ok {
+		return EntityOperation{}, false
+	}
+
+	variables := make(map[string]interface{}, len(op.Variables)+1)
+	for key, value := range op.Variables {
+		variables[key] = value
+	}
+	variables[op.Variable] = id
+	op.Variables = variables
+	return op, true
+}
+
+// save persists the stored IDs to File, if set
+func (s *entityStore) save() error {
+	if s == nil || s.config.File == "" {
+		return nil
+	}
+	s.mutex.RLock()
+	data, err := json.MarshalIndent(s.ids, "", "  ")
+	s.mutex.RUnlock()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.config.File, data, 0644)
+}
+
+// report lists the stored, registered and drawn IDs per kind
+func (s *entityStore) report() map[string]interface{} {
+	if s == nil {
+		return nil
+	}
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	kinds := make(map[string]interface{})
+	add := func(kind string) {
+		kinds[kind] = map[string]interface{}{
+			"stored":     len(s.ids[kind]),
+			"registered": s.registered[kind],
+			"drawn":      s.drawn[kind],
+		}
+	}
+	for kind := range s.ids {
+		add(kind)
+	}
+	for _, capture := range s.config.Capture {
+		add(capture.Kind)
+	}
+	for _, op := range s.config.Operations {
+		if op.Kind != "" {
+			add(op.Kind)
+		}
+	}
+
+	report := map[string]interface{}{
+		"kinds":  kinds,
+		"loaded": s.loaded,
+	}
+	if len(s.missed) > 0 {
+		// Picked before any entity of the kind existed; a normal request was sent instead
+		report["missedOperations"] = s.missed
+	}
+	if s.config.File != "" {
+		report["file"] = s.config.File
+	}
+	if s.faker != nil {
+		report["faker"] = s.faker.report()
+	}
+	return report
+}
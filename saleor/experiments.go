@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+// Experiment is one A/B variant: a header set attached to a share of traffic
+type Experiment struct {
+	Name    string
+	Percent float64
+	Headers map[string]string
+}
+
+// experimentPicker assigns requests to experiments by percentage; the remainder
+// gets no extra headers
+type experimentPicker struct {
+	experiments []Experiment
+}
+
+// newExperimentPicker validates the experiments and returns nil when none are configured
+func newExperimentPicker(experiments []Experiment) (*experimentPicker, error) {
+	if len(experiments) == 0 {
+		return nil, nil
+	}
+
+	var total float64
+	names := make(map[string]bool)
+	for _, e := range experiments {
+		if e.Name == "" {
+			return nil, fmt.Errorf("every experiment needs a name")
+		}
+		if names[e.Name] || e.Name == "primary" || e.Name == "canary" {
+			return nil, fmt.Errorf("duplicate or reserved experiment name %q", e.Name)
+		}
+		names[e.Name] = true
+		if e.Percent < 0 {
+			return nil, fmt.Errorf("experiment %s has a negative percent", e.Name)
+		}
+		total += e.Percent
+	}
+	if total > 100 {
+		return nil, fmt.Errorf("experiment percentages add up to %.1f%%, more than 100%%", total)
+	}
+	return &experimentPicker{experiments: experiments}, nil
+}
+
+// pick returns the experiment for the next request, or nil for the control group.
+// A nil picker always returns nil.
+func (e *experimentPicker) pick() *Experiment {
+	if e == nil {
+		return nil
+	}
+	roll := rand.Float64() * 100
+	for i := range e.experiments {
+		if roll < e.experiments[i].Percent {
+			return &e.experiments[i]
+		}
+		roll -= e.experiments[i].Percent
+	}
+	return nil
+}
+
+// withHeaders returns a copy of base with extra added, leaving base untouched
+// since it is shared between tasks
+func withHeaders(base, extra map[string]string) map[string]string {
+	headers := make(map[string]string, len(base)+len(extra))
+	for key, value := range base {
+		headers[key] = value
+	}
+	for key, value := range extra {
+		headers[key] = value
+	}
+	return headers
+}
+
+// assignVariant routes the task to the canary and/or an A/B experiment and tags
+// its operation name so metrics are segmented by variant
+func (g *LoadGenerator) assignVariant(task *Task) {
+	if task.Page != nil {
+		// Each of a page's calls is routed on its own
+		for i := range task.Page.calls {
+			g.assignVariant(&task.Page.calls[i])
+		}
+		return
+	}
+	g.routeCanary(task)
+	if e := g.Experiments.pick(); e != nil {
+		task.Headers = withHeaders(task.Headers, e.Headers)
+		task.Operation += " [" + e.Name + "]"
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// fingerprintHeaders are response headers that identify the server software or build
+var fingerprintHeaders = []string{"Server", "X-Powered-By", "Via", "X-Version", "X-App-Version", "X-Api-Version", "X-Build"}
+
+// schemaQuery fetches enough of the GraphQL schema to detect API changes between releases
+const schemaQuery = `{ __schema { queryType { name } mutationType { name } types { name kind fields { name } } } }`
+
+// headerFingerprint collects the identifying headers present on a response
+func headerFingerprint(resp *http.Response) map[string]string {
+	headers := make(map[string]string)
+	for _, name := range fingerprintHeaders {
+		if value := resp.Header.Get(name); value != "" {
+			headers[name] = value
+		}
+	}
+	return headers
+}
+
+// postGraphQL sends a single probe query and returns the response and its body
+func postGraphQL(client *http.Client, config *Config, query string) (*http.Response, []byte, error) {
+	payload, _ := json.Marshal(GraphQLRequest{Query: query})
+	req, err := http.NewRequest("POST", config.GraphQLURL, bytes.NewReader(payload))
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range config.Headers {
+		req.Header.Set(key, value)
+	}
+	setLoadTestID(req.Header)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	return resp, body, err
+}
+
+// fingerprintTarget checks that the GraphQL endpoint is up before any load is
+// sent and records what is running there: the Saleor version (when the API
+// exposes it), a hash of the introspected schema and identifying headers.
+// An unreachable endpoint or a 5xx response is an error.
+func fingerprintTarget(config *Config) (map[string]interface{}, error) {
+	client := &http.Client{Timeout: 15 * time.Second}
+
+	start := time.Now()
+	resp, body, err := postGraphQL(client, config, `{ shop { version } }`)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", config.GraphQLURL, err)
+	}
+	if resp.StatusCode >= 500 {
+		return nil, fmt.Errorf("%s: status %d", config.GraphQLURL, resp.StatusCode)
+	}
+
+	env := map[string]interface{}{
+		"target":       config.GraphQLURL,
+		"probedAt":     start.Format(time.RFC3339),
+		"probeLatency": time.Since(start).String(),
+		"statusCode":   resp.StatusCode,
+		"headers":      headerFingerprint(resp),
+	}
+
+	var shop struct {
+		Data struct {
+			Shop struct {
+				Version string `json:"version"`
+			} `json:"shop"`
+		} `json:"data"`
+	}
+	if json.Unmarshal(body, &shop) == nil && shop.Data.Shop.Version != "" {
+		env["version"] = shop.Data.Shop.Version
+	}
+
+	// Hash the decoded schema rather than the raw body so formatting differences don't matter
+	if _, body, err := postGraphQL(client, config, schemaQuery); err == nil {
+		var schema GraphQLResponse
+		if json.Unmarshal(body, &schema) == nil && schema.Data != nil {
+			canonical, _ := json.Marshal(schema.Data)
+			sum := sha256.Sum256(canonical)
+			env["schemaHash"] = hex.EncodeToString(sum[:])
+		} else {
+			env["schemaHash"] = "unavailable (introspection disabled?)"
+		}
+	}
+
+	return env, nil
+}
+
+// valueOr returns v, or fallback when v is nil
+func valueOr(v interface{}, fallback string) interface{} {
+	if v == nil {
+		return fallback
+	}
+	return v
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Environment variables describing the generator where the host can't tell:
+// the instance type and region off the cloud (or to skip the metadata
+// lookup), and the agents of a distributed run, which wsm k8s sets
+const (
+	instanceTypeEnv = "WSM_INSTANCE_TYPE"
+	regionEnv       = "WSM_REGION"
+	agentsEnv       = "WSM_AGENTS"
+	agentIndexEnv   = "WSM_AGENT_INDEX"
+)
+
+// cloudMetadataTimeout bounds the instance metadata lookup, which off the
+// cloud only waits for the link-local address not to answer
+const cloudMetadataTimeout = 500 * time.Millisecond
+
+// Instance metadata services, variables so tests can point them elsewhere
+var (
+	awsMetadataURL   = "http://169.254.169.254/latest"
+	gcpMetadataURL   = "http://metadata.google.internal/computeMetadata/v1/instance"
+	azureMetadataURL = "http://169.254.169.254/metadata/instance/compute?api-version=2021-02-01"
+)
+
+// describeGeneratorHost returns the hardware and placement of the machine
+// generating the load, so results from different generators aren't compared
+// unknowingly. With cloud set, the instance type and region come from the
+// AWS, GCP or Azure metadata service unless WSM_INSTANCE_TYPE and WSM_REGION
+// give them.
+func describeGeneratorHost(cloud bool) map[string]interface{} {
+	hostname, _ := os.Hostname()
+	host := map[string]interface{}{
+		"hostname": hostname,
+		"os":       runtime.GOOS,
+		"arch":     runtime.GOARCH,
+		"cpus":     runtime.NumCPU(),
+		"agents":   1,
+	}
+	if model := procField("/proc/cpuinfo", "model name"); model != "" {
+		host["cpuModel"] = model
+	}
+	if total := procField("/proc/meminfo", "MemTotal"); total != "" {
+		// "16318480 kB"
+		if kb, err := strconv.ParseInt(strings.Fields(total)[0], 10, 64); err == nil {
+			host["memoryBytes"] = kb * 1024
+		}
+	}
+	if kernel, err := os.ReadFile("/proc/sys/kernel/osrelease"); err == nil {
+		host["kernel"] = strings.TrimSpace(string(kernel))
+	}
+
+	instanceType, region := os.Getenv(instanceTypeEnv), os.Getenv(regionEnv)
+	if cloud && (instanceType == "" || region == "") {
+		if instance := lookupCloudInstance(cloudMetadataTimeout); instance != nil {
+			host["cloud"] = instance.provider
+			if instanceType == "" {
+				instanceType = instance.instanceType
+			}
+			if region == "" {
+				region = instance.region
+			}
+		}
+	}
+	if instanceType != "" {
+		host["instanceType"] = instanceType
+	}
+	if region != "" {
+		host["region"] = region
+	}
+
+	if agents, err := strconv.Atoi(os.Getenv(agentsEnv)); err == nil && agents > 0 {
+		host["agents"] = agents
+		if index, err := strconv.Atoi(os.Getenv(agentIndexEnv)); err == nil {
+			host["agentIndex"] = index
+		}
+	}
+	if os.Getenv("KUBERNETES_SERVICE_HOST") != "" {
+		k8s := map[string]interface{}{"pod": hostname}
+		if node := os.Getenv("NODE_NAME"); node != "" {
+			k8s["node"] = node
+		}
+		host["kubernetes"] = k8s
+	}
+	return host
+}
+
+// summarizeGeneratorHost is the one-line description printed at the start
+func summarizeGeneratorHost(host map[string]interface{}) string {
+	s := fmt.Sprintf("%v, %v CPUs", host["hostname"], host["cpus"])
+	if instanceType, ok := host["instanceType"]; ok {
+		s += fmt.Sprintf(", %v", instanceType)
+	}
+	if region, ok := host["region"]; ok {
+		s += fmt.Sprintf(" in %v", region)
+	}
+	if agents, _ := host["agents"].(int); agents > 1 {
+		s += fmt.Sprintf(", agent %v of %d", host["agentIndex"], agents)
+	}
+	return s
+}
+
+// procField returns the value of the first "key: value" line of a /proc
+// file, or "" where there is none
+func procField(path, key string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		name, value, ok := strings.Cut(scanner.Text(), ":")
+		if ok && strings.TrimSpace(name) == key {
+			return strings.TrimSpace(value)
+		}
+	}
+	return ""
+}
+
+// cloudInstance is what a metadata service says about the generator
+type cloudInstance struct {
+	provider     string
+	instanceType string
+	region       string
+}
+
+// lookupCloudInstance asks the AWS, GCP and Azure metadata services at once
+// and returns the first answer, or nil when none answers within timeout
+func lookupCloudInstance(timeout time.Duration) *cloudInstance {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	client := &http.Client{}
+	lookups := []func(context.Context, *http.Client) (*cloudInstance, error){awsInstance, gcpInstance, azureInstance}
+
+	found := make(chan *cloudInstance, len(lookups))
+	var wg sync.WaitGroup
+	for _, lookup := range lookups {
+		wg.Add(1)
+		go func(lookup func(context.Context, *http.Client) (*cloudInstance, error)) {
+			defer wg.Done()
+			if instance, err := lookup(ctx, client); err == nil {
+				found <- instance
+			}
+		}(lookup)
+	}
+	go func() {
+		wg.Wait()
+		close(found)
+	}()
+	return <-found
+}
+
+// metadataGet fetches one metadata value, failing on anything but a 200
+func metadataGet(ctx context.Context, client *http.Client, method, url string, headers map[string]string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return "", err
+	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s: status %d", url, resp.StatusCode)
+	}
+	return strings.TrimSpace(string(body)), nil
+}
+
+// awsInstance reads the instance type and region over IMDSv2
+func awsInstance(ctx context.Context, client *http.Client) (*cloudInstance, error) {
+	token, err := metadataGet(ctx, client, "PUT", awsMetadataURL+"/api/token", map[string]string{"X-aws-ec2-metadata-token-ttl-seconds": "60"})
+	if err != nil {
+		return nil, err
+	}
+	headers := map[string]string{"X-aws-ec2-metadata-token": token}
+	instanceType, err := metadataGet(ctx, client, "GET", awsMetadataURL+"/meta-data/instance-type", headers)
+	if err != nil {
+		return nil, err
+	}
+	region, err := metadataGet(ctx, client, "GET", awsMetadataURL+"/meta-data/placement/region", headers)
+	if err != nil {
+		return nil, err
+	}
+	return &cloudInstance{provider: "aws", instanceType: instanceType, region: region}, nil
+}
+
+// gcpInstance reads the machine type and zone; the region is the zone
+// without its last part ("us-central1-a" is in "us-central1")
+func gcpInstance(ctx context.Context, client *http.Client) (*cloudInstance, error) {
+	headers := map[string]string{"Metadata-Flavor": "Google"}
+	machineType, err := metadataGet(ctx, client, "GET", gcpMetadataURL+"/machine-type", headers)
+	if err != nil {
+		return nil, err
+	}
+	zone, err := metadataGet(ctx, client, "GET", gcpMetadataURL+"/zone", headers)
+	if err != nil {
+		return nil, err
+	}
+	// Both are paths: projects/123/machineTypes/n2-standard-8, projects/123/zones/us-central1-a
+	machineType = machineType[strings.LastIndex(machineType, "/")+1:]
+	zone = zone[strings.LastIndex(zone, "/")+1:]
+	region := zone
+	if i := strings.LastIndex(zone, "-"); i > 0 {
+		region = zone[:i]
+	}
+	return &cloudInstance{provider: "gcp", instanceType: machineType, region: region}, nil
+}
+
+// azureInstance reads the VM size and location
+func azureInstance(ctx context.Context, client *http.Client) (*cloudInstance, error) {
+	body, err := metadataGet(ctx, client, "GET", azureMetadataURL, map[string]string{"Metadata": "true"})
+	if err != nil {
+		return nil, err
+	}
+	var compute struct {
+		VMSize   string `json:"vmSize"`
+		Location string `json:"location"`
+	}
+	if err := json.Unmarshal([]byte(body), &compute); err != nil {
+		return nil, err
+	}
+	if compute.VMSize == "" {
+		return nil, fmt.Errorf("no vmSize in the Azure metadata")
+	}
+	return &cloudInstance{provider: "azure", instanceType: compute.VMSize, region: compute.Location}, nil
+}
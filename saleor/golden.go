@@ -0,0 +1,313 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// GoldenConfig compares a sample of the responses served under load with a
+// golden response captured before the test, to catch a degraded platform
+// serving wrong or empty catalogs with a 200
+type GoldenConfig struct {
+	SampleRate float64  // share of successful responses compared, 0 turns the checks off
+	Ignore     []string // volatile fields: key names or dot paths without array indices, e.g. "meta.timestamp"
+	File       string   // golden responses are loaded from this JSON file if it exists, and saved to it otherwise
+}
+
+// maxGoldenDiffs caps the differences listed per compared response
+const maxGoldenDiffs = 20
+
+// goldenStats are the comparisons of one operation
+type goldenStats struct {
+	compared int64
+	diverged int64
+	paths    map[string]int64 // differing fields, without array indices
+	examples []map[string]interface{}
+}
+
+// goldenChecker holds the golden responses and the comparison results
+type goldenChecker struct {
+	config   GoldenConfig
+	ignore   map[string]bool
+	golden   map[string]interface{} // parsed golden response per operation
+	loaded   bool                   // read from File instead of captured
+	captured time.Time
+
+	mutex sync.Mutex
+	stats map[string]*goldenStats
+}
+
+// newGoldenChecker loads the golden responses from File, or captures them
+// with the fetchers (one per operation) and saves them to File. It returns nil
+// when the checks are off.
+func newGoldenChecker(config GoldenConfig, fetchers map[string]func() ([]byte, error)) (*goldenChecker, error) {
+	if config.SampleRate <= 0 {
+		return nil, nil
+	}
+	if config.SampleRate > 1 {
+		return nil, fmt.Errorf("golden SampleRate %.2f is above 1", config.SampleRate)
+	}
+	g := &goldenChecker{
+		config:   config,
+		ignore:   make(map[string]bool),
+		golden:   make(map[string]interface{}),
+		captured: time.Now(),
+		stats:    make(map[string]*goldenStats),
+	}
+	for _, field := range config.Ignore {
+		g.ignore[field] = true
+	}
+
+	if config.File != "" {
+		data, err := os.ReadFile(config.File)
+		if err == nil {
+			if err := json.Unmarshal(data, &g.golden); err != nil {
+				return nil, fmt.Errorf("parsing %s: %v", config.File, err)
+			}
+			g.loaded = true
+			return g, nil
+		} else if !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+
+	for op, fetch := range fetchers {
+		body, err := fetch()
+		if err != nil {
+			return nil, fmt.Errorf("capturing the golden %s response: %v", op, err)
+		}
+		var doc interface{}
+		if err := json.Unmarshal(body, &doc); err != nil {
+			return nil, fmt.Errorf("golden %s response is not JSON: %v", op, err)
+		}
+		g.golden[op] = doc
+	}
+	if config.File != "" {
+		data, err := json.MarshalIndent(g.golden, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(config.File, data, 0644); err != nil {
+			return nil, err
+		}
+	}
+	return g, nil
+}
+
+// wants decides whether this response of the operation is compared
+func (g *goldenChecker) wants(operation string) bool {
+	if g == nil {
+		return false
+	}
+	if _, ok := g.golden[baseOperation(operation)]; !ok {
+		return false
+	}
+	return rand.Float64() < g.config.SampleRate
+}
+
+// compare diffs a sampled successful response against the golden one
+func (g *goldenChecker) compare(operation string, body []byte) {
+	golden := g.golden[baseOperation(operation)]
+	var diffs []string
+	var doc interface{}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		diffs = []string{"(response is not JSON)"}
+	} else {
+		g.diff("", "", golden, doc, &diffs)
+	}
+
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	stats, ok := g.stats[operation]
+	if !ok {
+		stats = &goldenStats{paths: make(map[string]int64)}
+		g.stats[operation] = stats
+	}
+	stats.compared++
+	if len(diffs) == 0 {
+		return
+	}
+	stats.diverged++
+	for _, d := range diffs {
+		path, _, _ := strings.Cut(d, ":")
+		stats.paths[stripIndices(path)]++
+	}
+	if len(stats.examples) < 5 {
+		stats.examples = append(stats.examples, map[string]interface{}{
+			"time":  time.Now().Format(time.RFC3339),
+			"diffs": diffs,
+		})
+	}
+}
+
+// diff appends the differences between want and got to diffs. path has array
+// indices ("data.3.id"); field is the same path without them, which Ignore
+// entries are matched against.
+func (g *goldenChecker) diff(path, field string, want, got interface{}, diffs *[]string) {
+	if len(*diffs) >= maxGoldenDiffs {
+		return
+	}
+	switch w := want.(type) {
+	case map[string]interface{}:
+		m, ok := got.(map[string]interface{})
+		if !ok {
+			*diffs = append(*diffs, fmt.Sprintf("%s: expected an object", displayPath(path)))
+			return
+		}
+		keys := make([]string, 0, len(w)+len(m))
+		for key := range w {
+			keys = append(keys, key)
+		}
+		for key := range m {
+			if _, ok := w[key]; !ok {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			childPath, childField := joinPath(path, key), joinPath(field, key)
+			if g.ignore[key] || g.ignore[childField] {
+				continue
+			}
+			wv, inWant := w[key]
+			gv, inGot := m[key]
+			switch {
+			case !inGot:
+				*diffs = append(*diffs, fmt.Sprintf("%s: missing", childPath))
+			case !inWant:
+				*diffs = append(*diffs, fmt.Sprintf("%s: unexpected", childPath))
+			default:
+				g.diff(childPath, childField, wv, gv, diffs)
+			}
+			if len(*diffs) >= maxGoldenDiffs {
+				return
+			}
+		}
+	case []interface{}:
+		a, ok := got.([]interface{})
+		if !ok {
+			*diffs = append(*diffs, fmt.Sprintf("%s: expected an array", displayPath(path)))
+			return
+		}
+		if len(a) != len(w) {
+			// An empty or truncated list is the usual symptom of a degraded catalog
+			*diffs = append(*diffs, fmt.Sprintf("%s: %d items, golden has %d", displayPath(path), len(a), len(w)))
+		}
+		for i := 0; i < len(w) && i < len(a); i++ {
+			g.diff(joinPath(path, fmt.Sprint(i)), field, w[i], a[i], diffs)
+		}
+	default:
+		if fmt.Sprint(want) != fmt.Sprint(got) {
+			*diffs = append(*diffs, fmt.Sprintf("%s: %v, golden %v", displayPath(path), truncateValue(got), truncateValue(want)))
+		}
+	}
+}
+
+// joinPath appends a key to a dot path
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// displayPath names the document root
+func displayPath(path string) string {
+	if path == "" {
+		return "(root)"
+	}
+	return path
+}
+
+// stripIndices drops the array indices of a dot path, so the same field of
+// every list item is counted together
+func stripIndices(path string) string {
+	parts := strings.Split(path, ".")
+	kept := parts[:0]
+	for _, part := range parts {
+		if part != "" && strings.Trim(part, "0123456789") == "" {
+			continue
+		}
+		kept = append(kept, part)
+	}
+	return strings.Join(kept, ".")
+}
+
+// truncateValue shortens long values in the listed differences
+func truncateValue(v interface{}) string {
+	s := fmt.Sprint(v)
+	if len(s) > 60 {
+		return s[:60] + "..."
+	}
+	return s
+}
+
+// report lists the divergence from the golden responses per operation
+func (g *goldenChecker) report() map[string]interface{} {
+	if g == nil {
+		return nil
+	}
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	operations := make(map[string]interface{}, len(g.stats))
+	for operation, s := range g.stats {
+		entry := map[string]interface{}{
+			"compared": s.compared,
+			"diverged": s.diverged,
+		}
+		if s.compared > 0 {
+			entry["divergenceRate"] = fmt.Sprintf("%.2f%%", float64(s.diverged)/float64(s.compared)*100)
+		}
+		if len(s.paths) > 0 {
+			entry["fields"] = s.paths
+			entry["examples"] = s.examples
+		}
+		operations[operation] = entry
+	}
+	report := map[string]interface{}{
+		"sampleRate": g.config.SampleRate,
+		"operations": operations,
+	}
+	if g.loaded {
+		report["file"] = g.config.File
+	} else {
+		report["capturedAt"] = g.captured.Format(time.RFC3339)
+	}
+	return report
+}
+
+// goldenFetchers request the golden response of each built-in query
+func goldenFetchers(config *Config) map[string]func() ([]byte, error) {
+	client := &http.Client{Timeout: 15 * time.Second}
+	queries := map[string]string{
+		"products":         config.Queries.Products,
+		"categories":       config.Queries.Categories,
+		"specific_product": config.Queries.SpecificProduct,
+	}
+	fetchers := make(map[string]func() ([]byte, error))
+	for name, query := range queries {
+		if query == "" {
+			continue
+		}
+		query := query
+		fetchers[name] = func() ([]byte, error) {
+			resp, body, err := postGraphQL(client, config, query)
+			if err != nil {
+				return nil, err
+			}
+			if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+				return nil, fmt.Errorf("status %d", resp.StatusCode)
+			}
+			return body, nil
+		}
+	}
+	return fetchers
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"sync"
+)
+
+// getSuffix marks operations sent as GET so they can be compared with POST
+const getSuffix = " [get]"
+
+// GraphQLGetConfig sends a share of the queries as GET requests with the
+// query in the URL, the way CDN-cacheable GraphQL reads are made
+type GraphQLGetConfig struct {
+	Percent float64
+
+	// Send only the query's SHA-256 hash (Apollo automatic persisted queries);
+	// the full query is included the first time, which registers it
+	PersistedQueries bool
+}
+
+// graphqlGet builds GET requests and remembers which persisted queries the
+// target has seen
+type graphqlGet struct {
+	config     GraphQLGetConfig
+	registered sync.Map // query hash -> true
+}
+
+// newGraphQLGet returns nil when GET requests are off
+func newGraphQLGet(config GraphQLGetConfig) (*graphqlGet, error) {
+	if config.Percent <= 0 {
+		return nil, nil
+	}
+	if config.Percent > 100 {
+		return nil, fmt.Errorf("GET percent %.1f is above 100", config.Percent)
+	}
+	return &graphqlGet{config: config}, nil
+}
+
+// pick reports whether the next query is sent as GET. A nil picker never picks.
+func (g *graphqlGet) pick() bool {
+	return g != nil && rand.Float64()*100 < g.config.Percent
+}
+
+// queryHash returns the hex SHA-256 of a query as used by persisted queries
+func queryHash(query string) string {
+	sum := sha256.Sum256([]byte(query))
+	return hex.EncodeToString(sum[:])
+}
+
+// request builds the GET request for a GraphQL query
+func (g *graphqlGet) request(target string, gql GraphQLRequest) (*http.Request, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, err
+	}
+
+	params := u.Query()
+	if g.config.PersistedQueries {
+		hash := queryHash(gql.Query)
+		extensions, _ := json.Marshal(map[string]interface{}{
+			"persistedQuery": map[string]interface{}{"version": 1, "sha256Hash": hash},
+		})
+		params.Set("extensions", string(extensions))
+		if _, ok := g.registered.Load(hash); !ok {
+			params.Set("query", gql.Query)
+			g.registered.Store(hash, true)
+		}
+	} else {
+		params.Set("query", gql.Query)
+	}
+	if len(gql.Variables) > 0 {
+		variables, err := json.Marshal(gql.Variables)
+		if err != nil {
+			return nil, err
+		}
+		params.Set("variables", string(variables))
+	}
+	u.RawQuery = params.Encode()
+
+	return http.NewRequest("GET", u.String(), nil)
+}
+
+// forget makes the next request for the query register it again, after the
+// target answered PersistedQueryNotFound
+func (g *graphqlGet) forget(query string) {
+	if g != nil {
+		g.registered.Delete(queryHash(query))
+	}
+}
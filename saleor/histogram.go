@@ -0,0 +1,205 @@
+package main
+
+import (
+	"math/bits"
+	"sync"
+	"time"
+)
+
+// histogramSubBuckets is the number of buckets per power of two; a recorded
+// duration is off by at most 1/128 (0.8%) of its value
+const histogramSubBuckets = 128
+
+// durationHistogram counts every recorded duration in log-linear buckets, so
+// memory stays bounded however many requests a run sends
+type durationHistogram struct {
+	counts []int64
+	count  int64
+	sum    time.Duration
+	min    time.Duration
+	max    time.Duration
+}
+
+func newDurationHistogram() *durationHistogram {
+	return &durationHistogram{counts: make([]int64, 58*histogramSubBuckets)}
+}
+
+// histogramBucket returns the bucket of a duration: exact below 128ns, then
+// 128 buckets per power of two
+func histogramBucket(d time.Duration) int {
+	if d < histogramSubBuckets {
+		return int(max(int64(d), 0))
+	}
+	v := uint64(d)
+	shift := bits.Len64(v) - 8
+	return (shift+1)*histogramSubBuckets + int(v>>shift) - histogramSubBuckets
+}
+
+// bucketMidpoint returns the middle of a bucket's range
+func bucketMidpoint(bucket int) time.Duration {
+	if bucket < histogramSubBuckets {
+		return time.Duration(bucket)
+	}
+	shift := bucket/histogramSubBuckets - 1
+	low := uint64(bucket%histogramSubBuckets+histogramSubBuckets) << shift
+	return time.Duration(low + (uint64(1)<<shift)/2)
+}
+
+// record adds one duration
+func (h *durationHistogram) record(d time.Duration) {
+	if h.count == 0 || d < h.min {
+		h.min = d
+	}
+	if d > h.max {
+		h.max = d
+	}
+	h.counts[histogramBucket(d)]++
+	h.count++
+	h.sum += d
+}
+
+// value returns the duration of a bucket, clamped to the exact min and max
+func (h *durationHistogram) value(bucket int) time.Duration {
+	v := bucketMidpoint(bucket)
+	if v < h.min {
+		return h.min
+	}
+	if v > h.max {
+		return h.max
+	}
+	return v
+}
+
+// quantile returns the duration at rank q*count, like percentileDuration does
+// on a sorted sample
+func (h *durationHistogram) quantile(q float64) time.Duration {
+	rank := int64(float64(h.count) * q)
+	if rank >= h.count {
+		rank = h.count - 1
+	}
+	seen := int64(0)
+	for bucket, n := range h.counts {
+		seen += n
+		if seen > rank {
+			return h.value(bucket)
+		}
+	}
+	return h.max
+}
+
+// robustMeans returns the trimmed and winsorized means, cutting trimPercent
+// of the recorded durations from each end
+func (h *durationHistogram) robustMeans(trimPercent float64) (time.Duration, time.Duration, bool) {
+	k := int64(float64(h.count) * trimPercent / 100)
+	if trimPercent <= 0 || h.count-2*k <= 0 {
+		return 0, 0, false
+	}
+	low, high := h.quantile(float64(k)/float64(h.count)), h.quantile(float64(h.count-k-1)/float64(h.count))
+	var trimmed, winsorized float64
+	rank := int64(0)
+	for bucket, n := range h.counts {
+		if n == 0 {
+			continue
+		}
+		// Ranks rank..rank+n-1 fall in this bucket; split them into the
+		// cut-off low end, the cut-off high end and the kept middle
+		below := min(max(k-rank, 0), n)
+		above := min(max(rank+n-(h.count-k), 0), n-below)
+		kept := n - below - above
+		v := float64(h.value(bucket))
+		trimmed += v * float64(kept)
+		winsorized += v*float64(kept) + float64(low)*float64(below) + float64(high)*float64(above)
+		rank += n
+	}
+	return time.Duration(trimmed / float64(h.count-2*k)), time.Duration(winsorized / float64(h.count)), true
+}
+
+// latency returns the latency section of every recorded duration: the
+// reported percentiles, min, max, mean and the trimmed means if set
+func (h *durationHistogram) latency() map[string]string {
+	latency := make(map[string]string, len(reportedPercentiles)+5)
+	if h.count == 0 {
+		return latency
+	}
+	for _, p := range reportedPercentiles {
+		latency[percentileLabel(p)] = h.quantile(p / 100).String()
+	}
+	latency["min"] = h.min.String()
+	latency["max"] = h.max.String()
+	latency["mean"] = (h.sum / time.Duration(h.count)).String()
+	if trimmed, winsorized, ok := h.robustMeans(latencyStatistics.TrimPercent); ok {
+		latency["trimmedMean"] = trimmed.String()
+		latency["winsorizedMean"] = winsorized.String()
+	}
+	return latency
+}
+
+// histogramSet records every duration overall and per operation
+type histogramSet struct {
+	mutex      sync.Mutex
+	overall    *durationHistogram
+	operations map[string]*durationHistogram
+}
+
+// newHistogramSet returns nil unless every duration is to be recorded
+func newHistogramSet(enabled bool) *histogramSet {
+	if !enabled {
+		return nil
+	}
+	return &histogramSet{overall: newDurationHistogram(), operations: make(map[string]*durationHistogram)}
+}
+
+// record adds a request's duration
+func (s *histogramSet) record(operation string, d time.Duration) {
+	if s == nil {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	h, ok := s.operations[operation]
+	if !ok {
+		h = newDurationHistogram()
+		s.operations[operation] = h
+	}
+	h.record(d)
+	s.overall.record(d)
+}
+
+// overallLatency returns the latency section of all requests; false when
+// durations are sampled instead
+func (s *histogramSet) overallLatency() (map[string]string, bool) {
+	if s == nil {
+		return nil, false
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.overall.latency(), s.overall.count > 0
+}
+
+// operationLatency returns the latency section of one operation
+func (s *histogramSet) operationLatency(operation string) (map[string]string, bool) {
+	if s == nil {
+		return nil, false
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	h, ok := s.operations[operation]
+	if !ok || h.count == 0 {
+		return nil, false
+	}
+	return h.latency(), true
+}
+
+// report describes the recording in the results
+func (s *histogramSet) report() map[string]interface{} {
+	if s == nil {
+		return nil
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return map[string]interface{}{
+		"mode":      "histogram",
+		"recorded":  s.overall.count,
+		"precision": "0.8%",
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+)
+
+// decodeJSON decodes with numbers kept as written, so re-encoding gives the
+// same digits
+func decodeJSON(data []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var v interface{}
+	err := decoder.Decode(&v)
+	return v, err
+}
+
+// canonicalJSON encodes a value compactly with sorted keys, the form a
+// results file decodes back to
+func canonicalJSON(v interface{}) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	decoded, err := decodeJSON(data)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(decoded)
+}
+
+// embeddedConfig returns the config the test ran with, secrets redacted
+func embeddedConfig(config *Config) (map[string]interface{}, error) {
+	data, err := json.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+	decoded, err := decodeJSON(data)
+	if err != nil {
+		return nil, err
+	}
+	embedded, _ := redact(decoded).(map[string]interface{})
+	return embedded, nil
+}
+
+// sealResults adds the SHA-256 of the canonicalized results, without the
+// integrity section itself; wsm verify recomputes it from the results file
+func sealResults(report map[string]interface{}) error {
+	delete(report, "integrity")
+	canonical, err := canonicalJSON(report)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(canonical)
+	report["integrity"] = map[string]interface{}{
+		"algorithm":        "sha256",
+		"digest":           hex.EncodeToString(sum[:]),
+		"canonicalization": "compact JSON with sorted keys, numbers as written, without the integrity section",
+	}
+	return nil
+}
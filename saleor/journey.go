@@ -0,0 +1,434 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// JourneyState is one step of a user journey, e.g. "browse" or "cart"
+type JourneyState struct {
+	Operation string             // requested in this state; default the state's name
+	Next      map[string]float64 // probability of moving to each state; the rest ends the session
+}
+
+// JourneyConfig models users as a Markov chain over states instead of
+// independent weighted requests: each virtual user requests its state's
+// operation, then moves on with the configured probabilities until the
+// session ends and a new one begins at Start
+type JourneyConfig struct {
+	Users  int    // virtual users, default 100
+	Start  string // state sessions start in
+	States map[string]JourneyState
+
+	// CSV of dwell times exported from analytics (state,seconds[,count[,next]]),
+	// resolved relative to the config file; users wait that long between steps
+	ThinkTimeCSV string
+}
+
+// journeyUser is one virtual user's session
+type journeyUser struct {
+	state   string // "" before the first request of a session
+	next    string // chosen when the state was entered; "" ends the session
+	readyAt time.Time
+	visited map[string]bool
+	steps   int
+}
+
+// dwellTimes is a weighted distribution of think times
+type dwellTimes struct {
+	values []time.Duration
+	cum    []int64 // cumulative weights
+}
+
+// sample draws a think time
+func (d *dwellTimes) sample() time.Duration {
+	n := rand.Int63n(d.cum[len(d.cum)-1])
+	i := sort.Search(len(d.cum), func(i int) bool { return d.cum[i] > n })
+	return d.values[i]
+}
+
+// journeys advances the virtual users and records the realized funnel
+type journeys struct {
+	config JourneyConfig
+	names  []string               // sorted, for a stable transition order
+	think  map[string]*dwellTimes // by "state>next", or "state>" for any transition
+
+	mutex       sync.Mutex
+	users       []journeyUser
+	nextUser    int
+	visits      map[string]int64
+	transitions map[string]map[string]int64
+	exits       map[string]int64
+	blocked     map[string]int64 // sessions ended because the state's request could not be built
+	sessions    int64            // finished sessions
+	steps       int64            // requests in finished sessions
+	reached     map[string]int64 // finished sessions that visited the state
+	shortened   int64            // requests sent before the user's think time was over
+	thinkTotal  time.Duration
+	thinkCount  int64
+}
+
+// newJourneys validates the journey and loads its think times; known reports
+// whether the runner can request an operation. It returns nil when no states
+// are configured.
+func newJourneys(config JourneyConfig, known func(string) bool, configPath string) (*journeys, error) {
+	if len(config.States) == 0 {
+		return nil, nil
+	}
+	if config.Users <= 0 {
+		config.Users = 100
+	}
+	if _, ok := config.States[config.Start]; !ok {
+		return nil, fmt.Errorf("start state %q is not defined", config.Start)
+	}
+
+	j := &journeys{
+		config:      config,
+		users:       make([]journeyUser, config.Users),
+		visits:      make(map[string]int64),
+		transitions: make(map[string]map[string]int64),
+		exits:       make(map[string]int64),
+		blocked:     make(map[string]int64),
+		reached:     make(map[string]int64),
+	}
+	for name, state := range config.States {
+		j.names = append(j.names, name)
+		if !known(j.operation(name)) {
+			return nil, fmt.Errorf("state %q: unknown operation %q", name, j.operation(name))
+		}
+		total := 0.0
+		for next, p := range state.Next {
+			if _, ok := config.States[next]; !ok {
+				return nil, fmt.Errorf("state %q: transition to undefined state %q", name, next)
+			}
+			if p < 0 {
+				return nil, fmt.Errorf("state %q: negative probability to %q", name, next)
+			}
+			total += p
+		}
+		if total > 1.000001 {
+			return nil, fmt.Errorf("state %q: transition probabilities add up to %.3f, above 1", name, total)
+		}
+	}
+	sort.Strings(j.names)
+
+	if config.ThinkTimeCSV != "" {
+		path := config.ThinkTimeCSV
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(filepath.Dir(configPath), path)
+		}
+		think, err := loadThinkTimes(path, config.States)
+		if err != nil {
+			return nil, err
+		}
+		j.think = think
+	}
+	return j, nil
+}
+
+// loadThinkTimes reads dwell times exported from analytics. Each row is
+// state,seconds[,count[,next]]: count weights the row (default 1) and next
+// limits it to transitions into that state. Seconds may also be a Go
+// duration, MM:SS or HH:MM:SS. A header row is skipped.
+func loadThinkTimes(path string, states map[string]JourneyState) (map[string]*dwellTimes, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	reader.Comment = '#'
+
+	think := make(map[string]*dwellTimes)
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(record) < 2 {
+			return nil, fmt.Errorf("%s line %d: expected state,seconds", path, line)
+		}
+
+		state := strings.TrimSpace(record[0])
+		dwell, err := parseDwellTime(record[1])
+		if err != nil || dwell < 0 {
+			if line == 1 && len(think) == 0 {
+				continue // header
+			}
+			return nil, fmt.Errorf("%s line %d: cannot parse %q", path, line, strings.Join(record, ","))
+		}
+		if _, ok := states[state]; !ok {
+			return nil, fmt.Errorf("%s line %d: unknown state %q", path, line, state)
+		}
+		count := int64(1)
+		if len(record) > 2 && strings.TrimSpace(record[2]) != "" {
+			count, err = strconv.ParseInt(strings.TrimSpace(record[2]), 10, 64)
+			if err != nil || count <= 0 {
+				return nil, fmt.Errorf("%s line %d: invalid count %q", path, line, record[2])
+			}
+		}
+		next := ""
+		if len(record) > 3 {
+			next = strings.TrimSpace(record[3])
+			if _, ok := states[next]; next != "" && !ok {
+				return nil, fmt.Errorf("%s line %d: unknown state %q", path, line, next)
+			}
+		}
+
+		key := state + ">" + next
+		d := think[key]
+		if d == nil {
+			d = &dwellTimes{}
+			think[key] = d
+		}
+		total := count
+		if len(d.cum) > 0 {
+			total += d.cum[len(d.cum)-1]
+		}
+		d.values = append(d.values, dwell)
+		d.cum = append(d.cum, total)
+	}
+	if len(think) == 0 {
+		return nil, fmt.Errorf("%s: no think times", path)
+	}
+	return think, nil
+}
+
+// parseDwellTime parses an exported dwell time. Unlike a profile offset, a
+// single colon separates minutes and seconds.
+func parseDwellTime(s string) (time.Duration, error) {
+	if strings.Count(s, ":") == 1 {
+		return parseProfileOffset("0:" + strings.TrimSpace(s))
+	}
+	return parseProfileOffset(s)
+}
+
+// thinkTime draws the dwell time on state before moving to next ("" for
+// leaving), preferring rows for that transition over rows for the state
+func (j *journeys) thinkTime(state, next string) time.Duration {
+	if d, ok := j.think[state+">"+next]; ok {
+		return d.sample()
+	}
+	if d, ok := j.think[state+">"]; ok {
+		return d.sample()
+	}
+	return 0
+}
+
+// choose draws the state after state, or "" for the end of the session
+func (j *journeys) choose(state string) string {
+	n := rand.Float64()
+	for _, name := range j.names {
+		p, ok := j.config.States[state].Next[name]
+		if !ok {
+			continue
+		}
+		if n < p {
+			return name
+		}
+		n -= p
+	}
+	return ""
+}
+
+// operation returns the operation requested in a state
+func (j *journeys) operation(state string) string {
+	if op := j.config.States[state].Operation; op != "" {
+		return op
+	}
+	return state
+}
+
+// advance moves the next virtual user whose think time is over (round
+// robin) to its next state and returns the user and state. When every user
+// is still thinking, the request rate wins: the user that would be ready
+// first moves early and the request counts as shortened. A nil journey
+// never advances.
+func (j *journeys) advance() (int, string, bool) {
+	if j == nil {
+		return 0, "", false
+	}
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	now := time.Now()
+	i := -1
+	for k := 0; k < len(j.users); k++ {
+		c := (j.nextUser + k) % len(j.users)
+		if !j.users[c].readyAt.After(now) {
+			i = c
+			break
+		}
+		if i < 0 || j.users[c].readyAt.Before(j.users[i].readyAt) {
+			i = c
+		}
+	}
+	if j.users[i].readyAt.After(now) {
+		j.shortened++
+	}
+	j.nextUser = (i + 1) % len(j.users)
+	u := &j.users[i]
+
+	next := ""
+	if u.state != "" {
+		next = u.next
+		if next == "" {
+			j.exits[u.state]++
+			j.finish(u)
+		} else {
+			if j.transitions[u.state] == nil {
+				j.transitions[u.state] = make(map[string]int64)
+			}
+			j.transitions[u.state][next]++
+		}
+	}
+	if next == "" {
+		next = j.config.Start
+		u.visited = make(map[string]bool)
+	}
+
+	u.state = next
+	u.visited[next] = true
+	u.steps++
+	j.visits[next]++
+
+	// Decide now where the user goes next, so the think time can depend on it
+	u.next = j.choose(next)
+	if j.think != nil {
+		think := j.thinkTime(next, u.next)
+		u.readyAt = now.Add(think)
+		j.thinkTotal += think
+		j.thinkCount++
+	}
+	return i, next, true
+}
+
+// block ends a user's session because its state's request could not be
+// built, e.g. no cart exists yet for an add-to-cart step
+func (j *journeys) block(user int, state string) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	j.blocked[state]++
+	j.finish(&j.users[user])
+}
+
+// finish records a finished session and resets the user; callers hold the mutex
+func (j *journeys) finish(u *journeyUser) {
+	j.sessions++
+	j.steps += int64(u.steps)
+	for state := range u.visited {
+		j.reached[state]++
+	}
+	*u = journeyUser{}
+}
+
+// report lists the realized funnel: per state the visits, where users went
+// next, and the share of finished sessions that reached it
+func (j *journeys) report() map[string]interface{} {
+	if j == nil {
+		return nil
+	}
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	states := make(map[string]interface{}, len(j.names))
+	funnel := make([]map[string]interface{}, 0, len(j.names))
+	for _, name := range j.names {
+		state := map[string]interface{}{
+			"operation": j.operation(name),
+			"visits":    j.visits[name],
+			"exits":     j.exits[name],
+		}
+		if j.blocked[name] > 0 {
+			state["blocked"] = j.blocked[name]
+		}
+		if visits := j.visits[name]; visits > 0 && len(j.transitions[name]) > 0 {
+			next := make(map[string]string, len(j.transitions[name]))
+			for to, count := range j.transitions[name] {
+				next[to] = fmt.Sprintf("%.1f%%", float64(count)/float64(visits)*100)
+			}
+			state["next"] = next
+		}
+		states[name] = state
+
+		reached := 0.0
+		if j.sessions > 0 {
+			reached = float64(j.reached[name]) / float64(j.sessions) * 100
+		}
+		funnel = append(funnel, map[string]interface{}{
+			"state":    name,
+			"sessions": j.reached[name],
+			"percent":  fmt.Sprintf("%.1f%%", reached),
+		})
+	}
+	sort.SliceStable(funnel, func(a, b int) bool {
+		return funnel[a]["sessions"].(int64) > funnel[b]["sessions"].(int64)
+	})
+
+	report := map[string]interface{}{
+		"users":            len(j.users),
+		"finishedSessions": j.sessions,
+		"states":           states,
+		"funnel":           funnel,
+	}
+	if j.sessions > 0 {
+		report["avgSessionSteps"] = fmt.Sprintf("%.2f", float64(j.steps)/float64(j.sessions))
+	}
+	if j.think != nil {
+		think := map[string]interface{}{
+			"source": j.config.ThinkTimeCSV,
+			// Requests the target RPS forced out before the think time was
+			// over; add Users if this is a large share
+			"shortenedRequests": j.shortened,
+		}
+		if j.thinkCount > 0 {
+			think["meanThinkTime"] = (j.thinkTotal / time.Duration(j.thinkCount)).String()
+		}
+		report["thinkTime"] = think
+	}
+	return report
+}
+
+// journeyOperation reports whether a journey state can request the operation
+func (g *LoadGenerator) journeyOperation(operation string) bool {
+	switch operation {
+	case "products", "categories", "specific_product":
+		return true
+	}
+	for _, op := range g.Config.Test.Entities.Operations {
+		if op.Name == operation {
+			return true
+		}
+	}
+	return false
+}
+
+// journeyTask builds the request of a journey state
+func (g *LoadGenerator) journeyTask(operation string) (Task, bool) {
+	switch operation {
+	case "products":
+		return Task{Query: g.Config.Queries.Products, Operation: operation}, true
+	case "categories":
+		return Task{Query: g.Config.Queries.Categories, Operation: operation}, true
+	case "specific_product":
+		return Task{Query: g.Config.Queries.SpecificProduct, Operation: operation}, true
+	}
+	if op, ok := g.Pool.Entities.named(operation); ok {
+		return Task{Query: op.Query, Variables: op.Variables, Operation: op.Name, Headers: op.Headers}, true
+	}
+	return Task{}, false
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// checkoutJourney always goes browse > product > cart > checkout and ends
+// there, so the steps don't depend on the random draws
+func checkoutJourney(users int) JourneyConfig {
+	return JourneyConfig{
+		Users: users,
+		Start: "browse",
+		States: map[string]JourneyState{
+			"browse":   {Operation: "products", Next: map[string]float64{"product": 1}},
+			"product":  {Next: map[string]float64{"cart": 1}},
+			"cart":     {Next: map[string]float64{"checkout": 1}},
+			"checkout": {},
+		},
+	}
+}
+
+func newTestJourneys(t *testing.T, config JourneyConfig) *journeys {
+	t.Helper()
+	j, err := newJourneys(config, func(string) bool { return true }, "config.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return j
+}
+
+// advanceStates advances the journey n times and returns the user and state
+// of each step as "user:state"
+func advanceStates(t *testing.T, j *journeys, n int) []string {
+	t.Helper()
+	var steps []string
+	for i := 0; i < n; i++ {
+		user, state, ok := j.advance()
+		if !ok {
+			t.Fatal("journey did not advance")
+		}
+		steps = append(steps, string(rune('0'+user))+":"+state)
+	}
+	return steps
+}
+
+func TestJourneyConfigErrors(t *testing.T) {
+	for name, change := range map[string]func(*JourneyConfig){
+		"undefined start":      func(c *JourneyConfig) { c.Start = "home" },
+		"undefined transition": func(c *JourneyConfig) { c.States["cart"] = JourneyState{Next: map[string]float64{"payment": 0.5}} },
+		"negative probability": func(c *JourneyConfig) { c.States["cart"] = JourneyState{Next: map[string]float64{"browse": -0.1}} },
+		"probabilities above 1": func(c *JourneyConfig) {
+			c.States["cart"] = JourneyState{Next: map[string]float64{"browse": 0.6, "checkout": 0.6}}
+		},
+	} {
+		config := checkoutJourney(1)
+		change(&config)
+		if _, err := newJourneys(config, func(string) bool { return true }, ""); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+
+	if _, err := newJourneys(checkoutJourney(1), func(op string) bool { return op != "cart" }, ""); err == nil || !strings.Contains(err.Error(), `"cart"`) {
+		t.Errorf("unknown operation: %v", err)
+	}
+	if j, err := newJourneys(JourneyConfig{}, nil, ""); j != nil || err != nil {
+		t.Errorf("journey without states: %v, %v", j, err)
+	}
+	var none *journeys
+	if _, _, ok := none.advance(); ok || none.report() != nil {
+		t.Error("a nil journey advanced or reported")
+	}
+}
+
+// A session visits the states in order and a new one starts after the
+// last state
+func TestJourneyStepOrder(t *testing.T) {
+	j := newTestJourneys(t, checkoutJourney(1))
+	got := strings.Join(advanceStates(t, j, 6), " ")
+	if want := "0:browse 0:product 0:cart 0:checkout 0:browse 0:product"; got != want {
+		t.Fatalf("steps %s, want %s", got, want)
+	}
+	if op := j.operation("browse"); op != "products" {
+		t.Errorf("browse requests %q, want products", op)
+	}
+	if op := j.operation("cart"); op != "cart" {
+		t.Errorf("cart requests %q, want the state's name", op)
+	}
+
+	report := j.report()
+	if report["finishedSessions"] != int64(1) || report["avgSessionSteps"] != "4.00" {
+		t.Errorf("sessions %v with %v steps, want 1 with 4.00", report["finishedSessions"], report["avgSessionSteps"])
+	}
+	states := report["states"].(map[string]interface{})
+	browse := states["browse"].(map[string]interface{})
+	if browse["visits"] != int64(2) || browse["next"].(map[string]string)["product"] != "100.0%" {
+		t.Errorf("browse %v", browse)
+	}
+	if checkout := states["checkout"].(map[string]interface{}); checkout["exits"] != int64(1) {
+		t.Errorf("checkout %v, want 1 exit", checkout)
+	}
+	for _, step := range report["funnel"].([]map[string]interface{}) {
+		if step["sessions"] != int64(1) || step["percent"] != "100.0%" {
+			t.Errorf("funnel %v, every state reached by the finished session", step)
+		}
+	}
+}
+
+// Each virtual user keeps its own state between its requests while the
+// users take turns
+func TestJourneyUsersCarryState(t *testing.T) {
+	j := newTestJourneys(t, checkoutJourney(2))
+	got := strings.Join(advanceStates(t, j, 6), " ")
+	if want := "0:browse 1:browse 0:product 1:product 0:cart 1:cart"; got != want {
+		t.Fatalf("steps %s, want %s", got, want)
+	}
+	if report := j.report(); report["users"] != 2 || report["finishedSessions"] != int64(0) {
+		t.Errorf("report %v", report)
+	}
+}
+
+// A state whose request cannot be built ends the session there; the user
+// starts over and the other users carry on
+func TestJourneyBlockedMidJourney(t *testing.T) {
+	j := newTestJourneys(t, checkoutJourney(2))
+	advanceStates(t, j, 4) // both users at product
+	user, state, _ := j.advance()
+	if user != 0 || state != "cart" {
+		t.Fatalf("user %d at %s, want user 0 at cart", user, state)
+	}
+	j.block(user, state)
+
+	got := strings.Join(advanceStates(t, j, 3), " ")
+	if want := "1:cart 0:browse 1:checkout"; got != want {
+		t.Errorf("steps after the block %s, want %s", got, want)
+	}
+
+	report := j.report()
+	cart := report["states"].(map[string]interface{})["cart"].(map[string]interface{})
+	if cart["blocked"] != int64(1) || cart["exits"] != int64(0) {
+		t.Errorf("cart %v, want 1 blocked and no exits", cart)
+	}
+	// The blocked session counts as finished after 3 steps, having reached
+	// browse, product and cart but not checkout
+	if report["finishedSessions"] != int64(1) || report["avgSessionSteps"] != "3.00" {
+		t.Errorf("sessions %v with %v steps", report["finishedSessions"], report["avgSessionSteps"])
+	}
+	for _, step := range report["funnel"].([]map[string]interface{}) {
+		want := int64(1)
+		if step["state"] == "checkout" {
+			want = 0
+		}
+		if step["sessions"] != want {
+			t.Errorf("funnel %v, want %d sessions", step, want)
+		}
+	}
+}
+
+func writeThinkTimes(t *testing.T, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "dwell.csv")
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadThinkTimes(t *testing.T) {
+	states := checkoutJourney(1).States
+	path := writeThinkTimes(t, "state,seconds,count,next\n# exported 2024-05-01\nbrowse,5\nbrowse,0:15,3\ncart,0:01:00,1,checkout\n")
+	think, err := loadThinkTimes(path, states)
+	if err != nil {
+		t.Fatal(err)
+	}
+	browse := think["browse>"]
+	if browse == nil || len(browse.values) != 2 || browse.values[1] != 15*time.Second || browse.cum[1] != 4 {
+		t.Errorf("browse think times %+v", browse)
+	}
+	if cart := think["cart>checkout"]; cart == nil || cart.values[0] != time.Minute {
+		t.Errorf("cart to checkout think times %+v", cart)
+	}
+
+	for _, data := range []string{
+		"state,seconds\n",
+		"home,5\n",
+		"browse,5\nbrowse,soon\n",
+		"browse,5,0\n",
+		"browse,5,1,payment\n",
+		"browse\n",
+	} {
+		if _, err := loadThinkTimes(writeThinkTimes(t, data), states); err == nil {
+			t.Errorf("%q loaded without an error", data)
+		}
+	}
+}
+
+// A transition's own think times win over the state's; the request rate
+// wins over a user still thinking
+func TestJourneyThinkTime(t *testing.T) {
+	config := checkoutJourney(1)
+	config.ThinkTimeCSV = writeThinkTimes(t, "browse,1h\ncart,2h\ncart,3h,1,checkout\n")
+	j := newTestJourneys(t, config)
+
+	if got := j.thinkTime("cart", "checkout"); got != 3*time.Hour {
+		t.Errorf("cart to checkout: %s, want the transition's 3h", got)
+	}
+	if got := j.thinkTime("cart", ""); got != 2*time.Hour {
+		t.Errorf("leaving from cart: %s, want the state's 2h", got)
+	}
+	if got := j.thinkTime("product", "cart"); got != 0 {
+		t.Errorf("product without think times: %s", got)
+	}
+
+	advanceStates(t, j, 2) // browse thinks for 1h, product for none
+	think := j.report()["thinkTime"].(map[string]interface{})
+	if think["shortenedRequests"] != int64(1) || think["meanThinkTime"] != "30m0s" {
+		t.Errorf("think time report %v", think)
+	}
+}
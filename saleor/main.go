@@ -13,6 +13,7 @@ import (
 	"os/signal"
 	"runtime"
 	"sort"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"syscall"
@@ -42,26 +43,194 @@ type Config struct {
 		ReportingSeconds int
 		LogErrors        bool
 		ErrorSampleRate  float64
+
+		// Local IPs or interface names to bind outgoing connections to (round-robin)
+		SourceIPs []string
+
+		// HTTP client backend; only "net/http" (the default) is built in
+		Client string
+
+		// Keep requests that hit the client timeout in the latency percentiles
+		IncludeTimeoutsInLatency bool
+
+		// Maximum in-flight requests per operation, e.g. {"products": 10}
+		ConcurrencyLimits map[string]int
+
+		// Burst mode fires BurstConfig.Size requests back-to-back every
+		// BurstConfig.Interval instead of following RampupStages
+		BurstMode   bool
+		BurstConfig struct {
+			Size     int
+			Interval time.Duration
+		}
+
+		// Send Canary.Percent of each operation's requests to Canary.BaseURL
+		// (scheme and host replaced, path kept); metrics are split per variant
+		Canary struct {
+			BaseURL string
+			Percent float64
+		}
+
+		// Client classes add artificial network delay to a share of the
+		// workers to simulate clients in other regions
+		ClientClasses []ClientClass
+
+		// Bandwidth profiles throttle a share of the connections (e.g. 3G clients)
+		BandwidthProfiles []BandwidthProfile
+
+		// Admin API traffic mixed into the load (needs a Token or Headers)
+		Admin AdminConfig
+
+		// Multipart upload mutations with synthetic files (operation "upload")
+		Upload UploadConfig
+
+		// Send a share of the queries as GET (optionally as persisted query
+		// hashes); these operations are tagged [get] in the results
+		GraphQLGet GraphQLGetConfig
+
+		// Apollo-style batching: send BatchSize operations as one JSON array
+		// per HTTP request (off when 0 or 1)
+		BatchSize int
+
+		// Tags per operation, e.g. {"products": ["read", "critical"]}; the
+		// results group requests, errors and latency by tag
+		Tags map[string][]string
+
+		// Per-operation success rules replacing the default 2xx check, e.g.
+		// {"specificProduct": {"Statuses": [200, 404]}}
+		SuccessCriteria map[string]SuccessRule
+
+		// Whether 3xx responses are followed, globally and per operation
+		Redirects RedirectConfig
+
+		// Sudden surge on one product on top of the regular load
+		FlashSale FlashSaleConfig
+
+		// Virtual users moving through states (browse, product, cart, ...)
+		// with transition probabilities, instead of independent requests
+		Journey JourneyConfig
+
+		// Search-as-you-type: users typing terms keystroke by keystroke
+		Autocomplete AutocompleteConfig
+
+		// Named pages whose API calls are sent concurrently and reported
+		// together, e.g. a product page's product, related and reviews calls
+		Pages []PageConfig
+
+		// Locale and seed of the fake customer data in entity operations
+		Faker FakerConfig
+
+		// IDs of created entities (checkouts, tokens) shared between workers
+		// and the requests operating on them
+		Entities EntityConfig
+
+		// Images and static files found in responses, mixed into the traffic
+		Assets AssetConfig
+
+		// Expected cache TTL per operation; Age and Date headers beyond it
+		// are reported as staleness violations
+		CacheTTLs map[string]CacheExpectation
+
+		// Sampled responses compared with golden responses captured before
+		// the test, ignoring volatile fields
+		Golden GoldenConfig
+
+		// Embedded receiver matching platform webhooks to the requests that
+		// triggered them, for end-to-end async latency
+		Webhooks WebhookConfig
+
+		// Sample of all requests logged as NDJSON with a timing breakdown
+		Trace TraceConfig
+
+		// Requests built and serialized before the test into a memory-mapped
+		// file and replayed, for RPS the per-request generation cannot keep up with
+		Corpus CorpusConfig
+
+		// Prometheus endpoint of the target, scraped for memory/CPU trends
+		TargetMetrics TargetMetricsConfig
+
+		// URLs polled during the run (health checks, Prometheus queries)
+		// whose values are stored next to the load side's latency
+		ExternalMetrics []ExternalSource
+
+		// Detector run over the per-second series after the test, listing
+		// latency jumps and error bursts with their timestamps
+		Anomalies AnomalyConfig
+
+		// Pass/fail criteria checked at the end; a miss exits with status 99
+		Thresholds ThresholdConfig
+
+		// Latency percentiles reported, default [50, 90, 95, 99]
+		Percentiles []float64
+
+		// Trimmed means and percentile confidence intervals
+		Statistics StatisticsConfig
+
+		// Record every request duration in histograms instead of a sample,
+		// for the overall and per-operation latency
+		RecordAllDurations bool
+
+		// Product and category IDs collected at startup; specific_product
+		// queries draw from them with a hot/cold split
+		Discovery DiscoveryConfig
+
+		// Commands or webhooks run before, during (at an offset) or after the
+		// test, e.g. chaos actions; each run is recorded as an annotation
+		Hooks []Hook
+
+		// A/B experiments: each attaches its Headers (e.g. feature-flag cookies)
+		// to Percent of the traffic; metrics are split per experiment
+		Experiments []Experiment
+
+		// CSV of (time offset, target RPS) rows replayed instead of RampupStages,
+		// with every RPS value multiplied by ProfileScale (default 1)
+		ProfileCSV   string
+		ProfileScale float64
+
+		// Named phases run one after another, each with its own load
+		// model (constant, ramp, spike, adaptive), instead of RampupStages
+		Phases []LoadPhase
+
+		// Requests per second per operation, e.g. {"products": 300,
+		// "specific_product": 20}, each paced on its own for Duration
+		// instead of RampupStages and the even split of the queries
+		EndpointRPS map[string]float64
 		
 		// Add these fields for adaptive testing
 		AdaptiveRPS    bool
 		AdaptiveConfig struct {
-			InitialRPS               int64
+			InitialRPS               float64
 			ErrorThresholdPercentage float64
 			RPSIncreasePercentage    float64
 			RPSDecreasePercentage    float64
-			MinimumRPS               int64
-			MaximumRPS               int64
+			MinimumRPS               float64
+			MaximumRPS               float64
 			SamplingWindow           time.Duration
 			StabilizationWindow      time.Duration
+
+			// How the RPS is adjusted: "step" (default), "aimd" or "pid"
+			Strategy string
+			AIMD     AIMDConfig
+			PID      PIDConfig
+
+			// End the run early once the RPS has settled (off by default)
+			Convergence ConvergenceConfig
 		}
+
+		// Follow RampupStages, but hold a rising stage while the error rate
+		// is above AdaptiveConfig.ErrorThresholdPercentage
+		AdaptiveStages bool
 		Duration time.Duration
+
+		// Hard limit on the whole run, whatever the stages, phases or
+		// adaptive settings say; the results are still written (0 = off)
+		MaxDuration time.Duration
 	}
 }
 // Stage represents a load testing stage
 type Stage struct {
 	Duration    time.Duration
-	TargetRPS   int64
+	TargetRPS   float64
 	Description string
 }
 
@@ -87,6 +256,8 @@ type ErrorResponse struct {
 	GraphQLErrs []string
 	Time        time.Time
 	Error       string // If error occurred before getting a response
+	FinalURL    string // Set when redirects were followed
+	Redirects   int
 }
 
 // Metrics tracks test execution metrics
@@ -96,11 +267,146 @@ type Metrics struct {
 	TotalRequests      int64
 	SuccessfulRequests int64
 	FailedRequests     int64
+	TimeoutRequests    int64
 	RequestDurations   []time.Duration
 	StatusCodes        map[int]int64
 	OperationCounts    map[string]int64
+	OperationFailures  map[string]int64
+	OperationDurations map[string][]time.Duration
+	TimeoutCounts      map[string]int64
 	ErrorSamples       []ErrorResponse
 	mutex              sync.RWMutex
+
+	// Timed-out requests are excluded from RequestDurations unless this is set
+	IncludeTimeoutsInLatency bool
+
+	// Per-operation accepted status codes (default 2xx)
+	SuccessRules map[string]SuccessRule
+
+	// Per-operation tags the final report is grouped by
+	Tags map[string][]string
+
+	// Flash sale surge the catalog's results are split around (nil if off)
+	FlashSale *flashSale
+
+	// Per-second series the anomaly detector runs on
+	Series *secondSeries
+
+	// Reused and new connections, over the test and per reporting interval
+	Conns       *connReuse
+	Connections map[string]interface{}
+
+	// Pre-built request corpus and how much of it was replayed
+	Corpus map[string]interface{}
+
+	// Target and queued rate of each operation paced on its own
+	EndpointRPS map[string]interface{}
+
+	// Load and results of each phase of a phased test
+	Phases []map[string]interface{}
+
+	// Every failed request counted by error signature
+	Errors *errorTally
+
+	// Every duration, when recorded in full instead of sampled (nil otherwise)
+	Histograms *histogramSet
+
+	// Target fingerprint recorded by the pre-check (nil if skipped)
+	Environment map[string]interface{}
+
+	// Hardware and placement of the generator the results came from
+	GeneratorHost map[string]interface{}
+
+	// Production hosts of the target policy among the targets (nil if none)
+	TargetPolicy map[string]interface{}
+
+	// Stamp of wsm calibrate for this machine (nil if none)
+	Calibration map[string]interface{}
+
+	// Objectives of the platform's SLO file (nil if none)
+	SLO *serviceObjective
+
+	// Wall clock jumps during the run (nil if none)
+	ClockJumps map[string]interface{}
+
+	// Config the test ran with, secrets redacted; set with -checksum, which
+	// also seals the results with a SHA-256 (nil if off)
+	Config map[string]interface{}
+
+	// Generator resource limits and throttling, filled in at the end of the test
+	Resources map[string]interface{}
+
+	// MaxDuration and whether it stopped the run (nil without one)
+	MaxDuration map[string]interface{}
+
+	// Gaps in the load during blackout windows (nil without a calendar)
+	Blackouts map[string]interface{}
+
+	// Per-operation concurrency caps and the waiting they caused (nil if none)
+	ConcurrencyLimits map[string]interface{}
+
+	// Burst completion latencies in burst mode (nil otherwise)
+	Bursts map[string]interface{}
+
+	// Traffic over bandwidth-limited connections (nil if none)
+	BandwidthProfiles map[string]interface{}
+
+	// Hook runs (e.g. chaos actions) with their offsets into the test
+	Annotations []map[string]interface{}
+
+	// Target resource timeline and trends (nil unless TargetMetrics is set)
+	TargetResources map[string]interface{}
+
+	// Timelines of the polled external sources (nil unless ExternalMetrics is set)
+	ExternalMetrics map[string]interface{}
+
+	// Request trace log summary (nil unless tracing is on)
+	Trace map[string]interface{}
+
+	// Latency split into DNS, connect, TLS, server and transfer time (nil unless Trace.Attribution is set)
+	LatencyAttribution map[string]interface{}
+
+	// Followed redirects per operation (nil if none)
+	Redirects map[string]interface{}
+
+	// Multipart uploads sent (nil unless uploads are on)
+	Uploads map[string]interface{}
+
+	// Batched HTTP requests when BatchSize > 1 (nil otherwise)
+	Batches map[string]interface{}
+
+	// Webhooks matched to the requests that triggered them (nil if off)
+	Webhooks map[string]interface{}
+
+	// Entity IDs stored and drawn (nil unless the entity store is on)
+	Entities map[string]interface{}
+
+	// Asset downloads (nil unless assets are mixed in)
+	Assets map[string]interface{}
+
+	// Page-level latency of the composed pages (nil if none)
+	Pages map[string]interface{}
+
+	// Staleness of cached responses (nil unless CacheTTLs are set)
+	CacheChecks map[string]interface{}
+
+	// Divergence of sampled responses from the golden ones (nil if off)
+	Golden map[string]interface{}
+
+	// Realized funnel of the virtual user journeys (nil if off)
+	Journeys map[string]interface{}
+
+	// Keystrokes sent by the typing users (nil if autocomplete is off)
+	Autocomplete map[string]interface{}
+
+	// Stages held by AdaptiveStages (nil if off)
+	HeldStages map[string]interface{}
+
+	// Every adjustment of an adaptive run (nil unless AdaptiveRPS)
+	Adaptive map[string]interface{}
+
+	// IDs discovered at startup (nil if off)
+	Discovery map[string]interface{}
 }
 
 // NewMetrics creates a new metrics instance
@@ -108,43 +414,113 @@ func NewMetrics() *Metrics {
 	return &Metrics{
 		StartTime:       time.Now(),
 		StatusCodes:     make(map[int]int64),
-		OperationCounts: make(map[string]int64),
+		OperationCounts:    make(map[string]int64),
+		OperationFailures:  make(map[string]int64),
+		OperationDurations: make(map[string][]time.Duration),
+		TimeoutCounts:      make(map[string]int64),
 		ErrorSamples:    make([]ErrorResponse, 0, 100),
+		Errors:          newErrorTally(),
 	}
 }
 
-// AddResult adds a result to the metrics
-func (m *Metrics) AddResult(duration time.Duration, operation string, statusCode int, errResp *ErrorResponse) {
+// AddResult adds a result to the metrics; it fails when the status is not
+// accepted or there is an error response
+func (m *Metrics) AddResult(duration time.Duration, operation string, statusCode int, timedOut bool, errResp *ErrorResponse) {
+	m.AddOutcome(duration, operation, statusCode, timedOut, statusAccepted(m.SuccessRules, operation, statusCode) && errResp == nil, errResp)
+}
+
+// AddOutcome adds a result whose success was already decided (e.g. GraphQL
+// errors in a 200 response). errResp is only the error sample to keep, if
+// any, so a failure counts as one whether or not it was sampled.
+func (m *Metrics) AddOutcome(duration time.Duration, operation string, statusCode int, timedOut bool, success bool, errResp *ErrorResponse) {
 	atomic.AddInt64(&m.TotalRequests, 1)
 
 	m.mutex.Lock()
 	m.OperationCounts[operation]++
 	m.StatusCodes[statusCode]++
+	if timedOut {
+		m.TimeoutCounts[operation]++
+	}
 	m.mutex.Unlock()
 
-	if statusCode >= 200 && statusCode < 300 && errResp == nil {
+	if timedOut {
+		atomic.AddInt64(&m.TimeoutRequests, 1)
+	}
+
+	m.Series.observe(duration, success)
+	m.FlashSale.observe(operation, duration, success)
+	if success {
 		atomic.AddInt64(&m.SuccessfulRequests, 1)
 	} else {
 		atomic.AddInt64(&m.FailedRequests, 1)
 
+		m.mutex.Lock()
+		m.OperationFailures[operation]++
+		m.mutex.Unlock()
+
 		// Store error sample if provided
 		if errResp != nil {
 			m.mutex.Lock()
 			if len(m.ErrorSamples) < 100 { // Limit to 100 samples
-				m.ErrorSamples = append(m.ErrorSamples, *errResp)
+				// Samples end up in the results; URLs and bodies may echo credentials
+				sample := *errResp
+				sample.FinalURL, sample.Body, sample.Error = redactText(sample.FinalURL), redactText(sample.Body), redactText(sample.Error)
+				sample.GraphQLErrs = make([]string, len(errResp.GraphQLErrs))
+				for i, message := range errResp.GraphQLErrs {
+					sample.GraphQLErrs[i] = redactText(message)
+				}
+				m.ErrorSamples = append(m.ErrorSamples, sample)
 			}
 			m.mutex.Unlock()
 		}
 	}
 
+	// A timed-out request's duration is just the client timeout, not a real latency
+	if timedOut && !m.IncludeTimeoutsInLatency {
+		return
+	}
+	m.Histograms.record(operation, duration)
+
 	// Only store a sample of durations to avoid memory issues
 	if rand.Float64() < 0.1 { // Store 10% of durations
 		m.mutex.Lock()
 		m.RequestDurations = append(m.RequestDurations, duration)
+		m.OperationDurations[operation] = append(m.OperationDurations[operation], duration)
 		m.mutex.Unlock()
 	}
 }
 
+// operationStats summarizes requests, failures and latency per operation so the
+// same logical operation can be compared across platforms. The caller must hold the mutex.
+func (m *Metrics) operationStats() map[string]interface{} {
+	stats := make(map[string]interface{}, len(m.OperationCounts))
+	for op, count := range m.OperationCounts {
+		failed := m.OperationFailures[op]
+		opStats := map[string]interface{}{
+			"requests":           count,
+			"successfulRequests": count - failed,
+			"failedRequests":     failed,
+			"errorRate":          fmt.Sprintf("%.2f%%", float64(failed)/float64(max(count, 1))*100),
+		}
+
+		if latency, ok := m.Histograms.operationLatency(op); ok {
+			opStats["latency"] = latency
+		} else if durations := m.OperationDurations[op]; len(durations) > 0 {
+			sorted := make([]time.Duration, len(durations))
+			copy(sorted, durations)
+			sort.Sort(durationSlice(sorted))
+
+			opStats["latency"] = latencyPercentiles(sorted)
+			if confidence := percentileConfidence(sorted); confidence != nil {
+				opStats["latencyConfidence"] = confidence
+			}
+		}
+
+		stats[op] = opStats
+	}
+	return stats
+}
+
 // Calculate percentile from sorted durations
 func percentileDuration(sorted []time.Duration, percentile float64) time.Duration {
 	if len(sorted) == 0 {
@@ -162,26 +538,78 @@ type Task struct {
 	Query     string
 	Variables map[string]interface{}
 	Operation string // For metrics tracking
+	URL       string // Overrides the pool's GraphQL URL (canary requests)
+	Headers   map[string]string // Added to the pool's headers (A/B experiments)
+	Delay     time.Duration     // Artificial client delay (client classes)
+	Burst     *burst // Set when the task is part of a burst
+	Batch     []Task // Operations sent together in one batched request
+	Method    string // "GET" sends the query in the URL (default POST)
+	Upload    bool   // GraphQL multipart upload with a synthetic file
+	Page      *pageLoad // Set when the task loads a page of concurrent calls
+	Result    *pageCall // Filled in when the task is one of a page's calls
+	Body      []byte    // Request serialized when the corpus was built
 }
 
 // WorkerPool for handling concurrent requests
 type WorkerPool struct {
-	Tasks       chan Task
-	Workers     int
-	StopChan    chan struct{}
-	WaitGroup   sync.WaitGroup
-	HTTPClient  *http.Client
-	GraphQLURL  string
-	Headers     map[string]string
-	Metrics     *Metrics
-	CurrentRate *atomic.Int64
-	Config      *Config
+	Tasks      chan Task
+	Workers    int
+	StopChan   chan struct{}
+	WaitGroup  sync.WaitGroup
+	HTTPClient *http.Client
+	GraphQLURL string
+	Headers    map[string]string
+	Metrics    *Metrics
+	Config     *Config
+	Limiter    *concurrencyLimiter // per-operation in-flight caps (nil if none)
+	Shaper     *bandwidthShaper    // bandwidth-limited connections (nil if none)
+	Tracer     *requestTracer      // request trace log (nil if off)
+	Redirects  *redirectTracker    // redirect policy and counts
+	Batches    *batchTracker       // batched HTTP requests (BatchSize > 1)
+	GraphQLGet *graphqlGet         // builds GET requests (nil if all POST)
+	Uploads    *uploader           // multipart upload bodies (nil if off)
+	Webhooks   *webhookReceiver    // webhook correlation (nil if off)
+	Entities   *entityStore        // IDs of created entities (nil if off)
+	Assets     *assetStore         // asset URLs found in responses (nil if off)
+	Pages      *pageComposer       // page loads (nil if off)
+	Cache      *cacheChecker       // staleness checks of cached responses (nil if off)
+	Golden     *goldenChecker      // golden response comparisons (nil if off)
 }
 
 // NewWorkerPool creates a new worker pool for Saleor GraphQL requests
 func NewWorkerPool(workers, queueSize int, graphqlURL string, headers map[string]string, metrics *Metrics, config *Config) *WorkerPool {
+	if err := checkClientBackend(config.Test.Client); err != nil {
+		log.Fatalf("Invalid Client configuration: %v", err)
+	}
+	// Spread connections over the configured source addresses
+	dialer, err := newSourceIPDialer(config.Test.SourceIPs, 30*time.Second, 30*time.Second)
+	if err != nil {
+		log.Fatalf("Invalid SourceIPs configuration: %v", err)
+	}
+	shaper, err := newBandwidthShaper(config.Test.BandwidthProfiles)
+	if err != nil {
+		log.Fatalf("Invalid BandwidthProfiles configuration: %v", err)
+	}
+	redirects, err := newRedirectTracker(config.Test.Redirects)
+	if err != nil {
+		log.Fatalf("Invalid Redirects configuration: %v", err)
+	}
+	graphqlGet, err := newGraphQLGet(config.Test.GraphQLGet)
+	if err != nil {
+		log.Fatalf("Invalid GraphQLGet configuration: %v", err)
+	}
+	uploadConfig := config.Test.Upload
+	if uploadConfig.URL == "" {
+		uploadConfig.URL = graphqlURL
+	}
+	uploads, err := newUploader(uploadConfig)
+	if err != nil {
+		log.Fatalf("Invalid Upload configuration: %v", err)
+	}
+
 	// Create an optimized HTTP transport
 	transport := &http.Transport{
+		DialContext:         shaper.wrapDial(dialer.DialContext),
 		MaxIdleConns:        workers,
 		MaxIdleConnsPerHost: workers,
 		MaxConnsPerHost:     workers,
@@ -192,23 +620,26 @@ func NewWorkerPool(workers, queueSize int, graphqlURL string, headers map[string
 	}
 
 	client := &http.Client{
-		Transport: transport,
-		Timeout:   10 * time.Second,
+		Transport:     clientTransport(transport, config),
+		CheckRedirect: redirects.checkRedirect,
+		Timeout:       10 * time.Second,
 	}
 
-	currentRate := &atomic.Int64{}
-	currentRate.Store(0)
-
 	return &WorkerPool{
-		Tasks:       make(chan Task, queueSize),
-		Workers:     workers,
-		StopChan:    make(chan struct{}),
-		HTTPClient:  client,
-		GraphQLURL:  graphqlURL,
-		Headers:     headers,
-		Metrics:     metrics,
-		CurrentRate: currentRate,
-		Config:      config,
+		Tasks:      make(chan Task, queueSize),
+		Workers:    workers,
+		StopChan:   make(chan struct{}),
+		HTTPClient: client,
+		GraphQLURL: graphqlURL,
+		Headers:    headers,
+		Metrics:    metrics,
+		Config:     config,
+		Limiter:    newConcurrencyLimiter(config.Test.ConcurrencyLimits),
+		Shaper:     shaper,
+		Redirects:  redirects,
+		Batches:    &batchTracker{},
+		GraphQLGet: graphqlGet,
+		Uploads:    uploads,
 	}
 }
 
@@ -216,7 +647,7 @@ func NewWorkerPool(workers, queueSize int, graphqlURL string, headers map[string
 func (p *WorkerPool) Start() {
 	for i := 0; i < p.Workers; i++ {
 		p.WaitGroup.Add(1)
-		go p.worker()
+		go p.worker(classForWorker(p.Config.Test.ClientClasses, i, p.Workers))
 	}
 }
 
@@ -227,7 +658,7 @@ func (p *WorkerPool) Stop() {
 }
 
 // worker processes GraphQL tasks from the queue
-func (p *WorkerPool) worker() {
+func (p *WorkerPool) worker(class *ClientClass) {
 	defer p.WaitGroup.Done()
 
 	for {
@@ -236,7 +667,16 @@ func (p *WorkerPool) worker() {
 			if !ok {
 				return
 			}
+			class.apply(&task)
+			if task.Page != nil {
+				p.loadPage(class, task.Page)
+				task.Burst.done()
+				continue
+			}
+			p.Limiter.acquire(task.Operation)
 			p.executeGraphQLTask(task)
+			p.Limiter.release(task.Operation)
+			task.Burst.done()
 		case <-p.StopChan:
 			return
 		}
@@ -245,32 +685,67 @@ func (p *WorkerPool) worker() {
 
 // executeGraphQLTask performs the GraphQL request
 func (p *WorkerPool) executeGraphQLTask(task Task) {
+	var duration time.Duration
+	success := false
+	defer func() { task.Result.set(duration, success) }()
+
+	if len(task.Batch) > 0 {
+		p.executeBatch(task)
+		return
+	}
+	if baseOperation(task.Operation) == assetOperation {
+		p.fetchAsset(task)
+		return
+	}
+
 	// Prepare GraphQL request
 	graphqlReq := GraphQLRequest{
 		Query:     task.Query,
 		Variables: task.Variables,
 	}
 
-	reqBody, err := json.Marshal(graphqlReq)
+	// Requests from the corpus were serialized before the test
+	reqBody := task.Body
+	var err error
+	if reqBody == nil {
+		reqBody, err = json.Marshal(graphqlReq)
+	}
 	if err != nil {
 		errResp := &ErrorResponse{
 			Query: task.Query,
 			Time:  time.Now(),
 			Error: fmt.Sprintf("request marshaling error: %v", err),
 		}
-		p.Metrics.AddResult(0, task.Operation, 0, errResp)
+		p.Metrics.AddResult(0, task.Operation, 0, false, errResp)
+		p.finishRequest(nil, task.Operation, p.GraphQLURL, 0, true, errResp.Error)
 		return
 	}
 
 	// Create HTTP request
-	req, err := http.NewRequest("POST", p.GraphQLURL, bytes.NewBuffer(reqBody))
+	target := p.GraphQLURL
+	if task.URL != "" {
+		target = task.URL
+	}
+	var req *http.Request
+	contentType := ""
+	if task.Upload {
+		var body *bytes.Buffer
+		if body, contentType, err = p.Uploads.graphqlBody(); err == nil {
+			req, err = http.NewRequest("POST", target, body)
+		}
+	} else if task.Method == "GET" {
+		req, err = p.GraphQLGet.request(target, graphqlReq)
+	} else {
+		req, err = http.NewRequest("POST", target, bytes.NewBuffer(reqBody))
+	}
 	if err != nil {
 		errResp := &ErrorResponse{
 			Query: task.Query,
 			Time:  time.Now(),
 			Error: fmt.Sprintf("request creation error: %v", err),
 		}
-		p.Metrics.AddResult(0, task.Operation, 0, errResp)
+		p.Metrics.AddResult(0, task.Operation, 0, false, errResp)
+		p.finishRequest(nil, task.Operation, target, 0, true, errResp.Error)
 		return
 	}
 
@@ -278,11 +753,23 @@ func (p *WorkerPool) executeGraphQLTask(task Task) {
 	for key, value := range p.Headers {
 		req.Header.Set(key, value)
 	}
+	for key, value := range task.Headers {
+		req.Header.Set(key, value)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	setLoadTestID(req.Header)
 
 	// Execute request with timing
+	req = withClientDelay(req, task.Delay)
+	req = p.Metrics.Conns.begin(req)
+	req, timing := p.Tracer.begin(req)
+	req, redirect := p.Redirects.begin(req, task.Operation)
 	start := time.Now()
 	resp, err := p.HTTPClient.Do(req)
-	duration := time.Since(start)
+	duration = time.Since(start)
+	p.Redirects.finish(redirect, task.Operation)
 
 	if err != nil {
 		errResp := &ErrorResponse{
@@ -290,14 +777,18 @@ func (p *WorkerPool) executeGraphQLTask(task Task) {
 			Time:  time.Now(),
 			Error: fmt.Sprintf("request error: %v", err),
 		}
-		p.Metrics.AddResult(duration, task.Operation, 0, errResp)
+		errResp.FinalURL, errResp.Redirects = redirect.finalURL, redirect.hops
+		p.Metrics.AddResult(duration, task.Operation, 0, isTimeoutError(err), errResp)
+		p.finishRequest(timing, task.Operation, target, 0, true, errResp.Error)
 		return
 	}
 
 	defer resp.Body.Close()
+	p.Cache.check(task.Operation, resp.Header)
 
 	// Process response
 	body, err := io.ReadAll(resp.Body)
+	timing.bodyRead(resp.Header)
 	if err != nil {
 		errResp := &ErrorResponse{
 			Query:      task.Query,
@@ -305,7 +796,9 @@ func (p *WorkerPool) executeGraphQLTask(task Task) {
 			Time:       time.Now(),
 			Error:      fmt.Sprintf("error reading response: %v", err),
 		}
-		p.Metrics.AddResult(duration, task.Operation, resp.StatusCode, errResp)
+		errResp.FinalURL, errResp.Redirects = redirect.finalURL, redirect.hops
+		p.Metrics.AddResult(duration, task.Operation, resp.StatusCode, isTimeoutError(err), errResp)
+		p.finishRequest(timing, task.Operation, target, resp.StatusCode, true, errResp.Error)
 		return
 	}
 
@@ -314,27 +807,30 @@ func (p *WorkerPool) executeGraphQLTask(task Task) {
 	err = json.Unmarshal(body, &graphqlResp)
 
 	var errResp *ErrorResponse
-	if err != nil {
-		// JSON parsing error
+	if !statusAccepted(p.Config.Test.SuccessCriteria, task.Operation, resp.StatusCode) {
+		// HTTP error
 		errResp = &ErrorResponse{
 			Query:      task.Query,
 			StatusCode: resp.StatusCode,
+			Body:       string(body),
 			Time:       time.Now(),
-			Error:      fmt.Sprintf("error parsing response: %v", err),
 		}
-	} else if resp.StatusCode >= 400 {
-		// HTTP error
+	} else if err != nil && resp.StatusCode < 300 {
+		// JSON parsing error
 		errResp = &ErrorResponse{
 			Query:      task.Query,
 			StatusCode: resp.StatusCode,
-			Body:       string(body),
 			Time:       time.Now(),
+			Error:      fmt.Sprintf("error parsing response: %v", err),
 		}
 	} else if graphqlResp.Errors != nil && len(graphqlResp.Errors) > 0 {
 		// GraphQL error
 		var graphqlErrors []string
 		for _, e := range graphqlResp.Errors {
 			graphqlErrors = append(graphqlErrors, e.Message)
+			if e.Message == "PersistedQueryNotFound" {
+				p.GraphQLGet.forget(task.Query)
+			}
 		}
 
 		errResp = &ErrorResponse{
@@ -344,14 +840,45 @@ func (p *WorkerPool) executeGraphQLTask(task Task) {
 			GraphQLErrs: graphqlErrors,
 			Time:        time.Now(),
 		}
+	} else if reason := checkBody(p.Config.Test.SuccessCriteria, task.Operation, resp.StatusCode, body); reason != "" {
+		// Operation-specific body check, e.g. an empty product list
+		errResp = &ErrorResponse{
+			Query:      task.Query,
+			StatusCode: resp.StatusCode,
+			Body:       string(body),
+			Time:       time.Now(),
+			Error:      reason,
+		}
+	} else {
+		p.Webhooks.track(task.Operation, start, start.Add(duration), body)
+		p.Entities.capture(task.Operation, body)
+		p.Assets.capture(task.Operation, resp.Request.URL, body)
+		if p.Golden.wants(task.Operation) {
+			p.Golden.compare(task.Operation, body)
+		}
 	}
 
-	// Only create error sample if enabled and within sample rate
-	if errResp != nil && p.Config.Test.LogErrors && rand.Float64() <= p.Config.Test.ErrorSampleRate {
-		p.Metrics.AddResult(duration, task.Operation, resp.StatusCode, errResp)
-	} else {
-		p.Metrics.AddResult(duration, task.Operation, resp.StatusCode, nil)
+	traceErr := ""
+	if errResp != nil {
+		errResp.FinalURL, errResp.Redirects = redirect.finalURL, redirect.hops
+		traceErr = errResp.Error
+		if len(errResp.GraphQLErrs) > 0 {
+			traceErr = strings.Join(errResp.GraphQLErrs, "; ")
+		}
 	}
+	p.finishRequest(timing, task.Operation, target, resp.StatusCode, errResp != nil, traceErr)
+	success = errResp == nil
+	p.Metrics.AddOutcome(duration, task.Operation, resp.StatusCode, false, success, p.sampled(errResp))
+}
+
+// sampled returns the error response if error sampling keeps it, nil
+// otherwise. It only decides what is stored as a sample, never whether the
+// request counts as failed.
+func (p *WorkerPool) sampled(errResp *ErrorResponse) *ErrorResponse {
+	if errResp == nil || !p.Config.Test.LogErrors || rand.Float64() > p.Config.Test.ErrorSampleRate {
+		return nil
+	}
+	return errResp
 }
 
 // LoadGenerator controls the rate of GraphQL request generation
@@ -359,7 +886,29 @@ type LoadGenerator struct {
 	Pool      *WorkerPool
 	Config    *Config
 	StopChan  chan struct{}
+	Done      chan struct{} // closed when load generation finishes on its own
+	Guard     *resourceGuard // pauses generation near the generator's resource limits
+	Blackout  *blackoutPause // pauses generation during maintenance windows (nil if none)
+	bursts    *burstTracker  // burst completion latencies in burst mode
+	Canary    *canaryRouter  // sends a share of traffic to a canary (nil if none)
+	Experiments *experimentPicker // A/B header sets (nil if none)
+	Admin       *adminWorkload    // admin API operations (nil if none)
+	Journeys    *journeys         // virtual user journeys (nil if off)
+	Autocomplete *autocomplete    // keystroke searches (nil if off)
+	Hold         *stageHold       // holds rising stages on errors (nil if off)
+	Discovered   *discoveredIDs   // products of the specific_product queries (nil if off)
+	Adaptive     *adaptiveController // adjusts the RPS (nil unless AdaptiveRPS)
+	Corpus       *requestCorpus      // pre-built requests replayed (nil if off)
+	Endpoints    *endpointRates      // per-operation rates (nil unless EndpointRPS)
+	Phases       *phasePlan          // load model per phase (nil unless Phases)
+	Tuning       generatorTuning     // generator goroutines and tick resolution
+	rate         atomic.Uint64       // target RPS as float64 bits, paced by the generators
 	WaitGroup sync.WaitGroup
+
+	// Progress tracking for periodic reports
+	testStart  time.Time
+	stageIndex atomic.Int64
+	stageStart atomic.Int64 // offset from testStart on the monotonic clock
 }
 
 // NewLoadGenerator creates a new GraphQL load generator
@@ -368,6 +917,8 @@ func NewLoadGenerator(pool *WorkerPool, config *Config) *LoadGenerator {
 		Pool:     pool,
 		Config:   config,
 		StopChan: make(chan struct{}),
+		Done:     make(chan struct{}),
+		bursts:   &burstTracker{},
 	}
 }
 
@@ -383,8 +934,56 @@ func (g *LoadGenerator) Stop() {
 	g.WaitGroup.Wait()
 }
 
-// generateGraphQLTask creates a new GraphQL request task with even distribution
+// generateGraphQLTask creates the next task: an upload for Upload.Percent of
+// them, a dashboard query for Admin.Percent, otherwise one operation (sent as GET for GraphQLGet.Percent of them),
+// or a batch of BatchSize operations when batching is enabled
 func (g *LoadGenerator) generateGraphQLTask() Task {
+	if g.Pool.Uploads.pick() {
+		return Task{
+			Query:     g.Config.Test.Upload.Query,
+			Operation: uploadOperation,
+			URL:       g.Pool.Uploads.config.URL,
+			Headers:   g.Config.Test.Upload.Headers,
+			Upload:    true,
+		}
+	}
+	if g.Admin.pick() {
+		op := g.Admin.next()
+		return Task{Query: op.Query, Operation: op.Name, Headers: g.Admin.headers}
+	}
+	if op, ok := g.Pool.Entities.pick(); ok {
+		return Task{Query: op.Query, Variables: op.Variables, Operation: op.Name, Headers: op.Headers}
+	}
+	if asset, ok := g.Pool.Assets.pick(); ok {
+		return Task{URL: asset, Headers: g.Config.Test.Assets.Headers, Method: "GET", Operation: assetOperation}
+	}
+	if page, ok := g.Pool.Pages.pick(); ok {
+		if task, ok := g.pageTask(page); ok {
+			return task
+		}
+	}
+	if g.Autocomplete.pick() {
+		return Task{Query: g.Autocomplete.config.Query, Variables: g.Autocomplete.variables(g.Autocomplete.next()), Operation: autocompleteOperation}
+	}
+	if user, state, ok := g.Journeys.advance(); ok {
+		if task, ok := g.journeyTask(g.Journeys.operation(state)); ok {
+			return task
+		}
+		g.Journeys.block(user, state)
+	}
+	if g.Config.Test.BatchSize > 1 {
+		return g.generateBatchTask(g.Config.Test.BatchSize)
+	}
+	task := g.generateGraphQLOperation()
+	if g.Pool.GraphQLGet.pick() {
+		task.Method = "GET"
+		task.Operation += getSuffix
+	}
+	return task
+}
+
+// generateGraphQLOperation creates a new GraphQL request task with even distribution
+func (g *LoadGenerator) generateGraphQLOperation() Task {
 	// Distribute traffic across query types
 	var query string
 	var operation string
@@ -396,6 +995,8 @@ func (g *LoadGenerator) generateGraphQLTask() Task {
 	} else if rand < 0.666 {
 		query = g.Config.Queries.Categories
 		operation = "categories"
+	} else if g.Discovered != nil {
+		return g.Discovered.productTask()
 	} else {
 		query = g.Config.Queries.SpecificProduct
 		operation = "specific_product"
@@ -408,30 +1009,41 @@ func (g *LoadGenerator) generateGraphQLTask() Task {
 	}
 }
 
-// generateLoad produces tasks at the configured rate
+// generateLoad follows the stages or the adaptive controller and publishes
+// the target rate the generators pace to
 func (g *LoadGenerator) generateLoad() {
 	defer g.WaitGroup.Done()
+	defer close(g.Done)
 
 	stageStart := time.Now()
 	testStart := time.Now()
 	currentStage := 0
+	g.testStart = testStart
+	g.stageStart.Store(int64(stageStart.Sub(testStart)))
 
-	ticker := time.NewTicker(1 * time.Millisecond)
+	ticker := time.NewTicker(g.Tuning.Tick)
 	defer ticker.Stop()
 
 	// Initialize variables for rate limiting
-	var currentTargetRPS int64 = 0
+	var currentTargetRPS float64 = 0
 	
-	if g.Config.Test.AdaptiveRPS {
+	if g.Phases != nil {
+		// Phases run one after another, each with its own load model
+		currentTargetRPS = g.Phases.Start(testStart)
+	} else if g.Endpoints != nil {
+		// Each operation is paced at its own fixed rate
+		currentTargetRPS = g.Endpoints.total()
+	} else if g.Config.Test.AdaptiveRPS {
 		// For adaptive testing, start with the initial RPS
 		currentTargetRPS = g.Config.Test.AdaptiveConfig.InitialRPS
+		g.Adaptive.Start(testStart)
 	} else if len(g.Config.Test.RampupStages) > 0 {
 		// For staged testing, start with first stage
 		currentTargetRPS = g.Config.Test.RampupStages[0].TargetRPS
 	}
 	
 	startRPS := currentTargetRPS
-	g.Pool.CurrentRate.Store(currentTargetRPS)
+	g.publishRate(currentTargetRPS)
 
 	// Variables for adaptive testing
 	var (
@@ -451,16 +1063,23 @@ func (g *LoadGenerator) generateLoad() {
 		for {
 			select {
 			case <-reportTicker.C:
-				printGraphQLReport(g.Pool.Metrics, currentTargetRPS)
+				printGraphQLReport(g.Pool.Metrics, g.targetRate(), g.progress())
 			case <-g.StopChan:
 				return
 			}
 		}
 	}()
 
-	// Variables for tracking requests per second
-	secondStart := time.Now()
-	requestsThisSecond := int64(0)
+	// Burst mode replaces the rate-based loop below
+	if g.Config.Test.BurstMode {
+		g.generateBursts()
+		return
+	}
+	if g.Endpoints != nil {
+		g.startEndpointPacers()
+	} else {
+		g.startGenerators()
+	}
 
 	for {
 		select {
@@ -501,53 +1120,44 @@ func (g *LoadGenerator) generateLoad() {
 					}
 					
 					// Only adjust RPS after stabilization window
-					if now.Sub(lastAdaptiveChange) >= g.Config.Test.AdaptiveConfig.StabilizationWindow {
-						previousRPS := currentTargetRPS
-						
-						// Adjust RPS based on error rate
-						if recentErrorRate > g.Config.Test.AdaptiveConfig.ErrorThresholdPercentage {
-							// Too many errors, decrease RPS
-							decreaseAmount := float64(currentTargetRPS) * (g.Config.Test.AdaptiveConfig.RPSDecreasePercentage / 100.0)
-							currentTargetRPS = currentTargetRPS - int64(decreaseAmount)
-							
-							// Ensure we don't go below minimum
-							if currentTargetRPS < g.Config.Test.AdaptiveConfig.MinimumRPS {
-								currentTargetRPS = g.Config.Test.AdaptiveConfig.MinimumRPS
-							}
-							
-							fmt.Printf("Error rate %.2f%% exceeds threshold. Decreasing RPS from %d to %d\n", 
-								recentErrorRate, previousRPS, currentTargetRPS)
-						} else {
-							// Error rate is acceptable, increase RPS
-							increaseAmount := float64(currentTargetRPS) * (g.Config.Test.AdaptiveConfig.RPSIncreasePercentage / 100.0)
-							currentTargetRPS = currentTargetRPS + int64(increaseAmount)
-							
-							// Ensure we don't exceed maximum
-							if currentTargetRPS > g.Config.Test.AdaptiveConfig.MaximumRPS {
-								currentTargetRPS = g.Config.Test.AdaptiveConfig.MaximumRPS
-							}
-							
-							fmt.Printf("Error rate %.2f%% below threshold. Increasing RPS from %d to %d\n", 
-								recentErrorRate, previousRPS, currentTargetRPS)
-						}
-						
-						g.Pool.CurrentRate.Store(currentTargetRPS)
+					if now.Sub(lastAdaptiveChange) >= g.Config.Test.AdaptiveConfig.StabilizationWindow && !g.Blackout.Paused() {
+						window := g.Config.Test.AdaptiveConfig.SamplingWindow
+						currentTargetRPS = g.Adaptive.next(now, currentTargetRPS, recentErrorRate, g.Pool.Metrics.Series.recentP95(window))
+						g.publishRate(currentTargetRPS)
 						lastAdaptiveChange = now
+						if g.Adaptive.Converged() {
+							return
+						}
 					}
 					
 					lastSamplingTime = now
 				}
-			} else {
+			} else if g.Phases != nil {
+				rps, done := g.Phases.advance(now, currentTargetRPS, g.Pool.Metrics.Series)
+				if done {
+					fmt.Println("Load test completed all phases.")
+					return
+				}
+				currentTargetRPS = rps
+				g.publishRate(currentTargetRPS)
+			} else if g.Endpoints == nil {
 				// Original staged testing logic
 				// Check if we need to move to the next stage
 				if currentStage < len(g.Config.Test.RampupStages) {
 					stage := g.Config.Test.RampupStages[currentStage]
+					// A held stage's clock stands still
+					if paused := g.Hold.pause(now, currentStage, stage, currentTargetRPS, stage.TargetRPS > startRPS, g.Pool.Metrics); paused > 0 {
+						stageStart = stageStart.Add(paused)
+						g.stageStart.Store(int64(stageStart.Sub(testStart)))
+					}
 					elapsed := now.Sub(stageStart)
 
 					if elapsed >= stage.Duration {
 						// Move to next stage
 						stageStart = now
 						currentStage++
+						g.stageIndex.Store(int64(currentStage))
+						g.stageStart.Store(int64(now.Sub(testStart)))
 						if currentStage < len(g.Config.Test.RampupStages) {
 							startRPS = currentTargetRPS
 							fmt.Printf("Moving to stage %d: %s\n", currentStage+1, g.Config.Test.RampupStages[currentStage].Description)
@@ -563,37 +1173,17 @@ func (g *LoadGenerator) generateLoad() {
 						progress := float64(elapsed) / float64(stage.Duration)
 
 						// Linear interpolation between start RPS and target RPS
-						currentTargetRPS = startRPS + int64(float64(stage.TargetRPS-startRPS)*progress)
-						g.Pool.CurrentRate.Store(currentTargetRPS)
+						currentTargetRPS = startRPS + (stage.TargetRPS-startRPS)*progress
+						g.publishRate(currentTargetRPS)
 					}
 				}
 			}
-
-			// Check if we've started a new second
-			if now.Sub(secondStart) >= time.Second {
-				secondStart = now
-				requestsThisSecond = 0
-			}
-
-			// Ensure we don't exceed our target RPS
-			if requestsThisSecond < currentTargetRPS {
-				// Generate a task
-				task := g.generateGraphQLTask()
-
-				// Try to send the task, but don't block if queue is full
-				select {
-				case g.Pool.Tasks <- task:
-					requestsThisSecond++
-				default:
-					// Queue is full, skip this task
-				}
-			}
 		}
 	}
 }
 
 // printGraphQLReport generates and prints a report of current GraphQL metrics
-func printGraphQLReport(metrics *Metrics, targetRPS int64) {
+func printGraphQLReport(metrics *Metrics, targetRPS float64, progress map[string]interface{}) {
 	metrics.mutex.RLock()
 	defer metrics.mutex.RUnlock()
 
@@ -618,12 +1208,14 @@ func printGraphQLReport(metrics *Metrics, targetRPS int64) {
 		"totalRequests":         metrics.TotalRequests,
 		"successfulRequests":    metrics.SuccessfulRequests,
 		"failedRequests":        metrics.FailedRequests,
+		"timeoutRequests":       metrics.TimeoutRequests,
 		"testDuration":          testDuration.String(),
 		"actualRPS":             fmt.Sprintf("%.2f", actualRPS),
-		"targetRPS":             targetRPS,
+		"targetRPS":             roundRPS(targetRPS),
 		"successRate":           fmt.Sprintf("%.2f%%", float64(metrics.SuccessfulRequests)/float64(max(metrics.TotalRequests, 1))*100),
 		"statusCodes":           metrics.StatusCodes,
 		"operationDistribution": operationDistribution,
+		"progress":              progress,
 	}
 
 	// Calculate latency percentiles if we have data
@@ -633,12 +1225,7 @@ func printGraphQLReport(metrics *Metrics, targetRPS int64) {
 		copy(sorted, metrics.RequestDurations)
 		sort.Sort(durationSlice(sorted))
 
-		report["latency"] = map[string]string{
-			"p50": percentileDuration(sorted, 0.5).String(),
-			"p90": percentileDuration(sorted, 0.9).String(),
-			"p95": percentileDuration(sorted, 0.95).String(),
-			"p99": percentileDuration(sorted, 0.99).String(),
-		}
+		report["latency"] = latencyPercentiles(sorted)
 	}
 
 	// Include recent error samples if available
@@ -660,6 +1247,11 @@ func printGraphQLReport(metrics *Metrics, targetRPS int64) {
 				sampleInfo["graphqlErrors"] = sample.GraphQLErrs
 			}
 
+			if sample.Redirects > 0 {
+				sampleInfo["finalURL"] = sample.FinalURL
+				sampleInfo["redirects"] = sample.Redirects
+			}
+
 			if sample.Error != "" {
 				sampleInfo["error"] = sample.Error
 			}
@@ -670,6 +1262,10 @@ func printGraphQLReport(metrics *Metrics, targetRPS int64) {
 		report["errorSamples"] = sampleData
 	}
 
+	if connections := metrics.Conns.current(); connections != nil {
+		report["connections"] = connections
+	}
+
 	reportJSON, _ := json.MarshalIndent(report, "", "  ")
 	fmt.Println(string(reportJSON))
 }
@@ -692,10 +1288,36 @@ func max(a, b int64) int64 {
 func main() {
 	// Parse command line arguments
 	configPath := flag.String("config", "config.json", "Path to the configuration file")
+	outDir := flag.String("out-dir", ".", "Directory to write the results file to")
+	outName := flag.String("out-name", defaultResultsName, "Results file name template ({platform}, {timestamp}, {date})")
+	skipPrecheck := flag.Bool("skip-precheck", false, "Start the test without probing the target first")
+	maxCPU := flag.Float64("max-cpu", 0, "Pause load generation while the generator uses more than this percent of its CPU limit (0 = off)")
+	maxMem := flag.Float64("max-mem", 0, "Pause load generation while the generator uses more than this percent of its memory limit (0 = off)")
+	gomaxprocsFlag := flag.Int("gomaxprocs", 0, "GOMAXPROCS (0 = the container CPU quota, or the host's CPUs)")
+	generators := flag.Int("generators", 1, "Goroutines pacing and queueing tasks, each sending its share of the rate")
+	tick := flag.Duration("tick", 10*time.Millisecond, "Interval of the rate updates, and the longest a generator sleeps before it picks up a new rate")
+	preset := flag.String("preset", "", "Built-in scenario layered on the config (flash-sale)")
+	printJSON := flag.Bool("print-json", false, "Print the full results JSON to the console, not just the summary")
+	recordAll := flag.Bool("record-all-durations", false, "Record every request duration in histograms instead of a sample (overrides the config)")
+	calibrationPath := flag.String("calibration", defaultCalibrationFile, "Calibration stamp of wsm calibrate embedded in the results, if present")
+	sloDir := flag.String("slo-dir", defaultSLODir, "Directory of the per-platform SLO files the results are checked against (empty = off)")
+	uiListen := flag.String("ui-listen", "", "Serve a page with live charts of the test on this address, e.g. 127.0.0.1:8089 (empty = off)")
+	collector := flag.String("collector", "", "Stream the per-second results to a wsm collector at this URL, e.g. http://collector:9300 (empty = off)")
+	checksum := flag.Bool("checksum", false, "Embed the config used (secrets redacted) and a SHA-256 of the canonicalized results")
+	maxDuration := flag.Duration("max-duration", 0, "Stop the run after this long whatever the config says, still writing the results (0 = Test.MaxDuration)")
+	targetPolicyPath := flag.String("target-policy", defaultTargetPolicyFile, "Policy keeping high-RPS plans off production hosts, used if present (empty = off)")
+	blackoutFile := flag.String("blackout", defaultBlackoutFile, "Blackout calendar of maintenance windows to pause the load in, used if present (empty = none)")
+	productionOverride := flag.Bool("i-know-this-is-production", false, "Run a plan above the target policy's MaxRPS against production hosts anyway")
+	cloudMetadata := flag.Bool("cloud-metadata", true, "Look up the generator's instance type and region from the AWS, GCP or Azure metadata service")
 	flag.Parse()
 
-	// Set GOMAXPROCS to use all available CPU cores
-	runtime.GOMAXPROCS(runtime.NumCPU())
+	// Size GOMAXPROCS to the container CPU quota rather than the host's core count
+	limits := detectContainerLimits()
+	tuning := generatorTuning{Generators: *generators, Tick: *tick}
+	if err := tuning.validate(); err != nil {
+		log.Fatalf("Invalid generator tuning: %v", err)
+	}
+	gomaxprocs, gomaxprocsFrom := configureGOMAXPROCS(limits, *gomaxprocsFlag)
 
 	// Load configuration
 	configFile, err := os.Open(*configPath)
@@ -712,9 +1334,49 @@ func main() {
 	if err := json.NewDecoder(configFile).Decode(&config); err != nil {
 		log.Fatalf("Failed to parse config file: %v", err)
 	}
+	if err := resolveCredentials(&config, *configPath); err != nil {
+		log.Fatalf("Invalid credentials configuration: %v", err)
+	}
+	if err := applyRPSProfile(&config, *configPath); err != nil {
+		log.Fatalf("Failed to load RPS profile: %v", err)
+	}
+	if err := applyPreset(&config, *preset); err != nil {
+		log.Fatalf("Invalid preset: %v", err)
+	}
+	registerSecrets(&config)
+	id, err := newRunID()
+	if err != nil {
+		log.Fatalf("Generating the run ID failed: %v", err)
+	}
+	runID = id
+	fmt.Printf("Run ID: %s (sent as %s)\n", runID, loadTestIDHeader)
+	targetPolicy, err := loadTargetPolicy(*targetPolicyPath)
+	if err != nil {
+		log.Fatalf("Invalid target policy: %v", err)
+	}
+	peakRPS := plannedPeakRPS(&config) + float64(config.Test.FlashSale.ExtraRPS)
+	policyReport, err := checkTargetPolicy(targetPolicy, targetHosts(&config), peakRPS, *productionOverride)
+	if err != nil {
+		log.Fatalf("Refusing to run: %v", err)
+	}
+	calendar, err := loadBlackoutCalendar(*blackoutFile)
+	if err != nil {
+		log.Fatalf("Invalid blackout calendar: %v", err)
+	}
+	fileLimit, err := checkFileLimit(&config)
+	if err != nil {
+		log.Fatalf("Open file limit too low: %v", err)
+	}
+	if err := setPercentiles(config.Test.Percentiles, config.Test.Statistics); err != nil {
+		log.Fatalf("Invalid Percentiles: %v", err)
+	}
 
 	// Initialize metrics
 	metrics := NewMetrics()
+	metrics.TargetPolicy = policyReport
+	metrics.IncludeTimeoutsInLatency = config.Test.IncludeTimeoutsInLatency
+	metrics.SuccessRules = config.Test.SuccessCriteria
+	metrics.Tags = config.Test.Tags
 
 	// Set up worker pool for GraphQL
 	pool := NewWorkerPool(
@@ -726,8 +1388,157 @@ func main() {
 		&config,
 	)
 
+	tracer, err := newRequestTracer(config.Test.Trace, resultsOutput{Dir: *outDir}, "saleor", time.Now())
+	if err != nil {
+		log.Fatalf("Invalid trace configuration: %v", err)
+	}
+	pool.Tracer = tracer
+	webhooks, err := newWebhookReceiver(config.Test.Webhooks)
+	if err != nil {
+		log.Fatalf("Invalid webhook configuration: %v", err)
+	}
+	pool.Webhooks = webhooks
+	fakeData, err := newFaker(config.Test.Faker)
+	if err != nil {
+		log.Fatalf("Invalid faker configuration: %v", err)
+	}
+	entities, err := newEntityStore(config.Test.Entities, fakeData)
+	if err != nil {
+		log.Fatalf("Invalid entity configuration: %v", err)
+	}
+	pool.Entities = entities
+	assets, err := newAssetStore(config.Test.Assets, []string{"products", "specific_product"})
+	if err != nil {
+		log.Fatalf("Invalid asset configuration: %v", err)
+	}
+	pool.Assets = assets
+	cache, err := newCacheChecker(config.Test.CacheTTLs)
+	if err != nil {
+		log.Fatalf("Invalid cache TTL configuration: %v", err)
+	}
+	pool.Cache = cache
+	golden, err := newGoldenChecker(config.Test.Golden, goldenFetchers(&config))
+	if err != nil {
+		log.Fatalf("Golden snapshot failed: %v", err)
+	}
+	pool.Golden = golden
+	external, err := newExternalPoller(config.Test.ExternalMetrics, metrics)
+	if err != nil {
+		log.Fatalf("Invalid external metrics configuration: %v", err)
+	}
+	if golden != nil {
+		fmt.Printf("Comparing %.1f%% of responses with %d golden responses\n", config.Test.Golden.SampleRate*100, len(golden.golden))
+	}
+	if assets != nil {
+		fmt.Printf("Sending %.1f%% of requests to assets found in responses\n", config.Test.Assets.Percent)
+	}
+	if webhooks != nil {
+		fmt.Printf("Receiving webhooks on %s%s\n", config.Test.Webhooks.Listen, webhooks.config.Path)
+	}
+
+	flashQuery := config.Test.FlashSale.Query
+	if flashQuery == "" {
+		flashQuery = config.Queries.SpecificProduct
+	}
+	flashSale, err := newFlashSale(config.Test.FlashSale, Task{Query: flashQuery})
+	if err != nil {
+		log.Fatalf("Invalid flash sale configuration: %v", err)
+	}
+	metrics.FlashSale = flashSale
+	metrics.Series = newSecondSeries(config.Test.Anomalies)
+	metrics.Conns = newConnReuse()
+	metrics.Histograms = newHistogramSet(config.Test.RecordAllDurations || *recordAll)
+	if flashSale != nil {
+		fmt.Printf("Flash sale: +%d RPS on the specific product query from %s for %s\n", config.Test.FlashSale.ExtraRPS, config.Test.FlashSale.Start, config.Test.FlashSale.Duration)
+	}
+
 	// Set up load generator
 	generator := NewLoadGenerator(pool, &config)
+	generator.Tuning = tuning
+	guard := newResourceGuard(limits, gomaxprocs, *maxCPU, *maxMem)
+	guard.fileLimit = fileLimit
+	guard.gomaxprocsFrom = gomaxprocsFrom
+	guard.tuning = tuning.report()
+	generator.Guard = guard
+	canary, err := newCanaryRouter(config.Test.Canary.BaseURL, config.Test.Canary.Percent)
+	if err != nil {
+		log.Fatalf("Invalid canary configuration: %v", err)
+	}
+	generator.Canary = canary
+	if canary != nil {
+		fmt.Printf("Sending %.1f%% of requests to canary %s\n", config.Test.Canary.Percent, config.Test.Canary.BaseURL)
+	}
+	if err := validateClientClasses(config.Test.ClientClasses); err != nil {
+		log.Fatalf("Invalid client class configuration: %v", err)
+	}
+	for _, c := range config.Test.ClientClasses {
+		fmt.Printf("Client class %s: %.1f%% of workers with +%s (±%s) delay\n", c.Name, c.Percent, c.Delay, c.Jitter)
+	}
+	experiments, err := newExperimentPicker(config.Test.Experiments)
+	if err != nil {
+		log.Fatalf("Invalid experiment configuration: %v", err)
+	}
+	generator.Experiments = experiments
+	admin, err := newAdminWorkload(config.Test.Admin)
+	if err != nil {
+		log.Fatalf("Invalid admin configuration: %v", err)
+	}
+	generator.Admin = admin
+	if admin != nil {
+		fmt.Printf("Sending %.1f%% of requests to the admin API\n", config.Test.Admin.Percent)
+	}
+	journey, err := newJourneys(config.Test.Journey, generator.journeyOperation, *configPath)
+	if err != nil {
+		log.Fatalf("Invalid journey configuration: %v", err)
+	}
+	generator.Journeys = journey
+	if journey != nil {
+		fmt.Printf("Simulating %d virtual users starting at %s\n", len(journey.users), config.Test.Journey.Start)
+	}
+	typing, err := newAutocomplete(config.Test.Autocomplete)
+	if err != nil {
+		log.Fatalf("Invalid autocomplete configuration: %v", err)
+	}
+	generator.Autocomplete = typing
+	hold, err := newStageHold(&config)
+	if err != nil {
+		log.Fatalf("Invalid AdaptiveStages configuration: %v", err)
+	}
+	generator.Hold = hold
+	if hold != nil {
+		fmt.Printf("Holding rising stages while the error rate exceeds %.2f%%\n", config.Test.AdaptiveConfig.ErrorThresholdPercentage)
+	}
+	adaptive, err := newAdaptiveController(&config)
+	if err != nil {
+		log.Fatalf("Invalid adaptive configuration: %v", err)
+	}
+	generator.Adaptive = adaptive
+	if adaptive != nil {
+		fmt.Printf("Adaptive strategy: %s\n", adaptive.name)
+	}
+	phases, err := newPhasePlan(&config, adaptive)
+	if err != nil {
+		log.Fatalf("Invalid Phases configuration: %v", err)
+	}
+	generator.Phases = phases
+	pages, err := newPageComposer(config.Test.Pages, generator.journeyOperation)
+	if err != nil {
+		log.Fatalf("Invalid page configuration: %v", err)
+	}
+	pool.Pages = pages
+	for _, page := range config.Test.Pages {
+		fmt.Printf("Loading page %s for %.1f%% of requests: %s\n", page.Name, page.Percent, strings.Join(page.Operations, ", "))
+	}
+	if typing != nil {
+		fmt.Printf("Sending %.1f%% of requests as autocomplete keystrokes from %d typing users\n", config.Test.Autocomplete.Percent, len(typing.users))
+	}
+	hooks, err := newHookRunner("saleor", config.Test.Hooks)
+	if err != nil {
+		log.Fatalf("Invalid hook configuration: %v", err)
+	}
+	for _, e := range config.Test.Experiments {
+		fmt.Printf("Experiment %s: %.1f%% of requests with headers %v\n", e.Name, e.Percent, e.Headers)
+	}
 
 	// Handle OS signals for graceful shutdown
 	sigChan := make(chan os.Signal, 1)
@@ -735,35 +1546,200 @@ func main() {
 
 	// Start load test
 	fmt.Println("Starting Saleor GraphQL load test...")
-	if config.Test.AdaptiveRPS {
-		fmt.Printf("Using adaptive load testing with initial RPS: %d, error threshold: %.2f%%\n", 
-			config.Test.AdaptiveConfig.InitialRPS, 
+	if phases != nil {
+		fmt.Printf("Using phased load testing with %d phases\n", len(config.Test.Phases))
+	} else if len(config.Test.EndpointRPS) > 0 {
+		fmt.Printf("Using per-endpoint rates: %s\n", describeEndpointRPS(config.Test.EndpointRPS))
+	} else if config.Test.AdaptiveRPS {
+		fmt.Printf("Using adaptive load testing with initial RPS: %s, error threshold: %.2f%%\n", 
+			formatRPS(config.Test.AdaptiveConfig.InitialRPS), 
 			config.Test.AdaptiveConfig.ErrorThresholdPercentage)
 	} else {
 		fmt.Printf("Using staged load testing with %d stages\n", len(config.Test.RampupStages))
 	}
+	printTestPlan(&config)
+	limit, err := newRunLimit(&config, *maxDuration)
+	if err != nil {
+		log.Fatalf("Invalid MaxDuration: %v", err)
+	}
+	blackout := newBlackoutPause(calendar, plannedDuration(&config))
+	generator.Blackout = blackout
+	calibration, err := loadCalibration(*calibrationPath)
+	if err != nil {
+		log.Fatalf("Invalid calibration stamp: %v", err)
+	}
+	slo, err := loadSLO(*sloDir, "saleor")
+	if err != nil {
+		log.Fatalf("Invalid SLO file: %v", err)
+	}
+	if slo != nil {
+		fmt.Printf("Checking the results against SLO %s\n", slo.describe())
+	}
+	checkCalibration(calibration, &config)
+	metrics.Calibration = calibration
+	metrics.SLO = slo
+	if *checksum {
+		if metrics.Config, err = embeddedConfig(&config); err != nil {
+			log.Fatalf("Embedding the config failed: %v", err)
+		}
+	}
+	if len(config.Test.SourceIPs) > 0 {
+		fmt.Printf("Binding outgoing connections to source addresses: %v\n", config.Test.SourceIPs)
+	}
+	metrics.GeneratorHost = describeGeneratorHost(*cloudMetadata)
+	fmt.Printf("Generator host: %s\n", summarizeGeneratorHost(metrics.GeneratorHost))
+
+	// Make sure the target is up and record which release is being tested
+	if !*skipPrecheck {
+		env, err := fingerprintTarget(&config)
+		if err != nil {
+			log.Fatalf("Target pre-check failed: %v (use -skip-precheck to run anyway)", err)
+		}
+		metrics.Environment = env
+		metrics.StartTime = time.Now() // don't count the pre-check in the test duration
+		fmt.Printf("Target pre-check passed: version %v, schema hash %v\n", valueOr(env["version"], "unknown"), valueOr(env["schemaHash"], "unknown"))
+	}
+	discovered, err := discoverIDs(&config, pool.Entities)
+	if err != nil {
+		log.Fatalf("ID discovery failed: %v", err)
+	}
+	generator.Discovered = discovered
+	if discovered != nil {
+		fmt.Printf("Discovered %d products and %d categories; %.0f%% of specific_product queries go to the %d hottest\n",
+			len(discovered.products), len(discovered.categories), discovered.config.HotPercent, min(discovered.config.Hot, len(discovered.products)))
+	}
+	corpus, err := loadRequestCorpus(&config, generator)
+	if err != nil {
+		log.Fatalf("Invalid Corpus configuration: %v", err)
+	}
+	generator.Corpus = corpus
+	if corpus != nil {
+		fmt.Printf("Replaying %d pre-built requests from %s\n", corpus.count, corpus.path)
+	}
+	endpoints, err := newEndpointRates(generator)
+	if err != nil {
+		log.Fatalf("Invalid EndpointRPS configuration: %v", err)
+	}
+	generator.Endpoints = endpoints
+	var liveConfig map[string]interface{}
+	if *uiListen != "" {
+		if liveConfig, err = embeddedConfig(&config); err != nil {
+			log.Fatalf("Embedding the config failed: %v", err)
+		}
+	}
+	ui, err := newLiveUI(*uiListen, "saleor", liveConfig, metrics.Series, func() map[string]interface{} {
+		status := generator.progress()
+		status["targetRPS"] = roundRPS(generator.targetRate())
+		return status
+	}, generator.targetRate)
+	if err != nil {
+		log.Fatalf("Invalid UI configuration: %v", err)
+	}
+	if ui != nil {
+		fmt.Printf("Live results on %s\n", ui.URL())
+	}
+	streamer, err := newMetricsStreamer(*collector, "saleor", metrics.Series, generator.progress, generator.targetRate)
+	if err != nil {
+		log.Fatalf("Invalid collector: %v", err)
+	}
 	
+	fmt.Printf("GOMAXPROCS: %d from %s (host CPUs: %d, CPU quota: %.2f)\n", gomaxprocs, gomaxprocsFrom, runtime.NumCPU(), limits.CPUQuota)
+	fmt.Printf("Load generators: %d, rate updates every %s\n", tuning.Generators, tuning.Tick)
+	hooks.runPhase("pre")
+	metrics.StartTime = time.Now() // pre hooks are not part of the test
+	metrics.Series.Start(metrics.StartTime)
+	ui.Start()
+	streamer.Start(metrics.StartTime)
+	clock := newClockWatch()
+	clock.Start(metrics.StartTime)
+	metrics.Conns.Start(metrics.StartTime, time.Duration(config.Test.ReportingSeconds)*time.Second)
+
+	guard.Start()
+	blackout.Start()
 	pool.Start()
 	generator.Start()
+	metrics.FlashSale.Start(pool.Tasks)
+	hooks.startDuring(time.Now())
+	scraper := newTargetScraper(config.Test.TargetMetrics, metrics)
+	scraper.Start(time.Now())
+	external.Start(time.Now())
+	limit.Start()
 
 	// Wait for completion or interrupt
 	select {
 	case <-sigChan:
 		fmt.Println("\nReceived interrupt signal, shutting down...")
+	case <-generator.Done:
+		fmt.Println("\nLoad generation finished, shutting down...")
+	case <-limit.C():
+		limit.Reached()
 	}
+	limit.Stop()
 
 	// Graceful shutdown
 	generator.Stop()
+	ui.Finish()
+	metrics.FlashSale.Stop()
 	close(pool.Tasks)
 	pool.Stop()
+	if err := entities.save(); err != nil {
+		log.Printf("Failed to save entity IDs: %v", err)
+	}
+	tracer.Close()
+	webhooks.Stop()
+	ui.Stop()
+	streamer.Stop()
+	guard.Stop()
+	blackout.Stop()
+	clock.Stop()
+	metrics.Conns.Stop()
+	hooks.stopDuring()
+	scraper.Stop()
+	external.Stop()
+	hooks.runPhase("post")
+	metrics.Annotations = hooks.report()
+	metrics.TargetResources = scraper.report()
+	metrics.ExternalMetrics = external.report()
+	metrics.Trace = tracer.report()
+	metrics.LatencyAttribution = tracer.attributionReport()
+	metrics.Redirects = pool.Redirects.report()
+	metrics.Uploads = pool.Uploads.report()
+	metrics.Webhooks = webhooks.report()
+	metrics.Entities = entities.report()
+	metrics.Assets = assets.report()
+	metrics.Pages = pool.Pages.report()
+	metrics.CacheChecks = cache.report()
+	metrics.Golden = golden.report()
+	metrics.Journeys = generator.Journeys.report()
+	metrics.Autocomplete = generator.Autocomplete.report()
+	metrics.HeldStages = generator.Hold.report()
+	metrics.Adaptive = generator.Adaptive.report(metrics.Series)
+	metrics.ClockJumps = clock.report()
+	metrics.Connections = metrics.Conns.report()
+	metrics.Corpus = generator.Corpus.report()
+	metrics.EndpointRPS = generator.Endpoints.report()
+	metrics.Phases = generator.Phases.report(metrics.Series)
+	metrics.Discovery = generator.Discovered.report()
+	metrics.Batches = pool.Batches.report(config.Test.BatchSize)
+	metrics.Resources = guard.report()
+	metrics.MaxDuration = limit.report()
+	metrics.Blackouts = blackout.report()
+	metrics.ConcurrencyLimits = pool.Limiter.report()
+	metrics.BandwidthProfiles = pool.Shaper.report()
+	if config.Test.BurstMode {
+		metrics.Bursts = generator.bursts.report(burstSettings(&config))
+	}
 
 	// Final report
 	metrics.EndTime = time.Now()
-	printFinalReport(metrics)
+	if !printFinalReport(metrics, resultsOutput{Dir: *outDir, NameTemplate: *outName}, config.Test.Thresholds, *printJSON) {
+		os.Exit(thresholdFailedExit)
+	}
 }
 
-// printFinalReport generates and writes the final test report
-func printFinalReport(metrics *Metrics) {
+// printFinalReport generates and writes the final test report and prints
+// the summary; it returns false if the thresholds or an enforced SLO were missed
+func printFinalReport(metrics *Metrics, output resultsOutput, thresholds ThresholdConfig, printJSON bool) bool {
 	metrics.mutex.RLock()
 	defer metrics.mutex.RUnlock()
 
@@ -779,6 +1755,8 @@ func printFinalReport(metrics *Metrics) {
 		"totalRequests":      metrics.TotalRequests,
 		"successfulRequests": metrics.SuccessfulRequests,
 		"failedRequests":     metrics.FailedRequests,
+		"timeoutRequests":    metrics.TimeoutRequests,
+		"timeoutRate":        fmt.Sprintf("%.2f%%", float64(metrics.TimeoutRequests)/float64(max(metrics.TotalRequests, 1))*100),
 		"actualRPS":          fmt.Sprintf("%.2f", actualRPS),
 		"successRate":        fmt.Sprintf("%.2f%%", float64(metrics.SuccessfulRequests)/float64(max(metrics.TotalRequests, 1))*100),
 	}
@@ -807,21 +1785,165 @@ func printFinalReport(metrics *Metrics) {
 		}
 	}
 	report["operationDistribution"] = opDist
+	report["operations"] = metrics.operationStats()
+	if variants := variantStats(metrics.OperationCounts, metrics.OperationFailures, metrics.OperationDurations); variants != nil {
+		report["variants"] = variants
+	}
+	if tags := tagStats(metrics.Tags, metrics.OperationCounts, metrics.OperationFailures, metrics.OperationDurations); tags != nil {
+		report["tags"] = tags
+	}
+
+	// Timeouts are reported separately from the latency percentiles
+	if len(metrics.TimeoutCounts) > 0 {
+		report["timeoutsByOperation"] = metrics.TimeoutCounts
+	}
+	report["latencyIncludesTimeouts"] = metrics.IncludeTimeoutsInLatency
+	if metrics.Environment != nil {
+		report["environment"] = metrics.Environment
+	}
+	report["runId"] = runID
+	if metrics.GeneratorHost != nil {
+		report["generatorHost"] = metrics.GeneratorHost
+	}
+	if metrics.TargetPolicy != nil {
+		report["targetPolicy"] = metrics.TargetPolicy
+	}
+	if metrics.Calibration != nil {
+		report["calibration"] = metrics.Calibration
+	}
+	if metrics.ClockJumps != nil {
+		report["clockJumps"] = metrics.ClockJumps
+	}
+	if metrics.Connections != nil {
+		report["connections"] = metrics.Connections
+	}
+	if metrics.Corpus != nil {
+		report["corpus"] = metrics.Corpus
+	}
+	if metrics.EndpointRPS != nil {
+		report["endpointRPS"] = metrics.EndpointRPS
+	}
+	if metrics.Phases != nil {
+		report["phases"] = metrics.Phases
+	}
+	if metrics.Resources != nil {
+		report["resources"] = metrics.Resources
+	}
+	if metrics.MaxDuration != nil {
+		report["maxDuration"] = metrics.MaxDuration
+	}
+	if metrics.Blackouts != nil {
+		report["blackouts"] = metrics.Blackouts
+	}
+	if metrics.ConcurrencyLimits != nil {
+		report["concurrencyLimits"] = metrics.ConcurrencyLimits
+	}
+	if metrics.Bursts != nil {
+		report["bursts"] = metrics.Bursts
+	}
+	if metrics.BandwidthProfiles != nil {
+		report["bandwidthProfiles"] = metrics.BandwidthProfiles
+	}
+	if len(metrics.Annotations) > 0 {
+		report["annotations"] = metrics.Annotations
+	}
+	if metrics.TargetResources != nil {
+		report["targetResources"] = metrics.TargetResources
+	}
+	if metrics.ExternalMetrics != nil {
+		report["externalMetrics"] = metrics.ExternalMetrics
+	}
+	if metrics.Trace != nil {
+		report["trace"] = metrics.Trace
+	}
+	if metrics.LatencyAttribution != nil {
+		report["latencyAttribution"] = metrics.LatencyAttribution
+	}
+	if metrics.Redirects != nil {
+		report["redirects"] = metrics.Redirects
+	}
+	if metrics.Uploads != nil {
+		report["uploads"] = metrics.Uploads
+	}
+	if metrics.Webhooks != nil {
+		report["webhooks"] = metrics.Webhooks
+	}
+	if metrics.Entities != nil {
+		report["entities"] = metrics.Entities
+	}
+	if metrics.Assets != nil {
+		report["assets"] = metrics.Assets
+	}
+	if metrics.Pages != nil {
+		report["pages"] = metrics.Pages
+	}
+	if metrics.CacheChecks != nil {
+		report["cacheChecks"] = metrics.CacheChecks
+	}
+	if metrics.Golden != nil {
+		report["golden"] = metrics.Golden
+	}
+	if metrics.Journeys != nil {
+		report["journeys"] = metrics.Journeys
+	}
+	if metrics.Autocomplete != nil {
+		report["autocomplete"] = metrics.Autocomplete
+	}
+	if metrics.HeldStages != nil {
+		report["heldStages"] = metrics.HeldStages
+	}
+	if metrics.Discovery != nil {
+		report["discovery"] = metrics.Discovery
+	}
+	if metrics.Adaptive != nil {
+		report["adaptive"] = metrics.Adaptive
+	}
+	if flashSale := metrics.FlashSale.report(); flashSale != nil {
+		report["flashSale"] = flashSale
+	}
+	if anomalies := metrics.Series.report(); anomalies != nil {
+		report["anomalies"] = anomalies
+	}
+	if metrics.Batches != nil {
+		report["batches"] = metrics.Batches
+	}
 
 	// Calculate latency percentiles if we have data
-	if len(metrics.RequestDurations) > 0 {
+	if latency, ok := metrics.Histograms.overallLatency(); ok {
+		report["latency"] = latency
+		report["durationRecording"] = metrics.Histograms.report()
+	} else if len(metrics.RequestDurations) > 0 {
 		sorted := make([]time.Duration, len(metrics.RequestDurations))
 		copy(sorted, metrics.RequestDurations)
 		sort.Sort(durationSlice(sorted))
 
-		report["latency"] = map[string]string{
-			"min":  sorted[0].String(),
-			"p50":  percentileDuration(sorted, 0.5).String(),
-			"p90":  percentileDuration(sorted, 0.9).String(),
-			"p95":  percentileDuration(sorted, 0.95).String(),
-			"p99":  percentileDuration(sorted, 0.99).String(),
-			"max":  sorted[len(sorted)-1].String(),
-			"mean": calculateMeanDuration(sorted).String(),
+		latency := latencyPercentiles(sorted)
+		latency["min"] = sorted[0].String()
+		latency["max"] = sorted[len(sorted)-1].String()
+		latency["mean"] = calculateMeanDuration(sorted).String()
+		report["latency"] = latency
+		if confidence := percentileConfidence(sorted); confidence != nil {
+			report["latencyConfidence"] = confidence
+		}
+	}
+
+	causes := metrics.Errors.report()
+	if len(causes) > 0 {
+		report["errorCauses"] = causes
+	}
+	checks := checkThresholds(thresholds, report)
+	if checks != nil {
+		report["thresholds"] = map[string]interface{}{
+			"passed": thresholdsPassed(checks),
+			"checks": checks,
+		}
+	}
+	sloMet := metrics.SLO.evaluate(report)
+
+	if metrics.Config != nil {
+		report["config"] = metrics.Config
+		if err := sealResults(report); err != nil {
+			fmt.Printf("Error computing the results checksum: %v\n", err)
 		}
 	}
 
@@ -829,16 +1951,20 @@ func printFinalReport(metrics *Metrics) {
 	reportJSON, _ := json.MarshalIndent(report, "", "  ")
 
 	// Print to console
-	fmt.Println("\nFinal Test Results:")
-	fmt.Println(string(reportJSON))
+	if printJSON {
+		fmt.Println("\nFinal Test Results:")
+		fmt.Println(string(reportJSON))
+	}
+	printSummary(report, causes, checks)
 
 	// Save to file
-	err := os.WriteFile("saleor_results.json", reportJSON, 0644)
+	path, err := output.write("saleor", metrics.StartTime, reportJSON)
 	if err != nil {
 		fmt.Printf("Error writing results file: %v\n", err)
 	} else {
-		fmt.Println("\nDetailed results saved to saleor_results.json")
+		fmt.Printf("\nDetailed results saved to %s\n", path)
 	}
+	return thresholdsPassed(checks) && sloMet
 }
 
 // calculateMeanDuration calculates the mean of a slice of durations
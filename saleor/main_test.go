@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// graphqlErrorServer answers every query, single or batched, with a 200 and
+// a GraphQL error, the way Saleor reports a failed query
+func graphqlErrorServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body json.RawMessage
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		result := map[string]interface{}{
+			"data":   nil,
+			"errors": []map[string]string{{"message": "Internal error"}},
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if len(body) > 0 && body[0] == '[' {
+			var batch []json.RawMessage
+			json.Unmarshal(body, &batch)
+			results := make([]interface{}, len(batch))
+			for i := range results {
+				results[i] = result
+			}
+			json.NewEncoder(w).Encode(results)
+			return
+		}
+		json.NewEncoder(w).Encode(result)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// errorPool returns a worker pool against url with error sampling off
+func errorPool(url string) *WorkerPool {
+	config := &Config{GraphQLURL: url}
+	config.Test.LogErrors = false
+	config.Test.ErrorSampleRate = 0
+	return NewWorkerPool(4, 100, url, map[string]string{"Content-Type": "application/json"}, NewMetrics(), config)
+}
+
+func checkAllFailed(t *testing.T, metrics *Metrics, want int64) {
+	t.Helper()
+	if metrics.TotalRequests != want {
+		t.Fatalf("TotalRequests = %d, want %d", metrics.TotalRequests, want)
+	}
+	if metrics.FailedRequests != metrics.TotalRequests || metrics.SuccessfulRequests != 0 {
+		t.Errorf("FailedRequests = %d, SuccessfulRequests = %d of %d requests, want every request failed",
+			metrics.FailedRequests, metrics.SuccessfulRequests, metrics.TotalRequests)
+	}
+	if len(metrics.ErrorSamples) != 0 {
+		t.Errorf("%d error samples kept with LogErrors off", len(metrics.ErrorSamples))
+	}
+}
+
+// GraphQL errors fail the request even when error sampling keeps no sample
+func TestGraphQLErrorsCountWithoutSampling(t *testing.T) {
+	pool := errorPool(graphqlErrorServer(t).URL)
+	pool.Start()
+	for i := 0; i < 50; i++ {
+		pool.Tasks <- Task{Operation: "products", Query: "query { products(first: 1) { edges { node { id } } } }"}
+	}
+	close(pool.Tasks)
+	pool.WaitGroup.Wait()
+
+	checkAllFailed(t, pool.Metrics, 50)
+	if failures := pool.Metrics.OperationFailures["products"]; failures != 50 {
+		t.Errorf("products failures = %d, want 50", failures)
+	}
+}
+
+func TestBatchedGraphQLErrorsCountWithoutSampling(t *testing.T) {
+	pool := errorPool(graphqlErrorServer(t).URL)
+	pool.Start()
+	for i := 0; i < 10; i++ {
+		batch := Task{Operation: batchOperation}
+		for _, operation := range []string{"products", "categories", "specificProduct"} {
+			batch.Batch = append(batch.Batch, Task{Operation: operation, Query: "query { shop { name } }"})
+		}
+		pool.Tasks <- batch
+	}
+	close(pool.Tasks)
+	pool.WaitGroup.Wait()
+
+	checkAllFailed(t, pool.Metrics, 30)
+	for _, operation := range []string{"products", "categories", "specificProduct"} {
+		if failures := pool.Metrics.OperationFailures[operation]; failures != 10 {
+			t.Errorf("%s failures = %d, want 10", operation, failures)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync/atomic"
+	"time"
+)
+
+// sourceIPDialer binds outgoing connections to a set of local addresses in
+// round-robin order. Spreading connections over several source IPs avoids
+// ephemeral port exhaustion and makes the load look like it comes from
+// multiple clients, which matters for targets that rate-limit per IP.
+//
+// A socket bound to an IPv6 address cannot reach an IPv4 target and vice
+// versa, so the addresses are kept per family and each connection rotates
+// over those of the target's family.
+type sourceIPDialer struct {
+	v4, v6 dialerPool
+	plain  *net.Dialer // no source addresses configured
+}
+
+// dialerPool rotates over the dialers of one address family
+type dialerPool struct {
+	dialers []*net.Dialer
+	next    atomic.Uint64
+}
+
+func (p *dialerPool) pick() *net.Dialer {
+	n := p.next.Add(1) - 1
+	return p.dialers[n%uint64(len(p.dialers))]
+}
+
+// newSourceIPDialer creates a dialer for the configured source addresses.
+// Entries may be IP addresses or interface names; an interface expands to
+// all of its unicast addresses. An empty list yields a single default dialer.
+func newSourceIPDialer(sourceIPs []string, timeout, keepAlive time.Duration) (*sourceIPDialer, error) {
+	d := &sourceIPDialer{}
+
+	for _, entry := range sourceIPs {
+		ips, err := resolveSourceIPs(entry)
+		if err != nil {
+			return nil, err
+		}
+		for _, ip := range ips {
+			dialer := &net.Dialer{
+				Timeout:   timeout,
+				KeepAlive: keepAlive,
+				LocalAddr: &net.TCPAddr{IP: ip},
+			}
+			if ip.To4() != nil {
+				d.v4.dialers = append(d.v4.dialers, dialer)
+			} else {
+				d.v6.dialers = append(d.v6.dialers, dialer)
+			}
+		}
+	}
+
+	if d.Count() == 0 {
+		d.plain = &net.Dialer{
+			Timeout:   timeout,
+			KeepAlive: keepAlive,
+		}
+	}
+
+	return d, nil
+}
+
+// resolveSourceIPs turns a SourceIPs entry into one or more local IPs
+func resolveSourceIPs(entry string) ([]net.IP, error) {
+	if ip := net.ParseIP(entry); ip != nil {
+		return []net.IP{ip}, nil
+	}
+
+	iface, err := net.InterfaceByName(entry)
+	if err != nil {
+		return nil, fmt.Errorf("%q is neither an IP address nor a network interface: %v", entry, err)
+	}
+
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list addresses of interface %s: %v", entry, err)
+	}
+
+	var ips []net.IP
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.IsGlobalUnicast() {
+			ips = append(ips, ipNet.IP)
+		}
+	}
+	if len(ips) == 0 {
+		return nil, fmt.Errorf("interface %s has no usable unicast addresses", entry)
+	}
+
+	return ips, nil
+}
+
+// Count returns the number of local addresses connections are spread over
+func (d *sourceIPDialer) Count() int {
+	return len(d.v4.dialers) + len(d.v6.dialers)
+}
+
+// DialContext dials using the next local address of the target's family
+func (d *sourceIPDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	if d.plain != nil {
+		return d.plain.DialContext(ctx, network, addr)
+	}
+	pool, err := d.family(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+	return pool.pick().DialContext(ctx, network, addr)
+}
+
+// family returns the dialers for addr. With addresses of both families the
+// target is resolved, preferring IPv4 when it has both.
+func (d *sourceIPDialer) family(ctx context.Context, addr string) (*dialerPool, error) {
+	switch {
+	case len(d.v6.dialers) == 0:
+		return &d.v4, nil
+	case len(d.v4.dialers) == 0:
+		return &d.v6, nil
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		if ip.To4() != nil {
+			return &d.v4, nil
+		}
+		return &d.v6, nil
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range addrs {
+		if a.IP.To4() != nil {
+			return &d.v4, nil
+		}
+	}
+	return &d.v6, nil
+}
+
+// isTimeoutError reports whether err was caused by the client or a network timeout
+func isTimeoutError(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
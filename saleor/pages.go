@@ -0,0 +1,206 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+// PageConfig groups API calls a page makes at once into one named page load,
+// e.g. a product page fetching the product, related products and reviews
+type PageConfig struct {
+	Name       string   // e.g. "pdp"
+	Percent    float64  // share of all requests that load this page instead
+	Operations []string // calls sent concurrently, named like journey operations
+}
+
+// pageLoad is one load of a page
+type pageLoad struct {
+	name  string
+	calls []Task
+}
+
+// pageCall is filled in by the executor with the outcome of one of a page's calls
+type pageCall struct {
+	duration time.Duration
+	success  bool
+}
+
+// set records the call's outcome; safe to call on a nil pageCall
+func (c *pageCall) set(duration time.Duration, success bool) {
+	if c == nil {
+		return
+	}
+	c.duration, c.success = duration, success
+}
+
+// pageStats are the loads of one page
+type pageStats struct {
+	loads     int64
+	failed    int64 // loads with at least one failed call
+	blocked   int64 // loads whose calls could not be built
+	durations []time.Duration
+}
+
+const pageSamples = 20000
+
+// pageComposer picks page loads and records page-level latency
+type pageComposer struct {
+	pages []PageConfig
+
+	mutex sync.Mutex
+	stats map[string]*pageStats
+}
+
+// newPageComposer validates the pages; known reports whether the runner can
+// request an operation. It returns nil when no pages are configured.
+func newPageComposer(pages []PageConfig, known func(string) bool) (*pageComposer, error) {
+	if len(pages) == 0 {
+		return nil, nil
+	}
+	total := 0.0
+	c := &pageComposer{pages: pages, stats: make(map[string]*pageStats)}
+	for i, page := range pages {
+		if page.Name == "" || len(page.Operations) == 0 {
+			return nil, fmt.Errorf("page %d needs a Name and Operations", i+1)
+		}
+		if _, ok := c.stats[page.Name]; ok {
+			return nil, fmt.Errorf("page %q is defined twice", page.Name)
+		}
+		for _, op := range page.Operations {
+			if !known(op) {
+				return nil, fmt.Errorf("page %q: unknown operation %q", page.Name, op)
+			}
+		}
+		c.stats[page.Name] = &pageStats{}
+		total += page.Percent
+	}
+	if total > 100 {
+		return nil, fmt.Errorf("pages add up to %.1f%%, above 100", total)
+	}
+	return c, nil
+}
+
+// pick decides whether the next request loads a page. A nil composer never
+// picks.
+func (c *pageComposer) pick() (PageConfig, bool) {
+	if c == nil {
+		return PageConfig{}, false
+	}
+	n := rand.Float64() * 100
+	for _, page := range c.pages {
+		if n < page.Percent {
+			return page, true
+		}
+		n -= page.Percent
+	}
+	return PageConfig{}, false
+}
+
+// block counts a page load whose calls could not be built, e.g. no cart
+// exists yet for a cart page
+func (c *pageComposer) block(name string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.stats[name].blocked++
+}
+
+// record counts a finished page load; a page takes as long as its slowest call
+func (c *pageComposer) record(name string, calls []pageCall) {
+	slowest := time.Duration(0)
+	failed := false
+	for _, call := range calls {
+		if call.duration > slowest {
+			slowest = call.duration
+		}
+		if !call.success {
+			failed = true
+		}
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	stats := c.stats[name]
+	stats.loads++
+	if failed {
+		stats.failed++
+	}
+	if len(stats.durations) < pageSamples {
+		stats.durations = append(stats.durations, slowest)
+	} else if i := rand.Int63n(stats.loads); i < pageSamples {
+		stats.durations[i] = slowest
+	}
+}
+
+// report lists the page-level latency and errors of each page
+func (c *pageComposer) report() map[string]interface{} {
+	if c == nil {
+		return nil
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	report := make(map[string]interface{}, len(c.pages))
+	for _, page := range c.pages {
+		stats := c.stats[page.Name]
+		entry := map[string]interface{}{
+			"calls": page.Operations,
+			"loads": stats.loads,
+		}
+		if stats.blocked > 0 {
+			// A normal request was sent instead
+			entry["blocked"] = stats.blocked
+		}
+		if stats.loads > 0 {
+			entry["failedLoads"] = stats.failed
+			entry["errorRate"] = fmt.Sprintf("%.2f%%", float64(stats.failed)/float64(stats.loads)*100)
+			sorted := append([]time.Duration(nil), stats.durations...)
+			sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+			entry["latency"] = map[string]string{
+				"p50": percentileDuration(sorted, 0.5).String(),
+				"p95": percentileDuration(sorted, 0.95).String(),
+				"p99": percentileDuration(sorted, 0.99).String(),
+				"max": sorted[len(sorted)-1].String(),
+			}
+		}
+		report[page.Name] = entry
+	}
+	return report
+}
+
+// pageTask builds a page load from the journey operations of its calls
+func (g *LoadGenerator) pageTask(page PageConfig) (Task, bool) {
+	load := &pageLoad{name: page.Name}
+	for _, op := range page.Operations {
+		call, ok := g.journeyTask(op)
+		if !ok {
+			g.Pool.Pages.block(page.Name)
+			return Task{}, false
+		}
+		load.calls = append(load.calls, call)
+	}
+	return Task{Operation: page.Name, Page: load}, true
+}
+
+// loadPage sends a page's calls concurrently, like a browser rendering the
+// page would, and records the page load once all of them finished
+func (p *WorkerPool) loadPage(class *ClientClass, load *pageLoad) {
+	results := make([]pageCall, len(load.calls))
+	var wg sync.WaitGroup
+	for i := range load.calls {
+		call := load.calls[i]
+		call.Result = &results[i]
+		class.apply(&call)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.Limiter.acquire(call.Operation)
+			p.executeGraphQLTask(call)
+			p.Limiter.release(call.Operation)
+		}()
+	}
+	wg.Wait()
+	p.Pages.record(load.name, results)
+}
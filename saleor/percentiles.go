@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// StatisticsConfig adds robust statistics to the latency sections of the results
+type StatisticsConfig struct {
+	// Also report the mean without the fastest and slowest TrimPercent of
+	// durations (trimmedMean) and with them clamped to the remaining range
+	// (winsorizedMean), e.g. 5. 0 = off.
+	TrimPercent float64
+
+	// Report each percentile's 95% confidence interval and how many
+	// recorded durations lie above it, to show when a tail percentile rests
+	// on a handful of points
+	Confidence bool
+}
+
+// minTailSamples is the number of recorded durations above a percentile
+// below which it is marked lowConfidence
+const minTailSamples = 10
+
+// reportedPercentiles are the percentiles in every latency section of the
+// results: overall, per operation, tag, variant and canary. Test.Percentiles
+// replaces them, e.g. [50, 99, 99.9, 99.99] for a tail-focused test.
+var reportedPercentiles = []float64{50, 90, 95, 99}
+
+// requiredPercentiles are reported whatever Test.Percentiles lists: the
+// console summary and the P95/P99 thresholds read them
+var requiredPercentiles = []float64{95, 99}
+
+// latencyStatistics is set from Test.Statistics
+var latencyStatistics StatisticsConfig
+
+// setPercentiles installs the configured percentiles and statistics; an
+// empty list keeps the default percentiles, and any other list gets the
+// required ones added
+func setPercentiles(percentiles []float64, statistics StatisticsConfig) error {
+	if statistics.TrimPercent < 0 || statistics.TrimPercent >= 50 {
+		return fmt.Errorf("TrimPercent %v is not between 0 and 50", statistics.TrimPercent)
+	}
+	latencyStatistics = statistics
+	if len(percentiles) == 0 {
+		return nil
+	}
+	sorted := append(append([]float64(nil), percentiles...), requiredPercentiles...)
+	sort.Float64s(sorted)
+	unique := sorted[:0]
+	for _, p := range sorted {
+		if p <= 0 || p >= 100 {
+			return fmt.Errorf("percentile %v is not between 0 and 100", p)
+		}
+		if len(unique) == 0 || unique[len(unique)-1] != p {
+			unique = append(unique, p)
+		}
+	}
+	reportedPercentiles = unique
+	return nil
+}
+
+// percentileLabel names a percentile in the results, e.g. "p99.9"
+func percentileLabel(p float64) string {
+	return "p" + strconv.FormatFloat(p, 'f', -1, 64)
+}
+
+// latencyPercentiles returns the reported percentiles of sorted durations,
+// and the trimmed and winsorized means if TrimPercent is set
+func latencyPercentiles(sorted []time.Duration) map[string]string {
+	latency := make(map[string]string, len(reportedPercentiles)+5)
+	for _, p := range reportedPercentiles {
+		latency[percentileLabel(p)] = percentileDuration(sorted, p/100).String()
+	}
+	if trimmed, winsorized, ok := robustMeans(sorted, latencyStatistics.TrimPercent); ok {
+		latency["trimmedMean"] = trimmed.String()
+		latency["winsorizedMean"] = winsorized.String()
+	}
+	return latency
+}
+
+// robustMeans returns the trimmed and winsorized means of sorted durations,
+// cutting trimPercent from each end
+func robustMeans(sorted []time.Duration, trimPercent float64) (time.Duration, time.Duration, bool) {
+	n := len(sorted)
+	k := int(float64(n) * trimPercent / 100)
+	if trimPercent <= 0 || n-2*k <= 0 {
+		return 0, 0, false
+	}
+	var trimmed, winsorized float64
+	for i, d := range sorted {
+		switch {
+		case i < k:
+			winsorized += float64(sorted[k])
+		case i >= n-k:
+			winsorized += float64(sorted[n-k-1])
+		default:
+			trimmed += float64(d)
+			winsorized += float64(d)
+		}
+	}
+	return time.Duration(trimmed / float64(n-2*k)), time.Duration(winsorized / float64(n)), true
+}
+
+// percentileConfidence returns, for each reported percentile, the 95%
+// confidence interval from the order statistics of the recorded durations
+// (no assumption about the distribution) and the number of durations above
+// it. It returns nil unless Confidence is set.
+func percentileConfidence(sorted []time.Duration) map[string]interface{} {
+	if !latencyStatistics.Confidence || len(sorted) == 0 {
+		return nil
+	}
+	n := float64(len(sorted))
+	clamp := func(i int) int {
+		return int(math.Max(0, math.Min(float64(i), n-1)))
+	}
+	confidence := map[string]interface{}{"samples": len(sorted)}
+	for _, p := range reportedPercentiles {
+		q := p / 100
+		spread := 1.96 * math.Sqrt(n*q*(1-q))
+		above := len(sorted) - 1 - clamp(int(n*q))
+		entry := map[string]interface{}{
+			"low":          sorted[clamp(int(math.Floor(n*q-spread)))].String(),
+			"high":         sorted[clamp(int(math.Ceil(n*q+spread)))].String(),
+			"samplesAbove": above,
+		}
+		if above < minTailSamples {
+			entry["lowConfidence"] = true
+		}
+		confidence[percentileLabel(p)] = entry
+	}
+	return confidence
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// The load generator moves linearly from the previous stage's rate to the
+// stage's TargetRPS; the first stage starts at its own rate
+func TestExpectedStagedRequests(t *testing.T) {
+	for _, c := range []struct {
+		name     string
+		stages   []Stage
+		duration time.Duration
+		want     int64
+	}{
+		{"hold", []Stage{{Duration: 10 * time.Second, TargetRPS: 20}}, 0, 200},
+		{"ramp", []Stage{
+			{Duration: 10 * time.Second, TargetRPS: 10},
+			{Duration: 20 * time.Second, TargetRPS: 50}, // 10 to 50 RPS, 30 on average
+			{Duration: 10 * time.Second, TargetRPS: 0},  // 50 to 0 RPS
+		}, 0, 100 + 600 + 250},
+		{"cut by Duration", []Stage{
+			{Duration: 10 * time.Second, TargetRPS: 10},
+			{Duration: 20 * time.Second, TargetRPS: 50},
+		}, 20 * time.Second, 100 + 200}, // halfway through the ramp, at 30 RPS: 20 on average
+		{"no stages", nil, 0, 0},
+	} {
+		config := &Config{}
+		config.Test.RampupStages = c.stages
+		config.Test.Duration = c.duration
+		if got := expectedStagedRequests(config); got != c.want {
+			t.Errorf("%s: %d requests, want %d", c.name, got, c.want)
+		}
+	}
+}
+
+func TestPlannedDuration(t *testing.T) {
+	config := &Config{}
+	config.Test.RampupStages = []Stage{{Duration: time.Minute}, {Duration: 2 * time.Minute}}
+	if got := plannedDuration(config); got != 3*time.Minute {
+		t.Errorf("stages: %s", got)
+	}
+	config.Test.Duration = 90 * time.Second
+	if got := plannedDuration(config); got != 90*time.Second {
+		t.Errorf("stages cut by Duration: %s", got)
+	}
+	config.Test.AdaptiveRPS = true
+	config.Test.Duration = 0
+	if got := plannedDuration(config); got != 0 {
+		t.Errorf("adaptive without Duration: %s, want until interrupted", got)
+	}
+}
+
+func TestExpectedPhasedRequests(t *testing.T) {
+	phases := []LoadPhase{
+		{Model: "ramp", Duration: 10 * time.Second, RPS: 20},                                                                    // 0 to 20 RPS
+		{Model: "steady", Duration: 10 * time.Second, RPS: 20},                                                                  // 20 RPS
+		{Model: "spike", Duration: 10 * time.Second, RPS: 20, PeakRPS: 60, SpikeAt: 2 * time.Second, SpikeFor: 3 * time.Second}, // 40 more for 3s
+	}
+	if got := expectedPhasedRequests(phases, 30*time.Second); got != 100+200+200+120 {
+		t.Errorf("%d requests, want 620", got)
+	}
+	if got := expectedPhasedRequests(phases, 15*time.Second); got != 100+100 {
+		t.Errorf("cut in the second phase: %d requests, want 200", got)
+	}
+}
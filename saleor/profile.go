@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// applyRPSProfile replaces the configured stages with the CSV profile named by
+// Test.ProfileCSV, resolved relative to the config file's directory
+func applyRPSProfile(config *Config, configPath string) error {
+	if config.Test.ProfileCSV == "" {
+		return nil
+	}
+	if config.Test.AdaptiveRPS || config.Test.BurstMode {
+		return fmt.Errorf("ProfileCSV cannot be combined with AdaptiveRPS or BurstMode")
+	}
+
+	path := config.Test.ProfileCSV
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(filepath.Dir(configPath), path)
+	}
+	stages, err := loadRPSProfile(path, config.Test.ProfileScale)
+	if err != nil {
+		return err
+	}
+	config.Test.RampupStages = stages
+	return nil
+}
+
+// parseProfileOffset parses a profile time offset: HH:MM:SS, HH:MM, a Go
+// duration such as "90s", or a plain number of seconds
+func parseProfileOffset(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if strings.Contains(s, ":") {
+		parts := strings.Split(s, ":")
+		if len(parts) > 3 {
+			return 0, fmt.Errorf("invalid offset %q", s)
+		}
+		var total float64
+		for _, part := range parts {
+			v, err := strconv.ParseFloat(part, 64)
+			if err != nil {
+				return 0, fmt.Errorf("invalid offset %q", s)
+			}
+			total = total*60 + v
+		}
+		if len(parts) == 2 {
+			total *= 60 // HH:MM
+		}
+		return time.Duration(total * float64(time.Second)), nil
+	}
+	if seconds, err := strconv.ParseFloat(s, 64); err == nil {
+		return time.Duration(seconds * float64(time.Second)), nil
+	}
+	return time.ParseDuration(s)
+}
+
+// loadRPSProfile reads a CSV of (time offset, target RPS) rows and turns it
+// into stages the load generator interpolates between, so a recorded traffic
+// shape can be replayed. A header row is skipped. scale multiplies every RPS
+// value (0 means 1).
+func loadRPSProfile(path string, scale float64) ([]Stage, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	if scale <= 0 {
+		scale = 1
+	}
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	reader.Comment = '#'
+
+	type point struct {
+		offset time.Duration
+		rps    float64
+	}
+	var points []point
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(record) < 2 {
+			return nil, fmt.Errorf("line %d: expected offset,rps", line)
+		}
+
+		rps, rpsErr := strconv.ParseFloat(strings.TrimSpace(record[1]), 64)
+		offset, offsetErr := parseProfileOffset(record[0])
+		if rpsErr != nil || offsetErr != nil {
+			if line == 1 && len(points) == 0 {
+				continue // header
+			}
+			return nil, fmt.Errorf("line %d: cannot parse %q", line, strings.Join(record, ","))
+		}
+		if len(points) > 0 && offset <= points[len(points)-1].offset {
+			return nil, fmt.Errorf("line %d: offsets must increase", line)
+		}
+		points = append(points, point{offset, rps * scale})
+	}
+
+	if len(points) < 2 {
+		return nil, fmt.Errorf("%s: a profile needs at least two points", path)
+	}
+
+	// A zero-length first stage sets the starting rate; each following stage
+	// ramps linearly from the previous point to the next one
+	stages := []Stage{{Duration: 0, TargetRPS: points[0].rps, Description: fmt.Sprintf("Profile start at %s RPS", formatRPS(points[0].rps))}}
+	for i := 1; i < len(points); i++ {
+		stages = append(stages, Stage{
+			Duration:    points[i].offset - points[i-1].offset,
+			TargetRPS:   points[i].rps,
+			Description: fmt.Sprintf("Profile %s: %s RPS", points[i].offset, formatRPS(points[i].rps)),
+		})
+	}
+	return stages, nil
+}
@@ -0,0 +1,11 @@
+//go:build !unix
+
+package main
+
+import "time"
+
+// processCPUTime reports no CPU time where getrusage is unavailable, so the
+// CPU guard never pauses and the CPU percentages read 0
+func processCPUTime() time.Duration {
+	return 0
+}
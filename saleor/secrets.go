@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// redactedValue replaces a secret in the results
+const redactedValue = "[REDACTED]"
+
+// secretKey reports whether a config field, header or query parameter name
+// holds a secret
+func secretKey(name string) bool {
+	lower := strings.ToLower(name)
+	switch lower {
+	case "authorization", "proxy-authorization", "cookie", "x-publishable-api-key", "apikey", "key":
+		return true
+	}
+	for _, part := range []string{"token", "secret", "password", "api-key", "api_key"} {
+		if strings.Contains(lower, part) {
+			return true
+		}
+	}
+	return false
+}
+
+// redact replaces the values of secret fields, and credentials in other
+// strings, in a decoded JSON value
+func redact(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for key, field := range value {
+			if s, ok := field.(string); ok && s != "" && secretKey(key) {
+				value[key] = redactedValue
+			} else {
+				value[key] = redact(field)
+			}
+		}
+	case []interface{}:
+		for i := range value {
+			value[i] = redact(value[i])
+		}
+	case string:
+		// URLs with a password or a token parameter
+		return redactText(value)
+	}
+	return v
+}
+
+// knownSecrets are the secret values of the config, longest first, so text
+// echoing them (an error page repeating a header, say) can be redacted
+var knownSecrets []string
+
+// registerSecrets collects the secret values of the config, and the
+// credentials resolved into it, for redactText
+func registerSecrets(config *Config) {
+	data, err := json.Marshal(config)
+	if err != nil {
+		return
+	}
+	var decoded interface{}
+	if json.Unmarshal(data, &decoded) != nil {
+		return
+	}
+	// Short values like "1" would redact unrelated text
+	var secrets []string
+	for _, s := range resolvedCredentials {
+		if len(s) >= 6 {
+			secrets = append(secrets, s)
+		}
+	}
+	var collect func(v interface{})
+	collect = func(v interface{}) {
+		switch value := v.(type) {
+		case map[string]interface{}:
+			for key, field := range value {
+				if s, ok := field.(string); ok && len(s) >= 6 && secretKey(key) {
+					secrets = append(secrets, s)
+				} else {
+					collect(field)
+				}
+			}
+		case []interface{}:
+			for _, item := range value {
+				collect(item)
+			}
+		}
+	}
+	collect(decoded)
+	sort.Slice(secrets, func(i, j int) bool { return len(secrets[i]) > len(secrets[j]) })
+	knownSecrets = secrets
+}
+
+var (
+	// Credentials that look like one wherever they appear: authorization
+	// schemes, Stripe/Medusa-style keys, URL passwords and secret query
+	// parameters
+	authScheme  = regexp.MustCompile(`(?i)\b(bearer|basic|token)\s+[A-Za-z0-9._~+/=-]{8,}`)
+	prefixedKey = regexp.MustCompile(`\b(pk|sk|rk)_[A-Za-z0-9]{16,}`)
+	urlPassword = regexp.MustCompile(`(://[^:/@\s"]+):[^@/\s"]+@`)
+	secretParam = regexp.MustCompile(`(?i)([?&][a-z0-9_-]*(?:token|key|secret|password)[a-z0-9_-]*=)[^&\s"]+`)
+)
+
+// redactText replaces the config's secrets and anything that looks like a
+// credential in text bound for the results or logs: URLs, error messages
+// and response bodies
+func redactText(s string) string {
+	if s == "" {
+		return s
+	}
+	for _, secret := range knownSecrets {
+		s = strings.ReplaceAll(s, secret, redactedValue)
+	}
+	s = authScheme.ReplaceAllString(s, "$1 "+redactedValue)
+	s = prefixedKey.ReplaceAllString(s, "${1}_"+redactedValue)
+	s = urlPassword.ReplaceAllString(s, "$1:"+redactedValue+"@")
+	return secretParam.ReplaceAllString(s, "${1}"+redactedValue)
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// SuccessRule overrides what counts as success for one operation, e.g. 404
+// for a product looked up by random ID, or an empty product list as a failure
+type SuccessRule struct {
+	// Accepted status codes; any 2xx when empty
+	Statuses []int
+
+	// Dot-separated JSON paths (e.g. "products" or "data.products.edges") that
+	// must be present and non-empty in 2xx responses
+	NonEmpty []string
+}
+
+// statusAccepted reports whether the status counts as success for the operation
+func statusAccepted(rules map[string]SuccessRule, operation string, status int) bool {
+	rule, ok := rules[baseOperation(operation)]
+	if !ok || len(rule.Statuses) == 0 {
+		return status >= 200 && status < 300
+	}
+	for _, s := range rule.Statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// needsBody reports whether the operation's rule inspects the response body
+func needsBody(rules map[string]SuccessRule, operation string) bool {
+	return len(rules[baseOperation(operation)].NonEmpty) > 0
+}
+
+// checkBody returns why a 2xx response body fails the operation's rule, or "" if it passes
+func checkBody(rules map[string]SuccessRule, operation string, status int, body []byte) string {
+	rule := rules[baseOperation(operation)]
+	if len(rule.NonEmpty) == 0 || status < 200 || status >= 300 {
+		return ""
+	}
+
+	var doc interface{}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return fmt.Sprintf("success criteria: response is not JSON: %v", err)
+	}
+	for _, path := range rule.NonEmpty {
+		value, ok := lookupPath(doc, path)
+		if !ok {
+			return fmt.Sprintf("success criteria: %s missing", path)
+		}
+		if isEmptyValue(value) {
+			return fmt.Sprintf("success criteria: %s is empty", path)
+		}
+	}
+	return ""
+}
+
+// lookupPath follows a dot-separated path through objects and (by index) arrays
+func lookupPath(doc interface{}, path string) (interface{}, bool) {
+	value := doc
+	for _, key := range strings.Split(path, ".") {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			next, ok := v[key]
+			if !ok {
+				return nil, false
+			}
+			value = next
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, false
+			}
+			value = v[i]
+		default:
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// isEmptyValue treats null, "", [] and {} as empty
+func isEmptyValue(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	case []interface{}:
+		return len(v) == 0
+	case map[string]interface{}:
+		return len(v) == 0
+	}
+	return false
+}
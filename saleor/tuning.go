@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// generatorTuning holds the load generator settings of the command line.
+// Each generator paces its share of the rate by sleeping until its next
+// send; Tick bounds those sleeps, so a new rate (the next point of a ramp,
+// an adaptive step) is picked up within one tick.
+type generatorTuning struct {
+	Generators int           // goroutines pacing and queueing tasks
+	Tick       time.Duration // interval of the rate updates and longest pacing sleep
+}
+
+// validate checks the flag values
+func (t generatorTuning) validate() error {
+	if t.Generators < 1 {
+		return fmt.Errorf("-generators must be at least 1, got %d", t.Generators)
+	}
+	if t.Tick < 10*time.Microsecond || t.Tick > time.Second {
+		return fmt.Errorf("-tick must be between 10µs and 1s, got %s", t.Tick)
+	}
+	return nil
+}
+
+// report records the effective settings in the results
+func (t generatorTuning) report() map[string]interface{} {
+	report := map[string]interface{}{
+		"generators": t.Generators,
+		"tick":       t.Tick.String(),
+	}
+	if cpus := cpusAllowed(); cpus != "" {
+		report["cpusAllowed"] = cpus
+	}
+	return report
+}
+
+// cpusAllowed returns the CPUs the process may run on (Linux), e.g. "0-3"
+// when pinned with taskset or a cpuset
+func cpusAllowed() string {
+	f, err := os.Open("/proc/self/status")
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if value, ok := strings.CutPrefix(scanner.Text(), "Cpus_allowed_list:"); ok {
+			return strings.TrimSpace(value)
+		}
+	}
+	return ""
+}
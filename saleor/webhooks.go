@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// WebhookConfig starts a listener for the webhooks the platform sends while
+// under load (order created, product updated, ...). Each webhook is matched to
+// the request that caused it by an entity ID found in both the request's
+// response (ResponseField) and the webhook payload (CorrelationField), giving
+// the end-to-end latency of the asynchronous processing.
+type WebhookConfig struct {
+	Listen           string        // e.g. ":9099"; empty disables the receiver
+	Path             string        // default "/webhooks"
+	Operations       []string      // operations whose responses are tracked, e.g. ["upload"]
+	ResponseField    string        // dot path of the entity ID in the response, e.g. "id"
+	CorrelationField string        // dot path of the same ID in the webhook, e.g. "data.id"
+	EventField       string        // dot path of the event name (default: Saleor-Event header)
+	Timeout          time.Duration // a request without a webhook after this long is missing, default 60s
+	Drain            time.Duration // wait at the end for outstanding webhooks, default 10s
+}
+
+// pendingWebhook is a tracked request waiting for its webhook
+type pendingWebhook struct {
+	operation string
+	sent      time.Time
+	responded time.Time
+}
+
+// webhookReceiver correlates incoming webhooks with tracked requests
+type webhookReceiver struct {
+	config     WebhookConfig
+	operations map[string]bool
+	server     *http.Server
+	listener   net.Listener
+
+	mutex      sync.Mutex
+	pending    map[string]pendingWebhook
+	tracked    int64
+	received   int64
+	unmatched  int64
+	missing    int64
+	latencies  map[string][]time.Duration // per event, from request start
+	processing map[string][]time.Duration // per event, from the response
+}
+
+// newWebhookReceiver validates the configuration and starts listening; it
+// returns nil when the receiver is off
+func newWebhookReceiver(config WebhookConfig) (*webhookReceiver, error) {
+	if config.Listen == "" {
+		return nil, nil
+	}
+	if len(config.Operations) == 0 || config.ResponseField == "" || config.CorrelationField == "" {
+		return nil, fmt.Errorf("webhooks need Operations, ResponseField and CorrelationField")
+	}
+	if config.Path == "" {
+		config.Path = "/webhooks"
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 60 * time.Second
+	}
+	if config.Drain <= 0 {
+		config.Drain = 10 * time.Second
+	}
+
+	listener, err := net.Listen("tcp", config.Listen)
+	if err != nil {
+		return nil, err
+	}
+	w := &webhookReceiver{
+		config:     config,
+		operations: make(map[string]bool, len(config.Operations)),
+		listener:   listener,
+		pending:    make(map[string]pendingWebhook),
+		latencies:  make(map[string][]time.Duration),
+		processing: make(map[string][]time.Duration),
+	}
+	for _, op := range config.Operations {
+		w.operations[op] = true
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(config.Path, w.handle)
+	w.server = &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go w.server.Serve(listener)
+	return w, nil
+}
+
+// wants reports whether the operation's responses are tracked
+func (w *webhookReceiver) wants(operation string) bool {
+	return w != nil && w.operations[baseOperation(operation)]
+}
+
+// track registers a successful response so its webhook can be matched
+func (w *webhookReceiver) track(operation string, sent, responded time.Time, body []byte) {
+	if !w.wants(operation) {
+		return
+	}
+	var doc interface{}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return
+	}
+	id, ok := lookupPath(doc, w.config.ResponseField)
+	if !ok || id == nil {
+		return
+	}
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.pending[fmt.Sprint(id)] = pendingWebhook{operation: operation, sent: sent, responded: responded}
+	w.tracked++
+}
+
+// handle receives one webhook
+func (w *webhookReceiver) handle(rw http.ResponseWriter, r *http.Request) {
+	received := time.Now()
+	body, err := io.ReadAll(io.LimitReader(r.Body, 10<<20))
+	rw.WriteHeader(http.StatusOK)
+	if err != nil {
+		return
+	}
+
+	var doc interface{}
+	json.Unmarshal(body, &doc)
+	event := r.Header.Get("Saleor-Event")
+	if w.config.EventField != "" {
+		if v, ok := lookupPath(doc, w.config.EventField); ok {
+			event = fmt.Sprint(v)
+		}
+	}
+	if event == "" {
+		event = "unknown"
+	}
+	id, ok := lookupPath(doc, w.config.CorrelationField)
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.received++
+	if !ok {
+		w.unmatched++
+		return
+	}
+	p, found := w.pending[fmt.Sprint(id)]
+	if !found {
+		w.unmatched++
+		return
+	}
+	delete(w.pending, fmt.Sprint(id))
+	w.latencies[event] = append(w.latencies[event], received.Sub(p.sent))
+	w.processing[event] = append(w.processing[event], received.Sub(p.responded))
+}
+
+// expire counts pending requests older than Timeout as missing
+func (w *webhookReceiver) expire(now time.Time) {
+	for id, p := range w.pending {
+		if now.Sub(p.responded) > w.config.Timeout {
+			delete(w.pending, id)
+			w.missing++
+		}
+	}
+}
+
+// Stop waits up to Drain for outstanding webhooks, then stops listening
+func (w *webhookReceiver) Stop() {
+	if w == nil {
+		return
+	}
+	deadline := time.Now().Add(w.config.Drain)
+	for time.Now().Before(deadline) {
+		w.mutex.Lock()
+		w.expire(time.Now())
+		outstanding := len(w.pending)
+		w.mutex.Unlock()
+		if outstanding == 0 {
+			break
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	w.server.Shutdown(ctx)
+}
+
+// report summarizes the async latencies per event
+func (w *webhookReceiver) report() map[string]interface{} {
+	if w == nil {
+		return nil
+	}
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	events := make(map[string]interface{}, len(w.latencies))
+	for event, latencies := range w.latencies {
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+		processing := w.processing[event]
+		sort.Slice(processing, func(i, j int) bool { return processing[i] < processing[j] })
+		events[event] = map[string]interface{}{
+			"count": len(latencies),
+			"endToEnd": map[string]string{
+				"p50": percentileDuration(latencies, 0.5).String(),
+				"p95": percentileDuration(latencies, 0.95).String(),
+				"p99": percentileDuration(latencies, 0.99).String(),
+				"max": latencies[len(latencies)-1].String(),
+			},
+			"afterResponse": map[string]string{
+				"p50": percentileDuration(processing, 0.5).String(),
+				"p95": percentileDuration(processing, 0.95).String(),
+				"p99": percentileDuration(processing, 0.99).String(),
+			},
+		}
+	}
+
+	return map[string]interface{}{
+		"listen":    w.listener.Addr().String() + w.config.Path,
+		"tracked":   w.tracked,
+		"received":  w.received,
+		"unmatched": w.unmatched,
+		// Still outstanding at the end count as missing as well
+		"missing": w.missing + int64(len(w.pending)),
+		"events":  events,
+	}
+}
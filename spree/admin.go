@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"net/url"
+	"strings"
+)
+
+// AdminOperation is one admin API request in the admin workload
+type AdminOperation struct {
+	Name   string // operation name in the results, e.g. "admin_orders"
+	URL    string // absolute, or a path relative to the admin BaseURL
+	Weight int    // relative frequency, default 1
+}
+
+// AdminConfig mixes admin API traffic (merchandising, order management) into
+// the storefront load. Admin endpoints need credentials: Token is sent as a
+// Bearer token, Headers can carry any other auth scheme.
+type AdminConfig struct {
+	Percent    float64
+	BaseURL    string // default: scheme and host of the products endpoint
+	Token      string
+	Headers    map[string]string
+	Operations []AdminOperation // default: order, product and user listings
+}
+
+// defaultAdminOperations lists the Platform API calls a Spree back office makes most
+func defaultAdminOperations() []AdminOperation {
+	return []AdminOperation{
+		{Name: "admin_orders", URL: "/api/v2/platform/orders?per_page=20", Weight: 3},
+		{Name: "admin_products", URL: "/api/v2/platform/products?per_page=20", Weight: 2},
+		{Name: "admin_users", URL: "/api/v2/platform/users?per_page=20", Weight: 1},
+	}
+}
+
+// adminWorkload picks weighted admin operations for a share of the requests
+type adminWorkload struct {
+	percent    float64
+	operations []AdminOperation
+	total      int
+	headers    map[string]string
+}
+
+// newAdminWorkload resolves the operations against the base URL; it returns
+// nil when admin traffic is off
+func newAdminWorkload(config AdminConfig, storefrontURL string) (*adminWorkload, error) {
+	if config.Percent <= 0 {
+		return nil, nil
+	}
+	if config.Percent > 100 {
+		return nil, fmt.Errorf("admin percent %.1f is above 100", config.Percent)
+	}
+	if config.Token == "" && len(config.Headers) == 0 {
+		return nil, fmt.Errorf("admin traffic needs a Token or auth Headers")
+	}
+
+	baseURL := config.BaseURL
+	if baseURL == "" {
+		u, err := url.Parse(storefrontURL)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("admin BaseURL is required")
+		}
+		baseURL = u.Scheme + "://" + u.Host
+	}
+
+	operations := config.Operations
+	if len(operations) == 0 {
+		operations = defaultAdminOperations()
+	}
+	a := &adminWorkload{percent: config.Percent, headers: make(map[string]string)}
+	for _, op := range operations {
+		if op.Name == "" || op.URL == "" {
+			return nil, fmt.Errorf("admin operations need a Name and URL")
+		}
+		if op.Weight <= 0 {
+			op.Weight = 1
+		}
+		if !strings.Contains(op.URL, "://") {
+			op.URL = strings.TrimSuffix(baseURL, "/") + "/" + strings.TrimPrefix(op.URL, "/")
+		}
+		a.operations = append(a.operations, op)
+		a.total += op.Weight
+	}
+
+	if config.Token != "" {
+		a.headers["Authorization"] = "Bearer " + config.Token
+	}
+	for key, value := range config.Headers {
+		a.headers[key] = value
+	}
+	return a, nil
+}
+
+// pick reports whether the next request goes to the admin API. A nil workload never picks.
+func (a *adminWorkload) pick() bool {
+	return a != nil && rand.Float64()*100 < a.percent
+}
+
+// next returns a weighted random admin operation
+func (a *adminWorkload) next() AdminOperation {
+	n := rand.Intn(a.total)
+	for _, op := range a.operations {
+		if n < op.Weight {
+			return op
+		}
+		n -= op.Weight
+	}
+	return a.operations[len(a.operations)-1]
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+)
+
+// AsyncPollConfig follows operations answered with 202 Accepted until the
+// resource they started reaches a terminal state, e.g. a Medusa workflow
+// execution or a batch job
+type AsyncPollConfig struct {
+	Operations    []string      // operations whose 202 responses are followed
+	LocationField string        // dot path of the poll URL in the 202 body; the Location header if empty
+	StatusField   string        // dot path of the state in the poll response, default "status"
+	Terminal      []string      // states that end polling successfully, default ["completed", "succeeded", "done"]
+	Failed        []string      // states that end polling as failed, default ["failed", "canceled", "cancelled"]
+	Interval      time.Duration // between polls, default 500ms
+	Timeout       time.Duration // give up after this long, default 60s
+}
+
+// asyncStats are the poll outcomes of one operation
+type asyncStats struct {
+	accepted  int64
+	completed int64
+	failed    int64
+	timedOut  int64
+	lost      int64 // no poll URL or the poll request failed
+	polls     int64
+	latencies []time.Duration
+}
+
+// asyncPoller runs the pollers and collects completion latencies
+type asyncPoller struct {
+	config     AsyncPollConfig
+	client     *http.Client
+	operations map[string]bool
+	terminal   map[string]bool
+	failed     map[string]bool
+	stopChan   chan struct{}
+	wg         sync.WaitGroup
+
+	mutex sync.Mutex
+	stats map[string]*asyncStats
+}
+
+// newAsyncPoller returns nil when no operations are followed
+func newAsyncPoller(config AsyncPollConfig, client *http.Client) *asyncPoller {
+	if len(config.Operations) == 0 {
+		return nil
+	}
+	if config.StatusField == "" {
+		config.StatusField = "status"
+	}
+	if len(config.Terminal) == 0 {
+		config.Terminal = []string{"completed", "succeeded", "done"}
+	}
+	if len(config.Failed) == 0 {
+		config.Failed = []string{"failed", "canceled", "cancelled"}
+	}
+	if config.Interval <= 0 {
+		config.Interval = 500 * time.Millisecond
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 60 * time.Second
+	}
+
+	a := &asyncPoller{
+		config:     config,
+		client:     client,
+		operations: make(map[string]bool),
+		terminal:   make(map[string]bool),
+		failed:     make(map[string]bool),
+		stopChan:   make(chan struct{}),
+		stats:      make(map[string]*asyncStats),
+	}
+	for _, op := range config.Operations {
+		a.operations[op] = true
+	}
+	for _, s := range config.Terminal {
+		a.terminal[s] = true
+	}
+	for _, s := range config.Failed {
+		a.failed[s] = true
+	}
+	return a
+}
+
+// needsBody reports whether the poll URL has to be read from the response body
+func (a *asyncPoller) needsBody(operation string, status int) bool {
+	return a != nil && status == http.StatusAccepted && a.operations[baseOperation(operation)] && a.config.LocationField != ""
+}
+
+// accepted starts following a 202 response of a followed operation; body is
+// only needed when the poll URL comes from LocationField
+func (a *asyncPoller) accepted(operation string, resp *http.Response, sent time.Time, headers map[string]string, body []byte) {
+	if a == nil || resp.StatusCode != http.StatusAccepted || !a.operations[baseOperation(operation)] {
+		return
+	}
+
+	location := resp.Header.Get("Location")
+	if a.config.LocationField != "" {
+		location = ""
+		var doc interface{}
+		if json.Unmarshal(body, &doc) == nil {
+			if v, ok := lookupPath(doc, a.config.LocationField); ok {
+				location = fmt.Sprint(v)
+			}
+		}
+	}
+	target, err := resp.Request.URL.Parse(location)
+
+	a.mutex.Lock()
+	stats := a.statsFor(operation)
+	stats.accepted++
+	if location == "" || err != nil {
+		stats.lost++
+		a.mutex.Unlock()
+		return
+	}
+	a.mutex.Unlock()
+
+	a.wg.Add(1)
+	go a.poll(operation, target, sent, headers)
+}
+
+// statsFor returns the operation's stats; callers hold the mutex
+func (a *asyncPoller) statsFor(operation string) *asyncStats {
+	stats, ok := a.stats[operation]
+	if !ok {
+		stats = &asyncStats{}
+		a.stats[operation] = stats
+	}
+	return stats
+}
+
+// poll requests the resource until it reaches a terminal state or times out
+func (a *asyncPoller) poll(operation string, target *url.URL, sent time.Time, headers map[string]string) {
+	defer a.wg.Done()
+	deadline := sent.Add(a.config.Timeout)
+	ticker := time.NewTicker(a.config.Interval)
+	defer ticker.Stop()
+
+	outcome := func(record func(s *asyncStats)) {
+		a.mutex.Lock()
+		defer a.mutex.Unlock()
+		record(a.statsFor(operation))
+	}
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-a.stopChan:
+			outcome(func(s *asyncStats) { s.timedOut++ })
+			return
+		}
+		if time.Now().After(deadline) {
+			outcome(func(s *asyncStats) { s.timedOut++ })
+			return
+		}
+
+		state, err := a.fetchState(target, headers)
+		outcome(func(s *asyncStats) { s.polls++ })
+		if err != nil {
+			outcome(func(s *asyncStats) { s.lost++ })
+			return
+		}
+		if a.terminal[state] {
+			completed := time.Since(sent)
+			outcome(func(s *asyncStats) {
+				s.completed++
+				s.latencies = append(s.latencies, completed)
+			})
+			return
+		}
+		if a.failed[state] {
+			outcome(func(s *asyncStats) { s.failed++ })
+			return
+		}
+	}
+}
+
+// fetchState requests the resource once and returns its state
+func (a *asyncPoller) fetchState(target *url.URL, headers map[string]string) (string, error) {
+	req, err := http.NewRequest("GET", target.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	setLoadTestID(req.Header)
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode >= 400 {
+		return "", fmt.Errorf("poll returned %d", resp.StatusCode)
+	}
+
+	var doc interface{}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return "", err
+	}
+	state, _ := lookupPath(doc, a.config.StatusField)
+	return fmt.Sprint(state), nil
+}
+
+// Stop waits for the pollers to finish, at most for one Timeout
+func (a *asyncPoller) Stop() {
+	if a == nil {
+		return
+	}
+	done := make(chan struct{})
+	go func() {
+		a.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(a.config.Timeout):
+		close(a.stopChan)
+		<-done
+	}
+}
+
+// report summarizes completion latency and outcomes per operation
+func (a *asyncPoller) report() map[string]interface{} {
+	if a == nil {
+		return nil
+	}
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	operations := make(map[string]interface{}, len(a.stats))
+	for operation, s := range a.stats {
+		op := map[string]interface{}{
+			"accepted":     s.accepted,
+			"completed":    s.completed,
+			"failed":       s.failed,
+			"timedOut":     s.timedOut,
+			"lost":         s.lost,
+			"pollRequests": s.polls,
+		}
+		if s.accepted > 0 {
+			op["timeoutRate"] = fmt.Sprintf("%.2f%%", float64(s.timedOut)/float64(s.accepted)*100)
+		}
+		if len(s.latencies) > 0 {
+			sorted := append([]time.Duration(nil), s.latencies...)
+			sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+			op["completionLatency"] = map[string]string{
+				"p50": percentileDuration(sorted, 0.5).String(),
+				"p95": percentileDuration(sorted, 0.95).String(),
+				"p99": percentileDuration(sorted, 0.99).String(),
+				"max": sorted[len(sorted)-1].String(),
+			}
+		}
+		operations[operation] = op
+	}
+	return map[string]interface{}{
+		"timeout":    a.config.Timeout.String(),
+		"operations": operations,
+	}
+}
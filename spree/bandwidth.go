@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// BandwidthProfile caps the bandwidth of a share of the connections, to
+// simulate slow (e.g. 3G) clients that hold connections open for longer
+type BandwidthProfile struct {
+	Name         string
+	Percent      float64 // share of new connections using this profile
+	DownloadKbps int     // 0 = unlimited
+	UploadKbps   int     // 0 = unlimited
+}
+
+// profileStats counts the traffic of one profile's connections
+type profileStats struct {
+	connections  atomic.Int64
+	bytesRead    atomic.Int64
+	bytesWritten atomic.Int64
+}
+
+// bandwidthShaper wraps dialed connections with the configured bandwidth profiles
+type bandwidthShaper struct {
+	profiles []BandwidthProfile
+	stats    []*profileStats
+}
+
+// newBandwidthShaper validates the profiles and returns nil when none are configured
+func newBandwidthShaper(profiles []BandwidthProfile) (*bandwidthShaper, error) {
+	if len(profiles) == 0 {
+		return nil, nil
+	}
+
+	var total float64
+	s := &bandwidthShaper{profiles: profiles}
+	for _, p := range profiles {
+		if p.Name == "" || p.Percent < 0 || p.DownloadKbps < 0 || p.UploadKbps < 0 {
+			return nil, fmt.Errorf("invalid bandwidth profile %+v", p)
+		}
+		total += p.Percent
+		s.stats = append(s.stats, &profileStats{})
+	}
+	if total > 100 {
+		return nil, fmt.Errorf("bandwidth profile percentages add up to %.1f%%, more than 100%%", total)
+	}
+	return s, nil
+}
+
+// wrapDial returns a dial function that throttles the configured share of new connections
+func (s *bandwidthShaper) wrapDial(dial func(ctx context.Context, network, addr string) (net.Conn, error)) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	if s == nil {
+		return dial
+	}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+
+		roll := rand.Float64() * 100
+		for i, p := range s.profiles {
+			if roll < p.Percent {
+				s.stats[i].connections.Add(1)
+				return &throttledConn{
+					Conn:  conn,
+					read:  newRateLimiter(p.DownloadKbps),
+					write: newRateLimiter(p.UploadKbps),
+					stats: s.stats[i],
+				}, nil
+			}
+			roll -= p.Percent
+		}
+		return conn, nil
+	}
+}
+
+// report returns connection and byte counts per profile
+func (s *bandwidthShaper) report() map[string]interface{} {
+	if s == nil {
+		return nil
+	}
+	report := make(map[string]interface{}, len(s.profiles))
+	for i, p := range s.profiles {
+		report[p.Name] = map[string]interface{}{
+			"percent":      p.Percent,
+			"downloadKbps": p.DownloadKbps,
+			"uploadKbps":   p.UploadKbps,
+			"connections":  s.stats[i].connections.Load(),
+			"bytesRead":    s.stats[i].bytesRead.Load(),
+			"bytesWritten": s.stats[i].bytesWritten.Load(),
+		}
+	}
+	return report
+}
+
+// rateLimiter paces byte transfers to a fixed rate
+type rateLimiter struct {
+	bytesPerSecond float64
+	mutex          sync.Mutex
+	next           time.Time // when the next transfer may start
+}
+
+// newRateLimiter returns nil (unlimited) for a zero rate
+func newRateLimiter(kbps int) *rateLimiter {
+	if kbps <= 0 {
+		return nil
+	}
+	return &rateLimiter{bytesPerSecond: float64(kbps) * 1000 / 8}
+}
+
+// chunk is the largest transfer allowed at once, about 50ms worth of data
+func (r *rateLimiter) chunk(n int) int {
+	if r == nil {
+		return n
+	}
+	limit := int(r.bytesPerSecond / 20)
+	if limit < 512 {
+		limit = 512
+	}
+	if n > limit {
+		return limit
+	}
+	return n
+}
+
+// wait sleeps until n more bytes fit in the rate
+func (r *rateLimiter) wait(n int) {
+	if r == nil || n <= 0 {
+		return
+	}
+	r.mutex.Lock()
+	now := time.Now()
+	if r.next.Before(now) {
+		r.next = now
+	}
+	r.next = r.next.Add(time.Duration(float64(n) / r.bytesPerSecond * float64(time.Second)))
+	sleep := r.next.Sub(now)
+	r.mutex.Unlock()
+	time.Sleep(sleep)
+}
+
+// throttledConn limits a connection's read and write rates
+type throttledConn struct {
+	net.Conn
+	read  *rateLimiter
+	write *rateLimiter
+	stats *profileStats
+}
+
+func (c *throttledConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b[:c.read.chunk(len(b))])
+	c.read.wait(n)
+	c.stats.bytesRead.Add(int64(n))
+	return n, err
+}
+
+func (c *throttledConn) Write(b []byte) (int, error) {
+	written := 0
+	for written < len(b) {
+		size := c.write.chunk(len(b) - written)
+		c.write.wait(size)
+		n, err := c.Conn.Write(b[written : written+size])
+		written += n
+		c.stats.bytesWritten.Add(int64(n))
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+)
+
+// BodyValidationConfig restores the checks of the original k6 script: a
+// 2xx response of a validated operation must have a non-empty body that
+// parses as JSON and holds the JSON:API "data" member
+type BodyValidationConfig struct {
+	Enabled bool
+
+	// Operations validated, default products, specificProduct and productPage
+	Operations []string
+
+	// Operations whose "data" must be an array (lists), default products and
+	// productPage; the others need "data" present
+	Lists []string
+}
+
+// Body validation failures, counted separately per operation
+const (
+	bodyEmpty       = "emptyBody"
+	bodyInvalidJSON = "invalidJSON"
+	bodyMissingData = "missingData"
+	bodyDataNoArray = "dataNotArray"
+)
+
+// bodyChecks counts one operation's validated responses and failures
+type bodyChecks struct {
+	validated int64
+	failures  map[string]int64
+}
+
+// bodyValidator checks response bodies and counts why they fail
+type bodyValidator struct {
+	operations map[string]bool
+	lists      map[string]bool
+
+	mutex  sync.Mutex
+	checks map[string]*bodyChecks
+}
+
+// newBodyValidator returns nil unless body validation is enabled
+func newBodyValidator(config BodyValidationConfig) *bodyValidator {
+	if !config.Enabled {
+		return nil
+	}
+	operations := config.Operations
+	if len(operations) == 0 {
+		operations = []string{"products", "specificProduct", productPageOperation}
+	}
+	lists := config.Lists
+	if len(lists) == 0 {
+		lists = []string{"products", productPageOperation}
+	}
+	v := &bodyValidator{operations: make(map[string]bool), lists: make(map[string]bool), checks: make(map[string]*bodyChecks)}
+	for _, op := range operations {
+		v.operations[op] = true
+	}
+	for _, op := range lists {
+		v.lists[op] = true
+	}
+	return v
+}
+
+// wants reports whether the operation's bodies are validated
+func (v *bodyValidator) wants(operation string) bool {
+	return v != nil && v.operations[baseOperation(operation)]
+}
+
+// check returns why a 2xx body fails validation, or "" if it passes
+func (v *bodyValidator) check(operation string, body []byte) string {
+	if !v.wants(operation) {
+		return ""
+	}
+	base := baseOperation(operation)
+	failure := ""
+	var doc map[string]json.RawMessage
+	switch {
+	case len(body) == 0:
+		failure = bodyEmpty
+	case json.Unmarshal(body, &doc) != nil:
+		failure = bodyInvalidJSON
+	case len(doc["data"]) == 0 || string(doc["data"]) == "null":
+		failure = bodyMissingData
+	case v.lists[base]:
+		var list []json.RawMessage
+		if json.Unmarshal(doc["data"], &list) != nil {
+			failure = bodyDataNoArray
+		}
+	}
+
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	checks, ok := v.checks[base]
+	if !ok {
+		checks = &bodyChecks{failures: make(map[string]int64)}
+		v.checks[base] = checks
+	}
+	checks.validated++
+	if failure == "" {
+		return ""
+	}
+	checks.failures[failure]++
+	return "body validation: " + failure
+}
+
+// report returns the validated responses and failures per operation and in total
+func (v *bodyValidator) report() map[string]interface{} {
+	if v == nil {
+		return nil
+	}
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	operations := make([]string, 0, len(v.checks))
+	for op := range v.checks {
+		operations = append(operations, op)
+	}
+	sort.Strings(operations)
+	var validated int64
+	failed := make(map[string]int64)
+	perOperation := make(map[string]interface{}, len(operations))
+	for _, op := range operations {
+		checks := v.checks[op]
+		validated += checks.validated
+		var opFailed int64
+		failures := make(map[string]int64, len(checks.failures))
+		for reason, n := range checks.failures {
+			failures[reason] = n
+			failed[reason] += n
+			opFailed += n
+		}
+		perOperation[op] = map[string]interface{}{
+			"validated": checks.validated,
+			"failed":    opFailed,
+			"failures":  failures,
+		}
+	}
+	var total int64
+	for _, n := range failed {
+		total += n
+	}
+	return map[string]interface{}{
+		"validated":  validated,
+		"failed":     total,
+		"failures":   failed,
+		"operations": perOperation,
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CacheExpectation is the cache lifetime an operation's responses should have,
+// e.g. the TTL of the CDN rule in front of the products endpoint
+type CacheExpectation struct {
+	TTL       time.Duration // responses must not be older than this
+	Tolerance time.Duration // allowed clock skew when judging the Date header, default 2s
+}
+
+// maxCacheViolations caps the violation examples kept per operation
+const maxCacheViolations = 10
+
+// cacheStats are the header checks of one operation
+type cacheStats struct {
+	checked         int64
+	violations      int64 // responses with an Age or Date violation
+	withAge         int64
+	maxAge          time.Duration // oldest Age seen
+	ageViolations   int64         // Age above the TTL
+	staleViolations int64         // Date further in the past than the TTL
+	missingHeaders  int64         // neither Age nor Date
+	maxAgeMismatch  int64         // Cache-Control max-age differs from the TTL
+	examples        []map[string]string
+}
+
+// cacheChecker verifies that cached responses stay within their expected TTL
+type cacheChecker struct {
+	expectations map[string]CacheExpectation
+
+	mutex sync.Mutex
+	stats map[string]*cacheStats
+}
+
+// newCacheChecker validates the expectations; it returns nil when none are set
+func newCacheChecker(expectations map[string]CacheExpectation) (*cacheChecker, error) {
+	if len(expectations) == 0 {
+		return nil, nil
+	}
+	for op, e := range expectations {
+		if e.TTL <= 0 {
+			return nil, fmt.Errorf("cache expectation for %s needs a TTL", op)
+		}
+		if e.Tolerance <= 0 {
+			e.Tolerance = 2 * time.Second
+			expectations[op] = e
+		}
+	}
+	return &cacheChecker{expectations: expectations, stats: make(map[string]*cacheStats)}, nil
+}
+
+// check compares a response's Age, Date and Cache-Control headers with the
+// operation's expected TTL
+func (c *cacheChecker) check(operation string, header http.Header) {
+	if c == nil {
+		return
+	}
+	expect, ok := c.expectations[baseOperation(operation)]
+	if !ok {
+		return
+	}
+	now := time.Now()
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	stats, ok := c.stats[operation]
+	if !ok {
+		stats = &cacheStats{}
+		c.stats[operation] = stats
+	}
+	stats.checked++
+
+	violation := ""
+	ageHeader, dateHeader := header.Get("Age"), header.Get("Date")
+	if ageHeader == "" && dateHeader == "" {
+		stats.missingHeaders++
+	}
+	if seconds, err := strconv.ParseInt(strings.TrimSpace(ageHeader), 10, 64); err == nil {
+		age := time.Duration(seconds) * time.Second
+		stats.withAge++
+		if age > stats.maxAge {
+			stats.maxAge = age
+		}
+		if age > expect.TTL {
+			stats.ageViolations++
+			violation = fmt.Sprintf("Age %s above TTL %s", age, expect.TTL)
+		}
+	}
+	if date, err := http.ParseTime(dateHeader); err == nil {
+		if staleness := now.Sub(date); staleness > expect.TTL+expect.Tolerance {
+			stats.staleViolations++
+			if violation == "" {
+				violation = fmt.Sprintf("Date %s old, TTL %s", staleness.Round(time.Second), expect.TTL)
+			}
+		}
+	}
+	if maxAge, ok := cacheControlMaxAge(header.Get("Cache-Control")); ok && maxAge != expect.TTL {
+		stats.maxAgeMismatch++
+	}
+
+	if violation != "" {
+		stats.violations++
+	}
+	if violation != "" && len(stats.examples) < maxCacheViolations {
+		stats.examples = append(stats.examples, map[string]string{
+			"time":      now.Format(time.RFC3339),
+			"violation": violation,
+			"age":       ageHeader,
+			"date":      dateHeader,
+		})
+	}
+}
+
+// cacheControlMaxAge returns the s-maxage or max-age of a Cache-Control
+// header, s-maxage first since it applies to the CDN
+func cacheControlMaxAge(value string) (time.Duration, bool) {
+	directives := make(map[string]time.Duration)
+	for _, directive := range strings.Split(value, ",") {
+		name, arg, ok := strings.Cut(strings.TrimSpace(strings.ToLower(directive)), "=")
+		if !ok {
+			continue
+		}
+		if seconds, err := strconv.ParseInt(strings.Trim(arg, `"`), 10, 64); err == nil {
+			directives[name] = time.Duration(seconds) * time.Second
+		}
+	}
+	if maxAge, ok := directives["s-maxage"]; ok {
+		return maxAge, true
+	}
+	maxAge, ok := directives["max-age"]
+	return maxAge, ok
+}
+
+// report lists the staleness violations per operation
+func (c *cacheChecker) report() map[string]interface{} {
+	if c == nil {
+		return nil
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	report := make(map[string]interface{}, len(c.stats))
+	for operation, s := range c.stats {
+		expect := c.expectations[baseOperation(operation)]
+		entry := map[string]interface{}{
+			"ttl":             expect.TTL.String(),
+			"checked":         s.checked,
+			"withAge":         s.withAge,
+			"maxAgeSeen":      s.maxAge.String(),
+			"ageViolations":   s.ageViolations,
+			"staleViolations": s.staleViolations,
+			"missingHeaders":  s.missingHeaders,
+		}
+		if s.maxAgeMismatch > 0 {
+			// Cache-Control announces a different lifetime than expected
+			entry["cacheControlMismatch"] = s.maxAgeMismatch
+		}
+		if s.checked > 0 {
+			entry["violationRate"] = fmt.Sprintf("%.2f%%", float64(s.violations)/float64(s.checked)*100)
+		}
+		if len(s.examples) > 0 {
+			entry["examples"] = s.examples
+		}
+		report[operation] = entry
+	}
+	return report
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// concurrencyLimiter caps the number of in-flight requests per operation,
+// independent of the overall request rate. Workers wait for a free slot, the
+// way real clients queue up behind a contended write endpoint.
+type concurrencyLimiter struct {
+	slots    map[string]chan struct{}
+	waits    map[string]*atomic.Int64 // requests that had to wait for a slot
+	waitTime map[string]*atomic.Int64 // total time spent waiting, in nanoseconds
+}
+
+// newConcurrencyLimiter creates a limiter from operation -> max in-flight
+// requests. It returns nil when no limits are configured.
+func newConcurrencyLimiter(limits map[string]int) *concurrencyLimiter {
+	if len(limits) == 0 {
+		return nil
+	}
+
+	l := &concurrencyLimiter{
+		slots:    make(map[string]chan struct{}),
+		waits:    make(map[string]*atomic.Int64),
+		waitTime: make(map[string]*atomic.Int64),
+	}
+	for op, limit := range limits {
+		if limit <= 0 {
+			continue
+		}
+		l.slots[op] = make(chan struct{}, limit)
+		l.waits[op] = &atomic.Int64{}
+		l.waitTime[op] = &atomic.Int64{}
+	}
+	return l
+}
+
+// acquire blocks until op has a free slot. Operations without a limit return immediately.
+func (l *concurrencyLimiter) acquire(op string) {
+	if l == nil {
+		return
+	}
+	op = baseOperation(op) // canary and experiment requests share the operation's cap
+	slots, ok := l.slots[op]
+	if !ok {
+		return
+	}
+
+	select {
+	case slots <- struct{}{}:
+		return
+	default:
+	}
+
+	start := time.Now()
+	slots <- struct{}{}
+	l.waits[op].Add(1)
+	l.waitTime[op].Add(int64(time.Since(start)))
+}
+
+// release frees the slot taken by acquire
+func (l *concurrencyLimiter) release(op string) {
+	if l == nil {
+		return
+	}
+	if slots, ok := l.slots[baseOperation(op)]; ok {
+		<-slots
+	}
+}
+
+// report describes each limit and how much waiting it caused
+func (l *concurrencyLimiter) report() map[string]interface{} {
+	if l == nil {
+		return nil
+	}
+
+	report := make(map[string]interface{}, len(l.slots))
+	for op, slots := range l.slots {
+		waits := l.waits[op].Load()
+		entry := map[string]interface{}{
+			"limit":    cap(slots),
+			"inFlight": len(slots),
+			"waits":    waits,
+		}
+		if waits > 0 {
+			entry["meanWait"] = (time.Duration(l.waitTime[op].Load()) / time.Duration(waits)).String()
+		}
+		report[op] = entry
+	}
+	return report
+}
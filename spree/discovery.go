@@ -0,0 +1,237 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// productPageOperation is the operation of the pagination walk's requests
+const productPageOperation = "productPage"
+
+// DiscoveryConfig lists the catalog before the load. Single-product requests
+// then spread over the discovered IDs instead of one cached product, and a
+// share of the traffic walks the product list page by page: Spree paginates
+// with OFFSET, so the deep pages are the slow ones and page 1 hides them.
+type DiscoveryConfig struct {
+	Percent           float64 // share of specificProduct requests for a discovered product; 0 keeps Endpoints.SpecificProduct
+	Limit             int     // product IDs fetched, default 100
+	PaginationPercent float64 // share of all requests walking the product list, page=1..N in turn
+	PerPage           int     // per_page of the walk, default 25 (Spree's default)
+	MaxPages          int     // deepest page walked, default the last page
+}
+
+// paginationDepthBands is the number of depth ranges the walk's latency is
+// reported in, shallowest first
+const paginationDepthBands = 4
+
+// depthBand is the walk's requests for one range of pages
+type depthBand struct {
+	first, last int
+	failed      int64
+	durations   *durationHistogram
+}
+
+// discoveredIDs are the discovered product IDs and the pagination walk
+type discoveredIDs struct {
+	config      DiscoveryConfig
+	productsURL string
+	products    []string
+	total       int // products in the catalog, by the list's meta.total_count
+	pages       int // pages walked
+
+	cursor atomic.Int64
+	mutex  sync.Mutex
+	bands  []*depthBand
+}
+
+// discoverIDs fetches the first page of products; it returns nil when
+// discovery is off
+func discoverIDs(config *Config) (*discoveredIDs, error) {
+	dc := config.Test.Discovery
+	if dc.Percent <= 0 && dc.PaginationPercent <= 0 {
+		return nil, nil
+	}
+	if dc.Percent > 100 || dc.PaginationPercent > 100 {
+		return nil, fmt.Errorf("discovery percentages must be at most 100")
+	}
+	if dc.Limit <= 0 {
+		dc.Limit = 100
+	}
+	if dc.PerPage <= 0 {
+		dc.PerPage = 25
+	}
+	d := &discoveredIDs{config: dc, productsURL: strings.TrimSuffix(config.Endpoints.Products, "/")}
+
+	var err error
+	d.products, d.total, err = fetchProductIDs(&http.Client{Timeout: 15 * time.Second}, config.Headers, d.productsURL, dc.Limit)
+	if err != nil {
+		return nil, err
+	}
+	if len(d.products) == 0 {
+		return nil, fmt.Errorf("%s returned no products", d.productsURL)
+	}
+	if dc.PaginationPercent > 0 {
+		if d.total == 0 {
+			return nil, fmt.Errorf("%s has no meta.total_count to paginate by", d.productsURL)
+		}
+		d.pages = (d.total + dc.PerPage - 1) / dc.PerPage
+		if dc.MaxPages > 0 && d.pages > dc.MaxPages {
+			d.pages = dc.MaxPages
+		}
+		bands := min(paginationDepthBands, d.pages)
+		for i := 0; i < bands; i++ {
+			d.bands = append(d.bands, &depthBand{
+				first:     i*d.pages/bands + 1,
+				last:      (i + 1) * d.pages / bands,
+				durations: newDurationHistogram(),
+			})
+		}
+	}
+	return d, nil
+}
+
+// fetchProductIDs lists up to limit products and returns their IDs and the
+// catalog size reported in the JSON:API meta
+func fetchProductIDs(client *http.Client, headers map[string]string, endpoint string, limit int) ([]string, int, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, 0, err
+	}
+	query := u.Query()
+	query.Set("per_page", fmt.Sprint(limit))
+	query.Set("fields[product]", "name")
+	u.RawQuery = query.Encode()
+
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	setLoadTestID(req.Header)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, 0, fmt.Errorf("%s: status %d", u, resp.StatusCode)
+	}
+
+	var page struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+		Meta struct {
+			TotalCount int `json:"total_count"`
+		} `json:"meta"`
+	}
+	if err := json.Unmarshal(body, &page); err != nil {
+		return nil, 0, fmt.Errorf("%s: response is not a JSON:API product list: %v", u, err)
+	}
+	var ids []string
+	for _, entry := range page.Data {
+		if entry.ID != "" {
+			ids = append(ids, entry.ID)
+		}
+	}
+	return ids, page.Meta.TotalCount, nil
+}
+
+// productURL returns a random discovered product's URL for Percent of the
+// specificProduct requests. A nil set never picks.
+func (d *discoveredIDs) productURL() (string, bool) {
+	if d == nil || len(d.products) == 0 || rand.Float64()*100 >= d.config.Percent {
+		return "", false
+	}
+	return d.productsURL + "/" + url.PathEscape(d.products[rand.Intn(len(d.products))]), true
+}
+
+// pageTask returns the walk's next page for PaginationPercent of the requests.
+// Concurrent walkers share one cursor, so every page is requested equally
+// often however the load is spread over the workers.
+func (d *discoveredIDs) pageTask(headers map[string]string) (Task, bool) {
+	if d == nil || d.pages == 0 || rand.Float64()*100 >= d.config.PaginationPercent {
+		return Task{}, false
+	}
+	page := int((d.cursor.Add(1)-1)%int64(d.pages)) + 1
+	u, err := url.Parse(d.productsURL)
+	if err != nil {
+		return Task{}, false
+	}
+	query := u.Query()
+	query.Set("page", fmt.Sprint(page))
+	query.Set("per_page", fmt.Sprint(d.config.PerPage))
+	u.RawQuery = query.Encode()
+	return Task{URL: u.String(), Headers: headers, Method: "GET", Type: productPageOperation, ListPage: page}, true
+}
+
+// record adds a walk request to its depth band
+func (d *discoveredIDs) record(page int, duration time.Duration, failed bool) {
+	if d == nil || page <= 0 {
+		return
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	for _, band := range d.bands {
+		if page <= band.last {
+			band.durations.record(duration)
+			if failed {
+				band.failed++
+			}
+			return
+		}
+	}
+}
+
+// report lists the discovered products and the walk's latency per depth band
+func (d *discoveredIDs) report() map[string]interface{} {
+	if d == nil {
+		return nil
+	}
+	report := map[string]interface{}{
+		"percent":  d.config.Percent,
+		"products": len(d.products),
+	}
+	if d.pages == 0 {
+		return report
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	bands := make([]map[string]interface{}, 0, len(d.bands))
+	for _, band := range d.bands {
+		entry := map[string]interface{}{
+			"pages":          fmt.Sprintf("%d-%d", band.first, band.last),
+			"requests":       band.durations.count,
+			"failedRequests": band.failed,
+		}
+		if band.durations.count > 0 {
+			entry["latency"] = band.durations.latency()
+		}
+		bands = append(bands, entry)
+	}
+	walked := d.cursor.Load()
+	report["pagination"] = map[string]interface{}{
+		"percent":       d.config.PaginationPercent,
+		"perPage":       d.config.PerPage,
+		"totalProducts": d.total,
+		"pages":         d.pages,
+		"requests":      walked,
+		"walks":         walked / int64(d.pages),
+		"depth":         bands,
+	}
+	return report
+}
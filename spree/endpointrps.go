@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// endpointPacer is one operation of Test.EndpointRPS and what was queued for it
+type endpointPacer struct {
+	operation string
+	rps       float64
+	sent      atomic.Int64
+	dropped   atomic.Int64 // queue full
+}
+
+// endpointRates paces every operation of Test.EndpointRPS at its own rate,
+// replacing the global RPS and the traffic distribution, so each endpoint
+// gets the load of its SLA regardless of the others
+type endpointRates struct {
+	pacers  []*endpointPacer
+	started time.Time
+}
+
+// endpointConflicts lists the settings that shape the load some other way
+func endpointConflicts(g *LoadGenerator) []string {
+	test := g.Config.Test
+	var conflicts []string
+	for name, set := range map[string]bool{
+		"AdaptiveRPS":    test.AdaptiveRPS,
+		"AdaptiveStages": test.AdaptiveStages,
+		"BurstMode":      test.BurstMode,
+		"ProfileCSV":     test.ProfileCSV != "",
+		"Upload":         g.Pool.Uploads != nil,
+		"Admin":          g.Admin != nil,
+		"Assets":         g.Pool.Assets != nil,
+		"Pages":          g.Pool.Pages != nil,
+		"Discovery":      g.Pool.Discovered != nil,
+		"Journey":        g.Journeys != nil,
+		"Autocomplete":   g.Autocomplete != nil,
+		"Corpus":         g.Corpus != nil,
+	} {
+		if set {
+			conflicts = append(conflicts, name)
+		}
+	}
+	sort.Strings(conflicts)
+	return conflicts
+}
+
+// newEndpointRates returns nil when Test.EndpointRPS is empty. The operations
+// are those a journey can use: the built-in endpoints and the Entities
+// operations.
+func newEndpointRates(g *LoadGenerator) (*endpointRates, error) {
+	rates := g.Config.Test.EndpointRPS
+	if len(rates) == 0 {
+		return nil, nil
+	}
+	if conflicts := endpointConflicts(g); len(conflicts) > 0 {
+		return nil, fmt.Errorf("per-endpoint rates replace the global RPS and the traffic mix and cannot be combined with %s", strings.Join(conflicts, ", "))
+	}
+	if g.Config.Test.Duration <= 0 {
+		return nil, fmt.Errorf("per-endpoint rates run for Test.Duration, which is not set")
+	}
+	e := &endpointRates{}
+	for operation, rps := range rates {
+		if !g.journeyOperation(operation) {
+			return nil, fmt.Errorf("unknown operation %q", operation)
+		}
+		if rps <= 0 {
+			return nil, fmt.Errorf("%s: RPS must be positive, got %v", operation, rps)
+		}
+		e.pacers = append(e.pacers, &endpointPacer{operation: operation, rps: rps})
+	}
+	sort.Slice(e.pacers, func(i, j int) bool { return e.pacers[i].operation < e.pacers[j].operation })
+	return e, nil
+}
+
+// total is the sum of the per-endpoint rates
+func (e *endpointRates) total() float64 {
+	var total float64
+	for _, p := range e.pacers {
+		total += p.rps
+	}
+	return total
+}
+
+// describeEndpointRPS lists the rates, e.g. "products 300 RPS, checkout 5 RPS"
+func describeEndpointRPS(rates map[string]float64) string {
+	operations := make([]string, 0, len(rates))
+	for operation := range rates {
+		operations = append(operations, operation)
+	}
+	sort.Strings(operations)
+	parts := make([]string, len(operations))
+	for i, operation := range operations {
+		parts[i] = fmt.Sprintf("%s %s RPS", operation, formatRPS(rates[operation]))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// startEndpointPacers launches -generators pacing goroutines per operation
+func (g *LoadGenerator) startEndpointPacers() {
+	g.Endpoints.started = time.Now()
+	for _, p := range g.Endpoints.pacers {
+		p := p
+		rate := func() float64 { return p.rps }
+		emit := func() { g.emitOperation(p) }
+		for i := 0; i < g.Tuning.Generators; i++ {
+			g.WaitGroup.Add(1)
+			go g.pace(i, rate, emit)
+		}
+	}
+}
+
+// emitOperation queues one task of the pacer's operation unless the resource
+// guard or a blackout window holds generation back
+func (g *LoadGenerator) emitOperation(p *endpointPacer) {
+	if g.Guard.Throttled() || g.Blackout.Paused() {
+		return
+	}
+	task, ok := g.journeyTask(p.operation)
+	if !ok {
+		return
+	}
+	g.assignVariant(&task)
+	select {
+	case g.Pool.Tasks <- task:
+		p.sent.Add(1)
+	default:
+		p.dropped.Add(1)
+	}
+}
+
+// report gives each operation's target and queued rate; the completed
+// requests and latency are under operations
+func (e *endpointRates) report() map[string]interface{} {
+	if e == nil || e.started.IsZero() {
+		return nil
+	}
+	elapsed := time.Since(e.started).Seconds()
+	report := make(map[string]interface{}, len(e.pacers))
+	for _, p := range e.pacers {
+		sent := p.sent.Load()
+		report[p.operation] = map[string]interface{}{
+			"targetRPS": roundRPS(p.rps),
+			"sent":      sent,
+			"sentRPS":   roundRPS(float64(sent) / elapsed),
+			"dropped":   p.dropped.Load(),
+		}
+	}
+	return report
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ExternalSource is a URL polled during the run, e.g. the platform's /health
+// or a Prometheus query, whose values are stored as a time series next to the
+// load side's latency of the same window
+type ExternalSource struct {
+	Name     string
+	URL      string
+	Interval time.Duration // default 15s
+	Headers  map[string]string
+
+	// Series name to dot path of a value in the JSON response, e.g.
+	// {"cpu": "data.result.0.value.1"} for a Prometheus instant query.
+	// Without Fields only the status and response time are recorded.
+	Fields map[string]string
+}
+
+// externalSeries is the timeline of one source
+type externalSeries struct {
+	source     ExternalSource
+	samples    []map[string]interface{}
+	failures   int64
+	lastDurIdx int
+	lastTotal  int64
+	lastFailed int64
+}
+
+// externalPoller polls the external sources, each on its own interval
+type externalPoller struct {
+	metrics *Metrics
+	client  *http.Client
+	start   time.Time
+
+	stopChan chan struct{}
+	wg       sync.WaitGroup
+
+	mutex  sync.Mutex
+	series []*externalSeries
+}
+
+// newExternalPoller validates the sources; it returns nil when there are none
+func newExternalPoller(sources []ExternalSource, metrics *Metrics) (*externalPoller, error) {
+	if len(sources) == 0 {
+		return nil, nil
+	}
+	p := &externalPoller{
+		metrics:  metrics,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		stopChan: make(chan struct{}),
+	}
+	names := make(map[string]bool)
+	for i, source := range sources {
+		if source.Name == "" || source.URL == "" {
+			return nil, fmt.Errorf("external source %d needs a Name and URL", i+1)
+		}
+		if names[source.Name] {
+			return nil, fmt.Errorf("external source %q is defined twice", source.Name)
+		}
+		names[source.Name] = true
+		if source.Interval <= 0 {
+			source.Interval = 15 * time.Second
+		}
+		p.series = append(p.series, &externalSeries{source: source})
+	}
+	return p, nil
+}
+
+// Start polls every source once immediately and then on its interval
+func (p *externalPoller) Start(start time.Time) {
+	if p == nil {
+		return
+	}
+	p.start = start
+	for _, s := range p.series {
+		p.wg.Add(1)
+		go func(s *externalSeries) {
+			defer p.wg.Done()
+			ticker := time.NewTicker(s.source.Interval)
+			defer ticker.Stop()
+			p.sample(s, time.Now())
+			for {
+				select {
+				case <-p.stopChan:
+					return
+				case now := <-ticker.C:
+					p.sample(s, now)
+				}
+			}
+		}(s)
+	}
+}
+
+// Stop ends polling
+func (p *externalPoller) Stop() {
+	if p == nil {
+		return
+	}
+	close(p.stopChan)
+	p.wg.Wait()
+}
+
+// poll requests the source once and extracts its fields
+func (p *externalPoller) poll(source ExternalSource) (int, map[string]float64, error) {
+	req, err := http.NewRequest("GET", source.URL, nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	for key, value := range source.Headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err == nil && resp.StatusCode >= 400 {
+		// Still a data point: a health check failing under load
+		err = fmt.Errorf("status %d", resp.StatusCode)
+	}
+	if err != nil || len(source.Fields) == 0 {
+		return resp.StatusCode, nil, err
+	}
+
+	var doc interface{}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return resp.StatusCode, nil, fmt.Errorf("response is not JSON: %v", err)
+	}
+	values := make(map[string]float64, len(source.Fields))
+	for name, path := range source.Fields {
+		v, ok := lookupPath(doc, path)
+		if !ok {
+			continue
+		}
+		// Prometheus returns sample values as strings
+		switch t := v.(type) {
+		case float64:
+			values[name] = t
+		case bool:
+			values[name] = 0
+			if t {
+				values[name] = 1
+			}
+		case string:
+			if f, err := strconv.ParseFloat(t, 64); err == nil {
+				values[name] = f
+			}
+		}
+	}
+	return resp.StatusCode, values, nil
+}
+
+// sample records one point of a source's timeline together with the load
+// side's requests, error rate and latency since its previous point
+func (p *externalPoller) sample(s *externalSeries, now time.Time) {
+	polled := time.Now()
+	status, values, err := p.poll(s.source)
+	responseTime := time.Since(polled)
+
+	p.metrics.mutex.Lock()
+	window := append([]time.Duration(nil), p.metrics.RequestDurations[min(s.lastDurIdx, len(p.metrics.RequestDurations)):]...)
+	s.lastDurIdx = len(p.metrics.RequestDurations)
+	p.metrics.mutex.Unlock()
+	total := atomic.LoadInt64(&p.metrics.TotalRequests)
+	failed := atomic.LoadInt64(&p.metrics.FailedRequests)
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	point := map[string]interface{}{
+		"time":         now.Format(time.RFC3339),
+		"offsetSec":    now.Sub(p.start).Round(time.Second).Seconds(),
+		"responseTime": responseTime.String(),
+	}
+	if status > 0 {
+		point["status"] = status
+	}
+	if err != nil {
+		point["error"] = err.Error()
+		s.failures++
+	}
+	if len(values) > 0 {
+		point["values"] = values
+	}
+
+	load := map[string]interface{}{"requests": total - s.lastTotal}
+	if requests := total - s.lastTotal; requests > 0 {
+		load["errorRate"] = fmt.Sprintf("%.2f%%", float64(failed-s.lastFailed)/float64(requests)*100)
+	}
+	if len(window) > 0 {
+		sort.Slice(window, func(i, j int) bool { return window[i] < window[j] })
+		load["p50"] = percentileDuration(window, 0.5).String()
+		load["p95"] = percentileDuration(window, 0.95).String()
+	}
+	point["load"] = load
+	s.lastTotal, s.lastFailed = total, failed
+	s.samples = append(s.samples, point)
+}
+
+// report returns each source's timeline
+func (p *externalPoller) report() map[string]interface{} {
+	if p == nil {
+		return nil
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	report := map[string]interface{}{"start": p.start.Format(time.RFC3339)}
+	sources := make(map[string]interface{}, len(p.series))
+	for _, s := range p.series {
+		sources[s.source.Name] = map[string]interface{}{
+			"url":         s.source.URL,
+			"interval":    s.source.Interval.String(),
+			"failedPolls": s.failures,
+			"timeline":    s.samples,
+		}
+	}
+	report["sources"] = sources
+	return report
+}
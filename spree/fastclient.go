@@ -0,0 +1,318 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// checkClientBackend validates Test.Client: "net/http" (the default) or
+// "fasthttp"
+func checkClientBackend(name string) error {
+	switch name {
+	case "", "net/http", "fasthttp":
+		return nil
+	}
+	return fmt.Errorf("unknown client %q (available: net/http, fasthttp)", name)
+}
+
+// fasthttpTransport sends the requests with fasthttp, for REST loads where
+// net/http's transport becomes the generator's bottleneck. It is an
+// http.RoundTripper, so redirects, client delays, timeouts and the result
+// sections work as with net/http. What net/http reports through httptrace
+// comes from the connections the transport dials itself: new and reused
+// connections with their idle time, and the DNS, connect, TLS, send and wait
+// phases of traced requests. It speaks HTTP/1.1 only.
+type fasthttpTransport struct {
+	client   *fasthttp.Client
+	dial     func(ctx context.Context, network, addr string) (net.Conn, error)
+	compress bool        // ask for gzip and decompress it, like net/http
+	tls      *tls.Config // nil for the system's roots
+
+	conns sync.Map // local and remote address -> *fastConn
+}
+
+// newFasthttpTransport returns a transport opening up to workers
+// connections per host with dial, which binds source addresses and shapes
+// bandwidth like the net/http transport's
+func newFasthttpTransport(dial func(ctx context.Context, network, addr string) (net.Conn, error), workers int, timeout time.Duration, compress bool) *fasthttpTransport {
+	t := &fasthttpTransport{dial: dial, compress: compress}
+	t.client = &fasthttp.Client{
+		Name:                   "Go-http-client/1.1", // the target can't tell the backends apart
+		MaxConnsPerHost:        workers,
+		MaxIdleConnDuration:    90 * time.Second,
+		MaxConnWaitTimeout:     timeout, // net/http waits for a free connection as well
+		DisablePathNormalizing: true,    // send the URLs as built
+		ConfigureClient: func(hc *fasthttp.HostClient) error {
+			hc.DialTimeout = t.dialer(hc.IsTLS)
+			return nil
+		},
+	}
+	return t
+}
+
+// dialer resolves, connects and, for https, completes the TLS handshake, so
+// each phase of a new connection is timed
+func (t *fasthttpTransport) dialer(isTLS bool) fasthttp.DialFuncWithTimeout {
+	return func(addr string, timeout time.Duration) (net.Conn, error) {
+		ctx := context.Background()
+		if timeout > 0 { // zero for requests without a deadline
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+
+		c := &fastConn{transport: t}
+		ips := []net.IPAddr{{IP: net.ParseIP(host)}}
+		if ips[0].IP == nil {
+			c.dnsStart = time.Now()
+			ips, err = net.DefaultResolver.LookupIPAddr(ctx, host)
+			c.dnsDone = time.Now()
+			if err != nil {
+				return nil, err
+			}
+		}
+		c.connectStart = time.Now()
+		for _, ip := range ips {
+			if c.Conn, err = t.dial(ctx, "tcp", net.JoinHostPort(ip.String(), port)); err == nil {
+				break
+			}
+		}
+		c.connectDone = time.Now()
+		if err != nil {
+			return nil, err
+		}
+		c.key = c.LocalAddr().String() + ">" + c.RemoteAddr().String()
+		t.conns.Store(c.key, c)
+		if !isTLS {
+			return c, nil
+		}
+
+		config := &tls.Config{}
+		if t.tls != nil {
+			config = t.tls.Clone()
+		}
+		config.ServerName = host
+		tlsConn := tls.Client(c, config)
+		c.tlsStart = time.Now()
+		err = tlsConn.HandshakeContext(ctx)
+		c.tlsDone = time.Now()
+		if err != nil {
+			tlsConn.Close()
+			return nil, err
+		}
+		c.handshaken()
+		return tlsConn, nil
+	}
+}
+
+func (t *fasthttpTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	freq := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(freq)
+	fresp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseResponse(fresp)
+
+	freq.SetRequestURI(req.URL.String())
+	freq.Header.SetMethod(req.Method)
+	for key, values := range req.Header {
+		for _, value := range values {
+			freq.Header.Add(key, value)
+		}
+	}
+	gzipped := false
+	if t.compress && req.Header.Get("Accept-Encoding") == "" && req.Header.Get("Range") == "" && req.Method != http.MethodHead {
+		freq.Header.Set("Accept-Encoding", "gzip")
+		gzipped = true
+	}
+	if req.Body != nil {
+		body, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		freq.SetBodyRaw(body)
+	}
+
+	start := time.Now()
+	var err error
+	if deadline, ok := ctx.Deadline(); ok {
+		err = t.client.DoDeadline(freq, fresp, deadline)
+	} else {
+		err = t.client.Do(freq, fresp)
+	}
+	end := time.Now()
+	if err != nil {
+		return nil, err
+	}
+	t.observe(ctx, fresp, start, end)
+
+	resp := &http.Response{
+		Status:     fmt.Sprintf("%d %s", fresp.StatusCode(), http.StatusText(fresp.StatusCode())),
+		StatusCode: fresp.StatusCode(),
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     make(http.Header),
+		Request:    req,
+	}
+	fresp.Header.VisitAll(func(key, value []byte) {
+		resp.Header.Add(string(key), string(value))
+	})
+	var body []byte
+	if gzipped && resp.Header.Get("Content-Encoding") == "gzip" {
+		// Decompressed transparently, as net/http does
+		if body, err = fresp.BodyGunzip(); err != nil {
+			return nil, err
+		}
+		resp.Header.Del("Content-Encoding")
+		resp.Header.Del("Content-Length")
+		resp.ContentLength = -1
+		resp.Uncompressed = true
+	} else {
+		body = append([]byte(nil), fresp.Body()...)
+		resp.ContentLength = int64(len(body))
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	return resp, nil
+}
+
+// observe reports the connection the request went over to the client
+// traces (connection reuse) and fills in the phases of a traced request
+func (t *fasthttpTransport) observe(ctx context.Context, fresp *fasthttp.Response, start, end time.Time) {
+	trace := httptrace.ContextClientTrace(ctx)
+	timing, _ := ctx.Value(traceTimingKey{}).(*traceTiming)
+	if trace == nil && timing == nil {
+		return
+	}
+
+	var c *fastConn
+	var e fastExchange
+	found := false
+	if local, remote := fresp.LocalAddr(), fresp.RemoteAddr(); local != nil && remote != nil {
+		if v, ok := t.conns.Load(local.String() + ">" + remote.String()); ok {
+			c = v.(*fastConn)
+			e, found = c.exchange(start, end)
+		}
+	}
+	// A connection handed on before this was noted counts as reused
+	info := httptrace.GotConnInfo{Reused: !found || !e.first}
+	if found && info.Reused && !e.previous.IsZero() {
+		info.WasIdle, info.IdleTime = true, e.sent.Sub(e.previous)
+	}
+	if trace != nil && trace.GotConn != nil {
+		trace.GotConn(info)
+	}
+
+	if timing == nil || !found {
+		return
+	}
+	if e.first {
+		timing.dnsStart, timing.dnsDone = c.dnsStart, c.dnsDone
+		timing.connectStart, timing.connectDone = c.connectStart, c.connectDone
+		timing.tlsStart, timing.tlsDone = c.tlsStart, c.tlsDone
+	}
+	timing.wroteRequest, timing.firstByte = e.wrote, e.firstByte
+}
+
+// fastConn is a connection of the fasthttp transport, below TLS. It notes
+// how it was opened and when the requests it carries were written and
+// answered; fasthttp uses a connection for one request at a time.
+type fastConn struct {
+	net.Conn
+	transport *fasthttpTransport
+	key       string
+
+	dnsStart, dnsDone         time.Time
+	connectStart, connectDone time.Time
+	tlsStart, tlsDone         time.Time
+
+	mutex    sync.Mutex
+	current  fastExchange // the writes since the last response byte
+	writing  bool
+	answered fastExchange // the last request whose response started
+	lastRead time.Time
+}
+
+// fastExchange is one request on a connection
+type fastExchange struct {
+	first                  bool // the request opened the connection
+	sent, wrote, firstByte time.Time
+	previous               time.Time // end of the previous response, if any
+}
+
+func (c *fastConn) Write(b []byte) (int, error) {
+	c.mutex.Lock()
+	if !c.writing {
+		c.writing = true
+		c.current = fastExchange{sent: time.Now(), previous: c.lastRead}
+	}
+	c.mutex.Unlock()
+	n, err := c.Conn.Write(b)
+	c.mutex.Lock()
+	c.current.wrote = time.Now()
+	c.mutex.Unlock()
+	return n, err
+}
+
+func (c *fastConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		now := time.Now()
+		c.mutex.Lock()
+		if c.writing {
+			// Writes without an answer, like TLS's close_notify, are no request
+			c.writing = false
+			c.current.firstByte = now
+			c.answered = c.current
+		}
+		c.lastRead = now
+		c.mutex.Unlock()
+	}
+	return n, err
+}
+
+// Close keeps the connection known for a while, so a request answered just
+// before the server closed it is still matched
+func (c *fastConn) Close() error {
+	time.AfterFunc(time.Minute, func() { c.transport.conns.CompareAndDelete(c.key, c) })
+	return c.Conn.Close()
+}
+
+// handshaken forgets the TLS handshake's reads and writes, which are not a
+// request
+func (c *fastConn) handshaken() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.current, c.writing, c.answered, c.lastRead = fastExchange{}, false, fastExchange{}, time.Time{}
+}
+
+// exchange returns the request answered on the connection if it was sent
+// between start and end, i.e. the connection was not taken by the next
+// request meanwhile
+func (c *fastConn) exchange(start, end time.Time) (fastExchange, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	e := c.answered
+	if e.sent.Before(start) || e.sent.After(end) {
+		return fastExchange{}, false
+	}
+	e.first = !c.connectStart.Before(start)
+	return e, true
+}
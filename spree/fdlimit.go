@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+)
+
+// fdHeadroom is the file descriptors kept for everything but the workers'
+// connections: the results and trace files, the pre-check, discovery and
+// metrics clients, webhook listeners and the runtime itself
+const fdHeadroom = 256
+
+// targetHosts returns the hosts of the URLs in the config; the transport
+// opens up to MaxWorkers connections to each
+func targetHosts(config *Config) []string {
+	data, err := json.Marshal(config)
+	if err != nil {
+		return nil
+	}
+	var decoded interface{}
+	if json.Unmarshal(data, &decoded) != nil {
+		return nil
+	}
+	seen := make(map[string]bool)
+	var hosts []string
+	var collect func(v interface{})
+	collect = func(v interface{}) {
+		switch value := v.(type) {
+		case string:
+			if !strings.HasPrefix(value, "http://") && !strings.HasPrefix(value, "https://") {
+				return
+			}
+			if u, err := url.Parse(value); err == nil && u.Host != "" && !seen[u.Host] {
+				seen[u.Host] = true
+				hosts = append(hosts, u.Host)
+			}
+		case map[string]interface{}:
+			for _, field := range value {
+				collect(field)
+			}
+		case []interface{}:
+			for _, item := range value {
+				collect(item)
+			}
+		}
+	}
+	collect(decoded)
+	return hosts
+}
@@ -0,0 +1,8 @@
+//go:build !unix
+
+package main
+
+// checkFileLimit has no RLIMIT_NOFILE to check or raise on this platform
+func checkFileLimit(config *Config) (map[string]interface{}, error) {
+	return nil, nil
+}
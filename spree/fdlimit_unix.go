@@ -0,0 +1,53 @@
+//go:build unix
+
+package main
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// checkFileLimit makes sure RLIMIT_NOFILE allows a connection per worker to
+// every target host, raising the soft limit (and the hard one, as root) if
+// it does not. Running out mid-test shows up as "too many open files"
+// errors counted against the platform.
+func checkFileLimit(config *Config) (map[string]interface{}, error) {
+	hosts := max(int64(len(targetHosts(config))), 1)
+	need := uint64(max(int64(config.Test.MaxWorkers), 1)*hosts + fdHeadroom)
+
+	var limit syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &limit); err != nil {
+		// Not available on this platform; nothing to check against
+		return nil, nil
+	}
+	report := map[string]interface{}{
+		"needed":      need,
+		"targetHosts": hosts,
+		"initial":     limit.Cur,
+	}
+	if uint64(limit.Cur) < need {
+		raised := limit
+		raised.Cur = rlimitValue(raised.Cur, need)
+		if uint64(raised.Max) < need {
+			raised.Max = rlimitValue(raised.Max, need)
+		}
+		if err := syscall.Setrlimit(syscall.RLIMIT_NOFILE, &raised); err != nil {
+			if uint64(limit.Max) < need {
+				return nil, fmt.Errorf("%d workers need about %d open files (target hosts: %d), but the hard limit is %d: "+
+					"raise it (ulimit -Hn %d, LimitNOFILE in systemd, nofile in /etc/security/limits.conf) or lower MaxWorkers", config.Test.MaxWorkers, need, hosts, limit.Max, need)
+			}
+			return nil, fmt.Errorf("%d workers need about %d open files (target hosts: %d) and raising the limit of %d failed: %v", config.Test.MaxWorkers, need, hosts, limit.Cur, err)
+		}
+		fmt.Printf("Raised the open file limit from %d to %d for %d workers\n", limit.Cur, need, config.Test.MaxWorkers)
+		limit = raised
+		report["raised"] = true
+	}
+	report["limit"] = limit.Cur
+	return report, nil
+}
+
+// rlimitValue converts n to the type of an Rlimit field, which is int64 on
+// FreeBSD and uint64 elsewhere
+func rlimitValue[T int64 | uint64](_ T, n uint64) T {
+	return T(n)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// fingerprintHeaders are response headers that identify the server software or build
+var fingerprintHeaders = []string{"Server", "X-Powered-By", "Via", "X-Version", "X-App-Version", "X-Api-Version", "X-Build", "X-Runtime"}
+
+// headerFingerprint collects the identifying headers present on a response
+func headerFingerprint(resp *http.Response) map[string]string {
+	headers := make(map[string]string)
+	for _, name := range fingerprintHeaders {
+		if value := resp.Header.Get(name); value != "" {
+			headers[name] = value
+		}
+	}
+	return headers
+}
+
+// fingerprintTarget checks that every configured endpoint answers before any
+// load is sent and records identifying response headers, so results can be
+// tied to the Spree deployment that produced them. An unreachable endpoint or
+// a 5xx response is an error.
+func fingerprintTarget(config *Config) (map[string]interface{}, error) {
+	client := &http.Client{Timeout: 15 * time.Second}
+	endpoints := map[string]string{
+		"products":        config.Endpoints.Products,
+		"specificProduct": config.Endpoints.SpecificProduct,
+	}
+
+	env := map[string]interface{}{
+		"probedAt": time.Now().Format(time.RFC3339),
+	}
+	probes := make(map[string]interface{})
+	for name, url := range endpoints {
+		if url == "" {
+			continue
+		}
+		req, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", url, err)
+		}
+		for key, value := range config.Headers {
+			req.Header.Set(key, value)
+		}
+		setLoadTestID(req.Header)
+
+		start := time.Now()
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", url, err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode >= 500 {
+			return nil, fmt.Errorf("%s: status %d", url, resp.StatusCode)
+		}
+
+		probes[name] = map[string]interface{}{
+			"url":          url,
+			"statusCode":   resp.StatusCode,
+			"probeLatency": time.Since(start).String(),
+		}
+		if _, ok := env["headers"]; !ok {
+			env["headers"] = headerFingerprint(resp)
+		}
+	}
+	env["endpoints"] = probes
+	return env, nil
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// flashSaleOperation is the operation name of the surge requests
+const flashSaleOperation = "flash_sale"
+
+// FlashSaleConfig layers a sudden surge on one product on top of the
+// regular load, to see how the rest of the catalog copes
+type FlashSaleConfig struct {
+	Start    time.Duration // offset from the start of the test
+	Duration time.Duration
+	ExtraRPS int64  // surge requests per second on top of the regular load
+	URL      string // product hit by the surge; default the specific product endpoint
+}
+
+// flashSaleStats are the results of one phase
+type flashSaleStats struct {
+	requests  int64
+	failed    int64
+	durations []time.Duration // up to flashSaleSamples, replaced at random beyond
+}
+
+const flashSaleSamples = 20000
+
+// flashSalePhases name the windows relative to the surge
+var flashSalePhases = []string{"before", "during", "after"}
+
+// flashSale sends the surge and compares the catalog before, during and after it
+type flashSale struct {
+	config   FlashSaleConfig
+	task     Task
+	started  time.Time
+	stopChan chan struct{}
+	done     chan struct{}
+	sent     int64
+	dropped  int64
+
+	mutex   sync.Mutex
+	catalog [3]flashSaleStats // by phase, without the surge requests
+	surge   flashSaleStats
+}
+
+// applyPreset layers a built-in scenario on the configuration
+func applyPreset(config *Config, name string) error {
+	switch name {
+	case "":
+		return nil
+	case "flash-sale":
+		return applyFlashSalePreset(config)
+	}
+	return fmt.Errorf("unknown preset %q (available: flash-sale)", name)
+}
+
+// applyFlashSalePreset fills in the parts of the flash sale that are not
+// configured: the surge starts a third into the test, lasts a sixth of it
+// and adds five times the peak stage RPS
+func applyFlashSalePreset(config *Config) error {
+	if config.Test.AdaptiveRPS || config.Test.BurstMode {
+		return fmt.Errorf("the flash-sale preset needs a steady staged load, not AdaptiveRPS or BurstMode")
+	}
+	planned := plannedDuration(config)
+	if planned <= 0 {
+		return fmt.Errorf("the flash-sale preset needs RampupStages with a known duration")
+	}
+	fs := &config.Test.FlashSale
+	if fs.Start <= 0 {
+		fs.Start = planned / 3
+	}
+	if fs.Duration <= 0 {
+		fs.Duration = planned / 6
+	}
+	if fs.ExtraRPS <= 0 {
+		fs.ExtraRPS = int64(math.Ceil(plannedPeakRPS(config) * 5))
+	}
+	return nil
+}
+
+// newFlashSale returns nil when no surge is configured
+func newFlashSale(config FlashSaleConfig, task Task) (*flashSale, error) {
+	if config.ExtraRPS <= 0 {
+		return nil, nil
+	}
+	if config.Duration <= 0 {
+		return nil, fmt.Errorf("flash sale needs a Duration")
+	}
+	task.Type = flashSaleOperation
+	return &flashSale{
+		config:   config,
+		task:     task,
+		stopChan: make(chan struct{}),
+		done:     make(chan struct{}),
+	}, nil
+}
+
+// Start begins the test's clock and sends the surge in its window
+func (f *flashSale) Start(tasks chan<- Task) {
+	if f == nil {
+		return
+	}
+	f.mutex.Lock()
+	f.started = time.Now()
+	f.mutex.Unlock()
+	go f.run(tasks)
+}
+
+// run waits for the window, then queues ExtraRPS surge requests per second
+func (f *flashSale) run(tasks chan<- Task) {
+	defer close(f.done)
+	select {
+	case <-time.After(f.config.Start):
+	case <-f.stopChan:
+		return
+	}
+
+	surgeStart := time.Now()
+	queued := int64(0)
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-f.stopChan:
+			return
+		}
+		elapsed := time.Since(surgeStart)
+		if elapsed >= f.config.Duration {
+			return
+		}
+		due := int64(elapsed.Seconds()*float64(f.config.ExtraRPS)) - queued
+		queued += due
+		for i := int64(0); i < due; i++ {
+			select {
+			case tasks <- f.task:
+				atomic.AddInt64(&f.sent, 1)
+			default:
+				atomic.AddInt64(&f.dropped, 1) // queue full
+			}
+		}
+	}
+}
+
+// Stop ends the surge; call it before the task queue is closed
+func (f *flashSale) Stop() {
+	if f == nil {
+		return
+	}
+	close(f.stopChan)
+	<-f.done
+}
+
+// observe records a finished request in its phase
+func (f *flashSale) observe(operation string, duration time.Duration, success bool) {
+	if f == nil {
+		return
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.started.IsZero() {
+		return
+	}
+
+	stats := &f.surge
+	if baseOperation(operation) != flashSaleOperation {
+		elapsed := time.Since(f.started)
+		phase := 0
+		if elapsed >= f.config.Start+f.config.Duration {
+			phase = 2
+		} else if elapsed >= f.config.Start {
+			phase = 1
+		}
+		stats = &f.catalog[phase]
+	}
+
+	stats.requests++
+	if !success {
+		stats.failed++
+	}
+	if len(stats.durations) < flashSaleSamples {
+		stats.durations = append(stats.durations, duration)
+	} else if i := rand.Int63n(stats.requests); i < flashSaleSamples {
+		stats.durations[i] = duration
+	}
+}
+
+// summary reports one phase's requests, error rate and latency
+func (s *flashSaleStats) summary() (map[string]interface{}, time.Duration) {
+	summary := map[string]interface{}{"requests": s.requests}
+	if s.requests == 0 {
+		return summary, 0
+	}
+	summary["errorRate"] = fmt.Sprintf("%.2f%%", float64(s.failed)/float64(s.requests)*100)
+	sorted := append([]time.Duration(nil), s.durations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	p95 := percentileDuration(sorted, 0.95)
+	summary["latency"] = map[string]string{
+		"p50": percentileDuration(sorted, 0.5).String(),
+		"p95": p95.String(),
+		"p99": percentileDuration(sorted, 0.99).String(),
+	}
+	return summary, p95
+}
+
+// report compares the catalog's latency before, during and after the surge
+func (f *flashSale) report() map[string]interface{} {
+	if f == nil {
+		return nil
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	catalog := make(map[string]interface{}, len(flashSalePhases))
+	var p95 [3]time.Duration
+	for i, phase := range flashSalePhases {
+		catalog[phase], p95[i] = f.catalog[i].summary()
+	}
+	surge, _ := f.surge.summary()
+	surge["sent"] = atomic.LoadInt64(&f.sent)
+	surge["dropped"] = atomic.LoadInt64(&f.dropped)
+
+	report := map[string]interface{}{
+		"start":    f.config.Start.String(),
+		"duration": f.config.Duration.String(),
+		"extraRPS": f.config.ExtraRPS,
+		"surge":    surge,
+		"catalog":  catalog,
+	}
+	if p95[0] > 0 && p95[1] > 0 {
+		// How much slower the rest of the catalog got while the surge ran
+		report["catalogP95Change"] = fmt.Sprintf("%+.1f%%", (float64(p95[1])/float64(p95[0])-1)*100)
+	}
+	return report
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// liveUI serves a page with live charts of the running test (-ui-listen),
+// for ad-hoc runs without Grafana at hand. It reads the per-second series
+// and the load generator's progress; nothing is stored beyond the target
+// rate of each second.
+type liveUI struct {
+	platform string
+	config   map[string]interface{} // secrets redacted
+	series   *secondSeries
+	status   func() map[string]interface{}
+	target   func() float64
+
+	listener net.Listener
+	server   *http.Server
+	stop     chan struct{}
+
+	mutex   sync.Mutex
+	targets []float64 // target RPS by second
+	started bool
+	done    bool
+}
+
+// newLiveUI starts serving on listen; it returns nil when listen is empty
+func newLiveUI(listen, platform string, config map[string]interface{}, series *secondSeries, status func() map[string]interface{}, target func() float64) (*liveUI, error) {
+	if listen == "" {
+		return nil, nil
+	}
+	listener, err := net.Listen("tcp", listen)
+	if err != nil {
+		return nil, err
+	}
+	u := &liveUI{
+		platform: platform,
+		config:   config,
+		series:   series,
+		status:   status,
+		target:   target,
+		listener: listener,
+		stop:     make(chan struct{}),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", u.page)
+	mux.HandleFunc("/api/live", u.live)
+	mux.HandleFunc("/api/config", u.configJSON)
+	u.server = &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go u.server.Serve(listener)
+	return u, nil
+}
+
+// URL is the address of the page
+func (u *liveUI) URL() string {
+	return "http://" + u.listener.Addr().String() + "/"
+}
+
+// Start records the target rate of every second; call it when the series
+// starts, so the seconds line up
+func (u *liveUI) Start() {
+	if u == nil {
+		return
+	}
+	u.mutex.Lock()
+	u.started = true
+	u.mutex.Unlock()
+	go func() {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-u.stop:
+				return
+			case <-ticker.C:
+				u.mutex.Lock()
+				u.targets = append(u.targets, roundRPS(u.target()))
+				u.mutex.Unlock()
+			}
+		}
+	}()
+}
+
+// Finish marks the test as over; the page keeps serving the final state
+// until Stop
+func (u *liveUI) Finish() {
+	if u == nil {
+		return
+	}
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	if !u.done {
+		u.done = true
+		close(u.stop)
+	}
+}
+
+// Stop closes the server
+func (u *liveUI) Stop() {
+	if u == nil {
+		return
+	}
+	u.Finish()
+	u.server.Close()
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(v)
+}
+
+// live returns the status and the completed seconds from ?from= on
+func (u *liveUI) live(w http.ResponseWriter, r *http.Request) {
+	from, _ := strconv.Atoi(r.URL.Query().Get("from"))
+	seconds := u.series.completed(from)
+	type second struct {
+		secondSummary
+		Target *float64 `json:"target,omitempty"`
+	}
+	u.mutex.Lock()
+	points := make([]second, len(seconds))
+	for i, s := range seconds {
+		points[i].secondSummary = s
+		if s.Second < len(u.targets) {
+			target := u.targets[s.Second]
+			points[i].Target = &target
+		}
+	}
+	started, done := u.started, u.done
+	u.mutex.Unlock()
+
+	status := map[string]interface{}{}
+	if started {
+		status = u.status() // the generator's clock is not set before
+	}
+	status["platform"] = u.platform
+	status["done"] = done
+	writeJSON(w, map[string]interface{}{"status": status, "seconds": points})
+}
+
+func (u *liveUI) configJSON(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, u.config)
+}
+
+func (u *liveUI) page(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprint(w, liveUIPage)
+}
+
+// liveUIPage polls /api/live every second and draws the charts on canvases,
+// with no external scripts so it works offline
+const liveUIPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>wsm live</title>
+<style>
+body { font-family: sans-serif; margin: 1.5em; color: #222; }
+h1 { font-size: 1.3em; }
+.status { display: flex; flex-wrap: wrap; gap: 1.5em; margin-bottom: 1em; }
+.status div { min-width: 8em; }
+.status b { display: block; font-size: 1.2em; }
+canvas { width: 100%; height: 180px; border: 1px solid #ddd; margin-bottom: 0.3em; }
+h2 { font-size: 1em; margin: 1em 0 0.3em; }
+.legend span { margin-right: 1em; }
+pre { background: #f6f6f6; padding: 1em; overflow: auto; max-height: 30em; }
+</style>
+</head>
+<body>
+<h1 id="title">wsm live results</h1>
+<div class="status" id="status"></div>
+<h2>Requests per second</h2>
+<canvas id="rps"></canvas>
+<div class="legend"><span style="color:#1f77b4">&#9632; completed</span><span style="color:#999">&#9632; target</span></div>
+<h2>Latency (ms)</h2>
+<canvas id="latency"></canvas>
+<div class="legend"><span style="color:#2ca02c">&#9632; p50</span><span style="color:#ff7f0e">&#9632; p95</span><span style="color:#d62728">&#9632; p99</span></div>
+<h2>Errors per second</h2>
+<canvas id="errors"></canvas>
+<h2>Config</h2>
+<pre id="config"></pre>
+<script>
+var seconds = [];
+function chart(id, series) {
+  var canvas = document.getElementById(id);
+  var w = canvas.width = canvas.clientWidth, h = canvas.height = canvas.clientHeight;
+  var ctx = canvas.getContext('2d');
+  var top = 0;
+  series.forEach(function (s) { seconds.forEach(function (p) { var v = s.value(p); if (v > top) top = v; }); });
+  top = top > 0 ? top * 1.1 : 1;
+  var n = Math.max(seconds.length, 2);
+  ctx.fillStyle = '#666'; ctx.font = '11px sans-serif';
+  ctx.fillText(top.toFixed(top < 10 ? 2 : 0), 4, 12);
+  ctx.fillText(n + 's', w - 30, h - 4);
+  series.forEach(function (s) {
+    ctx.strokeStyle = s.color; ctx.lineWidth = 1.5; ctx.beginPath();
+    var started = false;
+    seconds.forEach(function (p, i) {
+      var v = s.value(p);
+      if (v === undefined) return;
+      var x = i / (n - 1) * (w - 2) + 1, y = h - 2 - v / top * (h - 16);
+      if (started) ctx.lineTo(x, y); else ctx.moveTo(x, y);
+      started = true;
+    });
+    ctx.stroke();
+  });
+}
+function draw() {
+  chart('rps', [
+    { color: '#999', value: function (p) { return p.target; } },
+    { color: '#1f77b4', value: function (p) { return p.requests; } }
+  ]);
+  chart('latency', [
+    { color: '#2ca02c', value: function (p) { return p.p50; } },
+    { color: '#ff7f0e', value: function (p) { return p.p95; } },
+    { color: '#d62728', value: function (p) { return p.p99; } }
+  ]);
+  chart('errors', [{ color: '#d62728', value: function (p) { return p.failed; } }]);
+}
+function showStatus(status) {
+  var last = seconds[seconds.length - 1] || {};
+  var total = 0, failed = 0;
+  seconds.forEach(function (p) { total += p.requests; failed += p.failed; });
+  var fields = [
+    ['Platform', status.platform],
+    ['State', status.done ? 'finished' : 'running'],
+    ['Elapsed', status.elapsed],
+    ['Remaining', status.remaining],
+    ['Stage', status.stage || status.phase],
+    ['Target RPS', status.targetRPS],
+    ['Last second', last.requests === undefined ? '' : last.requests + ' req, p95 ' + last.p95 + ' ms'],
+    ['Requests', total],
+    ['Error rate', total ? (failed / total * 100).toFixed(2) + '%' : '']
+  ];
+  document.getElementById('status').innerHTML = '';
+  fields.forEach(function (f) {
+    if (f[1] === undefined || f[1] === '') return;
+    var div = document.createElement('div');
+    div.textContent = f[0];
+    var b = document.createElement('b');
+    b.textContent = f[1];
+    div.appendChild(b);
+    document.getElementById('status').appendChild(div);
+  });
+  document.getElementById('title').textContent = status.platform + ' live results';
+}
+function poll() {
+  fetch('api/live?from=' + seconds.length).then(function (r) { return r.json(); }).then(function (data) {
+    (data.seconds || []).forEach(function (p) { if (p.second === seconds.length) seconds.push(p); });
+    showStatus(data.status);
+    draw();
+    if (!data.status.done) setTimeout(poll, 1000);
+  }).catch(function () { setTimeout(poll, 3000); });
+}
+fetch('api/config').then(function (r) { return r.json(); }).then(function (config) {
+  document.getElementById('config').textContent = JSON.stringify(config, null, 2);
+});
+window.addEventListener('resize', draw);
+poll();
+</script>
+</body>
+</html>
+`
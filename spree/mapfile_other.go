@@ -0,0 +1,12 @@
+//go:build !unix
+
+package main
+
+import "os"
+
+// mapFile reads path into memory; the corpus is not memory-mapped on this
+// platform
+func mapFile(path string) ([]byte, bool, error) {
+	data, err := os.ReadFile(path)
+	return data, false, err
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// restorePercentiles undoes setPercentiles when the test ends
+func restorePercentiles(t *testing.T) {
+	percentiles, statistics := reportedPercentiles, latencyStatistics
+	t.Cleanup(func() { reportedPercentiles, latencyStatistics = percentiles, statistics })
+}
+
+func TestSetPercentiles(t *testing.T) {
+	restorePercentiles(t)
+	for _, c := range []struct {
+		percentiles []float64
+		statistics  StatisticsConfig
+	}{
+		{[]float64{0}, StatisticsConfig{}},
+		{[]float64{50, 100}, StatisticsConfig{}},
+		{nil, StatisticsConfig{TrimPercent: 50}},
+		{nil, StatisticsConfig{TrimPercent: -1}},
+	} {
+		if err := setPercentiles(c.percentiles, c.statistics); err == nil {
+			t.Errorf("setPercentiles(%v, %+v) succeeded, want an error", c.percentiles, c.statistics)
+		}
+	}
+
+	if err := setPercentiles(nil, StatisticsConfig{}); err != nil || !reflect.DeepEqual(reportedPercentiles, []float64{50, 90, 95, 99}) {
+		t.Errorf("default percentiles %v, %v", reportedPercentiles, err)
+	}
+	// p95 and p99 stay, the rest is sorted and deduplicated
+	if err := setPercentiles([]float64{99.9, 50, 99.9}, StatisticsConfig{}); err != nil || !reflect.DeepEqual(reportedPercentiles, []float64{50, 95, 99, 99.9}) {
+		t.Errorf("custom percentiles %v, %v", reportedPercentiles, err)
+	}
+}
+
+// A custom list without p95 and p99 still leaves the summary and the
+// thresholds something to read
+func TestCustomPercentilesKeepThresholds(t *testing.T) {
+	restorePercentiles(t)
+	if err := setPercentiles([]float64{50, 99.9}, StatisticsConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	sorted := make([]time.Duration, 1000)
+	for i := range sorted {
+		sorted[i] = time.Duration(i+1) * time.Millisecond
+	}
+	latency := latencyPercentiles(sorted)
+	report := map[string]interface{}{
+		"latency":       latency,
+		"totalRequests": int64(1000),
+		"operations": map[string]interface{}{
+			"products": map[string]interface{}{"latency": latency},
+		},
+	}
+	if p95, ok := reportDuration(report, "p95"); !ok || p95 != 951*time.Millisecond {
+		t.Errorf("p95 %s, %v", p95, ok)
+	}
+	if _, ok := latency["p99.9"]; !ok {
+		t.Errorf("latency %v without the configured p99.9", latency)
+	}
+
+	checks := checkThresholds(ThresholdConfig{
+		P95:        time.Second,
+		P99:        time.Second,
+		Operations: map[string]time.Duration{"products": time.Second},
+	}, report)
+	if len(checks) != 3 || !thresholdsPassed(checks) {
+		t.Errorf("checks %v, want 3 passed", checks)
+	}
+}
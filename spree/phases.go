@@ -0,0 +1,321 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// LoadPhase is one named part of a phased test. Phases run one after
+// another, each with its own load model:
+//
+//   - "constant" holds RPS
+//   - "ramp" goes linearly from the rate the previous phase ended at to RPS
+//   - "spike" holds RPS, jumping to PeakRPS for SpikeFor starting at SpikeAt
+//   - "adaptive" starts at RPS (default: where the previous phase ended, or
+//     AdaptiveConfig.InitialRPS) and lets the adaptive controller move it
+//     within AdaptiveConfig's bounds
+type LoadPhase struct {
+	Name     string
+	Model    string
+	Duration time.Duration
+	RPS      float64
+	PeakRPS  float64       // spike only
+	SpikeAt  time.Duration // spike only, offset into the phase, default a third of Duration
+	SpikeFor time.Duration // spike only, default a sixth of Duration
+}
+
+// phaseRun is a phase as it ran
+type phaseRun struct {
+	config   LoadPhase
+	from, to time.Time // zero until the phase starts and ends
+	startRPS float64
+	minRPS   float64
+	maxRPS   float64
+}
+
+// phasePlan steps through Test.Phases on the load generator's ticks
+type phasePlan struct {
+	runs    []phaseRun
+	current atomic.Int64
+
+	adaptive       *adaptiveController
+	samplingWindow time.Duration
+	stabilization  time.Duration
+	initialRPS     float64
+	lastSample     time.Time
+	lastChange     time.Time
+
+	mutex sync.Mutex // guards runs against the report
+}
+
+// adaptivePhase reports whether one of the phases is adaptive, which needs
+// the adaptive controller
+func adaptivePhase(config *Config) bool {
+	for _, phase := range config.Test.Phases {
+		if phase.Model == "adaptive" {
+			return true
+		}
+	}
+	return false
+}
+
+// newPhasePlan returns nil unless Test.Phases is set. Defaults are written
+// back to the config, so the test plan shows them.
+func newPhasePlan(config *Config, adaptive *adaptiveController) (*phasePlan, error) {
+	phases := config.Test.Phases
+	if len(phases) == 0 {
+		return nil, nil
+	}
+	var conflicts []string
+	for name, set := range map[string]bool{
+		"AdaptiveRPS":    config.Test.AdaptiveRPS,
+		"AdaptiveStages": config.Test.AdaptiveStages,
+		"BurstMode":      config.Test.BurstMode,
+		"ProfileCSV":     config.Test.ProfileCSV != "",
+		"EndpointRPS":    len(config.Test.EndpointRPS) > 0,
+	} {
+		if set {
+			conflicts = append(conflicts, name)
+		}
+	}
+	if len(conflicts) > 0 {
+		return nil, fmt.Errorf("Phases replace RampupStages and cannot be combined with %s", strings.Join(conflicts, ", "))
+	}
+
+	ac := config.Test.AdaptiveConfig
+	p := &phasePlan{
+		adaptive:       adaptive,
+		samplingWindow: ac.SamplingWindow,
+		stabilization:  ac.StabilizationWindow,
+		initialRPS:     ac.InitialRPS,
+	}
+	if p.samplingWindow <= 0 {
+		p.samplingWindow = 5 * time.Second
+	}
+	adaptivePhases := 0
+	for i, phase := range phases {
+		if phase.Name == "" {
+			phase.Name = fmt.Sprintf("phase %d", i+1)
+		}
+		if phase.Duration <= 0 {
+			return nil, fmt.Errorf("%s: Duration must be positive", phase.Name)
+		}
+		if phase.RPS < 0 {
+			return nil, fmt.Errorf("%s: RPS must not be negative", phase.Name)
+		}
+		switch phase.Model {
+		case "constant", "ramp":
+		case "spike":
+			if phase.SpikeAt <= 0 {
+				phase.SpikeAt = phase.Duration / 3
+			}
+			if phase.SpikeFor <= 0 {
+				phase.SpikeFor = phase.Duration / 6
+			}
+			if phase.PeakRPS <= phase.RPS {
+				return nil, fmt.Errorf("%s: PeakRPS must be above RPS", phase.Name)
+			}
+			if phase.SpikeAt+phase.SpikeFor > phase.Duration {
+				return nil, fmt.Errorf("%s: the spike (SpikeAt %s + SpikeFor %s) must end within Duration %s", phase.Name, phase.SpikeAt, phase.SpikeFor, phase.Duration)
+			}
+		case "adaptive":
+			adaptivePhases++
+			if adaptivePhases > 1 {
+				return nil, fmt.Errorf("%s: only one phase can be adaptive", phase.Name)
+			}
+		default:
+			return nil, fmt.Errorf("%s: unknown Model %q (available: constant, ramp, spike, adaptive)", phase.Name, phase.Model)
+		}
+		phases[i] = phase
+		p.runs = append(p.runs, phaseRun{config: phase})
+	}
+	return p, nil
+}
+
+// phasesDuration is the planned length of all phases
+func phasesDuration(phases []LoadPhase) time.Duration {
+	var total time.Duration
+	for _, phase := range phases {
+		total += phase.Duration
+	}
+	return total
+}
+
+// phasesPeakRPS is the highest rate the phases ask for
+func phasesPeakRPS(config *Config) float64 {
+	var peak float64
+	for _, phase := range config.Test.Phases {
+		peak = math.Max(peak, phase.RPS)
+		switch phase.Model {
+		case "spike":
+			peak = math.Max(peak, phase.PeakRPS)
+		case "adaptive":
+			peak = math.Max(peak, config.Test.AdaptiveConfig.MaximumRPS)
+		}
+	}
+	return peak
+}
+
+// describe prints the phase for the test plan
+func (phase LoadPhase) describe() string {
+	switch phase.Model {
+	case "ramp":
+		return fmt.Sprintf("ramp to %s RPS", formatRPS(phase.RPS))
+	case "spike":
+		return fmt.Sprintf("%s RPS, spiking to %s RPS for %s at +%s", formatRPS(phase.RPS), formatRPS(phase.PeakRPS), phase.SpikeFor, phase.SpikeAt)
+	case "adaptive":
+		if phase.RPS > 0 {
+			return fmt.Sprintf("adaptive from %s RPS", formatRPS(phase.RPS))
+		}
+		return "adaptive"
+	}
+	return fmt.Sprintf("%s RPS", formatRPS(phase.RPS))
+}
+
+// Start begins the first phase and returns its rate
+func (p *phasePlan) Start(now time.Time) float64 {
+	return p.begin(0, now, 0)
+}
+
+// begin starts phase i at now, coming from rate current
+func (p *phasePlan) begin(i int, now time.Time, current float64) float64 {
+	p.mutex.Lock()
+	run := &p.runs[i]
+	run.from = now
+	phase := run.config
+	rps := phase.RPS
+	switch phase.Model {
+	case "ramp":
+		rps = current
+	case "adaptive":
+		if rps <= 0 {
+			rps = current
+		}
+		if rps <= 0 {
+			rps = p.initialRPS
+		}
+		p.adaptive.Start(now)
+		p.lastSample, p.lastChange = now, now
+	}
+	run.startRPS, run.minRPS, run.maxRPS = rps, rps, rps
+	p.mutex.Unlock()
+	p.current.Store(int64(i))
+
+	fmt.Printf("Phase %d/%d: %s, %s (%s)\n", i+1, len(p.runs), phase.Name, phase.describe(), phase.Duration)
+	return rps
+}
+
+// advance returns the target rate at now, moving on to the next phase when
+// the current one is over; done is set after the last phase
+func (p *phasePlan) advance(now time.Time, current float64, series *secondSeries) (float64, bool) {
+	i := int(p.current.Load())
+	run := &p.runs[i]
+	for now.Sub(run.from) >= run.config.Duration {
+		end := run.from.Add(run.config.Duration)
+		p.mutex.Lock()
+		run.to = end
+		p.mutex.Unlock()
+		if i+1 == len(p.runs) {
+			return current, true
+		}
+		i++
+		current = p.begin(i, end, current)
+		run = &p.runs[i]
+	}
+
+	phase := run.config
+	elapsed := now.Sub(run.from)
+	rps := current
+	switch phase.Model {
+	case "constant":
+		rps = phase.RPS
+	case "ramp":
+		progress := float64(elapsed) / float64(phase.Duration)
+		rps = run.startRPS + (phase.RPS-run.startRPS)*progress
+	case "spike":
+		rps = phase.RPS
+		if elapsed >= phase.SpikeAt && elapsed < phase.SpikeAt+phase.SpikeFor {
+			rps = phase.PeakRPS
+		}
+	case "adaptive":
+		if now.Sub(p.lastSample) >= p.samplingWindow {
+			if now.Sub(p.lastChange) >= p.stabilization {
+				w := series.window(now.Add(-p.samplingWindow), now)
+				var errorRate float64
+				if w.requests > 0 {
+					errorRate = float64(w.failed) / float64(w.requests) * 100
+				}
+				rps = p.adaptive.next(now, current, errorRate, percentileDuration(w.durations, 0.95))
+				p.lastChange = now
+			}
+			p.lastSample = now
+		}
+	}
+
+	p.mutex.Lock()
+	run.minRPS = math.Min(run.minRPS, rps)
+	run.maxRPS = math.Max(run.maxRPS, rps)
+	p.mutex.Unlock()
+	return rps, false
+}
+
+// progress names the running phase for the periodic reports
+func (p *phasePlan) progress() string {
+	i := int(p.current.Load())
+	return fmt.Sprintf("%d/%d: %s", i+1, len(p.runs), p.runs[i].config.Name)
+}
+
+// report gives every phase's load and results; phases an interrupted test
+// did not reach are marked as skipped
+func (p *phasePlan) report(series *secondSeries) []map[string]interface{} {
+	if p == nil {
+		return nil
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	report := make([]map[string]interface{}, 0, len(p.runs))
+	for _, run := range p.runs {
+		phase := map[string]interface{}{
+			"name":            run.config.Name,
+			"model":           run.config.Model,
+			"plannedDuration": run.config.Duration.String(),
+		}
+		if run.from.IsZero() {
+			phase["skipped"] = true
+			report = append(report, phase)
+			continue
+		}
+		to := run.to
+		if to.IsZero() {
+			to = time.Now()
+			phase["completed"] = false
+		}
+		phase["duration"] = to.Sub(run.from).Round(time.Millisecond).String()
+		phase["targetRPS"] = map[string]interface{}{
+			"start": roundRPS(run.startRPS),
+			"min":   roundRPS(run.minRPS),
+			"max":   roundRPS(run.maxRPS),
+		}
+
+		w := series.window(run.from, to)
+		phase["requests"] = w.requests
+		if w.seconds > 0 {
+			phase["achievedRPS"] = fmt.Sprintf("%.2f", float64(w.requests)/float64(w.seconds))
+		}
+		if w.requests > 0 {
+			phase["errorRate"] = fmt.Sprintf("%.2f%%", float64(w.failed)/float64(w.requests)*100)
+			phase["latency"] = map[string]string{
+				"p50": percentileDuration(w.durations, 0.5).String(),
+				"p95": percentileDuration(w.durations, 0.95).String(),
+				"p99": percentileDuration(w.durations, 0.99).String(),
+			}
+		}
+		report = append(report, phase)
+	}
+	return report
+}
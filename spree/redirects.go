@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// RedirectConfig controls how 3xx responses are handled. Redirects are
+// followed (up to MaxHops) unless Follow or the operation's entry says otherwise.
+type RedirectConfig struct {
+	Follow     *bool           // default for all operations, true when unset
+	MaxHops    int             // default 10
+	Operations map[string]bool // per-operation Follow override
+}
+
+// redirectState records the redirects of one request
+type redirectState struct {
+	follow   bool
+	hops     int
+	statuses []int
+	finalURL string
+}
+
+// maxFinalURLs caps the distinct final URLs kept per operation
+const maxFinalURLs = 20
+
+type redirectStateKey struct{}
+
+// redirectCounts aggregates the redirects of one operation
+type redirectCounts struct {
+	redirected int64
+	hops       int64
+	finalURLs  map[string]int64 // beyond maxFinalURLs counted under "other"
+}
+
+// redirectTracker applies the redirect policy and counts redirects per operation
+type redirectTracker struct {
+	config RedirectConfig
+
+	mutex       sync.Mutex
+	operations  map[string]*redirectCounts
+	statusCodes map[int]int64 // 3xx responses that were followed
+}
+
+// newRedirectTracker validates the redirect configuration
+func newRedirectTracker(config RedirectConfig) (*redirectTracker, error) {
+	if config.MaxHops < 0 {
+		return nil, fmt.Errorf("MaxHops must not be negative")
+	}
+	if config.MaxHops == 0 {
+		config.MaxHops = 10
+	}
+	return &redirectTracker{
+		config:      config,
+		operations:  make(map[string]*redirectCounts),
+		statusCodes: make(map[int]int64),
+	}, nil
+}
+
+// follows reports whether redirects are followed for the operation
+func (t *redirectTracker) follows(operation string) bool {
+	if follow, ok := t.config.Operations[baseOperation(operation)]; ok {
+		return follow
+	}
+	return t.config.Follow == nil || *t.config.Follow
+}
+
+// begin attaches the redirect state for the operation to the request
+func (t *redirectTracker) begin(req *http.Request, operation string) (*http.Request, *redirectState) {
+	state := &redirectState{follow: t.follows(operation)}
+	return req.WithContext(context.WithValue(req.Context(), redirectStateKey{}, state)), state
+}
+
+// checkRedirect is the client's CheckRedirect: unfollowed redirects are
+// returned as-is so the 3xx shows up in the status distribution
+func (t *redirectTracker) checkRedirect(req *http.Request, via []*http.Request) error {
+	state, ok := req.Context().Value(redirectStateKey{}).(*redirectState)
+	if !ok {
+		if len(via) >= t.config.MaxHops {
+			return fmt.Errorf("stopped after %d redirects", len(via))
+		}
+		return nil
+	}
+	if !state.follow {
+		return http.ErrUseLastResponse
+	}
+	if len(via) >= t.config.MaxHops {
+		return fmt.Errorf("stopped after %d redirects", len(via))
+	}
+	state.hops++
+	state.finalURL = req.URL.String()
+	if req.Response != nil {
+		state.statuses = append(state.statuses, req.Response.StatusCode)
+	}
+	return nil
+}
+
+// finish adds a completed request's redirects to the operation's counts
+func (t *redirectTracker) finish(state *redirectState, operation string) {
+	if state == nil || state.hops == 0 {
+		return
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	counts, ok := t.operations[operation]
+	if !ok {
+		counts = &redirectCounts{finalURLs: make(map[string]int64)}
+		t.operations[operation] = counts
+	}
+	counts.redirected++
+	counts.hops += int64(state.hops)
+	if _, seen := counts.finalURLs[state.finalURL]; seen || len(counts.finalURLs) < maxFinalURLs {
+		counts.finalURLs[state.finalURL]++
+	} else {
+		counts.finalURLs["other"]++
+	}
+	for _, status := range state.statuses {
+		t.statusCodes[status]++
+	}
+}
+
+// report summarizes the followed redirects; nil if there were none
+func (t *redirectTracker) report() map[string]interface{} {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if len(t.operations) == 0 {
+		return nil
+	}
+
+	operations := make(map[string]interface{}, len(t.operations))
+	for op, counts := range t.operations {
+		operations[op] = map[string]interface{}{
+			"redirectedRequests": counts.redirected,
+			"hops":               counts.hops,
+			"finalURLs":          counts.finalURLs,
+		}
+	}
+	return map[string]interface{}{
+		"maxHops":     t.config.MaxHops,
+		"statusCodes": t.statusCodes,
+		"operations":  operations,
+	}
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// containerLimits holds the CPU and memory available to this process, taken
+// from the cgroup (v2 or v1) when running in a container
+type containerLimits struct {
+	CPUQuota     float64 // CPUs; 0 if unlimited
+	MemoryLimit  int64   // bytes; 0 if unknown
+	MemorySource string
+}
+
+// readFileTrim returns the trimmed contents of a file, or "" if it can't be read
+func readFileTrim(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// detectContainerLimits reads the cgroup CPU quota and memory limit, falling
+// back to the host's total memory when no memory limit is set
+func detectContainerLimits() containerLimits {
+	var limits containerLimits
+
+	// cgroup v2: "max 100000" or "<quota> <period>"
+	if fields := strings.Fields(readFileTrim("/sys/fs/cgroup/cpu.max")); len(fields) == 2 && fields[0] != "max" {
+		quota, err1 := strconv.ParseFloat(fields[0], 64)
+		period, err2 := strconv.ParseFloat(fields[1], 64)
+		if err1 == nil && err2 == nil && period > 0 {
+			limits.CPUQuota = quota / period
+		}
+	} else if quota, err := strconv.ParseFloat(readFileTrim("/sys/fs/cgroup/cpu/cpu.cfs_quota_us"), 64); err == nil && quota > 0 {
+		// cgroup v1
+		if period, err := strconv.ParseFloat(readFileTrim("/sys/fs/cgroup/cpu/cpu.cfs_period_us"), 64); err == nil && period > 0 {
+			limits.CPUQuota = quota / period
+		}
+	}
+
+	for _, path := range []string{"/sys/fs/cgroup/memory.max", "/sys/fs/cgroup/memory/memory.limit_in_bytes"} {
+		// v1 reports "unlimited" as a huge number close to MaxInt64
+		if limit, err := strconv.ParseInt(readFileTrim(path), 10, 64); err == nil && limit > 0 && limit < 1<<60 {
+			limits.MemoryLimit = limit
+			limits.MemorySource = "cgroup"
+			return limits
+		}
+	}
+
+	if f, err := os.Open("/proc/meminfo"); err == nil {
+		defer f.Close()
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			fields := strings.Fields(scanner.Text())
+			if len(fields) >= 2 && fields[0] == "MemTotal:" {
+				if kb, err := strconv.ParseInt(fields[1], 10, 64); err == nil {
+					limits.MemoryLimit = kb * 1024
+					limits.MemorySource = "host"
+				}
+			}
+		}
+	}
+	return limits
+}
+
+// configureGOMAXPROCS sizes GOMAXPROCS to the container CPU quota (rounded up)
+// instead of the host's core count, which would oversubscribe a throttled
+// container. A positive requested value (-gomaxprocs) takes precedence. It
+// returns the value and where it came from.
+func configureGOMAXPROCS(limits containerLimits, requested int) (int, string) {
+	if requested > 0 {
+		runtime.GOMAXPROCS(requested)
+		return requested, "flag"
+	}
+	procs, from := runtime.NumCPU(), "host CPUs"
+	if limits.CPUQuota > 0 {
+		quotaProcs := int(math.Ceil(limits.CPUQuota))
+		if quotaProcs < procs {
+			procs, from = quotaProcs, "CPU quota"
+		}
+	}
+	if procs < 1 {
+		procs = 1
+	}
+	runtime.GOMAXPROCS(procs)
+	return procs, from
+}
+
+// memoryUsage returns the container's memory usage, or the Go runtime's view of
+// this process when the cgroup doesn't expose it
+func memoryUsage() int64 {
+	for _, path := range []string{"/sys/fs/cgroup/memory.current", "/sys/fs/cgroup/memory/memory.usage_in_bytes"} {
+		if usage, err := strconv.ParseInt(readFileTrim(path), 10, 64); err == nil {
+			return usage
+		}
+	}
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return int64(stats.Sys)
+}
+
+// resourceGuard samples the generator's own CPU and memory use and pauses load
+// generation while either is above its configured share of the limit, so an
+// overloaded generator doesn't show up as target latency
+type resourceGuard struct {
+	limits        containerLimits
+	gomaxprocs    int
+	maxCPUPercent float64 // 0 disables the CPU guard
+	maxMemPercent float64 // 0 disables the memory guard
+
+	throttled        atomic.Bool
+	throttledSamples atomic.Int64
+	stopChan         chan struct{}
+	wg               sync.WaitGroup
+
+	mutex          sync.Mutex
+	peakCPUPercent float64
+	peakMemPercent float64
+
+	// Ephemeral ports and TIME_WAIT sockets (nil where not available)
+	sockets *socketMonitor
+
+	// RLIMIT_NOFILE checked at startup (nil where not available)
+	fileLimit map[string]interface{}
+
+	// Where GOMAXPROCS came from, and the generator goroutines and tick
+	gomaxprocsFrom string
+	tuning         map[string]interface{}
+}
+
+// newResourceGuard creates a guard for the given limits
+func newResourceGuard(limits containerLimits, gomaxprocs int, maxCPUPercent, maxMemPercent float64) *resourceGuard {
+	return &resourceGuard{
+		limits:        limits,
+		gomaxprocs:    gomaxprocs,
+		maxCPUPercent: maxCPUPercent,
+		maxMemPercent: maxMemPercent,
+		stopChan:      make(chan struct{}),
+		sockets:       newSocketMonitor(),
+	}
+}
+
+// Start begins sampling once a second
+func (g *resourceGuard) Start() {
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+
+		availableCPUs := float64(runtime.NumCPU())
+		if g.limits.CPUQuota > 0 {
+			availableCPUs = g.limits.CPUQuota
+		}
+		lastCPU, lastWall := processCPUTime(), time.Now()
+
+		for {
+			select {
+			case <-g.stopChan:
+				return
+			case now := <-ticker.C:
+				cpu := processCPUTime()
+				cpuPercent := float64(cpu-lastCPU) / float64(now.Sub(lastWall)) / availableCPUs * 100
+				lastCPU, lastWall = cpu, now
+
+				memPercent := 0.0
+				if g.limits.MemoryLimit > 0 {
+					memPercent = float64(memoryUsage()) / float64(g.limits.MemoryLimit) * 100
+				}
+
+				g.mutex.Lock()
+				g.peakCPUPercent = math.Max(g.peakCPUPercent, cpuPercent)
+				g.peakMemPercent = math.Max(g.peakMemPercent, memPercent)
+				g.mutex.Unlock()
+
+				over := (g.maxCPUPercent > 0 && cpuPercent > g.maxCPUPercent) ||
+					(g.maxMemPercent > 0 && memPercent > g.maxMemPercent)
+				if over != g.throttled.Load() {
+					if over {
+						fmt.Printf("Generator near its resource limits (CPU %.1f%%, memory %.1f%%), pausing load generation\n", cpuPercent, memPercent)
+					} else {
+						fmt.Println("Generator resources back under limits, resuming load generation")
+					}
+				}
+				g.throttled.Store(over)
+				if over {
+					g.throttledSamples.Add(1)
+				}
+				g.sockets.sample()
+			}
+		}
+	}()
+}
+
+// Stop ends sampling
+func (g *resourceGuard) Stop() {
+	close(g.stopChan)
+	g.wg.Wait()
+}
+
+// Throttled reports whether load generation should currently pause. A nil guard never throttles.
+func (g *resourceGuard) Throttled() bool {
+	return g != nil && g.throttled.Load()
+}
+
+// report describes the limits and how often the guard paused generation
+func (g *resourceGuard) report() map[string]interface{} {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	report := map[string]interface{}{
+		"numCPU":           runtime.NumCPU(),
+		"gomaxprocs":       g.gomaxprocs,
+		"cpuQuota":         g.limits.CPUQuota,
+		"memoryLimitBytes": g.limits.MemoryLimit,
+		"memoryLimitFrom":  g.limits.MemorySource,
+		"peakCPUPercent":   fmt.Sprintf("%.1f", g.peakCPUPercent),
+		"peakMemPercent":   fmt.Sprintf("%.1f", g.peakMemPercent),
+		"throttledSeconds": g.throttledSamples.Load(),
+	}
+	if g.maxCPUPercent > 0 {
+		report["maxCPUPercent"] = g.maxCPUPercent
+	}
+	if g.maxMemPercent > 0 {
+		report["maxMemPercent"] = g.maxMemPercent
+	}
+	if g.gomaxprocsFrom != "" {
+		report["gomaxprocsFrom"] = g.gomaxprocsFrom
+	}
+	if g.tuning != nil {
+		report["generator"] = g.tuning
+	}
+	if g.fileLimit != nil {
+		report["openFiles"] = g.fileLimit
+	}
+	if g.sockets != nil {
+		if sockets := g.sockets.report(); sockets != nil {
+			report["sockets"] = sockets
+		}
+	}
+	return report
+}
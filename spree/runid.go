@@ -0,0 +1,42 @@
+package main
+
+import (
+	"crypto/rand"
+	"fmt"
+	"net/http"
+	"os"
+)
+
+// loadTestIDHeader carries the run ID on every request to the target, so
+// its logs and metrics can tell the load test's traffic apart
+const loadTestIDHeader = "X-Load-Test-ID"
+
+// runIDEnv gives the run ID instead of a new one; wsm k8s sets it so the
+// agents of a distributed run share one
+const runIDEnv = "WSM_RUN_ID"
+
+// runID identifies the run in its requests, results, trace log and collector
+// stream, so artifacts of one run can be joined across systems; set once at
+// startup, before the first request
+var runID string
+
+// newRunID returns WSM_RUN_ID if set, else a random (version 4) UUID
+func newRunID() (string, error) {
+	if id := os.Getenv(runIDEnv); id != "" {
+		return id, nil
+	}
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
+
+// setLoadTestID adds the X-Load-Test-ID header to a request to the target
+func setLoadTestID(header http.Header) {
+	if runID != "" {
+		header.Set(loadTestIDHeader, runID)
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// portWarnPercent is the share of the ephemeral port range in use towards
+// one target address at which the socket monitor warns
+const portWarnPercent = 80
+
+// TCP states in /proc/net/tcp
+const (
+	tcpEstablished = "01"
+	tcpTimeWait    = "06"
+	tcpListen      = "0A"
+)
+
+// socketSample counts the TCP sockets of one reading of /proc/net/tcp{,6}
+type socketSample struct {
+	ephemeral    int    // sockets bound to a local port in the ephemeral range
+	timeWait     int    // sockets in TIME_WAIT
+	established  int    // established sockets
+	busiest      string // remote address with the most ephemeral ports
+	busiestPorts int
+}
+
+// socketMonitor watches the host's ephemeral port use and TIME_WAIT sockets.
+// A connection needs a free local port per target address and port; once
+// the range is used up, by open connections or by closed ones still in
+// TIME_WAIT, connects fail with "cannot assign requested address". The
+// counts cover the network namespace, not just this process.
+type socketMonitor struct {
+	low, high int // ephemeral port range
+	twReuse   string
+
+	mutex         sync.Mutex
+	samples       int
+	warned        bool
+	warnings      int
+	peak          socketSample // reading with the most ports towards one address
+	peakEphemeral int
+	peakTimeWait  int
+	last          socketSample
+}
+
+// newSocketMonitor returns nil where /proc/net/tcp is not available
+func newSocketMonitor() *socketMonitor {
+	if _, err := os.Stat("/proc/net/tcp"); err != nil {
+		return nil
+	}
+	m := &socketMonitor{low: 32768, high: 60999, twReuse: readFileTrim("/proc/sys/net/ipv4/tcp_tw_reuse")}
+	if fields := strings.Fields(readFileTrim("/proc/sys/net/ipv4/ip_local_port_range")); len(fields) == 2 {
+		low, err1 := strconv.Atoi(fields[0])
+		high, err2 := strconv.Atoi(fields[1])
+		if err1 == nil && err2 == nil && high >= low {
+			m.low, m.high = low, high
+		}
+	}
+	return m
+}
+
+// rangeSize is the number of ephemeral ports
+func (m *socketMonitor) rangeSize() int {
+	return m.high - m.low + 1
+}
+
+// procAddr converts a /proc/net/tcp address ("0100007F:1F90") to host:port;
+// the address is stored as 32-bit words in host (little-endian) order
+func procAddr(s string) string {
+	hexIP, hexPort, ok := strings.Cut(s, ":")
+	if !ok {
+		return s
+	}
+	port, err1 := strconv.ParseInt(hexPort, 16, 32)
+	raw, err2 := hex.DecodeString(hexIP)
+	if err1 != nil || err2 != nil || len(raw)%4 != 0 {
+		return s
+	}
+	ip := make(net.IP, len(raw))
+	for i := 0; i < len(raw); i += 4 {
+		ip[i], ip[i+1], ip[i+2], ip[i+3] = raw[i+3], raw[i+2], raw[i+1], raw[i]
+	}
+	return net.JoinHostPort(ip.String(), strconv.FormatInt(port, 10))
+}
+
+// read counts the sockets in /proc/net/tcp and /proc/net/tcp6
+func (m *socketMonitor) read() socketSample {
+	var sample socketSample
+	perRemote := make(map[string]int)
+	for _, path := range []string{"/proc/net/tcp", "/proc/net/tcp6"} {
+		f, err := os.Open(path)
+		if err != nil {
+			continue
+		}
+		scanner := bufio.NewScanner(f)
+		scanner.Scan() // header
+		for scanner.Scan() {
+			fields := strings.Fields(scanner.Text())
+			if len(fields) < 4 || fields[3] == tcpListen {
+				continue
+			}
+			switch fields[3] {
+			case tcpTimeWait:
+				sample.timeWait++
+			case tcpEstablished:
+				sample.established++
+			}
+			hexPort := fields[1][strings.LastIndexByte(fields[1], ':')+1:]
+			if port, err := strconv.ParseInt(hexPort, 16, 32); err != nil || int(port) < m.low || int(port) > m.high {
+				continue
+			}
+			sample.ephemeral++
+			perRemote[fields[2]]++
+		}
+		f.Close()
+	}
+	for remote, ports := range perRemote {
+		if ports > sample.busiestPorts {
+			sample.busiest, sample.busiestPorts = remote, ports
+		}
+	}
+	if sample.busiest != "" {
+		sample.busiest = procAddr(sample.busiest)
+	}
+	return sample
+}
+
+// sample reads the sockets and warns when the ports towards one target
+// address near the end of the range
+func (m *socketMonitor) sample() {
+	if m == nil {
+		return
+	}
+	sample := m.read()
+	percent := float64(sample.busiestPorts) / float64(m.rangeSize()) * 100
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.samples++
+	m.last = sample
+	if sample.busiestPorts > m.peak.busiestPorts {
+		m.peak = sample
+	}
+	if sample.ephemeral > m.peakEphemeral {
+		m.peakEphemeral = sample.ephemeral
+	}
+	if sample.timeWait > m.peakTimeWait {
+		m.peakTimeWait = sample.timeWait
+	}
+	over := percent >= portWarnPercent
+	if over && !m.warned {
+		m.warnings++
+		fmt.Printf("Warning: %d of %d ephemeral ports to %s in use (%.0f%%, %d sockets in TIME_WAIT); new connections will fail when they run out\n",
+			sample.busiestPorts, m.rangeSize(), sample.busiest, percent, sample.timeWait)
+	} else if !over && m.warned {
+		fmt.Printf("Ephemeral ports back under %d%% of the range\n", portWarnPercent)
+	}
+	m.warned = over
+}
+
+// report describes the port use at its peak
+func (m *socketMonitor) report() map[string]interface{} {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.samples == 0 {
+		return nil
+	}
+	report := map[string]interface{}{
+		"ephemeralPortRange": fmt.Sprintf("%d-%d", m.low, m.high),
+		"ephemeralPorts":     m.rangeSize(),
+		"peakPortsToTarget":  m.peak.busiestPorts,
+		"peakPortUsage":      fmt.Sprintf("%.1f%%", float64(m.peak.busiestPorts)/float64(m.rangeSize())*100),
+		"peakEphemeralInUse": m.peakEphemeral,
+		"peakTimeWait":       m.peakTimeWait,
+		"lastTimeWait":       m.last.timeWait,
+		"lastEstablished":    m.last.established,
+		"exhaustionWarnings": m.warnings,
+	}
+	if m.peak.busiest != "" {
+		report["peakTarget"] = m.peak.busiest
+	}
+	if m.twReuse != "" {
+		report["tcpTwReuse"] = m.twReuse
+	}
+	return report
+}
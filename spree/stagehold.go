@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// heldStage is the holds of one stage
+type heldStage struct {
+	stage         int
+	description   string
+	holds         int
+	heldFor       time.Duration
+	heldAtRPS     float64
+	peakErrorRate float64
+}
+
+// stageHold is the hybrid of staged and adaptive load (Test.AdaptiveStages):
+// the stages are followed, but while the error rate of the last sampling
+// window is above the adaptive threshold a rising stage is held. Its clock
+// stops, so the RPS stays where it was instead of continuing the ramp, and
+// resumes once the error rate is back under the threshold. Stages that
+// keep or lower the RPS are never held.
+type stageHold struct {
+	threshold float64
+	window    time.Duration
+
+	lastTick   time.Time
+	lastSample time.Time
+	successful int64 // counters at the last sample
+	failed     int64
+	heldSince  time.Time
+
+	mutex   sync.Mutex
+	stages  []*heldStage
+	current *heldStage // the stage being held, nil when ramping
+}
+
+// newStageHold returns nil unless Test.AdaptiveStages is set
+func newStageHold(config *Config) (*stageHold, error) {
+	if !config.Test.AdaptiveStages {
+		return nil, nil
+	}
+	if config.Test.AdaptiveRPS || config.Test.BurstMode {
+		return nil, fmt.Errorf("AdaptiveStages follows RampupStages and cannot be combined with AdaptiveRPS or BurstMode")
+	}
+	if len(config.Test.RampupStages) == 0 {
+		return nil, fmt.Errorf("AdaptiveStages needs RampupStages")
+	}
+	if config.Test.AdaptiveConfig.ErrorThresholdPercentage <= 0 {
+		return nil, fmt.Errorf("AdaptiveStages needs AdaptiveConfig.ErrorThresholdPercentage")
+	}
+	window := config.Test.AdaptiveConfig.SamplingWindow
+	if window <= 0 {
+		window = 5 * time.Second
+	}
+	return &stageHold{threshold: config.Test.AdaptiveConfig.ErrorThresholdPercentage, window: window}, nil
+}
+
+// pause is called on every generator tick; it returns how long the stage
+// clock stood still since the previous tick
+func (h *stageHold) pause(now time.Time, index int, stage Stage, rps float64, rising bool, metrics *Metrics) time.Duration {
+	if h == nil {
+		return 0
+	}
+	successful := atomic.LoadInt64(&metrics.SuccessfulRequests)
+	failed := atomic.LoadInt64(&metrics.FailedRequests)
+	if h.lastTick.IsZero() {
+		h.lastTick, h.lastSample = now, now
+		h.successful, h.failed = successful, failed
+		return 0
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	var paused time.Duration
+	if h.current != nil {
+		paused = now.Sub(h.lastTick)
+	}
+	h.lastTick = now
+	if now.Sub(h.lastSample) < h.window {
+		return paused
+	}
+
+	errorRate := 0.0
+	if requests := successful - h.successful + failed - h.failed; requests > 0 {
+		errorRate = float64(failed-h.failed) / float64(requests) * 100
+	}
+	h.lastSample = now
+	h.successful, h.failed = successful, failed
+
+	switch {
+	case h.current == nil && rising && errorRate > h.threshold:
+		if len(h.stages) == 0 || h.stages[len(h.stages)-1].stage != index {
+			h.stages = append(h.stages, &heldStage{stage: index, description: stage.Description})
+		}
+		h.current = h.stages[len(h.stages)-1]
+		h.current.holds++
+		h.current.heldAtRPS = rps
+		h.heldSince = now
+		fmt.Printf("Error rate %.2f%% exceeds threshold. Holding stage %d at %s RPS\n", errorRate, index+1, formatRPS(rps))
+	case h.current != nil && (!rising || errorRate <= h.threshold):
+		h.current.heldFor += now.Sub(h.heldSince)
+		h.current = nil
+		fmt.Printf("Error rate %.2f%% below threshold. Resuming stage %d\n", errorRate, index+1)
+	}
+	if h.current != nil && errorRate > h.current.peakErrorRate {
+		h.current.peakErrorRate = errorRate
+	}
+	return paused
+}
+
+// report lists the stages that were held; a hold still on at the end of
+// the test counts until the report
+func (h *stageHold) report() map[string]interface{} {
+	if h == nil {
+		return nil
+	}
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	stages := make([]map[string]interface{}, 0, len(h.stages))
+	total := time.Duration(0)
+	for _, s := range h.stages {
+		heldFor := s.heldFor
+		if s == h.current {
+			heldFor += time.Since(h.heldSince)
+		}
+		total += heldFor
+		stages = append(stages, map[string]interface{}{
+			"stage":         s.stage + 1,
+			"description":   s.description,
+			"holds":         s.holds,
+			"heldFor":       heldFor.Round(time.Millisecond).String(),
+			"heldAtRPS":     roundRPS(s.heldAtRPS),
+			"peakErrorRate": fmt.Sprintf("%.2f%%", s.peakErrorRate),
+		})
+	}
+	return map[string]interface{}{
+		"errorThreshold": fmt.Sprintf("%.2f%%", h.threshold),
+		"samplingWindow": h.window.String(),
+		"heldFor":        total.Round(time.Millisecond).String(),
+		"stages":         stages,
+	}
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ThresholdConfig is the pass/fail criteria checked at the end of the run.
+// Unset limits are not checked; a failed threshold makes the runner exit
+// with status 99 after writing the results.
+type ThresholdConfig struct {
+	P95        time.Duration            // overall p95 latency must stay below this
+	P99        time.Duration            // overall p99 latency must stay below this
+	ErrorRate  float64                  // failed requests in percent must stay below this
+	MinRPS     float64                  // achieved requests per second must reach this
+	Operations map[string]time.Duration // per-operation p95 limits
+}
+
+// thresholdFailedExit is the exit status of a run that missed its thresholds
+const thresholdFailedExit = 99
+
+// summaryTopN is how many operations and error causes the summary lists
+const summaryTopN = 5
+
+var (
+	quotedText = regexp.MustCompile(`"[^"]*"`)
+	digitRuns  = regexp.MustCompile(`[0-9]+`)
+)
+
+// errorSignature groups failures that differ only in URLs, IDs, ports or
+// timings, e.g. every "dial tcp ...: connection refused"
+func errorSignature(status int, errText string) string {
+	text := digitRuns.ReplaceAllString(quotedText.ReplaceAllString(redactText(errText), `"..."`), "N")
+	if len(text) > 100 {
+		text = text[:100] + "..."
+	}
+	switch {
+	case status > 0 && text != "":
+		return fmt.Sprintf("HTTP %d: %s", status, text)
+	case status > 0:
+		return fmt.Sprintf("HTTP %d", status)
+	case text == "":
+		return "request failed"
+	}
+	return text
+}
+
+// errorCause counts one error signature
+type errorCause struct {
+	count      int64
+	operations map[string]int64
+}
+
+// errorTally counts every failed request by its error signature, unlike the
+// error samples which only keep a few
+type errorTally struct {
+	mutex  sync.Mutex
+	causes map[string]*errorCause
+}
+
+func newErrorTally() *errorTally {
+	return &errorTally{causes: make(map[string]*errorCause)}
+}
+
+// count adds a failed request
+func (t *errorTally) count(operation, signature string) {
+	if t == nil {
+		return
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	cause, ok := t.causes[signature]
+	if !ok {
+		cause = &errorCause{operations: make(map[string]int64)}
+		t.causes[signature] = cause
+	}
+	cause.count++
+	cause.operations[operation]++
+}
+
+// report lists the error causes, most frequent first
+func (t *errorTally) report() []map[string]interface{} {
+	if t == nil {
+		return nil
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	causes := make([]map[string]interface{}, 0, len(t.causes))
+	for signature, cause := range t.causes {
+		causes = append(causes, map[string]interface{}{
+			"signature":  signature,
+			"count":      cause.count,
+			"operations": cause.operations,
+		})
+	}
+	sort.Slice(causes, func(i, j int) bool {
+		if causes[i]["count"].(int64) != causes[j]["count"].(int64) {
+			return causes[i]["count"].(int64) > causes[j]["count"].(int64)
+		}
+		return causes[i]["signature"].(string) < causes[j]["signature"].(string)
+	})
+	return causes
+}
+
+// finishRequest ends a request: it counts the error cause of a failure and
+// writes the request's trace line
+func (p *WorkerPool) finishRequest(timing *traceTiming, operation, url string, status int, failed bool, errText string) {
+	if failed {
+		p.Metrics.Errors.count(operation, errorSignature(status, errText))
+	}
+	p.Tracer.finish(timing, operation, url, status, failed, errText)
+}
+
+// reportDuration reads a latency percentile back from the final report
+func reportDuration(stats interface{}, percentile string) (time.Duration, bool) {
+	entry, _ := stats.(map[string]interface{})
+	latency, _ := entry["latency"].(map[string]string)
+	d, err := time.ParseDuration(latency[percentile])
+	return d, err == nil
+}
+
+// reportFloat reads a number the final report may hold formatted, e.g. "41.20" or "1.50%"
+func reportFloat(value interface{}) float64 {
+	switch v := value.(type) {
+	case int64:
+		return float64(v)
+	case float64:
+		return v
+	case string:
+		f, _ := strconv.ParseFloat(strings.TrimSuffix(v, "%"), 64)
+		return f
+	}
+	return 0
+}
+
+// checkThresholds evaluates the thresholds against the final report; it
+// returns nil when none are set
+func checkThresholds(config ThresholdConfig, report map[string]interface{}) []map[string]interface{} {
+	var checks []map[string]interface{}
+	add := func(name string, measured, limit string, passed bool) {
+		checks = append(checks, map[string]interface{}{
+			"threshold": name,
+			"measured":  measured,
+			"limit":     limit,
+			"passed":    passed,
+		})
+	}
+	latencyLimit := func(name string, stats interface{}, percentile string, limit time.Duration) {
+		if limit <= 0 {
+			return
+		}
+		d, ok := reportDuration(stats, percentile)
+		if !ok {
+			add(name, "no data", "< "+limit.String(), false)
+			return
+		}
+		add(name, d.String(), "< "+limit.String(), d < limit)
+	}
+
+	latencyLimit("p95", report, "p95", config.P95)
+	latencyLimit("p99", report, "p99", config.P99)
+	if config.ErrorRate > 0 {
+		total := reportFloat(report["totalRequests"])
+		rate := 0.0
+		if total > 0 {
+			rate = reportFloat(report["failedRequests"]) / total * 100
+		}
+		add("errorRate", fmt.Sprintf("%.2f%%", rate), fmt.Sprintf("< %.2f%%", config.ErrorRate), rate < config.ErrorRate)
+	}
+	if config.MinRPS > 0 {
+		rps := reportFloat(report["actualRPS"])
+		add("rps", fmt.Sprintf("%.2f", rps), fmt.Sprintf(">= %.2f", config.MinRPS), rps >= config.MinRPS)
+	}
+	operations, _ := report["operations"].(map[string]interface{})
+	names := make([]string, 0, len(config.Operations))
+	for name := range config.Operations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		latencyLimit(name+" p95", operations[name], "p95", config.Operations[name])
+	}
+	return checks
+}
+
+// thresholdsPassed reports whether every check passed
+func thresholdsPassed(checks []map[string]interface{}) bool {
+	for _, check := range checks {
+		if !check["passed"].(bool) {
+			return false
+		}
+	}
+	return true
+}
+
+// printSummary prints the short end-of-run summary: overall numbers, the
+// slowest operations by p95, the most frequent error causes, detected
+// anomalies and the SLO and threshold verdicts
+func printSummary(report map[string]interface{}, causes []map[string]interface{}, checks []map[string]interface{}) {
+	fmt.Println("\nSummary:")
+	p95, _ := reportDuration(report, "p95")
+	fmt.Printf("  %v requests, %v successful, %v RPS, p95 %s\n",
+		report["totalRequests"], report["successRate"], report["actualRPS"], p95)
+	if limit, ok := report["maxDuration"].(map[string]interface{}); ok && limit["reached"] == true {
+		fmt.Printf("  Stopped by MaxDuration after %v\n", limit["limit"])
+	}
+
+	type slowOperation struct {
+		name      string
+		p95       time.Duration
+		errorRate interface{}
+	}
+	operations, _ := report["operations"].(map[string]interface{})
+	var slowest []slowOperation
+	for name, stats := range operations {
+		if d, ok := reportDuration(stats, "p95"); ok {
+			slowest = append(slowest, slowOperation{name, d, stats.(map[string]interface{})["errorRate"]})
+		}
+	}
+	sort.Slice(slowest, func(i, j int) bool { return slowest[i].p95 > slowest[j].p95 })
+	if len(slowest) > 0 {
+		fmt.Println("  Slowest operations by p95:")
+		for i, op := range slowest[:min(len(slowest), summaryTopN)] {
+			fmt.Printf("    %d. %-32s p95 %-12s errors %v\n", i+1, op.name, op.p95.Round(time.Microsecond), op.errorRate)
+		}
+	}
+
+	if adaptive, ok := report["adaptive"].(map[string]interface{}); ok {
+		fmt.Printf("  Adaptive result: %v\n", adaptive["headline"])
+	}
+
+	if len(causes) == 0 {
+		fmt.Println("  No errors")
+	} else {
+		fmt.Println("  Top error causes:")
+		for i, cause := range causes[:min(len(causes), summaryTopN)] {
+			fmt.Printf("    %d. %-8d %s\n", i+1, cause["count"], cause["signature"])
+		}
+	}
+
+	if anomalies, ok := report["anomalies"].(map[string]interface{}); ok {
+		events, _ := anomalies["events"].([]map[string]interface{})
+		if len(events) > 0 {
+			var listed []string
+			for _, e := range events[:min(len(events), summaryTopN)] {
+				listed = append(listed, fmt.Sprintf("%s at +%vs for %s", e["kind"], e["offsetSec"], e["duration"]))
+			}
+			fmt.Printf("  Anomalies: %s\n", strings.Join(listed, ", "))
+		}
+	}
+
+	if slo, ok := report["slo"].(map[string]interface{}); ok {
+		printSLOVerdict(slo)
+	}
+
+	if len(checks) == 0 {
+		fmt.Println("  Thresholds: none set")
+		return
+	}
+	if thresholdsPassed(checks) {
+		fmt.Printf("  Thresholds: PASSED (%d checked)\n", len(checks))
+		return
+	}
+	fmt.Println("  Thresholds: FAILED")
+	for _, check := range checks {
+		if !check["passed"].(bool) {
+			fmt.Printf("    %s: %s, limit %s\n", check["threshold"], check["measured"], check["limit"])
+		}
+	}
+}
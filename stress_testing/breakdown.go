@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Error classes of failed requests, so a difference in error rate can be
+// told apart as the platform timing out, refusing connections or failing
+const (
+	errorClassTimeout    = "timeout"    // client or network timeout
+	errorClassConnection = "connection" // dial, reset, TLS or other transport failure
+	errorClass5xx        = "5xx"
+	errorClass4xx        = "4xx"
+	errorClassOther      = "other"   // any other non-2xx status
+	errorClassRequest    = "request" // the request could not be built
+)
+
+// AddResponse records the status code of a response to a request sent at
+// sentAt
+func (m *Metrics) AddResponse(sentAt time.Time, status int) {
+	success := status >= 200 && status < 300
+	m.mutex.Lock()
+	if m.StatusCodes == nil {
+		m.StatusCodes = make(map[int]int64)
+	}
+	m.StatusCodes[status]++
+	if !success {
+		m.addErrorClass(statusErrorClass(status))
+	}
+	m.addToSeries(sentAt, success)
+	m.mutex.Unlock()
+	m.AddResult(success)
+}
+
+// AddError records a request sent at sentAt that got no response
+func (m *Metrics) AddError(sentAt time.Time, class string) {
+	m.mutex.Lock()
+	m.addErrorClass(class)
+	m.addToSeries(sentAt, false)
+	m.mutex.Unlock()
+	m.AddResult(false)
+}
+
+// addErrorClass counts a failure; m.mutex must be held
+func (m *Metrics) addErrorClass(class string) {
+	if m.ErrorClasses == nil {
+		m.ErrorClasses = make(map[string]int64)
+	}
+	m.ErrorClasses[class]++
+}
+
+// statusErrorClass returns the error class of a failed response's status
+func statusErrorClass(status int) string {
+	switch {
+	case status >= 500 && status < 600:
+		return errorClass5xx
+	case status >= 400 && status < 500:
+		return errorClass4xx
+	}
+	return errorClassOther
+}
+
+// transportErrorClass returns the error class of a request that failed
+// without a response
+func transportErrorClass(err error) string {
+	if isTimeoutError(err) {
+		return errorClassTimeout
+	}
+	return errorClassConnection
+}
+
+// breakdown prints and returns the platform's status codes and error
+// classes, with each error class's share of all requests
+func (m *Metrics) breakdown() map[string]interface{} {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	codes := make([]int, 0, len(m.StatusCodes))
+	for code := range m.StatusCodes {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	parts := make([]string, len(codes))
+	statusCodes := make(map[string]int64, len(codes))
+	for i, code := range codes {
+		parts[i] = fmt.Sprintf("%d: %d", code, m.StatusCodes[code])
+		statusCodes[fmt.Sprint(code)] = m.StatusCodes[code]
+	}
+	if len(parts) > 0 {
+		fmt.Printf("  Status Codes: %s\n", strings.Join(parts, ", "))
+	}
+
+	classes := make([]string, 0, len(m.ErrorClasses))
+	for class := range m.ErrorClasses {
+		classes = append(classes, class)
+	}
+	sort.Strings(classes)
+	parts = parts[:0]
+	errorClasses := make(map[string]interface{}, len(classes))
+	for _, class := range classes {
+		count := m.ErrorClasses[class]
+		rate := float64(count) / float64(m.TotalRequests) * 100
+		parts = append(parts, fmt.Sprintf("%s %d (%.2f%%)", class, count, rate))
+		errorClasses[class] = map[string]interface{}{
+			"count": count,
+			"rate":  rate,
+		}
+	}
+	if len(parts) > 0 {
+		fmt.Printf("  Errors: %s\n", strings.Join(parts, ", "))
+	}
+
+	return map[string]interface{}{
+		"statusCodes":  statusCodes,
+		"errorClasses": errorClasses,
+	}
+}
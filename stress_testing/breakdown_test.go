@@ -0,0 +1,98 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestErrorClasses(t *testing.T) {
+	for _, c := range []struct {
+		status int
+		want   string
+	}{
+		{500, errorClass5xx},
+		{503, errorClass5xx},
+		{599, errorClass5xx},
+		{400, errorClass4xx},
+		{429, errorClass4xx},
+		{302, errorClassOther},
+		{600, errorClassOther},
+		{100, errorClassOther},
+	} {
+		if got := statusErrorClass(c.status); got != c.want {
+			t.Errorf("status %d: %s, want %s", c.status, got, c.want)
+		}
+	}
+
+	refused := &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}
+	for _, c := range []struct {
+		err  error
+		want string
+	}{
+		{os.ErrDeadlineExceeded, errorClassTimeout},
+		{fmt.Errorf("Get %q: %w", "http://shop", &net.DNSError{IsTimeout: true}), errorClassTimeout},
+		{refused, errorClassConnection},
+		{errors.New("tls: handshake failure"), errorClassConnection},
+	} {
+		if got := transportErrorClass(c.err); got != c.want {
+			t.Errorf("%v: %s, want %s", c.err, got, c.want)
+		}
+	}
+}
+
+// Requests are classified by what the target did with them
+func TestExecuteRequestClasses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ok":
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+		case "/down":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case "/slow":
+			time.Sleep(500 * time.Millisecond)
+		}
+	}))
+	defer server.Close()
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	p := NewPlatform(PlatformConfig{Name: "test", Client: ClientConfig{TimeoutSeconds: 0.1}})
+	var wg sync.WaitGroup
+	for _, url := range []string{
+		server.URL + "/ok", server.URL + "/ok", server.URL + "/missing", server.URL + "/down",
+		server.URL + "/slow", closed.URL, "http://[::1]:namedport",
+	} {
+		p.Config.URL = url
+		wg.Add(1)
+		p.ExecuteRequest(&wg)
+	}
+
+	breakdown := p.Metrics.breakdown()
+	codes := breakdown["statusCodes"].(map[string]int64)
+	if len(codes) != 3 || codes["200"] != 2 || codes["404"] != 1 || codes["503"] != 1 {
+		t.Errorf("status codes %v", codes)
+	}
+	classes := breakdown["errorClasses"].(map[string]interface{})
+	for class, want := range map[string]int64{errorClass4xx: 1, errorClass5xx: 1, errorClassTimeout: 1, errorClassConnection: 1, errorClassRequest: 1} {
+		entry, _ := classes[class].(map[string]interface{})
+		if entry["count"] != want {
+			t.Errorf("%s: %v, want %d", class, classes[class], want)
+		}
+	}
+	if rate := classes[errorClass5xx].(map[string]interface{})["rate"].(float64); math.Abs(rate-100.0/7) > 1e-9 {
+		t.Errorf("5xx rate %v%%, want one in 7 requests", rate)
+	}
+	if p.Metrics.TotalRequests != 7 || p.Metrics.FailedRequests != 5 {
+		t.Errorf("%d requests, %d failed; want 7 and 5", p.Metrics.TotalRequests, p.Metrics.FailedRequests)
+	}
+}
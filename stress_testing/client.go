@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// ClientConfig tunes a platform's HTTP client. Zero values take the
+// defaults below; the effective settings are written to the results, so a
+// run can be reproduced with the same client.
+type ClientConfig struct {
+	// Requests in flight at most; a send finding all workers busy is skipped
+	// and counted (0 = no limit, one goroutine per request)
+	Workers int
+
+	// Whole-request timeout (default 10)
+	TimeoutSeconds float64
+
+	// Connect timeout (default 5)
+	DialTimeoutSeconds float64
+
+	// TLS handshake timeout (default 5)
+	TLSHandshakeTimeoutSeconds float64
+
+	// How long an idle connection stays pooled (default 90)
+	IdleConnTimeoutSeconds float64
+
+	// Connection pool limits (defaults 3000, 1000 and 1000)
+	MaxIdleConns        int
+	MaxIdleConnsPerHost int
+	MaxConnsPerHost     int
+
+	// Open a new connection for every request
+	DisableKeepAlives bool
+
+	// Stay on HTTP/1.1 even where the target offers HTTP/2
+	DisableHTTP2 bool
+}
+
+// withDefaults checks the settings and fills in the defaults
+func (c ClientConfig) withDefaults() (ClientConfig, error) {
+	for name, v := range map[string]float64{
+		"Workers":                    float64(c.Workers),
+		"TimeoutSeconds":             c.TimeoutSeconds,
+		"DialTimeoutSeconds":         c.DialTimeoutSeconds,
+		"TLSHandshakeTimeoutSeconds": c.TLSHandshakeTimeoutSeconds,
+		"IdleConnTimeoutSeconds":     c.IdleConnTimeoutSeconds,
+		"MaxIdleConns":               float64(c.MaxIdleConns),
+		"MaxIdleConnsPerHost":        float64(c.MaxIdleConnsPerHost),
+		"MaxConnsPerHost":            float64(c.MaxConnsPerHost),
+	} {
+		if v < 0 {
+			return c, fmt.Errorf("Client.%s %g is negative", name, v)
+		}
+	}
+	if c.TimeoutSeconds == 0 {
+		c.TimeoutSeconds = 10
+	}
+	if c.DialTimeoutSeconds == 0 {
+		c.DialTimeoutSeconds = 5
+	}
+	if c.TLSHandshakeTimeoutSeconds == 0 {
+		c.TLSHandshakeTimeoutSeconds = 5
+	}
+	if c.IdleConnTimeoutSeconds == 0 {
+		c.IdleConnTimeoutSeconds = 90
+	}
+	if c.MaxIdleConns == 0 {
+		c.MaxIdleConns = 3000
+	}
+	if c.MaxIdleConnsPerHost == 0 {
+		c.MaxIdleConnsPerHost = 1000
+	}
+	if c.MaxConnsPerHost == 0 {
+		c.MaxConnsPerHost = 1000
+	}
+	return c, nil
+}
+
+// seconds converts a seconds setting to a duration
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// newClient builds the platform's HTTP client from the settings, spreading
+// connections over the dialer's source addresses
+func newClient(c ClientConfig, dialer *sourceIPDialer) *http.Client {
+	transport := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		MaxIdleConns:          c.MaxIdleConns,
+		MaxIdleConnsPerHost:   c.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       c.MaxConnsPerHost,
+		IdleConnTimeout:       seconds(c.IdleConnTimeoutSeconds),
+		TLSHandshakeTimeout:   seconds(c.TLSHandshakeTimeoutSeconds),
+		ExpectContinueTimeout: 1 * time.Second,
+		DisableCompression:    true,
+		DisableKeepAlives:     c.DisableKeepAlives,
+		ForceAttemptHTTP2:     !c.DisableHTTP2,
+	}
+
+	return &http.Client{
+		Transport: transport,
+		Timeout:   seconds(c.TimeoutSeconds),
+	}
+}
+
+// acquireWorker takes a worker for the next request; it returns false when
+// all are busy. Without a Workers limit it always succeeds.
+func (p *Platform) acquireWorker() bool {
+	if p.workers == nil {
+		return true
+	}
+	select {
+	case p.workers <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// releaseWorker frees the worker of a finished request
+func (p *Platform) releaseWorker() {
+	if p.workers != nil {
+		<-p.workers
+	}
+}
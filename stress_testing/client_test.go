@@ -0,0 +1,67 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestClientConfigDefaults(t *testing.T) {
+	c, err := ClientConfig{}.withDefaults()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := ClientConfig{TimeoutSeconds: 10, DialTimeoutSeconds: 5, TLSHandshakeTimeoutSeconds: 5, IdleConnTimeoutSeconds: 90,
+		MaxIdleConns: 3000, MaxIdleConnsPerHost: 1000, MaxConnsPerHost: 1000}
+	if c != want {
+		t.Errorf("defaults %+v, want %+v", c, want)
+	}
+
+	// Set values are kept
+	c, err = ClientConfig{Workers: 8, TimeoutSeconds: 0.5, MaxConnsPerHost: 16, DisableHTTP2: true}.withDefaults()
+	if err != nil || c.Workers != 8 || c.TimeoutSeconds != 0.5 || c.MaxConnsPerHost != 16 || !c.DisableHTTP2 {
+		t.Errorf("settings %+v, %v", c, err)
+	}
+
+	for _, c := range []ClientConfig{{Workers: -1}, {TimeoutSeconds: -1}, {MaxIdleConnsPerHost: -1}} {
+		if _, err := c.withDefaults(); err == nil {
+			t.Errorf("%+v: no error", c)
+		}
+	}
+}
+
+func TestNewClient(t *testing.T) {
+	c, _ := ClientConfig{TimeoutSeconds: 2.5, IdleConnTimeoutSeconds: 30, MaxConnsPerHost: 16, DisableKeepAlives: true, DisableHTTP2: true}.withDefaults()
+	dialer, err := newSourceIPDialer(nil, time.Second, 30*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := newClient(c, dialer)
+	transport := client.Transport.(*http.Transport)
+	if client.Timeout != 2500*time.Millisecond || transport.IdleConnTimeout != 30*time.Second || transport.MaxConnsPerHost != 16 ||
+		!transport.DisableKeepAlives || transport.ForceAttemptHTTP2 {
+		t.Errorf("client timeout %s, transport %+v", client.Timeout, transport)
+	}
+}
+
+func TestWorkers(t *testing.T) {
+	p := &Platform{workers: make(chan struct{}, 2)}
+	if !p.acquireWorker() || !p.acquireWorker() {
+		t.Fatal("free workers not acquired")
+	}
+	if p.acquireWorker() {
+		t.Error("a third request got one of 2 workers")
+	}
+	p.releaseWorker()
+	if !p.acquireWorker() {
+		t.Error("released worker not acquired")
+	}
+
+	unlimited := &Platform{}
+	for i := 0; i < 100; i++ {
+		if !unlimited.acquireWorker() {
+			t.Fatal("no worker without a Workers limit")
+		}
+	}
+	unlimited.releaseWorker()
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+// credentialRef is a reference to a credential kept out of the config file,
+// e.g. "Bearer ${env:SPREE_TOKEN}". It may appear in any string of the config.
+//
+//	${env:NAME}                 environment variable
+//	${keyring:service/account}  OS keyring (secret-tool on Linux, security on macOS)
+//	${sops:file#key.path}       value in a sops-encrypted JSON/YAML file
+//	${age:file}                 age-encrypted file, or ${age:file#key.path} for a JSON one
+//
+// Relative files are resolved against the config file's directory.
+var credentialRef = regexp.MustCompile(`\$\{(env|keyring|sops|age):([^}]*)\}`)
+
+// resolvedCredentials are the values resolveCredentials filled in; they are
+// redacted wherever they would be echoed, whatever field they ended up in
+var resolvedCredentials []string
+
+// credentialResolver fills in credential references, fetching each once
+type credentialResolver struct {
+	dir   string
+	cache map[string]string
+}
+
+// resolveCredentials replaces the credential references in the config with
+// the credentials they point to
+func resolveCredentials(config *Config, configPath string) error {
+	r := &credentialResolver{dir: filepath.Dir(configPath), cache: map[string]string{}}
+	return r.walk(reflect.ValueOf(config).Elem(), "config")
+}
+
+// walk resolves the references in every string reachable from v
+func (r *credentialResolver) walk(v reflect.Value, path string) error {
+	switch v.Kind() {
+	case reflect.String:
+		if !strings.Contains(v.String(), "${") {
+			return nil
+		}
+		resolved, err := r.expand(v.String())
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		v.SetString(resolved)
+	case reflect.Pointer:
+		if !v.IsNil() {
+			return r.walk(v.Elem(), path)
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if field := v.Type().Field(i); field.IsExported() {
+				if err := r.walk(v.Field(i), path+"."+field.Name); err != nil {
+					return err
+				}
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := r.walk(v.Index(i), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		// Map values are not addressable: resolve a copy and store it back
+		iter := v.MapRange()
+		for iter.Next() {
+			value := reflect.New(v.Type().Elem()).Elem()
+			value.Set(iter.Value())
+			if err := r.walk(value, fmt.Sprintf("%s[%v]", path, iter.Key())); err != nil {
+				return err
+			}
+			v.SetMapIndex(iter.Key(), value)
+		}
+	}
+	return nil
+}
+
+// expand replaces the references in s
+func (r *credentialResolver) expand(s string) (string, error) {
+	var failed error
+	expanded := credentialRef.ReplaceAllStringFunc(s, func(ref string) string {
+		if failed != nil {
+			return ref
+		}
+		if value, ok := r.cache[ref]; ok {
+			return value
+		}
+		match := credentialRef.FindStringSubmatch(ref)
+		value, err := r.lookup(match[1], match[2])
+		if err != nil {
+			failed = fmt.Errorf("%s: %v", ref, err)
+			return ref
+		}
+		r.cache[ref] = value
+		resolvedCredentials = append(resolvedCredentials, value)
+		return value
+	})
+	return expanded, failed
+}
+
+// lookup fetches one credential
+func (r *credentialResolver) lookup(scheme, ref string) (string, error) {
+	switch scheme {
+	case "env":
+		value, ok := os.LookupEnv(ref)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", ref)
+		}
+		return value, nil
+	case "keyring":
+		service, account, ok := strings.Cut(ref, "/")
+		if !ok || service == "" || account == "" {
+			return "", fmt.Errorf("keyring reference needs service/account")
+		}
+		var out []byte
+		var err error
+		switch runtime.GOOS {
+		case "darwin":
+			out, err = runCredentialCommand("security", "find-generic-password", "-s", service, "-a", account, "-w")
+		case "windows":
+			return "", fmt.Errorf("the keyring is not supported on Windows; use ${env:...}")
+		default:
+			out, err = runCredentialCommand("secret-tool", "lookup", "service", service, "account", account)
+		}
+		if err != nil {
+			return "", err
+		}
+		value := strings.TrimRight(string(out), "\r\n")
+		if value == "" {
+			return "", fmt.Errorf("no keyring entry for service %q, account %q", service, account)
+		}
+		return value, nil
+	case "sops":
+		file, key, _ := strings.Cut(ref, "#")
+		if key == "" {
+			return "", fmt.Errorf("sops reference needs file#key")
+		}
+		out, err := runCredentialCommand("sops", "--decrypt", "--output-type", "json", r.path(file))
+		if err != nil {
+			return "", err
+		}
+		return credentialField(out, key)
+	case "age":
+		file, key, _ := strings.Cut(ref, "#")
+		identity := os.Getenv("WSM_AGE_IDENTITY")
+		if identity == "" {
+			identity = os.Getenv("SOPS_AGE_KEY_FILE")
+		}
+		if identity == "" {
+			return "", fmt.Errorf("set WSM_AGE_IDENTITY to the age identity file")
+		}
+		out, err := runCredentialCommand("age", "--decrypt", "-i", identity, r.path(file))
+		if err != nil {
+			return "", err
+		}
+		if key != "" {
+			return credentialField(out, key)
+		}
+		return strings.TrimRight(string(out), "\r\n"), nil
+	}
+	return "", fmt.Errorf("unknown credential source %q", scheme)
+}
+
+// path resolves a credential file against the config file's directory
+func (r *credentialResolver) path(file string) string {
+	if filepath.IsAbs(file) {
+		return file
+	}
+	return filepath.Join(r.dir, file)
+}
+
+// runCredentialCommand runs a decryption or keyring tool and returns its
+// output; the tool's error message is kept, its output is not
+func runCredentialCommand(name string, args ...string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(name, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	cmd.Stdin = os.Stdin // keyring unlock and passphrase prompts
+	if err := cmd.Run(); err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return nil, fmt.Errorf("%s: %v: %s", name, err, message)
+		}
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return stdout.Bytes(), nil
+}
+
+// credentialField returns the value at a dotted key path in a decrypted
+// JSON document
+func credentialField(data []byte, key string) (string, error) {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return "", fmt.Errorf("decrypted file is not JSON: %v", err)
+	}
+	for _, part := range strings.Split(key, ".") {
+		object, ok := v.(map[string]interface{})
+		if !ok {
+			return "", fmt.Errorf("no key %q in the decrypted file", key)
+		}
+		if v, ok = object[part]; !ok {
+			return "", fmt.Errorf("no key %q in the decrypted file", key)
+		}
+	}
+	switch value := v.(type) {
+	case string:
+		return value, nil
+	case float64, bool:
+		return fmt.Sprint(value), nil
+	}
+	return "", fmt.Errorf("key %q in the decrypted file is not a single value", key)
+}
@@ -8,8 +8,8 @@ import (
 	"io"
 	"log"
 	"math"
-	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
 	"runtime"
@@ -26,6 +26,10 @@ type PlatformConfig struct {
 	Headers   map[string]string
 	Query     string
 	IsGraphQL bool
+	// Local IPs or interface names to bind outgoing connections to (round-robin)
+	SourceIPs []string
+	// Workers, timeouts and connection pool of the HTTP client
+	Client ClientConfig
 }
 
 // Config holds the application configuration
@@ -35,6 +39,21 @@ type Config struct {
 	Test   struct {
 		DurationSeconds int
 		RPS             int
+
+		// Hold back the platform whose responses run ahead, so both are
+		// compared at the throughput the slower one achieves
+		MatchAchievedRPS bool
+
+		// Flag a platform whose achieved RPS is off the target by more than
+		// this many percent (0 = 10)
+		MaxRPSDivergence float64
+
+		// How the platforms share the generator: parallel (default),
+		// sequential or interleaved
+		Mode string
+
+		// Length of each platform's turn in interleaved mode (0 = 10)
+		InterleaveSeconds int
 	}
 }
 
@@ -43,6 +62,10 @@ type Metrics struct {
 	TotalRequests      int64
 	SuccessfulRequests int64
 	FailedRequests     int64
+	StatusCodes        map[int]int64    // responses by status code
+	ErrorClasses       map[string]int64 // failures by error class
+	Start              time.Time        // when the run started; the error series counts from here
+	buckets            []errorBucket
 	mutex              sync.RWMutex
 }
 
@@ -78,47 +101,46 @@ type Platform struct {
 	Metrics  *Metrics
 	StopChan chan struct{}
 	client   *http.Client
+	settings ClientConfig  // Config.Client with the defaults filled in
+	workers  chan struct{} // one slot per worker; nil without a limit
+
+	sent      int64         // requests sent
+	throttled int64         // sends held back to match the other platform's throughput
+	busy      int64         // sends skipped because all workers were busy
+	elapsed   time.Duration // sending and waiting for the responses
 }
 
 // NewPlatform creates a new platform instance with optimized HTTP client
 func NewPlatform(config PlatformConfig) *Platform {
-	// Create a custom dialer with shorter timeouts
-	dialer := &net.Dialer{
-		Timeout:   5 * time.Second,
-		KeepAlive: 30 * time.Second,
-	}
-
-	// Configure transport for high-concurrency testing
-	transport := &http.Transport{
-		Proxy:                 http.ProxyFromEnvironment,
-		DialContext:           dialer.DialContext,
-		MaxIdleConns:          3000,
-		MaxIdleConnsPerHost:   1000,
-		MaxConnsPerHost:       1000,
-		IdleConnTimeout:       90 * time.Second,
-		TLSHandshakeTimeout:   5 * time.Second,
-		ExpectContinueTimeout: 1 * time.Second,
-		DisableCompression:    true,
-		DisableKeepAlives:     false,
-		ForceAttemptHTTP2:     true,
+	settings, err := config.Client.withDefaults()
+	if err != nil {
+		log.Fatalf("Invalid Client configuration for %s: %v", config.Name, err)
 	}
 
-	client := &http.Client{
-		Transport: transport,
-		Timeout:   10 * time.Second,
+	// Create a custom dialer with shorter timeouts, spread over the configured source addresses
+	dialer, err := newSourceIPDialer(config.SourceIPs, seconds(settings.DialTimeoutSeconds), 30*time.Second)
+	if err != nil {
+		log.Fatalf("Invalid SourceIPs configuration for %s: %v", config.Name, err)
 	}
 
-	return &Platform{
+	p := &Platform{
 		Config:   config,
 		Metrics:  &Metrics{},
 		StopChan: make(chan struct{}),
-		client:   client,
+		client:   newClient(settings, dialer),
+		settings: settings,
+	}
+	if settings.Workers > 0 {
+		p.workers = make(chan struct{}, settings.Workers)
 	}
+	return p
 }
 
 // ExecuteRequest performs a single request to the platform
 func (p *Platform) ExecuteRequest(wg *sync.WaitGroup) {
 	defer wg.Done()
+	defer p.releaseWorker()
+	sentAt := time.Now()
 	
 	var req *http.Request
 	var err error
@@ -131,7 +153,7 @@ func (p *Platform) ExecuteRequest(wg *sync.WaitGroup) {
 
 		reqBody, err := json.Marshal(graphqlReq)
 		if err != nil {
-			p.Metrics.AddResult(false)
+			p.Metrics.AddError(sentAt, errorClassRequest)
 			return
 		}
 
@@ -142,7 +164,7 @@ func (p *Platform) ExecuteRequest(wg *sync.WaitGroup) {
 	}
 
 	if err != nil {
-		p.Metrics.AddResult(false)
+		p.Metrics.AddError(sentAt, errorClassRequest)
 		return
 	}
 
@@ -150,13 +172,14 @@ func (p *Platform) ExecuteRequest(wg *sync.WaitGroup) {
 	for key, value := range p.Config.Headers {
 		req.Header.Set(key, value)
 	}
+	setLoadTestID(req.Header)
 
 	// Execute request
 	resp, err := p.client.Do(req)
 	
 	// Handle response
 	if err != nil {
-		p.Metrics.AddResult(false)
+		p.Metrics.AddError(sentAt, transportErrorClass(err))
 		return
 	}
 
@@ -164,13 +187,13 @@ func (p *Platform) ExecuteRequest(wg *sync.WaitGroup) {
 	io.Copy(io.Discard, resp.Body)
 	resp.Body.Close()
 
-	// Check if request was successful
-	success := resp.StatusCode >= 200 && resp.StatusCode < 300
-	p.Metrics.AddResult(success)
+	// Record the status code; only 2xx counts as success
+	p.Metrics.AddResponse(sentAt, resp.StatusCode)
 }
 
-// StressTest runs a high-RPS stress test against the platform
-func StressTest(p *Platform, rps int, duration time.Duration) {
+// StressTest runs a high-RPS stress test against the platform; a matcher
+// holds it back to the other platform's achieved throughput
+func StressTest(p *Platform, rps int, duration time.Duration, matcher *rpsMatcher) {
 	fmt.Printf("Starting stress test for %s at %d RPS for %s\n", 
 		p.Config.Name, rps, duration.String())
 
@@ -187,28 +210,37 @@ func StressTest(p *Platform, rps int, duration time.Duration) {
 	defer reportTicker.Stop()
 
 	// Set deadline
-	deadline := time.Now().Add(duration)
+	testStart := time.Now()
+	deadline := testStart.Add(duration)
+	startSent := atomic.LoadInt64(&p.sent) // earlier turns of an interleaved run
+	startCompleted := atomic.LoadInt64(&p.Metrics.TotalRequests)
 	
 	// WaitGroup for tracking in-flight requests
 	var wg sync.WaitGroup
 	
-	// Track progress
-	var requestsSent int64
-	
-	// Report current status
+	// Report current status until this call returns; an interleaved run
+	// calls StressTest once per turn
+	done := make(chan struct{})
+	defer close(done)
 	go func() {
 		lastReported := int64(0)
 		for {
 			select {
 			case <-reportTicker.C:
-				current := atomic.LoadInt64(&requestsSent)
-				currentReqs := atomic.LoadInt64(&p.Metrics.TotalRequests)
+				current := atomic.LoadInt64(&p.sent) - startSent
+				currentReqs := atomic.LoadInt64(&p.Metrics.TotalRequests) - startCompleted
 				rate := current - lastReported
 				lastReported = current
 				percent := float64(current) / float64(totalRequests) * 100
-				fmt.Printf("%s: %d/%d requests (%.1f%%) - Sent: %d RPS, Completed: %d\n", 
-					p.Config.Name, current, totalRequests, percent, rate, currentReqs)
-			case <-p.StopChan:
+				elapsed := time.Since(testStart)
+				remaining := time.Until(deadline)
+				if remaining < 0 {
+					remaining = 0
+				}
+				fmt.Printf("%s: %d/%d requests (%.1f%%) - Sent: %d RPS, Completed: %d, Elapsed: %s, Remaining: %s\n", 
+					p.Config.Name, current, totalRequests, percent, rate, currentReqs,
+					elapsed.Round(time.Second), remaining.Round(time.Second))
+			case <-done:
 				return
 			}
 		}
@@ -218,12 +250,21 @@ func StressTest(p *Platform, rps int, duration time.Duration) {
 	for time.Now().Before(deadline) {
 		select {
 		case <-ticker.C:
+			if !matcher.allow(p) {
+				atomic.AddInt64(&p.throttled, 1)
+				continue
+			}
+			if !p.acquireWorker() {
+				atomic.AddInt64(&p.busy, 1)
+				continue
+			}
 			wg.Add(1)
-			atomic.AddInt64(&requestsSent, 1)
+			atomic.AddInt64(&p.sent, 1)
 			go p.ExecuteRequest(&wg)
 		case <-p.StopChan:
 			fmt.Printf("%s: Test interrupted\n", p.Config.Name)
 			wg.Wait()
+			p.elapsed += time.Since(testStart)
 			return
 		}
 	}
@@ -231,13 +272,18 @@ func StressTest(p *Platform, rps int, duration time.Duration) {
 	// Wait for any remaining requests to complete
 	fmt.Printf("%s: All requests sent, waiting for completion...\n", p.Config.Name)
 	wg.Wait()
+	p.elapsed += time.Since(testStart)
 	fmt.Printf("%s: Test completed. Sent %d requests, processed %d responses\n", 
-		p.Config.Name, requestsSent, p.Metrics.TotalRequests)
+		p.Config.Name, p.sent-startSent, p.Metrics.TotalRequests-startCompleted)
 }
 
 func main() {
 	// Parse command line arguments
 	configPath := flag.String("config", "stress_test_config.json", "Path to the configuration file")
+	outDir := flag.String("out-dir", ".", "Directory to write the results file to")
+	outName := flag.String("out-name", defaultResultsName, "Results file name template ({platform}, {timestamp}, {date})")
+	targetPolicyPath := flag.String("target-policy", defaultTargetPolicyFile, "Policy keeping high-RPS plans off production hosts, used if present (empty = off)")
+	productionOverride := flag.Bool("i-know-this-is-production", false, "Run above the target policy's MaxRPS against production hosts anyway")
 	flag.Parse()
 
 	// Set GOMAXPROCS to use all available CPU cores
@@ -248,6 +294,24 @@ func main() {
 	if err != nil {
 		log.Fatalf("Failed to load configuration: %v", err)
 	}
+	mode, err := checkMode(config.Test.Mode, config.Test.InterleaveSeconds, config.Test.MatchAchievedRPS)
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	targetPolicy, err := loadTargetPolicy(*targetPolicyPath)
+	if err != nil {
+		log.Fatalf("Invalid target policy: %v", err)
+	}
+	policyReport, err := checkTargetPolicy(targetPolicy, platformHosts(config), float64(config.Test.RPS), *productionOverride)
+	if err != nil {
+		log.Fatalf("Refusing to run: %v", err)
+	}
+	id, err := newRunID()
+	if err != nil {
+		log.Fatalf("Generating the run ID failed: %v", err)
+	}
+	runID = id
+	fmt.Printf("Run ID: %s (sent as %s)\n", runID, loadTestIDHeader)
 
 	// Create platforms
 	saleor := NewPlatform(config.Saleor)
@@ -268,28 +332,41 @@ func main() {
 	// Set test parameters
 	testDuration := time.Duration(config.Test.DurationSeconds) * time.Second
 	rps := config.Test.RPS
+	maxDivergence := config.Test.MaxRPSDivergence
+	if maxDivergence == 0 {
+		maxDivergence = defaultMaxRPSDivergence
+	}
+	var matcher *rpsMatcher
+	if config.Test.MatchAchievedRPS {
+		matcher = newRPSMatcher(rps, saleor, medusa)
+		fmt.Println("Matching achieved RPS: the platform ahead is held back to the other's throughput")
+	}
 
-	// Run tests in parallel
-	var wg sync.WaitGroup
-	wg.Add(2)
-	
-	go func() {
-		defer wg.Done()
-		StressTest(saleor, rps, testDuration)
-	}()
-	
-	go func() {
-		defer wg.Done()
-		StressTest(medusa, rps, testDuration)
-	}()
+	interleave := time.Duration(config.Test.InterleaveSeconds) * time.Second
+	if interleave == 0 {
+		interleave = defaultInterleaveSeconds * time.Second
+	}
+	switch mode {
+	case modeSequential:
+		fmt.Println("Running the platforms one after the other")
+	case modeInterleaved:
+		fmt.Printf("Running the platforms in alternating %s turns\n", interleave)
+	}
 
-	// Wait for both tests to complete
-	wg.Wait()
+	// Run the tests and wait for both to complete
+	testStart := time.Now()
+	saleor.Metrics.Start = testStart
+	medusa.Metrics.Start = testStart
+	runPlatforms(mode, interleave, rps, testDuration, matcher, saleor, medusa)
 
 	// Print comparison results
 	fmt.Println("\n----- ERROR RATE COMPARISON RESULTS -----")
-	fmt.Printf("Test Duration: %d seconds at target %d RPS\n\n", 
-		config.Test.DurationSeconds, config.Test.RPS)
+	fmt.Printf("Test Duration: %d seconds at target %d RPS (%s)\n", 
+		config.Test.DurationSeconds, config.Test.RPS, mode)
+	if matcher != nil {
+		fmt.Printf("Matched achieved RPS: %.1f\n", math.Min(saleor.AchievedRPS(), medusa.AchievedRPS()))
+	}
+	fmt.Println()
 
 	saleorErrorRate := saleor.Metrics.GetErrorRate()
 	medusaErrorRate := medusa.Metrics.GetErrorRate()
@@ -297,12 +374,20 @@ func main() {
 	fmt.Printf("Saleor:\n")
 	fmt.Printf("  Total Requests Processed: %d\n", saleor.Metrics.TotalRequests)
 	fmt.Printf("  Success Rate: %.2f%%\n", saleor.Metrics.GetSuccessRate())
-	fmt.Printf("  Error Rate: %.2f%%\n\n", saleorErrorRate)
+	fmt.Printf("  Error Rate: %.2f%%\n", saleorErrorRate)
+	saleorBreakdown := saleor.Metrics.breakdown()
+	saleorSeries := saleor.Metrics.errorSeries()
+	saleorThroughput := throughputReport(saleor, rps, maxDivergence, matcher != nil)
+	fmt.Println()
 
 	fmt.Printf("Medusa:\n")
 	fmt.Printf("  Total Requests Processed: %d\n", medusa.Metrics.TotalRequests)
 	fmt.Printf("  Success Rate: %.2f%%\n", medusa.Metrics.GetSuccessRate())
-	fmt.Printf("  Error Rate: %.2f%%\n\n", medusaErrorRate)
+	fmt.Printf("  Error Rate: %.2f%%\n", medusaErrorRate)
+	medusaBreakdown := medusa.Metrics.breakdown()
+	medusaSeries := medusa.Metrics.errorSeries()
+	medusaThroughput := throughputReport(medusa, rps, maxDivergence, matcher != nil)
+	fmt.Println()
 
 	// Determine which platform performed better
 	fmt.Println("Comparison:")
@@ -318,17 +403,31 @@ func main() {
 
 	// Save results to file
 	results := map[string]interface{}{
-		"testDuration": config.Test.DurationSeconds,
-		"targetRPS":    config.Test.RPS,
+		"runId":              runID,
+		"testDuration":       config.Test.DurationSeconds,
+		"targetRPS":          config.Test.RPS,
+		"mode":               mode,
+		"matchedAchievedRPS": config.Test.MatchAchievedRPS,
+		"maxRPSDivergence":   maxDivergence,
 		"saleor": map[string]interface{}{
 			"totalRequests": saleor.Metrics.TotalRequests,
 			"successRate":   saleor.Metrics.GetSuccessRate(),
 			"errorRate":     saleorErrorRate,
+			"statusCodes":   saleorBreakdown["statusCodes"],
+			"errorClasses":  saleorBreakdown["errorClasses"],
+			"errorSeries":   saleorSeries,
+			"throughput":    saleorThroughput,
+			"client":        saleor.settings,
 		},
 		"medusa": map[string]interface{}{
 			"totalRequests": medusa.Metrics.TotalRequests,
 			"successRate":   medusa.Metrics.GetSuccessRate(),
 			"errorRate":     medusaErrorRate,
+			"statusCodes":   medusaBreakdown["statusCodes"],
+			"errorClasses":  medusaBreakdown["errorClasses"],
+			"errorSeries":   medusaSeries,
+			"throughput":    medusaThroughput,
+			"client":        medusa.settings,
 		},
 		"comparisonResult": map[string]interface{}{
 			"errorRateDifference": errorRateDiff,
@@ -343,13 +442,35 @@ func main() {
 		},
 	}
 
+	if mode == modeInterleaved {
+		results["interleaveSeconds"] = interleave.Seconds()
+	}
+	if matcher != nil {
+		results["matchedRPS"] = math.Min(saleor.AchievedRPS(), medusa.AchievedRPS())
+	}
+	if policyReport != nil {
+		results["targetPolicy"] = policyReport
+	}
+
 	resultsJSON, _ := json.MarshalIndent(results, "", "  ")
-	err = os.WriteFile("stress_test_results.json", resultsJSON, 0644)
+	output := resultsOutput{Dir: *outDir, NameTemplate: *outName}
+	path, err := output.write("stress_test", testStart, resultsJSON)
 	if err != nil {
 		fmt.Printf("Error writing results file: %v\n", err)
 	} else {
-		fmt.Println("Results saved to stress_test_results.json")
+		fmt.Printf("Results saved to %s\n", path)
+	}
+}
+
+// platformHosts returns the hosts of the platforms' URLs
+func platformHosts(config *Config) []string {
+	var hosts []string
+	for _, platform := range []PlatformConfig{config.Saleor, config.Medusa} {
+		if u, err := url.Parse(platform.URL); err == nil && u.Host != "" {
+			hosts = append(hosts, u.Host)
+		}
 	}
+	return hosts
 }
 
 // loadConfig loads the configuration from a file or creates a default one
@@ -357,7 +478,11 @@ func loadConfig(path string) (*Config, error) {
 	configFile, err := os.Open(path)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return createDefaultConfig(path)
+			config, err := createDefaultConfig(path)
+			if err != nil {
+				return nil, err
+			}
+			return config, checkPublishableKey(config, path)
 		}
 		return nil, err
 	}
@@ -367,8 +492,22 @@ func loadConfig(path string) (*Config, error) {
 	if err := json.NewDecoder(configFile).Decode(&config); err != nil {
 		return nil, err
 	}
+	if err := resolveCredentials(&config, path); err != nil {
+		return nil, fmt.Errorf("invalid credentials configuration: %v", err)
+	}
+
+	return &config, checkPublishableKey(&config, path)
+}
+
+// apiKeyPlaceholder is the Medusa publishable key of a created default config
+const apiKeyPlaceholder = "pk_your_publishable_key"
 
-	return &config, nil
+// checkPublishableKey refuses a config still holding the placeholder key
+func checkPublishableKey(config *Config, path string) error {
+	if config.Medusa.Headers["x-publishable-api-key"] == apiKeyPlaceholder {
+		return fmt.Errorf("set the Medusa x-publishable-api-key header in %s to a publishable API key of the store", path)
+	}
+	return nil
 }
 
 // createDefaultConfig creates a default configuration file
@@ -404,7 +543,7 @@ func createDefaultConfig(path string) (*Config, error) {
 		Headers: map[string]string{
 			"Accept":                "application/json",
 			"Content-Type":          "application/json",
-			"x-publishable-api-key": "pk_cf8ea2bcf8f97ee114ed8797b464ffb068777ff1751ac7b0612f58b06dca21fa",
+			"x-publishable-api-key": apiKeyPlaceholder, // no real key is written out
 		},
 	}
 
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Test.Mode values: how the platforms share the generator
+const (
+	// Both platforms are loaded at the same time (the default)
+	modeParallel = "parallel"
+
+	// One platform after the other, each for the full duration, so their
+	// connection pools and the generator's CPU don't contend
+	modeSequential = "sequential"
+
+	// The platforms take turns in windows of Test.InterleaveSeconds until
+	// each has had the full duration, so neither gets the quieter hours
+	modeInterleaved = "interleaved"
+)
+
+// defaultInterleaveSeconds is the Test.InterleaveSeconds used when unset
+const defaultInterleaveSeconds = 10
+
+// checkMode validates the mode settings and returns the mode, defaulting to
+// parallel
+func checkMode(mode string, interleaveSeconds int, matchAchievedRPS bool) (string, error) {
+	switch mode {
+	case "":
+		mode = modeParallel
+	case modeParallel, modeSequential, modeInterleaved:
+	default:
+		return "", fmt.Errorf("unknown Test.Mode %q (want %s, %s or %s)", mode, modeParallel, modeSequential, modeInterleaved)
+	}
+	if interleaveSeconds < 0 {
+		return "", fmt.Errorf("Test.InterleaveSeconds %d is negative", interleaveSeconds)
+	}
+	// Matching compares live response counts, which only keep pace while both
+	// platforms are loaded
+	if matchAchievedRPS && mode != modeParallel {
+		return "", fmt.Errorf("Test.MatchAchievedRPS needs Test.Mode %q, not %q", modeParallel, mode)
+	}
+	return mode, nil
+}
+
+// runPlatforms loads the platforms for duration each, in the given mode
+func runPlatforms(mode string, window time.Duration, rps int, duration time.Duration, matcher *rpsMatcher, platforms ...*Platform) {
+	switch mode {
+	case modeSequential:
+		for _, p := range platforms {
+			if p.stopped() {
+				return
+			}
+			StressTest(p, rps, duration, nil)
+		}
+
+	case modeInterleaved:
+		for remaining := duration; remaining > 0; remaining -= window {
+			w := window
+			if remaining < w {
+				w = remaining
+			}
+			for _, p := range platforms {
+				if p.stopped() {
+					return
+				}
+				StressTest(p, rps, w, nil)
+			}
+		}
+
+	default:
+		var wg sync.WaitGroup
+		for _, p := range platforms {
+			wg.Add(1)
+			go func(p *Platform) {
+				defer wg.Done()
+				StressTest(p, rps, duration, matcher)
+			}(p)
+		}
+		wg.Wait()
+	}
+}
+
+// stopped reports whether the run was interrupted
+func (p *Platform) stopped() bool {
+	select {
+	case <-p.StopChan:
+		return true
+	default:
+		return false
+	}
+}
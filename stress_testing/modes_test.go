@@ -0,0 +1,125 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// targetLog records the path of every request in arrival order
+type targetLog struct {
+	mutex sync.Mutex
+	paths []string
+}
+
+func (l *targetLog) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	l.mutex.Lock()
+	l.paths = append(l.paths, strings.TrimPrefix(r.URL.Path, "/"))
+	l.mutex.Unlock()
+}
+
+// turns returns the platforms in the order they sent, one entry per run of
+// consecutive requests
+func (l *targetLog) turns() string {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	var turns []string
+	for _, path := range l.paths {
+		if len(turns) == 0 || turns[len(turns)-1] != path {
+			turns = append(turns, path)
+		}
+	}
+	return strings.Join(turns, " ")
+}
+
+// newTestPlatforms returns platforms "a" and "b" sending to the same target
+func newTestPlatforms(t *testing.T, handler http.Handler) (*Platform, *Platform, *httptest.Server) {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	a := NewPlatform(PlatformConfig{Name: "a", URL: server.URL + "/a"})
+	b := NewPlatform(PlatformConfig{Name: "b", URL: server.URL + "/b"})
+	return a, b, server
+}
+
+func TestCheckMode(t *testing.T) {
+	for _, c := range []struct {
+		mode              string
+		interleaveSeconds int
+		match             bool
+		want              string // "" for an error
+	}{
+		{"", 0, false, modeParallel},
+		{"", 0, true, modeParallel},
+		{modeSequential, 0, false, modeSequential},
+		{modeInterleaved, 30, false, modeInterleaved},
+		{"random", 0, false, ""},
+		{modeInterleaved, -1, false, ""},
+		{modeSequential, 0, true, ""},
+		{modeInterleaved, 10, true, ""},
+	} {
+		got, err := checkMode(c.mode, c.interleaveSeconds, c.match)
+		if got != c.want || (err == nil) != (c.want != "") {
+			t.Errorf("checkMode(%q, %d, %v) = %q, %v; want %q", c.mode, c.interleaveSeconds, c.match, got, err, c.want)
+		}
+	}
+}
+
+func TestRunPlatformsScheduling(t *testing.T) {
+	for _, c := range []struct {
+		mode     string
+		duration time.Duration
+		want     string
+	}{
+		{modeSequential, 500 * time.Millisecond, "a b"},
+		// Windows of 300ms, 300ms and the remaining 100ms
+		{modeInterleaved, 700 * time.Millisecond, "a b a b a b"},
+		{modeInterleaved, 300 * time.Millisecond, "a b"},
+	} {
+		log := &targetLog{}
+		a, b, _ := newTestPlatforms(t, log)
+		runPlatforms(c.mode, 300*time.Millisecond, 50, c.duration, nil, a, b)
+
+		if got := log.turns(); got != c.want {
+			t.Errorf("%s for %s: turns %s, want %s", c.mode, c.duration, got, c.want)
+		}
+		for _, p := range []*Platform{a, b} {
+			if p.elapsed < c.duration || p.elapsed > c.duration+300*time.Millisecond {
+				t.Errorf("%s for %s: %s ran for %s", c.mode, c.duration, p.Config.Name, p.elapsed)
+			}
+		}
+	}
+}
+
+// An interrupted run gives no further turns
+func TestRunPlatformsStopped(t *testing.T) {
+	log := &targetLog{}
+	a, b, _ := newTestPlatforms(t, log)
+	close(a.StopChan)
+	runPlatforms(modeInterleaved, 100*time.Millisecond, 50, time.Second, nil, a, b)
+	if got := log.turns(); got != "" {
+		t.Errorf("turns %q after the interrupt", got)
+	}
+}
+
+// Each turn's status reporter ends with the turn
+func TestStressTestGoroutines(t *testing.T) {
+	a, b, server := newTestPlatforms(t, http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	before := runtime.NumGoroutine()
+	runPlatforms(modeInterleaved, 50*time.Millisecond, 100, 500*time.Millisecond, nil, a, b)
+
+	a.client.CloseIdleConnections()
+	b.client.CloseIdleConnections()
+	server.CloseClientConnections()
+	deadline := time.Now().Add(2 * time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("%d goroutines after 20 turns, %d before", after, before)
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// errorBucketWidth is the length of the buckets of the error rate series
+const errorBucketWidth = 10 * time.Second
+
+// errorBucket counts the requests sent during one bucket of the run
+type errorBucket struct {
+	requests int64
+	errors   int64
+}
+
+// addToSeries counts a request in the bucket it was sent in; m.mutex must be
+// held
+func (m *Metrics) addToSeries(sentAt time.Time, success bool) {
+	if m.Start.IsZero() {
+		return
+	}
+	i := int(sentAt.Sub(m.Start) / errorBucketWidth)
+	if i < 0 {
+		i = 0
+	}
+	for len(m.buckets) <= i {
+		m.buckets = append(m.buckets, errorBucket{})
+	}
+	m.buckets[i].requests++
+	if !success {
+		m.buckets[i].errors++
+	}
+}
+
+// errorSeries prints and returns the error rate of each bucket requests were
+// sent in, so a platform degrading over the run shows when it started failing.
+// Buckets start at offsetSec seconds into the run; those without requests,
+// e.g. the other platform's turns, are left out.
+func (m *Metrics) errorSeries() []map[string]interface{} {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	series := make([]map[string]interface{}, 0, len(m.buckets))
+	var parts []string
+	for i, b := range m.buckets {
+		if b.requests == 0 {
+			continue
+		}
+		offset := time.Duration(i) * errorBucketWidth
+		rate := float64(b.errors) / float64(b.requests) * 100
+		parts = append(parts, fmt.Sprintf("%s %.1f%%", offset, rate))
+		series = append(series, map[string]interface{}{
+			"offsetSec": offset.Seconds(),
+			"requests":  b.requests,
+			"errors":    b.errors,
+			"errorRate": rate,
+		})
+	}
+	if len(parts) > 1 {
+		fmt.Printf("  Error Rate per %s: %s\n", errorBucketWidth, strings.Join(parts, ", "))
+	}
+	return series
+}
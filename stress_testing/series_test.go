@@ -0,0 +1,54 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestErrorSeries(t *testing.T) {
+	start := time.Now()
+	at := func(d time.Duration) time.Time { return start.Add(d) }
+	m := &Metrics{Start: start}
+
+	for _, c := range []struct {
+		sentAt time.Time
+		status int // 0 for a transport error
+	}{
+		{at(-time.Millisecond), 200}, // sent while the run started counts in the first bucket
+		{at(0), 200},
+		{at(10*time.Second - time.Nanosecond), 500},
+		{at(10 * time.Second), 200}, // a bucket starts at its offset
+		{at(10 * time.Second), 0},
+		{at(19 * time.Second), 200},
+		// Nothing in 20s-30s, e.g. the other platform's turn
+		{at(34 * time.Second), 404}, // the last, partial bucket of a 35s run
+	} {
+		if c.status == 0 {
+			m.AddError(c.sentAt, errorClassTimeout)
+		} else {
+			m.AddResponse(c.sentAt, c.status)
+		}
+	}
+
+	one, three := 1.0, 3.0
+	third := one / three * 100 // as computed at run time
+	want := []map[string]interface{}{
+		{"offsetSec": 0.0, "requests": int64(3), "errors": int64(1), "errorRate": third},
+		{"offsetSec": 10.0, "requests": int64(3), "errors": int64(1), "errorRate": third},
+		{"offsetSec": 30.0, "requests": int64(1), "errors": int64(1), "errorRate": 100.0},
+	}
+	if got := m.errorSeries(); !reflect.DeepEqual(got, want) {
+		t.Errorf("series %v, want %v", got, want)
+	}
+	if m.TotalRequests != 7 || m.FailedRequests != 3 {
+		t.Errorf("%d requests, %d failed; want 7 and 3", m.TotalRequests, m.FailedRequests)
+	}
+
+	// Without a start time there is no series, but the requests still count
+	unstarted := &Metrics{}
+	unstarted.AddResponse(start, 500)
+	if got := unstarted.errorSeries(); len(got) != 0 || unstarted.FailedRequests != 1 {
+		t.Errorf("series %v and %d failures without a start time", got, unstarted.FailedRequests)
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path"
+	"strings"
+)
+
+// defaultTargetPolicyFile is the target policy read when -target-policy is
+// not given; without the file every target is allowed
+const defaultTargetPolicyFile = "target_policy.json"
+
+// TargetPolicy keeps high-RPS plans off production hosts. Patterns match
+// hostnames, with "*" for any part: "shop.example.com", "*.example.com".
+type TargetPolicy struct {
+	// Hostname patterns of production targets
+	Production []string
+
+	// Hostname patterns that are never production; when set, every host
+	// they don't match is treated as production
+	Allowed []string
+
+	// Highest planned RPS allowed against production, e.g. for smoke tests
+	// (0 = none)
+	MaxRPS float64
+}
+
+// loadTargetPolicy reads the policy file; it returns nil when file is empty
+// or doesn't exist
+func loadTargetPolicy(file string) (*TargetPolicy, error) {
+	if file == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var policy TargetPolicy
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields() // a misspelled list would protect nothing
+	if err := decoder.Decode(&policy); err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+	for _, pattern := range append(append([]string(nil), policy.Production...), policy.Allowed...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("%s: pattern %q: %v", file, pattern, err)
+		}
+	}
+	if policy.MaxRPS < 0 {
+		return nil, fmt.Errorf("%s: MaxRPS %v is negative", file, policy.MaxRPS)
+	}
+	return &policy, nil
+}
+
+// production returns the pattern that makes host a production host, or ""
+// if it isn't one
+func (p *TargetPolicy) production(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(host)
+	if hostMatch(p.Allowed, host) != "" {
+		return ""
+	}
+	if pattern := hostMatch(p.Production, host); pattern != "" {
+		return pattern
+	}
+	if len(p.Allowed) > 0 {
+		return "not in Allowed"
+	}
+	return ""
+}
+
+// hostMatch returns the first pattern matching host, or ""
+func hostMatch(patterns []string, host string) string {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(strings.ToLower(pattern), host); ok {
+			return pattern
+		}
+	}
+	return ""
+}
+
+// checkTargetPolicy refuses a plan peaking above the policy's MaxRPS when
+// one of the targets is a production host, unless overridden with
+// -i-know-this-is-production. It returns what the results record: the
+// production hosts and whether the policy was overridden (nil if none).
+func checkTargetPolicy(policy *TargetPolicy, targets []string, peak float64, override bool) (map[string]interface{}, error) {
+	if policy == nil {
+		return nil, nil
+	}
+	var hosts, reasons []string
+	for _, host := range targets {
+		if pattern := policy.production(host); pattern != "" {
+			hosts = append(hosts, host)
+			reasons = append(reasons, fmt.Sprintf("%s (%s)", host, pattern))
+		}
+	}
+	if len(hosts) == 0 {
+		return nil, nil
+	}
+
+	report := map[string]interface{}{
+		"productionHosts": hosts,
+		"plannedPeakRPS":  peak,
+		"maxRPS":          policy.MaxRPS,
+	}
+	if peak <= policy.MaxRPS {
+		return report, nil
+	}
+	if !override {
+		return nil, fmt.Errorf("the plan peaks at %g RPS against production hosts %s, above the %g RPS the target policy allows; pass -i-know-this-is-production to run anyway",
+			peak, strings.Join(reasons, ", "), policy.MaxRPS)
+	}
+	fmt.Printf("Warning: running %g RPS against production hosts %s (-i-know-this-is-production)\n", peak, strings.Join(reasons, ", "))
+	report["overridden"] = true
+	return report, nil
+}
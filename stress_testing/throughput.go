@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+// defaultMaxRPSDivergence is the Test.MaxRPSDivergence used when unset
+const defaultMaxRPSDivergence = 10.0
+
+// rpsMatcher holds back a platform whose responses run ahead of the other's,
+// so both end up at the throughput the slower one achieves. Comparing error
+// rates at the same target RPS says little when one platform only completes
+// half of it.
+type rpsMatcher struct {
+	platforms []*Platform
+
+	// How many responses a platform may complete ahead of the slowest one
+	lead int64
+}
+
+// newRPSMatcher matches the platforms' throughput, letting each run up to a
+// second of the target rate ahead
+func newRPSMatcher(rps int, platforms ...*Platform) *rpsMatcher {
+	return &rpsMatcher{platforms: platforms, lead: int64(rps)}
+}
+
+// allow reports whether p may send its next request. A nil matcher always
+// allows.
+func (m *rpsMatcher) allow(p *Platform) bool {
+	if m == nil {
+		return true
+	}
+	completed := atomic.LoadInt64(&p.Metrics.TotalRequests)
+	for _, other := range m.platforms {
+		if other != p && completed-atomic.LoadInt64(&other.Metrics.TotalRequests) >= m.lead {
+			return false
+		}
+	}
+	return true
+}
+
+// AchievedRPS returns the responses processed per second of the platform's
+// run, including the wait for the last responses
+func (p *Platform) AchievedRPS() float64 {
+	if p.elapsed <= 0 {
+		return 0
+	}
+	return float64(atomic.LoadInt64(&p.Metrics.TotalRequests)) / p.elapsed.Seconds()
+}
+
+// rpsDivergence returns how far achieved is off target, in percent
+func rpsDivergence(achieved float64, target int) float64 {
+	if target <= 0 {
+		return 0
+	}
+	return math.Abs(achieved-float64(target)) / float64(target) * 100
+}
+
+// throughputReport prints and returns the platform's achieved throughput
+// against the target, flagging a divergence above maxDivergence percent
+func throughputReport(p *Platform, target int, maxDivergence float64, matched bool) map[string]interface{} {
+	achieved := p.AchievedRPS()
+	divergence := rpsDivergence(achieved, target)
+	diverged := divergence > maxDivergence
+
+	fmt.Printf("  Achieved RPS: %.1f (%.1f%% off the target)\n", achieved, divergence)
+	if diverged {
+		fmt.Printf("  Warning: achieved RPS diverged from the target by more than %g%%\n", maxDivergence)
+	}
+
+	report := map[string]interface{}{
+		"sentRequests":  atomic.LoadInt64(&p.sent),
+		"elapsed":       p.elapsed.Round(time.Millisecond).String(),
+		"achievedRPS":   achieved,
+		"rpsDivergence": divergence,
+		"rpsDiverged":   diverged,
+	}
+	if matched {
+		report["throttledRequests"] = atomic.LoadInt64(&p.throttled)
+	}
+	if p.workers != nil {
+		busy := atomic.LoadInt64(&p.busy)
+		if busy > 0 {
+			fmt.Printf("  Skipped %d sends with all %d workers busy\n", busy, p.settings.Workers)
+		}
+		report["workersBusy"] = busy
+	}
+	return report
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// completedPlatform returns a platform that has processed n responses
+func completedPlatform(name string, n int64) *Platform {
+	return &Platform{Config: PlatformConfig{Name: name}, Metrics: &Metrics{TotalRequests: n}}
+}
+
+func TestRPSMatcher(t *testing.T) {
+	for _, c := range []struct {
+		name        string
+		a, b        int64 // responses completed
+		allowA      bool
+		allowB      bool
+		withMatcher bool
+	}{
+		{"even", 100, 100, true, true, true},
+		{"a ahead by less than the lead", 149, 100, true, true, true},
+		{"a ahead by the lead", 150, 100, false, true, true},
+		{"b far ahead", 0, 500, true, false, true},
+		{"no matcher", 1000, 0, true, true, false},
+	} {
+		a, b := completedPlatform("a", c.a), completedPlatform("b", c.b)
+		var m *rpsMatcher
+		if c.withMatcher {
+			m = newRPSMatcher(50, a, b)
+		}
+		if got := m.allow(a); got != c.allowA {
+			t.Errorf("%s: a allowed %v, want %v", c.name, got, c.allowA)
+		}
+		if got := m.allow(b); got != c.allowB {
+			t.Errorf("%s: b allowed %v, want %v", c.name, got, c.allowB)
+		}
+	}
+}
+
+func TestRPSDivergence(t *testing.T) {
+	for _, c := range []struct {
+		achieved float64
+		target   int
+		want     float64
+	}{
+		{100, 100, 0},
+		{90, 100, 10},
+		{110, 100, 10},
+		{0, 100, 100},
+		{50, 0, 0},
+	} {
+		if got := rpsDivergence(c.achieved, c.target); got != c.want {
+			t.Errorf("rpsDivergence(%v, %d) = %v, want %v", c.achieved, c.target, got, c.want)
+		}
+	}
+}
+
+func TestThroughputReport(t *testing.T) {
+	p := completedPlatform("a", 450)
+	p.sent, p.throttled, p.elapsed = 500, 7, 5*time.Second
+	if got := p.AchievedRPS(); got != 90 {
+		t.Errorf("achieved %v RPS, want 90", got)
+	}
+
+	report := throughputReport(p, 100, 5, true)
+	if report["sentRequests"] != int64(500) || report["achievedRPS"] != 90.0 || report["rpsDiverged"] != true || report["throttledRequests"] != int64(7) {
+		t.Errorf("report %v", report)
+	}
+	if _, ok := report["workersBusy"]; ok {
+		t.Errorf("workersBusy reported without a Workers limit: %v", report)
+	}
+
+	// Within the allowed divergence, unmatched, with a worker limit
+	p.workers, p.busy = make(chan struct{}, 1), 3
+	report = throughputReport(p, 100, 10, false)
+	if report["rpsDiverged"] != false || report["workersBusy"] != int64(3) {
+		t.Errorf("report %v", report)
+	}
+	if _, ok := report["throttledRequests"]; ok {
+		t.Errorf("throttledRequests reported without matching: %v", report)
+	}
+
+	if got := completedPlatform("b", 10).AchievedRPS(); got != 0 {
+		t.Errorf("achieved %v RPS before the run, want 0", got)
+	}
+}
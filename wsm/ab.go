@@ -0,0 +1,336 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// A/B run orders
+const (
+	abInterleaved = "interleaved"
+	abSequential  = "sequential"
+)
+
+// abMetrics are compared for the whole test; abOperationMetrics per operation
+var (
+	abMetrics          = []string{"actualRPS", "errorRate", "p50", "p95", "p99"}
+	abOperationMetrics = []string{"errorRate", "p50", "p95", "p99"}
+)
+
+// ABVariant is one side of an A/B comparison
+type ABVariant struct {
+	Label  string   `json:"label"`
+	Config string   `json:"config"`
+	Runs   []string `json:"runs"` // results file of each successful run
+}
+
+// ABMetric compares one metric between the variants
+type ABMetric struct {
+	Operation     string    `json:"operation,omitempty"`
+	Metric        string    `json:"metric"`
+	A             []float64 `json:"a"` // value of each run
+	B             []float64 `json:"b"`
+	MeanA         float64   `json:"meanA"`
+	MeanB         float64   `json:"meanB"`
+	ChangePercent *float64  `json:"changePercent,omitempty"` // B against A
+	// Separated is set when every run of one variant is above every run of
+	// the other, so the difference is larger than the run-to-run spread
+	Separated bool `json:"separated"`
+}
+
+// ABReport is the outcome of wsm ab
+type ABReport struct {
+	Platform  string     `json:"platform"`
+	Order     string     `json:"order"`
+	Rounds    int        `json:"rounds"`
+	StartTime string     `json:"startTime"`
+	EndTime   string     `json:"endTime"`
+	A         ABVariant  `json:"a"`
+	B         ABVariant  `json:"b"`
+	Metrics   []ABMetric `json:"metrics"`
+	Errors    []string   `json:"errors,omitempty"`
+}
+
+// abSchedule returns the order of the runs: A then B in every round when
+// interleaved, alternating which goes first (ABBA) so neither variant always
+// runs on a target warmed up by the other; all A runs first when sequential
+func abSchedule(order string, rounds int) []string {
+	var schedule []string
+	for i := 0; i < rounds; i++ {
+		if order == abSequential {
+			schedule = append(schedule, "a")
+		} else if i%2 == 0 {
+			schedule = append(schedule, "a", "b")
+		} else {
+			schedule = append(schedule, "b", "a")
+		}
+	}
+	if order == abSequential {
+		for i := 0; i < rounds; i++ {
+			schedule = append(schedule, "b")
+		}
+	}
+	return schedule
+}
+
+// readABResults reads the compared metrics of one results file, keyed by
+// operation and metric ("" for the whole test)
+func readABResults(path string) (map[[2]string]float64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("parsing %s: %v", path, err)
+	}
+
+	values := make(map[[2]string]float64)
+	for _, metric := range abMetrics {
+		if v, ok := measure(raw, SLAClause{Metric: metric}); ok {
+			values[[2]string{"", metric}] = v
+		}
+	}
+	operations, _ := raw["operations"].(map[string]interface{})
+	for operation := range operations {
+		for _, metric := range abOperationMetrics {
+			if v, ok := measure(raw, SLAClause{Metric: metric, Operation: operation}); ok {
+				values[[2]string{operation, metric}] = v
+			}
+		}
+	}
+	return values, nil
+}
+
+// mean returns the average of values
+func mean(values []float64) float64 {
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+// spread returns the smallest and the largest of values
+func spread(values []float64) (float64, float64) {
+	lowest, highest := values[0], values[0]
+	for _, v := range values[1:] {
+		lowest, highest = math.Min(lowest, v), math.Max(highest, v)
+	}
+	return lowest, highest
+}
+
+// compareAB lines up the metrics both variants reported
+func compareAB(a, b []map[[2]string]float64) []ABMetric {
+	keys := make(map[[2]string]bool)
+	for _, run := range a {
+		for key := range run {
+			keys[key] = true
+		}
+	}
+
+	var metrics []ABMetric
+	for key := range keys {
+		m := ABMetric{Operation: key[0], Metric: key[1]}
+		for _, run := range a {
+			if v, ok := run[key]; ok {
+				m.A = append(m.A, v)
+			}
+		}
+		for _, run := range b {
+			if v, ok := run[key]; ok {
+				m.B = append(m.B, v)
+			}
+		}
+		if len(m.A) == 0 || len(m.B) == 0 {
+			continue
+		}
+		m.MeanA, m.MeanB = mean(m.A), mean(m.B)
+		if m.MeanA != 0 {
+			change := math.Round((m.MeanB-m.MeanA)/m.MeanA*1000) / 10
+			m.ChangePercent = &change
+		}
+		if len(m.A) > 1 && len(m.B) > 1 {
+			minA, maxA := spread(m.A)
+			minB, maxB := spread(m.B)
+			m.Separated = maxA < minB || maxB < minA
+		}
+		metrics = append(metrics, m)
+	}
+
+	// The whole test first, then the operations by name
+	sort.Slice(metrics, func(i, j int) bool {
+		if metrics[i].Operation != metrics[j].Operation {
+			return metrics[i].Operation < metrics[j].Operation
+		}
+		return metricRank(metrics[i].Metric) < metricRank(metrics[j].Metric)
+	})
+	return metrics
+}
+
+// metricRank orders metrics as abMetrics lists them
+func metricRank(metric string) int {
+	for i, m := range abMetrics {
+		if m == metric {
+			return i
+		}
+	}
+	return len(abMetrics)
+}
+
+// printABReport prints the comparison table
+func printABReport(report *ABReport) {
+	fmt.Printf("\nA/B comparison for %s: %s (A, runs: %d) vs %s (B, runs: %d)\n",
+		report.Platform, report.A.Label, len(report.A.Runs), report.B.Label, len(report.B.Runs))
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Operation\tMetric\tA\tB\tChange\t")
+	for _, m := range report.Metrics {
+		operation := m.Operation
+		if operation == "" {
+			operation = "(all)"
+		}
+		change := "-"
+		if m.ChangePercent != nil {
+			change = fmt.Sprintf("%+.1f%%", *m.ChangePercent)
+		}
+		note := ""
+		if m.Separated {
+			note = "beyond run-to-run spread"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", operation, m.Metric,
+			formatSLAValue(m.Metric, m.MeanA), formatSLAValue(m.Metric, m.MeanB), change, note)
+	}
+	w.Flush()
+}
+
+// runAB implements "wsm ab": run two configs of the same platform and
+// compare their results
+func runAB(args []string) {
+	fs := flag.NewFlagSet("ab", flag.ExitOnError)
+	platform := fs.String("platform", "", "Platform both configs test (spree, medusa or saleor)")
+	binary := fs.String("binary", "", "Runner binary (default ./<platform>_benchmark)")
+	configA := fs.String("config-a", "", "Config of variant A")
+	configB := fs.String("config-b", "", "Config of variant B")
+	labelA := fs.String("label-a", "", "Name of variant A in the report (default: its config file name)")
+	labelB := fs.String("label-b", "", "Name of variant B in the report (default: its config file name)")
+	rounds := fs.Int("runs", 1, "Runs of each variant")
+	order := fs.String("order", abInterleaved, "interleaved (A B, B A, ...) or sequential (all A runs, then all B runs)")
+	cooldown := fs.Duration("cooldown", 0, "Pause between runs")
+	timeout := fs.String("timeout", "", "Kill a run after this long, e.g. \"40m\"")
+	outDir := fs.String("out-dir", "", "Results directory (default ab_results_<timestamp>)")
+	var runnerArgs stringList
+	fs.Var(&runnerArgs, "arg", "Extra argument for the runner (repeatable)")
+	fs.Parse(args)
+
+	if *platform == "" || *configA == "" || *configB == "" {
+		log.Fatal("ab: -platform, -config-a and -config-b are required")
+	}
+	*platform = strings.ToLower(*platform)
+	if *binary == "" {
+		*binary = "./" + *platform + "_benchmark"
+	}
+	if *rounds <= 0 {
+		log.Fatal("ab: -runs must be positive")
+	}
+	if *order != abInterleaved && *order != abSequential {
+		log.Fatalf("ab: unknown order %q (use %s or %s)", *order, abInterleaved, abSequential)
+	}
+	if *timeout != "" {
+		if _, err := time.ParseDuration(*timeout); err != nil {
+			log.Fatalf("ab: invalid timeout %q", *timeout)
+		}
+	}
+	for _, path := range []string{*configA, *configB} {
+		if _, err := os.Stat(path); err != nil {
+			log.Fatalf("ab: %v", err)
+		}
+	}
+	if *labelA == "" {
+		*labelA = strings.TrimSuffix(filepath.Base(*configA), filepath.Ext(*configA))
+	}
+	if *labelB == "" {
+		*labelB = strings.TrimSuffix(filepath.Base(*configB), filepath.Ext(*configB))
+	}
+	if *labelA == *labelB {
+		*labelA, *labelB = *labelA+" (A)", *labelB+" (B)"
+	}
+
+	resultsDir := *outDir
+	if resultsDir == "" {
+		resultsDir = "ab_results_" + time.Now().Format("20060102_150405")
+	}
+
+	report := &ABReport{
+		Platform:  *platform,
+		Order:     *order,
+		Rounds:    *rounds,
+		StartTime: time.Now().Format(time.RFC3339),
+		A:         ABVariant{Label: *labelA, Config: *configA},
+		B:         ABVariant{Label: *labelB, Config: *configB},
+	}
+	values := map[string][]map[[2]string]float64{}
+	count := map[string]int{}
+	schedule := abSchedule(*order, *rounds)
+	for i, variant := range schedule {
+		if i > 0 && *cooldown > 0 {
+			fmt.Printf("Cooling down for %s\n", *cooldown)
+			time.Sleep(*cooldown)
+		}
+		count[variant]++
+		runDir := filepath.Join(resultsDir, fmt.Sprintf("%s%d", variant, count[variant]))
+		if err := os.MkdirAll(runDir, 0755); err != nil {
+			log.Fatalf("ab: %v", err)
+		}
+		side := &report.A
+		if variant == "b" {
+			side = &report.B
+		}
+		fmt.Printf("Run %d of %d: %s, variant %s\n", i+1, len(schedule), side.Label, strings.ToUpper(variant))
+
+		test := SuiteTest{Platform: *platform, Binary: *binary, Config: side.Config, Args: runnerArgs, Timeout: *timeout}
+		if err := runTest(test, runDir); err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("%s%d: %v", variant, count[variant], err))
+			continue
+		}
+		results, _ := collectResults(runDir)
+		path, ok := results[*platform]
+		if !ok {
+			report.Errors = append(report.Errors, fmt.Sprintf("%s%d: no %s results in %s", variant, count[variant], *platform, runDir))
+			continue
+		}
+		run, err := readABResults(path)
+		if err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("%s%d: %v", variant, count[variant], err))
+			continue
+		}
+		side.Runs = append(side.Runs, path)
+		values[variant] = append(values[variant], run)
+	}
+	report.EndTime = time.Now().Format(time.RFC3339)
+	report.Metrics = compareAB(values["a"], values["b"])
+
+	data, _ := json.MarshalIndent(report, "", "  ")
+	reportPath := filepath.Join(resultsDir, "ab.json")
+	if err := os.WriteFile(reportPath, data, 0644); err != nil {
+		log.Fatalf("ab: %v", err)
+	}
+	if len(report.A.Runs) > 0 && len(report.B.Runs) > 0 {
+		printABReport(report)
+	}
+	if *rounds == 1 {
+		fmt.Println("With one run per variant the change includes run-to-run noise; use -runs 3 or more to see which differences stand out")
+	}
+	fmt.Printf("Comparison saved to %s\n", reportPath)
+	if len(report.Errors) > 0 {
+		log.Fatalf("ab: %d of %d runs failed: %s", len(report.Errors), len(schedule), strings.Join(report.Errors, "; "))
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeBlackoutCalendar(t *testing.T, data string) (*BlackoutCalendar, error) {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "blackout.json")
+	if err := os.WriteFile(file, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return loadBlackoutCalendar(file)
+}
+
+func TestBlackoutCalendar(t *testing.T) {
+	calendar, err := writeBlackoutCalendar(t, `{"Windows": [
+		{"Name": "nightly", "Cron": "0 3 * * *", "Duration": "30m"},
+		{"Name": "reindex", "Cron": "30 3 * * *", "Duration": "15m"},
+		{"Cron": "0 12 * * 0", "Duration": "1h"}
+	]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	day := func(hour, minute int) time.Time { return time.Date(2024, 5, 1, hour, minute, 0, 0, time.Local) } // a Wednesday
+
+	for _, c := range []struct {
+		at   time.Time
+		name string
+		end  time.Time
+	}{
+		{day(2, 59), "", time.Time{}},
+		{day(3, 0), "nightly", day(3, 45)}, // the reindex follows right after
+		{day(3, 40), "reindex", day(3, 45)},
+		{day(3, 45), "", time.Time{}},
+	} {
+		name, end := calendar.openAt(c.at)
+		if name != c.name || !end.Equal(c.end) {
+			t.Errorf("at %s: open %q until %s, want %q until %s", c.at.Format("15:04"), name, end, c.name, c.end)
+		}
+	}
+
+	gaps := calendar.gaps(day(3, 10), day(12, 0).AddDate(0, 0, 4)) // to Sunday noon
+	var names []string
+	for _, gap := range gaps {
+		names = append(names, gap.Window)
+	}
+	if got := strings.Join(names, ","); got != "nightly,reindex,nightly,reindex,nightly,reindex,nightly,reindex,nightly,reindex" {
+		t.Errorf("gaps %s", got)
+	}
+	if gaps[0].String() != "nightly 03:00-03:30" {
+		t.Errorf("first gap %q", gaps[0])
+	}
+	if gaps := calendar.gaps(day(12, 30).AddDate(0, 0, 4), day(13, 0).AddDate(0, 0, 4)); len(gaps) != 1 || gaps[0].Window != "0 12 * * 0" {
+		t.Errorf("Sunday gaps %v, want the unnamed window", gaps)
+	}
+
+	// Windows that are always open end at maxBlackout
+	always, err := writeBlackoutCalendar(t, `{"Windows": [{"Cron": "* * * * *", "Duration": "1m"}]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, end := always.openAt(day(0, 0)); end.Sub(day(0, 0)) < maxBlackout {
+		t.Errorf("always open until %s", end)
+	}
+
+	for _, c := range []struct{ data, err string }{
+		{`{"Windows": [{"Cron": "0 3 * *", "Duration": "30m"}]}`, "5 fields"},
+		{`{"Windows": [{"Cron": "0 3 * * *", "Duration": "30"}]}`, "positive duration"},
+		{`{"Windows": [{"Cron": "0 3 * * *", "Lenght": "30m"}]}`, "unknown field"},
+	} {
+		if _, err := writeBlackoutCalendar(t, c.data); err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("%s: error %v, want %q", c.data, err, c.err)
+		}
+	}
+	if calendar, err := loadBlackoutCalendar(filepath.Join(t.TempDir(), "missing.json")); calendar != nil || err != nil {
+		t.Errorf("missing file: %v, %v", calendar, err)
+	}
+}
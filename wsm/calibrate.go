@@ -0,0 +1,215 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"runtime"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// CalibrationStamp is what wsm calibrate measured on this machine. The
+// runners embed it in their results, so a throughput ceiling can be told
+// apart from the generator's own limit.
+type CalibrationStamp struct {
+	CalibratedAt string `json:"calibratedAt"`
+	Host         string `json:"host"`
+	OS           string `json:"os"`
+	Arch         string `json:"arch"`
+	CPUs         int    `json:"cpus"`
+	GOMAXPROCS   int    `json:"gomaxprocs"`
+
+	Target   string  `json:"target"` // "mock" for the built-in mock target
+	Workers  int     `json:"workers"`
+	Duration string  `json:"duration"`
+	Requests int64   `json:"requests"`
+	Errors   int64   `json:"errors"`
+	MaxRPS   float64 `json:"maxRPS"`
+
+	// Lateness of a 1ms ticker, the runners' scheduling tick
+	TickerJitter map[string]string `json:"tickerJitter"`
+	// Median time a 1µs sleep takes, the finest pacing the runtime can do
+	TimerResolution string `json:"timerResolution"`
+	// Smallest step of the monotonic clock seen between readings
+	ClockResolution string `json:"clockResolution"`
+}
+
+// durationQuantile returns the duration at rank q of sorted durations
+func durationQuantile(sorted []time.Duration, q float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	return sorted[min(int(float64(len(sorted))*q), len(sorted)-1)]
+}
+
+// measureMaxRPS sends requests from workers closed-loop connections as fast
+// as the target answers them for the duration
+func measureMaxRPS(url string, workers int, duration time.Duration) (int64, int64, time.Duration) {
+	transport := &http.Transport{MaxIdleConns: workers, MaxIdleConnsPerHost: workers, MaxConnsPerHost: workers}
+	client := &http.Client{Transport: transport, Timeout: 10 * time.Second}
+	defer transport.CloseIdleConnections()
+
+	var requests, failures atomic.Int64
+	var wg sync.WaitGroup
+	start := time.Now()
+	deadline := start.Add(duration)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for time.Now().Before(deadline) {
+				resp, err := client.Get(url)
+				requests.Add(1)
+				if err != nil {
+					failures.Add(1)
+					continue
+				}
+				io.Copy(io.Discard, resp.Body)
+				resp.Body.Close()
+				if resp.StatusCode >= 400 {
+					failures.Add(1)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	return requests.Load(), failures.Load(), time.Since(start)
+}
+
+// measureTickerJitter returns how far the gaps between the ticks of a 1ms
+// ticker stray from 1ms
+func measureTickerJitter(duration time.Duration) map[string]string {
+	const interval = time.Millisecond
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var deviations []time.Duration
+	last := time.Now()
+	for end := last.Add(duration); last.Before(end); {
+		now := <-ticker.C
+		gap := now.Sub(last) - interval
+		if gap < 0 {
+			gap = -gap
+		}
+		deviations = append(deviations, gap)
+		last = now
+	}
+	sort.Slice(deviations, func(i, j int) bool { return deviations[i] < deviations[j] })
+	return map[string]string{
+		"interval": interval.String(),
+		"p50":      durationQuantile(deviations, 0.5).String(),
+		"p99":      durationQuantile(deviations, 0.99).String(),
+		"max":      deviations[len(deviations)-1].String(),
+	}
+}
+
+// measureTimerResolution returns the median duration of a 1µs sleep
+func measureTimerResolution() time.Duration {
+	samples := make([]time.Duration, 200)
+	for i := range samples {
+		start := time.Now()
+		time.Sleep(time.Microsecond)
+		samples[i] = time.Since(start)
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+	return durationQuantile(samples, 0.5)
+}
+
+// measureClockResolution returns the smallest non-zero step between two
+// readings of the monotonic clock
+func measureClockResolution() time.Duration {
+	smallest := time.Duration(0)
+	for i := 0; i < 1000; i++ {
+		start := time.Now()
+		step := time.Since(start)
+		for step == 0 {
+			step = time.Since(start)
+		}
+		if smallest == 0 || step < smallest {
+			smallest = step
+		}
+	}
+	return smallest
+}
+
+// runCalibrate measures the load the generator can produce on this machine
+// and writes the stamp the runners embed in their results
+func runCalibrate(args []string) {
+	fs := flag.NewFlagSet("calibrate", flag.ExitOnError)
+	target := fs.String("target", "", "URL to load-test (default: a built-in mock target on localhost)")
+	workers := fs.Int("workers", 256, "Concurrent connections sending requests back to back")
+	duration := fs.Duration("duration", 10*time.Second, "How long to send requests")
+	out := fs.String("out", "calibration.json", "Calibration stamp file the runners read")
+	fs.Parse(args)
+
+	if *workers <= 0 || *duration <= 0 {
+		log.Fatal("calibrate: -workers and -duration must be positive")
+	}
+	url, targetName := *target, *target
+	if url == "" {
+		mock, err := newMockTarget(MockTargetConfig{Mode: "spree", Products: 25})
+		if err != nil {
+			log.Fatalf("calibrate: %v", err)
+		}
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			log.Fatalf("calibrate: %v", err)
+		}
+		server := &http.Server{Handler: mock}
+		go server.Serve(listener)
+		defer server.Close()
+		url = "http://" + listener.Addr().String() + "/api/v2/storefront/products/"
+		targetName = "mock"
+	}
+
+	fmt.Printf("Measuring timer and clock resolution...\n")
+	timer := measureTimerResolution()
+	clock := measureClockResolution()
+	fmt.Printf("Measuring scheduling jitter of a 1ms ticker for 2s...\n")
+	jitter := measureTickerJitter(2 * time.Second)
+	fmt.Printf("Sending requests to %s from %d connections for %s...\n", url, *workers, *duration)
+	requests, failures, elapsed := measureMaxRPS(url, *workers, *duration)
+
+	host, _ := os.Hostname()
+	stamp := CalibrationStamp{
+		CalibratedAt:    time.Now().UTC().Format(time.RFC3339),
+		Host:            host,
+		OS:              runtime.GOOS,
+		Arch:            runtime.GOARCH,
+		CPUs:            runtime.NumCPU(),
+		GOMAXPROCS:      runtime.GOMAXPROCS(0),
+		Target:          targetName,
+		Workers:         *workers,
+		Duration:        duration.String(),
+		Requests:        requests,
+		Errors:          failures,
+		MaxRPS:          float64(int64(float64(requests)/elapsed.Seconds()*10)) / 10,
+		TickerJitter:    jitter,
+		TimerResolution: timer.String(),
+		ClockResolution: clock.String(),
+	}
+	data, err := json.MarshalIndent(stamp, "", "  ")
+	if err != nil {
+		log.Fatalf("calibrate: %v", err)
+	}
+	if err := os.WriteFile(*out, data, 0644); err != nil {
+		log.Fatalf("calibrate: %v", err)
+	}
+
+	fmt.Printf("Maximum RPS:       %.1f (%d requests, %d errors)\n", stamp.MaxRPS, requests, failures)
+	fmt.Printf("Ticker jitter:     p50 %s, p99 %s, max %s\n", jitter["p50"], jitter["p99"], jitter["max"])
+	fmt.Printf("Timer resolution:  %s\n", stamp.TimerResolution)
+	fmt.Printf("Clock resolution:  %s\n", stamp.ClockResolution)
+	fmt.Printf("Calibration stamp written to %s\n", *out)
+	if failures > 0 {
+		fmt.Printf("Warning: %d requests failed; the maximum RPS may be the target's limit, not the generator's\n", failures)
+	}
+}
@@ -0,0 +1,501 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// collectorTokenEnv holds the shared secret the runners must present
+const collectorTokenEnv = "WSM_COLLECTOR_TOKEN"
+
+// collectorMessage is one line of a runner's stream (see the runners'
+// collector.go): "hello", "second" or "end"
+type collectorMessage struct {
+	Type     string
+	Run      string
+	RunID    string // the runner's X-Load-Test-ID
+	Platform string
+	Host     string
+	Started  *time.Time
+	Data     *collectorSecond
+	Target   float64
+	Status   map[string]interface{}
+}
+
+// collectorSecond is one completed second of a runner, latency in ms
+type collectorSecond struct {
+	Second   int     `json:"second"`
+	Requests int64   `json:"requests"`
+	Failed   int64   `json:"failed"`
+	P50      float64 `json:"p50"`
+	P95      float64 `json:"p95"`
+	P99      float64 `json:"p99"`
+	Target   float64 `json:"target"`
+}
+
+// collectedRun is one runner as the collector sees it
+type collectedRun struct {
+	ID       string
+	RunID    string
+	Platform string
+	Host     string
+	Started  time.Time
+	Streams  int // open connections
+	Finished bool
+	LastSeen time.Time
+	Status   map[string]interface{}
+	Requests int64
+	Failed   int64
+	seconds  map[int64]collectorSecond // by Unix time
+}
+
+// state is "streaming", "disconnected" or "finished"
+func (r *collectedRun) state() string {
+	switch {
+	case r.Finished:
+		return "finished"
+	case r.Streams > 0:
+		return "streaming"
+	}
+	return "disconnected"
+}
+
+// summary describes the run for /api/runs
+func (r *collectedRun) summary() map[string]interface{} {
+	summary := map[string]interface{}{
+		"id":       r.ID,
+		"runId":    r.RunID,
+		"platform": r.Platform,
+		"host":     r.Host,
+		"started":  r.Started.Format(time.RFC3339),
+		"state":    r.state(),
+		"lastSeen": r.LastSeen.Format(time.RFC3339),
+		"seconds":  len(r.seconds),
+		"requests": r.Requests,
+		"failed":   r.Failed,
+	}
+	if r.Requests > 0 {
+		summary["errorRate"] = fmt.Sprintf("%.2f%%", float64(r.Failed)/float64(r.Requests)*100)
+	}
+	if r.Status != nil {
+		summary["status"] = r.Status
+	}
+	return summary
+}
+
+// platformSecond is one platform's share of a combined second
+type platformSecond struct {
+	Requests int64   `json:"requests"`
+	Failed   int64   `json:"failed"`
+	Target   float64 `json:"target"`
+	P95Max   float64 `json:"p95Max"`
+}
+
+// combinedSecond merges the runners' results of one wall clock second.
+// Percentiles of different runners cannot be merged exactly, so the worst
+// p95 is given.
+type combinedSecond struct {
+	Time      int64                      `json:"time"` // Unix seconds
+	Runs      int                        `json:"runs"`
+	Requests  int64                      `json:"requests"`
+	Failed    int64                      `json:"failed"`
+	Target    float64                    `json:"target"`
+	P95Max    float64                    `json:"p95Max"`
+	Platforms map[string]*platformSecond `json:"platforms"`
+}
+
+// metricsCollector receives the streams of concurrent runners, typically
+// saleor, spree and medusa on different hosts, and serves them merged on
+// one wall clock timeline
+type metricsCollector struct {
+	token string
+
+	mutex sync.Mutex
+	runs  map[string]*collectedRun
+}
+
+func newMetricsCollector(token string) *metricsCollector {
+	return &metricsCollector{token: token, runs: make(map[string]*collectedRun)}
+}
+
+func (c *metricsCollector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/v1/stream":
+		c.ingest(w, r)
+	case "/api/runs":
+		writeMockJSON(w, http.StatusOK, c.runSummaries())
+	case "/api/combined":
+		from, _ := strconv.ParseInt(r.URL.Query().Get("from"), 10, 64)
+		writeMockJSON(w, http.StatusOK, c.combined(from))
+	case "/":
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, collectorPage)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// authorized checks the bearer token when the collector has one
+func (c *metricsCollector) authorized(r *http.Request) bool {
+	if c.token == "" {
+		return true
+	}
+	return subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+c.token)) == 1
+}
+
+// ingest reads one runner connection until the runner closes it
+func (c *metricsCollector) ingest(w http.ResponseWriter, r *http.Request) {
+	// close rejected streams instead of reading them to the end, so the
+	// runner sees the error right away
+	w.Header().Set("Connection", "close")
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST a stream of results", http.StatusMethodNotAllowed)
+		return
+	}
+	if !c.authorized(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	decoder := json.NewDecoder(r.Body)
+	var hello collectorMessage
+	if err := decoder.Decode(&hello); err != nil || hello.Type != "hello" || hello.Run == "" || hello.Started == nil {
+		http.Error(w, "the stream must start with a hello naming the run", http.StatusBadRequest)
+		return
+	}
+	w.Header().Del("Connection")
+	run := c.open(hello)
+	defer c.close(run)
+
+	received := 0
+	for {
+		var message collectorMessage
+		err := decoder.Decode(&message)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			log.Printf("collector: %s: %v", hello.Run, err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		switch message.Type {
+		case "second":
+			if message.Data != nil {
+				c.record(run, message)
+				received++
+			}
+		case "end":
+			c.mutex.Lock()
+			run.Finished = true
+			requests, failed := run.Requests, run.Failed
+			c.mutex.Unlock()
+			log.Printf("collector: %s finished: %d requests, %d failed", run.ID, requests, failed)
+		}
+	}
+	writeMockJSON(w, http.StatusOK, map[string]int{"received": received})
+}
+
+// open registers a connection of a run; a reconnecting runner continues
+// its run
+func (c *metricsCollector) open(hello collectorMessage) *collectedRun {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	run, ok := c.runs[hello.Run]
+	if !ok {
+		run = &collectedRun{
+			ID:       hello.Run,
+			RunID:    hello.RunID,
+			Platform: hello.Platform,
+			Host:     hello.Host,
+			Started:  *hello.Started,
+			seconds:  make(map[int64]collectorSecond),
+		}
+		c.runs[hello.Run] = run
+		log.Printf("collector: %s connected (%s on %s)", run.ID, run.Platform, run.Host)
+	} else {
+		log.Printf("collector: %s reconnected", run.ID)
+	}
+	run.Streams++
+	run.LastSeen = time.Now()
+	return run
+}
+
+func (c *metricsCollector) close(run *collectedRun) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	run.Streams--
+	if run.Streams == 0 && !run.Finished {
+		log.Printf("collector: %s disconnected", run.ID)
+	}
+}
+
+// record stores a second, replacing one sent before
+func (c *metricsCollector) record(run *collectedRun, message collectorMessage) {
+	second := *message.Data
+	second.Target = message.Target
+	at := run.Started.Unix() + int64(second.Second)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if previous, ok := run.seconds[at]; ok {
+		run.Requests -= previous.Requests
+		run.Failed -= previous.Failed
+	}
+	run.seconds[at] = second
+	run.Requests += second.Requests
+	run.Failed += second.Failed
+	run.LastSeen = time.Now()
+	if message.Status != nil {
+		run.Status = message.Status
+	}
+}
+
+// runSummaries lists the runs by start time
+func (c *metricsCollector) runSummaries() []map[string]interface{} {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	runs := make([]*collectedRun, 0, len(c.runs))
+	for _, run := range c.runs {
+		runs = append(runs, run)
+	}
+	sort.Slice(runs, func(i, j int) bool {
+		if !runs[i].Started.Equal(runs[j].Started) {
+			return runs[i].Started.Before(runs[j].Started)
+		}
+		return runs[i].ID < runs[j].ID
+	})
+	summaries := make([]map[string]interface{}, len(runs))
+	for i, run := range runs {
+		summaries[i] = run.summary()
+	}
+	return summaries
+}
+
+// combined merges the seconds of all runs from the Unix time from on
+func (c *metricsCollector) combined(from int64) []*combinedSecond {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	byTime := make(map[int64]*combinedSecond)
+	for _, run := range c.runs {
+		for at, second := range run.seconds {
+			if at < from {
+				continue
+			}
+			merged, ok := byTime[at]
+			if !ok {
+				merged = &combinedSecond{Time: at, Platforms: make(map[string]*platformSecond)}
+				byTime[at] = merged
+			}
+			merged.Runs++
+			merged.Requests += second.Requests
+			merged.Failed += second.Failed
+			merged.Target += second.Target
+			merged.P95Max = math.Max(merged.P95Max, second.P95)
+			platform, ok := merged.Platforms[run.Platform]
+			if !ok {
+				platform = &platformSecond{}
+				merged.Platforms[run.Platform] = platform
+			}
+			platform.Requests += second.Requests
+			platform.Failed += second.Failed
+			platform.Target += second.Target
+			platform.P95Max = math.Max(platform.P95Max, second.P95)
+		}
+	}
+	combined := make([]*combinedSecond, 0, len(byTime))
+	for _, second := range byTime {
+		combined = append(combined, second)
+	}
+	sort.Slice(combined, func(i, j int) bool { return combined[i].Time < combined[j].Time })
+	return combined
+}
+
+// write saves the runs and the combined timeline
+func (c *metricsCollector) write(path string) error {
+	data, err := json.MarshalIndent(map[string]interface{}{
+		"runs":     c.runSummaries(),
+		"combined": c.combined(0),
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+func runCollector(args []string) {
+	fs := flag.NewFlagSet("collector", flag.ExitOnError)
+	listen := fs.String("listen", ":9300", "Address to receive the runners' streams and serve the combined view on")
+	out := fs.String("out", "", "Write the runs and the combined timeline to this JSON file when stopped")
+	fs.Parse(args)
+
+	collector := newMetricsCollector(os.Getenv(collectorTokenEnv))
+	// no read timeout: a stream lasts as long as its run
+	server := &http.Server{Addr: *listen, Handler: collector, ReadHeaderTimeout: 10 * time.Second}
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	done := make(chan struct{})
+	go func() {
+		<-sigChan
+		fmt.Println("\nReceived interrupt signal, shutting down")
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+		close(done)
+	}()
+
+	auth := "no token"
+	if collector.token != "" {
+		auth = "token from " + collectorTokenEnv
+	}
+	_, port, _ := net.SplitHostPort(*listen)
+	fmt.Printf("Collector on %s (%s); start the runners with -collector http://<this host>:%s\n", *listen, auth, port)
+	if err := server.ListenAndServe(); err != http.ErrServerClosed {
+		log.Fatalf("collector: %v", err)
+	}
+	<-done
+
+	runs := collector.runSummaries()
+	fmt.Printf("Received %d runs\n", len(runs))
+	if *out != "" {
+		if err := collector.write(*out); err != nil {
+			log.Fatalf("collector: %v", err)
+		}
+		fmt.Printf("Combined timeline saved to %s\n", *out)
+	}
+}
+
+// collectorPage polls the collector and draws the combined charts; the
+// latest seconds are fetched again since slower runners fill them in later
+const collectorPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>wsm collector</title>
+<style>
+body { font-family: sans-serif; margin: 1.5em; color: #222; }
+h1 { font-size: 1.3em; }
+h2 { font-size: 1em; margin: 1em 0 0.3em; }
+canvas { width: 100%; height: 180px; border: 1px solid #ddd; }
+table { border-collapse: collapse; margin-top: 0.5em; }
+td, th { padding: 0.2em 0.8em; text-align: left; border-bottom: 1px solid #eee; }
+.legend span { margin-right: 1em; }
+</style>
+</head>
+<body>
+<h1>wsm combined live results</h1>
+<table id="runs"></table>
+<h2>Requests per second</h2>
+<canvas id="rps"></canvas>
+<div class="legend" id="legend"></div>
+<h2>Worst p95 latency (ms)</h2>
+<canvas id="latency"></canvas>
+<h2>Errors per second</h2>
+<canvas id="errors"></canvas>
+<script>
+var seconds = {}, platforms = [];
+var colors = { total: '#222', target: '#999', spree: '#2ca02c', medusa: '#9467bd', saleor: '#1f77b4' };
+var extra = ['#ff7f0e', '#8c564b', '#e377c2', '#17becf'];
+function color(name) {
+  if (!colors[name]) colors[name] = extra[Object.keys(colors).length % extra.length];
+  return colors[name];
+}
+function points() {
+  return Object.keys(seconds).map(Number).sort(function (a, b) { return a - b; }).map(function (t) { return seconds[t]; });
+}
+function chart(id, series) {
+  var list = points();
+  var canvas = document.getElementById(id);
+  var w = canvas.width = canvas.clientWidth, h = canvas.height = canvas.clientHeight;
+  var ctx = canvas.getContext('2d');
+  var top = 0;
+  series.forEach(function (s) { list.forEach(function (p) { var v = s.value(p); if (v > top) top = v; }); });
+  top = top > 0 ? top * 1.1 : 1;
+  var first = list.length ? list[0].time : 0, span = Math.max(list.length ? list[list.length - 1].time - first : 1, 1);
+  ctx.fillStyle = '#666'; ctx.font = '11px sans-serif';
+  ctx.fillText(top.toFixed(top < 10 ? 2 : 0), 4, 12);
+  ctx.fillText(span + 's', w - 40, h - 4);
+  series.forEach(function (s) {
+    ctx.strokeStyle = s.color; ctx.lineWidth = 1.5; ctx.beginPath();
+    var started = false;
+    list.forEach(function (p) {
+      var v = s.value(p);
+      if (v === undefined) return;
+      var x = (p.time - first) / span * (w - 2) + 1, y = h - 2 - v / top * (h - 16);
+      if (started) ctx.lineTo(x, y); else ctx.moveTo(x, y);
+      started = true;
+    });
+    ctx.stroke();
+  });
+}
+function byPlatform(field) {
+  return platforms.map(function (name) {
+    return { color: color(name), value: function (p) { return p.platforms[name] ? p.platforms[name][field] : undefined; } };
+  });
+}
+function draw() {
+  chart('rps', [
+    { color: colors.target, value: function (p) { return p.target; } },
+    { color: colors.total, value: function (p) { return p.requests; } }
+  ].concat(byPlatform('requests')));
+  chart('latency', byPlatform('p95Max'));
+  chart('errors', [{ color: colors.total, value: function (p) { return p.failed; } }].concat(byPlatform('failed')));
+  var legend = document.getElementById('legend');
+  legend.innerHTML = '';
+  ['total', 'target'].concat(platforms).forEach(function (name) {
+    var span = document.createElement('span');
+    span.style.color = color(name);
+    span.textContent = '■ ' + name;
+    legend.appendChild(span);
+  });
+}
+function showRuns(runs) {
+  var table = document.getElementById('runs');
+  table.innerHTML = '';
+  var header = ['Run', 'Platform', 'Host', 'State', 'Stage', 'Elapsed', 'Requests', 'Error rate'];
+  var row = table.insertRow();
+  header.forEach(function (h) { var th = document.createElement('th'); th.textContent = h; row.appendChild(th); });
+  runs.forEach(function (run) {
+    var status = run.status || {};
+    var row = table.insertRow();
+    [run.id, run.platform, run.host, run.state, status.stage || status.phase || '', status.elapsed || '', run.requests, run.errorRate || ''].forEach(function (v) {
+      row.insertCell().textContent = v;
+    });
+  });
+}
+function poll() {
+  var times = Object.keys(seconds).map(Number);
+  var from = times.length ? Math.max.apply(null, times) - 10 : 0;
+  Promise.all([
+    fetch('api/runs').then(function (r) { return r.json(); }),
+    fetch('api/combined?from=' + from).then(function (r) { return r.json(); })
+  ]).then(function (data) {
+    showRuns(data[0]);
+    data[0].forEach(function (run) { if (platforms.indexOf(run.platform) < 0) platforms.push(run.platform); });
+    platforms.sort();
+    data[1].forEach(function (p) { seconds[p.time] = p; });
+    draw();
+    setTimeout(poll, 1000);
+  }).catch(function () { setTimeout(poll, 3000); });
+}
+window.addEventListener('resize', draw);
+poll();
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCronErrors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+		"1-x * * * *",
+		"@yearly",
+	} {
+		if _, err := ParseCron(expr); err == nil {
+			t.Errorf("ParseCron(%q) succeeded, want an error", expr)
+		}
+	}
+}
+
+func TestParseCronFields(t *testing.T) {
+	s, err := ParseCron("0-30/10,45 9-17 * * 1-5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range []int{0, 10, 20, 30, 45} {
+		if !s.minute[m] {
+			t.Errorf("minute %d not in %v", m, s.minute)
+		}
+	}
+	if len(s.minute) != 5 || len(s.hour) != 9 || len(s.dow) != 5 || !s.domStar || s.dowStar {
+		t.Errorf("fields: minute %v, hour %v, dow %v", s.minute, s.hour, s.dow)
+	}
+
+	// "5/15" steps from 5 to the end of the range
+	if s, err = ParseCron("5/15 * * * *"); err != nil || len(s.minute) != 4 || !s.minute[50] {
+		t.Errorf("5/15: %v, %v", s, err)
+	}
+	// Both 0 and 7 mean Sunday
+	if s, err = ParseCron("0 0 * * 7"); err != nil || !s.dow[0] {
+		t.Errorf("7 as Sunday: %v, %v", s, err)
+	}
+	if s, err = ParseCron(" @daily "); err != nil || !s.minute[0] || len(s.minute) != 1 || !s.hour[0] || len(s.hour) != 1 {
+		t.Errorf("@daily: %v, %v", s, err)
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	// 2024-05-01 is a Wednesday
+	at := func(month time.Month, day, hour, minute int) time.Time {
+		return time.Date(2024, month, day, hour, minute, 0, 0, time.UTC)
+	}
+	for _, c := range []struct {
+		expr string
+		from time.Time
+		want time.Time
+	}{
+		{"*/15 * * * *", at(5, 1, 10, 7), at(5, 1, 10, 15)},
+		{"*/15 * * * *", at(5, 1, 10, 15), at(5, 1, 10, 30)}, // strictly after
+		{"*/15 * * * *", at(5, 1, 10, 14).Add(59 * time.Second), at(5, 1, 10, 15)},
+		{"0 2 * * *", at(5, 1, 3, 0), at(5, 2, 2, 0)},
+		{"@hourly", at(5, 1, 23, 30), at(5, 2, 0, 0)},
+		{"0 9 * * 1-5", at(5, 3, 9, 0), at(5, 6, 9, 0)}, // Friday to Monday
+		{"0 0 1 * *", at(5, 15, 0, 0), at(6, 1, 0, 0)},
+		{"0 0 31 * *", at(4, 1, 0, 0), at(5, 31, 0, 0)}, // April has no 31st
+		{"0 0 29 2 *", at(3, 1, 0, 0), time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		// Both day fields restricted: either matches
+		{"0 0 15 * 0", at(5, 1, 0, 0), at(5, 5, 0, 0)},
+		{"0 0 15 * 0", at(5, 13, 0, 0), at(5, 15, 0, 0)},
+	} {
+		s, err := ParseCron(c.expr)
+		if err != nil {
+			t.Fatalf("%s: %v", c.expr, err)
+		}
+		if got := s.Next(c.from); !got.Equal(c.want) {
+			t.Errorf("%s after %s: %s, want %s", c.expr, c.from.Format(time.RFC3339), got.Format(time.RFC3339), c.want.Format(time.RFC3339))
+		}
+	}
+
+	// 30 February never comes
+	s, err := ParseCron("0 0 30 2 *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Next(at(1, 1, 0, 0)); !got.IsZero() {
+		t.Errorf("30 February: %s, want the zero time", got)
+	}
+}
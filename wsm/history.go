@@ -0,0 +1,240 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// knownPlatforms lists the platforms whose results files are picked up from a run directory
+var knownPlatforms = []string{"medusa", "saleor", "spree"}
+
+// HistoryStore keeps every orchestrated run in its own directory under Dir,
+// with an index.json describing all runs so trends can be reported over time
+type HistoryStore struct {
+	Dir string
+}
+
+// RunSummary holds the headline metrics of one platform in one run
+type RunSummary struct {
+	TotalRequests int64   `json:"totalRequests"`
+	ActualRPS     float64 `json:"actualRPS"`
+	ErrorRate     float64 `json:"errorRate"`
+	P50Ms         float64 `json:"p50Ms"`
+	P95Ms         float64 `json:"p95Ms"`
+	P99Ms         float64 `json:"p99Ms"`
+}
+
+// HistoryEntry records one orchestrated run
+type HistoryEntry struct {
+	ID        string                `json:"id"`
+	Label     string                `json:"label,omitempty"`
+	StartTime string                `json:"startTime"`
+	EndTime   string                `json:"endTime"`
+	Dir       string                `json:"dir"`
+	Succeeded bool                  `json:"succeeded"`
+	Error     string                `json:"error,omitempty"`
+	Results   map[string]string     `json:"results,omitempty"`
+	Summaries map[string]RunSummary `json:"summaries,omitempty"`
+
+	// Maintenance windows the run waited for or paused during
+	Blackouts []blackoutGap `json:"blackouts,omitempty"`
+}
+
+// indexPath returns the location of the history index
+func (h *HistoryStore) indexPath() string {
+	return filepath.Join(h.Dir, "index.json")
+}
+
+// NewRunDir creates the directory a run started at t writes its results to
+func (h *HistoryStore) NewRunDir(t time.Time) (string, string, error) {
+	id := t.Format("20060102_150405")
+	dir := filepath.Join(h.Dir, id)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", "", fmt.Errorf("creating run directory: %v", err)
+	}
+	return id, dir, nil
+}
+
+// Load reads all recorded runs, oldest first
+func (h *HistoryStore) Load() ([]HistoryEntry, error) {
+	data, err := os.ReadFile(h.indexPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []HistoryEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("parsing %s: %v", h.indexPath(), err)
+	}
+	return entries, nil
+}
+
+// Append adds a run to the index
+func (h *HistoryStore) Append(entry HistoryEntry) error {
+	entries, err := h.Load()
+	if err != nil {
+		return err
+	}
+	entries = append(entries, entry)
+
+	data, _ := json.MarshalIndent(entries, "", "  ")
+	tmp := h.indexPath() + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, h.indexPath())
+}
+
+// collectResults finds each platform's results file in a run directory and summarizes it
+func collectResults(dir string) (map[string]string, map[string]RunSummary) {
+	results := make(map[string]string)
+	summaries := make(map[string]RunSummary)
+
+	for _, platform := range knownPlatforms {
+		for _, name := range []string{platform + "_latest.json", platform + "_results.json"} {
+			path := filepath.Join(dir, name)
+			if _, err := os.Stat(path); err != nil {
+				continue
+			}
+			results[platform] = path
+			if summary, err := summarizeResults(path); err == nil {
+				summaries[platform] = summary
+			} else {
+				fmt.Printf("Warning: could not summarize %s: %v\n", path, err)
+			}
+			break
+		}
+	}
+
+	return results, summaries
+}
+
+// summarizeResults extracts headline metrics from a runner results file.
+// Numbers may be JSON numbers or strings ("21.59", "97.5%"), and latencies
+// Go duration strings or milliseconds.
+func summarizeResults(path string) (RunSummary, error) {
+	var s RunSummary
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return s, err
+	}
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return s, err
+	}
+
+	s.TotalRequests = int64(looseNumber(raw["totalRequests"]))
+	s.ActualRPS = looseNumber(raw["actualRPS"])
+	if s.TotalRequests > 0 {
+		s.ErrorRate = looseNumber(raw["failedRequests"]) / float64(s.TotalRequests) * 100
+	}
+	if latency, ok := raw["latency"].(map[string]interface{}); ok {
+		s.P50Ms = looseMillis(latency["p50"])
+		s.P95Ms = looseMillis(latency["p95"])
+		s.P99Ms = looseMillis(latency["p99"])
+	}
+
+	return s, nil
+}
+
+// looseNumber converts a JSON number or numeric string to a float, returning 0 if it can't
+func looseNumber(v interface{}) float64 {
+	switch n := v.(type) {
+	case float64:
+		return n
+	case string:
+		f, _ := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(n), "%"), 64)
+		return f
+	}
+	return 0
+}
+
+// looseMillis converts a duration string or millisecond number to milliseconds
+func looseMillis(v interface{}) float64 {
+	if str, ok := v.(string); ok {
+		if d, err := time.ParseDuration(str); err == nil {
+			return float64(d) / float64(time.Millisecond)
+		}
+	}
+	return looseNumber(v)
+}
+
+// TrendPoint is one run's metrics in a platform trend, with the blackout
+// windows that paused it
+type TrendPoint struct {
+	RunID     string   `json:"runId"`
+	Blackouts []string `json:"blackouts,omitempty"`
+	RunSummary
+}
+
+// Trend builds each platform's metrics over the last limit successful runs
+func (h *HistoryStore) Trend(limit int) (map[string][]TrendPoint, error) {
+	entries, err := h.Load()
+	if err != nil {
+		return nil, err
+	}
+	if limit > 0 && len(entries) > limit {
+		entries = entries[len(entries)-limit:]
+	}
+
+	trend := make(map[string][]TrendPoint)
+	for _, entry := range entries {
+		var blackouts []string
+		for _, gap := range entry.Blackouts {
+			blackouts = append(blackouts, gap.String())
+		}
+		for platform, summary := range entry.Summaries {
+			trend[platform] = append(trend[platform], TrendPoint{RunID: entry.ID, Blackouts: blackouts, RunSummary: summary})
+		}
+	}
+	return trend, nil
+}
+
+// WriteTrendReport prints each platform's trend with the change against the previous
+// run and saves it to trend.json in the history directory
+func (h *HistoryStore) WriteTrendReport(limit int) error {
+	trend, err := h.Trend(limit)
+	if err != nil {
+		return err
+	}
+
+	platforms := make([]string, 0, len(trend))
+	for platform := range trend {
+		platforms = append(platforms, platform)
+	}
+	sort.Strings(platforms)
+
+	for _, platform := range platforms {
+		fmt.Printf("\nTrend: %s\n", platform)
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "Run\tActual RPS\tError Rate\tp95\tΔ p95\tBlackouts")
+		var previous *TrendPoint
+		for i, point := range trend[platform] {
+			delta := "-"
+			if previous != nil && previous.P95Ms > 0 {
+				delta = fmt.Sprintf("%+.1f%%", (point.P95Ms-previous.P95Ms)/previous.P95Ms*100)
+			}
+			blackouts := "-"
+			if len(point.Blackouts) > 0 {
+				blackouts = strings.Join(point.Blackouts, ", ")
+			}
+			fmt.Fprintf(w, "%s\t%.2f\t%.2f%%\t%.1fms\t%s\t%s\n", point.RunID, point.ActualRPS, point.ErrorRate, point.P95Ms, delta, blackouts)
+			previous = &trend[platform][i]
+		}
+		w.Flush()
+	}
+
+	data, _ := json.MarshalIndent(trend, "", "  ")
+	return os.WriteFile(filepath.Join(h.Dir, "trend.json"), data, 0644)
+}
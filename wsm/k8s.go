@@ -0,0 +1,479 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// kubectl runs kubectl against the configured cluster and returns its stdout
+type kubectl struct {
+	Kubeconfig string
+	Namespace  string
+}
+
+func (k kubectl) run(stdin []byte, args ...string) ([]byte, error) {
+	if k.Kubeconfig != "" {
+		args = append([]string{"--kubeconfig", k.Kubeconfig}, args...)
+	}
+	args = append([]string{"--namespace", k.Namespace}, args...)
+
+	cmd := exec.Command("kubectl", args...)
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("kubectl %s: %v: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+// agentShare returns agent i's part of total when split over n agents
+func agentShare(total int64, n, i int) int64 {
+	share := total / int64(n)
+	if int64(i) < total%int64(n) {
+		share++
+	}
+	return share
+}
+
+// scaleField replaces the number at obj[key] with agent i's share of it.
+// Integers are split into whole shares; fractional rates (0.5 RPS) are
+// divided evenly.
+func scaleField(obj map[string]interface{}, key string, n, i int, minimum int64) {
+	num, ok := obj[key].(json.Number)
+	if !ok {
+		return
+	}
+	total, err := num.Int64()
+	if err != nil {
+		if rate, err := num.Float64(); err == nil {
+			obj[key] = rate / float64(n)
+		}
+		return
+	}
+	share := agentShare(total, n, i)
+	if share < minimum {
+		share = minimum
+	}
+	obj[key] = share
+}
+
+// splitConfig divides a runner config among n agents: worker and queue sizes,
+// stage and phase target RPS, per-endpoint rates, the adaptive RPS bounds and AIMD step are split so the agents together
+// produce the original load profile. Everything else is copied unchanged.
+func splitConfig(data []byte, n int) ([][]byte, error) {
+	configs := make([][]byte, n)
+	for i := 0; i < n; i++ {
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		var config map[string]interface{}
+		if err := dec.Decode(&config); err != nil {
+			return nil, err
+		}
+
+		if test, ok := config["Test"].(map[string]interface{}); ok {
+			scaleField(test, "MaxWorkers", n, i, 1)
+			scaleField(test, "MaxQueueSize", n, i, 1)
+			if stages, ok := test["RampupStages"].([]interface{}); ok {
+				for _, s := range stages {
+					if stage, ok := s.(map[string]interface{}); ok {
+						scaleField(stage, "TargetRPS", n, i, 0)
+					}
+				}
+			}
+			if phases, ok := test["Phases"].([]interface{}); ok {
+				for _, p := range phases {
+					if phase, ok := p.(map[string]interface{}); ok {
+						scaleField(phase, "RPS", n, i, 0)
+						scaleField(phase, "PeakRPS", n, i, 0)
+					}
+				}
+			}
+			if rates, ok := test["EndpointRPS"].(map[string]interface{}); ok {
+				// Split evenly, as a whole share could leave an agent at 0
+				for operation, v := range rates {
+					if num, ok := v.(json.Number); ok {
+						if rate, err := num.Float64(); err == nil {
+							rates[operation] = rate / float64(n)
+						}
+					}
+				}
+			}
+			if adaptive, ok := test["AdaptiveConfig"].(map[string]interface{}); ok {
+				scaleField(adaptive, "InitialRPS", n, i, 1)
+				scaleField(adaptive, "MinimumRPS", n, i, 1)
+				scaleField(adaptive, "MaximumRPS", n, i, 1)
+				if aimd, ok := adaptive["AIMD"].(map[string]interface{}); ok {
+					scaleField(aimd, "Increase", n, i, 1)
+				}
+			}
+		}
+
+		out, err := json.MarshalIndent(config, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		configs[i] = out
+	}
+	return configs, nil
+}
+
+// agentManifest builds a ConfigMap holding the per-agent configs and an Indexed
+// Job running one agent per completion index
+func agentManifest(name, runID, platform, image, binary string, configs [][]byte) ([]byte, error) {
+	labels := map[string]string{"app": "wsm-agent", "wsm-run": name, "wsm-run-id": runID, "wsm-platform": platform}
+
+	data := make(map[string]string, len(configs))
+	for i, config := range configs {
+		data[fmt.Sprintf("agent-%d.json", i)] = string(config)
+	}
+
+	// The results are read back from the log
+	command := fmt.Sprintf("%s -config /config/agent-${JOB_COMPLETION_INDEX}.json -out-dir /tmp -print-json", binary)
+	// The runners report the agents and their node with the generator host;
+	// all agents send the same X-Load-Test-ID
+	env := []interface{}{
+		map[string]interface{}{"name": "WSM_RUN_ID", "value": runID},
+		map[string]interface{}{"name": "WSM_AGENTS", "value": fmt.Sprint(len(configs))},
+		map[string]interface{}{"name": "WSM_AGENT_INDEX", "valueFrom": map[string]interface{}{
+			"fieldRef": map[string]interface{}{"fieldPath": "metadata.annotations['batch.kubernetes.io/job-completion-index']"},
+		}},
+		map[string]interface{}{"name": "NODE_NAME", "valueFrom": map[string]interface{}{
+			"fieldRef": map[string]interface{}{"fieldPath": "spec.nodeName"},
+		}},
+	}
+	manifest := map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "List",
+		"items": []interface{}{
+			map[string]interface{}{
+				"apiVersion": "v1",
+				"kind":       "ConfigMap",
+				"metadata":   map[string]interface{}{"name": name, "labels": labels},
+				"data":       data,
+			},
+			map[string]interface{}{
+				"apiVersion": "batch/v1",
+				"kind":       "Job",
+				"metadata":   map[string]interface{}{"name": name, "labels": labels},
+				"spec": map[string]interface{}{
+					"completionMode": "Indexed",
+					"completions":    len(configs),
+					"parallelism":    len(configs),
+					"backoffLimit":   0,
+					"template": map[string]interface{}{
+						"metadata": map[string]interface{}{"labels": labels},
+						"spec": map[string]interface{}{
+							"restartPolicy": "Never",
+							"containers": []interface{}{
+								map[string]interface{}{
+									"name":         "agent",
+									"image":        image,
+									"command":      []string{"sh", "-c", command},
+									"env":          env,
+									"volumeMounts": []interface{}{map[string]interface{}{"name": "config", "mountPath": "/config"}},
+								},
+							},
+							"volumes": []interface{}{
+								map[string]interface{}{"name": "config", "configMap": map[string]interface{}{"name": name}},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	return json.MarshalIndent(manifest, "", "  ")
+}
+
+// waitForJob polls the Job until it completes or fails
+func waitForJob(k kubectl, name string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		out, err := k.run(nil, "get", "job", name, "-o", "json")
+		if err != nil {
+			return err
+		}
+
+		var job struct {
+			Status struct {
+				Active     int `json:"active"`
+				Succeeded  int `json:"succeeded"`
+				Failed     int `json:"failed"`
+				Conditions []struct {
+					Type   string `json:"type"`
+					Status string `json:"status"`
+				} `json:"conditions"`
+			} `json:"status"`
+		}
+		if err := json.Unmarshal(out, &job); err != nil {
+			return err
+		}
+		for _, c := range job.Status.Conditions {
+			if c.Status != "True" {
+				continue
+			}
+			switch c.Type {
+			case "Complete":
+				return nil
+			case "Failed":
+				return fmt.Errorf("job %s failed (%d agents succeeded, %d failed)", name, job.Status.Succeeded, job.Status.Failed)
+			}
+		}
+
+		fmt.Printf("Agents: %d active, %d succeeded, %d failed\n", job.Status.Active, job.Status.Succeeded, job.Status.Failed)
+		if time.Now().After(deadline) {
+			return fmt.Errorf("job %s did not finish within %s", name, timeout)
+		}
+		time.Sleep(15 * time.Second)
+	}
+}
+
+// extractResults finds the final results JSON a runner prints to its log
+func extractResults(logs []byte) (map[string]interface{}, error) {
+	marker := []byte("Final Test Results:")
+	idx := bytes.LastIndex(logs, marker)
+	if idx < 0 {
+		return nil, fmt.Errorf("no final results in agent log")
+	}
+
+	var results map[string]interface{}
+	if err := json.NewDecoder(bytes.NewReader(logs[idx+len(marker):])).Decode(&results); err != nil {
+		return nil, fmt.Errorf("parsing agent results: %v", err)
+	}
+	return results, nil
+}
+
+// collectAgentResults reads each agent pod's log and saves its results
+func collectAgentResults(k kubectl, name, platform, outDir string) ([]map[string]interface{}, error) {
+	out, err := k.run(nil, "get", "pods", "-l", "wsm-run="+name, "-o", "json")
+	if err != nil {
+		return nil, err
+	}
+	var pods struct {
+		Items []struct {
+			Metadata struct {
+				Name        string            `json:"name"`
+				Annotations map[string]string `json:"annotations"`
+			} `json:"metadata"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(out, &pods); err != nil {
+		return nil, err
+	}
+
+	var results []map[string]interface{}
+	for _, pod := range pods.Items {
+		index := pod.Metadata.Annotations["batch.kubernetes.io/job-completion-index"]
+		logs, err := k.run(nil, "logs", pod.Metadata.Name)
+		if err != nil {
+			fmt.Printf("Agent %s: %v\n", index, err)
+			continue
+		}
+		os.WriteFile(filepath.Join(outDir, fmt.Sprintf("agent-%s.log", index)), logs, 0644)
+
+		agentResults, err := extractResults(logs)
+		if err != nil {
+			fmt.Printf("Agent %s: %v\n", index, err)
+			continue
+		}
+		data, _ := json.MarshalIndent(agentResults, "", "  ")
+		os.WriteFile(filepath.Join(outDir, fmt.Sprintf("%s_agent-%s.json", platform, index)), data, 0644)
+		results = append(results, agentResults)
+	}
+	return results, nil
+}
+
+// mergeAgentResults combines the agents' results into one report in the
+// runners' format. Counts and RPS are summed; percentiles cannot be combined
+// exactly, so each latency percentile is the worst value across agents. The
+// agents' generator hosts are listed under generatorHosts.
+func mergeAgentResults(runID, platform string, agents []map[string]interface{}) map[string]interface{} {
+	var total, successful, failed, timeouts, rps float64
+	latency := make(map[string]float64)
+	for _, agent := range agents {
+		total += looseNumber(agent["totalRequests"])
+		successful += looseNumber(agent["successfulRequests"])
+		failed += looseNumber(agent["failedRequests"])
+		timeouts += looseNumber(agent["timeoutRequests"])
+		rps += looseNumber(agent["actualRPS"])
+
+		if l, ok := agent["latency"].(map[string]interface{}); ok {
+			for key, v := range l {
+				if ms := looseMillis(v); ms > latency[key] {
+					latency[key] = ms
+				}
+			}
+		}
+	}
+
+	merged := map[string]interface{}{
+		"platform":           platform,
+		"runId":              runID,
+		"agents":             len(agents),
+		"totalRequests":      int64(total),
+		"successfulRequests": int64(successful),
+		"failedRequests":     int64(failed),
+		"timeoutRequests":    int64(timeouts),
+		"actualRPS":          fmt.Sprintf("%.2f", rps),
+		"latencyMerge":       "per-agent maximum, not a combined percentile",
+	}
+	if total > 0 {
+		merged["successRate"] = fmt.Sprintf("%.2f%%", successful/total*100)
+		merged["errorRate"] = fmt.Sprintf("%.2f%%", failed/total*100)
+	}
+	if len(latency) > 0 {
+		l := make(map[string]string, len(latency))
+		for key, ms := range latency {
+			l[key] = time.Duration(ms * float64(time.Millisecond)).String()
+		}
+		merged["latency"] = l
+	}
+
+	// Keep the earliest start and latest end so the merged run spans all agents
+	var starts, ends []string
+	for _, agent := range agents {
+		if s, ok := agent["testStartTime"].(string); ok {
+			starts = append(starts, s)
+		}
+		if e, ok := agent["testEndTime"].(string); ok {
+			ends = append(ends, e)
+		}
+	}
+	sort.Strings(starts)
+	sort.Strings(ends)
+	if len(starts) > 0 {
+		merged["testStartTime"] = starts[0]
+	}
+	if len(ends) > 0 {
+		merged["testEndTime"] = ends[len(ends)-1]
+	}
+
+	var hosts []interface{}
+	for _, agent := range agents {
+		if host, ok := agent["generatorHost"]; ok {
+			hosts = append(hosts, host)
+		}
+	}
+	if len(hosts) > 0 {
+		merged["generatorHosts"] = hosts
+	}
+	return merged
+}
+
+// newRunID returns a random (version 4) UUID for the agents to share as
+// their run ID
+func newRunID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
+
+// runK8s implements `wsm k8s`: run one platform test as a Kubernetes Job of
+// load agents, each generating its share of the configured profile
+func runK8s(args []string) {
+	fs := flag.NewFlagSet("k8s", flag.ExitOnError)
+	platform := fs.String("platform", "", "Platform to test (medusa, saleor or spree)")
+	configPath := fs.String("config", "", "Runner config file to split among the agents")
+	image := fs.String("image", "", "Container image containing the runner binaries")
+	binary := fs.String("binary", "", "Runner binary inside the image (default /app/<platform>_benchmark)")
+	agents := fs.Int("agents", 2, "Number of load agents")
+	kubeconfig := fs.String("kubeconfig", "", "Path to the kubeconfig (default: kubectl's default)")
+	namespace := fs.String("namespace", "default", "Namespace to create the Job in")
+	timeout := fs.Duration("timeout", 2*time.Hour, "Maximum time to wait for the agents")
+	outDir := fs.String("out-dir", "", "Results directory (default k8s_results_<timestamp>)")
+	keep := fs.Bool("keep", false, "Keep the Job and ConfigMap after collecting results")
+	fs.Parse(args)
+
+	*platform = strings.ToLower(*platform)
+	if *platform == "" || *configPath == "" || *image == "" {
+		log.Fatal("k8s: -platform, -config and -image are required")
+	}
+	if *agents < 1 {
+		log.Fatal("k8s: -agents must be at least 1")
+	}
+	if *binary == "" {
+		*binary = "/app/" + *platform + "_benchmark"
+	}
+
+	start := time.Now()
+	name := fmt.Sprintf("wsm-%s-%s", *platform, start.Format("20060102-150405"))
+	if *outDir == "" {
+		*outDir = "k8s_results_" + start.Format("20060102_150405")
+	}
+	if err := os.MkdirAll(*outDir, 0755); err != nil {
+		log.Fatalf("k8s: %v", err)
+	}
+
+	data, err := os.ReadFile(*configPath)
+	if err != nil {
+		log.Fatalf("k8s: %v", err)
+	}
+	configs, err := splitConfig(data, *agents)
+	if err != nil {
+		log.Fatalf("k8s: splitting %s: %v", *configPath, err)
+	}
+	runID, err := newRunID()
+	if err != nil {
+		log.Fatalf("k8s: %v", err)
+	}
+	manifest, err := agentManifest(name, runID, *platform, *image, *binary, configs)
+	if err != nil {
+		log.Fatalf("k8s: %v", err)
+	}
+	os.WriteFile(filepath.Join(*outDir, "manifest.json"), manifest, 0644)
+
+	k := kubectl{Kubeconfig: *kubeconfig, Namespace: *namespace}
+	if _, err := k.run(manifest, "apply", "-f", "-"); err != nil {
+		log.Fatalf("k8s: %v", err)
+	}
+	fmt.Printf("Started job %s with %d agents in namespace %s, run ID %s\n", name, *agents, *namespace, runID)
+	if !*keep {
+		defer func() {
+			if _, err := k.run(nil, "delete", "job,configmap", "-l", "wsm-run="+name); err != nil {
+				fmt.Printf("Cleanup failed: %v\n", err)
+			}
+		}()
+	}
+
+	if err := waitForJob(k, name, *timeout); err != nil {
+		fmt.Printf("Warning: %v; collecting whatever results exist\n", err)
+	}
+
+	results, err := collectAgentResults(k, name, *platform, *outDir)
+	if err != nil {
+		fmt.Printf("Error collecting results: %v\n", err)
+		return
+	}
+	if len(results) == 0 {
+		fmt.Println("No agent produced results")
+		return
+	}
+
+	merged := mergeAgentResults(runID, strings.ToUpper((*platform)[:1])+(*platform)[1:], results)
+	mergedJSON, _ := json.MarshalIndent(merged, "", "  ")
+	path := filepath.Join(*outDir, *platform+"_results.json")
+	if err := os.WriteFile(path, mergedJSON, 0644); err != nil {
+		fmt.Printf("Error writing results: %v\n", err)
+		return
+	}
+	fmt.Println(string(mergedJSON))
+	fmt.Printf("\nResults from %d of %d agents merged into %s\n", len(results), *agents, path)
+}
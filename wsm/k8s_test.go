@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// agentTests decodes the Test section of each split config
+func agentTests(t *testing.T, configs [][]byte) []map[string]interface{} {
+	t.Helper()
+	var tests []map[string]interface{}
+	for _, data := range configs {
+		var config struct{ Test map[string]interface{} }
+		if err := json.Unmarshal(data, &config); err != nil {
+			t.Fatal(err)
+		}
+		tests = append(tests, config.Test)
+	}
+	return tests
+}
+
+func TestSplitConfig(t *testing.T) {
+	data := []byte(`{
+		"Test": {
+			"MaxWorkers": 10,
+			"MaxQueueSize": 1000,
+			"RampupStages": [{"Duration": "10s", "TargetRPS": 100}, {"Duration": "10s", "TargetRPS": 0.5}],
+			"EndpointRPS": {"products": 3},
+			"AdaptiveConfig": {"InitialRPS": 2, "MaximumRPS": 7, "AIMD": {"Increase": 5}}
+		},
+		"Endpoints": {"Products": "http://shop/products"}
+	}`)
+	configs, err := splitConfig(data, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := agentTests(t, configs)
+
+	var workers, queue, rps, maximum float64
+	for i, test := range tests {
+		workers += test["MaxWorkers"].(float64)
+		queue += test["MaxQueueSize"].(float64)
+		stages := test["RampupStages"].([]interface{})
+		rps += stages[0].(map[string]interface{})["TargetRPS"].(float64)
+		if rate := stages[1].(map[string]interface{})["TargetRPS"].(float64); rate != 0.5/3 {
+			t.Errorf("agent %d: fractional stage rate %v, want an even share", i, rate)
+		}
+		if rate := test["EndpointRPS"].(map[string]interface{})["products"].(float64); rate != 1 {
+			t.Errorf("agent %d: endpoint rate %v, want 1", i, rate)
+		}
+		adaptive := test["AdaptiveConfig"].(map[string]interface{})
+		maximum += adaptive["MaximumRPS"].(float64)
+		if initial := adaptive["InitialRPS"].(float64); initial != 1 {
+			t.Errorf("agent %d: InitialRPS %v, want at least 1", i, initial)
+		}
+	}
+	// The remainders go to the first agents, so the shares add up
+	if workers != 10 || queue != 1000 || rps != 100 || maximum != 7 {
+		t.Errorf("shares add up to %v workers, %v queue, %v RPS, %v MaximumRPS", workers, queue, rps, maximum)
+	}
+	if first, last := tests[0]["MaxWorkers"].(float64), tests[2]["MaxWorkers"].(float64); first != 4 || last != 3 {
+		t.Errorf("workers split %v ... %v, want 4 ... 3", first, last)
+	}
+
+	var config struct{ Endpoints map[string]string }
+	if err := json.Unmarshal(configs[1], &config); err != nil || config.Endpoints["Products"] != "http://shop/products" {
+		t.Errorf("endpoints not copied: %v, %v", config.Endpoints, err)
+	}
+}
+
+// With more agents than workers, every agent still gets a worker, while
+// the stage rate may leave an agent at 0
+func TestSplitConfigMoreAgentsThanWorkers(t *testing.T) {
+	configs, err := splitConfig([]byte(`{"Test": {"MaxWorkers": 2, "RampupStages": [{"TargetRPS": 3}]}}`), 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, test := range agentTests(t, configs) {
+		if workers := test["MaxWorkers"].(float64); workers != 1 {
+			t.Errorf("agent %d: %v workers, want 1", i, workers)
+		}
+		want := 0.0
+		if i < 3 {
+			want = 1
+		}
+		stage := test["RampupStages"].([]interface{})[0].(map[string]interface{})
+		if rps := stage["TargetRPS"].(float64); rps != want {
+			t.Errorf("agent %d: %v RPS, want %v", i, rps, want)
+		}
+	}
+
+	if _, err := splitConfig([]byte(`{"Test": `), 2); err == nil {
+		t.Error("invalid config split without an error")
+	}
+}
+
+func TestMergeAgentResults(t *testing.T) {
+	agents := []map[string]interface{}{
+		{
+			"totalRequests": 100.0, "successfulRequests": 90.0, "failedRequests": 10.0, "timeoutRequests": 2.0,
+			"actualRPS":     "10.50",
+			"latency":       map[string]interface{}{"p50": "10ms", "p95": "80ms", "p99": "1.2s"},
+			"testStartTime": "2024-05-01T10:00:01Z", "testEndTime": "2024-05-01T10:01:00Z",
+			"generatorHost": map[string]interface{}{"hostname": "agent-0"},
+		},
+		{
+			"totalRequests": 300.0, "successfulRequests": 300.0, "failedRequests": 0.0,
+			"actualRPS":     "30.25",
+			"latency":       map[string]interface{}{"p50": "20ms", "p95": "60ms", "p99": "900ms"},
+			"testStartTime": "2024-05-01T10:00:00Z", "testEndTime": "2024-05-01T10:01:02Z",
+			"generatorHost": map[string]interface{}{"hostname": "agent-1"},
+		},
+	}
+	merged := mergeAgentResults("run-1", "spree", agents)
+
+	for key, want := range map[string]interface{}{
+		"runId":              "run-1",
+		"agents":             2,
+		"totalRequests":      int64(400),
+		"successfulRequests": int64(390),
+		"failedRequests":     int64(10),
+		"timeoutRequests":    int64(2),
+		"actualRPS":          "40.75",
+		"successRate":        "97.50%",
+		"errorRate":          "2.50%",
+		"testStartTime":      "2024-05-01T10:00:00Z",
+		"testEndTime":        "2024-05-01T10:01:02Z",
+	} {
+		if merged[key] != want {
+			t.Errorf("%s: %v, want %v", key, merged[key], want)
+		}
+	}
+
+	// Each percentile is the highest of the agents'
+	latency := merged["latency"].(map[string]string)
+	for key, want := range map[string]string{"p50": "20ms", "p95": "80ms", "p99": "1.2s"} {
+		if latency[key] != want {
+			t.Errorf("latency %s: %s, want %s", key, latency[key], want)
+		}
+	}
+	if merged["latencyMerge"] == nil {
+		t.Error("merged results don't say how the percentiles were merged")
+	}
+	if hosts := merged["generatorHosts"].([]interface{}); len(hosts) != 2 {
+		t.Errorf("generatorHosts %v", hosts)
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// usage prints the available subcommands
+func usage() {
+	fmt.Fprintln(os.Stderr, `Usage: wsm <command> [flags]
+
+Commands:
+  schedule   Run test commands on a cron schedule and record them in the history store
+  k8s        Run a platform test as a Kubernetes Job of load agents and merge their results
+  suite      Run the platform tests of a suite file sequentially or in parallel, then compare them
+  sla        Check results against an SLA definition and write a one-page verdict (Markdown/PDF)
+  report     Render any results file (platform run, stress test or comparison) as console text, Markdown or HTML
+  mocktarget Serve mock Spree, Medusa and Saleor APIs to measure the generator's own limits
+  calibrate  Measure this machine's maximum RPS, scheduling jitter and timer resolution
+  verify     Check that results files sealed with -checksum are unmodified
+  ab         Run two configs of the same platform, interleaved, and compare their results
+  sweep      Run a short test per value of one parameter (workers, RPS, payload, page size) and tabulate them
+  collector  Receive the live results of runners on several hosts and serve them as one combined view
+
+Run "wsm <command> -h" for the flags of a command.`)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	switch os.Args[1] {
+	case "schedule":
+		runSchedule(os.Args[2:])
+	case "k8s":
+		runK8s(os.Args[2:])
+	case "suite":
+		runSuite(os.Args[2:])
+	case "sla":
+		runSLA(os.Args[2:])
+	case "report":
+		runReport(os.Args[2:])
+	case "mocktarget":
+		runMockTarget(os.Args[2:])
+	case "calibrate":
+		runCalibrate(os.Args[2:])
+	case "verify":
+		runVerify(os.Args[2:])
+	case "ab":
+		runAB(os.Args[2:])
+	case "sweep":
+		runSweep(os.Args[2:])
+	case "collector":
+		runCollector(os.Args[2:])
+	case "-h", "--help", "help":
+		usage()
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", os.Args[1])
+		usage()
+		os.Exit(2)
+	}
+}
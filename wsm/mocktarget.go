@@ -0,0 +1,345 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// MockTargetConfig shapes the responses of the mock platform server
+type MockTargetConfig struct {
+	Mode      string        // "spree", "medusa", "saleor" or "all" (default)
+	Latency   time.Duration // added to every response
+	Jitter    time.Duration // up to this much more, uniformly distributed
+	ErrorRate float64       // share of failed responses in percent
+	Products  int           // catalog size, default 100
+}
+
+// mockModes lists the platforms the mock server can answer for
+var mockModes = map[string]bool{"all": true, "spree": true, "medusa": true, "saleor": true}
+
+// mockTarget is an http.Handler answering the storefront APIs the runners
+// test with a synthetic catalog: Spree's JSON:API product list, Medusa's
+// store API and Saleor's GraphQL endpoint. Failures are a 500 for the REST
+// APIs and a 200 with a GraphQL error for Saleor, like the real platforms.
+// It runs under httptest.NewServer as well as a real listener.
+type mockTarget struct {
+	config MockTargetConfig
+
+	requests atomic.Int64
+	failures atomic.Int64
+
+	mutex sync.Mutex
+	paths map[string]int64 // requests per route
+}
+
+// newMockTarget validates the config and fills in the defaults
+func newMockTarget(config MockTargetConfig) (*mockTarget, error) {
+	if config.Mode == "" {
+		config.Mode = "all"
+	}
+	if !mockModes[config.Mode] {
+		return nil, fmt.Errorf("unknown mode %q (available: all, spree, medusa, saleor)", config.Mode)
+	}
+	if config.Latency < 0 || config.Jitter < 0 {
+		return nil, fmt.Errorf("latency and jitter must not be negative")
+	}
+	if config.ErrorRate < 0 || config.ErrorRate > 100 {
+		return nil, fmt.Errorf("error rate %.2f%% is outside 0-100%%", config.ErrorRate)
+	}
+	if config.Products <= 0 {
+		config.Products = 100
+	}
+	return &mockTarget{config: config, paths: make(map[string]int64)}, nil
+}
+
+// serves reports whether the mode answers for a platform
+func (m *mockTarget) serves(platform string) bool {
+	return m.config.Mode == "all" || m.config.Mode == platform
+}
+
+func (m *mockTarget) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimSuffix(r.URL.Path, "/")
+	switch {
+	case path == "/health":
+		writeMockJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+		return
+	case path == "/mock/stats":
+		writeMockJSON(w, http.StatusOK, m.stats())
+		return
+	}
+
+	route, handler := m.route(path)
+	if handler == nil {
+		http.NotFound(w, r)
+		return
+	}
+	m.requests.Add(1)
+	m.mutex.Lock()
+	m.paths[route]++
+	m.mutex.Unlock()
+
+	if !m.wait(r) {
+		return // the client gave up
+	}
+	if m.config.ErrorRate > 0 && rand.Float64()*100 < m.config.ErrorRate {
+		m.failures.Add(1)
+		if route == "saleor" {
+			writeMockJSON(w, http.StatusOK, map[string]interface{}{
+				"data":   nil,
+				"errors": []map[string]string{{"message": "mock target: injected error"}},
+			})
+		} else {
+			writeMockJSON(w, http.StatusInternalServerError, map[string]string{"message": "mock target: injected error"})
+		}
+		return
+	}
+	handler(w, r, path)
+}
+
+// route returns the route name and handler of a path, or a nil handler
+func (m *mockTarget) route(path string) (string, func(http.ResponseWriter, *http.Request, string)) {
+	switch {
+	case m.serves("spree") && strings.HasPrefix(path, "/api/v2/storefront/products"):
+		return "spree", m.spree
+	case m.serves("medusa") && (strings.HasPrefix(path, "/store/products") || strings.HasPrefix(path, "/store/product-categories")):
+		return "medusa", m.medusa
+	case m.serves("saleor") && path == "/graphql":
+		return "saleor", m.saleor
+	}
+	return "", nil
+}
+
+// wait sleeps the configured latency; it returns false if the request was
+// canceled meanwhile
+func (m *mockTarget) wait(r *http.Request) bool {
+	delay := m.config.Latency
+	if m.config.Jitter > 0 {
+		delay += time.Duration(rand.Int63n(int64(m.config.Jitter) + 1))
+	}
+	if delay <= 0 {
+		return true
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-r.Context().Done():
+		return false
+	}
+}
+
+// page returns the 1-based IDs of one page of the catalog
+func (m *mockTarget) page(offset, limit int) []int {
+	var ids []int
+	for id := offset + 1; id <= m.config.Products && len(ids) < limit; id++ {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// queryInt reads a non-negative integer query parameter
+func queryInt(r *http.Request, name string, fallback int) int {
+	if v, err := strconv.Atoi(r.URL.Query().Get(name)); err == nil && v >= 0 {
+		return v
+	}
+	return fallback
+}
+
+// spree answers the JSON:API product list (page, per_page) and single products
+func (m *mockTarget) spree(w http.ResponseWriter, r *http.Request, path string) {
+	if id := strings.TrimPrefix(path, "/api/v2/storefront/products"); id != "" {
+		writeMockJSON(w, http.StatusOK, map[string]interface{}{"data": spreeProduct(strings.TrimPrefix(id, "/"))})
+		return
+	}
+	perPage := max(queryInt(r, "per_page", 25), 1)
+	page := max(queryInt(r, "page", 1), 1)
+	data := []interface{}{}
+	for _, id := range m.page((page-1)*perPage, perPage) {
+		data = append(data, spreeProduct(strconv.Itoa(id)))
+	}
+	writeMockJSON(w, http.StatusOK, map[string]interface{}{
+		"data": data,
+		"meta": map[string]int{
+			"count":       len(data),
+			"total_count": m.config.Products,
+			"total_pages": (m.config.Products + perPage - 1) / perPage,
+		},
+	})
+}
+
+func spreeProduct(id string) map[string]interface{} {
+	return map[string]interface{}{
+		"id":         id,
+		"type":       "product",
+		"attributes": map[string]interface{}{"name": "Product " + id, "price": "19.99", "currency": "USD"},
+	}
+}
+
+// medusa answers the store API's product and category lists (limit, offset)
+// and single resources
+func (m *mockTarget) medusa(w http.ResponseWriter, r *http.Request, path string) {
+	field, single, prefix, kind := "products", "product", "/store/products", "prod"
+	if strings.HasPrefix(path, "/store/product-categories") {
+		field, single, prefix, kind = "product_categories", "product_category", "/store/product-categories", "pcat"
+	}
+	if id := strings.TrimPrefix(path, prefix); id != "" {
+		writeMockJSON(w, http.StatusOK, map[string]interface{}{single: medusaResource(strings.TrimPrefix(id, "/"))})
+		return
+	}
+	limit := max(queryInt(r, "limit", 50), 1)
+	offset := queryInt(r, "offset", 0)
+	list := []interface{}{}
+	for _, id := range m.page(offset, limit) {
+		list = append(list, medusaResource(fmt.Sprintf("%s_%d", kind, id)))
+	}
+	writeMockJSON(w, http.StatusOK, map[string]interface{}{
+		field:    list,
+		"count":  m.config.Products,
+		"offset": offset,
+		"limit":  limit,
+	})
+}
+
+func medusaResource(id string) map[string]interface{} {
+	return map[string]interface{}{"id": id, "title": "Mock " + id, "handle": id}
+}
+
+// saleor answers any GraphQL query, or an Apollo batch of them, with a
+// product connection, a category connection and a single product, which
+// covers the fields the runner's queries select
+func (m *mockTarget) saleor(w http.ResponseWriter, r *http.Request, path string) {
+	edges := func(kind string) map[string]interface{} {
+		list := []interface{}{}
+		for _, id := range m.page(0, min(m.config.Products, 20)) {
+			node := map[string]interface{}{"id": fmt.Sprintf("%s:%d", kind, id), "name": fmt.Sprintf("Mock %s %d", kind, id)}
+			list = append(list, map[string]interface{}{"node": node})
+		}
+		return map[string]interface{}{"edges": list, "totalCount": m.config.Products}
+	}
+	result := map[string]interface{}{
+		"data": map[string]interface{}{
+			"products":   edges("Product"),
+			"categories": edges("Category"),
+			"product":    map[string]interface{}{"id": "Product:1", "name": "Mock Product 1"},
+		},
+	}
+
+	// A batch is a JSON array of operations and gets an array of results
+	var batch []json.RawMessage
+	if r.Method == http.MethodPost && json.NewDecoder(r.Body).Decode(&batch) == nil {
+		results := make([]interface{}, len(batch))
+		for i := range results {
+			results[i] = result
+		}
+		writeMockJSON(w, http.StatusOK, results)
+		return
+	}
+	writeMockJSON(w, http.StatusOK, result)
+}
+
+// stats returns the requests served so far
+func (m *mockTarget) stats() map[string]interface{} {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	paths := make(map[string]int64, len(m.paths))
+	for route, n := range m.paths {
+		paths[route] = n
+	}
+	return map[string]interface{}{
+		"requests": m.requests.Load(),
+		"failures": m.failures.Load(),
+		"routes":   paths,
+		"config": map[string]interface{}{
+			"mode":      m.config.Mode,
+			"latency":   m.config.Latency.String(),
+			"jitter":    m.config.Jitter.String(),
+			"errorRate": m.config.ErrorRate,
+			"products":  m.config.Products,
+		},
+	}
+}
+
+func writeMockJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// parsePercent reads a percentage with or without the % sign, e.g. "1%" or "0.5"
+func parsePercent(s string) (float64, error) {
+	v, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(s), "%"), 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid percentage %q", s)
+	}
+	return v, nil
+}
+
+// runMockTarget serves the mock platform APIs until interrupted, so the
+// generator's own limits can be measured on this machine without a real
+// platform behind it
+func runMockTarget(args []string) {
+	fs := flag.NewFlagSet("mocktarget", flag.ExitOnError)
+	listen := fs.String("listen", "127.0.0.1:8089", "Address to listen on")
+	mode := fs.String("mode", "all", "Platform APIs to serve: all, spree, medusa or saleor")
+	latency := fs.Duration("latency", 0, "Latency added to every response, e.g. 50ms")
+	jitter := fs.Duration("jitter", 0, "Up to this much more latency, uniformly distributed")
+	errorRate := fs.String("error-rate", "0", "Share of failed responses, e.g. 1%")
+	products := fs.Int("products", 100, "Products and categories in the synthetic catalog")
+	fs.Parse(args)
+
+	rate, err := parsePercent(*errorRate)
+	if err != nil {
+		log.Fatalf("mocktarget: -error-rate: %v", err)
+	}
+	target, err := newMockTarget(MockTargetConfig{Mode: *mode, Latency: *latency, Jitter: *jitter, ErrorRate: rate, Products: *products})
+	if err != nil {
+		log.Fatalf("mocktarget: %v", err)
+	}
+	server := &http.Server{Addr: *listen, Handler: target}
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	done := make(chan struct{})
+	go func() {
+		<-sigChan
+		fmt.Println("\nReceived interrupt signal, shutting down")
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+		close(done)
+	}()
+
+	base := "http://" + *listen
+	fmt.Printf("Mock target on %s: latency %s (+%s jitter), %.2f%% errors, %d products\n", base, *latency, *jitter, rate, target.config.Products)
+	if target.serves("spree") {
+		fmt.Printf("  Spree:  %s/api/v2/storefront/products/\n", base)
+	}
+	if target.serves("medusa") {
+		fmt.Printf("  Medusa: %s/store/products, %s/store/product-categories\n", base, base)
+	}
+	if target.serves("saleor") {
+		fmt.Printf("  Saleor: %s/graphql/\n", base)
+	}
+	fmt.Printf("  Stats:  %s/mock/stats\n", base)
+	if err := server.ListenAndServe(); err != http.ErrServerClosed {
+		log.Fatalf("mocktarget: %v", err)
+	}
+	<-done
+
+	stats := target.stats()
+	fmt.Printf("Served %d requests, %d failed\n", stats["requests"], stats["failures"])
+}
@@ -0,0 +1,418 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func newTestTarget(t *testing.T, config MockTargetConfig) (*mockTarget, *httptest.Server) {
+	t.Helper()
+	target, err := newMockTarget(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(target)
+	t.Cleanup(server.Close)
+	return target, server
+}
+
+func getJSON(t *testing.T, method, url, body string) (int, interface{}) {
+	t.Helper()
+	request, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	var v interface{}
+	json.NewDecoder(response.Body).Decode(&v)
+	return response.StatusCode, v
+}
+
+func TestMockTargetConfig(t *testing.T) {
+	for _, config := range []MockTargetConfig{
+		{Mode: "magento"},
+		{Latency: -time.Millisecond},
+		{ErrorRate: 101},
+		{ErrorRate: -1},
+	} {
+		if _, err := newMockTarget(config); err == nil {
+			t.Errorf("newMockTarget(%+v) succeeded, want an error", config)
+		}
+	}
+	target, err := newMockTarget(MockTargetConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if target.config.Mode != "all" || target.config.Products != 100 {
+		t.Errorf("defaults: mode %q, %d products", target.config.Mode, target.config.Products)
+	}
+}
+
+func TestMockTargetRoutes(t *testing.T) {
+	target, server := newTestTarget(t, MockTargetConfig{Products: 30})
+
+	status, v := getJSON(t, "GET", server.URL+"/api/v2/storefront/products?page=2&per_page=20", "")
+	page := v.(map[string]interface{})
+	if status != 200 || len(page["data"].([]interface{})) != 10 {
+		t.Errorf("spree page 2: status %d, %v", status, page["meta"])
+	}
+	if status, _ := getJSON(t, "GET", server.URL+"/api/v2/storefront/products/7", ""); status != 200 {
+		t.Errorf("spree product: status %d", status)
+	}
+	_, v = getJSON(t, "GET", server.URL+"/store/products?limit=25&offset=20", "")
+	if products := v.(map[string]interface{})["products"].([]interface{}); len(products) != 10 {
+		t.Errorf("medusa offset 20 of 30: %d products", len(products))
+	}
+	_, v = getJSON(t, "GET", server.URL+"/store/product-categories/pcat_3", "")
+	if _, ok := v.(map[string]interface{})["product_category"]; !ok {
+		t.Errorf("medusa category: %v", v)
+	}
+	_, v = getJSON(t, "POST", server.URL+"/graphql/", `{"query": "{ products { edges { node { id } } } }"}`)
+	if _, ok := v.(map[string]interface{})["data"]; !ok {
+		t.Errorf("saleor query: %v", v)
+	}
+	_, v = getJSON(t, "POST", server.URL+"/graphql/", `[{"query": "{ a }"}, {"query": "{ b }"}, {"query": "{ c }"}]`)
+	if results, ok := v.([]interface{}); !ok || len(results) != 3 {
+		t.Errorf("saleor batch of 3: %v", v)
+	}
+	if status, _ := getJSON(t, "GET", server.URL+"/wp-admin", ""); status != http.StatusNotFound {
+		t.Errorf("unknown path: status %d", status)
+	}
+
+	// health and stats requests are not counted
+	getJSON(t, "GET", server.URL+"/health", "")
+	stats := target.stats()
+	if stats["requests"].(int64) != 6 {
+		t.Errorf("stats: %v", stats)
+	}
+	routes := stats["routes"].(map[string]int64)
+	if routes["spree"] != 2 || routes["medusa"] != 2 || routes["saleor"] != 2 {
+		t.Errorf("routes: %v", routes)
+	}
+}
+
+func TestMockTargetMode(t *testing.T) {
+	_, server := newTestTarget(t, MockTargetConfig{Mode: "saleor"})
+	if status, _ := getJSON(t, "GET", server.URL+"/store/products", ""); status != http.StatusNotFound {
+		t.Errorf("medusa route in saleor mode: status %d", status)
+	}
+}
+
+func TestMockTargetErrors(t *testing.T) {
+	target, server := newTestTarget(t, MockTargetConfig{ErrorRate: 100})
+	if status, _ := getJSON(t, "GET", server.URL+"/store/products", ""); status != http.StatusInternalServerError {
+		t.Errorf("REST failure: status %d, want 500", status)
+	}
+	// Saleor fails like the real platform: a 200 with a GraphQL error
+	status, v := getJSON(t, "POST", server.URL+"/graphql/", `{"query": "{ shop { name } }"}`)
+	if errs, _ := v.(map[string]interface{})["errors"].([]interface{}); status != 200 || len(errs) != 1 {
+		t.Errorf("GraphQL failure: status %d, %v", status, v)
+	}
+	if target.failures.Load() != 2 {
+		t.Errorf("%d failures counted, want 2", target.failures.Load())
+	}
+
+	target, server = newTestTarget(t, MockTargetConfig{ErrorRate: 25})
+	for i := 0; i < 400; i++ {
+		getJSON(t, "GET", server.URL+"/api/v2/storefront/products/1", "")
+	}
+	if failures := target.failures.Load(); failures < 60 || failures > 140 {
+		t.Errorf("%d of 400 requests failed at a 25%% error rate", failures)
+	}
+}
+
+func TestMockTargetLatency(t *testing.T) {
+	_, server := newTestTarget(t, MockTargetConfig{Latency: 50 * time.Millisecond, Jitter: 20 * time.Millisecond})
+	start := time.Now()
+	getJSON(t, "GET", server.URL+"/store/products", "")
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond || elapsed > time.Second {
+		t.Errorf("response after %s, want 50-70ms", elapsed)
+	}
+
+	// A client giving up doesn't keep the handler sleeping
+	client := &http.Client{Timeout: 20 * time.Millisecond}
+	_, server = newTestTarget(t, MockTargetConfig{Latency: time.Minute})
+	if _, err := client.Get(server.URL + "/store/products"); err == nil {
+		t.Error("request against a minute of latency did not time out")
+	}
+}
+
+// The tests below run the real runners against the mock target and check
+// their results against what the mock served. They build the runners, so
+// they are skipped with -short.
+
+// buildRunners builds the three runners once per test binary
+var runnerBinaries = map[string]string{}
+
+func runnerBinary(t *testing.T, platform string) string {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("builds and runs the runners")
+	}
+	if binary, ok := runnerBinaries[platform]; ok {
+		return binary
+	}
+	dir, err := os.MkdirTemp("", "wsm-runners")
+	if err != nil {
+		t.Fatal(err)
+	}
+	binary := filepath.Join(dir, platform)
+	if out, err := exec.Command("go", "build", "-o", binary, "../"+platform).CombinedOutput(); err != nil {
+		t.Fatalf("building %s: %v\n%s", platform, err, out)
+	}
+	runnerBinaries[platform] = binary
+	return binary
+}
+
+func TestMain(m *testing.M) {
+	code := m.Run()
+	for _, binary := range runnerBinaries {
+		os.RemoveAll(filepath.Dir(binary))
+	}
+	os.Exit(code)
+}
+
+// runnerConfig returns a config of the platform against the mock at url
+func runnerConfig(platform, url string, stages []map[string]interface{}) map[string]interface{} {
+	test := map[string]interface{}{
+		"MaxWorkers":       20,
+		"MaxQueueSize":     5000,
+		"ReportingSeconds": 60,
+		"LogErrors":        true,
+		"ErrorSampleRate":  1,
+		"RampupStages":     stages,
+	}
+	config := map[string]interface{}{"Test": test}
+	switch platform {
+	case "spree":
+		config["Endpoints"] = map[string]string{
+			"Products":        url + "/api/v2/storefront/products",
+			"SpecificProduct": url + "/api/v2/storefront/products/1",
+		}
+	case "medusa":
+		config["Endpoints"] = map[string]string{
+			"Products":   url + "/store/products",
+			"Categories": url + "/store/product-categories",
+		}
+	case "saleor":
+		config["GraphQLURL"] = url + "/graphql/"
+		config["Headers"] = map[string]string{"Content-Type": "application/json"}
+		config["Queries"] = map[string]string{
+			"Products":        "{ products(first: 20) { edges { node { id name } } } }",
+			"Categories":      "{ categories(first: 20) { edges { node { id name } } } }",
+			"SpecificProduct": "{ product(id: \"UHJvZHVjdDox\") { id name } }",
+		}
+	}
+	return config
+}
+
+func stage(duration time.Duration, rps float64) map[string]interface{} {
+	return map[string]interface{}{"Duration": duration, "TargetRPS": rps}
+}
+
+// startRunner starts a runner writing its results to dir/results.json
+func startRunner(t *testing.T, platform, dir string, config map[string]interface{}) (*exec.Cmd, *bytes.Buffer) {
+	t.Helper()
+	data, err := json.Marshal(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "config.json")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(runnerBinary(t, platform), "-config", path, "-out-dir", dir, "-out-name", "results.json",
+		"-skip-precheck", "-slo-dir", "", "-calibration", "", "-cloud-metadata=false")
+	cmd.Dir = dir
+	output := &bytes.Buffer{}
+	cmd.Stdout, cmd.Stderr = output, output
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	return cmd, output
+}
+
+type runnerResults struct {
+	RunID              string `json:"runId"`
+	TotalRequests      int64  `json:"totalRequests"`
+	SuccessfulRequests int64  `json:"successfulRequests"`
+	FailedRequests     int64  `json:"failedRequests"`
+	MaxDuration        *struct {
+		Limit   string `json:"limit"`
+		Reached bool   `json:"reached"`
+	} `json:"maxDuration"`
+}
+
+// waitRunner waits for the runner and reads its results; a runner whose
+// thresholds failed still wrote them
+func waitRunner(t *testing.T, cmd *exec.Cmd, output *bytes.Buffer, dir string, thresholdsMayFail bool) runnerResults {
+	t.Helper()
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+	var err error
+	select {
+	case err = <-done:
+	case <-time.After(time.Minute):
+		cmd.Process.Kill()
+		t.Fatalf("runner did not exit:\n%s", output)
+	}
+	var exit *exec.ExitError
+	if err != nil && !(thresholdsMayFail && errors.As(err, &exit) && exit.ExitCode() == 99) {
+		t.Fatalf("runner: %v\n%s", err, output)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "results.json"))
+	if err != nil {
+		t.Fatalf("no results: %v\n%s", err, output)
+	}
+	var results runnerResults
+	if err := json.Unmarshal(data, &results); err != nil {
+		t.Fatal(err)
+	}
+	return results
+}
+
+// Every request the mock served is in the results exactly once, and so is
+// every failure it injected, including Saleor's GraphQL errors in a 200
+func TestRunnersMetricsAccuracy(t *testing.T) {
+	for _, platform := range []string{"spree", "medusa", "saleor"} {
+		t.Run(platform, func(t *testing.T) {
+			target, server := newTestTarget(t, MockTargetConfig{Mode: platform, Latency: 2 * time.Millisecond, ErrorRate: 20})
+			dir := t.TempDir()
+			cmd, output := startRunner(t, platform, dir, runnerConfig(platform, server.URL, []map[string]interface{}{stage(3*time.Second, 30)}))
+			results := waitRunner(t, cmd, output, dir, true)
+
+			served, failed := target.requests.Load(), target.failures.Load()
+			if results.TotalRequests != served {
+				t.Errorf("results have %d requests, the mock served %d", results.TotalRequests, served)
+			}
+			if results.FailedRequests != failed {
+				t.Errorf("results have %d failed requests, the mock injected %d failures", results.FailedRequests, failed)
+			}
+			if results.SuccessfulRequests+results.FailedRequests != results.TotalRequests {
+				t.Errorf("%d successful + %d failed != %d total", results.SuccessfulRequests, results.FailedRequests, results.TotalRequests)
+			}
+		})
+	}
+}
+
+// A ramp sends the requests of the linear interpolation between the stages'
+// rates: 10 RPS held for 1s, then 10 to 40 RPS over 3s, 85 requests
+func TestRunnersStageInterpolation(t *testing.T) {
+	want := 10*1.0 + (10+40)/2.0*3
+	for _, platform := range []string{"spree", "medusa", "saleor"} {
+		t.Run(platform, func(t *testing.T) {
+			target, server := newTestTarget(t, MockTargetConfig{Mode: platform})
+			dir := t.TempDir()
+			stages := []map[string]interface{}{stage(time.Second, 10), stage(3*time.Second, 40)}
+			cmd, output := startRunner(t, platform, dir, runnerConfig(platform, server.URL, stages))
+			results := waitRunner(t, cmd, output, dir, false)
+
+			if got := float64(results.TotalRequests); math.Abs(got-want) > want*0.15 {
+				t.Errorf("%v requests, want %v within 15%%\n%s", got, want, output)
+			}
+			if results.TotalRequests != target.requests.Load() {
+				t.Errorf("results have %d requests, the mock served %d", results.TotalRequests, target.requests.Load())
+			}
+		})
+	}
+}
+
+// An interrupted runner stops generating, finishes the requests in flight
+// and still writes complete results
+func TestRunnersGracefulShutdown(t *testing.T) {
+	for _, platform := range []string{"spree", "medusa", "saleor"} {
+		t.Run(platform, func(t *testing.T) {
+			target, server := newTestTarget(t, MockTargetConfig{Mode: platform, Latency: 100 * time.Millisecond})
+			dir := t.TempDir()
+			cmd, output := startRunner(t, platform, dir, runnerConfig(platform, server.URL, []map[string]interface{}{stage(10*time.Minute, 20)}))
+			time.Sleep(2 * time.Second)
+			if err := cmd.Process.Signal(syscall.SIGINT); err != nil {
+				t.Fatal(err)
+			}
+			interrupted := time.Now()
+			results := waitRunner(t, cmd, output, dir, false)
+
+			if elapsed := time.Since(interrupted); elapsed > 15*time.Second {
+				t.Errorf("runner took %s to exit after the interrupt", elapsed)
+			}
+			if results.TotalRequests == 0 || results.FailedRequests != 0 {
+				t.Errorf("%d requests, %d failed after the interrupt\n%s", results.TotalRequests, results.FailedRequests, output)
+			}
+			if results.TotalRequests != target.requests.Load() {
+				t.Errorf("results have %d requests, the mock served %d", results.TotalRequests, target.requests.Load())
+			}
+		})
+	}
+}
+
+// Test.MaxDuration stops a run planned far longer and the results are
+// still written, marked as stopped by it
+func TestRunnersMaxDuration(t *testing.T) {
+	for _, platform := range []string{"spree", "medusa", "saleor"} {
+		t.Run(platform, func(t *testing.T) {
+			target, server := newTestTarget(t, MockTargetConfig{Mode: platform})
+			dir := t.TempDir()
+			config := runnerConfig(platform, server.URL, []map[string]interface{}{stage(10*time.Minute, 20)})
+			config["Test"].(map[string]interface{})["MaxDuration"] = 2 * time.Second
+			start := time.Now()
+			cmd, output := startRunner(t, platform, dir, config)
+			results := waitRunner(t, cmd, output, dir, false)
+
+			if elapsed := time.Since(start); elapsed > 15*time.Second {
+				t.Errorf("runner took %s to exit with a MaxDuration of 2s", elapsed)
+			}
+			if results.MaxDuration == nil || !results.MaxDuration.Reached || results.MaxDuration.Limit != "2s" {
+				t.Errorf("maxDuration %+v, want reached at 2s\n%s", results.MaxDuration, output)
+			}
+			if results.TotalRequests == 0 || results.TotalRequests != target.requests.Load() {
+				t.Errorf("results have %d requests, the mock served %d", results.TotalRequests, target.requests.Load())
+			}
+		})
+	}
+}
+
+// Every request carries the run ID of the results in X-Load-Test-ID
+func TestRunnersLoadTestID(t *testing.T) {
+	for _, platform := range []string{"spree", "medusa", "saleor"} {
+		t.Run(platform, func(t *testing.T) {
+			target, err := newMockTarget(MockTargetConfig{Mode: platform})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var mutex sync.Mutex
+			ids := make(map[string]int64)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mutex.Lock()
+				ids[r.Header.Get("X-Load-Test-ID")]++
+				mutex.Unlock()
+				target.ServeHTTP(w, r)
+			}))
+			t.Cleanup(server.Close)
+			dir := t.TempDir()
+			cmd, output := startRunner(t, platform, dir, runnerConfig(platform, server.URL, []map[string]interface{}{stage(2*time.Second, 10)}))
+			results := waitRunner(t, cmd, output, dir, false)
+
+			mutex.Lock()
+			defer mutex.Unlock()
+			if results.RunID == "" || len(ids) != 1 || ids[results.RunID] != results.TotalRequests {
+				t.Errorf("requests by X-Load-Test-ID %v, want all %d with the run ID %q of the results", ids, results.TotalRequests, results.RunID)
+			}
+		})
+	}
+}
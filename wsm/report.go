@@ -0,0 +1,469 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"html/template"
+	"io"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// reportDocument is one results file laid out as sections, independent of
+// the format it is rendered in. The layout follows the file's structure, so
+// new fields in the runners' results show up without changes here.
+type reportDocument struct {
+	Title    string
+	Source   string
+	Sections []reportSection
+}
+
+// reportSection holds an object's plain fields and the tables of its lists
+type reportSection struct {
+	Title  string
+	Fields [][2]string
+	Tables []reportTable
+}
+
+// reportTable is a list of objects, or an object of objects, one row each
+type reportTable struct {
+	Title   string
+	Columns []string
+	Rows    [][]string
+	Omitted int // rows left out beyond -max-rows
+}
+
+// reportLeads lists the keys shown first for each kind of results
+var reportLeads = map[string][]string{
+	"Platform Comparison":    {"ranking", "platforms", "operations"},
+	"Error-Rate Stress Test": {"comparisonResult", "saleor", "medusa"},
+}
+
+// reportTitle names the kind of results: a compare_results comparison, a
+// stress test or a single platform run
+func reportTitle(raw map[string]interface{}) string {
+	if _, ok := raw["ranking"]; ok {
+		if _, ok := raw["platforms"]; ok {
+			return "Platform Comparison"
+		}
+	}
+	if _, ok := raw["comparisonResult"]; ok {
+		return "Error-Rate Stress Test"
+	}
+	if platform, ok := raw["platform"].(string); ok && platform != "" {
+		return strings.ToUpper(platform[:1]) + platform[1:] + " Load Test"
+	}
+	return "Results"
+}
+
+// buildReport lays out a results file; tables keep at most maxRows rows
+func buildReport(source string, raw map[string]interface{}, maxRows int) reportDocument {
+	doc := reportDocument{Title: reportTitle(raw), Source: source}
+	doc.walk("", raw, reportLeads[doc.Title], maxRows)
+	return doc
+}
+
+// walk adds a section for obj's fields and tables, then one per nested object
+func (d *reportDocument) walk(path string, obj map[string]interface{}, leads []string, maxRows int) {
+	title := path
+	if title == "" {
+		title = "Summary"
+	}
+	section := reportSection{Title: title}
+	var nested []string
+	for _, key := range reportKeys(obj, leads) {
+		value := obj[key]
+		if names, rows := tableRows(value); rows != nil {
+			section.Tables = append(section.Tables, buildTable(key, names, rows, maxRows))
+		} else if child, ok := value.(map[string]interface{}); ok && len(child) > 0 {
+			nested = append(nested, key)
+		} else {
+			section.Fields = append(section.Fields, [2]string{key, formatReportValue(value)})
+		}
+	}
+	if len(section.Fields) > 0 || len(section.Tables) > 0 {
+		d.Sections = append(d.Sections, section)
+	}
+	for _, key := range nested {
+		child := key
+		if path != "" {
+			child = path + " / " + key
+		}
+		d.walk(child, obj[key].(map[string]interface{}), nil, maxRows)
+	}
+}
+
+// reportKeys returns obj's keys, the leads first and the rest sorted
+func reportKeys(obj map[string]interface{}, leads []string) []string {
+	keys := make([]string, 0, len(obj))
+	for _, key := range leads {
+		if _, ok := obj[key]; ok {
+			keys = append(keys, key)
+		}
+	}
+	var rest []string
+	for key := range obj {
+		if !contains(leads, key) {
+			rest = append(rest, key)
+		}
+	}
+	sort.Strings(rest)
+	return append(keys, rest...)
+}
+
+// contains reports whether list holds s
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// tableRows returns the rows of a value that renders as a table: a list of
+// objects, or an object whose values are all objects (named by their keys).
+// rows is nil for any other value.
+func tableRows(value interface{}) (names []string, rows []map[string]interface{}) {
+	switch v := value.(type) {
+	case []interface{}:
+		if len(v) == 0 {
+			return nil, nil
+		}
+		for _, item := range v {
+			row, ok := item.(map[string]interface{})
+			if !ok {
+				return nil, nil
+			}
+			rows = append(rows, row)
+		}
+		return nil, rows
+	case map[string]interface{}:
+		if len(v) == 0 {
+			return nil, nil
+		}
+		for key := range v {
+			if _, ok := v[key].(map[string]interface{}); !ok {
+				return nil, nil
+			}
+			names = append(names, key)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			rows = append(rows, v[name].(map[string]interface{}))
+		}
+		return names, rows
+	}
+	return nil, nil
+}
+
+// tableCells flattens a row to its cells: plain values under their key and
+// the plain values of nested objects as "key.field". Deeper structures are
+// left out of the table.
+func tableCells(row map[string]interface{}) map[string]string {
+	cells := make(map[string]string)
+	for key, value := range row {
+		if nested, ok := value.(map[string]interface{}); ok {
+			for field, v := range nested {
+				if plainValue(v) {
+					cells[key+"."+field] = formatReportValue(v)
+				}
+			}
+		} else if plainValue(value) {
+			cells[key] = formatReportValue(value)
+		}
+	}
+	return cells
+}
+
+// buildTable lays out rows with the union of their cells as columns
+func buildTable(title string, names []string, rows []map[string]interface{}, maxRows int) reportTable {
+	table := reportTable{Title: title}
+	if maxRows > 0 && len(rows) > maxRows {
+		table.Omitted = len(rows) - maxRows
+		rows = rows[:maxRows]
+	}
+	cells := make([]map[string]string, len(rows))
+	seen := make(map[string]bool)
+	for i, row := range rows {
+		cells[i] = tableCells(row)
+		for column := range cells[i] {
+			if !seen[column] {
+				seen[column] = true
+				table.Columns = append(table.Columns, column)
+			}
+		}
+	}
+	// A row's own fields first, then those of its nested objects
+	sort.Slice(table.Columns, func(i, j int) bool {
+		a, b := table.Columns[i], table.Columns[j]
+		if nestedA, nestedB := strings.Contains(a, "."), strings.Contains(b, "."); nestedA != nestedB {
+			return nestedB
+		}
+		return a < b
+	})
+	if names != nil {
+		table.Columns = append([]string{"name"}, table.Columns...)
+	}
+	for i := range rows {
+		var line []string
+		if names != nil {
+			line = append(line, names[i])
+		}
+		for _, column := range table.Columns[len(line):] {
+			line = append(line, cells[i][column])
+		}
+		table.Rows = append(table.Rows, line)
+	}
+	return table
+}
+
+// plainValue reports whether v renders as a single cell: a scalar or a
+// list of scalars
+func plainValue(v interface{}) bool {
+	switch v := v.(type) {
+	case nil, bool, float64, string:
+		return true
+	case []interface{}:
+		for _, item := range v {
+			switch item.(type) {
+			case nil, bool, float64, string:
+			default:
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// maxReportListItems bounds how many items of a list are shown in one cell
+const maxReportListItems = 20
+
+// formatReportValue renders a value for a field or cell
+func formatReportValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "-"
+	case float64:
+		switch {
+		case v == math.Trunc(v) && math.Abs(v) < 1e15:
+			return fmt.Sprintf("%.0f", v)
+		case math.Abs(v) >= 1:
+			return fmt.Sprintf("%.2f", v)
+		default:
+			return fmt.Sprintf("%.4g", v)
+		}
+	case string:
+		return v
+	case []interface{}:
+		if len(v) == 0 {
+			return "-"
+		}
+		if !plainValue(v) {
+			return fmt.Sprintf("[%d entries]", len(v))
+		}
+		var items []string
+		for i, item := range v {
+			if i == maxReportListItems {
+				items = append(items, fmt.Sprintf("... (%d more)", len(v)-i))
+				break
+			}
+			items = append(items, formatReportValue(item))
+		}
+		return strings.Join(items, ", ")
+	case map[string]interface{}:
+		if len(v) == 0 {
+			return "-"
+		}
+		return fmt.Sprintf("{%d fields}", len(v))
+	}
+	return fmt.Sprint(v)
+}
+
+// renderReportConsole writes the documents as plain text tables
+func renderReportConsole(w io.Writer, docs []reportDocument) {
+	for i, doc := range docs {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "%s\n%s\n", doc.Title, strings.Repeat("=", len(doc.Title)))
+		fmt.Fprintf(w, "Source: %s\n", doc.Source)
+		for _, section := range doc.Sections {
+			fmt.Fprintf(w, "\n%s\n%s\n", section.Title, strings.Repeat("-", len(section.Title)))
+			tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+			for _, field := range section.Fields {
+				fmt.Fprintf(tw, "%s\t%s\n", field[0], field[1])
+			}
+			tw.Flush()
+			for _, table := range section.Tables {
+				fmt.Fprintf(w, "\n%s:\n", table.Title)
+				tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+				fmt.Fprintf(tw, "  %s\n", strings.Join(table.Columns, "\t"))
+				for _, row := range table.Rows {
+					fmt.Fprintf(tw, "  %s\n", strings.Join(row, "\t"))
+				}
+				tw.Flush()
+				if table.Omitted > 0 {
+					fmt.Fprintf(w, "  ... %d more rows\n", table.Omitted)
+				}
+			}
+		}
+	}
+}
+
+// markdownCell escapes a value for a Markdown table cell
+func markdownCell(s string) string {
+	return strings.NewReplacer("|", `\|`, "\n", " ").Replace(s)
+}
+
+// renderReportMarkdown writes the documents as Markdown, one top-level
+// heading each
+func renderReportMarkdown(w io.Writer, docs []reportDocument) {
+	for i, doc := range docs {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "# %s\n\nSource: `%s`\n", doc.Title, doc.Source)
+		for _, section := range doc.Sections {
+			fmt.Fprintf(w, "\n## %s\n", section.Title)
+			if len(section.Fields) > 0 {
+				fmt.Fprint(w, "\n| Field | Value |\n|---|---|\n")
+				for _, field := range section.Fields {
+					fmt.Fprintf(w, "| %s | %s |\n", markdownCell(field[0]), markdownCell(field[1]))
+				}
+			}
+			for _, table := range section.Tables {
+				fmt.Fprintf(w, "\n**%s**\n\n", table.Title)
+				cells := make([]string, len(table.Columns))
+				for i, column := range table.Columns {
+					cells[i] = markdownCell(column)
+				}
+				fmt.Fprintf(w, "| %s |\n|%s\n", strings.Join(cells, " | "), strings.Repeat("---|", len(cells)))
+				for _, row := range table.Rows {
+					for i, cell := range row {
+						cells[i] = markdownCell(cell)
+					}
+					fmt.Fprintf(w, "| %s |\n", strings.Join(cells, " | "))
+				}
+				if table.Omitted > 0 {
+					fmt.Fprintf(w, "\n_%d more rows not shown._\n", table.Omitted)
+				}
+			}
+		}
+	}
+}
+
+// reportHTML is the page the documents are rendered into; html/template
+// escapes every value
+var reportHTML = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{range $i, $d := .}}{{if $i}}, {{end}}{{$d.Title}}{{end}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin: 0.5em 0 1.5em; }
+th, td { border: 1px solid #ccc; padding: 0.25em 0.6em; text-align: left; }
+th { background: #f3f3f3; }
+.source { color: #666; }
+</style>
+</head>
+<body>
+{{range .}}<h1>{{.Title}}</h1>
+<p class="source">Source: {{.Source}}</p>
+{{range .Sections}}<h2>{{.Title}}</h2>
+{{if .Fields}}<table>
+{{range .Fields}}<tr><th>{{index . 0}}</th><td>{{index . 1}}</td></tr>
+{{end}}</table>
+{{end}}{{range .Tables}}<h3>{{.Title}}</h3>
+<table>
+<tr>{{range .Columns}}<th>{{.}}</th>{{end}}</tr>
+{{range .Rows}}<tr>{{range .}}<td>{{.}}</td>{{end}}</tr>
+{{end}}</table>
+{{if .Omitted}}<p>{{.Omitted}} more rows not shown.</p>
+{{end}}{{end}}{{end}}{{end}}</body>
+</html>
+`))
+
+// reportFormat picks the format from -format, else from the -out extension
+func reportFormat(format, out string) (string, error) {
+	if format == "" {
+		switch strings.ToLower(filepath.Ext(out)) {
+		case ".md", ".markdown":
+			return "markdown", nil
+		case ".html", ".htm":
+			return "html", nil
+		}
+		return "console", nil
+	}
+	switch format {
+	case "console", "markdown", "html":
+		return format, nil
+	}
+	return "", fmt.Errorf("unknown format %q (want console, markdown or html)", format)
+}
+
+// runReport implements "wsm report"
+func runReport(args []string) {
+	fs := flag.NewFlagSet("report", flag.ExitOnError)
+	format := fs.String("format", "", "Output format: console, markdown or html (default from the -out extension, else console)")
+	out := fs.String("out", "", "Report file (default stdout)")
+	maxRows := fs.Int("max-rows", 50, "Rows shown per table (0 = all)")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: wsm report [flags] results.json...")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	kind, err := reportFormat(*format, *out)
+	if err != nil {
+		log.Fatalf("report: %v", err)
+	}
+
+	var docs []reportDocument
+	for _, path := range fs.Args() {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			log.Fatalf("report: %v", err)
+		}
+		var raw map[string]interface{}
+		if err := json.Unmarshal(data, &raw); err != nil {
+			log.Fatalf("report: parsing %s: %v", path, err)
+		}
+		docs = append(docs, buildReport(filepath.Base(path), raw, *maxRows))
+	}
+
+	w := io.Writer(os.Stdout)
+	if *out != "" {
+		file, err := os.Create(*out)
+		if err != nil {
+			log.Fatalf("report: %v", err)
+		}
+		defer file.Close()
+		w = file
+	}
+	switch kind {
+	case "markdown":
+		renderReportMarkdown(w, docs)
+	case "html":
+		if err := reportHTML.Execute(w, docs); err != nil {
+			log.Fatalf("report: %v", err)
+		}
+	default:
+		renderReportConsole(w, docs)
+	}
+	if *out != "" {
+		fmt.Printf("Report saved to %s\n", *out)
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestBuildReport(t *testing.T) {
+	var raw map[string]interface{}
+	if err := json.Unmarshal([]byte(`{
+		"runId": "r1",
+		"targetRPS": 100,
+		"comparisonResult": {"betterPlatform": "Saleor", "errorRateDifference": 1.234},
+		"saleor": {
+			"errorRate": 0.5,
+			"statusCodes": {"200": 199, "503": 1},
+			"errorClasses": {"5xx": {"count": 1, "rate": 0.5}},
+			"errorSeries": [
+				{"offsetSec": 0, "requests": 100, "errors": 0},
+				{"offsetSec": 10, "requests": 100, "errors": 1, "note": "a|b"},
+				{"offsetSec": 20, "requests": 1, "errors": 0}
+			]
+		},
+		"medusa": {"errorRate": 1.734, "tags": ["x", "<y>"], "empty": {}}
+	}`), &raw); err != nil {
+		t.Fatal(err)
+	}
+	doc := buildReport("stress.json", raw, 2)
+
+	if doc.Title != "Error-Rate Stress Test" {
+		t.Errorf("title %q", doc.Title)
+	}
+	var titles []string
+	for _, s := range doc.Sections {
+		titles = append(titles, s.Title)
+	}
+	if got := strings.Join(titles, ","); got != "Summary,comparisonResult,saleor,saleor / statusCodes,medusa" {
+		t.Errorf("sections %s", got)
+	}
+
+	saleor := doc.Sections[2]
+	if len(saleor.Tables) != 2 || saleor.Tables[0].Title != "errorClasses" || saleor.Tables[1].Title != "errorSeries" {
+		t.Fatalf("saleor tables %+v", saleor.Tables)
+	}
+	classes := saleor.Tables[0]
+	if strings.Join(classes.Columns, ",") != "name,count,rate" || strings.Join(classes.Rows[0], ",") != "5xx,1,0.5" {
+		t.Errorf("errorClasses %+v", classes)
+	}
+	series := saleor.Tables[1]
+	if len(series.Rows) != 2 || series.Omitted != 1 || strings.Join(series.Columns, ",") != "errors,note,offsetSec,requests" {
+		t.Errorf("errorSeries %+v", series)
+	}
+	if medusa := doc.Sections[4]; strings.Join([]string{medusa.Fields[0][1], medusa.Fields[1][1], medusa.Fields[2][1]}, ";") != "-;1.73;x, <y>" {
+		t.Errorf("medusa fields %v", medusa.Fields)
+	}
+
+	var markdown bytes.Buffer
+	renderReportMarkdown(&markdown, []reportDocument{doc})
+	for _, want := range []string{"# Error-Rate Stress Test", "## saleor / statusCodes", "| 503 | 1 |", `| 1 | a\|b | 10 | 100 |`, "_1 more rows not shown._"} {
+		if !strings.Contains(markdown.String(), want) {
+			t.Errorf("Markdown lacks %q:\n%s", want, markdown.String())
+		}
+	}
+
+	var html bytes.Buffer
+	if err := reportHTML.Execute(&html, []reportDocument{doc}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(html.String(), "x, &lt;y&gt;") || strings.Contains(html.String(), "<y>") {
+		t.Error("HTML values are not escaped")
+	}
+}
+
+func TestReportFormat(t *testing.T) {
+	for _, c := range []struct{ format, out, want string }{
+		{"", "", "console"},
+		{"", "report.md", "markdown"},
+		{"", "report.HTML", "html"},
+		{"console", "report.html", "console"},
+	} {
+		if got, err := reportFormat(c.format, c.out); err != nil || got != c.want {
+			t.Errorf("format %q, out %q: %q, %v", c.format, c.out, got, err)
+		}
+	}
+	if _, err := reportFormat("pdf", ""); err == nil {
+		t.Error("unknown format accepted")
+	}
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// stringList is a repeatable string flag
+type stringList []string
+
+func (l *stringList) String() string     { return strings.Join(*l, ", ") }
+func (l *stringList) Set(v string) error { *l = append(*l, v); return nil }
+
+// flagSet reports whether the named flag was given on the command line
+func flagSet(fs *flag.FlagSet, name string) bool {
+	set := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// runCommands executes each command in order with {run_dir} substituted, logging
+// their output to run.log in the run directory. It stops at the first failure.
+func runCommands(commands []string, runDir string) error {
+	logFile, err := os.Create(filepath.Join(runDir, "run.log"))
+	if err != nil {
+		return err
+	}
+	defer logFile.Close()
+
+	for _, command := range commands {
+		command = strings.ReplaceAll(command, "{run_dir}", runDir)
+		fmt.Printf("Running: %s\n", command)
+		fmt.Fprintf(logFile, "$ %s\n", command)
+
+		cmd := exec.Command("sh", "-c", command)
+		cmd.Stdout = logFile
+		cmd.Stderr = logFile
+		cmd.Env = append(os.Environ(), "WSM_RUN_DIR="+runDir)
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("%q failed: %v", command, err)
+		}
+	}
+	return nil
+}
+
+// runComparison invokes compare_results on the platforms found in a run directory
+func runComparison(compareBin, runDir string, results map[string]string) {
+	if len(results) < 2 {
+		return
+	}
+	if _, err := os.Stat(compareBin); err != nil {
+		fmt.Printf("Skipping comparison: %s not found\n", compareBin)
+		return
+	}
+
+	args := []string{"-output", filepath.Join(runDir, "comparison.json")}
+	for platform, path := range results {
+		args = append(args, "-"+platform, path)
+	}
+
+	cmd := exec.Command(compareBin, args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		fmt.Printf("Comparison failed: %v\n", err)
+	}
+}
+
+// executeRun performs one orchestrated run: execute run into a fresh history
+// directory, record the results, compare platforms and update the trend report.
+// Blackout windows open between due and the end of the run are recorded
+// with it.
+func executeRun(store *HistoryStore, label string, run func(runDir string) error, compareBin string, trendRuns int, calendar *BlackoutCalendar, due time.Time) HistoryEntry {
+	start := time.Now()
+	id, runDir, err := store.NewRunDir(start)
+	if err != nil {
+		log.Printf("Cannot start run: %v", err)
+		return HistoryEntry{}
+	}
+
+	fmt.Printf("\n=== Run %s started at %s (results in %s) ===\n", id, start.Format(time.RFC3339), runDir)
+	entry := HistoryEntry{ID: id, Label: label, StartTime: start.Format(time.RFC3339), Dir: runDir, Succeeded: true}
+
+	if err := run(runDir); err != nil {
+		entry.Succeeded = false
+		entry.Error = err.Error()
+		fmt.Printf("Run %s failed: %v\n", id, err)
+	}
+
+	end := time.Now()
+	entry.EndTime = end.Format(time.RFC3339)
+	entry.Blackouts = calendar.gaps(due, end)
+	entry.Results, entry.Summaries = collectResults(runDir)
+	runComparison(compareBin, runDir, entry.Results)
+
+	if err := store.Append(entry); err != nil {
+		fmt.Printf("Error recording run in history: %v\n", err)
+	}
+	if err := store.WriteTrendReport(trendRuns); err != nil {
+		fmt.Printf("Error writing trend report: %v\n", err)
+	}
+
+	fmt.Printf("=== Run %s finished in %s ===\n", id, time.Since(start).Round(time.Second))
+	return entry
+}
+
+// waitBlackout waits until no blackout window is open; it returns false when
+// interrupted while waiting
+func waitBlackout(calendar *BlackoutCalendar) bool {
+	name, end := calendar.openAt(time.Now())
+	if name == "" {
+		return true
+	}
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigChan)
+	fmt.Printf("Blackout window %s is open until %s, delaying the run\n", name, end.Format(time.RFC3339))
+	timer := time.NewTimer(time.Until(end))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-sigChan:
+		fmt.Println("\nReceived interrupt signal, stopping scheduler")
+		return false
+	}
+}
+
+// runSchedule implements `wsm schedule`: run the configured commands on a cron
+// schedule, storing every run in the history store
+func runSchedule(args []string) {
+	fs := flag.NewFlagSet("schedule", flag.ExitOnError)
+	cronExpr := fs.String("cron", "", `Cron expression for when to run, e.g. "0 2 * * *" or @daily`)
+	historyDir := fs.String("history", "history", "History store directory")
+	compareBin := fs.String("compare", "./compare_results", "Path to the compare_results binary")
+	trendRuns := fs.Int("trend-runs", 14, "Number of most recent runs shown in the trend report")
+	label := fs.String("label", "", "Label recorded with each run")
+	once := fs.Bool("once", false, "Run immediately once and exit instead of waiting for the schedule")
+	suiteFile := fs.String("suite", "", "Suite definition file to run (see wsm suite)")
+	blackoutFile := fs.String("blackout", defaultBlackoutFile, "Blackout calendar of maintenance windows to wait out, used if present (empty = none)")
+	var commands stringList
+	fs.Var(&commands, "command", "Command to run (repeatable); {run_dir} and $WSM_RUN_DIR are the run's results directory")
+	fs.Parse(args)
+
+	var run func(runDir string) error
+	switch {
+	case *suiteFile != "" && len(commands) > 0:
+		log.Fatal("schedule: use either -suite or -command, not both")
+	case *suiteFile != "":
+		suite, err := loadSuite(*suiteFile)
+		if err != nil {
+			log.Fatalf("schedule: %v", err)
+		}
+		run = suite.Run
+		if !flagSet(fs, "compare") {
+			*compareBin = suite.Compare
+		}
+	case len(commands) > 0:
+		run = func(runDir string) error { return runCommands(commands, runDir) }
+	default:
+		log.Fatal("schedule: -suite or at least one -command is required")
+	}
+
+	calendar, err := loadBlackoutCalendar(*blackoutFile)
+	if err != nil {
+		log.Fatalf("schedule: invalid blackout calendar: %v", err)
+	}
+
+	store := &HistoryStore{Dir: *historyDir}
+	if *once {
+		due := time.Now()
+		if waitBlackout(calendar) {
+			executeRun(store, *label, run, *compareBin, *trendRuns, calendar, due)
+		}
+		return
+	}
+
+	if *cronExpr == "" {
+		log.Fatal("schedule: -cron is required unless -once is given")
+	}
+	schedule, err := ParseCron(*cronExpr)
+	if err != nil {
+		log.Fatalf("schedule: invalid -cron: %v", err)
+	}
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+
+	for {
+		next := schedule.Next(time.Now())
+		if next.IsZero() {
+			log.Fatalf("schedule: %q never fires", *cronExpr)
+		}
+		fmt.Printf("Next run at %s (in %s)\n", next.Format(time.RFC3339), time.Until(next).Round(time.Second))
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-timer.C:
+			if !waitBlackout(calendar) {
+				return
+			}
+			executeRun(store, *label, run, *compareBin, *trendRuns, calendar, next)
+		case <-sigChan:
+			timer.Stop()
+			fmt.Println("\nReceived interrupt signal, stopping scheduler")
+			return
+		}
+	}
+}
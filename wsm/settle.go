@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// SettleConfig controls the health-check probe loop run after a test so the
+// target has drained its backlog before the next test starts
+type SettleConfig struct {
+	Samples   int     `json:"samples"`   // probe requests per measurement (default 5)
+	Tolerance float64 `json:"tolerance"` // settled when p50 <= baseline * tolerance (default 1.2)
+	Interval  string  `json:"interval"`  // pause between measurements (default "5s")
+	Timeout   string  `json:"timeout"`   // give up waiting after this (default "10m")
+}
+
+// settleDefaults fills in unset fields and validates the durations
+func (c *SettleConfig) settleDefaults() error {
+	if c.Samples <= 0 {
+		c.Samples = 5
+	}
+	if c.Tolerance <= 0 {
+		c.Tolerance = 1.2
+	}
+	if c.Interval == "" {
+		c.Interval = "5s"
+	}
+	if c.Timeout == "" {
+		c.Timeout = "10m"
+	}
+	if _, err := time.ParseDuration(c.Interval); err != nil {
+		return fmt.Errorf("settle: invalid interval %q", c.Interval)
+	}
+	if _, err := time.ParseDuration(c.Timeout); err != nil {
+		return fmt.Errorf("settle: invalid timeout %q", c.Timeout)
+	}
+	return nil
+}
+
+var probeClient = &http.Client{Timeout: 30 * time.Second}
+
+// probeP50 sends samples sequential GET requests to url and returns the median
+// latency. Failed requests and non-2xx/3xx responses are an error.
+func probeP50(url string, samples int) (time.Duration, error) {
+	durations := make([]time.Duration, 0, samples)
+	for i := 0; i < samples; i++ {
+		start := time.Now()
+		resp, err := probeClient.Get(url)
+		if err != nil {
+			return 0, err
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode >= 400 {
+			return 0, fmt.Errorf("status %d", resp.StatusCode)
+		}
+		durations = append(durations, time.Since(start))
+	}
+
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	return durations[len(durations)/2], nil
+}
+
+// waitForSettle probes url until its p50 is back within tolerance of baseline
+// or the timeout passes. It returns false if the target did not settle.
+func waitForSettle(platform, url string, baseline time.Duration, cfg SettleConfig) bool {
+	interval, _ := time.ParseDuration(cfg.Interval)
+	timeout, _ := time.ParseDuration(cfg.Timeout)
+	limit := time.Duration(float64(baseline) * cfg.Tolerance)
+	deadline := time.Now().Add(timeout)
+
+	fmt.Printf("[%s] Waiting for p50 to return to baseline %s (limit %s)\n", platform, baseline.Round(time.Millisecond), limit.Round(time.Millisecond))
+	for {
+		p50, err := probeP50(url, cfg.Samples)
+		switch {
+		case err != nil:
+			fmt.Printf("[%s] Health probe failed: %v\n", platform, err)
+		case p50 <= limit:
+			fmt.Printf("[%s] Settled: p50 %s\n", platform, p50.Round(time.Millisecond))
+			return true
+		default:
+			fmt.Printf("[%s] Not settled yet: p50 %s\n", platform, p50.Round(time.Millisecond))
+		}
+
+		if time.Now().Add(interval).After(deadline) {
+			fmt.Printf("[%s] Warning: target did not settle within %s, continuing\n", platform, cfg.Timeout)
+			return false
+		}
+		time.Sleep(interval)
+	}
+}
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// SLA is a set of clauses the results are checked against
+type SLA struct {
+	Name     string      `json:"name"`
+	Audience string      `json:"audience"`
+	Clauses  []SLAClause `json:"clauses"`
+}
+
+// SLAClause is one commitment, e.g. "95% of product requests under 500ms".
+// Metric is one of p50, p90, p95, p99 (milliseconds), errorRate, successRate
+// (percent), actualRPS or totalRequests. Operation restricts it to one operation.
+type SLAClause struct {
+	Name      string   `json:"name"`
+	Metric    string   `json:"metric"`
+	Operation string   `json:"operation,omitempty"`
+	Platform  string   `json:"platform,omitempty"`
+	Min       *float64 `json:"min,omitempty"`
+	Max       *float64 `json:"max,omitempty"`
+}
+
+// slaVerdict is the outcome of one clause for one results file
+type slaVerdict struct {
+	Clause   SLAClause
+	Measured float64
+	Found    bool
+	Met      bool
+}
+
+// loadSLA reads and validates an SLA definition file
+func loadSLA(path string) (*SLA, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var sla SLA
+	if err := json.Unmarshal(data, &sla); err != nil {
+		return nil, fmt.Errorf("parsing %s: %v", path, err)
+	}
+	if len(sla.Clauses) == 0 {
+		return nil, fmt.Errorf("%s defines no clauses", path)
+	}
+	for i, clause := range sla.Clauses {
+		if _, ok := slaMetricUnits[clause.Metric]; !ok {
+			return nil, fmt.Errorf("clause %d: unknown metric %q", i+1, clause.Metric)
+		}
+		if clause.Min == nil && clause.Max == nil {
+			return nil, fmt.Errorf("clause %d: needs min or max", i+1)
+		}
+	}
+	return &sla, nil
+}
+
+// slaMetricUnits maps each supported metric to the unit it is reported in
+var slaMetricUnits = map[string]string{
+	"p50": "ms", "p90": "ms", "p95": "ms", "p99": "ms",
+	"errorRate": "%", "successRate": "%",
+	"actualRPS": "req/s", "totalRequests": "requests",
+}
+
+// slaMetricDescriptions phrases each metric for readers outside engineering
+var slaMetricDescriptions = map[string]string{
+	"p50":           "Typical response time (half of requests are faster)",
+	"p90":           "Response time 9 in 10 requests beat",
+	"p95":           "Response time 19 in 20 requests beat",
+	"p99":           "Response time 99 in 100 requests beat",
+	"errorRate":     "Share of requests that failed",
+	"successRate":   "Share of requests that succeeded",
+	"actualRPS":     "Requests handled per second",
+	"totalRequests": "Requests handled in the test",
+}
+
+// measure reads a clause's metric from a results file, scoped to an operation if set
+func measure(raw map[string]interface{}, clause SLAClause) (float64, bool) {
+	scope := raw
+	if clause.Operation != "" {
+		operations, _ := raw["operations"].(map[string]interface{})
+		op, ok := operations[clause.Operation].(map[string]interface{})
+		if !ok {
+			return 0, false
+		}
+		scope = op
+	}
+
+	switch clause.Metric {
+	case "p50", "p90", "p95", "p99":
+		latency, ok := scope["latency"].(map[string]interface{})
+		if !ok || latency[clause.Metric] == nil {
+			return 0, false
+		}
+		return looseMillis(latency[clause.Metric]), true
+	case "errorRate", "successRate":
+		total := looseNumber(scope["totalRequests"])
+		if total == 0 {
+			total = looseNumber(scope["requests"])
+		}
+		if total == 0 {
+			return 0, false
+		}
+		errorRate := looseNumber(scope["failedRequests"]) / total * 100
+		if clause.Metric == "successRate" {
+			return 100 - errorRate, true
+		}
+		return errorRate, true
+	case "totalRequests":
+		if v, ok := scope["totalRequests"]; ok {
+			return looseNumber(v), true
+		}
+		if v, ok := scope["requests"]; ok {
+			return looseNumber(v), true
+		}
+	case "actualRPS":
+		if v, ok := scope["actualRPS"]; ok {
+			return looseNumber(v), true
+		}
+	}
+	return 0, false
+}
+
+// evaluateSLA checks every clause that applies to the platform against a results file
+func evaluateSLA(sla *SLA, platform string, raw map[string]interface{}) []slaVerdict {
+	var verdicts []slaVerdict
+	for _, clause := range sla.Clauses {
+		if clause.Platform != "" && !strings.EqualFold(clause.Platform, platform) {
+			continue
+		}
+		v := slaVerdict{Clause: clause}
+		v.Measured, v.Found = measure(raw, clause)
+		v.Met = v.Found &&
+			(clause.Min == nil || v.Measured >= *clause.Min) &&
+			(clause.Max == nil || v.Measured <= *clause.Max)
+		verdicts = append(verdicts, v)
+	}
+	return verdicts
+}
+
+// formatSLAValue prints a value with its metric's unit
+func formatSLAValue(metric string, v float64) string {
+	switch unit := slaMetricUnits[metric]; unit {
+	case "ms":
+		return fmt.Sprintf("%.0f ms", v)
+	case "%":
+		return fmt.Sprintf("%.2f%%", v)
+	case "requests":
+		return fmt.Sprintf("%.0f %s", v, unit)
+	default:
+		return fmt.Sprintf("%.1f %s", v, unit)
+	}
+}
+
+// target describes the clause's bound in words
+func (c SLAClause) target() string {
+	switch {
+	case c.Min != nil && c.Max != nil:
+		return fmt.Sprintf("between %s and %s", formatSLAValue(c.Metric, *c.Min), formatSLAValue(c.Metric, *c.Max))
+	case c.Max != nil:
+		return "at most " + formatSLAValue(c.Metric, *c.Max)
+	default:
+		return "at least " + formatSLAValue(c.Metric, *c.Min)
+	}
+}
+
+// slaResult is one evaluated results file
+type slaResult struct {
+	Platform  string
+	Path      string
+	Verdicts  []slaVerdict
+	StartTime string
+}
+
+// renderSLAMarkdown writes the one-page verdict
+func renderSLAMarkdown(sla *SLA, results []slaResult, generated time.Time) string {
+	var b strings.Builder
+
+	title := sla.Name
+	if title == "" {
+		title = "Service Level Report"
+	}
+	fmt.Fprintf(&b, "# %s\n\n", title)
+	if sla.Audience != "" {
+		fmt.Fprintf(&b, "Prepared for: %s  \n", sla.Audience)
+	}
+	fmt.Fprintf(&b, "Generated: %s\n\n", generated.Format("2 January 2006 15:04 MST"))
+
+	total, met := 0, 0
+	for _, r := range results {
+		for _, v := range r.Verdicts {
+			total++
+			if v.Met {
+				met++
+			}
+		}
+	}
+	if met == total {
+		fmt.Fprintf(&b, "## Verdict: SLA met\n\nAll %d commitments were met.\n\n", total)
+	} else {
+		fmt.Fprintf(&b, "## Verdict: SLA not met\n\n%d of %d commitments were not met.\n\n", total-met, total)
+	}
+
+	for _, r := range results {
+		fmt.Fprintf(&b, "### %s\n\n", strings.ToUpper(r.Platform[:1])+r.Platform[1:])
+		if r.StartTime != "" {
+			fmt.Fprintf(&b, "Test run: %s (%s)\n\n", r.StartTime, filepath.Base(r.Path))
+		} else {
+			fmt.Fprintf(&b, "Test run: %s\n\n", filepath.Base(r.Path))
+		}
+		fmt.Fprintln(&b, "| Commitment | What it measures | Target | Measured | Result |")
+		fmt.Fprintln(&b, "|---|---|---|---|---|")
+		for _, v := range r.Verdicts {
+			name := v.Clause.Name
+			if name == "" {
+				name = v.Clause.Metric
+			}
+			what := slaMetricDescriptions[v.Clause.Metric]
+			if v.Clause.Operation != "" {
+				what += " for " + v.Clause.Operation
+			}
+			measured, result := "not measured", "**Not met**"
+			if v.Found {
+				measured = formatSLAValue(v.Clause.Metric, v.Measured)
+			}
+			if v.Met {
+				result = "Met"
+			}
+			fmt.Fprintf(&b, "| %s | %s | %s | %s | %s |\n", name, what, v.Clause.target(), measured, result)
+		}
+		fmt.Fprintln(&b)
+	}
+
+	return b.String()
+}
+
+// platformOf guesses the platform from the results' "platform" field or the file name
+func platformOf(path string, raw map[string]interface{}) string {
+	if p, ok := raw["platform"].(string); ok && p != "" {
+		return strings.ToLower(p)
+	}
+	base := strings.ToLower(filepath.Base(path))
+	for _, platform := range knownPlatforms {
+		if strings.HasPrefix(base, platform) {
+			return platform
+		}
+	}
+	return strings.TrimSuffix(base, filepath.Ext(base))
+}
+
+// runSLA implements "wsm sla"
+func runSLA(args []string) {
+	fs := flag.NewFlagSet("sla", flag.ExitOnError)
+	slaPath := fs.String("sla", "", "SLA definition file")
+	out := fs.String("out", "sla_report.md", "Report file; a .pdf extension converts the Markdown with pandoc")
+	var resultFiles stringList
+	fs.Var(&resultFiles, "results", "Runner results file (repeatable)")
+	fs.Parse(args)
+
+	if *slaPath == "" || len(resultFiles) == 0 {
+		log.Fatal("sla: -sla and at least one -results are required")
+	}
+	sla, err := loadSLA(*slaPath)
+	if err != nil {
+		log.Fatalf("sla: %v", err)
+	}
+
+	var results []slaResult
+	for _, path := range resultFiles {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			log.Fatalf("sla: %v", err)
+		}
+		var raw map[string]interface{}
+		if err := json.Unmarshal(data, &raw); err != nil {
+			log.Fatalf("sla: parsing %s: %v", path, err)
+		}
+		platform := platformOf(path, raw)
+		startTime, _ := raw["testStartTime"].(string)
+		results = append(results, slaResult{
+			Platform:  platform,
+			Path:      path,
+			Verdicts:  evaluateSLA(sla, platform, raw),
+			StartTime: startTime,
+		})
+	}
+
+	markdown := renderSLAMarkdown(sla, results, time.Now())
+	if strings.EqualFold(filepath.Ext(*out), ".pdf") {
+		cmd := exec.Command("pandoc", "--from", "markdown", "-o", *out)
+		cmd.Stdin = strings.NewReader(markdown)
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			log.Fatalf("sla: converting to PDF with pandoc: %v: %s", err, strings.TrimSpace(stderr.String()))
+		}
+	} else if err := os.WriteFile(*out, []byte(markdown), 0644); err != nil {
+		log.Fatalf("sla: %v", err)
+	}
+
+	fmt.Print(markdown)
+	fmt.Printf("SLA report saved to %s\n", *out)
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Suite execution modes
+const (
+	suiteSequential = "sequential"
+	suiteParallel   = "parallel"
+)
+
+// SuiteTest is one platform test in a suite
+type SuiteTest struct {
+	Platform string   `json:"platform"`
+	Binary   string   `json:"binary,omitempty"`  // defaults to ./<platform>_benchmark
+	Config   string   `json:"config"`            // config file passed with -config
+	Args     []string `json:"args,omitempty"`    // extra arguments for the runner
+	Timeout  string   `json:"timeout,omitempty"` // e.g. "40m"; the test is killed after this
+
+	// HealthURL is probed before the test to record a baseline p50 and after it
+	// until the target's p50 returns to that baseline (requires settle)
+	HealthURL string `json:"healthUrl,omitempty"`
+}
+
+// Suite describes a set of platform tests and how to run them
+type Suite struct {
+	Name    string      `json:"name"`
+	Mode    string      `json:"mode"`              // sequential (default) or parallel
+	Compare string      `json:"compare,omitempty"` // compare_results binary, defaults to ./compare_results
+	Tests   []SuiteTest `json:"tests"`
+
+	// Cooldown is the pause between sequential tests, e.g. "2m"
+	Cooldown string `json:"cooldown,omitempty"`
+	// Settle enables the health-check probe loop after each test with a HealthURL
+	Settle *SettleConfig `json:"settle,omitempty"`
+}
+
+// loadSuite reads and validates a suite definition file
+func loadSuite(path string) (*Suite, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var suite Suite
+	if err := json.Unmarshal(data, &suite); err != nil {
+		return nil, fmt.Errorf("parsing %s: %v", path, err)
+	}
+
+	if suite.Mode == "" {
+		suite.Mode = suiteSequential
+	}
+	if suite.Mode != suiteSequential && suite.Mode != suiteParallel {
+		return nil, fmt.Errorf("unknown mode %q (use %s or %s)", suite.Mode, suiteSequential, suiteParallel)
+	}
+	if suite.Compare == "" {
+		suite.Compare = "./compare_results"
+	}
+	if len(suite.Tests) == 0 {
+		return nil, fmt.Errorf("suite %s has no tests", path)
+	}
+
+	if suite.Cooldown != "" {
+		if _, err := time.ParseDuration(suite.Cooldown); err != nil {
+			return nil, fmt.Errorf("invalid cooldown %q", suite.Cooldown)
+		}
+	}
+	if suite.Settle != nil {
+		if err := suite.Settle.settleDefaults(); err != nil {
+			return nil, err
+		}
+	}
+
+	seen := make(map[string]bool)
+	for i := range suite.Tests {
+		test := &suite.Tests[i]
+		test.Platform = strings.ToLower(test.Platform)
+		if test.Platform == "" {
+			return nil, fmt.Errorf("test %d has no platform", i+1)
+		}
+		if seen[test.Platform] {
+			return nil, fmt.Errorf("platform %s is listed twice; each platform writes %s_latest.json", test.Platform, test.Platform)
+		}
+		seen[test.Platform] = true
+		if test.Binary == "" {
+			test.Binary = "./" + test.Platform + "_benchmark"
+		}
+		if test.Timeout != "" {
+			if _, err := time.ParseDuration(test.Timeout); err != nil {
+				return nil, fmt.Errorf("test %s: invalid timeout %q", test.Platform, test.Timeout)
+			}
+		}
+	}
+	return &suite, nil
+}
+
+// runTest runs a single platform test, writing its output to <platform>_output.log
+func runTest(test SuiteTest, resultsDir string) error {
+	logFile, err := os.Create(filepath.Join(resultsDir, test.Platform+"_output.log"))
+	if err != nil {
+		return err
+	}
+	defer logFile.Close()
+
+	ctx := context.Background()
+	if test.Timeout != "" {
+		timeout, _ := time.ParseDuration(test.Timeout)
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	args := []string{"-out-dir", resultsDir}
+	if test.Config != "" {
+		args = append(args, "-config", test.Config)
+	}
+	args = append(args, test.Args...)
+
+	cmd := exec.CommandContext(ctx, test.Binary, args...)
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	// Interrupt rather than kill on timeout so the runner still writes its results
+	cmd.Cancel = func() error { return cmd.Process.Signal(os.Interrupt) }
+	cmd.WaitDelay = time.Minute
+
+	start := time.Now()
+	fmt.Printf("[%s] Starting %s %s\n", test.Platform, test.Binary, strings.Join(args, " "))
+	err = cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		err = fmt.Errorf("timed out after %s", test.Timeout)
+	}
+	if err != nil {
+		fmt.Printf("[%s] Failed after %s: %v\n", test.Platform, time.Since(start).Round(time.Second), err)
+		return fmt.Errorf("%s: %v", test.Platform, err)
+	}
+	fmt.Printf("[%s] Completed in %s\n", test.Platform, time.Since(start).Round(time.Second))
+	return nil
+}
+
+// cooldown pauses for the configured time between sequential tests
+func (s *Suite) cooldown() {
+	if s.Cooldown == "" {
+		return
+	}
+	cooldown, _ := time.ParseDuration(s.Cooldown)
+	fmt.Printf("Cooling down for %s\n", cooldown)
+	time.Sleep(cooldown)
+}
+
+// Run executes the suite's tests into resultsDir and returns the combined error
+// of any failed tests. Remaining tests still run when one fails.
+func (s *Suite) Run(resultsDir string) error {
+	if err := os.MkdirAll(resultsDir, 0755); err != nil {
+		return err
+	}
+
+	fmt.Printf("Running suite %q: %d tests, %s\n", s.Name, len(s.Tests), s.Mode)
+
+	errs := make([]error, len(s.Tests))
+	if s.Mode == suiteParallel {
+		var wg sync.WaitGroup
+		for i, test := range s.Tests {
+			wg.Add(1)
+			go func(i int, test SuiteTest) {
+				defer wg.Done()
+				errs[i] = runTest(test, resultsDir)
+			}(i, test)
+		}
+		wg.Wait()
+	} else {
+		for i, test := range s.Tests {
+			var baseline time.Duration
+			settle := s.Settle != nil && test.HealthURL != ""
+			if settle {
+				p50, err := probeP50(test.HealthURL, s.Settle.Samples)
+				if err != nil {
+					fmt.Printf("[%s] Baseline probe failed, skipping settle check: %v\n", test.Platform, err)
+					settle = false
+				} else {
+					baseline = p50
+					fmt.Printf("[%s] Baseline p50: %s\n", test.Platform, baseline.Round(time.Millisecond))
+				}
+			}
+
+			errs[i] = runTest(test, resultsDir)
+
+			if i == len(s.Tests)-1 {
+				break
+			}
+			s.cooldown()
+			if settle {
+				waitForSettle(test.Platform, test.HealthURL, baseline, *s.Settle)
+			}
+		}
+	}
+
+	var failed []string
+	for _, err := range errs {
+		if err != nil {
+			failed = append(failed, err.Error())
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%d of %d tests failed: %s", len(failed), len(s.Tests), strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// runSuite implements `wsm suite`: run a suite definition and compare the results
+func runSuite(args []string) {
+	fs := flag.NewFlagSet("suite", flag.ExitOnError)
+	suiteFile := fs.String("file", "suite.json", "Suite definition file")
+	outDir := fs.String("out-dir", "", "Results directory (default suite_results_<timestamp>)")
+	compareBin := fs.String("compare", "", "Path to the compare_results binary (overrides the suite file)")
+	fs.Parse(args)
+
+	suite, err := loadSuite(*suiteFile)
+	if err != nil {
+		log.Fatalf("suite: %v", err)
+	}
+	if *compareBin != "" {
+		suite.Compare = *compareBin
+	}
+
+	resultsDir := *outDir
+	if resultsDir == "" {
+		resultsDir = "suite_results_" + time.Now().Format("20060102_150405")
+	}
+
+	runErr := suite.Run(resultsDir)
+	results, _ := collectResults(resultsDir)
+	runComparison(suite.Compare, resultsDir, results)
+
+	fmt.Printf("Results saved to: %s\n", resultsDir)
+	if runErr != nil {
+		log.Fatalf("suite: %v", runErr)
+	}
+}
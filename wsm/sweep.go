@@ -0,0 +1,565 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// sweepParams are the named parameters wsm sweep varies; any other name is
+// a dotted path into the config, e.g. Test.MaxQueueSize
+var sweepParams = map[string]string{
+	"workers":   "Test.MaxWorkers",
+	"rps":       "a plateau at the value for the whole test",
+	"payload":   "Test.Upload.SizeBytes (upload file size in bytes)",
+	"page-size": "per_page (Spree), limit (Medusa) or first (Saleor) of the product list",
+}
+
+// defaultPageSizes are swept when -values is not given for page-size
+const defaultPageSizes = "10,50,100,250"
+
+// sweepListOperation is the product list operation of every runner, the
+// one a page size sweep changes
+const sweepListOperation = "products"
+
+// SweepPoint is the outcome of the test of one platform at one value
+type SweepPoint struct {
+	Platform string      `json:"platform"`
+	Value    string      `json:"value"`
+	Results  string      `json:"results,omitempty"`
+	Summary  *RunSummary `json:"summary,omitempty"`
+	// Products is the product list operation alone, for page size sweeps
+	Products *RunSummary `json:"products,omitempty"`
+	Error    string      `json:"error,omitempty"`
+}
+
+// SweepReport is the outcome of wsm sweep
+type SweepReport struct {
+	Platforms []string          `json:"platforms"`
+	Configs   map[string]string `json:"configs"`
+	Param     string            `json:"param"`
+	Duration  string            `json:"duration"`
+	StartTime string            `json:"startTime"`
+	EndTime   string            `json:"endTime"`
+	Points    []SweepPoint      `json:"points"`
+}
+
+// saleorFirst is the page size argument of the Saleor product list query
+var saleorFirst = regexp.MustCompile(`(products\s*\([^)]*?\bfirst\s*:\s*)\d+`)
+
+// setConfigPath sets the value at a dotted path of a decoded config; the
+// objects on the way must exist, so a typo is an error rather than a new field
+func setConfigPath(config map[string]interface{}, path string, value interface{}) error {
+	parts := strings.Split(path, ".")
+	object := config
+	for i, part := range parts[:len(parts)-1] {
+		next, ok := object[part].(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("config has no object %s", strings.Join(parts[:i+1], "."))
+		}
+		object = next
+	}
+	last := parts[len(parts)-1]
+	if _, ok := object[last]; !ok {
+		return fmt.Errorf("config has no field %s", path)
+	}
+	object[last] = value
+	return nil
+}
+
+// configPath returns the value at a dotted path of a decoded config
+func configPath(config map[string]interface{}, path string) (interface{}, bool) {
+	var v interface{} = config
+	for _, part := range strings.Split(path, ".") {
+		object, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if v, ok = object[part]; !ok {
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// withQueryParam sets a query parameter of a URL
+func withQueryParam(rawURL, name, value string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	query := u.Query()
+	query.Set(name, value)
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// applySweepValue sets the swept parameter in a copy of the base config.
+// Numeric values are written as numbers, anything else as a string.
+func applySweepValue(config map[string]interface{}, platform, param, value string, duration time.Duration) error {
+	var typed interface{} = value
+	if _, err := strconv.ParseFloat(value, 64); err == nil {
+		typed = json.Number(value)
+	}
+
+	switch param {
+	case "workers":
+		return setConfigPath(config, "Test.MaxWorkers", typed)
+	case "rps":
+		rps, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || rps <= 0 {
+			return fmt.Errorf("rps value %q is not a positive whole number", value)
+		}
+		test, _ := config["Test"].(map[string]interface{})
+		if test == nil {
+			return fmt.Errorf("config has no object Test")
+		}
+		test["AdaptiveRPS"] = false
+		test["AdaptiveStages"] = false
+		delete(test, "ProfileCSV")
+		test["RampupStages"] = []interface{}{map[string]interface{}{
+			"Duration":    json.Number(strconv.FormatInt(int64(duration), 10)),
+			"TargetRPS":   json.Number(value),
+			"Description": "sweep plateau at " + value + " RPS",
+		}}
+		return nil
+	case "payload":
+		if percent, _ := configPath(config, "Test.Upload.Percent"); looseJSONNumber(percent) <= 0 {
+			return fmt.Errorf("payload sweeps need uploads: set Test.Upload.URL and Test.Upload.Percent in the config")
+		}
+		if err := setConfigPath(config, "Test.Upload.SizeBytes", typed); err != nil {
+			return err
+		}
+		// A fixed size, not a range starting at the value
+		return setConfigPath(config, "Test.Upload.MaxSizeBytes", typed)
+	case "page-size":
+		switch platform {
+		case "saleor":
+			query, _ := configPath(config, "Queries.Products")
+			text, _ := query.(string)
+			if !saleorFirst.MatchString(text) {
+				return fmt.Errorf("Queries.Products has no products(first: N) to set the page size of")
+			}
+			return setConfigPath(config, "Queries.Products", saleorFirst.ReplaceAllString(text, "${1}"+value))
+		case "spree", "medusa":
+			name := "per_page"
+			if platform == "medusa" {
+				name = "limit"
+			}
+			products, _ := configPath(config, "Endpoints.Products")
+			text, _ := products.(string)
+			if text == "" {
+				return fmt.Errorf("config has no Endpoints.Products")
+			}
+			withPage, err := withQueryParam(text, name, value)
+			if err != nil {
+				return err
+			}
+			return setConfigPath(config, "Endpoints.Products", withPage)
+		}
+		return fmt.Errorf("page-size sweeps are not supported for %s", platform)
+	}
+	return setConfigPath(config, param, typed)
+}
+
+// looseJSONNumber converts a decoded json.Number to a float, returning 0 if it can't
+func looseJSONNumber(v interface{}) float64 {
+	if n, ok := v.(json.Number); ok {
+		f, _ := n.Float64()
+		return f
+	}
+	return looseNumber(v)
+}
+
+// operationSummary reads one operation's metrics from a results file
+func operationSummary(path, operation string) (*RunSummary, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	requests, ok := measure(raw, SLAClause{Metric: "totalRequests", Operation: operation})
+	if !ok {
+		return nil, fmt.Errorf("no %s operation in %s", operation, path)
+	}
+	s := &RunSummary{TotalRequests: int64(requests)}
+	s.ErrorRate, _ = measure(raw, SLAClause{Metric: "errorRate", Operation: operation})
+	s.P50Ms, _ = measure(raw, SLAClause{Metric: "p50", Operation: operation})
+	s.P95Ms, _ = measure(raw, SLAClause{Metric: "p95", Operation: operation})
+	s.P99Ms, _ = measure(raw, SLAClause{Metric: "p99", Operation: operation})
+	return s, nil
+}
+
+// sweepMillis formats a latency; a results file without latency for an
+// operation (no sampled durations) leaves it at 0
+func sweepMillis(v float64) string {
+	if v == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.1fms", v)
+}
+
+// sweepLatency returns the summary a sweep's latency curve is drawn from:
+// the product list operation in page size sweeps, the whole test otherwise
+func sweepLatency(point SweepPoint) *RunSummary {
+	if point.Products != nil {
+		return point.Products
+	}
+	return point.Summary
+}
+
+// printSweepReport prints the results per platform and value, with a bar of
+// the p95 latency (p99 when the results have no p95) as a rough curve
+func printSweepReport(report *SweepReport) {
+	curve, curveName := func(s *RunSummary) float64 { return s.P95Ms }, "p95"
+	maxLatency := 0.0
+	for _, point := range report.Points {
+		if s := sweepLatency(point); s != nil {
+			maxLatency = math.Max(maxLatency, s.P95Ms)
+		}
+	}
+	if maxLatency == 0 {
+		curve, curveName = func(s *RunSummary) float64 { return s.P99Ms }, "p99"
+		for _, point := range report.Points {
+			if s := sweepLatency(point); s != nil {
+				maxLatency = math.Max(maxLatency, s.P99Ms)
+			}
+		}
+	}
+
+	for _, platform := range report.Platforms {
+		fmt.Printf("\nSweep of %s for %s (%s per value)\n", report.Param, platform, report.Duration)
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		if report.Param == "page-size" {
+			fmt.Fprintf(w, "Page Size\tActual RPS\tError Rate\tproducts p50\tproducts p95\tproducts p99\tp50 per item\t%s curve\n", curveName)
+		} else {
+			fmt.Fprintf(w, "Value\tActual RPS\tError Rate\tp50\tp95\tp99\t%s curve\n", curveName)
+		}
+		for _, point := range report.Points {
+			if point.Platform != platform {
+				continue
+			}
+			if point.Summary == nil {
+				fmt.Fprintf(w, "%s\tfailed: %s\t\t\t\t\t\n", point.Value, point.Error)
+				continue
+			}
+			s := sweepLatency(point)
+			bar := ""
+			if maxLatency > 0 {
+				bar = strings.Repeat("#", int(curve(s)/maxLatency*30+0.5))
+			}
+			if report.Param == "page-size" {
+				perItem := "-"
+				if size, _ := strconv.ParseFloat(point.Value, 64); size > 0 && s.P50Ms > 0 {
+					perItem = fmt.Sprintf("%.3fms", s.P50Ms/size)
+				}
+				fmt.Fprintf(w, "%s\t%.2f\t%.2f%%\t%s\t%s\t%s\t%s\t%s\n", point.Value, point.Summary.ActualRPS, s.ErrorRate,
+					sweepMillis(s.P50Ms), sweepMillis(s.P95Ms), sweepMillis(s.P99Ms), perItem, bar)
+			} else {
+				fmt.Fprintf(w, "%s\t%.2f\t%.2f%%\t%s\t%s\t%s\t%s\n", point.Value, s.ActualRPS, s.ErrorRate,
+					sweepMillis(s.P50Ms), sweepMillis(s.P95Ms), sweepMillis(s.P99Ms), bar)
+			}
+		}
+		w.Flush()
+	}
+	if len(report.Platforms) > 1 {
+		printSweepMatrix(report, curve, curveName)
+	}
+}
+
+// printSweepMatrix prints the latency of every platform side by side, one
+// row per value
+func printSweepMatrix(report *SweepReport, curve func(*RunSummary) float64, curveName string) {
+	fmt.Printf("\n%s %s by %s\n", sweepLatencyName(report), curveName, report.Param)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "%s\t%s\n", report.Param, strings.Join(report.Platforms, "\t"))
+	var values []string
+	seen := make(map[string]bool)
+	cells := make(map[[2]string]string)
+	for _, point := range report.Points {
+		if !seen[point.Value] {
+			seen[point.Value] = true
+			values = append(values, point.Value)
+		}
+		cell := "failed"
+		if s := sweepLatency(point); s != nil {
+			cell = sweepMillis(curve(s))
+		}
+		cells[[2]string{point.Value, point.Platform}] = cell
+	}
+	for _, value := range values {
+		row := []string{value}
+		for _, platform := range report.Platforms {
+			row = append(row, cells[[2]string{value, platform}])
+		}
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	w.Flush()
+}
+
+// sweepLatencyName names what the latency curve covers
+func sweepLatencyName(report *SweepReport) string {
+	if report.Param == "page-size" {
+		return "Product list"
+	}
+	return "Overall"
+}
+
+// writeSweepCSV writes one row per platform and value, for plotting the curve
+func writeSweepCSV(path string, report *SweepReport) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	millis := func(v float64) string {
+		if v == 0 {
+			return ""
+		}
+		return strconv.FormatFloat(v, 'f', 1, 64)
+	}
+	w := csv.NewWriter(file)
+	w.Write([]string{"platform", report.Param, "actualRPS", "errorRate", "p50Ms", "p95Ms", "p99Ms", "totalRequests",
+		"productsP50Ms", "productsP95Ms", "productsP99Ms", "error"})
+	for _, point := range report.Points {
+		if point.Summary == nil {
+			w.Write([]string{point.Platform, point.Value, "", "", "", "", "", "", "", "", "", point.Error})
+			continue
+		}
+		s := point.Summary
+		row := []string{
+			point.Platform,
+			point.Value,
+			strconv.FormatFloat(s.ActualRPS, 'f', 2, 64),
+			strconv.FormatFloat(s.ErrorRate, 'f', 2, 64),
+			millis(s.P50Ms),
+			millis(s.P95Ms),
+			millis(s.P99Ms),
+			strconv.FormatInt(s.TotalRequests, 10),
+			"", "", "",
+			"",
+		}
+		if p := point.Products; p != nil {
+			row[8], row[9], row[10] = millis(p.P50Ms), millis(p.P95Ms), millis(p.P99Ms)
+		}
+		w.Write(row)
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// sweepConfigs sets each value in a copy of a platform's base config. Every
+// value is checked before the first test runs, so a typo fails right away.
+func sweepConfigs(platform, configFile, param string, values []string, duration time.Duration) ([][]byte, error) {
+	data, err := os.ReadFile(configFile)
+	if err != nil {
+		return nil, err
+	}
+	configs := make([][]byte, len(values))
+	for i, value := range values {
+		decoded, err := decodeJSONNumbers(data)
+		if err != nil {
+			return nil, fmt.Errorf("parsing %s: %v", configFile, err)
+		}
+		config, ok := decoded.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s is not a config object", configFile)
+		}
+		if err := applySweepValue(config, platform, param, value, duration); err != nil {
+			return nil, fmt.Errorf("%s: %s=%s: %v", platform, param, value, err)
+		}
+		if err := setConfigPath(config, "Test.Duration", json.Number(strconv.FormatInt(int64(duration), 10))); err != nil {
+			return nil, fmt.Errorf("%s: %v", platform, err)
+		}
+		configs[i], _ = json.MarshalIndent(config, "", "  ")
+	}
+	return configs, nil
+}
+
+// splitList splits a comma-separated flag value
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// runSweep implements "wsm sweep": run a short test per value of one
+// parameter and tabulate the results
+func runSweep(args []string) {
+	fs := flag.NewFlagSet("sweep", flag.ExitOnError)
+	platformList := fs.String("platform", "", "Platforms to test, comma-separated (spree, medusa, saleor)")
+	binaryList := fs.String("binary", "", "Runner binaries, comma-separated in -platform order (default ./<platform>_benchmark)")
+	configList := fs.String("config", "", "Base config of each platform, comma-separated in -platform order; the swept parameter is set in a copy per value")
+	param := fs.String("param", "", "Parameter to vary: workers, rps, payload, page-size, or a config path such as Test.MaxQueueSize")
+	valueList := fs.String("values", "", "Comma-separated values, e.g. 50,100,200,400 (page-size default "+defaultPageSizes+")")
+	duration := fs.Duration("duration", time.Minute, "Length of the test at each value")
+	cooldown := fs.Duration("cooldown", 0, "Pause between tests")
+	timeout := fs.String("timeout", "", "Kill a test after this long (default: 3 x -duration)")
+	outDir := fs.String("out-dir", "", "Results directory (default sweep_results_<timestamp>)")
+	var runnerArgs stringList
+	fs.Var(&runnerArgs, "arg", "Extra argument for the runners (repeatable)")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: wsm sweep -platform <platforms> -config <configs> -param <param> -values <v1,v2,...>")
+		fs.PrintDefaults()
+		fmt.Fprintln(os.Stderr, "\nParameters:")
+		for _, name := range []string{"workers", "rps", "payload", "page-size"} {
+			fmt.Fprintf(os.Stderr, "  %-10s %s\n", name, sweepParams[name])
+		}
+	}
+	fs.Parse(args)
+
+	if *param == "page-size" && *valueList == "" {
+		*valueList = defaultPageSizes
+	}
+	platforms := splitList(strings.ToLower(*platformList))
+	configFiles := splitList(*configList)
+	values := splitList(*valueList)
+	if len(platforms) == 0 || len(configFiles) == 0 || *param == "" || len(values) == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if len(configFiles) != len(platforms) {
+		log.Fatalf("sweep: %d platforms but %d configs; give one config per platform", len(platforms), len(configFiles))
+	}
+	binaries := splitList(*binaryList)
+	if len(binaries) == 0 {
+		for _, platform := range platforms {
+			binaries = append(binaries, "./"+platform+"_benchmark")
+		}
+	} else if len(binaries) != len(platforms) {
+		log.Fatalf("sweep: %d platforms but %d binaries; give one binary per platform", len(platforms), len(binaries))
+	}
+	if _, named := sweepParams[*param]; !named && !strings.Contains(*param, ".") {
+		log.Fatalf("sweep: unknown parameter %q (use workers, rps, payload, page-size or a config path)", *param)
+	}
+	if *duration <= 0 {
+		log.Fatal("sweep: -duration must be positive")
+	}
+	if *timeout == "" {
+		*timeout = (3 * *duration).String()
+	} else if _, err := time.ParseDuration(*timeout); err != nil {
+		log.Fatalf("sweep: invalid timeout %q", *timeout)
+	}
+
+	configs := make(map[string][][]byte)
+	for i, platform := range platforms {
+		if configs[platform] != nil {
+			log.Fatalf("sweep: platform %s is listed twice", platform)
+		}
+		platformConfigs, err := sweepConfigs(platform, configFiles[i], *param, values, *duration)
+		if err != nil {
+			log.Fatalf("sweep: %v", err)
+		}
+		configs[platform] = platformConfigs
+	}
+
+	resultsDir := *outDir
+	if resultsDir == "" {
+		resultsDir = "sweep_results_" + time.Now().Format("20060102_150405")
+	}
+	report := &SweepReport{
+		Platforms: platforms,
+		Configs:   make(map[string]string),
+		Param:     *param,
+		Duration:  duration.String(),
+		StartTime: time.Now().Format(time.RFC3339),
+	}
+	for i, platform := range platforms {
+		report.Configs[platform] = configFiles[i]
+	}
+
+	// Every platform at a value before the next value, so a drift of the
+	// test environment over the sweep does not line up with one platform
+	failed, tests := 0, len(values)*len(platforms)
+	for i, value := range values {
+		for j, platform := range platforms {
+			n := i*len(platforms) + j + 1
+			if n > 1 && *cooldown > 0 {
+				fmt.Printf("Cooling down for %s\n", *cooldown)
+				time.Sleep(*cooldown)
+			}
+			runDir := filepath.Join(resultsDir, fmt.Sprintf("%02d_%s", i+1, sanitizeSweepValue(value)))
+			if len(platforms) > 1 {
+				runDir = filepath.Join(runDir, platform)
+			}
+			if err := os.MkdirAll(runDir, 0755); err != nil {
+				log.Fatalf("sweep: %v", err)
+			}
+			// Written next to the base config, so relative paths in it
+			// (profiles, credential files) still resolve
+			config := filepath.Join(filepath.Dir(configFiles[j]), fmt.Sprintf(".sweep_%s_%02d.json", platform, i+1))
+			if err := os.WriteFile(config, configs[platform][i], 0600); err != nil {
+				log.Fatalf("sweep: %v", err)
+			}
+			os.WriteFile(filepath.Join(runDir, "config.json"), configs[platform][i], 0600)
+			fmt.Printf("Test %d of %d: %s, %s=%s\n", n, tests, platform, *param, value)
+
+			point := SweepPoint{Platform: platform, Value: value}
+			test := SuiteTest{Platform: platform, Binary: binaries[j], Config: config, Args: runnerArgs, Timeout: *timeout}
+			err := runTest(test, runDir)
+			os.Remove(config)
+			if err == nil {
+				results, summaries := collectResults(runDir)
+				if summary, ok := summaries[platform]; ok {
+					point.Results = results[platform]
+					point.Summary = &summary
+				} else {
+					err = fmt.Errorf("no %s results in %s", platform, runDir)
+				}
+			}
+			if err == nil && *param == "page-size" {
+				if point.Products, err = operationSummary(point.Results, sweepListOperation); err != nil {
+					point.Summary = nil
+				}
+			}
+			if err != nil {
+				point.Error = err.Error()
+				failed++
+			}
+			report.Points = append(report.Points, point)
+		}
+	}
+	report.EndTime = time.Now().Format(time.RFC3339)
+
+	printSweepReport(report)
+	data, _ := json.MarshalIndent(report, "", "  ")
+	if err := os.WriteFile(filepath.Join(resultsDir, "sweep.json"), data, 0644); err != nil {
+		log.Fatalf("sweep: %v", err)
+	}
+	if err := writeSweepCSV(filepath.Join(resultsDir, "sweep.csv"), report); err != nil {
+		log.Fatalf("sweep: %v", err)
+	}
+	fmt.Printf("Results saved to %s (sweep.json, sweep.csv)\n", resultsDir)
+	if failed > 0 {
+		log.Fatalf("sweep: %d of %d tests failed", failed, tests)
+	}
+}
+
+// sanitizeSweepValue makes a value usable in a directory name
+func sanitizeSweepValue(value string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == ':' || r == ' ' {
+			return '_'
+		}
+		return r
+	}, value)
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+)
+
+// decodeJSONNumbers decodes with numbers kept as written, as the runners do
+// when they compute the checksum
+func decodeJSONNumbers(data []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var v interface{}
+	err := decoder.Decode(&v)
+	return v, err
+}
+
+// verifyResults recomputes the SHA-256 a runner sealed a results file with
+// (-checksum). It returns the recorded and the recomputed digest.
+func verifyResults(path string) (string, string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", "", err
+	}
+	decoded, err := decodeJSONNumbers(data)
+	if err != nil {
+		return "", "", fmt.Errorf("parsing %s: %v", path, err)
+	}
+	results, ok := decoded.(map[string]interface{})
+	if !ok {
+		return "", "", fmt.Errorf("%s is not a results object", path)
+	}
+	integrity, _ := results["integrity"].(map[string]interface{})
+	recorded, _ := integrity["digest"].(string)
+	if recorded == "" {
+		return "", "", fmt.Errorf("%s has no checksum (run the test with -checksum)", path)
+	}
+	if algorithm, _ := integrity["algorithm"].(string); algorithm != "sha256" {
+		return "", "", fmt.Errorf("%s: unsupported checksum algorithm %q", path, algorithm)
+	}
+
+	delete(results, "integrity")
+	canonical, err := json.Marshal(results)
+	if err != nil {
+		return "", "", err
+	}
+	sum := sha256.Sum256(canonical)
+	return recorded, hex.EncodeToString(sum[:]), nil
+}
+
+// runVerify checks that results files are unmodified since the run
+func runVerify(args []string) {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: wsm verify <results.json>...")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	failed := false
+	for _, path := range fs.Args() {
+		recorded, actual, err := verifyResults(path)
+		switch {
+		case err != nil:
+			fmt.Printf("ERROR     %s: %v\n", path, err)
+			failed = true
+		case recorded != actual:
+			fmt.Printf("MODIFIED  %s: checksum %s, content hashes to %s\n", path, recorded, actual)
+			failed = true
+		default:
+			fmt.Printf("OK        %s (sha256 %s)\n", path, actual)
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}